@@ -1,11 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
 
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/config"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 )
@@ -33,6 +36,114 @@ func newSingleHostReverseProxy(targetHost string) (*httputil.ReverseProxy, error
 	return proxy, nil
 }
 
+// routeRule merepresentasikan satu entri allowlist publik, misal "GET /api/v1/products/".
+type routeRule struct {
+	method string // Kosong berarti semua method
+	path   string
+	prefix bool // Path berakhiran "/" dicocokkan sebagai prefix
+}
+
+func parseRouteRules(entries []string) []routeRule {
+	rules := make([]routeRule, 0, len(entries))
+	for _, entry := range entries {
+		rule := routeRule{}
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			rule.path = fields[0]
+		case 2:
+			rule.method = strings.ToUpper(fields[0])
+			rule.path = fields[1]
+		default:
+			logger.Warn(fmt.Sprintf("Gateway: ignoring malformed public route entry '%s'", entry))
+			continue
+		}
+		if rule.method == "*" {
+			rule.method = ""
+		}
+		rule.prefix = strings.HasSuffix(rule.path, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (rr routeRule) matches(r *http.Request) bool {
+	if rr.method != "" && rr.method != r.Method {
+		return false
+	}
+	if rr.prefix {
+		return matchesPrefix(r.URL.Path, rr.path)
+	}
+	return r.URL.Path == rr.path
+}
+
+// matchesPrefix juga mencocokkan path tanpa trailing slash, misal "/api/v1/orders" untuk prefix "/api/v1/orders/".
+func matchesPrefix(path, prefix string) bool {
+	return strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/")
+}
+
+type authPolicy struct {
+	secret            []byte
+	protectedPrefixes []string
+	publicRoutes      []routeRule
+}
+
+func (p authPolicy) requiresAuth(r *http.Request) bool {
+	for _, rule := range p.publicRoutes {
+		if rule.matches(r) {
+			return false
+		}
+	}
+	for _, prefix := range p.protectedPrefixes {
+		if matchesPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// authMiddleware memverifikasi bearer token dan meneruskan identitas user ke service tujuan
+// lewat header X-User-ID/X-User-Email. Header identitas dari client selalu dibuang.
+func authMiddleware(next http.Handler, policy authPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.StripIdentityHeaders(r.Header)
+		protected := policy.requiresAuth(r)
+
+		tokenString, err := auth.BearerToken(r.Header.Get("Authorization"))
+		if err != nil {
+			if protected {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSONError(w, http.StatusUnauthorized, "Authorization bearer token is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString, policy.secret)
+		if err != nil {
+			if protected {
+				logger.Warn(fmt.Sprintf("Gateway: rejected token for %s %s: %v", r.Method, r.URL.Path, err))
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeJSONError(w, http.StatusUnauthorized, auth.ErrInvalidToken.Error())
+				return
+			}
+			// Rute publik tetap dilayani secara anonim walaupun token tidak valid
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		auth.SetIdentityHeaders(r.Header, claims)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
 	cfg := config.LoadGatewayConfig()
 	logger.Info("Starting API Gateway on port " + cfg.ListenPort)
@@ -41,12 +152,20 @@ func main() {
 
 	// Rute dan target service
 	serviceMappings := map[string]string{
-		"/api/v1/users/":      cfg.UserServiceURL, // Trailing slash penting untuk ServeMux matching
-		"/api/v1/products/":   cfg.ProductServiceURL,
-		"/api/v1/stock-info/": cfg.WarehouseServiceURL,
-		"/api/v1/warehouses/": cfg.WarehouseServiceURL,
-		"/api/v1/stocks/":     cfg.WarehouseServiceURL,
-		"/api/v1/orders/":     cfg.OrderServiceURL,
+		"/api/v1/users/":           cfg.UserServiceURL, // Trailing slash penting untuk ServeMux matching
+		"/api/v1/products/":        cfg.ProductServiceURL,
+		"/api/v1/stock-info/":      cfg.WarehouseServiceURL,
+		"/api/v1/warehouses/":      cfg.WarehouseServiceURL,
+		"/api/v1/stocks/":          cfg.WarehouseServiceURL,
+		"/api/v1/transfer-orders/": cfg.WarehouseServiceURL,
+		"/api/v1/stock-alerts/":    cfg.WarehouseServiceURL,
+		"/api/v1/orders/":          cfg.OrderServiceURL,
+		"/api/v1/admin/sagas/":     cfg.OrderServiceURL,
+		"/api/v1/admin/returns/":   cfg.OrderServiceURL,
+		"/api/v1/admin/orders/":    cfg.OrderServiceURL,
+		"/api/v1/admin/shipments/": cfg.OrderServiceURL,
+		"/api/v1/payments/":        cfg.OrderServiceURL, // Webhook payment provider, diverifikasi lewat tanda tangan di Order Service
+		"/api/v1/cart/":            cfg.CartServiceURL,  // Tidak diproteksi gateway agar guest bisa memakai keranjang
 	}
 
 	for pathPrefix, targetHost := range serviceMappings {
@@ -59,20 +178,31 @@ func main() {
 		// Karena layanan kita mengharapkan path lengkap (misal /api/v1/users/login),
 		// dan ServeMux dengan trailing slash akan cocok dengan semua subpath,
 		// kita tidak perlu strip prefix di sini.
-		mux.Handle(pathPrefix, http.HandlerFunc(func(p *httputil.ReverseProxy, prefix string) http.HandlerFunc {
+		handler := http.HandlerFunc(func(p *httputil.ReverseProxy, prefix string) http.HandlerFunc {
 			return func(w http.ResponseWriter, r *http.Request) {
 				// Log request yang masuk ke gateway jika diperlukan
 				// logger.Info(fmt.Sprintf("Gateway: a %s %s request.", r.Method, r.URL.Path))
 				p.ServeHTTP(w, r)
 			}
-		}(proxy, pathPrefix))) // Capture proxy & prefix dalam closure
+		}(proxy, pathPrefix)) // Capture proxy & prefix dalam closure
+		mux.Handle(pathPrefix, handler)
+		// Daftarkan juga root tanpa trailing slash (misal GET/POST /api/v1/orders),
+		// agar ServeMux tidak me-redirect ke versi dengan slash yang lalu di-redirect balik oleh Gin.
+		mux.Handle(strings.TrimSuffix(pathPrefix, "/"), handler)
 
 		logger.Info(fmt.Sprintf("Routing %s to %s", pathPrefix, targetHost))
 	}
 
+	policy := authPolicy{
+		secret:            cfg.JWTSecretKey,
+		protectedPrefixes: cfg.ProtectedPrefixes,
+		publicRoutes:      parseRouteRules(cfg.PublicRoutes),
+	}
+	logger.Info(fmt.Sprintf("Gateway auth: protected prefixes %v, public routes %v", cfg.ProtectedPrefixes, cfg.PublicRoutes))
+
 	server := &http.Server{
 		Addr:    ":" + cfg.ListenPort,
-		Handler: mux,
+		Handler: authMiddleware(mux, policy),
 	}
 
 	logger.Info(fmt.Sprintf("API Gateway successfully configured and listening on :%s", cfg.ListenPort))
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/api"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/service"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/config"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/database"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/robfig/cron/v3"
+)
+
+func main() {
+	// Load Config
+	dbCfg := config.LoadCartDBConfig()
+	serverCfg := config.LoadServerConfig("8085") // Cart service default port 8085
+	authCfg := config.LoadAuthConfig()
+	idempotencyCfg := config.LoadIdempotencyConfig()
+	productServiceURL := config.GetEnv("PRODUCT_SERVICE_URL", "http://localhost:8082")
+	warehouseServiceURL := config.GetEnv("WAREHOUSE_SERVICE_URL", "http://localhost:8083")
+	orderServiceURL := config.GetEnv("ORDER_SERVICE_URL", "http://localhost:8084")
+	guestCartTTL := time.Duration(config.GetEnvAsInt("GUEST_CART_TTL_DAYS", 30)) * 24 * time.Hour
+
+	logger.Info("Starting Cart Service...")
+
+	// Setup Database
+	db, err := database.Connect(dbCfg.DSN)
+	if err != nil {
+		logger.Error("Failed to connect to database for Cart Service", err, nil)
+		return
+	}
+	defer db.Close()
+
+	// Setup Dependencies
+	cartRepository := repository.NewPostgresCartRepository(db)
+	cartSvc := service.NewCartService(cartRepository,
+		service.NewHTTPProductClient(productServiceURL), // Harga terkini
+		service.NewHTTPStockClient(warehouseServiceURL), // Ketersediaan stok
+		service.NewHTTPOrderClient(orderServiceURL),     // Checkout
+	)
+	cartHandler := api.NewCartHandler(cartSvc)
+	idempotencyStore := idempotency.NewPostgresStore(db)
+
+	// Job untuk membersihkan keranjang guest yang ditinggalkan dan Idempotency-Key yang sudah kedaluwarsa
+	scheduler := cron.New()
+	if _, err := scheduler.AddFunc("@every 1h", func() {
+		ctx := context.Background()
+		if deleted, err := cartSvc.CleanupGuestCarts(ctx, guestCartTTL); err != nil {
+			logger.Error("Scheduler: CleanupGuestCarts failed", err, nil)
+		} else if deleted > 0 {
+			logger.Info(fmt.Sprintf("Scheduler: deleted %d stale guest carts", deleted))
+		}
+		if _, err := idempotencyStore.DeleteExpired(ctx); err != nil {
+			logger.Error("Scheduler: DeleteExpired idempotency keys failed", err, nil)
+		}
+	}); err != nil {
+		logger.Error("Failed to schedule cart cleanup job", err, nil)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	// Setup Gin Router
+	router := gin.Default()
+	apiV1 := router.Group("/api/v1")
+	cartHandler.RegisterRoutes(apiV1,
+		auth.OptionalGinMiddleware(authCfg.JWTSecretKey, authCfg.TrustIdentityHeaders),
+		idempotency.GinMiddleware(idempotencyStore, idempotencyCfg.KeyTTL, idempotencyCfg.LockLease),
+	)
+
+	logger.Info("Cart Service running on port " + serverCfg.Port)
+	logger.Info("Cart Service connecting to Product Service at " + productServiceURL)
+	logger.Info("Cart Service connecting to Warehouse Service at " + warehouseServiceURL)
+	logger.Info("Cart Service connecting to Order Service at " + orderServiceURL)
+	if errSrv := router.Run(serverCfg.Port); errSrv != nil {
+		logger.Error("Failed to run Cart Service server", errSrv, nil)
+	}
+}
@@ -1,22 +1,32 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/api"
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/service"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/config"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/database"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/events"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/robfig/cron/v3"
 )
 
 func main() {
 	// Load Config
 	dbCfg := config.LoadOrderDBConfig()
 	serverCfg := config.LoadServerConfig("8084") // Order service default port 8084
+	authCfg := config.LoadAuthConfig()
+	idempotencyCfg := config.LoadIdempotencyConfig()
+	paymentCfg := config.LoadPaymentConfig()
 	warehouseServiceURL := config.GetEnv("WAREHOUSE_SERVICE_URL", "http://localhost:8083")
+	productServiceURL := config.GetEnv("PRODUCT_SERVICE_URL", "http://localhost:8082")
 
 	logger.Info("Starting Order Service...")
 
@@ -37,17 +47,62 @@ func main() {
 
 	// Setup Dependencies
 	orderRepository := repository.NewPostgresOrderRepository(db)
-	warehouseClient := service.NewHTTPWarehouseClient(warehouseServiceURL) // Client ke Warehouse Service
-	ordService := service.NewOrderService(orderRepository, warehouseClient, paymentTimeoutMinutes)
+	warehouseClient := service.NewHTTPWarehouseClient(warehouseServiceURL, authCfg.JWTSecretKey) // Client ke Warehouse Service
+	productClient := service.NewHTTPProductClient(productServiceURL)                             // Client ke Product Service untuk harga resmi
+	var paymentProvider service.PaymentProvider
+	switch paymentCfg.Provider {
+	case service.FakeProviderName:
+		logger.Warn("Using the fake payment provider")
+		paymentProvider = service.NewFakePaymentProvider(paymentCfg.WebhookSecret, paymentCfg.CheckoutBaseURL)
+	default:
+		logger.Error("Invalid payment configuration", fmt.Errorf("unsupported PAYMENT_PROVIDER %q", paymentCfg.Provider), nil)
+		return
+	}
+	ordService := service.NewOrderServiceWithConfig(orderRepository, warehouseClient, productClient, paymentProvider, paymentTimeoutMinutes, paymentCfg.SimulationEnabled)
 	orderHandler := api.NewOrderHandler(ordService)
+	idempotencyStore := idempotency.NewPostgresStore(db)
+
+	// Job untuk membersihkan Idempotency-Key yang sudah kedaluwarsa
+	scheduler := cron.New()
+	if _, err := scheduler.AddFunc("@every 1h", func() {
+		if _, err := idempotencyStore.DeleteExpired(context.Background()); err != nil {
+			logger.Error("Scheduler: DeleteExpired idempotency keys failed", err, nil)
+		}
+	}); err != nil {
+		logger.Error("Failed to schedule idempotency key cleanup job", err, nil)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	// Relay outbox: kirim event order ke publisher yang dipilih (default: file JSON Lines)
+	eventPublisher, err := events.NewPublisher(events.Config{
+		Kind:          config.GetEnv("EVENT_PUBLISHER", events.PublisherFile),
+		FilePath:      config.GetEnv("EVENT_FILE_PATH", "order-events.jsonl"),
+		WebhookURL:    config.GetEnv("EVENT_WEBHOOK_URL", ""),
+		NotifyChannel: config.GetEnv("EVENT_NOTIFY_CHANNEL", "order_events"),
+	}, db)
+	if err != nil {
+		logger.Error("Invalid event publisher configuration", err, nil)
+		return
+	}
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := service.NewOutboxRelay(orderRepository, eventPublisher)
+	go outboxRelay.Run(relayCtx, time.Duration(config.GetEnvAsInt("OUTBOX_RELAY_INTERVAL_SECONDS", 2))*time.Second)
 
 	// Setup Gin Router
 	router := gin.Default()
 	apiV1 := router.Group("/api/v1")
-	orderHandler.RegisterRoutes(apiV1)
+	authMiddleware := auth.GinMiddleware(authCfg.JWTSecretKey, authCfg.TrustIdentityHeaders)
+	orderHandler.RegisterRoutes(apiV1, authMiddleware, idempotency.GinMiddleware(idempotencyStore, idempotencyCfg.KeyTTL, idempotencyCfg.LockLease))
+	if paymentCfg.SimulationEnabled {
+		logger.Warn("PAYMENT_SIMULATION_ENABLED is set; payments can be simulated via POST /api/v1/orders/:order_id/payments/simulate")
+		orderHandler.RegisterPaymentSimulationRoute(apiV1, authMiddleware)
+	}
 
 	logger.Info("Order Service running on port " + serverCfg.Port)
 	logger.Info("Order Service connecting to Warehouse Service at " + warehouseServiceURL)
+	logger.Info("Order Service connecting to Product Service at " + productServiceURL)
 	if errSrv := router.Run(serverCfg.Port); errSrv != nil {
 		logger.Error("Failed to run Order Service server", errSrv, nil)
 	}
@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/config"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/database"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 	warehouseAPI "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/api"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 	warehouseRepo "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
 	warehouseService "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/service"
+	"github.com/robfig/cron/v3"
 )
 
 func main() {
 	// Load Config
 	dbCfg := config.LoadWarehouseDBConfig()
 	serverCfg := config.LoadServerConfig("8083") // Warehouse service default port 8083
+	idempotencyCfg := config.LoadIdempotencyConfig()
+	warehouseCfg := config.LoadWarehouseConfig()
+	authCfg := config.LoadAuthConfig()
 
 	// Setup Logger
 	logger.Info("Starting Warehouse Service...")
@@ -28,14 +38,58 @@ func main() {
 
 	// Setup Dependencies
 	whRepository := warehouseRepo.NewPostgresWarehouseRepository(db)
-	whService := warehouseService.NewWarehouseService(whRepository)
+	allocation, err := warehouseService.NewAllocationStrategy(domain.AllocationStrategyName(warehouseCfg.AllocationStrategy))
+	if err != nil {
+		logger.Error("Invalid WAREHOUSE_ALLOCATION_STRATEGY", err, nil)
+		return
+	}
+	logger.Info("Default stock allocation strategy: " + string(allocation.Name()))
+	whService := warehouseService.NewWarehouseServiceWithConfig(whRepository, allocation, warehouseCfg.AdjustmentApprovalThreshold)
 	whHandler := warehouseAPI.NewWarehouseHandler(whService)
+	idempotencyStore := idempotency.NewPostgresStore(db)
+
+	// Job untuk melepas reservasi yang kedaluwarsa (misal order gagal dibuat setelah reservasi)
+	scheduler := cron.New()
+	if _, err := scheduler.AddFunc("@every 1m", func() {
+		if _, err := whService.ReleaseExpiredReservations(context.Background()); err != nil {
+			logger.Error("Scheduler: ReleaseExpiredReservations failed", err, nil)
+		}
+	}); err != nil {
+		logger.Error("Failed to schedule expired reservation job", err, nil)
+	}
+	// Job untuk membersihkan Idempotency-Key yang sudah kedaluwarsa
+	if _, err := scheduler.AddFunc("@every 1h", func() {
+		if _, err := idempotencyStore.DeleteExpired(context.Background()); err != nil {
+			logger.Error("Scheduler: DeleteExpired idempotency keys failed", err, nil)
+		}
+	}); err != nil {
+		logger.Error("Failed to schedule idempotency key cleanup job", err, nil)
+	}
+	// Evaluator alert stok rendah: memeriksa stok yang berubah setelah setiap operasi stok (lewat buku besar stok),
+	// ditambah evaluasi penuh semua ambang batas secara berkala
+	alertEvaluator := warehouseService.NewStockAlertEvaluator(whRepository)
+	if _, err := scheduler.AddFunc("@every 15m", func() {
+		if _, err := alertEvaluator.EvaluateAll(context.Background()); err != nil {
+			logger.Error("Scheduler: stock alert evaluation failed", err, nil)
+		}
+	}); err != nil {
+		logger.Error("Failed to schedule stock alert evaluation job", err, nil)
+	}
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	evaluatorCtx, stopEvaluator := context.WithCancel(context.Background())
+	defer stopEvaluator()
+	go alertEvaluator.Run(evaluatorCtx, time.Duration(warehouseCfg.StockAlertIntervalSeconds)*time.Second)
 
 	// Setup Gin Router
 	router := gin.Default()
 
 	apiV1 := router.Group("/api/v1")
-	whHandler.RegisterRoutes(apiV1) // Pass router, not the group directly to RegisterRoutes
+	whHandler.RegisterRoutes(apiV1,
+		auth.OptionalGinMiddleware(authCfg.JWTSecretKey, authCfg.TrustIdentityHeaders),
+		idempotency.GinMiddleware(idempotencyStore, idempotencyCfg.KeyTTL, idempotencyCfg.LockLease),
+	)
 
 	logger.Info("Warehouse Service running on port " + serverCfg.Port)
 	if err := router.Run(serverCfg.Port); err != nil {
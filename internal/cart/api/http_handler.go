@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/service"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+type CartHandler struct {
+	cartService service.CartService
+}
+
+func NewCartHandler(cs service.CartService) *CartHandler {
+	return &CartHandler{cartService: cs}
+}
+
+// RegisterRoutes memasang rute keranjang. authMiddleware boleh meloloskan request anonim (lihat auth.OptionalGinMiddleware)
+// karena guest juga punya keranjang; merge dan checkout tetap mewajibkan login.
+func (h *CartHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware, idempotencyMiddleware gin.HandlerFunc) {
+	cartRoutes := router.Group("/cart", authMiddleware)
+	{
+		cartRoutes.GET("", h.GetCart)
+		cartRoutes.POST("/items", h.AddItem)
+		cartRoutes.PUT("/items/:product_id", h.UpdateItem)
+		cartRoutes.DELETE("/items/:product_id", h.RemoveItem)
+		cartRoutes.POST("/merge", h.MergeGuestCart)
+		cartRoutes.POST("/checkout", idempotencyMiddleware, h.Checkout)
+	}
+}
+
+// owner menentukan keranjang pemanggil: keranjang user jika login, selain itu keranjang guest dari header token.
+func owner(c *gin.Context) service.CartOwner {
+	if identity, ok := auth.IdentityFromGin(c); ok {
+		return service.CartOwner{UserID: identity.UserID}
+	}
+	return service.CartOwner{GuestToken: strings.TrimSpace(c.GetHeader(domain.HeaderGuestToken))}
+}
+
+func requireIdentity(c *gin.Context) (auth.Identity, bool) {
+	identity, ok := auth.IdentityFromGin(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return auth.Identity{}, false
+	}
+	return identity, true
+}
+
+// writeError memetakan error service ke status HTTP untuk semua rute keranjang.
+func writeError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCartNotFound), errors.Is(err, repository.ErrCartItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidQuantity), errors.Is(err, service.ErrCartFull),
+		errors.Is(err, service.ErrUnknownProduct), errors.Is(err, service.ErrCartEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPriceLookupFailed):
+		logger.Error(op+": product price lookup failed", err, nil)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Product prices are temporarily unavailable, please retry"})
+	default:
+		logger.Error(op+": unhandled service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process cart"})
+	}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	view, err := h.cartService.GetCart(c.Request.Context(), owner(c))
+	if err != nil {
+		writeError(c, "GetCart Hdl", err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) AddItem(c *gin.Context) {
+	var req domain.AddItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	view, err := h.cartService.AddItem(c.Request.Context(), owner(c), req)
+	if err != nil {
+		writeError(c, "AddItem Hdl", err)
+		return
+	}
+	if view.GuestToken != "" {
+		// Keranjang guest baru: client menyimpan token ini dan mengirimnya di request berikutnya
+		c.Header(domain.HeaderGuestToken, view.GuestToken)
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) UpdateItem(c *gin.Context) {
+	var uri domain.ItemURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID: " + err.Error()})
+		return
+	}
+	var req domain.UpdateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	view, err := h.cartService.UpdateItem(c.Request.Context(), owner(c), uri.ProductID, *req.Quantity)
+	if err != nil {
+		writeError(c, "UpdateItem Hdl", err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	var uri domain.ItemURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID: " + err.Error()})
+		return
+	}
+	view, err := h.cartService.RemoveItem(c.Request.Context(), owner(c), uri.ProductID)
+	if err != nil {
+		writeError(c, "RemoveItem Hdl", err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// MergeGuestCart dipanggil client setelah login dengan token keranjang guest yang dimilikinya.
+func (h *CartHandler) MergeGuestCart(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	var req domain.MergeCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	view, err := h.cartService.MergeGuestCart(c.Request.Context(), identity.UserID, req.GuestToken)
+	if err != nil {
+		writeError(c, "MergeGuestCart Hdl", err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) Checkout(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+
+	created, err := h.cartService.Checkout(c.Request.Context(), identity.UserID, domain.CheckoutOptions{
+		Authorization:  c.GetHeader("Authorization"),
+		IdempotencyKey: c.GetHeader(idempotency.HeaderKey),
+	})
+	if err != nil {
+		var priceErr *service.PriceChangedError
+		if errors.As(err, &priceErr) {
+			// Snapshot keranjang sudah diperbarui; client menampilkan harga baru lalu checkout ulang
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "quote": priceErr.Quote})
+			return
+		}
+		var orderErr *service.OrderServiceError
+		if errors.As(err, &orderErr) {
+			if orderErr.StatusCode >= 400 && orderErr.StatusCode < 500 {
+				// Misal stok tidak cukup (409) atau produk tidak dikenal (400): diteruskan apa adanya ke client
+				c.JSON(orderErr.StatusCode, gin.H{"error": orderErr.Message})
+				return
+			}
+			logger.Error("Checkout Hdl: order service failed", err, nil)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Order service failed to create the order"})
+			return
+		}
+		if errors.Is(err, service.ErrOrderServiceUnavailable) {
+			logger.Error("Checkout Hdl: order service unavailable", err, nil)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Order service is temporarily unavailable, please retry"})
+			return
+		}
+		writeError(c, "Checkout Hdl", err)
+		return
+	}
+	c.JSON(http.StatusCreated, domain.CheckoutResponse{Order: created.Order, Payment: created.Payment})
+}
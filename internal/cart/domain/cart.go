@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"time"
+
+	orderDomain "github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+const (
+	MaxCartLines    = 50 // Jumlah produk berbeda dalam satu keranjang
+	MaxItemQuantity = 99 // Kuantitas maksimum per produk
+)
+
+// HeaderGuestToken membawa token keranjang guest. Token dikembalikan saat keranjang guest pertama kali dibuat.
+const HeaderGuestToken = "X-Guest-Cart-Token"
+
+// Cart adalah keranjang milik user (UserID terisi) atau guest (diakses lewat token).
+type Cart struct {
+	ID        string
+	UserID    *string
+	Items     []CartItem
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CartItem adalah satu baris keranjang. PriceSnapshot adalah harga satuan saat item terakhir ditambahkan.
+type CartItem struct {
+	ProductID     string
+	Quantity      int
+	PriceSnapshot money.Money
+	AddedAt       time.Time
+	UpdatedAt     time.Time
+}
+
+// FindItem mengembalikan item untuk produk tertentu jika ada di keranjang.
+func (c *Cart) FindItem(productID string) (*CartItem, bool) {
+	for i := range c.Items {
+		if c.Items[i].ProductID == productID {
+			return &c.Items[i], true
+		}
+	}
+	return nil, false
+}
+
+// Status ketersediaan baris keranjang berdasarkan stok di Warehouse Service
+const (
+	AvailabilityInStock           = "IN_STOCK"
+	AvailabilityInsufficientStock = "INSUFFICIENT_STOCK" // Ada stok, tapi kurang dari kuantitas di keranjang
+	AvailabilityOutOfStock        = "OUT_OF_STOCK"
+	AvailabilityUnknown           = "UNKNOWN" // Warehouse Service tidak bisa dihubungi
+	AvailabilityDiscontinued      = "DISCONTINUED"
+)
+
+// CartView adalah keranjang yang sudah diperkaya dengan harga dan stok terkini, dikembalikan ke client.
+type CartView struct {
+	ID         string     `json:"id,omitempty"` // Kosong jika keranjang belum pernah dibuat
+	UserID     string     `json:"user_id,omitempty"`
+	GuestToken string     `json:"guest_token,omitempty"` // Hanya diisi saat keranjang guest baru dibuat
+	Items      []CartLine `json:"items"`
+	ItemCount  int        `json:"item_count"` // Total kuantitas semua baris
+	// Subtotal dari harga terkini; kosong jika ada harga yang tidak diketahui atau mata uang berbeda
+	Subtotal  *money.Money `json:"subtotal,omitempty"`
+	Warnings  []string     `json:"warnings,omitempty"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+}
+
+type CartLine struct {
+	ProductID     string      `json:"product_id"`
+	Name          string      `json:"name,omitempty"`
+	SKU           string      `json:"sku,omitempty"`
+	Quantity      int         `json:"quantity"`
+	PriceSnapshot money.Money `json:"price_snapshot"`
+	// Harga terkini dari Product Service; kosong jika produk tidak ditemukan atau layanan tidak bisa dihubungi
+	UnitPrice         *money.Money `json:"unit_price,omitempty"`
+	LineTotal         *money.Money `json:"line_total,omitempty"`
+	PriceChanged      bool         `json:"price_changed"`
+	AvailableQuantity *int         `json:"available_quantity,omitempty"`
+	Availability      string       `json:"availability"`
+}
+
+type AddItemRequest struct {
+	ProductID string `json:"product_id" binding:"required,uuid"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
+
+// ItemURI adalah parameter path /cart/items/:product_id
+type ItemURI struct {
+	ProductID string `uri:"product_id" binding:"required,uuid"`
+}
+
+type UpdateItemRequest struct {
+	Quantity *int `json:"quantity" binding:"required,gte=0"` // 0 menghapus item
+}
+
+type MergeCartRequest struct {
+	GuestToken string `json:"guest_token" binding:"required"`
+}
+
+// CheckoutOptions diteruskan apa adanya ke Order Service agar order dibuat atas nama pemanggil.
+type CheckoutOptions struct {
+	Authorization  string // Header Authorization dari client; jika kosong, identitas dari context dikirim lewat header X-User-*
+	IdempotencyKey string
+}
+
+// CheckoutResponse adalah order yang dibuat dari keranjang beserta payment intent-nya (jika berhasil dibuat).
+type CheckoutResponse struct {
+	Order   orderDomain.Order    `json:"order"`
+	Payment *orderDomain.Payment `json:"payment,omitempty"`
+}
@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCartRepository struct {
+	mock.Mock
+}
+
+func (m *MockCartRepository) GetCartByUserID(ctx context.Context, userID string) (*domain.Cart, error) {
+	args := m.Called(ctx, userID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) GetCartByGuestTokenHash(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	args := m.Called(ctx, tokenHash)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) GetOrCreateUserCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	args := m.Called(ctx, userID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) CreateGuestCart(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	args := m.Called(ctx, tokenHash)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.Cart), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCartRepository) AddItem(ctx context.Context, cartID string, item domain.CartItem) error {
+	args := m.Called(ctx, cartID, item)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error {
+	args := m.Called(ctx, cartID, productID, quantity)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	args := m.Called(ctx, cartID, productID)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) UpdatePriceSnapshots(ctx context.Context, cartID string, prices map[string]money.Money) error {
+	args := m.Called(ctx, cartID, prices)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) RemoveCheckedOutItems(ctx context.Context, cartID string, items []domain.CartItem) error {
+	args := m.Called(ctx, cartID, items)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) MergeCarts(ctx context.Context, fromCartID, intoCartID string, maxQuantity int) error {
+	args := m.Called(ctx, fromCartID, intoCartID, maxQuantity)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) DeleteStaleGuestCarts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	args := m.Called(ctx, olderThan)
+	return args.Get(0).(int64), args.Error(1)
+}
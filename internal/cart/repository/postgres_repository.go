@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("product is not in the cart")
+)
+
+const cartColumns = `id, user_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCart(row rowScanner, c *domain.Cart) error {
+	var userID sql.NullString
+	if err := row.Scan(&c.ID, &userID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return err
+	}
+	if userID.Valid {
+		c.UserID = &userID.String
+	}
+	return nil
+}
+
+func scanCartItem(row rowScanner, i *domain.CartItem) error {
+	var price money.Decimal
+	var currency string
+	if err := row.Scan(&i.ProductID, &i.Quantity, &price, &currency, &i.AddedAt, &i.UpdatedAt); err != nil {
+		return err
+	}
+	var err error
+	i.PriceSnapshot, err = price.Money(money.Currency(currency))
+	return err
+}
+
+type CartRepository interface {
+	// GetCartByUserID dan GetCartByGuestTokenHash mengembalikan keranjang beserta item-itemnya
+	GetCartByUserID(ctx context.Context, userID string) (*domain.Cart, error)
+	GetCartByGuestTokenHash(ctx context.Context, tokenHash string) (*domain.Cart, error)
+	// GetOrCreateUserCart membuat keranjang user jika belum ada (aman dipanggil bersamaan)
+	GetOrCreateUserCart(ctx context.Context, userID string) (*domain.Cart, error)
+	CreateGuestCart(ctx context.Context, tokenHash string) (*domain.Cart, error)
+	// AddItem menambah kuantitas jika produk sudah ada dan memperbarui snapshot harganya
+	AddItem(ctx context.Context, cartID string, item domain.CartItem) error
+	SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error
+	RemoveItem(ctx context.Context, cartID, productID string) error
+	UpdatePriceSnapshots(ctx context.Context, cartID string, prices map[string]money.Money) error
+	// RemoveCheckedOutItems mengurangi kuantitas sebanyak yang sudah di-checkout. Item yang ditambahkan
+	// selama checkout berjalan tetap tersisa di keranjang.
+	RemoveCheckedOutItems(ctx context.Context, cartID string, items []domain.CartItem) error
+	// MergeCarts memindahkan item keranjang from ke keranjang into lalu menghapus keranjang from.
+	// Kuantitas produk yang sama dijumlahkan dengan batas maxQuantity.
+	MergeCarts(ctx context.Context, fromCartID, intoCartID string, maxQuantity int) error
+	DeleteStaleGuestCarts(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type postgresCartRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCartRepository(db *sql.DB) CartRepository {
+	return &postgresCartRepository{db: db}
+}
+
+func (r *postgresCartRepository) getCart(ctx context.Context, where string, arg interface{}) (*domain.Cart, error) {
+	var cart domain.Cart
+	err := scanCart(r.db.QueryRowContext(ctx, `SELECT `+cartColumns+` FROM carts WHERE `+where, arg), &cart)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCartNotFound
+		}
+		logger.Error("CartRepo.getCart: query failed", err, nil)
+		return nil, err
+	}
+	if cart.Items, err = r.getItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *postgresCartRepository) getItems(ctx context.Context, cartID string) ([]domain.CartItem, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT product_id, quantity, price_snapshot, currency, added_at, updated_at
+                                         FROM cart_items WHERE cart_id = $1 ORDER BY added_at, product_id`, cartID)
+	if err != nil {
+		logger.Error("CartRepo.getItems: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.CartItem{}
+	for rows.Next() {
+		var i domain.CartItem
+		if err := scanCartItem(rows, &i); err != nil {
+			logger.Error("CartRepo.getItems: scan failed", err, nil)
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func (r *postgresCartRepository) GetCartByUserID(ctx context.Context, userID string) (*domain.Cart, error) {
+	return r.getCart(ctx, `user_id = $1`, userID)
+}
+
+func (r *postgresCartRepository) GetCartByGuestTokenHash(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	return r.getCart(ctx, `guest_token_hash = $1`, tokenHash)
+}
+
+func (r *postgresCartRepository) GetOrCreateUserCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	// ON CONFLICT DO UPDATE (tanpa perubahan berarti) agar RETURNING tetap mengembalikan baris yang sudah ada
+	query := `INSERT INTO carts (user_id) VALUES ($1)
+              ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+              RETURNING ` + cartColumns
+	var cart domain.Cart
+	if err := scanCart(r.db.QueryRowContext(ctx, query, userID), &cart); err != nil {
+		logger.Error("CartRepo.GetOrCreateUserCart: upsert failed", err, nil)
+		return nil, err
+	}
+	var err error
+	if cart.Items, err = r.getItems(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *postgresCartRepository) CreateGuestCart(ctx context.Context, tokenHash string) (*domain.Cart, error) {
+	query := `INSERT INTO carts (guest_token_hash) VALUES ($1) RETURNING ` + cartColumns
+	cart := domain.Cart{Items: []domain.CartItem{}}
+	if err := scanCart(r.db.QueryRowContext(ctx, query, tokenHash), &cart); err != nil {
+		logger.Error("CartRepo.CreateGuestCart: insert failed", err, nil)
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// touchCart memperbarui updated_at keranjang dalam statement yang sama dengan perubahan item (lewat CTE "changed").
+const touchCart = ` UPDATE carts SET updated_at = NOW() WHERE id IN (SELECT cart_id FROM changed)`
+
+func (r *postgresCartRepository) AddItem(ctx context.Context, cartID string, item domain.CartItem) error {
+	query := `WITH changed AS (
+                  INSERT INTO cart_items (cart_id, product_id, quantity, price_snapshot, currency)
+                  VALUES ($1, $2, $3, $4, $5)
+                  ON CONFLICT (cart_id, product_id) DO UPDATE
+                  SET quantity = cart_items.quantity + EXCLUDED.quantity,
+                      price_snapshot = EXCLUDED.price_snapshot,
+                      currency = EXCLUDED.currency,
+                      updated_at = NOW()
+                  RETURNING cart_id
+              )` + touchCart
+	_, err := r.db.ExecContext(ctx, query, cartID, item.ProductID, item.Quantity, item.PriceSnapshot, item.PriceSnapshot.Currency())
+	if err != nil {
+		logger.Error("CartRepo.AddItem: upsert failed", err, map[string]interface{}{"cart_id": cartID, "product_id": item.ProductID})
+	}
+	return err
+}
+
+func (r *postgresCartRepository) SetItemQuantity(ctx context.Context, cartID, productID string, quantity int) error {
+	query := `WITH changed AS (
+                  UPDATE cart_items SET quantity = $3, updated_at = NOW()
+                  WHERE cart_id = $1 AND product_id = $2
+                  RETURNING cart_id
+              )` + touchCart
+	return r.execItemChange(ctx, "SetItemQuantity", query, cartID, productID, quantity)
+}
+
+func (r *postgresCartRepository) RemoveItem(ctx context.Context, cartID, productID string) error {
+	query := `WITH changed AS (
+                  DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2
+                  RETURNING cart_id
+              )` + touchCart
+	return r.execItemChange(ctx, "RemoveItem", query, cartID, productID)
+}
+
+func (r *postgresCartRepository) execItemChange(ctx context.Context, op, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("CartRepo."+op+": query failed", err, nil)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+func (r *postgresCartRepository) UpdatePriceSnapshots(ctx context.Context, cartID string, prices map[string]money.Money) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CartRepo.UpdatePriceSnapshots: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	for productID, price := range prices {
+		_, err := tx.ExecContext(ctx, `UPDATE cart_items SET price_snapshot = $3, currency = $4, updated_at = NOW()
+                                       WHERE cart_id = $1 AND product_id = $2`, cartID, productID, price, price.Currency())
+		if err != nil {
+			logger.Error("CartRepo.UpdatePriceSnapshots: update failed", err, map[string]interface{}{"product_id": productID})
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresCartRepository) RemoveCheckedOutItems(ctx context.Context, cartID string, items []domain.CartItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CartRepo.RemoveCheckedOutItems: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND quantity <= $3`,
+			cartID, item.ProductID, item.Quantity); err != nil {
+			logger.Error("CartRepo.RemoveCheckedOutItems: delete failed", err, nil)
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE cart_items SET quantity = quantity - $3, updated_at = NOW()
+                                          WHERE cart_id = $1 AND product_id = $2 AND quantity > $3`,
+			cartID, item.ProductID, item.Quantity); err != nil {
+			logger.Error("CartRepo.RemoveCheckedOutItems: update failed", err, nil)
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID); err != nil {
+		logger.Error("CartRepo.RemoveCheckedOutItems: touch cart failed", err, nil)
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresCartRepository) MergeCarts(ctx context.Context, fromCartID, intoCartID string, maxQuantity int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CartRepo.MergeCarts: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Snapshot harga diambil dari item yang paling baru diubah
+	mergeQuery := `INSERT INTO cart_items (cart_id, product_id, quantity, price_snapshot, currency, added_at, updated_at)
+                   SELECT $2, product_id, LEAST(quantity, $3), price_snapshot, currency, added_at, updated_at
+                   FROM cart_items WHERE cart_id = $1
+                   ON CONFLICT (cart_id, product_id) DO UPDATE
+                   SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $3),
+                       price_snapshot = CASE WHEN EXCLUDED.updated_at > cart_items.updated_at THEN EXCLUDED.price_snapshot ELSE cart_items.price_snapshot END,
+                       currency = CASE WHEN EXCLUDED.updated_at > cart_items.updated_at THEN EXCLUDED.currency ELSE cart_items.currency END,
+                       updated_at = NOW()`
+	if _, err := tx.ExecContext(ctx, mergeQuery, fromCartID, intoCartID, maxQuantity); err != nil {
+		logger.Error("CartRepo.MergeCarts: merge items failed", err, nil)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM carts WHERE id = $1`, fromCartID); err != nil {
+		logger.Error("CartRepo.MergeCarts: delete source cart failed", err, nil)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, intoCartID); err != nil {
+		logger.Error("CartRepo.MergeCarts: touch cart failed", err, nil)
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresCartRepository) DeleteStaleGuestCarts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM carts WHERE user_id IS NULL AND updated_at < $1`, time.Now().Add(-olderThan))
+	if err != nil {
+		logger.Error("CartRepo.DeleteStaleGuestCarts: delete failed", err, nil)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/repository"
+	orderDomain "github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
+)
+
+var (
+	ErrInvalidQuantity   = fmt.Errorf("quantity must be between 1 and %d", domain.MaxItemQuantity)
+	ErrCartFull          = fmt.Errorf("cart cannot contain more than %d different products", domain.MaxCartLines)
+	ErrUnknownProduct    = errors.New("product not found")
+	ErrPriceLookupFailed = errors.New("failed to look up product prices")
+	ErrCartEmpty         = errors.New("cart is empty")
+	ErrPriceChanged      = errors.New("prices of some items in the cart have changed")
+)
+
+// PriceChangedError dikembalikan saat checkout jika Order Service menolak harga di keranjang.
+// Snapshot harga di keranjang sudah diperbarui, jadi checkout ulang akan memakai harga terbaru.
+type PriceChangedError struct {
+	Quote []orderDomain.PriceQuote
+}
+
+func (e *PriceChangedError) Error() string { return ErrPriceChanged.Error() }
+func (e *PriceChangedError) Unwrap() error { return ErrPriceChanged }
+
+// CartOwner menentukan keranjang yang dipakai: keranjang user jika UserID terisi, selain itu keranjang guest dari token.
+type CartOwner struct {
+	UserID     string
+	GuestToken string
+}
+
+type CartService interface {
+	GetCart(ctx context.Context, owner CartOwner) (*domain.CartView, error)
+	AddItem(ctx context.Context, owner CartOwner, req domain.AddItemRequest) (*domain.CartView, error)
+	// UpdateItem mengganti kuantitas item; kuantitas 0 menghapus item
+	UpdateItem(ctx context.Context, owner CartOwner, productID string, quantity int) (*domain.CartView, error)
+	RemoveItem(ctx context.Context, owner CartOwner, productID string) (*domain.CartView, error)
+	// MergeGuestCart menggabungkan keranjang guest ke keranjang user setelah login, lalu menghapus keranjang guest
+	MergeGuestCart(ctx context.Context, userID, guestToken string) (*domain.CartView, error)
+	// Checkout membuat order dari keranjang user lewat Order Service dan mengosongkan item yang sudah dipesan
+	Checkout(ctx context.Context, userID string, opts domain.CheckoutOptions) (*orderDomain.CreateOrderResponse, error)
+	CleanupGuestCarts(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type cartService struct {
+	cartRepo      repository.CartRepository
+	productClient ProductClient
+	stockClient   StockClient
+	orderClient   OrderClient
+}
+
+func NewCartService(cr repository.CartRepository, pc ProductClient, sc StockClient, oc OrderClient) CartService {
+	return &cartService{
+		cartRepo:      cr,
+		productClient: pc,
+		stockClient:   sc,
+		orderClient:   oc,
+	}
+}
+
+func newGuestToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashGuestToken: hanya hash token yang disimpan, sehingga isi database tidak bisa dipakai untuk membuka keranjang guest.
+func hashGuestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// findCart mengembalikan repository.ErrCartNotFound jika pemilik belum punya keranjang.
+func (s *cartService) findCart(ctx context.Context, owner CartOwner) (*domain.Cart, error) {
+	if owner.UserID != "" {
+		return s.cartRepo.GetCartByUserID(ctx, owner.UserID)
+	}
+	if owner.GuestToken == "" {
+		return nil, repository.ErrCartNotFound
+	}
+	return s.cartRepo.GetCartByGuestTokenHash(ctx, hashGuestToken(owner.GuestToken))
+}
+
+func (s *cartService) GetCart(ctx context.Context, owner CartOwner) (*domain.CartView, error) {
+	cart, err := s.findCart(ctx, owner)
+	if err != nil {
+		// Keranjang kosong yang belum pernah dibuat bukan error; token guest yang tidak dikenal tetap 404
+		if errors.Is(err, repository.ErrCartNotFound) && (owner.UserID != "" || owner.GuestToken == "") {
+			return &domain.CartView{UserID: owner.UserID, Items: []domain.CartLine{}}, nil
+		}
+		return nil, err
+	}
+	return s.buildView(ctx, cart), nil
+}
+
+func (s *cartService) AddItem(ctx context.Context, owner CartOwner, req domain.AddItemRequest) (*domain.CartView, error) {
+	if req.Quantity < 1 || req.Quantity > domain.MaxItemQuantity {
+		return nil, ErrInvalidQuantity
+	}
+
+	// Harga dicek dulu agar produk yang tidak ada tidak membuat keranjang guest kosong
+	prices, err := s.productClient.GetProductPrices(ctx, []string{req.ProductID})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPriceLookupFailed, err)
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProduct, req.ProductID)
+	}
+
+	cart, newToken, err := s.getOrCreateCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok := cart.FindItem(req.ProductID); ok {
+		if existing.Quantity+req.Quantity > domain.MaxItemQuantity {
+			return nil, ErrInvalidQuantity
+		}
+	} else if len(cart.Items) >= domain.MaxCartLines {
+		return nil, ErrCartFull
+	}
+
+	item := domain.CartItem{ProductID: req.ProductID, Quantity: req.Quantity, PriceSnapshot: prices[0].Price}
+	if err := s.cartRepo.AddItem(ctx, cart.ID, item); err != nil {
+		return nil, err
+	}
+
+	if newToken != "" {
+		owner.GuestToken = newToken
+	}
+	view, err := s.GetCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	view.GuestToken = newToken
+	return view, nil
+}
+
+// getOrCreateCart membuat keranjang guest baru jika pemanggil belum punya token; token barunya dikembalikan.
+func (s *cartService) getOrCreateCart(ctx context.Context, owner CartOwner) (*domain.Cart, string, error) {
+	if owner.UserID != "" {
+		cart, err := s.cartRepo.GetOrCreateUserCart(ctx, owner.UserID)
+		return cart, "", err
+	}
+	if owner.GuestToken != "" {
+		cart, err := s.cartRepo.GetCartByGuestTokenHash(ctx, hashGuestToken(owner.GuestToken))
+		return cart, "", err
+	}
+	token, err := newGuestToken()
+	if err != nil {
+		logger.Error("CartService.getOrCreateCart: failed to generate guest token", err, nil)
+		return nil, "", err
+	}
+	cart, err := s.cartRepo.CreateGuestCart(ctx, hashGuestToken(token))
+	if err != nil {
+		return nil, "", err
+	}
+	return cart, token, nil
+}
+
+func (s *cartService) UpdateItem(ctx context.Context, owner CartOwner, productID string, quantity int) (*domain.CartView, error) {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, owner, productID)
+	}
+	if quantity < 0 || quantity > domain.MaxItemQuantity {
+		return nil, ErrInvalidQuantity
+	}
+	cart, err := s.findCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.SetItemQuantity(ctx, cart.ID, productID, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, owner)
+}
+
+func (s *cartService) RemoveItem(ctx context.Context, owner CartOwner, productID string) (*domain.CartView, error) {
+	cart, err := s.findCart(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.RemoveItem(ctx, cart.ID, productID); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, owner)
+}
+
+func (s *cartService) MergeGuestCart(ctx context.Context, userID, guestToken string) (*domain.CartView, error) {
+	owner := CartOwner{UserID: userID}
+	guestCart, err := s.cartRepo.GetCartByGuestTokenHash(ctx, hashGuestToken(guestToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrCartNotFound) {
+			// Sudah digabung sebelumnya (misal retry setelah login) atau sudah kedaluwarsa
+			return s.GetCart(ctx, owner)
+		}
+		return nil, err
+	}
+
+	userCart, err := s.cartRepo.GetOrCreateUserCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cartRepo.MergeCarts(ctx, guestCart.ID, userCart.ID, domain.MaxItemQuantity); err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("CartService: merged guest cart %s (%d items) into cart %s of user %s", guestCart.ID, len(guestCart.Items), userCart.ID, userID))
+	return s.GetCart(ctx, owner)
+}
+
+func (s *cartService) Checkout(ctx context.Context, userID string, opts domain.CheckoutOptions) (*orderDomain.CreateOrderResponse, error) {
+	cart, err := s.cartRepo.GetCartByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCartNotFound) {
+			return nil, ErrCartEmpty
+		}
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	// Harga snapshot ikut dikirim: Order Service menolaknya dengan penawaran harga baru jika sudah berubah
+	orderReq := orderDomain.CreateOrderRequest{Items: make([]orderDomain.CreateOrderItemRequest, 0, len(cart.Items))}
+	for _, item := range cart.Items {
+		price := item.PriceSnapshot
+		orderReq.Items = append(orderReq.Items, orderDomain.CreateOrderItemRequest{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Price:     &price,
+		})
+	}
+
+	order, err := s.orderClient.CreateOrder(ctx, orderReq, opts)
+	if err != nil {
+		var orderErr *OrderServiceError
+		if errors.As(err, &orderErr) && len(orderErr.Quote) > 0 {
+			prices := make(map[string]money.Money, len(orderErr.Quote))
+			for _, q := range orderErr.Quote {
+				prices[q.ProductID] = q.CurrentPrice
+			}
+			if err := s.cartRepo.UpdatePriceSnapshots(ctx, cart.ID, prices); err != nil {
+				return nil, err
+			}
+			return nil, &PriceChangedError{Quote: orderErr.Quote}
+		}
+		return nil, err
+	}
+
+	// Order sudah dibuat; kegagalan mengosongkan keranjang tidak boleh membuat checkout terlihat gagal
+	if err := s.cartRepo.RemoveCheckedOutItems(ctx, cart.ID, cart.Items); err != nil {
+		logger.Error(fmt.Sprintf("CartService.Checkout: order %s created but cart %s was not cleared", order.ID, cart.ID), err, nil)
+	}
+	return order, nil
+}
+
+func (s *cartService) CleanupGuestCarts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return s.cartRepo.DeleteStaleGuestCarts(ctx, olderThan)
+}
+
+// buildView memperkaya keranjang dengan harga dari Product Service dan stok dari Warehouse Service secara paralel.
+// Kegagalan layanan lain tidak menggagalkan request; keranjang tetap ditampilkan dengan peringatan.
+func (s *cartService) buildView(ctx context.Context, cart *domain.Cart) *domain.CartView {
+	view := &domain.CartView{ID: cart.ID, Items: make([]domain.CartLine, 0, len(cart.Items)), UpdatedAt: &cart.UpdatedAt}
+	if cart.UserID != nil {
+		view.UserID = *cart.UserID
+	}
+	if len(cart.Items) == 0 {
+		return view
+	}
+
+	productIDs := make([]string, len(cart.Items))
+	for i, item := range cart.Items {
+		productIDs[i] = item.ProductID
+	}
+
+	var wg sync.WaitGroup
+	var prices []productDomain.ProductPrice
+	var priceErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		prices, priceErr = s.productClient.GetProductPrices(ctx, productIDs)
+	}()
+	available := make([]*int, len(cart.Items)) // Setiap goroutine hanya menulis ke indeksnya sendiri
+	for i, productID := range productIDs {
+		wg.Add(1)
+		go func(i int, productID string) {
+			defer wg.Done()
+			info, err := s.stockClient.GetProductStockInfo(ctx, productID)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("CartService.buildView: stock lookup failed for product %s: %v", productID, err))
+				return
+			}
+			available[i] = &info.TotalAvailable
+		}(i, productID)
+	}
+	wg.Wait()
+
+	priceByID := make(map[string]productDomain.ProductPrice, len(prices))
+	for _, p := range prices {
+		priceByID[p.ProductID] = p
+	}
+	if priceErr != nil {
+		logger.Warn(fmt.Sprintf("CartService.buildView: price lookup failed for cart %s: %v", cart.ID, priceErr))
+		view.Warnings = append(view.Warnings, "Current prices are temporarily unavailable; showing prices from when items were added")
+	}
+
+	var subtotal *money.Money
+	subtotalKnown := priceErr == nil
+	var priceChanged, stockUnknown bool
+	for i, item := range cart.Items {
+		line := domain.CartLine{
+			ProductID:         item.ProductID,
+			Quantity:          item.Quantity,
+			PriceSnapshot:     item.PriceSnapshot,
+			AvailableQuantity: available[i],
+		}
+		view.ItemCount += item.Quantity
+
+		if p, ok := priceByID[item.ProductID]; ok {
+			line.Name = p.Name
+			line.SKU = p.SKU
+			unitPrice := p.Price
+			line.UnitPrice = &unitPrice
+			line.PriceChanged = !p.Price.Equal(item.PriceSnapshot)
+			priceChanged = priceChanged || line.PriceChanged
+			if total, err := p.Price.Mul(int64(item.Quantity)); err == nil {
+				line.LineTotal = &total
+			}
+		} else if priceErr == nil {
+			line.Availability = domain.AvailabilityDiscontinued
+			view.Warnings = append(view.Warnings, fmt.Sprintf("Product %s is no longer available", item.ProductID))
+		}
+
+		if line.Availability == "" {
+			switch {
+			case available[i] == nil:
+				line.Availability = domain.AvailabilityUnknown
+				stockUnknown = true
+			case *available[i] >= item.Quantity:
+				line.Availability = domain.AvailabilityInStock
+			case *available[i] > 0:
+				line.Availability = domain.AvailabilityInsufficientStock
+			default:
+				line.Availability = domain.AvailabilityOutOfStock
+			}
+		}
+
+		// Subtotal hanya ditampilkan jika semua harga terkini diketahui dan bermata uang sama
+		switch {
+		case line.LineTotal == nil:
+			subtotalKnown = false
+		case subtotal == nil:
+			first := *line.LineTotal
+			subtotal = &first
+		default:
+			sum, err := subtotal.Add(*line.LineTotal)
+			if err != nil {
+				subtotalKnown = false
+			} else {
+				subtotal = &sum
+			}
+		}
+		view.Items = append(view.Items, line)
+	}
+	if subtotalKnown {
+		view.Subtotal = subtotal
+	}
+	if priceChanged {
+		view.Warnings = append(view.Warnings, "Prices of some items have changed since they were added to the cart")
+	}
+	if stockUnknown {
+		view.Warnings = append(view.Warnings, "Stock availability could not be checked for some items")
+	}
+	return view
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/repository"
+	repoMocks "github.com/ridloal/e-commerce-go-microservices/internal/cart/repository/mocks"
+	clientMocks "github.com/ridloal/e-commerce-go-microservices/internal/cart/service/mocks"
+	orderDomain "github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
+	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	laptopID = "a1b2c3d4-e5f6-7890-1234-567890abcdef"
+	mouseID  = "b2c3d4e5-f6a7-8901-2345-67890abcdef0"
+	userID   = "c3d4e5f6-a7b8-9012-3456-7890abcdef01"
+)
+
+func idr(amount int64) money.Money {
+	return money.New(amount, money.IDR)
+}
+
+type cartMocks struct {
+	repo    *repoMocks.MockCartRepository
+	product *clientMocks.MockProductClientForCart
+	stock   *clientMocks.MockStockClientForCart
+	order   *clientMocks.MockOrderClientForCart
+}
+
+func newTestCartService() (CartService, cartMocks) {
+	m := cartMocks{
+		repo:    new(repoMocks.MockCartRepository),
+		product: new(clientMocks.MockProductClientForCart),
+		stock:   new(clientMocks.MockStockClientForCart),
+		order:   new(clientMocks.MockOrderClientForCart),
+	}
+	return NewCartService(m.repo, m.product, m.stock, m.order), m
+}
+
+func stockInfo(productID string, available int) *warehouseDomain.ProductStockInfo {
+	return &warehouseDomain.ProductStockInfo{ProductID: productID, TotalAvailable: available}
+}
+
+func userCart(items ...domain.CartItem) *domain.Cart {
+	id := userID
+	return &domain.Cart{ID: "cart-1", UserID: &id, Items: items}
+}
+
+func TestCartService_GetCart(t *testing.T) {
+	ctx := context.TODO()
+	owner := CartOwner{UserID: userID}
+
+	t.Run("User without a cart gets an empty cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(nil, repository.ErrCartNotFound).Once()
+
+		view, err := svc.GetCart(ctx, owner)
+
+		assert.NoError(t, err)
+		assert.Empty(t, view.ID)
+		assert.Empty(t, view.Items)
+		m.product.AssertNotCalled(t, "GetProductPrices", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Enriches items with live prices and availability", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(
+			domain.CartItem{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)},
+			domain.CartItem{ProductID: mouseID, Quantity: 2, PriceSnapshot: idr(350000)},
+		), nil).Once()
+		m.product.On("GetProductPrices", ctx, []string{laptopID, mouseID}).Return([]productDomain.ProductPrice{
+			{ProductID: laptopID, SKU: "LAP-16GB-001", Name: "Laptop", Price: idr(14000000)},
+			{ProductID: mouseID, SKU: "MOU-ERGO-001", Name: "Mouse", Price: idr(400000)},
+		}, nil).Once()
+		m.stock.On("GetProductStockInfo", ctx, laptopID).Return(stockInfo(laptopID, 10), nil).Once()
+		m.stock.On("GetProductStockInfo", ctx, mouseID).Return(stockInfo(mouseID, 1), nil).Once()
+
+		view, err := svc.GetCart(ctx, owner)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "cart-1", view.ID)
+		assert.Equal(t, userID, view.UserID)
+		assert.Equal(t, 3, view.ItemCount)
+		assert.Len(t, view.Items, 2)
+
+		laptop, mouse := view.Items[0], view.Items[1]
+		assert.Equal(t, "LAP-16GB-001", laptop.SKU)
+		assert.False(t, laptop.PriceChanged)
+		assert.Equal(t, domain.AvailabilityInStock, laptop.Availability)
+		assert.True(t, mouse.PriceChanged)
+		assert.True(t, mouse.LineTotal.Equal(idr(800000)))
+		assert.Equal(t, 1, *mouse.AvailableQuantity)
+		assert.Equal(t, domain.AvailabilityInsufficientStock, mouse.Availability)
+		assert.True(t, view.Subtotal.Equal(idr(14800000)))
+		assert.Contains(t, view.Warnings, "Prices of some items have changed since they were added to the cart")
+	})
+
+	t.Run("Cart is still shown when product and warehouse services are down", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(
+			domain.CartItem{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)},
+		), nil).Once()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return(nil, errors.New("connection refused")).Once()
+		m.stock.On("GetProductStockInfo", ctx, laptopID).Return(nil, errors.New("connection refused")).Once()
+
+		view, err := svc.GetCart(ctx, owner)
+
+		assert.NoError(t, err)
+		assert.Nil(t, view.Items[0].UnitPrice)
+		assert.True(t, view.Items[0].PriceSnapshot.Equal(idr(14000000)))
+		assert.Equal(t, domain.AvailabilityUnknown, view.Items[0].Availability)
+		assert.Nil(t, view.Subtotal)
+		assert.Len(t, view.Warnings, 2)
+	})
+
+	t.Run("Product that no longer exists is flagged as discontinued", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(
+			domain.CartItem{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)},
+		), nil).Once()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return([]productDomain.ProductPrice{}, nil).Once()
+		m.stock.On("GetProductStockInfo", ctx, laptopID).Return(stockInfo(laptopID, 0), nil).Once()
+
+		view, err := svc.GetCart(ctx, owner)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AvailabilityDiscontinued, view.Items[0].Availability)
+		assert.Nil(t, view.Subtotal)
+	})
+
+	t.Run("Unknown guest token is reported as not found", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByGuestTokenHash", ctx, hashGuestToken("expired")).Return(nil, repository.ErrCartNotFound).Once()
+
+		view, err := svc.GetCart(ctx, CartOwner{GuestToken: "expired"})
+
+		assert.ErrorIs(t, err, repository.ErrCartNotFound)
+		assert.Nil(t, view)
+	})
+}
+
+func TestCartService_AddItem(t *testing.T) {
+	ctx := context.TODO()
+	laptopPrice := []productDomain.ProductPrice{{ProductID: laptopID, SKU: "LAP-16GB-001", Name: "Laptop", Price: idr(14000000)}}
+
+	t.Run("First item of a guest creates a guest cart and returns its token", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return(laptopPrice, nil)
+		m.stock.On("GetProductStockInfo", ctx, laptopID).Return(stockInfo(laptopID, 5), nil).Once()
+		guestCart := &domain.Cart{ID: "guest-cart", Items: []domain.CartItem{}}
+		m.repo.On("CreateGuestCart", ctx, mock.AnythingOfType("string")).Return(guestCart, nil).Once()
+		item := domain.CartItem{ProductID: laptopID, Quantity: 2, PriceSnapshot: idr(14000000)}
+		m.repo.On("AddItem", ctx, "guest-cart", item).Return(nil).Once()
+		m.repo.On("GetCartByGuestTokenHash", ctx, mock.AnythingOfType("string")).
+			Return(&domain.Cart{ID: "guest-cart", Items: []domain.CartItem{item}}, nil).Once()
+
+		view, err := svc.AddItem(ctx, CartOwner{}, domain.AddItemRequest{ProductID: laptopID, Quantity: 2})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, view.GuestToken)
+		assert.Len(t, view.Items, 1)
+		// Yang disimpan hanya hash token
+		storedHash := m.repo.Calls[0].Arguments.Get(1).(string)
+		assert.Equal(t, hashGuestToken(view.GuestToken), storedHash)
+		assert.NotEqual(t, view.GuestToken, storedHash)
+	})
+
+	t.Run("Unknown product does not create a cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return([]productDomain.ProductPrice{}, nil).Once()
+
+		view, err := svc.AddItem(ctx, CartOwner{}, domain.AddItemRequest{ProductID: laptopID, Quantity: 1})
+
+		assert.ErrorIs(t, err, ErrUnknownProduct)
+		assert.Nil(t, view)
+		m.repo.AssertNotCalled(t, "CreateGuestCart", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Product Service unavailable", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return(nil, errors.New("timeout")).Once()
+
+		_, err := svc.AddItem(ctx, CartOwner{UserID: userID}, domain.AddItemRequest{ProductID: laptopID, Quantity: 1})
+		assert.ErrorIs(t, err, ErrPriceLookupFailed)
+	})
+
+	t.Run("Total quantity per product is limited", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return(laptopPrice, nil).Once()
+		m.repo.On("GetOrCreateUserCart", ctx, userID).Return(userCart(
+			domain.CartItem{ProductID: laptopID, Quantity: domain.MaxItemQuantity - 1, PriceSnapshot: idr(14000000)},
+		), nil).Once()
+
+		_, err := svc.AddItem(ctx, CartOwner{UserID: userID}, domain.AddItemRequest{ProductID: laptopID, Quantity: 2})
+
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+		m.repo.AssertNotCalled(t, "AddItem", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Number of different products is limited", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return(laptopPrice, nil).Once()
+		full := userCart()
+		for i := 0; i < domain.MaxCartLines; i++ {
+			full.Items = append(full.Items, domain.CartItem{ProductID: string(rune('A' + i)), Quantity: 1, PriceSnapshot: idr(1000)})
+		}
+		m.repo.On("GetOrCreateUserCart", ctx, userID).Return(full, nil).Once()
+
+		_, err := svc.AddItem(ctx, CartOwner{UserID: userID}, domain.AddItemRequest{ProductID: laptopID, Quantity: 1})
+		assert.ErrorIs(t, err, ErrCartFull)
+	})
+}
+
+func TestCartService_UpdateItem(t *testing.T) {
+	ctx := context.TODO()
+	owner := CartOwner{UserID: userID}
+
+	t.Run("Quantity zero removes the item", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(
+			domain.CartItem{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)},
+		), nil).Once()
+		m.repo.On("RemoveItem", ctx, "cart-1", laptopID).Return(nil).Once()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(), nil).Once()
+
+		view, err := svc.UpdateItem(ctx, owner, laptopID, 0)
+
+		assert.NoError(t, err)
+		assert.Empty(t, view.Items)
+		m.repo.AssertExpectations(t)
+	})
+
+	t.Run("Product not in the cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(), nil).Once()
+		m.repo.On("SetItemQuantity", ctx, "cart-1", mouseID, 3).Return(repository.ErrCartItemNotFound).Once()
+
+		_, err := svc.UpdateItem(ctx, owner, mouseID, 3)
+		assert.ErrorIs(t, err, repository.ErrCartItemNotFound)
+	})
+
+	t.Run("Quantity above the limit is rejected", func(t *testing.T) {
+		svc, _ := newTestCartService()
+
+		_, err := svc.UpdateItem(ctx, owner, laptopID, domain.MaxItemQuantity+1)
+		assert.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+}
+
+func TestCartService_MergeGuestCart(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Guest cart is merged into the user cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		guestCart := &domain.Cart{ID: "guest-cart", Items: []domain.CartItem{{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)}}}
+		m.repo.On("GetCartByGuestTokenHash", ctx, hashGuestToken("guest-token")).Return(guestCart, nil).Once()
+		m.repo.On("GetOrCreateUserCart", ctx, userID).Return(userCart(), nil).Once()
+		m.repo.On("MergeCarts", ctx, "guest-cart", "cart-1", domain.MaxItemQuantity).Return(nil).Once()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(guestCart.Items...), nil).Once()
+		m.product.On("GetProductPrices", ctx, []string{laptopID}).Return([]productDomain.ProductPrice{{ProductID: laptopID, Price: idr(14000000)}}, nil).Once()
+		m.stock.On("GetProductStockInfo", ctx, laptopID).Return(stockInfo(laptopID, 5), nil).Once()
+
+		view, err := svc.MergeGuestCart(ctx, userID, "guest-token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "cart-1", view.ID)
+		assert.Len(t, view.Items, 1)
+		m.repo.AssertExpectations(t)
+	})
+
+	t.Run("Already merged guest cart is a no-op", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByGuestTokenHash", ctx, hashGuestToken("guest-token")).Return(nil, repository.ErrCartNotFound).Once()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(), nil).Once()
+
+		view, err := svc.MergeGuestCart(ctx, userID, "guest-token")
+
+		assert.NoError(t, err)
+		assert.Empty(t, view.Items)
+		m.repo.AssertNotCalled(t, "MergeCarts", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCartService_Checkout(t *testing.T) {
+	ctx := context.TODO()
+	opts := domain.CheckoutOptions{Authorization: "Bearer token", IdempotencyKey: "checkout-1"}
+	items := []domain.CartItem{
+		{ProductID: laptopID, Quantity: 1, PriceSnapshot: idr(14000000)},
+		{ProductID: mouseID, Quantity: 2, PriceSnapshot: idr(350000)},
+	}
+	laptopPrice, mousePrice := idr(14000000), idr(350000)
+	expectedOrderReq := orderDomain.CreateOrderRequest{Items: []orderDomain.CreateOrderItemRequest{
+		{ProductID: laptopID, Quantity: 1, Price: &laptopPrice},
+		{ProductID: mouseID, Quantity: 2, Price: &mousePrice},
+	}}
+
+	t.Run("Creates an order with snapshot prices and clears the cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(items...), nil).Once()
+		created := &orderDomain.CreateOrderResponse{
+			Order:   orderDomain.Order{ID: "order-1", UserID: userID, TotalAmount: idr(14700000), Status: orderDomain.StatusPendingPayment},
+			Payment: &orderDomain.Payment{ID: "pay-1", OrderID: "order-1", Status: orderDomain.PaymentStatusPending},
+		}
+		m.order.On("CreateOrder", ctx, expectedOrderReq, opts).Return(created, nil).Once()
+		m.repo.On("RemoveCheckedOutItems", ctx, "cart-1", items).Return(nil).Once()
+
+		order, err := svc.Checkout(ctx, userID, opts)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "order-1", order.ID)
+		assert.Equal(t, "pay-1", order.Payment.ID)
+		m.repo.AssertExpectations(t)
+		m.order.AssertExpectations(t)
+	})
+
+	t.Run("Changed prices refresh the cart snapshots", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(items...), nil).Once()
+		quote := []orderDomain.PriceQuote{
+			{ProductID: laptopID, Quantity: 1, RequestedPrice: &laptopPrice, CurrentPrice: idr(14000000)},
+			{ProductID: mouseID, Quantity: 2, RequestedPrice: &mousePrice, CurrentPrice: idr(400000)},
+		}
+		m.order.On("CreateOrder", ctx, expectedOrderReq, opts).
+			Return(nil, &OrderServiceError{StatusCode: 409, Message: "prices changed", Quote: quote}).Once()
+		m.repo.On("UpdatePriceSnapshots", ctx, "cart-1", map[string]money.Money{laptopID: idr(14000000), mouseID: idr(400000)}).Return(nil).Once()
+
+		order, err := svc.Checkout(ctx, userID, opts)
+
+		assert.Nil(t, order)
+		var priceErr *PriceChangedError
+		assert.ErrorAs(t, err, &priceErr)
+		assert.Equal(t, quote, priceErr.Quote)
+		m.repo.AssertNotCalled(t, "RemoveCheckedOutItems", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Other order service errors are returned as is", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(userCart(items...), nil).Once()
+		stockErr := &OrderServiceError{StatusCode: 409, Message: "failed to reserve stock"}
+		m.order.On("CreateOrder", ctx, expectedOrderReq, opts).Return(nil, stockErr).Once()
+
+		_, err := svc.Checkout(ctx, userID, opts)
+
+		assert.Equal(t, stockErr, err)
+		m.repo.AssertNotCalled(t, "UpdatePriceSnapshots", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Empty cart", func(t *testing.T) {
+		svc, m := newTestCartService()
+		m.repo.On("GetCartByUserID", ctx, userID).Return(nil, repository.ErrCartNotFound).Once()
+
+		_, err := svc.Checkout(ctx, userID, opts)
+
+		assert.ErrorIs(t, err, ErrCartEmpty)
+		m.order.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
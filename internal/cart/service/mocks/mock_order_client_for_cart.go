@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	orderDomain "github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOrderClientForCart struct {
+	mock.Mock
+}
+
+func (m *MockOrderClientForCart) CreateOrder(ctx context.Context, req orderDomain.CreateOrderRequest, opts domain.CheckoutOptions) (*orderDomain.CreateOrderResponse, error) {
+	args := m.Called(ctx, req, opts)
+	if res := args.Get(0); res != nil {
+		return res.(*orderDomain.CreateOrderResponse), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+
+	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStockClientForCart struct {
+	mock.Mock
+}
+
+func (m *MockStockClientForCart) GetProductStockInfo(ctx context.Context, productID string) (*warehouseDomain.ProductStockInfo, error) {
+	args := m.Called(ctx, productID)
+	if res := args.Get(0); res != nil {
+		return res.(*warehouseDomain.ProductStockInfo), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/cart/domain"
+	orderDomain "github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+var ErrOrderServiceUnavailable = errors.New("order service is unavailable")
+
+// OrderServiceError adalah response non-2xx dari Order Service.
+type OrderServiceError struct {
+	StatusCode int
+	Message    string
+	Quote      []orderDomain.PriceQuote // Diisi jika order ditolak karena harga berubah (409)
+}
+
+func (e *OrderServiceError) Error() string {
+	return fmt.Sprintf("order service returned status %d: %s", e.StatusCode, e.Message)
+}
+
+type OrderClient interface {
+	CreateOrder(ctx context.Context, req orderDomain.CreateOrderRequest, opts domain.CheckoutOptions) (*orderDomain.CreateOrderResponse, error)
+}
+
+type httpOrderClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewHTTPOrderClient(baseURL string) OrderClient {
+	return &httpOrderClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 15 * time.Second, // Pembuatan order menunggu reservasi stok di Warehouse Service
+		},
+	}
+}
+
+func (c *httpOrderClient) CreateOrder(ctx context.Context, orderReq orderDomain.CreateOrderRequest, opts domain.CheckoutOptions) (*orderDomain.CreateOrderResponse, error) {
+	jsonPayload, err := json.Marshal(orderReq)
+	if err != nil {
+		logger.Error("OrderClient.CreateOrder: Marshal failed", err, nil)
+		return nil, fmt.Errorf("failed to marshal create order request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/v1/orders", bytes.NewReader(jsonPayload))
+	if err != nil {
+		logger.Error("OrderClient.CreateOrder: NewRequest failed", err, nil)
+		return nil, fmt.Errorf("failed to create request to order service: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.Authorization != "" {
+		req.Header.Set("Authorization", opts.Authorization)
+	} else if identity, ok := auth.IdentityFromContext(ctx); ok {
+		// Request dari gateway tanpa token (TRUST_IDENTITY_HEADERS); Order Service harus dikonfigurasi sama
+		auth.SetIdentityHeaders(req.Header, &auth.Claims{UserID: identity.UserID, Email: identity.Email, Role: identity.Role})
+	}
+	if opts.IdempotencyKey != "" {
+		req.Header.Set(idempotency.HeaderKey, opts.IdempotencyKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		logger.Error("OrderClient.CreateOrder: HTTPClient.Do failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrOrderServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string                   `json:"error"`
+			Quote []orderDomain.PriceQuote `json:"quote"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		logger.Warn(fmt.Sprintf("OrderClient.CreateOrder: order service returned status %d: %s", resp.StatusCode, errResp.Error))
+		return nil, &OrderServiceError{StatusCode: resp.StatusCode, Message: errResp.Error, Quote: errResp.Quote}
+	}
+
+	var created orderDomain.CreateOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		logger.Error("OrderClient.CreateOrder: JSON decode failed", err, nil)
+		return nil, fmt.Errorf("failed to decode response from order service: %w", err)
+	}
+	return &created, nil
+}
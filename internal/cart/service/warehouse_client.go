@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+)
+
+type StockClient interface {
+	// GetProductStockInfo mengembalikan total stok tersedia sebuah produk di semua warehouse
+	GetProductStockInfo(ctx context.Context, productID string) (*warehouseDomain.ProductStockInfo, error)
+}
+
+type httpStockClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewHTTPStockClient(baseURL string) StockClient {
+	return &httpStockClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 3 * time.Second, // Lebih pendek: stok hanya informasi tambahan saat menampilkan keranjang
+		},
+	}
+}
+
+func (c *httpStockClient) GetProductStockInfo(ctx context.Context, productID string) (*warehouseDomain.ProductStockInfo, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/stock-info/products/%s", c.BaseURL, url.PathEscape(productID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		logger.Error("StockClient.GetProductStockInfo: NewRequest failed", err, nil)
+		return nil, fmt.Errorf("failed to create request to warehouse service: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		logger.Error("StockClient.GetProductStockInfo: HTTPClient.Do failed", err, nil)
+		return nil, fmt.Errorf("failed to call warehouse service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error(fmt.Sprintf("StockClient.GetProductStockInfo: warehouse service returned status %d for product %s", resp.StatusCode, productID), nil, nil)
+		return nil, fmt.Errorf("warehouse service returned status: %d", resp.StatusCode)
+	}
+
+	var stockInfo warehouseDomain.ProductStockInfo
+	if err := json.NewDecoder(resp.Body).Decode(&stockInfo); err != nil {
+		logger.Error("StockClient.GetProductStockInfo: JSON decode failed", err, nil)
+		return nil, fmt.Errorf("failed to decode response from warehouse service: %w", err)
+	}
+	return &stockInfo, nil
+}
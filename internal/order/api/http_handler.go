@@ -3,12 +3,18 @@ package api
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	// Ganti dengan path yang benar
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/service"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 )
 
@@ -20,36 +26,130 @@ func NewOrderHandler(os service.OrderService) *OrderHandler {
 	return &OrderHandler{orderService: os}
 }
 
-func (h *OrderHandler) RegisterRoutes(router *gin.RouterGroup) {
-	orderRoutes := router.Group("/orders")
+// RegisterRoutes memasang rute order. authMiddleware wajib menyediakan identitas pemanggil (lihat auth.GinMiddleware).
+// idempotencyMiddleware dipasang pada pembuatan order agar retry dengan Idempotency-Key yang sama tidak membuat order ganda.
+func (h *OrderHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware, idempotencyMiddleware gin.HandlerFunc) {
+	orderRoutes := router.Group("/orders", authMiddleware)
 	{
-		orderRoutes.POST("", h.CreateOrder)
-		orderRoutes.POST("/:order_id/confirm-payment", h.ConfirmPayment)
-		// Tambahkan GET /:id, GET /user/:user_id nanti
+		orderRoutes.POST("", idempotencyMiddleware, h.CreateOrder)
+		orderRoutes.GET("", h.ListOrders)
+		orderRoutes.GET("/:order_id", h.GetOrder)
+		// Konfirmasi manual hanya untuk admin; pembayaran normal dikonfirmasi lewat webhook provider
+		orderRoutes.POST("/:order_id/confirm-payment", auth.RequireAdmin(), h.ConfirmPayment)
+		orderRoutes.POST("/:order_id/cancel", h.CancelOrder)
+		orderRoutes.GET("/:order_id/history", h.GetOrderHistory)
+		orderRoutes.GET("/:order_id/payments", h.GetOrderPayments)
+		orderRoutes.POST("/:order_id/payments", h.CreatePaymentIntent)
+		orderRoutes.POST("/:order_id/returns", h.CreateReturn)
+		orderRoutes.GET("/:order_id/returns", h.ListOrderReturns)
+		orderRoutes.GET("/:order_id/refunds", h.GetOrderRefunds)
+		orderRoutes.GET("/:order_id/shipments", h.GetOrderShipments)
 	}
+
+	// Callback dari payment provider tidak membawa token user; keasliannya diverifikasi lewat tanda tangan HMAC
+	router.POST("/payments/webhooks/:provider", h.PaymentWebhook)
+
+	sagaRoutes := router.Group("/admin/sagas", authMiddleware, auth.RequireAdmin())
+	{
+		sagaRoutes.GET("", h.ListSagas)
+		sagaRoutes.POST("/:saga_id/retry", h.RetrySaga)
+	}
+
+	// Alur persetujuan retur: approve/reject -> receive (restock + refund otomatis) -> refund ulang jika refund gagal
+	returnRoutes := router.Group("/admin/returns", authMiddleware, auth.RequireAdmin())
+	{
+		returnRoutes.GET("", h.ListReturns)
+		returnRoutes.GET("/:return_id", h.GetReturn)
+		returnRoutes.POST("/:return_id/approve", h.ApproveReturn)
+		returnRoutes.POST("/:return_id/reject", h.RejectReturn)
+		returnRoutes.POST("/:return_id/receive", h.ReceiveReturn)
+		returnRoutes.POST("/:return_id/refund", h.RefundReturn)
+	}
+
+	adminOrderRoutes := router.Group("/admin/orders", authMiddleware, auth.RequireAdmin())
+	{
+		adminOrderRoutes.POST("/:order_id/refunds", h.CreateRefund)
+	}
+
+	// Staf gudang: antrean shipment per gudang, lalu tandai dikirim (kurir + nomor resi) dan diterima
+	shipmentRoutes := router.Group("/admin/shipments", authMiddleware, auth.RequireAdmin())
+	{
+		shipmentRoutes.GET("", h.ListShipments)
+		shipmentRoutes.GET("/:shipment_id", h.GetShipment)
+		shipmentRoutes.POST("/:shipment_id/ship", h.ShipShipment)
+		shipmentRoutes.POST("/:shipment_id/deliver", h.DeliverShipment)
+	}
+}
+
+// requireIdentity mengambil identitas dari middleware auth, atau menulis 401 jika tidak ada.
+func requireIdentity(c *gin.Context) (auth.Identity, bool) {
+	identity, ok := auth.IdentityFromGin(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return auth.Identity{}, false
+	}
+	return identity, true
+}
+
+// authorizeOrder memastikan pemanggil adalah pemilik order atau admin.
+// Order milik user lain dilaporkan sebagai 404 agar keberadaannya tidak bocor.
+func (h *OrderHandler) authorizeOrder(c *gin.Context, identity auth.Identity, orderID string) bool {
+	order, err := h.orderService.GetOrder(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return false
+		}
+		logger.Error(fmt.Sprintf("Hdl.authorizeOrder: failed to load order %s", orderID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order"})
+		return false
+	}
+	if !identity.CanActFor(order.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrOrderNotFound.Error()})
+		return false
+	}
+	return true
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
-	var req domain.CreateOrderRequest
-	// Asumsikan UserID diambil dari JWT token di middleware pada production
-	// Untuk sekarang, bisa dikirim di body atau hardcode untuk tes
-	// if userID, exists := c.Get("userID"); exists {
-	//     req.UserID = userID.(string)
-	// } else {
-	//     c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
-	//     return
-	// }
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
 
+	var req domain.CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error("CreateOrder Hdl: bad request", err, nil)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
 		return
 	}
 
-	// Jika UserID tidak dari token, dan harus dari body, pastikan validasi `binding:"required"` ada di domain.CreateOrderRequest.UserID
+	// UserID selalu berasal dari identitas terverifikasi, kecuali admin yang memesan atas nama user lain
+	if req.UserID == "" {
+		req.UserID = identity.UserID
+	} else if !identity.CanActFor(req.UserID) {
+		logger.Warn(fmt.Sprintf("CreateOrder Hdl: user %s attempted to create order for user %s", identity.UserID, req.UserID))
+		c.JSON(http.StatusForbidden, gin.H{"error": "user_id does not match the authenticated user"})
+		return
+	}
 
 	resp, err := h.orderService.CreateOrder(c.Request.Context(), req)
 	if err != nil {
+		var priceErr *service.PriceChangedError
+		if errors.As(err, &priceErr) {
+			// Client menampilkan harga terbaru lalu mengirim ulang order dengan harga tersebut
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "quote": priceErr.Quote})
+			return
+		}
+		if errors.Is(err, service.ErrUnknownProduct) || errors.Is(err, service.ErrMixedCurrencies) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPriceLookupFailed) {
+			logger.Error("CreateOrder Hdl: product price lookup failed", err, nil)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Product prices are temporarily unavailable, please retry"})
+			return
+		}
 		if errors.Is(err, service.ErrStockReservationFailed) {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()}) // 409 Conflict
 			return
@@ -75,7 +175,13 @@ func (h *OrderHandler) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
-	// Di dunia nyata, di sini ada validasi apakah request ini sah (misal, dari payment gateway callback)
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
 
 	order, err := h.orderService.ConfirmPayment(c.Request.Context(), orderID)
 	if err != nil {
@@ -90,3 +196,609 @@ func (h *OrderHandler) ConfirmPayment(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, order)
 }
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+
+	order, err := h.orderService.GetOrderDetails(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(fmt.Sprintf("Hdl.GetOrder: service error for order %s", orderID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order"})
+		return
+	}
+	if !identity.CanActFor(order.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": repository.ErrOrderNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// ListOrders mendukung query: status, from, to (RFC3339 atau YYYY-MM-DD), cursor, limit.
+// Admin boleh menambahkan user_id untuk melihat order user lain.
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+
+	// user_id dibandingkan dengan kolom UUID; nilai lain ditolak di sini alih-alih gagal di database
+	var query struct {
+		UserID string `form:"user_id" binding:"omitempty,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'user_id' parameter: must be a UUID"})
+		return
+	}
+
+	filter := domain.ListOrdersFilter{
+		UserID: identity.UserID,
+		Status: domain.OrderStatus(strings.ToUpper(c.Query("status"))),
+	}
+	if userID := query.UserID; userID != "" {
+		if !identity.CanActFor(userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to list orders of another user"})
+			return
+		}
+		filter.UserID = userID
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseTimeQuery(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' parameter: " + err.Error()})
+		return
+	}
+	if filter.CreatedTo, err = parseTimeQuery(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' parameter: " + err.Error()})
+		return
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if filter.Limit, err = strconv.Atoi(limitStr); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter"})
+			return
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.After, err = domain.DecodeOrderCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	resp, err := h.orderService.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidListQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.ListOrders: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orders"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseTimeQuery menerima RFC3339 atau tanggal (YYYY-MM-DD).
+// Untuk batas akhir berupa tanggal, seluruh hari tersebut ikut tercakup.
+func parseTimeQuery(value string, endOfRange bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("expected RFC3339 timestamp or YYYY-MM-DD date")
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+
+	var req domain.CancelOrderRequest
+	// Body opsional; alasan pembatalan boleh kosong
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+			return
+		}
+	}
+
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	order, err := h.orderService.CancelOrder(c.Request.Context(), orderID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrOrderAlreadyShipped) || errors.Is(err, service.ErrOrderCannotBeCancelled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(fmt.Sprintf("Hdl.CancelOrder: service error for order %s", orderID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	history, err := h.orderService.GetOrderHistory(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(fmt.Sprintf("Hdl.GetOrderHistory: service error for order %s", orderID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "history": history})
+}
+
+// ListSagas (admin) mendukung query: status (default STUCK) dan limit.
+func (h *OrderHandler) ListSagas(c *gin.Context) {
+	filter := domain.ListSagasFilter{Status: domain.SagaStatus(strings.ToUpper(c.Query("status")))}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limitStr); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter"})
+			return
+		}
+	}
+
+	sagas, err := h.orderService.ListSagas(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSagaQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.ListSagas: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sagas"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sagas": sagas})
+}
+
+// RetrySaga (admin) menjalankan ulang saga STUCK dan mengembalikan statusnya setelah percobaan tersebut.
+func (h *OrderHandler) RetrySaga(c *gin.Context) {
+	sagaID := c.Param("saga_id")
+
+	saga, err := h.orderService.RetrySaga(c.Request.Context(), sagaID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSagaNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrSagaNotRetryable) || errors.Is(err, repository.ErrSagaConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error(fmt.Sprintf("Hdl.RetrySaga: service error for saga %s", sagaID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry saga"})
+		return
+	}
+	c.JSON(http.StatusOK, saga)
+}
+
+// maxWebhookBodyBytes membatasi ukuran body callback payment provider.
+const maxWebhookBodyBytes = 1 << 20
+
+// writePaymentError memetakan error pembayaran ke status HTTP untuk rute pembayaran order.
+func writePaymentError(c *gin.Context, op, orderID string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOrderNotPayable), errors.Is(err, service.ErrNoPendingPayment):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentSimulationUnsupported), errors.Is(err, service.ErrPaymentSimulationDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentProviderFailed):
+		logger.Error(fmt.Sprintf("%s: payment provider failed for order %s", op, orderID), err, nil)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider is temporarily unavailable, please retry"})
+	default:
+		logger.Error(fmt.Sprintf("%s: service error for order %s", op, orderID), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+	}
+}
+
+func (h *OrderHandler) GetOrderPayments(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	payments, err := h.orderService.GetOrderPayments(c.Request.Context(), orderID)
+	if err != nil {
+		writePaymentError(c, "Hdl.GetOrderPayments", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListPaymentsResponse{OrderID: orderID, Payments: payments})
+}
+
+// CreatePaymentIntent mengembalikan payment yang masih berlaku, atau membuat percobaan baru setelah pembayaran gagal.
+func (h *OrderHandler) CreatePaymentIntent(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	payment, err := h.orderService.CreatePaymentIntent(c.Request.Context(), orderID)
+	if err != nil {
+		writePaymentError(c, "Hdl.CreatePaymentIntent", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// RegisterPaymentSimulationRoute memasang endpoint simulasi pembayaran. Hanya dipanggil jika PAYMENT_SIMULATION_ENABLED
+// diaktifkan, karena pemilik order bisa menandai order-nya sendiri lunas tanpa membayar.
+func (h *OrderHandler) RegisterPaymentSimulationRoute(router *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
+	router.POST("/orders/:order_id/payments/simulate", authMiddleware, h.SimulatePayment)
+}
+
+// SimulatePayment (hanya untuk fake provider) menyelesaikan pembayaran PENDING tanpa payment gateway sungguhan.
+func (h *OrderHandler) SimulatePayment(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	var req domain.SimulatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	payment, err := h.orderService.SimulatePayment(c.Request.Context(), orderID, req.Outcome)
+	if err != nil {
+		writePaymentError(c, "Hdl.SimulatePayment", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// PaymentWebhook menerima callback payment provider. Status 2xx memberi tahu provider bahwa callback tidak perlu dikirim ulang;
+// 5xx membuat provider mencoba lagi.
+func (h *OrderHandler) PaymentWebhook(c *gin.Context) {
+	provider := c.Param("provider")
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = h.orderService.HandlePaymentWebhook(c.Request.Context(), provider, c.Request.Header, body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	case errors.Is(err, service.ErrUnknownPaymentProvider), errors.Is(err, repository.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidWebhookSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidWebhookPayload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentAmountMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		logger.Error(fmt.Sprintf("Hdl.PaymentWebhook: failed to process %s callback", provider), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment callback"})
+	}
+}
+
+// writeReturnError memetakan error retur dan refund ke status HTTP.
+func writeReturnError(c *gin.Context, op, id string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound), errors.Is(err, repository.ErrReturnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidReturnRequest), errors.Is(err, service.ErrInvalidReturnQuery),
+		errors.Is(err, service.ErrInvalidRefundAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrReturnQuantityExceeded), errors.Is(err, repository.ErrRefundExceedsPayment):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOrderNotReturnable), errors.Is(err, service.ErrReturnInvalidState),
+		errors.Is(err, service.ErrOrderNotRefundable), errors.Is(err, service.ErrNoRefundablePayment),
+		errors.Is(err, service.ErrReturnWarehouseRejected):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRefundProviderFailed), errors.Is(err, service.ErrReturnRestockFailed):
+		logger.Error(fmt.Sprintf("%s: upstream failure for %s", op, id), err, nil)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		logger.Error(fmt.Sprintf("%s: service error for %s", op, id), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process return or refund"})
+	}
+}
+
+func (h *OrderHandler) CreateReturn(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	var req domain.CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	req.Note = strings.TrimSpace(req.Note)
+	ret, err := h.orderService.CreateReturn(c.Request.Context(), orderID, req)
+	if err != nil {
+		writeReturnError(c, "Hdl.CreateReturn", orderID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ret)
+}
+
+func (h *OrderHandler) ListOrderReturns(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	returns, err := h.orderService.ListOrderReturns(c.Request.Context(), orderID)
+	if err != nil {
+		writeReturnError(c, "Hdl.ListOrderReturns", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListReturnsResponse{Returns: returns})
+}
+
+func (h *OrderHandler) GetOrderRefunds(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	refunds, err := h.orderService.GetOrderRefunds(c.Request.Context(), orderID)
+	if err != nil {
+		writeReturnError(c, "Hdl.GetOrderRefunds", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListRefundsResponse{OrderID: orderID, Refunds: refunds})
+}
+
+// ListReturns (admin) mendukung query: status dan limit.
+func (h *OrderHandler) ListReturns(c *gin.Context) {
+	filter := domain.ListReturnsFilter{Status: domain.ReturnStatus(strings.ToUpper(c.Query("status")))}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limitStr); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter"})
+			return
+		}
+	}
+
+	returns, err := h.orderService.ListReturns(c.Request.Context(), filter)
+	if err != nil {
+		writeReturnError(c, "Hdl.ListReturns", "return list", err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListReturnsResponse{Returns: returns})
+}
+
+func (h *OrderHandler) GetReturn(c *gin.Context) {
+	returnID := c.Param("return_id")
+	ret, err := h.orderService.GetReturn(c.Request.Context(), returnID)
+	if err != nil {
+		writeReturnError(c, "Hdl.GetReturn", returnID, err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *OrderHandler) ApproveReturn(c *gin.Context) {
+	returnID := c.Param("return_id")
+	ret, err := h.orderService.ApproveReturn(c.Request.Context(), returnID)
+	if err != nil {
+		writeReturnError(c, "Hdl.ApproveReturn", returnID, err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *OrderHandler) RejectReturn(c *gin.Context) {
+	returnID := c.Param("return_id")
+	var req domain.RejectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	ret, err := h.orderService.RejectReturn(c.Request.Context(), returnID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		writeReturnError(c, "Hdl.RejectReturn", returnID, err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// ReceiveReturn (admin) memasukkan barang retur ke stok gudang lalu me-refund nominalnya.
+// Jika refund gagal (502), retur tetap RECEIVED dan refund bisa diulang lewat POST /admin/returns/:return_id/refund.
+func (h *OrderHandler) ReceiveReturn(c *gin.Context) {
+	returnID := c.Param("return_id")
+	var req domain.ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	ret, err := h.orderService.ReceiveReturn(c.Request.Context(), returnID, req.WarehouseID)
+	if err != nil {
+		writeReturnError(c, "Hdl.ReceiveReturn", returnID, err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+func (h *OrderHandler) RefundReturn(c *gin.Context) {
+	returnID := c.Param("return_id")
+	ret, err := h.orderService.RefundReturn(c.Request.Context(), returnID)
+	if err != nil {
+		writeReturnError(c, "Hdl.RefundReturn", returnID, err)
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// CreateRefund (admin) mengembalikan dana order secara manual, penuh atau sebagian.
+func (h *OrderHandler) CreateRefund(c *gin.Context) {
+	orderID := c.Param("order_id")
+	var req domain.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	refund, err := h.orderService.CreateRefund(c.Request.Context(), orderID, req)
+	if err != nil {
+		writeReturnError(c, "Hdl.CreateRefund", orderID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, refund)
+}
+
+// writeShipmentError memetakan error shipment ke status HTTP.
+func writeShipmentError(c *gin.Context, op, id string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound), errors.Is(err, repository.ErrShipmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidShipmentQuery), errors.Is(err, service.ErrInvalidShipmentRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShipmentInvalidState), errors.Is(err, service.ErrOrderNotShippable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(fmt.Sprintf("%s: service error for %s", op, id), err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process shipment"})
+	}
+}
+
+func (h *OrderHandler) GetOrderShipments(c *gin.Context) {
+	identity, ok := requireIdentity(c)
+	if !ok {
+		return
+	}
+	orderID := c.Param("order_id")
+	if !h.authorizeOrder(c, identity, orderID) {
+		return
+	}
+
+	shipments, err := h.orderService.GetOrderShipments(c.Request.Context(), orderID)
+	if err != nil {
+		writeShipmentError(c, "Hdl.GetOrderShipments", orderID, err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListShipmentsResponse{OrderID: orderID, Shipments: shipments})
+}
+
+// ListShipments (admin) mendukung query: warehouse_id, status dan limit.
+func (h *OrderHandler) ListShipments(c *gin.Context) {
+	filter := domain.ListShipmentsFilter{
+		WarehouseID: c.Query("warehouse_id"),
+		Status:      domain.ShipmentStatus(strings.ToUpper(c.Query("status"))),
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limitStr); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter"})
+			return
+		}
+	}
+
+	shipments, err := h.orderService.ListShipments(c.Request.Context(), filter)
+	if err != nil {
+		writeShipmentError(c, "Hdl.ListShipments", "shipment list", err)
+		return
+	}
+	c.JSON(http.StatusOK, domain.ListShipmentsResponse{Shipments: shipments})
+}
+
+func (h *OrderHandler) GetShipment(c *gin.Context) {
+	shipmentID := c.Param("shipment_id")
+	shipment, err := h.orderService.GetShipment(c.Request.Context(), shipmentID)
+	if err != nil {
+		writeShipmentError(c, "Hdl.GetShipment", shipmentID, err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+// ShipShipment (admin) mencatat kurir dan nomor resi saat paket diserahkan ke kurir.
+func (h *OrderHandler) ShipShipment(c *gin.Context) {
+	shipmentID := c.Param("shipment_id")
+	var req domain.ShipShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	req.Carrier = strings.TrimSpace(req.Carrier)
+	req.TrackingNumber = strings.TrimSpace(req.TrackingNumber)
+	shipment, err := h.orderService.ShipShipment(c.Request.Context(), shipmentID, req)
+	if err != nil {
+		writeShipmentError(c, "Hdl.ShipShipment", shipmentID, err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
+
+func (h *OrderHandler) DeliverShipment(c *gin.Context) {
+	shipmentID := c.Param("shipment_id")
+	shipment, err := h.orderService.DeliverShipment(c.Request.Context(), shipmentID)
+	if err != nil {
+		writeShipmentError(c, "Hdl.DeliverShipment", shipmentID, err)
+		return
+	}
+	c.JSON(http.StatusOK, shipment)
+}
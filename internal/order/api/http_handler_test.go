@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter memasang rute order dengan identitas dari header gateway, tanpa service di belakangnya:
+// request yang ditolak handler sebelum memanggil service tidak membutuhkannya.
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	noop := func(c *gin.Context) { c.Next() }
+	NewOrderHandler(nil).RegisterRoutes(router.Group("/api/v1"), auth.GinMiddleware([]byte("test-secret"), true), noop)
+	return router
+}
+
+func getAs(router *gin.Engine, userID, role, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(auth.HeaderUserID, userID)
+	req.Header.Set(auth.HeaderUserRole, role)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOrderHandler_ListOrders(t *testing.T) {
+	router := newTestRouter()
+
+	t.Run("Non-UUID user_id is rejected", func(t *testing.T) {
+		rec := getAs(router, "admin-1", auth.RoleAdmin, "/api/v1/orders?user_id=not-a-uuid")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Customer cannot list another user's orders", func(t *testing.T) {
+		rec := getAs(router, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", auth.RoleCustomer,
+			"/api/v1/orders?user_id=b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a12")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
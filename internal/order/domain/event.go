@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+// Tipe event siklus hidup order yang dikirim lewat outbox.
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderPaid      = "order.paid"
+	EventOrderTimedOut  = "order.timed_out"
+	EventOrderCancelled = "order.cancelled"
+
+	EventOrderPartiallyShipped = "order.partially_shipped"
+	EventOrderShipped          = "order.shipped"
+	EventOrderDelivered        = "order.delivered"
+
+	EventOrderPartiallyRefunded = "order.partially_refunded"
+	EventOrderRefunded          = "order.refunded"
+)
+
+// EventTypeForStatus mengembalikan tipe event untuk perpindahan ke status tertentu.
+// Status lain (misal AWAITING_SHIPMENT) belum dipublikasikan.
+func EventTypeForStatus(s OrderStatus) (string, bool) {
+	switch s {
+	case StatusPaymentConfirmed:
+		return EventOrderPaid, true
+	case StatusPaymentTimeout:
+		return EventOrderTimedOut, true
+	case StatusCancelled:
+		return EventOrderCancelled, true
+	case StatusPartiallyShipped:
+		return EventOrderPartiallyShipped, true
+	case StatusShipped:
+		return EventOrderShipped, true
+	case StatusDelivered:
+		return EventOrderDelivered, true
+	case StatusPartiallyRefunded:
+		return EventOrderPartiallyRefunded, true
+	case StatusRefunded:
+		return EventOrderRefunded, true
+	}
+	return "", false
+}
+
+// OrderEvent adalah payload event order.
+type OrderEvent struct {
+	OrderID        string       `json:"order_id"`
+	UserID         string       `json:"user_id"`
+	Status         OrderStatus  `json:"status"`
+	PreviousStatus *OrderStatus `json:"previous_status,omitempty"`
+	TotalAmount    money.Money  `json:"total_amount"`
+	RefundRequired bool         `json:"refund_required,omitempty"`
+	Actor          string       `json:"actor"`
+	Reason         string       `json:"reason,omitempty"`
+	Items          []OrderItem  `json:"items,omitempty"` // Hanya untuk order.created
+	OccurredAt     time.Time    `json:"occurred_at"`
+}
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "PENDING"
+	OutboxStatusPublished OutboxStatus = "PUBLISHED"
+	OutboxStatusDead      OutboxStatus = "DEAD" // Gagal dikirim setelah batas percobaan; perlu ditangani manual
+)
+
+// OutboxEvent adalah event yang disimpan di tabel outbox dalam transaksi yang sama dengan perubahan order,
+// lalu dikirim oleh relay secara terpisah.
+type OutboxEvent struct {
+	ID            string          `json:"id"`
+	AggregateID   string          `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     *string         `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty"`
+}
@@ -0,0 +1,69 @@
+package domain
+
+// FulfillmentGroup adalah bagian order yang dipenuhi dari satu gudang, dihitung saat checkout dari reservasi stok.
+// Setiap group nantinya menjadi satu shipment, sehingga ongkos kirim dan estimasi pengiriman dapat dihitung per group.
+type FulfillmentGroup struct {
+	ID          string                 `json:"id"`
+	OrderID     string                 `json:"-"`
+	WarehouseID string                 `json:"warehouse_id"`
+	Sequence    int                    `json:"sequence"` // Mulai dari 1
+	Items       []FulfillmentGroupItem `json:"items"`
+}
+
+// FulfillmentGroupItem adalah produk dan quantity yang dikirim dari gudang group, dengan snapshot nama dan SKU.
+type FulfillmentGroupItem struct {
+	ID          string `json:"id"`
+	GroupID     string `json:"-"`
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity"`
+}
+
+// FulfillmentAllocation adalah quantity satu produk yang direservasi di satu gudang untuk order.
+type FulfillmentAllocation struct {
+	WarehouseID string
+	ProductID   string
+	Quantity    int
+}
+
+// PlanFulfillmentGroups mengelompokkan alokasi stok order menjadi satu group per gudang, berurutan sesuai gudang
+// yang pertama kali muncul. Produk yang sama dalam satu gudang digabung; nama dan SKU diambil dari item order.
+func PlanFulfillmentGroups(allocations []FulfillmentAllocation, items []OrderItem) []FulfillmentGroup {
+	snapshots := make(map[string]OrderItem, len(items))
+	for _, item := range items {
+		if _, ok := snapshots[item.ProductID]; !ok {
+			snapshots[item.ProductID] = item
+		}
+	}
+
+	var groups []FulfillmentGroup
+	byWarehouse := make(map[string]int)
+	for _, a := range allocations {
+		i, ok := byWarehouse[a.WarehouseID]
+		if !ok {
+			i = len(groups)
+			byWarehouse[a.WarehouseID] = i
+			groups = append(groups, FulfillmentGroup{WarehouseID: a.WarehouseID, Sequence: i + 1})
+		}
+		group := &groups[i]
+		merged := false
+		for j := range group.Items {
+			if group.Items[j].ProductID == a.ProductID {
+				group.Items[j].Quantity += a.Quantity
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			snapshot := snapshots[a.ProductID]
+			group.Items = append(group.Items, FulfillmentGroupItem{
+				ProductID:   a.ProductID,
+				ProductName: snapshot.ProductName,
+				SKU:         snapshot.SKU,
+				Quantity:    a.Quantity,
+			})
+		}
+	}
+	return groups
+}
@@ -1,7 +1,13 @@
 package domain
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
 )
 
 type OrderStatus string
@@ -12,45 +18,148 @@ const (
 	StatusPaymentConfirmed OrderStatus = "PAYMENT_CONFIRMED"
 	StatusAwaitingShipment OrderStatus = "AWAITING_SHIPMENT"
 	StatusShipped          OrderStatus = "SHIPPED"
+	// Sebagian shipment sudah dikirim, sisanya masih menunggu di gudang asal
+	StatusPartiallyShipped OrderStatus = "PARTIALLY_SHIPPED"
 	StatusDelivered        OrderStatus = "DELIVERED"
 	StatusCancelled        OrderStatus = "CANCELLED"
 	StatusFailed           OrderStatus = "FAILED"
+	// Sebagian atau seluruh nominal order sudah dikembalikan ke customer (retur atau refund manual)
+	StatusPartiallyRefunded OrderStatus = "PARTIALLY_REFUNDED"
+	StatusRefunded          OrderStatus = "REFUNDED"
 )
 
 type Order struct {
-	ID          string      `json:"id"`
-	UserID      string      `json:"user_id"` // UUID
-	TotalAmount float64     `json:"total_amount"`
-	Status      OrderStatus `json:"status"`
-	Items       []OrderItem `json:"items,omitempty"` // Di-populate saat get order details
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	ID                 string      `json:"id"`
+	UserID             string      `json:"user_id"` // UUID
+	TotalAmount        money.Money `json:"total_amount"`
+	Status             OrderStatus `json:"status"`
+	CancellationReason *string     `json:"cancellation_reason,omitempty"`
+	CancelledAt        *time.Time  `json:"cancelled_at,omitempty"`
+	RefundRequired     bool        `json:"refund_required"` // True jika order dibatalkan setelah pembayaran dan dana perlu dikembalikan
+	Items              []OrderItem `json:"items,omitempty"` // Di-populate saat get order details
+	// FulfillmentGroups adalah pembagian item per gudang dari checkout; di-populate saat get order details
+	FulfillmentGroups []FulfillmentGroup `json:"fulfillment_groups,omitempty"`
+	CreatedBy         string             `json:"-"` // Actor yang dicatat pada riwayat status awal
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID              string    `json:"id"`
-	OrderID         string    `json:"-"`          // Biasanya tidak perlu di JSON item, sudah ada di Order
-	ProductID       string    `json:"product_id"` // UUID
-	Quantity        int       `json:"quantity"`
-	PriceAtPurchase float64   `json:"price_at_purchase"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              string      `json:"id"`
+	OrderID         string      `json:"-"`            // Biasanya tidak perlu di JSON item, sudah ada di Order
+	ProductID       string      `json:"product_id"`   // UUID
+	ProductName     string      `json:"product_name"` // Snapshot dari Product Service saat checkout
+	SKU             string      `json:"sku"`
+	Quantity        int         `json:"quantity"`
+	PriceAtPurchase money.Money `json:"price_at_purchase"`
+	CreatedAt       time.Time   `json:"created_at"`
 }
 
 // Untuk request pembuatan order
 type CreateOrderItemRequest struct {
-	ProductID string `json:"product_id" binding:"required"`
+	ProductID string `json:"product_id" binding:"required,uuid"`
 	Quantity  int    `json:"quantity" binding:"required,gt=0"`
-	// Harga bisa diambil dari Product Service saat checkout nanti, atau dikirim client
-	// Untuk sekarang, asumsikan client mengirimkan harga, atau kita butuh call ke Product Service
-	Price float64 `json:"price" binding:"required,gt=0"` // Harga satuan produk
+	// Harga yang dilihat client (opsional). Harga resmi selalu diambil dari Product Service;
+	// jika berbeda, order ditolak dengan penawaran harga terbaru.
+	Price *money.Money `json:"price,omitempty"` // Harga satuan produk, misal {"amount": "14000000", "currency": "IDR"}
+}
+
+// PriceQuote adalah harga terbaru satu item, dikembalikan saat harga dari client sudah tidak berlaku.
+type PriceQuote struct {
+	ProductID      string       `json:"product_id"`
+	Name           string       `json:"name"`
+	SKU            string       `json:"sku"`
+	Quantity       int          `json:"quantity"`
+	RequestedPrice *money.Money `json:"requested_price,omitempty"`
+	CurrentPrice   money.Money  `json:"current_price"`
 }
 
 type CreateOrderRequest struct {
-	UserID string                   `json:"user_id" binding:"required"` // Didapat dari auth token idealnya
+	// Diisi dari identitas auth. Hanya admin yang boleh mengisi user_id milik user lain.
+	UserID string                   `json:"user_id,omitempty"`
 	Items  []CreateOrderItemRequest `json:"items" binding:"required,dive"`
 }
 
+// IsValid memeriksa apakah status termasuk status order yang dikenal.
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case StatusPendingPayment, StatusPaymentTimeout, StatusPaymentConfirmed, StatusAwaitingShipment,
+		StatusPartiallyShipped, StatusShipped, StatusDelivered, StatusCancelled, StatusFailed, StatusPartiallyRefunded, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// StockDeduction mencatat dari gudang mana stok sebuah item order dikurangi saat pembayaran dikonfirmasi,
+// sehingga pembatalan bisa mengembalikan stok ke gudang yang sama.
+// RestockedAt terisi setelah stoknya dikembalikan, agar saga cancel_restock yang diulang tidak me-restock dua kali.
+type StockDeduction struct {
+	ID          string     `json:"id"`
+	OrderID     string     `json:"order_id"`
+	ProductID   string     `json:"product_id"`
+	WarehouseID string     `json:"warehouse_id"`
+	Quantity    int        `json:"quantity"`
+	RestockedAt *time.Time `json:"restocked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
 // Response setelah order dibuat
 type CreateOrderResponse struct {
 	Order
+	// Payment intent untuk order ini; kosong jika payment provider gagal dihubungi (bisa dibuat ulang lewat POST /orders/:order_id/payments)
+	Payment *Payment `json:"payment,omitempty"`
+}
+
+const (
+	DefaultOrderListLimit = 20
+	MaxOrderListLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// OrderCursor adalah posisi keyset pagination (created_at DESC, id DESC).
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Encode menghasilkan cursor opaque untuk dikirim ke client.
+func (c OrderCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeOrderCursor(encoded string) (*OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	tsPart, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, tsPart)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return &OrderCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// ListOrdersFilter dipakai untuk query daftar order milik satu user.
+type ListOrdersFilter struct {
+	UserID      string
+	Status      OrderStatus // Kosong berarti semua status
+	CreatedFrom *time.Time  // Inklusif
+	CreatedTo   *time.Time  // Eksklusif
+	After       *OrderCursor
+	Limit       int
+}
+
+type ListOrdersResponse struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "PENDING" // Intent dibuat, menunggu customer membayar di provider
+	PaymentStatusSucceeded PaymentStatus = "SUCCEEDED"
+	PaymentStatusFailed    PaymentStatus = "FAILED"
+)
+
+// IsFinal bernilai true jika status pembayaran tidak bisa berubah lagi.
+func (s PaymentStatus) IsFinal() bool {
+	return s == PaymentStatusSucceeded || s == PaymentStatusFailed
+}
+
+// Payment adalah satu percobaan pembayaran order lewat payment provider.
+type Payment struct {
+	ID                string        `json:"id"`
+	OrderID           string        `json:"order_id"`
+	Provider          string        `json:"provider"`
+	ProviderPaymentID string        `json:"provider_payment_id"`
+	Amount            money.Money   `json:"amount"`
+	Status            PaymentStatus `json:"status"`
+	CheckoutURL       string        `json:"checkout_url,omitempty"`
+	FailureReason     *string       `json:"failure_reason,omitempty"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// Tipe event webhook yang dipahami Order Service. Tipe lain diterima tetapi diabaikan.
+const (
+	PaymentEventSucceeded = "payment.succeeded"
+	PaymentEventFailed    = "payment.failed"
+)
+
+// PaymentEvent adalah callback webhook yang sudah diverifikasi tanda tangannya oleh PaymentProvider.
+type PaymentEvent struct {
+	Provider          string       `json:"-"`
+	EventID           string       `json:"id"` // Unik per provider; dipakai untuk mendeteksi callback ganda
+	Type              string       `json:"type"`
+	ProviderPaymentID string       `json:"payment_id"`
+	Amount            *money.Money `json:"amount,omitempty"`
+	FailureReason     string       `json:"failure_reason,omitempty"`
+	OccurredAt        time.Time    `json:"occurred_at"`
+}
+
+// PaymentStatusForEvent mengembalikan status pembayaran hasil event, atau false untuk tipe event yang diabaikan.
+func PaymentStatusForEvent(eventType string) (PaymentStatus, bool) {
+	switch eventType {
+	case PaymentEventSucceeded:
+		return PaymentStatusSucceeded, true
+	case PaymentEventFailed:
+		return PaymentStatusFailed, true
+	}
+	return "", false
+}
+
+type ListPaymentsResponse struct {
+	OrderID  string    `json:"order_id"`
+	Payments []Payment `json:"payments"`
+}
+
+// SimulatePaymentRequest dipakai endpoint pengembangan untuk memicu webhook dari fake provider.
+type SimulatePaymentRequest struct {
+	Outcome string `json:"outcome" binding:"required,oneof=succeeded failed"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "PENDING" // Dicatat, belum dikonfirmasi provider
+	RefundStatusSucceeded RefundStatus = "SUCCEEDED"
+	RefundStatusFailed    RefundStatus = "FAILED"
+)
+
+// Refund adalah pengembalian dana (penuh atau sebagian) atas payment yang sudah SUCCEEDED.
+// Total refund PENDING dan SUCCEEDED untuk satu payment tidak pernah melebihi nominal payment tersebut.
+type Refund struct {
+	ID               string       `json:"id"`
+	OrderID          string       `json:"order_id"`
+	PaymentID        string       `json:"payment_id"`
+	ReturnID         *string      `json:"return_id,omitempty"` // Kosong untuk refund manual oleh admin
+	Amount           money.Money  `json:"amount"`
+	Status           RefundStatus `json:"status"`
+	Reason           string       `json:"reason"`
+	ProviderRefundID *string      `json:"provider_refund_id,omitempty"`
+	FailureReason    *string      `json:"failure_reason,omitempty"`
+	CreatedBy        string       `json:"created_by"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// CreateRefundRequest dipakai admin untuk refund manual, misal kompensasi atau order yang dibatalkan setelah dibayar.
+type CreateRefundRequest struct {
+	Amount *money.Money `json:"amount" binding:"required"`
+	Reason string       `json:"reason" binding:"required,max=500"`
+}
+
+type ListRefundsResponse struct {
+	OrderID string   `json:"order_id"`
+	Refunds []Refund `json:"refunds"`
+}
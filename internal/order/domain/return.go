@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "REQUESTED" // Diajukan customer, menunggu persetujuan admin
+	ReturnStatusApproved  ReturnStatus = "APPROVED"  // Disetujui; customer mengirim barang ke gudang
+	ReturnStatusRejected  ReturnStatus = "REJECTED"
+	ReturnStatusReceived  ReturnStatus = "RECEIVED" // Barang diterima gudang; menunggu refund berhasil
+	ReturnStatusRefunded  ReturnStatus = "REFUNDED"
+)
+
+// returnTransitions adalah alur persetujuan retur. Status tanpa entri adalah status akhir.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected},
+	ReturnStatusApproved:  {ReturnStatusReceived},
+	ReturnStatusReceived:  {ReturnStatusRefunded},
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ReturnStatus) IsValid() bool {
+	switch s {
+	case ReturnStatusRequested, ReturnStatusApproved, ReturnStatusRejected, ReturnStatusReceived, ReturnStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// ItemCondition adalah kondisi barang retur menurut customer. Barang DAMAGED tetap di-refund
+// tetapi tidak dikembalikan ke stok yang bisa dijual.
+type ItemCondition string
+
+const (
+	ItemConditionUnopened ItemCondition = "UNOPENED"
+	ItemConditionOpened   ItemCondition = "OPENED"
+	ItemConditionDamaged  ItemCondition = "DAMAGED"
+)
+
+func (c ItemCondition) Restockable() bool {
+	return c != ItemConditionDamaged
+}
+
+// ReturnRequest (RMA) adalah pengajuan retur atas sebagian atau seluruh item satu order.
+type ReturnRequest struct {
+	ID              string       `json:"id"`
+	OrderID         string       `json:"order_id"`
+	UserID          string       `json:"user_id"`
+	Status          ReturnStatus `json:"status"`
+	Note            *string      `json:"note,omitempty"`
+	RejectionReason *string      `json:"rejection_reason,omitempty"`
+	WarehouseID     *string      `json:"warehouse_id,omitempty"` // Gudang yang menerima barang retur
+	RefundID        *string      `json:"refund_id,omitempty"`
+	Items           []ReturnItem `json:"items"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+type ReturnItem struct {
+	ID           string        `json:"id"`
+	ReturnID     string        `json:"-"`
+	OrderItemID  string        `json:"order_item_id"`
+	ProductID    string        `json:"product_id"`
+	Quantity     int           `json:"quantity"`
+	Reason       string        `json:"reason"`
+	Condition    ItemCondition `json:"condition"`
+	RefundAmount money.Money   `json:"refund_amount"` // Harga beli x quantity
+}
+
+// RefundTotal menjumlahkan nominal refund semua item retur.
+func (r *ReturnRequest) RefundTotal() (money.Money, error) {
+	if len(r.Items) == 0 {
+		return money.Money{}, nil
+	}
+	total := money.Zero(r.Items[0].RefundAmount.Currency())
+	for _, item := range r.Items {
+		var err error
+		if total, err = total.Add(item.RefundAmount); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+type CreateReturnItemRequest struct {
+	OrderItemID string        `json:"order_item_id" binding:"required,uuid"`
+	Quantity    int           `json:"quantity" binding:"required,gt=0"`
+	Reason      string        `json:"reason" binding:"required,max=500"`
+	Condition   ItemCondition `json:"condition" binding:"required,oneof=UNOPENED OPENED DAMAGED"`
+}
+
+type CreateReturnRequest struct {
+	Note  string                    `json:"note" binding:"max=1000"`
+	Items []CreateReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type RejectReturnRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type ReceiveReturnRequest struct {
+	WarehouseID string `json:"warehouse_id" binding:"required,uuid"`
+}
+
+// ReturnTransition memindahkan status retur secara compare-and-set (WHERE status = From).
+// Field opsional hanya disimpan jika tidak kosong.
+type ReturnTransition struct {
+	ReturnID        string
+	From            ReturnStatus
+	To              ReturnStatus
+	RejectionReason string
+	WarehouseID     string
+	RefundID        string
+}
+
+const (
+	DefaultReturnListLimit = 50
+	MaxReturnListLimit     = 200
+)
+
+type ListReturnsFilter struct {
+	Status ReturnStatus // Kosong berarti semua status
+	Limit  int
+}
+
+type ListReturnsResponse struct {
+	Returns []ReturnRequest `json:"returns"`
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tipe saga yang dijalankan oleh Order Service.
+const (
+	SagaTypeCheckout = "checkout"
+	// Mengembalikan stok order yang dibatalkan setelah dibayar ke gudang asalnya
+	SagaTypeCancelRestock = "cancel_restock"
+)
+
+// Langkah saga checkout, dijalankan berurutan.
+const (
+	SagaStepReserveStock = "reserve_stock"
+	SagaStepCreateOrder  = "create_order"
+	SagaStepPayment      = "payment"
+	SagaStepCommitStock  = "commit_stock"
+	// Membuat shipment per gudang asal stok dan memindahkan order ke AWAITING_SHIPMENT
+	SagaStepCreateShipments = "create_shipments"
+)
+
+// Langkah saga cancel_restock.
+const (
+	SagaStepRestockStock = "restock_stock"
+)
+
+type SagaStatus string
+
+const (
+	SagaStatusRunning      SagaStatus = "RUNNING"      // Menjalankan langkah maju
+	SagaStatusWaiting      SagaStatus = "WAITING"      // Menunggu kejadian dari luar (misal pembayaran)
+	SagaStatusCompensating SagaStatus = "COMPENSATING" // Membatalkan langkah yang sudah berhasil, dari belakang ke depan
+	SagaStatusCompleted    SagaStatus = "COMPLETED"
+	SagaStatusCompensated  SagaStatus = "COMPENSATED"
+	SagaStatusStuck        SagaStatus = "STUCK" // Percobaan habis atau gagal setelah titik tanpa kompensasi; perlu retry manual
+)
+
+func (s SagaStatus) IsValid() bool {
+	switch s {
+	case SagaStatusRunning, SagaStatusWaiting, SagaStatusCompensating, SagaStatusCompleted, SagaStatusCompensated, SagaStatusStuck:
+		return true
+	}
+	return false
+}
+
+type SagaStepStatus string
+
+const (
+	SagaStepPending     SagaStepStatus = "PENDING"
+	SagaStepSucceeded   SagaStepStatus = "SUCCEEDED"
+	SagaStepFailed      SagaStepStatus = "FAILED" // Gagal permanen dan memicu kompensasi
+	SagaStepCompensated SagaStepStatus = "COMPENSATED"
+)
+
+// SagaStepState adalah status satu langkah saga yang disimpan bersama saga.
+type SagaStepState struct {
+	Name                 string         `json:"name"`
+	Status               SagaStepStatus `json:"status"`
+	Attempts             int            `json:"attempts"`
+	CompensationAttempts int            `json:"compensation_attempts,omitempty"`
+	LastError            string         `json:"last_error,omitempty"`
+	UpdatedAt            *time.Time     `json:"updated_at,omitempty"`
+}
+
+// Saga adalah proses multi-langkah yang statusnya disimpan setelah setiap langkah,
+// sehingga bisa dilanjutkan setelah restart.
+// Version dipakai untuk compare-and-set saat update agar dua proses tidak menjalankan saga yang sama.
+type Saga struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	OrderID       string          `json:"order_id"`
+	Status        SagaStatus      `json:"status"`
+	CurrentStep   int             `json:"current_step"` // Indeks langkah yang sedang dijalankan atau dikompensasi
+	Steps         []SagaStepState `json:"steps"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     *string         `json:"last_error,omitempty"`
+	Version       int             `json:"version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// FailedStep mengembalikan nama langkah yang gagal permanen, jika ada.
+func (s *Saga) FailedStep() (string, bool) {
+	for _, step := range s.Steps {
+		if step.Status == SagaStepFailed {
+			return step.Name, true
+		}
+	}
+	return "", false
+}
+
+// ListSagasFilter untuk endpoint admin. Status kosong berarti STUCK.
+type ListSagasFilter struct {
+	Status SagaStatus
+	Limit  int
+}
+
+const (
+	DefaultSagaListLimit = 50
+	MaxSagaListLimit     = 200
+)
@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"time"
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPending   ShipmentStatus = "PENDING" // Menunggu dikemas dan diserahkan ke kurir oleh gudang
+	ShipmentStatusShipped   ShipmentStatus = "SHIPPED"
+	ShipmentStatusDelivered ShipmentStatus = "DELIVERED"
+	ShipmentStatusCancelled ShipmentStatus = "CANCELLED" // Order dibatalkan sebelum shipment dikirim
+)
+
+// shipmentTransitions adalah alur pengiriman. Status tanpa entri adalah status akhir.
+var shipmentTransitions = map[ShipmentStatus][]ShipmentStatus{
+	ShipmentStatusPending: {ShipmentStatusShipped, ShipmentStatusCancelled},
+	ShipmentStatusShipped: {ShipmentStatusDelivered},
+}
+
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	for _, allowed := range shipmentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s ShipmentStatus) IsValid() bool {
+	switch s {
+	case ShipmentStatusPending, ShipmentStatusShipped, ShipmentStatusDelivered, ShipmentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Shipment adalah satu paket order yang dikirim dari satu gudang asal stok.
+type Shipment struct {
+	ID             string         `json:"id"`
+	OrderID        string         `json:"order_id"`
+	WarehouseID    string         `json:"warehouse_id"`
+	Status         ShipmentStatus `json:"status"`
+	Carrier        *string        `json:"carrier,omitempty"`
+	TrackingNumber *string        `json:"tracking_number,omitempty"`
+	ShippedBy      *string        `json:"shipped_by,omitempty"` // Actor yang menyerahkan paket ke kurir
+	ShippedAt      *time.Time     `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	Items          []ShipmentItem `json:"items"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ShipmentItem adalah isi paket, dengan snapshot nama dan SKU dari item order.
+type ShipmentItem struct {
+	ID          string `json:"id"`
+	ShipmentID  string `json:"-"`
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity"`
+}
+
+// PlanShipments mengelompokkan pengurangan stok order per gudang menjadi satu shipment per gudang,
+// berurutan sesuai gudang yang pertama kali muncul. Nama dan SKU diambil dari item order.
+func PlanShipments(orderID string, deductions []StockDeduction, items []OrderItem) []Shipment {
+	snapshots := make(map[string]OrderItem, len(items))
+	for _, item := range items {
+		if _, ok := snapshots[item.ProductID]; !ok {
+			snapshots[item.ProductID] = item
+		}
+	}
+
+	var shipments []Shipment
+	byWarehouse := make(map[string]int)
+	for _, d := range deductions {
+		i, ok := byWarehouse[d.WarehouseID]
+		if !ok {
+			i = len(shipments)
+			byWarehouse[d.WarehouseID] = i
+			shipments = append(shipments, Shipment{OrderID: orderID, WarehouseID: d.WarehouseID, Status: ShipmentStatusPending})
+		}
+		shipment := &shipments[i]
+		merged := false
+		for j := range shipment.Items {
+			if shipment.Items[j].ProductID == d.ProductID {
+				shipment.Items[j].Quantity += d.Quantity
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			snapshot := snapshots[d.ProductID]
+			shipment.Items = append(shipment.Items, ShipmentItem{
+				ProductID:   d.ProductID,
+				ProductName: snapshot.ProductName,
+				SKU:         snapshot.SKU,
+				Quantity:    d.Quantity,
+			})
+		}
+	}
+	return shipments
+}
+
+// FulfillmentStatus menurunkan status order dari status shipment-nya (shipment CANCELLED diabaikan):
+// semua DELIVERED -> DELIVERED, semua sudah dikirim -> SHIPPED, sebagian dikirim -> PARTIALLY_SHIPPED,
+// belum ada yang dikirim -> AWAITING_SHIPMENT. ok bernilai false jika tidak ada shipment aktif.
+func FulfillmentStatus(statuses []ShipmentStatus) (status OrderStatus, ok bool) {
+	var active, shipped, delivered int
+	for _, s := range statuses {
+		switch s {
+		case ShipmentStatusCancelled:
+			continue
+		case ShipmentStatusDelivered:
+			delivered++
+			shipped++
+		case ShipmentStatusShipped:
+			shipped++
+		}
+		active++
+	}
+	switch {
+	case active == 0:
+		return "", false
+	case delivered == active:
+		return StatusDelivered, true
+	case shipped == active:
+		return StatusShipped, true
+	case shipped > 0:
+		return StatusPartiallyShipped, true
+	default:
+		return StatusAwaitingShipment, true
+	}
+}
+
+// ShipShipmentRequest dikirim staf gudang saat paket diserahkan ke kurir.
+type ShipShipmentRequest struct {
+	Carrier        string `json:"carrier" binding:"required,max=100"`
+	TrackingNumber string `json:"tracking_number" binding:"required,max=100"`
+}
+
+// ShipmentTransition memindahkan status shipment secara compare-and-set (WHERE status = From).
+// Carrier dan TrackingNumber hanya dipakai saat shipment dikirim.
+type ShipmentTransition struct {
+	ShipmentID     string
+	From           ShipmentStatus
+	To             ShipmentStatus
+	Carrier        string
+	TrackingNumber string
+	Actor          string
+}
+
+const (
+	DefaultShipmentListLimit = 50
+	MaxShipmentListLimit     = 200
+)
+
+// ListShipmentsFilter dipakai staf gudang untuk melihat antrean shipment.
+type ListShipmentsFilter struct {
+	WarehouseID string         // Kosong berarti semua gudang
+	Status      ShipmentStatus // Kosong berarti semua status
+	Limit       int
+}
+
+type ListShipmentsResponse struct {
+	OrderID   string     `json:"order_id,omitempty"` // Diisi untuk daftar shipment satu order
+	Shipments []Shipment `json:"shipments"`
+}
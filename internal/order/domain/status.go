@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid order status transition")
+
+// orderTransitions adalah tabel transisi status yang diizinkan.
+// Status yang tidak punya entri (atau entri kosong) adalah status akhir.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPendingPayment:    {StatusPaymentConfirmed, StatusPaymentTimeout, StatusCancelled, StatusFailed},
+	StatusPaymentConfirmed:  {StatusAwaitingShipment, StatusCancelled, StatusFailed},
+	StatusAwaitingShipment:  {StatusPartiallyShipped, StatusShipped, StatusCancelled},
+	StatusPartiallyShipped:  {StatusShipped},
+	StatusShipped:           {StatusDelivered, StatusPartiallyRefunded, StatusRefunded},
+	StatusDelivered:         {StatusPartiallyRefunded, StatusRefunded},
+	StatusPartiallyRefunded: {StatusRefunded},
+	StatusRefunded:          {},
+	StatusCancelled:         {},
+	StatusPaymentTimeout:    {},
+	StatusFailed:            {},
+}
+
+// CanTransitionTo bernilai true jika perpindahan dari s ke next diizinkan oleh tabel transisi.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal bernilai true untuk status yang tidak bisa berpindah lagi.
+func (s OrderStatus) IsTerminal() bool {
+	return len(orderTransitions[s]) == 0
+}
+
+// Actor untuk perubahan status yang dipicu sistem (bukan request user).
+const (
+	ActorSystem               = "system"
+	ActorSystemPaymentTimeout = "system:payment-timeout"
+	ActorSystemPaymentWebhook = "system:payment-webhook"
+)
+
+// StatusTransition mendeskripsikan satu perubahan status yang diminta.
+// Perubahan hanya diterapkan jika status order saat ini masih sama dengan From (compare-and-set).
+type StatusTransition struct {
+	OrderID string
+	From    OrderStatus
+	To      OrderStatus
+	Actor   string // Misal "customer:<user_id>", "admin:<user_id>" atau "system:payment-timeout"
+	Reason  string
+}
+
+func (t StatusTransition) Validate() error {
+	if !t.From.CanTransitionTo(t.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, t.From, t.To)
+	}
+	return nil
+}
+
+// OrderStatusHistory adalah satu baris riwayat perubahan status order.
+type OrderStatusHistory struct {
+	ID         string       `json:"id"`
+	OrderID    string       `json:"order_id"`
+	FromStatus *OrderStatus `json:"from_status"` // Nil untuk status awal saat order dibuat
+	ToStatus   OrderStatus  `json:"to_status"`
+	Actor      string       `json:"actor"`
+	Reason     string       `json:"reason,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
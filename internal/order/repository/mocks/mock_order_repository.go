@@ -13,10 +13,16 @@ type MockOrderRepository struct {
 	mock.Mock
 }
 
+func (m *MockOrderRepository) NextOrderID(ctx context.Context) (string, error) {
+	args := m.Called(ctx)
+	return args.String(0), args.Error(1)
+}
 func (m *MockOrderRepository) CreateOrderWithItems(ctx context.Context, order *domain.Order, items []domain.OrderItem) error {
 	args := m.Called(ctx, order, items)
 	if order != nil && args.Error(0) == nil {
-		order.ID = "mock-order-id"
+		if order.ID == "" {
+			order.ID = "mock-order-id"
+		}
 		order.Status = domain.StatusPendingPayment
 		// ...
 	}
@@ -29,10 +35,17 @@ func (m *MockOrderRepository) GetPendingOrdersOlderThan(ctx context.Context, dur
 	}
 	return nil, args.Error(1)
 }
-func (m *MockOrderRepository) UpdateOrderStatus(ctx context.Context, orderID string, newStatus domain.OrderStatus) error {
-	args := m.Called(ctx, orderID, newStatus)
+func (m *MockOrderRepository) TransitionOrderStatus(ctx context.Context, t domain.StatusTransition) error {
+	args := m.Called(ctx, t)
 	return args.Error(0)
 }
+func (m *MockOrderRepository) GetOrderStatusHistory(ctx context.Context, orderID string) ([]domain.OrderStatusHistory, error) {
+	args := m.Called(ctx, orderID)
+	if h := args.Get(0); h != nil {
+		return h.([]domain.OrderStatusHistory), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
 func (m *MockOrderRepository) GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]domain.OrderItem, error) {
 	args := m.Called(ctx, orderID)
 	if oi := args.Get(0); oi != nil {
@@ -55,3 +68,229 @@ func (m *MockOrderRepository) BeginTx(ctx context.Context) (repository.DBTX, err
 	}
 	return nil, args.Error(1)
 }
+
+func (m *MockOrderRepository) ListOrdersByUserID(ctx context.Context, filter domain.ListOrdersFilter) ([]domain.Order, error) {
+	args := m.Called(ctx, filter)
+	if o := args.Get(0); o != nil {
+		return o.([]domain.Order), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) GetOrderItemsByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]domain.OrderItem, error) {
+	args := m.Called(ctx, orderIDs)
+	if oi := args.Get(0); oi != nil {
+		return oi.(map[string][]domain.OrderItem), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) CancelOrder(ctx context.Context, t domain.StatusTransition, refundRequired bool, compensation *domain.Saga) error {
+	args := m.Called(ctx, t, refundRequired, compensation)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) RecordStockDeduction(ctx context.Context, deduction *domain.StockDeduction) error {
+	args := m.Called(ctx, deduction)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetStockDeductionsByOrderID(ctx context.Context, orderID string) ([]domain.StockDeduction, error) {
+	args := m.Called(ctx, orderID)
+	if d := args.Get(0); d != nil {
+		return d.([]domain.StockDeduction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) MarkStockDeductionRestocked(ctx context.Context, deductionID string) error {
+	args := m.Called(ctx, deductionID)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	args := m.Called(ctx, limit, lease)
+	if e := args.Get(0); e != nil {
+		return e.([]domain.OutboxEvent), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) MarkOutboxEventPublished(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+func (m *MockOrderRepository) MarkOutboxEventFailed(ctx context.Context, id string, errMsg string, retryAt time.Time, dead bool) error {
+	args := m.Called(ctx, id, errMsg, retryAt, dead)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CreateSaga(ctx context.Context, saga *domain.Saga) error {
+	args := m.Called(ctx, saga)
+	if saga != nil && args.Error(0) == nil {
+		if saga.ID == "" {
+			saga.ID = "mock-saga-id"
+		}
+		saga.Version = 1
+	}
+	return args.Error(0)
+}
+func (m *MockOrderRepository) UpdateSaga(ctx context.Context, saga *domain.Saga) error {
+	args := m.Called(ctx, saga)
+	if saga != nil && args.Error(0) == nil {
+		saga.Version++
+	}
+	return args.Error(0)
+}
+func (m *MockOrderRepository) GetSagaByID(ctx context.Context, id string) (*domain.Saga, error) {
+	args := m.Called(ctx, id)
+	if s := args.Get(0); s != nil {
+		return s.(*domain.Saga), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) GetSagaByOrderID(ctx context.Context, sagaType, orderID string) (*domain.Saga, error) {
+	args := m.Called(ctx, sagaType, orderID)
+	if s := args.Get(0); s != nil {
+		return s.(*domain.Saga), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) ListSagas(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error) {
+	args := m.Called(ctx, filter)
+	if s := args.Get(0); s != nil {
+		return s.([]domain.Saga), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) ClaimDueSagas(ctx context.Context, limit int, lease time.Duration) ([]domain.Saga, error) {
+	args := m.Called(ctx, limit, lease)
+	if s := args.Get(0); s != nil {
+		return s.([]domain.Saga), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) CreatePayment(ctx context.Context, payment *domain.Payment) error {
+	args := m.Called(ctx, payment)
+	if args.Error(0) == nil && payment.ID == "" {
+		payment.ID = "mock-payment-id"
+	}
+	return args.Error(0)
+}
+func (m *MockOrderRepository) GetPaymentsByOrderID(ctx context.Context, orderID string) ([]domain.Payment, error) {
+	args := m.Called(ctx, orderID)
+	if p := args.Get(0); p != nil {
+		return p.([]domain.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) GetPaymentByProviderID(ctx context.Context, provider, providerPaymentID string) (*domain.Payment, error) {
+	args := m.Called(ctx, provider, providerPaymentID)
+	if p := args.Get(0); p != nil {
+		return p.(*domain.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockOrderRepository) ApplyPaymentEvent(ctx context.Context, paymentID string, event domain.PaymentEvent, status domain.PaymentStatus) error {
+	args := m.Called(ctx, paymentID, event, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CreateReturnRequest(ctx context.Context, ret *domain.ReturnRequest) error {
+	args := m.Called(ctx, ret)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetReturnRequestByID(ctx context.Context, id string) (*domain.ReturnRequest, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.ReturnRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) ListReturnRequestsByOrderID(ctx context.Context, orderID string) ([]domain.ReturnRequest, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.ReturnRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) ListReturnRequests(ctx context.Context, filter domain.ListReturnsFilter) ([]domain.ReturnRequest, error) {
+	args := m.Called(ctx, filter)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.ReturnRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) TransitionReturnRequest(ctx context.Context, t domain.ReturnTransition) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CreateRefund(ctx context.Context, refund *domain.Refund) error {
+	args := m.Called(ctx, refund)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CompleteRefund(ctx context.Context, refundID string, status domain.RefundStatus, providerRefundID, failureReason string) error {
+	args := m.Called(ctx, refundID, status, providerRefundID, failureReason)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetRefundsByOrderID(ctx context.Context, orderID string) ([]domain.Refund, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.Refund), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) ClearRefundRequired(ctx context.Context, orderID string) error {
+	args := m.Called(ctx, orderID)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) CreateShipments(ctx context.Context, t domain.StatusTransition, shipments []domain.Shipment) error {
+	args := m.Called(ctx, t, shipments)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetShipmentByID(ctx context.Context, id string) (*domain.Shipment, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.Shipment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) GetFulfillmentGroupsByOrderID(ctx context.Context, orderID string) ([]domain.FulfillmentGroup, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.FulfillmentGroup), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) GetShipmentsByOrderID(ctx context.Context, orderID string) ([]domain.Shipment, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.Shipment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) ListShipments(ctx context.Context, filter domain.ListShipmentsFilter) ([]domain.Shipment, error) {
+	args := m.Called(ctx, filter)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.Shipment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOrderRepository) TransitionShipment(ctx context.Context, t domain.ShipmentTransition) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
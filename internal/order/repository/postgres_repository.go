@@ -3,15 +3,101 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
 	// Ganti dengan path yang benar
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderStatusConflict = errors.New("order status was changed by another process")
+	ErrSagaNotFound        = errors.New("saga not found")
+	ErrSagaConflict        = errors.New("saga was updated by another process")
+
+	ErrPaymentNotFound       = errors.New("payment not found")
+	ErrOpenPaymentExists     = errors.New("order already has a pending or succeeded payment")
+	ErrDuplicatePaymentEvent = errors.New("payment webhook event was already processed")
+	ErrPaymentStatusConflict = errors.New("payment status was changed by another process")
+
+	ErrReturnNotFound         = errors.New("return request not found")
+	ErrReturnStatusConflict   = errors.New("return request status was changed by another process")
+	ErrReturnQuantityExceeded = errors.New("return quantity exceeds the quantity not yet returned")
+	ErrRefundExceedsPayment   = errors.New("refund total would exceed the payment amount")
+	ErrRefundStatusConflict   = errors.New("refund is no longer pending")
+
+	ErrShipmentNotFound       = errors.New("shipment not found")
+	ErrShipmentStatusConflict = errors.New("shipment status was changed by another process")
 )
 
-var ErrOrderNotFound = errors.New("order not found")
+// orderColumns dan scanOrder dipakai bersama oleh semua query yang membaca tabel orders.
+const orderColumns = `id, user_id, total_amount, currency, status, cancellation_reason, cancelled_at, refund_required, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row rowScanner, o *domain.Order) error {
+	var cancellationReason sql.NullString
+	var cancelledAt sql.NullTime
+	var totalAmount money.Decimal
+	var currency string
+	err := row.Scan(&o.ID, &o.UserID, &totalAmount, &currency, &o.Status, &cancellationReason, &cancelledAt, &o.RefundRequired, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if o.TotalAmount, err = totalAmount.Money(money.Currency(currency)); err != nil {
+		return err
+	}
+	if cancellationReason.Valid {
+		o.CancellationReason = &cancellationReason.String
+	}
+	if cancelledAt.Valid {
+		o.CancelledAt = &cancelledAt.Time
+	}
+	return nil
+}
+
+// execer dipenuhi oleh *sql.DB maupun *sql.Tx
+type execer interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
+
+func actorOrSystem(actor string) string {
+	if actor == "" {
+		return domain.ActorSystem
+	}
+	return actor
+}
+
+func insertStatusHistory(ctx context.Context, q execer, orderID string, from *domain.OrderStatus, to domain.OrderStatus, actor, reason string) error {
+	actor = actorOrSystem(actor)
+	query := `INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason)
+              VALUES ($1, $2, $3, $4, NULLIF($5, ''))`
+	_, err := q.ExecContext(ctx, query, orderID, from, to, actor, reason)
+	return err
+}
+
+// insertOutboxEvent menulis event ke tabel outbox. Dipanggil di dalam transaksi yang sama dengan perubahan order,
+// sehingga event tercatat jika dan hanya jika perubahannya tersimpan.
+func insertOutboxEvent(ctx context.Context, q execer, eventType string, event domain.OrderEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	query := `INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`
+	_, err = q.ExecContext(ctx, query, event.OrderID, eventType, payload)
+	return err
+}
 
 // DBTX interface untuk transaksi (bisa sama dengan yg di warehouse repo)
 type DBTX interface {
@@ -24,14 +110,93 @@ type DBTX interface {
 }
 
 type OrderRepository interface {
+	// NextOrderID membuat ID order baru sebelum order disimpan, agar reservasi stok bisa dikaitkan ke order sejak awal.
+	NextOrderID(ctx context.Context) (string, error)
+	// CreateOrderWithItems juga menyimpan order.FulfillmentGroups (jika ada) dalam transaksi yang sama.
 	CreateOrderWithItems(ctx context.Context, order *domain.Order, items []domain.OrderItem) error
-	// GetOrderByID, ListOrdersByUserID akan ditambahkan nanti
+	GetFulfillmentGroupsByOrderID(ctx context.Context, orderID string) ([]domain.FulfillmentGroup, error)
 	BeginTx(ctx context.Context) (DBTX, error)
 
 	GetPendingOrdersOlderThan(ctx context.Context, duration time.Duration) ([]domain.Order, error)
-	UpdateOrderStatus(ctx context.Context, orderID string, newStatus domain.OrderStatus) error
+	// TransitionOrderStatus mengubah status secara compare-and-set (WHERE status = t.From) dan mencatatnya di order_status_history.
+	// Mengembalikan ErrOrderStatusConflict jika status order sudah diubah proses lain.
+	TransitionOrderStatus(ctx context.Context, t domain.StatusTransition) error
+	GetOrderStatusHistory(ctx context.Context, orderID string) ([]domain.OrderStatusHistory, error)
 	GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]domain.OrderItem, error)
 	GetOrderByID(ctx context.Context, orderID string) (*domain.Order, error)
+	ListOrdersByUserID(ctx context.Context, filter domain.ListOrdersFilter) ([]domain.Order, error)
+	// GetOrderItemsByOrderIDs mengambil item untuk banyak order sekaligus (hindari N+1), dikelompokkan per order ID.
+	GetOrderItemsByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]domain.OrderItem, error)
+
+	// CancelOrder adalah TransitionOrderStatus ke CANCELLED yang sekaligus menyimpan alasan dan flag refund.
+	// compensation (boleh nil) adalah saga yang disimpan dalam transaksi yang sama, misal restock stok order yang sudah dibayar,
+	// sehingga kompensasinya tidak hilang jika proses mati tepat setelah order dibatalkan.
+	CancelOrder(ctx context.Context, t domain.StatusTransition, refundRequired bool, compensation *domain.Saga) error
+	RecordStockDeduction(ctx context.Context, deduction *domain.StockDeduction) error
+	GetStockDeductionsByOrderID(ctx context.Context, orderID string) ([]domain.StockDeduction, error)
+	// MarkStockDeductionRestocked menandai pengurangan stok sudah dikembalikan ke gudang. Aman dipanggil ulang.
+	MarkStockDeductionRestocked(ctx context.Context, deductionID string) error
+
+	// ClaimOutboxEvents mengambil event PENDING yang sudah jatuh tempo dan menundanya selama lease,
+	// sehingga relay lain tidak mengambil event yang sama selama sedang dikirim.
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error)
+	MarkOutboxEventPublished(ctx context.Context, id string) error
+	// MarkOutboxEventFailed mencatat kegagalan pengiriman; event dijadwalkan ulang pada retryAt, atau menjadi DEAD jika dead bernilai true.
+	MarkOutboxEventFailed(ctx context.Context, id string, errMsg string, retryAt time.Time, dead bool) error
+
+	// CreateSaga menyimpan saga baru dan mengisi ID, Version, CreatedAt dan UpdatedAt.
+	CreateSaga(ctx context.Context, saga *domain.Saga) error
+	// UpdateSaga menyimpan status saga secara compare-and-set (WHERE version = saga.Version) lalu menaikkan saga.Version.
+	// Mengembalikan ErrSagaConflict jika saga sudah diubah proses lain.
+	UpdateSaga(ctx context.Context, saga *domain.Saga) error
+	GetSagaByID(ctx context.Context, id string) (*domain.Saga, error)
+	GetSagaByOrderID(ctx context.Context, sagaType, orderID string) (*domain.Saga, error)
+	ListSagas(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error)
+	// ClaimDueSagas mengambil saga RUNNING/COMPENSATING/WAITING yang sudah jatuh tempo dan menundanya selama lease,
+	// sehingga instance lain tidak menjalankan saga yang sama.
+	ClaimDueSagas(ctx context.Context, limit int, lease time.Duration) ([]domain.Saga, error)
+
+	// CreatePayment menyimpan payment baru dan mengisi ID, CreatedAt dan UpdatedAt.
+	// Mengembalikan ErrOpenPaymentExists jika order sudah punya payment PENDING atau SUCCEEDED.
+	CreatePayment(ctx context.Context, payment *domain.Payment) error
+	GetPaymentsByOrderID(ctx context.Context, orderID string) ([]domain.Payment, error)
+	GetPaymentByProviderID(ctx context.Context, provider, providerPaymentID string) (*domain.Payment, error)
+	// ApplyPaymentEvent mencatat event webhook dan mengubah status payment dari PENDING ke status dalam satu transaksi.
+	// Mengembalikan ErrDuplicatePaymentEvent jika event yang sama sudah pernah dicatat,
+	// atau ErrPaymentStatusConflict jika payment sudah tidak PENDING (event tidak dicatat).
+	ApplyPaymentEvent(ctx context.Context, paymentID string, event domain.PaymentEvent, status domain.PaymentStatus) error
+
+	// CreateReturnRequest menyimpan retur beserta itemnya dan mengisi ID serta timestamp.
+	// Order dikunci selama transaksi dan quantity setiap item dicek terhadap sisa yang belum diretur
+	// (retur REJECTED tidak dihitung); mengembalikan ErrReturnQuantityExceeded jika melebihi.
+	CreateReturnRequest(ctx context.Context, ret *domain.ReturnRequest) error
+	GetReturnRequestByID(ctx context.Context, id string) (*domain.ReturnRequest, error)
+	ListReturnRequestsByOrderID(ctx context.Context, orderID string) ([]domain.ReturnRequest, error)
+	ListReturnRequests(ctx context.Context, filter domain.ListReturnsFilter) ([]domain.ReturnRequest, error)
+	// TransitionReturnRequest mengubah status retur secara compare-and-set (WHERE status = t.From).
+	// Mengembalikan ErrReturnStatusConflict jika status retur sudah diubah proses lain.
+	TransitionReturnRequest(ctx context.Context, t domain.ReturnTransition) error
+
+	// CreateRefund menyimpan refund PENDING. Payment dikunci selama transaksi dan refund ditolak dengan
+	// ErrRefundExceedsPayment jika total refund PENDING dan SUCCEEDED melebihi nominal payment.
+	CreateRefund(ctx context.Context, refund *domain.Refund) error
+	// CompleteRefund mengubah refund PENDING ke status akhir; ErrRefundStatusConflict jika sudah tidak PENDING.
+	CompleteRefund(ctx context.Context, refundID string, status domain.RefundStatus, providerRefundID, failureReason string) error
+	GetRefundsByOrderID(ctx context.Context, orderID string) ([]domain.Refund, error)
+	// ClearRefundRequired menandai dana order yang dibatalkan setelah pembayaran sudah dikembalikan seluruhnya.
+	ClearRefundRequired(ctx context.Context, orderID string) error
+
+	// CreateShipments menyimpan shipment beserta isinya dan menjalankan t (misal PAYMENT_CONFIRMED -> AWAITING_SHIPMENT)
+	// dalam satu transaksi. Mengembalikan ErrOrderStatusConflict (tanpa menyimpan shipment) jika status order sudah berubah.
+	CreateShipments(ctx context.Context, t domain.StatusTransition, shipments []domain.Shipment) error
+	GetShipmentByID(ctx context.Context, id string) (*domain.Shipment, error)
+	GetShipmentsByOrderID(ctx context.Context, orderID string) ([]domain.Shipment, error)
+	ListShipments(ctx context.Context, filter domain.ListShipmentsFilter) ([]domain.Shipment, error)
+	// TransitionShipment mengubah status shipment secara compare-and-set lalu menurunkan status order dari semua
+	// shipment-nya dalam transaksi yang sama. Order dikunci selama transaksi; shipment hanya bisa dikirim selama order
+	// AWAITING_SHIPMENT atau PARTIALLY_SHIPPED (ErrOrderStatusConflict), sehingga tidak balapan dengan pembatalan.
+	// Mengembalikan ErrShipmentStatusConflict jika status shipment sudah diubah proses lain.
+	TransitionShipment(ctx context.Context, t domain.ShipmentTransition) error
 }
 
 type postgresOrderRepository struct {
@@ -46,7 +211,17 @@ func (r *postgresOrderRepository) BeginTx(ctx context.Context) (DBTX, error) {
 	return r.db.BeginTx(ctx, nil)
 }
 
+func (r *postgresOrderRepository) NextOrderID(ctx context.Context) (string, error) {
+	var id string
+	if err := r.db.QueryRowContext(ctx, `SELECT gen_random_uuid()`).Scan(&id); err != nil {
+		logger.Error("NextOrderID: query failed", err, nil)
+		return "", err
+	}
+	return id, nil
+}
+
 // CreateOrderWithItems menyimpan order dan item-itemnya dalam satu transaksi.
+// Jika order.ID sudah diisi (lihat NextOrderID), ID tersebut yang dipakai.
 func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, order *domain.Order, items []domain.OrderItem) error {
 	tx, err := r.db.Begin() // Tidak menggunakan DBTX di sini karena manage transaksi internal
 	if err != nil {
@@ -56,8 +231,9 @@ func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, orde
 	defer tx.Rollback() // Rollback jika tidak di-commit
 
 	// 1. Simpan Order
-	orderQuery := `INSERT INTO orders (user_id, total_amount, status, created_at, updated_at)
-                   VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at, status`
+	orderQuery := `INSERT INTO orders (id, user_id, total_amount, currency, status, created_at, updated_at)
+                   VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7)
+                   RETURNING id, created_at, updated_at, status`
 
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
@@ -65,7 +241,7 @@ func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, orde
 		order.Status = domain.StatusPendingPayment // Default status
 	}
 
-	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.TotalAmount, order.Status, order.CreatedAt, order.UpdatedAt).
+	err = tx.QueryRowContext(ctx, orderQuery, order.ID, order.UserID, order.TotalAmount, order.TotalAmount.Currency(), order.Status, order.CreatedAt, order.UpdatedAt).
 		Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt, &order.Status)
 	if err != nil {
 		logger.Error("CreateOrderWithItems: failed to insert order", err, nil)
@@ -73,8 +249,8 @@ func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, orde
 	}
 
 	// 2. Simpan Order Items
-	itemStmt, err := tx.PrepareContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity, price_at_purchase, created_at)
-                                            VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`)
+	itemStmt, err := tx.PrepareContext(ctx, `INSERT INTO order_items (order_id, product_id, product_name, sku, quantity, price_at_purchase, currency, created_at)
+                                            VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`)
 	if err != nil {
 		logger.Error("CreateOrderWithItems: failed to prepare item statement", err, nil)
 		return err
@@ -84,7 +260,7 @@ func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, orde
 	for i := range items {
 		items[i].OrderID = order.ID
 		items[i].CreatedAt = time.Now() // Atau gunakan waktu order jika sama
-		err = itemStmt.QueryRowContext(ctx, items[i].OrderID, items[i].ProductID, items[i].Quantity, items[i].PriceAtPurchase, items[i].CreatedAt).
+		err = itemStmt.QueryRowContext(ctx, items[i].OrderID, items[i].ProductID, items[i].ProductName, items[i].SKU, items[i].Quantity, items[i].PriceAtPurchase, items[i].PriceAtPurchase.Currency(), items[i].CreatedAt).
 			Scan(&items[i].ID, &items[i].CreatedAt)
 		if err != nil {
 			logger.Error("CreateOrderWithItems: failed to insert order item", err, map[string]interface{}{"item_product_id": items[i].ProductID})
@@ -93,11 +269,95 @@ func (r *postgresOrderRepository) CreateOrderWithItems(ctx context.Context, orde
 	}
 	order.Items = items // Assign items to order struct
 
+	// 3. Simpan fulfillment group (pembagian item per gudang dari reservasi stok)
+	for i := range order.FulfillmentGroups {
+		if err := insertFulfillmentGroup(ctx, tx, order.ID, &order.FulfillmentGroups[i]); err != nil {
+			logger.Error("CreateOrderWithItems: failed to insert fulfillment group", err, map[string]interface{}{"warehouse_id": order.FulfillmentGroups[i].WarehouseID})
+			return err
+		}
+	}
+
+	// 4. Catat status awal di riwayat
+	if err := insertStatusHistory(ctx, tx, order.ID, nil, order.Status, order.CreatedBy, ""); err != nil {
+		logger.Error("CreateOrderWithItems: failed to insert status history", err, nil)
+		return err
+	}
+
+	// 5. Catat event order.created di outbox
+	err = insertOutboxEvent(ctx, tx, domain.EventOrderCreated, domain.OrderEvent{
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Status:      order.Status,
+		TotalAmount: order.TotalAmount,
+		Actor:       actorOrSystem(order.CreatedBy),
+		Items:       items,
+		OccurredAt:  order.CreatedAt,
+	})
+	if err != nil {
+		logger.Error("CreateOrderWithItems: failed to insert outbox event", err, nil)
+		return err
+	}
+
 	return tx.Commit()
 }
 
+func insertFulfillmentGroup(ctx context.Context, tx *sql.Tx, orderID string, group *domain.FulfillmentGroup) error {
+	group.OrderID = orderID
+	err := tx.QueryRowContext(ctx, `INSERT INTO order_fulfillment_groups (order_id, warehouse_id, sequence)
+              VALUES ($1, $2, $3) RETURNING id`, group.OrderID, group.WarehouseID, group.Sequence).Scan(&group.ID)
+	if err != nil {
+		return err
+	}
+	for i := range group.Items {
+		item := &group.Items[i]
+		item.GroupID = group.ID
+		err := tx.QueryRowContext(ctx, `INSERT INTO order_fulfillment_group_items (group_id, product_id, product_name, sku, quantity)
+                  VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			item.GroupID, item.ProductID, item.ProductName, item.SKU, item.Quantity).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFulfillmentGroupsByOrderID mengembalikan fulfillment group order beserta isinya, berurutan sesuai sequence.
+// Order yang dibuat sebelum fulfillment group dicatat mengembalikan slice kosong.
+func (r *postgresOrderRepository) GetFulfillmentGroupsByOrderID(ctx context.Context, orderID string) ([]domain.FulfillmentGroup, error) {
+	query := `SELECT g.id, g.warehouse_id, g.sequence, i.id, i.product_id, i.product_name, i.sku, i.quantity
+              FROM order_fulfillment_groups g
+              JOIN order_fulfillment_group_items i ON i.group_id = g.id
+              WHERE g.order_id = $1
+              ORDER BY g.sequence, i.id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		logger.Error("GetFulfillmentGroupsByOrderID: query failed", err, map[string]interface{}{"order_id": orderID})
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []domain.FulfillmentGroup{}
+	for rows.Next() {
+		var group domain.FulfillmentGroup
+		var item domain.FulfillmentGroupItem
+		if err := rows.Scan(&group.ID, &group.WarehouseID, &group.Sequence,
+			&item.ID, &item.ProductID, &item.ProductName, &item.SKU, &item.Quantity); err != nil {
+			logger.Error("GetFulfillmentGroupsByOrderID: scan failed", err, nil)
+			return nil, err
+		}
+		if n := len(groups); n == 0 || groups[n-1].ID != group.ID {
+			group.OrderID = orderID
+			groups = append(groups, group)
+		}
+		item.GroupID = group.ID
+		last := &groups[len(groups)-1]
+		last.Items = append(last.Items, item)
+	}
+	return groups, rows.Err()
+}
+
 func (r *postgresOrderRepository) GetPendingOrdersOlderThan(ctx context.Context, duration time.Duration) ([]domain.Order, error) {
-	query := `SELECT id, user_id, total_amount, status, created_at, updated_at
+	query := `SELECT ` + orderColumns + `
               FROM orders
               WHERE status = $1 AND created_at < $2
               ORDER BY created_at ASC`
@@ -113,7 +373,7 @@ func (r *postgresOrderRepository) GetPendingOrdersOlderThan(ctx context.Context,
 	var orders []domain.Order
 	for rows.Next() {
 		var o domain.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.TotalAmount, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			logger.Error("GetPendingOrdersOlderThan: scan failed", err, nil)
 			// Lanjutkan proses order lain jika satu gagal di-scan
 			continue
@@ -123,22 +383,118 @@ func (r *postgresOrderRepository) GetPendingOrdersOlderThan(ctx context.Context,
 	return orders, rows.Err()
 }
 
-func (r *postgresOrderRepository) UpdateOrderStatus(ctx context.Context, orderID string, newStatus domain.OrderStatus) error {
-	query := `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`
-	res, err := r.db.ExecContext(ctx, query, newStatus, orderID)
+func (r *postgresOrderRepository) TransitionOrderStatus(ctx context.Context, t domain.StatusTransition) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	return r.applyTransition(ctx, t, statusUpdateQuery, t.To, t.OrderID, t.From)
+}
+
+// transitionReturning adalah kolom yang dikembalikan UPDATE status untuk mengisi payload event.
+const transitionReturning = `user_id, total_amount, currency, refund_required, updated_at`
+
+// applyTransition menjalankan UPDATE bersyarat status, insert riwayat dan event outbox dalam satu transaksi.
+// updateQuery wajib memfilter "status = <from>" agar perubahan yang kalah balapan terdeteksi (0 baris),
+// dan diakhiri "RETURNING " + transitionReturning.
+func (r *postgresOrderRepository) applyTransition(ctx context.Context, t domain.StatusTransition, updateQuery string, args ...interface{}) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("UpdateOrderStatus: exec failed", err, map[string]interface{}{"order_id": orderID, "new_status": newStatus})
+		logger.Error("applyTransition: failed to begin tx", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrOrderNotFound
+	defer tx.Rollback()
+
+	if err := r.transitionInTx(ctx, tx, t, updateQuery, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// transitionInTx adalah isi applyTransition untuk transaksi milik pemanggil,
+// sehingga perubahan status bisa disimpan bersama perubahan data lain (misal shipment).
+func (r *postgresOrderRepository) transitionInTx(ctx context.Context, tx *sql.Tx, t domain.StatusTransition, updateQuery string, args ...interface{}) error {
+	event := domain.OrderEvent{OrderID: t.OrderID, Status: t.To, Actor: actorOrSystem(t.Actor), Reason: t.Reason}
+	var totalAmount money.Decimal
+	var currency string
+	err := tx.QueryRowContext(ctx, updateQuery, args...).Scan(&event.UserID, &totalAmount, &currency, &event.RefundRequired, &event.OccurredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Bedakan order yang tidak ada dengan order yang statusnya sudah berubah
+		if _, err := r.GetOrderByID(ctx, t.OrderID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: expected %s", ErrOrderStatusConflict, t.From)
+	}
+	if err != nil {
+		logger.Error("applyTransition: update failed", err, map[string]interface{}{"order_id": t.OrderID, "from": t.From, "to": t.To})
+		return err
+	}
+	if event.TotalAmount, err = totalAmount.Money(money.Currency(currency)); err != nil {
+		return err
+	}
+
+	from := t.From
+	if err := insertStatusHistory(ctx, tx, t.OrderID, &from, t.To, t.Actor, t.Reason); err != nil {
+		logger.Error("applyTransition: failed to insert status history", err, map[string]interface{}{"order_id": t.OrderID})
+		return err
+	}
+
+	if eventType, ok := domain.EventTypeForStatus(t.To); ok {
+		event.PreviousStatus = &from
+		if err := insertOutboxEvent(ctx, tx, eventType, event); err != nil {
+			logger.Error("applyTransition: failed to insert outbox event", err, map[string]interface{}{"order_id": t.OrderID})
+			return err
+		}
 	}
 	return nil
 }
 
+// statusUpdateQuery adalah UPDATE compare-and-set untuk TransitionOrderStatus: $1 status baru, $2 order ID, $3 status lama.
+const statusUpdateQuery = `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3
+              RETURNING ` + transitionReturning
+
+func (r *postgresOrderRepository) GetOrderStatusHistory(ctx context.Context, orderID string) ([]domain.OrderStatusHistory, error) {
+	query := `SELECT id, order_id, from_status, to_status, actor, COALESCE(reason, ''), created_at
+              FROM order_status_history WHERE order_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		logger.Error("GetOrderStatusHistory: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []domain.OrderStatusHistory{}
+	for rows.Next() {
+		var h domain.OrderStatusHistory
+		var fromStatus sql.NullString
+		if err := rows.Scan(&h.ID, &h.OrderID, &fromStatus, &h.ToStatus, &h.Actor, &h.Reason, &h.CreatedAt); err != nil {
+			logger.Error("GetOrderStatusHistory: scan failed", err, nil)
+			return nil, err
+		}
+		if fromStatus.Valid {
+			from := domain.OrderStatus(fromStatus.String)
+			h.FromStatus = &from
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// orderItemColumns dan scanOrderItem dipakai bersama oleh query yang membaca tabel order_items.
+const orderItemColumns = `id, order_id, product_id, product_name, sku, quantity, price_at_purchase, currency, created_at`
+
+func scanOrderItem(row rowScanner, i *domain.OrderItem) error {
+	var price money.Decimal
+	var currency string
+	if err := row.Scan(&i.ID, &i.OrderID, &i.ProductID, &i.ProductName, &i.SKU, &i.Quantity, &price, &currency, &i.CreatedAt); err != nil {
+		return err
+	}
+	var err error
+	i.PriceAtPurchase, err = price.Money(money.Currency(currency))
+	return err
+}
+
 func (r *postgresOrderRepository) GetOrderItemsByOrderID(ctx context.Context, orderID string) ([]domain.OrderItem, error) {
-	query := `SELECT id, order_id, product_id, quantity, price_at_purchase, created_at
+	query := `SELECT ` + orderItemColumns + `
               FROM order_items WHERE order_id = $1`
 	rows, err := r.db.QueryContext(ctx, query, orderID)
 	if err != nil {
@@ -150,7 +506,7 @@ func (r *postgresOrderRepository) GetOrderItemsByOrderID(ctx context.Context, or
 	var items []domain.OrderItem
 	for rows.Next() {
 		var i domain.OrderItem
-		if err := rows.Scan(&i.ID, &i.OrderID, &i.ProductID, &i.Quantity, &i.PriceAtPurchase, &i.CreatedAt); err != nil {
+		if err := scanOrderItem(rows, &i); err != nil {
 			logger.Error("GetOrderItemsByOrderID: scan failed", err, nil)
 			return nil, err
 		}
@@ -160,11 +516,9 @@ func (r *postgresOrderRepository) GetOrderItemsByOrderID(ctx context.Context, or
 }
 
 func (r *postgresOrderRepository) GetOrderByID(ctx context.Context, orderID string) (*domain.Order, error) {
-	query := `SELECT id, user_id, total_amount, status, created_at, updated_at FROM orders WHERE id = $1`
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`
 	var o domain.Order
-	err := r.db.QueryRowContext(ctx, query, orderID).Scan(
-		&o.ID, &o.UserID, &o.TotalAmount, &o.Status, &o.CreatedAt, &o.UpdatedAt,
-	)
+	err := scanOrder(r.db.QueryRowContext(ctx, query, orderID), &o)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrOrderNotFound
@@ -175,3 +529,1051 @@ func (r *postgresOrderRepository) GetOrderByID(ctx context.Context, orderID stri
 	// Dapatkan items jika perlu, atau biarkan service layer yang memanggil GetOrderItemsByOrderID
 	return &o, nil
 }
+
+// ListOrdersByUserID menggunakan keyset pagination (created_at DESC, id DESC).
+// filter.Limit dipakai apa adanya; service layer yang menentukan batas dan mengambil +1 untuk mendeteksi halaman berikutnya.
+func (r *postgresOrderRepository) ListOrdersByUserID(ctx context.Context, filter domain.ListOrdersFilter) ([]domain.Order, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	query := `SELECT ` + orderColumns + `
+              FROM orders
+              WHERE ` + strings.Join(conditions, " AND ") + `
+              ORDER BY created_at DESC, id DESC
+              LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("ListOrdersByUserID: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
+	for rows.Next() {
+		var o domain.Order
+		if err := scanOrder(rows, &o); err != nil {
+			logger.Error("ListOrdersByUserID: scan failed", err, nil)
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *postgresOrderRepository) GetOrderItemsByOrderIDs(ctx context.Context, orderIDs []string) (map[string][]domain.OrderItem, error) {
+	itemsByOrder := make(map[string][]domain.OrderItem, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return itemsByOrder, nil
+	}
+
+	query := `SELECT ` + orderItemColumns + `
+              FROM order_items WHERE order_id = ANY($1)
+              ORDER BY order_id, created_at`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(orderIDs))
+	if err != nil {
+		logger.Error("GetOrderItemsByOrderIDs: query failed", err, map[string]interface{}{"order_ids_count": len(orderIDs)})
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i domain.OrderItem
+		if err := scanOrderItem(rows, &i); err != nil {
+			logger.Error("GetOrderItemsByOrderIDs: scan failed", err, nil)
+			return nil, err
+		}
+		itemsByOrder[i.OrderID] = append(itemsByOrder[i.OrderID], i)
+	}
+	return itemsByOrder, rows.Err()
+}
+
+func (r *postgresOrderRepository) CancelOrder(ctx context.Context, t domain.StatusTransition, refundRequired bool, compensation *domain.Saga) error {
+	t.To = domain.StatusCancelled
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	query := `UPDATE orders
+              SET status = $1, cancellation_reason = NULLIF($2, ''), cancelled_at = NOW(), refund_required = $3, updated_at = NOW()
+              WHERE id = $4 AND status = $5
+              RETURNING ` + transitionReturning
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CancelOrder: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.transitionInTx(ctx, tx, t, query, t.To, t.Reason, refundRequired, t.OrderID, t.From); err != nil {
+		return err
+	}
+	// Shipment yang belum dikirim tidak perlu dikemas lagi
+	_, err = tx.ExecContext(ctx, `UPDATE shipments SET status = $1, updated_at = NOW() WHERE order_id = $2 AND status = $3`,
+		domain.ShipmentStatusCancelled, t.OrderID, domain.ShipmentStatusPending)
+	if err != nil {
+		logger.Error("CancelOrder: failed to cancel pending shipments", err, map[string]interface{}{"order_id": t.OrderID})
+		return err
+	}
+	if compensation != nil {
+		if err := insertSaga(ctx, tx, compensation); err != nil {
+			logger.Error("CancelOrder: failed to create compensation saga", err, map[string]interface{}{"order_id": t.OrderID})
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresOrderRepository) RecordStockDeduction(ctx context.Context, deduction *domain.StockDeduction) error {
+	query := `INSERT INTO order_stock_deductions (order_id, product_id, warehouse_id, quantity)
+              VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, deduction.OrderID, deduction.ProductID, deduction.WarehouseID, deduction.Quantity).
+		Scan(&deduction.ID, &deduction.CreatedAt)
+	if err != nil {
+		logger.Error("RecordStockDeduction: insert failed", err, map[string]interface{}{"order_id": deduction.OrderID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresOrderRepository) GetStockDeductionsByOrderID(ctx context.Context, orderID string) ([]domain.StockDeduction, error) {
+	query := `SELECT id, order_id, product_id, warehouse_id, quantity, restocked_at, created_at
+              FROM order_stock_deductions WHERE order_id = $1 ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		logger.Error("GetStockDeductionsByOrderID: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deductions := []domain.StockDeduction{}
+	for rows.Next() {
+		var d domain.StockDeduction
+		var restockedAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.OrderID, &d.ProductID, &d.WarehouseID, &d.Quantity, &restockedAt, &d.CreatedAt); err != nil {
+			logger.Error("GetStockDeductionsByOrderID: scan failed", err, nil)
+			return nil, err
+		}
+		if restockedAt.Valid {
+			d.RestockedAt = &restockedAt.Time
+		}
+		deductions = append(deductions, d)
+	}
+	return deductions, rows.Err()
+}
+
+func (r *postgresOrderRepository) MarkStockDeductionRestocked(ctx context.Context, deductionID string) error {
+	query := `UPDATE order_stock_deductions SET restocked_at = NOW() WHERE id = $1 AND restocked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, deductionID); err != nil {
+		logger.Error("MarkStockDeductionRestocked: update failed", err, map[string]interface{}{"deduction_id": deductionID})
+		return err
+	}
+	return nil
+}
+
+const outboxColumns = `id, aggregate_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, published_at`
+
+func (r *postgresOrderRepository) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	// SKIP LOCKED agar beberapa relay bisa berjalan bersamaan tanpa mengambil event yang sama
+	query := `UPDATE outbox SET next_attempt_at = NOW() + make_interval(secs => $2)
+              WHERE id IN (
+                  SELECT id FROM outbox
+                  WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+                  ORDER BY created_at
+                  LIMIT $1
+                  FOR UPDATE SKIP LOCKED
+              )
+              RETURNING ` + outboxColumns
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		logger.Error("ClaimOutboxEvents: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domain.OutboxEvent{}
+	for rows.Next() {
+		var e domain.OutboxEvent
+		var lastError sql.NullString
+		var publishedAt sql.NullTime
+		var payload []byte // Scan lewat []byte karena driver bisa mengembalikan jsonb sebagai string
+		if err := rows.Scan(&e.ID, &e.AggregateID, &e.EventType, &payload, &e.Status, &e.Attempts,
+			&e.NextAttemptAt, &lastError, &e.CreatedAt, &publishedAt); err != nil {
+			logger.Error("ClaimOutboxEvents: scan failed", err, nil)
+			return nil, err
+		}
+		e.Payload = payload
+		if lastError.Valid {
+			e.LastError = &lastError.String
+		}
+		if publishedAt.Valid {
+			e.PublishedAt = &publishedAt.Time
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING tidak menjamin urutan; kirim sesuai urutan event dibuat
+	sort.Slice(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+func (r *postgresOrderRepository) MarkOutboxEventPublished(ctx context.Context, id string) error {
+	query := `UPDATE outbox SET status = 'PUBLISHED', attempts = attempts + 1, published_at = NOW(), last_error = NULL WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		logger.Error("MarkOutboxEventPublished: update failed", err, map[string]interface{}{"event_id": id})
+	}
+	return err
+}
+
+func (r *postgresOrderRepository) MarkOutboxEventFailed(ctx context.Context, id string, errMsg string, retryAt time.Time, dead bool) error {
+	status := domain.OutboxStatusPending
+	if dead {
+		status = domain.OutboxStatusDead
+	}
+	query := `UPDATE outbox SET status = $1, attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $4`
+	_, err := r.db.ExecContext(ctx, query, status, errMsg, retryAt, id)
+	if err != nil {
+		logger.Error("MarkOutboxEventFailed: update failed", err, map[string]interface{}{"event_id": id})
+	}
+	return err
+}
+
+const sagaColumns = `id, saga_type, order_id, status, current_step, steps, data, next_attempt_at, last_error, version, created_at, updated_at`
+
+func scanSaga(row rowScanner, s *domain.Saga) error {
+	var steps, data []byte
+	var lastError sql.NullString
+	err := row.Scan(&s.ID, &s.Type, &s.OrderID, &s.Status, &s.CurrentStep, &steps, &data,
+		&s.NextAttemptAt, &lastError, &s.Version, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(steps, &s.Steps); err != nil {
+		return fmt.Errorf("failed to decode steps of saga %s: %w", s.ID, err)
+	}
+	s.Data = data
+	if lastError.Valid {
+		s.LastError = &lastError.String
+	}
+	return nil
+}
+
+func querySagas(ctx context.Context, db *sql.DB, op, query string, args ...interface{}) ([]domain.Saga, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error(op+": query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sagas := []domain.Saga{}
+	for rows.Next() {
+		var s domain.Saga
+		if err := scanSaga(rows, &s); err != nil {
+			logger.Error(op+": scan failed", err, nil)
+			return nil, err
+		}
+		sagas = append(sagas, s)
+	}
+	return sagas, rows.Err()
+}
+
+func sagaData(s *domain.Saga) []byte {
+	if len(s.Data) == 0 {
+		return []byte("{}")
+	}
+	return s.Data
+}
+
+func (r *postgresOrderRepository) CreateSaga(ctx context.Context, saga *domain.Saga) error {
+	err := insertSaga(ctx, r.db, saga)
+	if err != nil {
+		logger.Error("CreateSaga: insert failed", err, map[string]interface{}{"order_id": saga.OrderID})
+	}
+	return err
+}
+
+// sagaInserter dipenuhi oleh *sql.DB maupun *sql.Tx
+type sagaInserter interface {
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// insertSaga menyimpan saga baru dan mengisi ID, version, dan timestamp-nya.
+func insertSaga(ctx context.Context, q sagaInserter, saga *domain.Saga) error {
+	steps, err := json.Marshal(saga.Steps)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga steps: %w", err)
+	}
+	query := `INSERT INTO sagas (saga_type, order_id, status, current_step, steps, data, next_attempt_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)
+              RETURNING id, version, created_at, updated_at`
+	return q.QueryRowContext(ctx, query, saga.Type, saga.OrderID, saga.Status, saga.CurrentStep, steps, sagaData(saga), saga.NextAttemptAt).
+		Scan(&saga.ID, &saga.Version, &saga.CreatedAt, &saga.UpdatedAt)
+}
+
+func (r *postgresOrderRepository) UpdateSaga(ctx context.Context, saga *domain.Saga) error {
+	steps, err := json.Marshal(saga.Steps)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga steps: %w", err)
+	}
+	query := `UPDATE sagas
+              SET status = $1, current_step = $2, steps = $3, data = $4, next_attempt_at = $5, last_error = $6,
+                  version = version + 1, updated_at = NOW()
+              WHERE id = $7 AND version = $8
+              RETURNING version, updated_at`
+	err = r.db.QueryRowContext(ctx, query, saga.Status, saga.CurrentStep, steps, sagaData(saga), saga.NextAttemptAt, saga.LastError,
+		saga.ID, saga.Version).Scan(&saga.Version, &saga.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSagaConflict
+	}
+	if err != nil {
+		logger.Error("UpdateSaga: update failed", err, map[string]interface{}{"saga_id": saga.ID})
+	}
+	return err
+}
+
+func (r *postgresOrderRepository) GetSagaByID(ctx context.Context, id string) (*domain.Saga, error) {
+	var s domain.Saga
+	err := scanSaga(r.db.QueryRowContext(ctx, `SELECT `+sagaColumns+` FROM sagas WHERE id = $1`, id), &s)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		logger.Error("GetSagaByID: query failed", err, map[string]interface{}{"saga_id": id})
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *postgresOrderRepository) GetSagaByOrderID(ctx context.Context, sagaType, orderID string) (*domain.Saga, error) {
+	var s domain.Saga
+	query := `SELECT ` + sagaColumns + ` FROM sagas WHERE saga_type = $1 AND order_id = $2`
+	err := scanSaga(r.db.QueryRowContext(ctx, query, sagaType, orderID), &s)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		logger.Error("GetSagaByOrderID: query failed", err, map[string]interface{}{"order_id": orderID})
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *postgresOrderRepository) ListSagas(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error) {
+	query := `SELECT ` + sagaColumns + ` FROM sagas WHERE status = $1 ORDER BY updated_at LIMIT $2`
+	return querySagas(ctx, r.db, "ListSagas", query, filter.Status, filter.Limit)
+}
+
+func (r *postgresOrderRepository) ClaimDueSagas(ctx context.Context, limit int, lease time.Duration) ([]domain.Saga, error) {
+	query := `UPDATE sagas SET next_attempt_at = NOW() + make_interval(secs => $2), version = version + 1
+              WHERE id IN (
+                  SELECT id FROM sagas
+                  WHERE status IN ('RUNNING', 'COMPENSATING', 'WAITING') AND next_attempt_at <= NOW()
+                  ORDER BY next_attempt_at
+                  LIMIT $1
+                  FOR UPDATE SKIP LOCKED
+              )
+              RETURNING ` + sagaColumns
+	return querySagas(ctx, r.db, "ClaimDueSagas", query, limit, lease.Seconds())
+}
+
+const paymentColumns = `id, order_id, provider, provider_payment_id, amount, currency, status, COALESCE(checkout_url, ''), failure_reason, created_at, updated_at`
+
+func scanPayment(row rowScanner, p *domain.Payment) error {
+	var amount money.Decimal
+	var currency string
+	var failureReason sql.NullString
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.ProviderPaymentID, &amount, &currency, &p.Status, &p.CheckoutURL, &failureReason, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if p.Amount, err = amount.Money(money.Currency(currency)); err != nil {
+		return err
+	}
+	if failureReason.Valid {
+		p.FailureReason = &failureReason.String
+	}
+	return nil
+}
+
+func (r *postgresOrderRepository) CreatePayment(ctx context.Context, payment *domain.Payment) error {
+	// Konflik pada index parsial idx_payments_order_open berarti order sudah punya payment yang masih berlaku
+	query := `INSERT INTO payments (order_id, provider, provider_payment_id, amount, currency, status, checkout_url)
+              VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+              ON CONFLICT (order_id) WHERE status IN ('PENDING', 'SUCCEEDED') DO NOTHING
+              RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, payment.OrderID, payment.Provider, payment.ProviderPaymentID,
+		payment.Amount, payment.Amount.Currency(), payment.Status, payment.CheckoutURL).
+		Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOpenPaymentExists
+	}
+	if err != nil {
+		logger.Error("CreatePayment: insert failed", err, map[string]interface{}{"order_id": payment.OrderID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresOrderRepository) GetPaymentsByOrderID(ctx context.Context, orderID string) ([]domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		logger.Error("GetPaymentsByOrderID: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []domain.Payment{}
+	for rows.Next() {
+		var p domain.Payment
+		if err := scanPayment(rows, &p); err != nil {
+			logger.Error("GetPaymentsByOrderID: scan failed", err, nil)
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+func (r *postgresOrderRepository) GetPaymentByProviderID(ctx context.Context, provider, providerPaymentID string) (*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE provider = $1 AND provider_payment_id = $2`
+	var p domain.Payment
+	if err := scanPayment(r.db.QueryRowContext(ctx, query, provider, providerPaymentID), &p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		logger.Error("GetPaymentByProviderID: query failed", err, nil)
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *postgresOrderRepository) ApplyPaymentEvent(ctx context.Context, paymentID string, event domain.PaymentEvent, status domain.PaymentStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("ApplyPaymentEvent: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO payment_webhook_events (provider, event_id, payment_id, event_type)
+              VALUES ($1, $2, $3, $4) ON CONFLICT (provider, event_id) DO NOTHING`,
+		event.Provider, event.EventID, paymentID, event.Type)
+	if err != nil {
+		logger.Error("ApplyPaymentEvent: failed to insert webhook event", err, map[string]interface{}{"event_id": event.EventID})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDuplicatePaymentEvent
+	}
+
+	res, err = tx.ExecContext(ctx, `UPDATE payments SET status = $1, failure_reason = NULLIF($2, ''), updated_at = NOW()
+              WHERE id = $3 AND status = $4`,
+		status, event.FailureReason, paymentID, domain.PaymentStatusPending)
+	if err != nil {
+		logger.Error("ApplyPaymentEvent: failed to update payment", err, map[string]interface{}{"payment_id": paymentID})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPaymentStatusConflict
+	}
+	return tx.Commit()
+}
+
+// --- Returns & Refunds ---
+
+const returnColumns = `id, order_id, user_id, status, note, rejection_reason, warehouse_id, refund_id, created_at, updated_at`
+
+func scanReturnRequest(row rowScanner, ret *domain.ReturnRequest) error {
+	var note, rejectionReason, warehouseID, refundID sql.NullString
+	err := row.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &note, &rejectionReason, &warehouseID, &refundID, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	ret.Note = nullStringPtr(note)
+	ret.RejectionReason = nullStringPtr(rejectionReason)
+	ret.WarehouseID = nullStringPtr(warehouseID)
+	ret.RefundID = nullStringPtr(refundID)
+	return nil
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+const returnItemColumns = `id, return_id, order_item_id, product_id, quantity, reason, condition, refund_amount, currency`
+
+func scanReturnItem(row rowScanner, item *domain.ReturnItem) error {
+	var amount money.Decimal
+	var currency string
+	err := row.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.ProductID, &item.Quantity, &item.Reason, &item.Condition, &amount, &currency)
+	if err != nil {
+		return err
+	}
+	item.RefundAmount, err = amount.Money(money.Currency(currency))
+	return err
+}
+
+func (r *postgresOrderRepository) CreateReturnRequest(ctx context.Context, ret *domain.ReturnRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CreateReturnRequest: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Kunci order agar dua retur yang diajukan bersamaan tidak melewati sisa quantity yang sama
+	var lockedID string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM orders WHERE id = $1 FOR UPDATE`, ret.OrderID).Scan(&lockedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		logger.Error("CreateReturnRequest: failed to lock order", err, map[string]interface{}{"order_id": ret.OrderID})
+		return err
+	}
+
+	remainingQuery := `SELECT oi.quantity - COALESCE((
+                  SELECT SUM(ri.quantity) FROM return_items ri
+                  JOIN return_requests rr ON rr.id = ri.return_id
+                  WHERE ri.order_item_id = oi.id AND rr.status <> $3), 0)
+              FROM order_items oi WHERE oi.id = $1 AND oi.order_id = $2`
+	for _, item := range ret.Items {
+		var remaining int
+		err := tx.QueryRowContext(ctx, remainingQuery, item.OrderItemID, ret.OrderID, domain.ReturnStatusRejected).Scan(&remaining)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: order item %s does not belong to order %s", ErrReturnQuantityExceeded, item.OrderItemID, ret.OrderID)
+		}
+		if err != nil {
+			logger.Error("CreateReturnRequest: failed to compute returnable quantity", err, map[string]interface{}{"order_item_id": item.OrderItemID})
+			return err
+		}
+		if item.Quantity > remaining {
+			return fmt.Errorf("%w: order item %s has %d returnable unit(s), requested %d", ErrReturnQuantityExceeded, item.OrderItemID, remaining, item.Quantity)
+		}
+	}
+
+	if ret.Status == "" {
+		ret.Status = domain.ReturnStatusRequested
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO return_requests (order_id, user_id, status, note)
+              VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`,
+		ret.OrderID, ret.UserID, ret.Status, ret.Note).Scan(&ret.ID, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateReturnRequest: failed to insert return request", err, map[string]interface{}{"order_id": ret.OrderID})
+		return err
+	}
+
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		item.ReturnID = ret.ID
+		err = tx.QueryRowContext(ctx, `INSERT INTO return_items (return_id, order_item_id, product_id, quantity, reason, condition, refund_amount, currency)
+                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			item.ReturnID, item.OrderItemID, item.ProductID, item.Quantity, item.Reason, item.Condition,
+			item.RefundAmount, item.RefundAmount.Currency()).Scan(&item.ID)
+		if err != nil {
+			logger.Error("CreateReturnRequest: failed to insert return item", err, map[string]interface{}{"order_item_id": item.OrderItemID})
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresOrderRepository) GetReturnRequestByID(ctx context.Context, id string) (*domain.ReturnRequest, error) {
+	query := `SELECT ` + returnColumns + ` FROM return_requests WHERE id = $1`
+	var ret domain.ReturnRequest
+	if err := scanReturnRequest(r.db.QueryRowContext(ctx, query, id), &ret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReturnNotFound
+		}
+		logger.Error("GetReturnRequestByID: query failed", err, nil)
+		return nil, err
+	}
+	returns := []domain.ReturnRequest{ret}
+	if err := r.attachReturnItems(ctx, returns); err != nil {
+		return nil, err
+	}
+	return &returns[0], nil
+}
+
+func (r *postgresOrderRepository) ListReturnRequestsByOrderID(ctx context.Context, orderID string) ([]domain.ReturnRequest, error) {
+	query := `SELECT ` + returnColumns + ` FROM return_requests WHERE order_id = $1 ORDER BY created_at, id`
+	return r.queryReturnRequests(ctx, "ListReturnRequestsByOrderID", query, orderID)
+}
+
+// ListReturnRequests mengembalikan retur terlama lebih dulu, agar antrean persetujuan diproses berurutan.
+func (r *postgresOrderRepository) ListReturnRequests(ctx context.Context, filter domain.ListReturnsFilter) ([]domain.ReturnRequest, error) {
+	query := `SELECT ` + returnColumns + ` FROM return_requests
+              WHERE ($1 = '' OR status = $1)
+              ORDER BY created_at, id
+              LIMIT $2`
+	return r.queryReturnRequests(ctx, "ListReturnRequests", query, string(filter.Status), filter.Limit)
+}
+
+func (r *postgresOrderRepository) queryReturnRequests(ctx context.Context, op, query string, args ...interface{}) ([]domain.ReturnRequest, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error(op+": query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []domain.ReturnRequest{}
+	for rows.Next() {
+		var ret domain.ReturnRequest
+		if err := scanReturnRequest(rows, &ret); err != nil {
+			logger.Error(op+": scan failed", err, nil)
+			return nil, err
+		}
+		returns = append(returns, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.attachReturnItems(ctx, returns); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// attachReturnItems mengisi Items semua retur dengan satu query (hindari N+1).
+func (r *postgresOrderRepository) attachReturnItems(ctx context.Context, returns []domain.ReturnRequest) error {
+	if len(returns) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(returns))
+	ids := make([]string, len(returns))
+	for i := range returns {
+		index[returns[i].ID] = i
+		ids[i] = returns[i].ID
+		returns[i].Items = []domain.ReturnItem{}
+	}
+
+	query := `SELECT ` + returnItemColumns + ` FROM return_items WHERE return_id = ANY($1) ORDER BY return_id, id`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		logger.Error("attachReturnItems: query failed", err, nil)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.ReturnItem
+		if err := scanReturnItem(rows, &item); err != nil {
+			logger.Error("attachReturnItems: scan failed", err, nil)
+			return err
+		}
+		i := index[item.ReturnID]
+		returns[i].Items = append(returns[i].Items, item)
+	}
+	return rows.Err()
+}
+
+func (r *postgresOrderRepository) TransitionReturnRequest(ctx context.Context, t domain.ReturnTransition) error {
+	if !t.From.CanTransitionTo(t.To) {
+		return fmt.Errorf("%w: return %s -> %s", domain.ErrInvalidStatusTransition, t.From, t.To)
+	}
+	query := `UPDATE return_requests
+              SET status = $1,
+                  rejection_reason = COALESCE(NULLIF($2, ''), rejection_reason),
+                  warehouse_id = COALESCE(NULLIF($3, '')::uuid, warehouse_id),
+                  refund_id = COALESCE(NULLIF($4, '')::uuid, refund_id),
+                  updated_at = NOW()
+              WHERE id = $5 AND status = $6`
+	res, err := r.db.ExecContext(ctx, query, t.To, t.RejectionReason, t.WarehouseID, t.RefundID, t.ReturnID, t.From)
+	if err != nil {
+		logger.Error("TransitionReturnRequest: update failed", err, map[string]interface{}{"return_id": t.ReturnID, "from": t.From, "to": t.To})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := r.GetReturnRequestByID(ctx, t.ReturnID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: expected %s", ErrReturnStatusConflict, t.From)
+	}
+	return nil
+}
+
+const refundColumns = `id, order_id, payment_id, return_id, amount, currency, status, reason, provider_refund_id, failure_reason, created_by, created_at, updated_at`
+
+func scanRefund(row rowScanner, rf *domain.Refund) error {
+	var returnID, providerRefundID, failureReason sql.NullString
+	var amount money.Decimal
+	var currency string
+	err := row.Scan(&rf.ID, &rf.OrderID, &rf.PaymentID, &returnID, &amount, &currency, &rf.Status, &rf.Reason,
+		&providerRefundID, &failureReason, &rf.CreatedBy, &rf.CreatedAt, &rf.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if rf.Amount, err = amount.Money(money.Currency(currency)); err != nil {
+		return err
+	}
+	rf.ReturnID = nullStringPtr(returnID)
+	rf.ProviderRefundID = nullStringPtr(providerRefundID)
+	rf.FailureReason = nullStringPtr(failureReason)
+	return nil
+}
+
+func (r *postgresOrderRepository) CreateRefund(ctx context.Context, refund *domain.Refund) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CreateRefund: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Kunci payment agar refund yang dibuat bersamaan tidak melewati nominal payment
+	var paymentAmount money.Decimal
+	var currency string
+	err = tx.QueryRowContext(ctx, `SELECT amount, currency FROM payments WHERE id = $1 FOR UPDATE`, refund.PaymentID).
+		Scan(&paymentAmount, &currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPaymentNotFound
+	}
+	if err != nil {
+		logger.Error("CreateRefund: failed to lock payment", err, map[string]interface{}{"payment_id": refund.PaymentID})
+		return err
+	}
+	paid, err := paymentAmount.Money(money.Currency(currency))
+	if err != nil {
+		return err
+	}
+
+	var refunded money.Decimal
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status IN ($2, $3)`,
+		refund.PaymentID, domain.RefundStatusPending, domain.RefundStatusSucceeded).Scan(&refunded)
+	if err != nil {
+		logger.Error("CreateRefund: failed to sum existing refunds", err, map[string]interface{}{"payment_id": refund.PaymentID})
+		return err
+	}
+	alreadyRefunded, err := refunded.Money(paid.Currency())
+	if err != nil {
+		return err
+	}
+	total, err := alreadyRefunded.Add(refund.Amount)
+	if err != nil {
+		return err
+	}
+	if total.MinorUnits() > paid.MinorUnits() {
+		return fmt.Errorf("%w: %s already refunded of %s, requested %s", ErrRefundExceedsPayment, alreadyRefunded, paid, refund.Amount)
+	}
+
+	if refund.Status == "" {
+		refund.Status = domain.RefundStatusPending
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO refunds (order_id, payment_id, return_id, amount, currency, status, reason, created_by)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at`,
+		refund.OrderID, refund.PaymentID, refund.ReturnID, refund.Amount, refund.Amount.Currency(), refund.Status,
+		refund.Reason, actorOrSystem(refund.CreatedBy)).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateRefund: insert failed", err, map[string]interface{}{"order_id": refund.OrderID})
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresOrderRepository) CompleteRefund(ctx context.Context, refundID string, status domain.RefundStatus, providerRefundID, failureReason string) error {
+	query := `UPDATE refunds
+              SET status = $1, provider_refund_id = NULLIF($2, ''), failure_reason = NULLIF($3, ''), updated_at = NOW()
+              WHERE id = $4 AND status = $5`
+	res, err := r.db.ExecContext(ctx, query, status, providerRefundID, failureReason, refundID, domain.RefundStatusPending)
+	if err != nil {
+		logger.Error("CompleteRefund: update failed", err, map[string]interface{}{"refund_id": refundID})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRefundStatusConflict
+	}
+	return nil
+}
+
+func (r *postgresOrderRepository) GetRefundsByOrderID(ctx context.Context, orderID string) ([]domain.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE order_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		logger.Error("GetRefundsByOrderID: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []domain.Refund{}
+	for rows.Next() {
+		var rf domain.Refund
+		if err := scanRefund(rows, &rf); err != nil {
+			logger.Error("GetRefundsByOrderID: scan failed", err, nil)
+			return nil, err
+		}
+		refunds = append(refunds, rf)
+	}
+	return refunds, rows.Err()
+}
+
+func (r *postgresOrderRepository) ClearRefundRequired(ctx context.Context, orderID string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE orders SET refund_required = FALSE, updated_at = NOW() WHERE id = $1`, orderID)
+	if err != nil {
+		logger.Error("ClearRefundRequired: update failed", err, map[string]interface{}{"order_id": orderID})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+// --- Shipments ---
+
+const shipmentColumns = `id, order_id, warehouse_id, status, carrier, tracking_number, shipped_by, shipped_at, delivered_at, created_at, updated_at`
+
+func scanShipment(row rowScanner, sh *domain.Shipment) error {
+	var carrier, trackingNumber, shippedBy sql.NullString
+	var shippedAt, deliveredAt sql.NullTime
+	err := row.Scan(&sh.ID, &sh.OrderID, &sh.WarehouseID, &sh.Status, &carrier, &trackingNumber, &shippedBy,
+		&shippedAt, &deliveredAt, &sh.CreatedAt, &sh.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	sh.Carrier = nullStringPtr(carrier)
+	sh.TrackingNumber = nullStringPtr(trackingNumber)
+	sh.ShippedBy = nullStringPtr(shippedBy)
+	if shippedAt.Valid {
+		sh.ShippedAt = &shippedAt.Time
+	}
+	if deliveredAt.Valid {
+		sh.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
+
+func (r *postgresOrderRepository) CreateShipments(ctx context.Context, t domain.StatusTransition, shipments []domain.Shipment) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CreateShipments: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Status diubah lebih dulu: UPDATE mengunci order, sehingga pembatalan yang bersamaan tidak meninggalkan shipment
+	if err := r.transitionInTx(ctx, tx, t, statusUpdateQuery, t.To, t.OrderID, t.From); err != nil {
+		return err
+	}
+
+	for i := range shipments {
+		sh := &shipments[i]
+		sh.OrderID = t.OrderID
+		if sh.Status == "" {
+			sh.Status = domain.ShipmentStatusPending
+		}
+		err := tx.QueryRowContext(ctx, `INSERT INTO shipments (order_id, warehouse_id, status)
+                  VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`,
+			sh.OrderID, sh.WarehouseID, sh.Status).Scan(&sh.ID, &sh.CreatedAt, &sh.UpdatedAt)
+		if err != nil {
+			logger.Error("CreateShipments: failed to insert shipment", err, map[string]interface{}{"order_id": t.OrderID, "warehouse_id": sh.WarehouseID})
+			return err
+		}
+		for j := range sh.Items {
+			item := &sh.Items[j]
+			item.ShipmentID = sh.ID
+			err := tx.QueryRowContext(ctx, `INSERT INTO shipment_items (shipment_id, product_id, product_name, sku, quantity)
+                      VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+				item.ShipmentID, item.ProductID, item.ProductName, item.SKU, item.Quantity).Scan(&item.ID)
+			if err != nil {
+				logger.Error("CreateShipments: failed to insert shipment item", err, map[string]interface{}{"shipment_id": sh.ID})
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresOrderRepository) GetShipmentByID(ctx context.Context, id string) (*domain.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE id = $1`
+	var sh domain.Shipment
+	if err := scanShipment(r.db.QueryRowContext(ctx, query, id), &sh); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShipmentNotFound
+		}
+		logger.Error("GetShipmentByID: query failed", err, nil)
+		return nil, err
+	}
+	shipments := []domain.Shipment{sh}
+	if err := r.attachShipmentItems(ctx, shipments); err != nil {
+		return nil, err
+	}
+	return &shipments[0], nil
+}
+
+func (r *postgresOrderRepository) GetShipmentsByOrderID(ctx context.Context, orderID string) ([]domain.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE order_id = $1 ORDER BY created_at, id`
+	return r.queryShipments(ctx, "GetShipmentsByOrderID", query, orderID)
+}
+
+// ListShipments mengembalikan shipment terlama lebih dulu, sesuai urutan antrean pengemasan di gudang.
+func (r *postgresOrderRepository) ListShipments(ctx context.Context, filter domain.ListShipmentsFilter) ([]domain.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments
+              WHERE ($1 = '' OR warehouse_id = NULLIF($1, '')::uuid) AND ($2 = '' OR status = $2)
+              ORDER BY created_at, id
+              LIMIT $3`
+	return r.queryShipments(ctx, "ListShipments", query, filter.WarehouseID, string(filter.Status), filter.Limit)
+}
+
+func (r *postgresOrderRepository) queryShipments(ctx context.Context, op, query string, args ...interface{}) ([]domain.Shipment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error(op+": query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := []domain.Shipment{}
+	for rows.Next() {
+		var sh domain.Shipment
+		if err := scanShipment(rows, &sh); err != nil {
+			logger.Error(op+": scan failed", err, nil)
+			return nil, err
+		}
+		shipments = append(shipments, sh)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.attachShipmentItems(ctx, shipments); err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+// attachShipmentItems mengisi Items semua shipment dengan satu query (hindari N+1).
+func (r *postgresOrderRepository) attachShipmentItems(ctx context.Context, shipments []domain.Shipment) error {
+	if len(shipments) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(shipments))
+	ids := make([]string, len(shipments))
+	for i := range shipments {
+		index[shipments[i].ID] = i
+		ids[i] = shipments[i].ID
+		shipments[i].Items = []domain.ShipmentItem{}
+	}
+
+	query := `SELECT id, shipment_id, product_id, product_name, sku, quantity
+              FROM shipment_items WHERE shipment_id = ANY($1) ORDER BY shipment_id, id`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		logger.Error("attachShipmentItems: query failed", err, nil)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.ShipmentItem
+		if err := rows.Scan(&item.ID, &item.ShipmentID, &item.ProductID, &item.ProductName, &item.SKU, &item.Quantity); err != nil {
+			logger.Error("attachShipmentItems: scan failed", err, nil)
+			return err
+		}
+		i := index[item.ShipmentID]
+		shipments[i].Items = append(shipments[i].Items, item)
+	}
+	return rows.Err()
+}
+
+func (r *postgresOrderRepository) TransitionShipment(ctx context.Context, t domain.ShipmentTransition) error {
+	if !t.From.CanTransitionTo(t.To) {
+		return fmt.Errorf("%w: shipment %s -> %s", domain.ErrInvalidStatusTransition, t.From, t.To)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("TransitionShipment: failed to begin tx", err, nil)
+		return err
+	}
+	defer tx.Rollback()
+
+	var orderID string
+	var orderStatus domain.OrderStatus
+	err = tx.QueryRowContext(ctx, `SELECT o.id, o.status FROM orders o JOIN shipments s ON s.order_id = o.id
+              WHERE s.id = $1 FOR UPDATE OF o`, t.ShipmentID).Scan(&orderID, &orderStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrShipmentNotFound
+	}
+	if err != nil {
+		logger.Error("TransitionShipment: failed to lock order", err, map[string]interface{}{"shipment_id": t.ShipmentID})
+		return err
+	}
+	if t.To == domain.ShipmentStatusShipped && orderStatus != domain.StatusAwaitingShipment && orderStatus != domain.StatusPartiallyShipped {
+		return fmt.Errorf("%w: order %s is %s", ErrOrderStatusConflict, orderID, orderStatus)
+	}
+
+	query := `UPDATE shipments
+              SET status = $1,
+                  carrier = COALESCE(NULLIF($2, ''), carrier),
+                  tracking_number = COALESCE(NULLIF($3, ''), tracking_number),
+                  shipped_by = CASE WHEN $1 = $7 THEN $4 ELSE shipped_by END,
+                  shipped_at = CASE WHEN $1 = $7 THEN NOW() ELSE shipped_at END,
+                  delivered_at = CASE WHEN $1 = $8 THEN NOW() ELSE delivered_at END,
+                  updated_at = NOW()
+              WHERE id = $5 AND status = $6`
+	res, err := tx.ExecContext(ctx, query, t.To, t.Carrier, t.TrackingNumber, actorOrSystem(t.Actor), t.ShipmentID, t.From,
+		domain.ShipmentStatusShipped, domain.ShipmentStatusDelivered)
+	if err != nil {
+		logger.Error("TransitionShipment: update failed", err, map[string]interface{}{"shipment_id": t.ShipmentID, "from": t.From, "to": t.To})
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: expected %s", ErrShipmentStatusConflict, t.From)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT status FROM shipments WHERE order_id = $1`, orderID)
+	if err != nil {
+		logger.Error("TransitionShipment: failed to load order shipments", err, map[string]interface{}{"order_id": orderID})
+		return err
+	}
+	var statuses []domain.ShipmentStatus
+	for rows.Next() {
+		var status domain.ShipmentStatus
+		if err := rows.Scan(&status); err != nil {
+			rows.Close()
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Order yang sudah di-refund tetap pada status refund-nya; shipment hanya dicatat
+	derived, ok := domain.FulfillmentStatus(statuses)
+	if ok && derived != orderStatus && orderStatus.CanTransitionTo(derived) {
+		orderTransition := domain.StatusTransition{OrderID: orderID, From: orderStatus, To: derived, Actor: t.Actor}
+		if err := r.transitionInTx(ctx, tx, orderTransition, statusUpdateQuery, derived, orderID, orderStatus); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+// cancelRestockSagaSteps: mengembalikan stok order yang dibatalkan setelah dibayar ke gudang asalnya.
+// Tidak ada yang perlu dikompensasi; restock yang gagal di-retry dengan backoff lalu menjadi STUCK untuk retry manual.
+func (s *orderServiceImpl) cancelRestockSagaSteps() []SagaStep {
+	return []SagaStep{
+		{Name: domain.SagaStepRestockStock, Execute: s.restockStockStep},
+	}
+}
+
+func (s *orderServiceImpl) restockStockStep(ctx context.Context, saga *domain.Saga) error {
+	return s.restockDeductedStock(ctx, saga.OrderID)
+}
+
+// restockDeductedStock mengembalikan setiap pengurangan stok order yang belum di-restock ke gudang asalnya.
+// Aman diulang: pengurangan yang sudah di-restock dilewati, dan ID pengurangan dipakai sebagai Idempotency-Key
+// sehingga restock yang sampai ke Warehouse Service tapi belum tercatat tidak diterapkan dua kali.
+func (s *orderServiceImpl) restockDeductedStock(ctx context.Context, orderID string) error {
+	deductions, err := s.orderRepo.GetStockDeductionsByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	var lastErr error
+	for _, d := range deductions {
+		if d.RestockedAt != nil {
+			continue
+		}
+		if err := s.warehouseClient.RestockStock(ctx, d.ID, d.WarehouseID, d.ProductID, d.Quantity); err != nil {
+			logger.Error(fmt.Sprintf("Failed to restock ProductID: %s to WarehouseID: %s, OrderID: %s",
+				d.ProductID, d.WarehouseID, orderID), err, nil)
+			failed++
+			lastErr = err
+			continue
+		}
+		if err := s.orderRepo.MarkStockDeductionRestocked(ctx, d.ID); err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		logger.Info(fmt.Sprintf("Restocked %d of ProductID %s to WarehouseID %s (Order %s)", d.Quantity, d.ProductID, d.WarehouseID, orderID))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restock %d stock deduction(s) of order %s: %w", failed, orderID, lastErr)
+	}
+	return nil
+}
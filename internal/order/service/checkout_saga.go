@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+)
+
+// checkoutSagaData disimpan di kolom data saga checkout, cukup untuk menjalankan ulang setiap langkah setelah restart.
+type checkoutSagaData struct {
+	UserID                string         `json:"user_id"`
+	CreatedBy             string         `json:"created_by"`
+	Items                 []checkoutItem `json:"items"`
+	ReservationTTLSeconds int            `json:"reservation_ttl_seconds"`
+}
+
+// checkoutItem adalah item order dengan harga resmi dan snapshot produk dari Product Service.
+type checkoutItem struct {
+	ProductID   string      `json:"product_id"`
+	ProductName string      `json:"product_name,omitempty"`
+	SKU         string      `json:"sku,omitempty"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
+}
+
+func decodeCheckoutData(saga *domain.Saga) (checkoutSagaData, error) {
+	var data checkoutSagaData
+	if err := json.Unmarshal(saga.Data, &data); err != nil {
+		return data, permanentFailure(fmt.Errorf("invalid checkout saga data: %w", err))
+	}
+	return data, nil
+}
+
+// checkoutSagaSteps: reservasi stok -> simpan order -> tunggu pembayaran -> commit reservasi -> buat shipment.
+// Pembayaran adalah pivot: setelah order dibayar, kegagalan commit stok atau pembuatan shipment di-retry lalu ditangani manual, bukan dibatalkan.
+func (s *orderServiceImpl) checkoutSagaSteps() []SagaStep {
+	return []SagaStep{
+		{Name: domain.SagaStepReserveStock, Execute: s.reserveStockStep, Compensate: s.releaseStockStep},
+		// Order yang sudah tersimpan tidak perlu dikompensasi: pembayaran hanya gagal jika order sudah PAYMENT_TIMEOUT/CANCELLED
+		{Name: domain.SagaStepCreateOrder, Execute: s.createOrderStep},
+		{Name: domain.SagaStepPayment, Execute: s.awaitPaymentStep, Pivot: true},
+		{Name: domain.SagaStepCommitStock, Execute: s.commitStockStep},
+		{Name: domain.SagaStepCreateShipments, Execute: s.createShipmentsStep},
+	}
+}
+
+// newCheckoutOrder menyusun order dan item-itemnya dari data saga. Total dihitung tepat dalam minor unit.
+func newCheckoutOrder(orderID string, data checkoutSagaData) (*domain.Order, []domain.OrderItem, error) {
+	if len(data.Items) == 0 {
+		return nil, nil, errors.New("checkout has no items")
+	}
+	totalAmount := money.Zero(data.Items[0].Price.Currency())
+	items := make([]domain.OrderItem, len(data.Items))
+	for i, item := range data.Items {
+		lineTotal, err := item.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, nil, err
+		}
+		if totalAmount, err = totalAmount.Add(lineTotal); err != nil {
+			return nil, nil, err
+		}
+		items[i] = domain.OrderItem{
+			ProductID:       item.ProductID,
+			ProductName:     item.ProductName,
+			SKU:             item.SKU,
+			Quantity:        item.Quantity,
+			PriceAtPurchase: item.Price,
+		}
+	}
+	order := &domain.Order{
+		ID:          orderID,
+		UserID:      data.UserID,
+		TotalAmount: totalAmount,
+		Status:      domain.StatusPendingPayment, // Status awal
+		CreatedBy:   data.CreatedBy,
+	}
+	return order, items, nil
+}
+
+// reserveStockStep mereservasi seluruh item sekaligus via Warehouse Service (all-or-nothing).
+// Jika order sudah punya reservasi (langkah diulang setelah reservasi sebelumnya berhasil tapi belum tercatat),
+// reservasi tersebut dipakai dan tidak direservasi ulang.
+func (s *orderServiceImpl) reserveStockStep(ctx context.Context, saga *domain.Saga) error {
+	data, err := decodeCheckoutData(saga)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.warehouseClient.ListReservations(ctx, saga.OrderID)
+	if err != nil {
+		return err
+	}
+	for _, res := range existing {
+		if res.Status == warehouseDomain.ReservationStatusActive || res.Status == warehouseDomain.ReservationStatusCommitted {
+			logger.Info(fmt.Sprintf("Order %s already has stock reservations, skipping reservation", saga.OrderID))
+			return nil
+		}
+	}
+
+	batchItems := make([]warehouseDomain.ReserveStockBatchItem, len(data.Items))
+	for i, item := range data.Items {
+		batchItems[i] = warehouseDomain.ReserveStockBatchItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	logger.Info(fmt.Sprintf("Attempting to reserve stock for %d items (Order: %s)", len(batchItems), saga.OrderID))
+	ttl := time.Duration(data.ReservationTTLSeconds) * time.Second
+	lines, err := s.warehouseClient.ReserveStockBatch(ctx, saga.OrderID, batchItems, ttl)
+	if err != nil {
+		if isWarehouseRejection(err) {
+			return permanentFailure(err) // Misal stok tidak cukup; mengulang tidak akan berhasil
+		}
+		return err
+	}
+	allocations := 0
+	for _, line := range lines {
+		allocations += len(line.Reservations)
+	}
+	logger.Info(fmt.Sprintf("Successfully reserved stock for order %s (%d warehouse allocations)", saga.OrderID, allocations))
+	return nil
+}
+
+// releaseStockStep adalah kompensasi reserveStockStep: melepas semua reservasi aktif milik order.
+func (s *orderServiceImpl) releaseStockStep(ctx context.Context, saga *domain.Saga) error {
+	return s.releaseOrderReservations(ctx, saga.OrderID)
+}
+
+// createOrderStep menyimpan order beserta fulfillment group-nya. Group diturunkan dari reservasi stok order,
+// yang oleh Warehouse Service sudah dibagi ke gudang sesedikit mungkin.
+func (s *orderServiceImpl) createOrderStep(ctx context.Context, saga *domain.Saga) error {
+	data, err := decodeCheckoutData(saga)
+	if err != nil {
+		return err
+	}
+	order, items, err := newCheckoutOrder(saga.OrderID, data)
+	if err != nil {
+		return permanentFailure(err)
+	}
+	reservations, err := s.warehouseClient.ListReservations(ctx, saga.OrderID)
+	if err != nil {
+		return err
+	}
+	order.FulfillmentGroups = domain.PlanFulfillmentGroups(fulfillmentAllocations(reservations), items)
+	if err := s.orderRepo.CreateOrderWithItems(ctx, order, items); err != nil {
+		// Langkah diulang setelah order sebenarnya sudah tersimpan
+		if existing, getErr := s.orderRepo.GetOrderByID(ctx, saga.OrderID); getErr == nil && existing != nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// fulfillmentAllocations mengambil alokasi per gudang dari reservasi order yang masih berlaku.
+func fulfillmentAllocations(reservations []warehouseDomain.StockReservation) []domain.FulfillmentAllocation {
+	var allocations []domain.FulfillmentAllocation
+	for _, res := range reservations {
+		if res.Status != warehouseDomain.ReservationStatusActive && res.Status != warehouseDomain.ReservationStatusCommitted {
+			continue
+		}
+		allocations = append(allocations, domain.FulfillmentAllocation{
+			WarehouseID: res.WarehouseID,
+			ProductID:   res.ProductID,
+			Quantity:    res.Quantity,
+		})
+	}
+	return allocations
+}
+
+// awaitPaymentStep menunggu order dibayar. Order yang berakhir tanpa pembayaran (timeout/dibatalkan)
+// membuat saga melepas reservasi stoknya.
+func (s *orderServiceImpl) awaitPaymentStep(ctx context.Context, saga *domain.Saga) error {
+	order, err := s.orderRepo.GetOrderByID(ctx, saga.OrderID)
+	if err != nil {
+		return err
+	}
+	switch order.Status {
+	case domain.StatusPendingPayment:
+		return errSagaWaiting
+	case domain.StatusPaymentTimeout, domain.StatusCancelled, domain.StatusFailed:
+		return permanentFailure(fmt.Errorf("order ended in status %s without payment", order.Status))
+	default:
+		return nil
+	}
+}
+
+// commitStockStep meng-commit setiap reservasi aktif milik order sehingga stok dikurangi tepat di gudang yang direservasi,
+// lalu mencatat gudang asalnya agar pembatalan bisa mengembalikan stok ke tempat yang sama.
+// Aman diulang: reservasi yang sudah di-commit dilewati dan pengurangan stok hanya dicatat untuk selisih yang belum tercatat.
+// Order yang dibatalkan sebelum atau selama commit direkonsiliasi oleh reconcileCancelledCommit.
+func (s *orderServiceImpl) commitStockStep(ctx context.Context, saga *domain.Saga) error {
+	orderID := saga.OrderID
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status == domain.StatusCancelled {
+		logger.Info(fmt.Sprintf("Order %s was cancelled before stock was committed, reconciling its reservations", orderID))
+		return s.reconcileCancelledCommit(ctx, orderID)
+	}
+
+	reservations, err := s.warehouseClient.ListReservations(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	var commitErr error
+	for i, res := range reservations {
+		if res.Status != warehouseDomain.ReservationStatusActive {
+			continue
+		}
+		committed, err := s.warehouseClient.CommitReservation(ctx, res.ID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to commit reservation %s for ProductID %s from WarehouseID %s (Order %s)",
+				res.ID, res.ProductID, res.WarehouseID, orderID), err, nil)
+			commitErr = err
+			continue
+		}
+		logger.Info(fmt.Sprintf("Committed reservation %s (%d of ProductID %s from WarehouseID %s)",
+			committed.ID, committed.Quantity, committed.ProductID, committed.WarehouseID))
+		reservations[i] = *committed
+	}
+
+	if err := s.recordCommittedDeductions(ctx, orderID, reservations); err != nil {
+		return err
+	}
+	if commitErr != nil {
+		return commitErr
+	}
+
+	// Pembatalan yang terjadi selama commit mungkin sudah menjalankan saga cancel_restock sebelum pengurangan di atas tercatat,
+	// sehingga status order diperiksa ulang setelah pencatatan dan stoknya dikembalikan di sini.
+	if order, err = s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return err
+	}
+	if order.Status == domain.StatusCancelled {
+		logger.Warn(fmt.Sprintf("Order %s was cancelled while its stock was being committed, restocking", orderID))
+		return s.reconcileCancelledCommit(ctx, orderID)
+	}
+
+	// Pastikan jumlah yang di-commit sesuai dengan item order
+	items, err := s.orderRepo.GetOrderItemsByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	committedByProduct := make(map[string]int)
+	for _, res := range reservations {
+		if res.Status == warehouseDomain.ReservationStatusCommitted {
+			committedByProduct[res.ProductID] += res.Quantity
+		}
+	}
+	neededByProduct := make(map[string]int)
+	for _, item := range items {
+		neededByProduct[item.ProductID] += item.Quantity
+	}
+	for productID, needed := range neededByProduct {
+		if committedByProduct[productID] < needed {
+			// Reservasi sudah kedaluwarsa atau dilepas; mengulang tidak akan menambah stok yang di-commit
+			return permanentFailure(fmt.Errorf("committed %d of %d units of product %s", committedByProduct[productID], needed, productID))
+		}
+	}
+	return nil
+}
+
+// reconcileCancelledCommit menyelesaikan commit stok order yang sudah dibatalkan: reservasi yang sempat di-commit
+// dicatat pengurangannya lalu dikembalikan ke gudang, dan sisa reservasi aktif dilepas.
+// Restock di sini dan di saga cancel_restock memakai Idempotency-Key yang sama per pengurangan, sehingga tidak dobel.
+func (s *orderServiceImpl) reconcileCancelledCommit(ctx context.Context, orderID string) error {
+	reservations, err := s.warehouseClient.ListReservations(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if err := s.recordCommittedDeductions(ctx, orderID, reservations); err != nil {
+		return err
+	}
+	if err := s.releaseOrderReservations(ctx, orderID); err != nil {
+		return err
+	}
+	return s.restockDeductedStock(ctx, orderID)
+}
+
+// recordCommittedDeductions mencatat pengurangan stok per gudang untuk reservasi yang sudah di-commit
+// tetapi belum tercatat di stock deductions.
+func (s *orderServiceImpl) recordCommittedDeductions(ctx context.Context, orderID string, reservations []warehouseDomain.StockReservation) error {
+	recorded, err := s.orderRepo.GetStockDeductionsByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	type location struct{ productID, warehouseID string }
+	recordedQty := make(map[location]int)
+	for _, d := range recorded {
+		recordedQty[location{d.ProductID, d.WarehouseID}] += d.Quantity
+	}
+
+	committedQty := make(map[location]int)
+	var locations []location // Urutan sesuai reservasi agar pencatatan deterministik
+	for _, res := range reservations {
+		if res.Status != warehouseDomain.ReservationStatusCommitted {
+			continue
+		}
+		loc := location{res.ProductID, res.WarehouseID}
+		if _, seen := committedQty[loc]; !seen {
+			locations = append(locations, loc)
+		}
+		committedQty[loc] += res.Quantity
+	}
+
+	for _, loc := range locations {
+		missing := committedQty[loc] - recordedQty[loc]
+		if missing <= 0 {
+			continue
+		}
+		deduction := &domain.StockDeduction{
+			OrderID:     orderID,
+			ProductID:   loc.productID,
+			WarehouseID: loc.warehouseID,
+			Quantity:    missing,
+		}
+		if err := s.orderRepo.RecordStockDeduction(ctx, deduction); err != nil {
+			return fmt.Errorf("failed to record stock deduction for ProductID %s from WarehouseID %s: %w", loc.productID, loc.warehouseID, err)
+		}
+	}
+	return nil
+}
+
+// createShipmentsStep membuat satu shipment per gudang tempat stok order dikurangi, lalu memindahkan order
+// ke AWAITING_SHIPMENT dalam transaksi yang sama. Order yang sudah tidak PAYMENT_CONFIRMED (langkah diulang
+// setelah berhasil, atau order dibatalkan) dilewati.
+func (s *orderServiceImpl) createShipmentsStep(ctx context.Context, saga *domain.Saga) error {
+	orderID := saga.OrderID
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != domain.StatusPaymentConfirmed {
+		logger.Info(fmt.Sprintf("Order %s is %s, skipping shipment creation", orderID, order.Status))
+		return nil
+	}
+
+	deductions, err := s.orderRepo.GetStockDeductionsByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if len(deductions) == 0 {
+		return permanentFailure(fmt.Errorf("order %s has no recorded stock deductions to ship from", orderID))
+	}
+	items, err := s.orderRepo.GetOrderItemsByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	shipments := domain.PlanShipments(orderID, deductions, items)
+	err = s.orderRepo.CreateShipments(ctx, domain.StatusTransition{
+		OrderID: orderID,
+		From:    domain.StatusPaymentConfirmed,
+		To:      domain.StatusAwaitingShipment,
+		Actor:   domain.ActorSystem,
+		Reason:  fmt.Sprintf("%d shipment(s) created", len(shipments)),
+	}, shipments)
+	if errors.Is(err, repository.ErrOrderStatusConflict) {
+		// Dibatalkan atau sudah diproses instance lain di antara pengecekan status dan transaksi
+		logger.Info(fmt.Sprintf("Order %s changed status before shipments were created, skipping", orderID))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Created %d shipment(s) for order %s", len(shipments), orderID))
+	return nil
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/events"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, event events.Event) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockProductClientForOrder struct {
+	mock.Mock
+}
+
+func (m *MockProductClientForOrder) GetProductPrices(ctx context.Context, productIDs []string) ([]productDomain.ProductPrice, error) {
+	args := m.Called(ctx, productIDs)
+	if res := args.Get(0); res != nil {
+		return res.([]productDomain.ProductPrice), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
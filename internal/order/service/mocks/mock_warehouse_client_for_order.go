@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	whDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 	"github.com/stretchr/testify/mock"
@@ -11,22 +12,41 @@ type MockWarehouseClientForOrder struct {
 	mock.Mock
 }
 
-func (m *MockWarehouseClientForOrder) ReserveStock(ctx context.Context, productID string, quantity int) error {
-	args := m.Called(ctx, productID, quantity)
-	return args.Error(0)
+func (m *MockWarehouseClientForOrder) ReserveStockBatch(ctx context.Context, orderID string, items []whDomain.ReserveStockBatchItem, ttl time.Duration) ([]whDomain.ReservationLine, error) {
+	args := m.Called(ctx, orderID, items, ttl)
+	if lines := args.Get(0); lines != nil {
+		return lines.([]whDomain.ReservationLine), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockWarehouseClientForOrder) ListReservations(ctx context.Context, orderID string) ([]whDomain.StockReservation, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]whDomain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
-func (m *MockWarehouseClientForOrder) ReleaseStock(ctx context.Context, productID string, quantity int) error {
-	args := m.Called(ctx, productID, quantity)
+func (m *MockWarehouseClientForOrder) CommitReservation(ctx context.Context, reservationID string) (*whDomain.StockReservation, error) {
+	args := m.Called(ctx, reservationID)
+	if res := args.Get(0); res != nil {
+		return res.(*whDomain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockWarehouseClientForOrder) ReleaseReservation(ctx context.Context, reservationID string) error {
+	args := m.Called(ctx, reservationID)
 	return args.Error(0)
 }
-func (m *MockWarehouseClientForOrder) DeductStock(ctx context.Context, req whDomain.DeductStockRequest) error {
-	args := m.Called(ctx, req)
+
+func (m *MockWarehouseClientForOrder) RestockStock(ctx context.Context, reference, warehouseID, productID string, quantity int) error {
+	args := m.Called(ctx, reference, warehouseID, productID, quantity)
 	return args.Error(0)
 }
-func (m *MockWarehouseClientForOrder) FindWarehousesWithReservations(ctx context.Context, productIDs []string) ([]whDomain.ProductWarehouseReservationInfo, error) {
-	args := m.Called(ctx, productIDs)
+
+func (m *MockWarehouseClientForOrder) ReceiveReturn(ctx context.Context, reference, warehouseID, productID string, quantity int) (*whDomain.ReceiveReturnResponse, error) {
+	args := m.Called(ctx, reference, warehouseID, productID, quantity)
 	if res := args.Get(0); res != nil {
-		return res.([]whDomain.ProductWarehouseReservationInfo), args.Error(1)
+		return res.(*whDomain.ReceiveReturnResponse), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	// Ganti dengan path yang benar
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
 	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 	"github.com/robfig/cron/v3"
 )
@@ -19,28 +22,104 @@ var (
 	ErrStockReservationFailed = errors.New("stock reservation failed for one or more items")
 	ErrOrderCannotBeConfirmed = errors.New("order cannot be confirmed, invalid current status or order not found")
 	ErrStockDeductionFailed   = errors.New("stock deduction failed for one or more items")
+	ErrInvalidListQuery       = errors.New("invalid order list query")
+	ErrOrderCannotBeCancelled = errors.New("order cannot be cancelled in its current status")
+	ErrOrderAlreadyShipped    = errors.New("order has already been shipped and can no longer be cancelled")
+	ErrUnknownProduct         = errors.New("one or more products do not exist")
+	ErrPriceChanged           = errors.New("price of one or more items has changed")
+	ErrPriceLookupFailed      = errors.New("failed to look up product prices")
+	ErrMixedCurrencies        = errors.New("items are priced in different currencies")
 )
 
+// PriceChangedError dikembalikan CreateOrder jika harga dari client berbeda dengan harga resmi.
+// Quote berisi harga terbaru semua item sehingga client bisa mengonfirmasi dan mengirim ulang order.
+type PriceChangedError struct {
+	Quote []domain.PriceQuote
+}
+
+func (e *PriceChangedError) Error() string {
+	return ErrPriceChanged.Error()
+}
+
+func (e *PriceChangedError) Unwrap() error {
+	return ErrPriceChanged
+}
+
+// reservationExpiryGrace memberi jeda antara batas waktu pembayaran dan kedaluwarsa reservasi stok,
+// sehingga scheduler timeout Order Service sempat melepas reservasi lebih dulu.
+const reservationExpiryGrace = 15 * time.Minute
+
 type OrderService interface {
 	CreateOrder(ctx context.Context, req domain.CreateOrderRequest) (*domain.CreateOrderResponse, error)
 	ProcessPaymentTimeouts(ctx context.Context) // Fungsi untuk scheduler
 	ConfirmPayment(ctx context.Context, orderID string) (*domain.Order, error)
+	GetOrder(ctx context.Context, orderID string) (*domain.Order, error)
+	GetOrderDetails(ctx context.Context, orderID string) (*domain.Order, error)
+	ListOrders(ctx context.Context, filter domain.ListOrdersFilter) (*domain.ListOrdersResponse, error)
+	CancelOrder(ctx context.Context, orderID string, reason string) (*domain.Order, error)
+	GetOrderHistory(ctx context.Context, orderID string) ([]domain.OrderStatusHistory, error)
+
+	// Pembayaran lewat PaymentProvider; order hanya dibayar lewat webhook yang tanda tangannya valid
+	CreatePaymentIntent(ctx context.Context, orderID string) (*domain.Payment, error)
+	GetOrderPayments(ctx context.Context, orderID string) ([]domain.Payment, error)
+	HandlePaymentWebhook(ctx context.Context, provider string, header http.Header, body []byte) error
+	SimulatePayment(ctx context.Context, orderID string, outcome string) (*domain.Payment, error)
+
+	// Retur (RMA) dan refund
+	CreateReturn(ctx context.Context, orderID string, req domain.CreateReturnRequest) (*domain.ReturnRequest, error)
+	ListOrderReturns(ctx context.Context, orderID string) ([]domain.ReturnRequest, error)
+	GetReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error)
+	ListReturns(ctx context.Context, filter domain.ListReturnsFilter) ([]domain.ReturnRequest, error)
+	ApproveReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error)
+	RejectReturn(ctx context.Context, returnID string, reason string) (*domain.ReturnRequest, error)
+	ReceiveReturn(ctx context.Context, returnID, warehouseID string) (*domain.ReturnRequest, error)
+	RefundReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error)
+	CreateRefund(ctx context.Context, orderID string, req domain.CreateRefundRequest) (*domain.Refund, error)
+	GetOrderRefunds(ctx context.Context, orderID string) ([]domain.Refund, error)
+
+	// Pengiriman: satu shipment per gudang asal stok, status order diturunkan dari status shipment
+	GetOrderShipments(ctx context.Context, orderID string) ([]domain.Shipment, error)
+	GetShipment(ctx context.Context, shipmentID string) (*domain.Shipment, error)
+	ListShipments(ctx context.Context, filter domain.ListShipmentsFilter) ([]domain.Shipment, error)
+	ShipShipment(ctx context.Context, shipmentID string, req domain.ShipShipmentRequest) (*domain.Shipment, error)
+	DeliverShipment(ctx context.Context, shipmentID string) (*domain.Shipment, error)
+
+	// Admin: saga checkout yang STUCK dan perlu ditangani manual
+	ListSagas(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error)
+	RetrySaga(ctx context.Context, sagaID string) (*domain.Saga, error)
+	ResumeSagas(ctx context.Context) // Fungsi untuk scheduler
 }
 
 type orderServiceImpl struct {
 	orderRepo              repository.OrderRepository
 	warehouseClient        WarehouseClient
+	productClient          ProductClient
+	paymentProvider        PaymentProvider
 	scheduler              *cron.Cron
+	sagas                  *SagaOrchestrator
 	paymentTimeoutDuration time.Duration
+	// paymentSimulationEnabled mengizinkan SimulatePayment; default mati agar pembayaran tidak bisa dipalsukan
+	paymentSimulationEnabled bool
+}
+
+// NewOrderService membuat service dengan simulasi pembayaran dimatikan.
+func NewOrderService(or repository.OrderRepository, wc WarehouseClient, pc ProductClient, pp PaymentProvider, paymentTimeout time.Duration) OrderService {
+	return NewOrderServiceWithConfig(or, wc, pc, pp, paymentTimeout, false)
 }
 
-func NewOrderService(or repository.OrderRepository, wc WarehouseClient, paymentTimeout time.Duration) OrderService {
+func NewOrderServiceWithConfig(or repository.OrderRepository, wc WarehouseClient, pc ProductClient, pp PaymentProvider, paymentTimeout time.Duration, paymentSimulationEnabled bool) OrderService {
 	s := &orderServiceImpl{
-		orderRepo:              or,
-		warehouseClient:        wc,
-		scheduler:              cron.New(cron.WithSeconds()), // Menggunakan opsi WithSeconds() jika perlu granularitas detik
-		paymentTimeoutDuration: paymentTimeout,
+		orderRepo:                or,
+		warehouseClient:          wc,
+		productClient:            pc,
+		paymentProvider:          pp,
+		scheduler:                cron.New(cron.WithSeconds()), // Menggunakan opsi WithSeconds() jika perlu granularitas detik
+		sagas:                    NewSagaOrchestrator(or),
+		paymentTimeoutDuration:   paymentTimeout,
+		paymentSimulationEnabled: paymentSimulationEnabled,
 	}
+	s.sagas.Register(domain.SagaTypeCheckout, s.checkoutSagaSteps())
+	s.sagas.Register(domain.SagaTypeCancelRestock, s.cancelRestockSagaSteps())
 	s.initScheduler()
 	return s
 }
@@ -52,6 +131,10 @@ func (s *orderServiceImpl) initScheduler() {
 		// Gunakan context.Background() karena ini adalah background job
 		s.ProcessPaymentTimeouts(context.Background())
 	})
+	// Melanjutkan saga yang dijadwalkan ulang atau terhenti karena restart
+	s.scheduler.AddFunc(spec, func() {
+		s.ResumeSagas(context.Background())
+	})
 	s.scheduler.Start()
 	logger.Info(fmt.Sprintf("Payment timeout scheduler initialized with spec '%s' and timeout duration %v", spec, s.paymentTimeoutDuration))
 }
@@ -74,41 +157,44 @@ func (s *orderServiceImpl) ProcessPaymentTimeouts(ctx context.Context) {
 	for _, order := range orders {
 		logger.Info(fmt.Sprintf("Processing timeout for order ID: %s", order.ID))
 
-		// 1. Dapatkan item-item order
-		items, err := s.orderRepo.GetOrderItemsByOrderID(ctx, order.ID)
+		// Pembayaran yang sudah berhasil tetapi belum mengonfirmasi order (misal webhook gagal di tengah jalan)
+		// diselesaikan, bukan di-timeout.
+		paid, err := s.hasSucceededPayment(ctx, order.ID)
 		if err != nil {
-			logger.Error(fmt.Sprintf("ProcessPaymentTimeouts: Failed to get items for order %s", order.ID), err, nil)
-			// Pertimbangkan untuk menandai order ini sebagai FAILED atau perlu investigasi manual
-			// s.orderRepo.UpdateOrderStatus(ctx, order.ID, domain.StatusFailed) // contoh
-			continue // Lanjut ke order berikutnya
+			logger.Error(fmt.Sprintf("ProcessPaymentTimeouts: failed to check payments of order %s, skipping", order.ID), err, nil)
+			continue
 		}
-
-		// 2. Lepaskan stok untuk setiap item
-		allReleased := true
-		for _, item := range items {
-			logger.Info(fmt.Sprintf("Releasing stock for ProductID: %s, Quantity: %d (Order: %s)", item.ProductID, item.Quantity, order.ID))
-			err := s.warehouseClient.ReleaseStock(ctx, item.ProductID, item.Quantity)
-			if err != nil {
-				// Ini masalah jika pelepasan gagal, bisa menyebabkan inkonsistensi
-				// Mungkin stok sudah dilepas, atau warehouse service error. Perlu logging detail.
-				logger.Error(fmt.Sprintf("CRITICAL: Failed to release stock for ProductID: %s, OrderID: %s during timeout processing", item.ProductID, order.ID), err, nil)
-				allReleased = false
-				// Jangan hentikan proses update status order, tapi catat ini
+		if paid != nil {
+			logger.Warn(fmt.Sprintf("ProcessPaymentTimeouts: order %s has succeeded payment %s, confirming instead of timing out", order.ID, paid.ID))
+			if err := s.settlePaidOrder(ctx, paid); err != nil {
+				logger.Error(fmt.Sprintf("ProcessPaymentTimeouts: failed to confirm paid order %s", order.ID), err, nil)
 			}
+			continue
 		}
 
-		// 3. Update status order menjadi PAYMENT_TIMEOUT
-		// Lakukan ini bahkan jika beberapa pelepasan stok gagal, agar tidak diproses lagi.
-		// Kegagalan pelepasan stok harus dimonitor dan ditangani secara terpisah jika terjadi.
-		err = s.orderRepo.UpdateOrderStatus(ctx, order.ID, domain.StatusPaymentTimeout)
+		// 1. Update status order menjadi PAYMENT_TIMEOUT lebih dulu (compare-and-set).
+		// Jika pembayaran dikonfirmasi bersamaan, salah satu proses akan kalah dan stok tidak dilepas dua kali.
+		err = s.orderRepo.TransitionOrderStatus(ctx, domain.StatusTransition{
+			OrderID: order.ID,
+			From:    domain.StatusPendingPayment,
+			To:      domain.StatusPaymentTimeout,
+			Actor:   domain.ActorSystemPaymentTimeout,
+			Reason:  fmt.Sprintf("payment not received within %v", s.paymentTimeoutDuration),
+		})
 		if err != nil {
-			logger.Error(fmt.Sprintf("ProcessPaymentTimeouts: Failed to update order status for %s", order.ID), err, nil)
-		} else {
-			if allReleased {
-				logger.Info(fmt.Sprintf("Order %s marked as PAYMENT_TIMEOUT and stock released.", order.ID))
+			if errors.Is(err, repository.ErrOrderStatusConflict) {
+				logger.Info(fmt.Sprintf("ProcessPaymentTimeouts: order %s changed status concurrently, skipping.", order.ID))
 			} else {
-				logger.Warn(fmt.Sprintf("Order %s marked as PAYMENT_TIMEOUT, but some stock items may not have been released successfully. Needs review.", order.ID))
+				logger.Error(fmt.Sprintf("ProcessPaymentTimeouts: Failed to update order status for %s", order.ID), err, nil)
 			}
+			continue
+		}
+
+		// 2. Saga checkout melepas reservasi stok milik order ini (dan mengulangnya jika gagal)
+		if saga := s.resumeCheckoutSaga(ctx, order.ID); saga != nil && saga.Status == domain.SagaStatusCompensated {
+			logger.Info(fmt.Sprintf("Order %s marked as PAYMENT_TIMEOUT and stock released.", order.ID))
+		} else {
+			logger.Warn(fmt.Sprintf("Order %s marked as PAYMENT_TIMEOUT, stock release is still pending and will be retried.", order.ID))
 		}
 	}
 }
@@ -118,74 +204,147 @@ func (s *orderServiceImpl) CreateOrder(ctx context.Context, req domain.CreateOrd
 		return nil, errors.New("order must contain at least one item")
 	}
 
-	// 1. (TODO Nantinya): Validasi produk dan dapatkan harga terbaru dari ProductService
-	// Untuk sekarang, kita asumsikan harga di request valid.
+	// 1. Ambil harga resmi dari Product Service; harga dari client hanya dipakai untuk dicocokkan
+	items, err := s.priceItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
+	}
 
-	// 2. Reservasi stok untuk setiap item via WarehouseService
-	// Jika salah satu gagal, seluruh order gagal.
-	// Tidak ada rollback otomatis untuk reservasi yang sudah berhasil di item lain dalam tahap ini.
-	// Ini akan ditangani oleh mekanisme timeout pembayaran nanti (Tahap 4).
-	successfullyReservedItems := []domain.CreateOrderItemRequest{}
+	// 2. Siapkan ID order lebih dulu agar reservasi stok bisa dicatat atas nama order ini
+	orderID, err := s.orderRepo.NextOrderID(ctx)
+	if err != nil {
+		logger.Error("CreateOrder: failed to generate order ID", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrOrderCreationFailed, err)
+	}
 
-	for _, itemReq := range req.Items {
-		logger.Info(fmt.Sprintf("Attempting to reserve stock for ProductID: %s, Quantity: %d", itemReq.ProductID, itemReq.Quantity))
-		err := s.warehouseClient.ReserveStock(ctx, itemReq.ProductID, itemReq.Quantity)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to reserve stock for ProductID: %s", itemReq.ProductID), err, nil)
-
-			// PENTING: Melepaskan stok yang sudah berhasil direservasi untuk item sebelumnya dalam order ini
-			// Ini membuat proses lebih atomik dari perspektif Order Service
-			if len(successfullyReservedItems) > 0 {
-				logger.Info(fmt.Sprintf("Rolling back reservations for %d successfully reserved items due to failure on ProductID: %s", len(successfullyReservedItems), itemReq.ProductID))
-				for _, reservedItem := range successfullyReservedItems {
-					// Gunakan context baru atau background context jika ctx asli sudah selesai
-					// Ini adalah "best-effort" rollback. Kegagalan di sini kompleks untuk ditangani.
-					releaseErr := s.warehouseClient.ReleaseStock(context.Background(), reservedItem.ProductID, reservedItem.Quantity)
-					if releaseErr != nil {
-						// Log error parah ini, karena bisa menyebabkan inkonsistensi stok
-						logger.Error(fmt.Sprintf("CRITICAL: Failed to release previously reserved stock for ProductID: %s after order failure.", reservedItem.ProductID), releaseErr, nil)
-					}
-				}
+	// 3. Jalankan saga checkout: reservasi stok lalu simpan order, sampai menunggu pembayaran.
+	// Reservasi berlaku sedikit lebih lama dari batas waktu pembayaran; sisanya dibersihkan oleh Warehouse Service.
+	data := checkoutSagaData{
+		UserID:                req.UserID,
+		CreatedBy:             actorFromContext(ctx),
+		Items:                 items,
+		ReservationTTLSeconds: int((s.paymentTimeoutDuration + reservationExpiryGrace).Seconds()),
+	}
+	saga, err := s.sagas.Start(ctx, domain.SagaTypeCheckout, orderID, data)
+	if saga == nil {
+		logger.Error("CreateOrder: failed to start checkout saga", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrOrderCreationFailed, err)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("CreateOrder: failed to save progress of checkout saga for order %s", orderID), err, nil)
+	}
+
+	if saga.Status != domain.SagaStatusWaiting {
+		// Customer menunggu jawaban sekarang: langkah yang masih gagal tidak di-retry di background,
+		// melainkan dibatalkan dan reservasi yang sudah dibuat dilepas (kompensasi tetap di-retry jika gagal).
+		if saga.Status == domain.SagaStatusRunning {
+			cause := fmt.Errorf("%s", saga.Steps[saga.CurrentStep].LastError)
+			if err := s.sagas.Abort(ctx, saga, cause); err != nil {
+				logger.Error(fmt.Sprintf("CreateOrder: failed to abort checkout saga for order %s", orderID), err, nil)
 			}
-			return nil, fmt.Errorf("%w: product_id %s, quantity %d. %v", ErrStockReservationFailed, itemReq.ProductID, itemReq.Quantity, err)
 		}
-		successfullyReservedItems = append(successfullyReservedItems, itemReq) // Tambahkan ke daftar yang berhasil
-		logger.Info(fmt.Sprintf("Successfully reserved stock for ProductID: %s, Quantity: %d", itemReq.ProductID, itemReq.Quantity))
-	}
-
-	// 3. Hitung total amount
-	var totalAmount float64
-	orderItems := make([]domain.OrderItem, len(req.Items))
-	for i, itemReq := range req.Items {
-		totalAmount += itemReq.Price * float64(itemReq.Quantity)
-		orderItems[i] = domain.OrderItem{
-			ProductID:       itemReq.ProductID,
-			Quantity:        itemReq.Quantity,
-			PriceAtPurchase: itemReq.Price,
+		return nil, checkoutError(saga)
+	}
+
+	// 4. Kembalikan order yang tersimpan beserta item-itemnya
+	order, err := s.GetOrderDetails(ctx, orderID)
+	if err != nil {
+		// Order sudah tersimpan; jangan kembalikan error agar client tidak membuat order ganda
+		logger.Error(fmt.Sprintf("CreateOrder: order %s created but could not be reloaded", orderID), err, nil)
+		var orderItems []domain.OrderItem
+		if order, orderItems, err = newCheckoutOrder(orderID, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrOrderCreationFailed, err)
 		}
+		order.Items = orderItems
+	}
+
+	// 5. Siapkan pembayaran di provider. Kegagalan tidak membatalkan order: customer bisa meminta intent baru.
+	payment, err := s.ensurePaymentIntent(ctx, order)
+	if err != nil {
+		logger.Error(fmt.Sprintf("CreateOrder: failed to create payment intent for order %s", orderID), err, nil)
 	}
+	return &domain.CreateOrderResponse{Order: *order, Payment: payment}, nil
+}
 
-	// 4. Buat Order di database
-	newOrder := &domain.Order{
-		UserID:      req.UserID,
-		TotalAmount: totalAmount,
-		Status:      domain.StatusPendingPayment, // Status awal
+// priceItems mengganti harga item dengan harga resmi dari Product Service.
+// Produk yang tidak ada ditolak; jika ada harga dari client yang berbeda, seluruh order ditolak dengan penawaran harga terbaru.
+func (s *orderServiceImpl) priceItems(ctx context.Context, reqItems []domain.CreateOrderItemRequest) ([]checkoutItem, error) {
+	productIDs := make([]string, 0, len(reqItems))
+	seen := make(map[string]bool, len(reqItems))
+	for _, item := range reqItems {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			productIDs = append(productIDs, item.ProductID)
+		}
 	}
 
-	err := s.orderRepo.CreateOrderWithItems(ctx, newOrder, orderItems)
+	prices, err := s.productClient.GetProductPrices(ctx, productIDs)
 	if err != nil {
-		logger.Error("CreateOrder: failed to save order to repository", err, nil)
-		// Jika penyimpanan order gagal SETELAH stok direservasi, ini adalah masalah.
-		// Stok yang sudah direservasi akan "tergantung". Mekanisme timeout harus menangani ini.
-		// Untuk sekarang, log error ini dengan serius.
-		// Idealnya, kita juga coba release stok di sini, tapi itu menambah kompleksitas.
-		return nil, fmt.Errorf("%w: %v", ErrOrderCreationFailed, err)
+		logger.Error("CreateOrder: failed to look up product prices", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrPriceLookupFailed, err)
+	}
+	priceByProduct := make(map[string]productDomain.ProductPrice, len(prices))
+	for _, p := range prices {
+		priceByProduct[p.ProductID] = p
+	}
+
+	items := make([]checkoutItem, len(reqItems))
+	quote := make([]domain.PriceQuote, len(reqItems))
+	priceChanged := false
+	for i, reqItem := range reqItems {
+		current, ok := priceByProduct[reqItem.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProduct, reqItem.ProductID)
+		}
+		if current.Price.Currency() != prices[0].Price.Currency() {
+			return nil, fmt.Errorf("%w: %s is priced in %s, %s in %s", ErrMixedCurrencies,
+				current.ProductID, current.Price.Currency(), prices[0].ProductID, prices[0].Price.Currency())
+		}
+		if reqItem.Price != nil && !reqItem.Price.Equal(current.Price) {
+			priceChanged = true
+		}
+		items[i] = checkoutItem{
+			ProductID:   reqItem.ProductID,
+			ProductName: current.Name,
+			SKU:         current.SKU,
+			Quantity:    reqItem.Quantity,
+			Price:       current.Price,
+		}
+		quote[i] = domain.PriceQuote{
+			ProductID:      reqItem.ProductID,
+			Name:           current.Name,
+			SKU:            current.SKU,
+			Quantity:       reqItem.Quantity,
+			RequestedPrice: reqItem.Price,
+			CurrentPrice:   current.Price,
+		}
+	}
+	if priceChanged {
+		logger.Warn(fmt.Sprintf("CreateOrder: rejected order from %s because client prices are outdated", actorFromContext(ctx)))
+		return nil, &PriceChangedError{Quote: quote}
 	}
+	return items, nil
+}
 
-	return &domain.CreateOrderResponse{Order: *newOrder}, nil
+// checkoutError menerjemahkan saga checkout yang gagal menjadi error untuk caller CreateOrder.
+func checkoutError(saga *domain.Saga) error {
+	cause := "checkout did not complete"
+	if saga.LastError != nil {
+		cause = *saga.LastError
+	}
+	if step, _ := saga.FailedStep(); step == domain.SagaStepReserveStock {
+		return fmt.Errorf("%w: %s", ErrStockReservationFailed, cause)
+	}
+	return fmt.Errorf("%w: %s", ErrOrderCreationFailed, cause)
 }
 
+// ConfirmPayment adalah konfirmasi manual oleh admin (misal transfer yang dicek di luar sistem).
+// Pembayaran lewat provider dikonfirmasi oleh HandlePaymentWebhook.
 func (s *orderServiceImpl) ConfirmPayment(ctx context.Context, orderID string) (*domain.Order, error) {
+	return s.confirmPayment(ctx, orderID, actorFromContext(ctx), "payment confirmed manually")
+}
+
+func (s *orderServiceImpl) confirmPayment(ctx context.Context, orderID, actor, reason string) (*domain.Order, error) {
 	// 1. Dapatkan order
 	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
 	if err != nil {
@@ -202,111 +361,247 @@ func (s *orderServiceImpl) ConfirmPayment(ctx context.Context, orderID string) (
 		return nil, ErrOrderCannotBeConfirmed
 	}
 
-	// 3. Dapatkan item-item order
+	// 3. Update status order menjadi PAYMENT_CONFIRMED (compare-and-set) sebelum stok dikurangi,
+	//    agar tidak balapan dengan proses timeout yang melepas reservasi order yang sama.
+	newStatus := domain.StatusPaymentConfirmed
+	err = s.orderRepo.TransitionOrderStatus(ctx, domain.StatusTransition{
+		OrderID: order.ID,
+		From:    domain.StatusPendingPayment,
+		To:      newStatus,
+		Actor:   actor,
+		Reason:  reason,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderStatusConflict) {
+			logger.Warn(fmt.Sprintf("ConfirmPayment: order %s changed status concurrently", orderID))
+			return nil, fmt.Errorf("%w: %v", ErrOrderCannotBeConfirmed, err)
+		}
+		logger.Error(fmt.Sprintf("ConfirmPayment: failed to update order status for %s after payment", orderID), err, nil)
+		return nil, fmt.Errorf("failed to update order status for %s: %w", orderID, err)
+	}
+	order.Status = newStatus
+	order.UpdatedAt = time.Now()
+
+	// 4. Lanjutkan saga checkout: commit setiap reservasi aktif di gudang tempat stok direservasi.
+	// Kegagalan commit tidak membatalkan pembayaran; saga mengulangnya dan menandai STUCK jika tetap gagal.
+	if saga := s.resumeCheckoutSaga(ctx, orderID); saga != nil && saga.Status != domain.SagaStatusCompleted {
+		logger.Warn(fmt.Sprintf("ConfirmPayment: stock for order %s is not fully committed yet (saga %s is %s)", orderID, saga.ID, saga.Status))
+	}
+
+	logger.Info(fmt.Sprintf("Order %s payment confirmed. Status updated to %s.", orderID, newStatus))
+	return order, nil
+}
+
+func (s *orderServiceImpl) GetOrder(ctx context.Context, orderID string) (*domain.Order, error) {
+	return s.orderRepo.GetOrderByID(ctx, orderID)
+}
+
+// GetOrderDetails mengembalikan order beserta item-itemnya.
+func (s *orderServiceImpl) GetOrderDetails(ctx context.Context, orderID string) (*domain.Order, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
 	items, err := s.orderRepo.GetOrderItemsByOrderID(ctx, orderID)
-	if err != nil || len(items) == 0 {
-		logger.Error(fmt.Sprintf("ConfirmPayment: failed to get items for order %s or order has no items", orderID), err, nil)
-		// Mungkin update status order ke FAILED di sini jika item tidak ada
-		return nil, fmt.Errorf("failed to retrieve items for order %s: %w", orderID, err)
+	if err != nil {
+		logger.Error(fmt.Sprintf("GetOrderDetails: failed to get items for order %s", orderID), err, nil)
+		return nil, err
+	}
+	order.Items = items
+	groups, err := s.orderRepo.GetFulfillmentGroupsByOrderID(ctx, orderID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("GetOrderDetails: failed to get fulfillment groups for order %s", orderID), err, nil)
+		return nil, err
+	}
+	order.FulfillmentGroups = groups
+	return order, nil
+}
+
+// ListOrders mengembalikan satu halaman order milik filter.UserID beserta item-itemnya.
+// Item diambil dengan satu query batch untuk seluruh halaman.
+func (s *orderServiceImpl) ListOrders(ctx context.Context, filter domain.ListOrdersFilter) (*domain.ListOrdersResponse, error) {
+	if filter.UserID == "" {
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidListQuery)
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidListQuery, filter.Status)
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return nil, fmt.Errorf("%w: 'from' must be before 'to'", ErrInvalidListQuery)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultOrderListLimit
+	}
+	if filter.Limit > domain.MaxOrderListLimit {
+		filter.Limit = domain.MaxOrderListLimit
+	}
+	pageSize := filter.Limit
+
+	// Ambil satu baris ekstra untuk mengetahui apakah masih ada halaman berikutnya
+	filter.Limit = pageSize + 1
+	orders, err := s.orderRepo.ListOrdersByUserID(ctx, filter)
+	if err != nil {
+		logger.Error(fmt.Sprintf("ListOrders: failed to list orders for user %s", filter.UserID), err, nil)
+		return nil, err
 	}
 
-	productIDsInOrder := make([]string, 0, len(items))
-	itemMapByProductID := make(map[string]domain.OrderItem) // Untuk akses mudah ke quantity per item
-	for _, item := range items {
-		productIDsInOrder = append(productIDsInOrder, item.ProductID)
-		itemMapByProductID[item.ProductID] = item
+	resp := &domain.ListOrdersResponse{Orders: orders}
+	if len(orders) > pageSize {
+		resp.Orders = orders[:pageSize]
+		last := resp.Orders[pageSize-1]
+		resp.NextCursor = domain.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	if len(resp.Orders) == 0 {
+		return resp, nil
 	}
 
-	// 4. Cari gudang mana saja yang memiliki reservasi untuk produk-produk dalam order ini
-	warehouseReservations, err := s.warehouseClient.FindWarehousesWithReservations(ctx, productIDsInOrder)
+	orderIDs := make([]string, len(resp.Orders))
+	for i, o := range resp.Orders {
+		orderIDs[i] = o.ID
+	}
+	itemsByOrder, err := s.orderRepo.GetOrderItemsByOrderIDs(ctx, orderIDs)
 	if err != nil {
-		logger.Error(fmt.Sprintf("ConfirmPayment: Failed to find warehouses with reservations for order %s", orderID), err, nil)
-		// Ini bukan error fatal untuk status order, tapi stock deduction akan gagal.
-		// Kita bisa lanjutkan update status order dan catat error ini untuk investigasi.
-		// Alternatif: GAGALKAN konfirmasi jika ini tidak bisa didapatkan? Tergantung kebutuhan bisnis.
-		// Untuk simulasi, kita log dan lanjutkan, tapi tandai bahwa deduction mungkin tidak terjadi.
+		logger.Error(fmt.Sprintf("ListOrders: failed to get items for user %s", filter.UserID), err, nil)
+		return nil, err
+	}
+	for i := range resp.Orders {
+		resp.Orders[i].Items = itemsByOrder[resp.Orders[i].ID]
 	}
+	return resp, nil
+}
 
-	logger.Info(fmt.Sprintf("ConfirmPayment: Found %d potential warehouse locations with reservations for products in order %s", len(warehouseReservations), orderID))
+// CancelOrder membatalkan order atas permintaan customer.
+// - PENDING_PAYMENT: reservasi stok dilepas.
+// - PAYMENT_CONFIRMED / AWAITING_SHIPMENT: order ditandai perlu refund dan stok yang sudah dikurangi dikembalikan ke gudang asal.
+// - PARTIALLY_SHIPPED / SHIPPED / DELIVERED: ditolak dengan ErrOrderAlreadyShipped.
+// Shipment yang belum dikirim ikut dibatalkan. Restock dijalankan saga cancel_restock yang disimpan bersama pembatalan
+// dan di-retry sampai berhasil.
+// Status diubah lebih dulu (dengan syarat status belum berubah) sebelum operasi stok,
+// sehingga proses lain (misal timeout pembayaran) tidak bisa melepas stok yang sama dua kali.
+func (s *orderServiceImpl) CancelOrder(ctx context.Context, orderID string, reason string) (*domain.Order, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
 
-	// 5. Iterasi per item order, lalu iterasi per gudang yang punya reservasi untuk item tsb,
-	//    dan coba kurangi stok.
-	allDeductionsSuccessful := true
-	for _, item := range items {
-		quantityToDeductForItem := item.Quantity // Total yang perlu dikurangi untuk item ini
-		if quantityToDeductForItem <= 0 {
-			continue
-		} // Lewati jika sudah 0 (seharusnya tidak terjadi)
-
-		successfullyDeductedForThisItem := 0
-
-		// Cari semua gudang yang punya reservasi untuk item.ProductID ini
-		for _, whReservation := range warehouseReservations {
-			if whReservation.ProductID == item.ProductID && whReservation.Reserved > 0 {
-				if quantityToDeductForItem <= 0 {
-					break
-				} // Sudah cukup dikurangi untuk item ini
-
-				// Jumlah yang bisa dikurangi dari gudang ini adalah min(yang perlu dikurangi, yang direservasi di gudang ini)
-				var amountToDeductFromThisWarehouse int
-				if quantityToDeductForItem <= whReservation.Reserved {
-					amountToDeductFromThisWarehouse = quantityToDeductForItem
-				} else {
-					amountToDeductFromThisWarehouse = whReservation.Reserved
-				}
-
-				if amountToDeductFromThisWarehouse > 0 {
-					logger.Info(fmt.Sprintf("ConfirmPayment: Attempting to deduct %d of ProductID %s from WarehouseID %s for OrderID %s",
-						amountToDeductFromThisWarehouse, item.ProductID, whReservation.WarehouseID, orderID))
-
-					deductReq := warehouseDomain.DeductStockRequest{
-						ProductID:   item.ProductID,
-						Quantity:    amountToDeductFromThisWarehouse,
-						WarehouseID: whReservation.WarehouseID,
-					}
-					err := s.warehouseClient.DeductStock(ctx, deductReq) // Memanggil client
-					if err != nil {
-						logger.Error(fmt.Sprintf("ConfirmPayment: Failed to deduct stock for ProductID %s from WarehouseID %s (Order %s)",
-							item.ProductID, whReservation.WarehouseID, orderID), err, nil)
-						allDeductionsSuccessful = false
-						// PENTING: Lanjutkan ke gudang lain atau item lain. JANGAN return error di sini.
-						// Kita ingin mencoba mengurangi sebanyak mungkin.
-					} else {
-						logger.Info(fmt.Sprintf("ConfirmPayment: Successfully deducted %d of ProductID %s from WarehouseID %s",
-							amountToDeductFromThisWarehouse, item.ProductID, whReservation.WarehouseID))
-						quantityToDeductForItem -= amountToDeductFromThisWarehouse
-						successfullyDeductedForThisItem += amountToDeductFromThisWarehouse
-					}
-				}
-			}
+	switch {
+	case order.Status == domain.StatusPartiallyShipped || order.Status == domain.StatusShipped || order.Status == domain.StatusDelivered:
+		return nil, ErrOrderAlreadyShipped
+	case !order.Status.CanTransitionTo(domain.StatusCancelled):
+		return nil, fmt.Errorf("%w: %s", ErrOrderCannotBeCancelled, order.Status)
+	}
+	// Stok sudah dikurangi setelah pembayaran dikonfirmasi, sehingga perlu restock dan refund
+	refundRequired := order.Status != domain.StatusPendingPayment
+	var restockSaga *domain.Saga
+	if refundRequired {
+		if restockSaga, err = s.sagas.Prepare(domain.SagaTypeCancelRestock, orderID, struct{}{}); err != nil {
+			return nil, err
 		}
-		// Setelah mencoba semua warehouse untuk item ini:
-		if quantityToDeductForItem > 0 {
-			logger.Error(fmt.Sprintf("ConfirmPayment: Could not fully deduct stock for ProductID %s (Order %s). Needed %d, remaining to deduct %d.",
-				item.ProductID, orderID, item.Quantity, quantityToDeductForItem), nil, nil)
-			allDeductionsSuccessful = false
+	}
+
+	transition := domain.StatusTransition{
+		OrderID: orderID,
+		From:    order.Status,
+		To:      domain.StatusCancelled,
+		Actor:   actorFromContext(ctx),
+		Reason:  reason,
+	}
+	if err := s.orderRepo.CancelOrder(ctx, transition, refundRequired, restockSaga); err != nil {
+		if errors.Is(err, repository.ErrOrderStatusConflict) {
+			return nil, fmt.Errorf("%w: %v", ErrOrderCannotBeCancelled, err)
 		}
+		logger.Error(fmt.Sprintf("CancelOrder: failed to update order %s", orderID), err, nil)
+		return nil, err
 	}
 
-	if !allDeductionsSuccessful {
-		// Log error/warning tingkat tinggi bahwa tidak semua stok berhasil dikurangi
-		logger.Error(fmt.Sprintf("ConfirmPayment: One or more stock deductions failed for OrderID %s. Manual review may be needed.", orderID), nil, nil)
-		// Bergantung pada kebijakan bisnis, ini bisa jadi alasan untuk TIDAK mengupdate status ke PAYMENT_CONFIRMED
-		// atau memindahkannya ke status khusus "PARTIALLY_ALLOCATED" atau "AWAITING_STOCK_VERIFICATION".
-		// UNTUK SIMULASI INI, kita tetap lanjutkan update status, tapi dengan catatan.
+	if restockSaga != nil {
+		// Restock yang gagal dijadwalkan ulang oleh saga; kegagalan di sini hanya berarti statusnya belum tersimpan
+		if err := s.sagas.Run(ctx, restockSaga); err != nil {
+			logger.Error(fmt.Sprintf("CancelOrder: failed to run restock saga for order %s, it will be resumed by the scheduler", orderID), err, nil)
+		}
+	} else {
+		s.resumeCheckoutSaga(ctx, orderID) // Saga checkout melepas reservasi stok
 	}
 
-	// 6. Update status order menjadi PAYMENT_CONFIRMED
-	newStatus := domain.StatusPaymentConfirmed
-	err = s.orderRepo.UpdateOrderStatus(ctx, order.ID, newStatus)
+	now := time.Now()
+	order.Status = domain.StatusCancelled
+	order.RefundRequired = refundRequired
+	order.CancelledAt = &now
+	order.UpdatedAt = now
+	if reason != "" {
+		order.CancellationReason = &reason
+	}
+	logger.Info(fmt.Sprintf("Order %s cancelled (refund required: %t).", orderID, refundRequired))
+	return order, nil
+}
+
+// releaseOrderReservations melepas semua reservasi aktif milik order.
+func (s *orderServiceImpl) releaseOrderReservations(ctx context.Context, orderID string) error {
+	reservations, err := s.warehouseClient.ListReservations(ctx, orderID)
 	if err != nil {
-		logger.Error(fmt.Sprintf("ConfirmPayment: failed to update order status for %s after payment", orderID), err, nil)
-		// Pembayaran berhasil tapi status gagal update. Perlu mekanisme retry atau alert.
-		return nil, fmt.Errorf("failed to update order status for %s: %w", orderID, err)
+		logger.Error(fmt.Sprintf("Failed to list reservations to release stock for order %s", orderID), err, nil)
+		return err
 	}
+	failed := 0
+	var lastErr error
+	for _, res := range reservations {
+		if res.Status != warehouseDomain.ReservationStatusActive {
+			continue
+		}
+		logger.Info(fmt.Sprintf("Releasing reservation %s (ProductID: %s, Quantity: %d, Order: %s)", res.ID, res.ProductID, res.Quantity, orderID))
+		if err := s.warehouseClient.ReleaseReservation(ctx, res.ID); err != nil {
+			logger.Error(fmt.Sprintf("Failed to release reservation %s for ProductID: %s, OrderID: %s", res.ID, res.ProductID, orderID), err, nil)
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to release %d reservation(s) of order %s: %w", failed, orderID, lastErr)
+	}
+	return nil
+}
 
-	order.Status = newStatus
-	order.UpdatedAt = time.Now() // Seharusnya dihandle oleh UpdateOrderStatus di repo
-	logger.Info(fmt.Sprintf("Order %s payment confirmed. Status updated to %s.", orderID, newStatus))
-	return order, nil
+// resumeCheckoutSaga melanjutkan saga checkout order setelah status pembayarannya berubah.
+// Kegagalan hanya dicatat: saga yang belum selesai dilanjutkan oleh ResumeSagas.
+func (s *orderServiceImpl) resumeCheckoutSaga(ctx context.Context, orderID string) *domain.Saga {
+	saga, err := s.sagas.Signal(ctx, domain.SagaTypeCheckout, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSagaNotFound) {
+			logger.Warn(fmt.Sprintf("No checkout saga found for order %s", orderID))
+			return nil
+		}
+		logger.Error(fmt.Sprintf("Failed to resume checkout saga for order %s", orderID), err, nil)
+	}
+	return saga
+}
+
+func (s *orderServiceImpl) ListSagas(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error) {
+	return s.sagas.List(ctx, filter)
+}
+
+func (s *orderServiceImpl) RetrySaga(ctx context.Context, sagaID string) (*domain.Saga, error) {
+	return s.sagas.Retry(ctx, sagaID)
+}
+
+func (s *orderServiceImpl) ResumeSagas(ctx context.Context) {
+	if n := s.sagas.ResumeDue(ctx); n > 0 {
+		logger.Info(fmt.Sprintf("ResumeSagas: processed %d due sagas", n))
+	}
+}
+
+func (s *orderServiceImpl) GetOrderHistory(ctx context.Context, orderID string) ([]domain.OrderStatusHistory, error) {
+	if _, err := s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetOrderStatusHistory(ctx, orderID)
+}
+
+// actorFromContext menentukan actor untuk riwayat status: "<role>:<user_id>" jika request membawa identitas,
+// atau "system" untuk proses internal.
+func actorFromContext(ctx context.Context) string {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || identity.UserID == "" {
+		return domain.ActorSystem
+	}
+	return identity.Role + ":" + identity.UserID
 }
@@ -8,6 +8,8 @@ import (
 
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
 	oRepo "github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
 
 	// mocks for order repo
 	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository/mocks"
@@ -18,14 +20,65 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// transition mencocokkan argumen StatusTransition berdasarkan order dan perpindahan statusnya.
+func transition(orderID string, from, to domain.OrderStatus) interface{} {
+	return mock.MatchedBy(func(t domain.StatusTransition) bool {
+		return t.OrderID == orderID && t.From == from && t.To == to && t.Actor != ""
+	})
+}
+
+func idr(amount int64) money.Money {
+	return money.New(amount, money.IDR)
+}
+
+func idrPtr(amount int64) *money.Money {
+	m := idr(amount)
+	return &m
+}
+
+// allowSagaPersistence mengizinkan saga disimpan tanpa memeriksa setiap penyimpanan;
+// urutan status saga diuji di saga_orchestrator_test.go.
+func allowSagaPersistence(repo *mocks.MockOrderRepository) {
+	repo.On("CreateSaga", mock.Anything, mock.AnythingOfType("*domain.Saga")).Return(nil).Maybe()
+	repo.On("UpdateSaga", mock.Anything, mock.AnythingOfType("*domain.Saga")).Return(nil).Maybe()
+}
+
+// waitingCheckoutSaga adalah saga checkout yang stoknya sudah direservasi dan ordernya menunggu pembayaran.
+func waitingCheckoutSaga(orderID string) *domain.Saga {
+	return &domain.Saga{
+		ID:          "saga-" + orderID,
+		Type:        domain.SagaTypeCheckout,
+		OrderID:     orderID,
+		Status:      domain.SagaStatusWaiting,
+		CurrentStep: 2,
+		Steps: []domain.SagaStepState{
+			{Name: domain.SagaStepReserveStock, Status: domain.SagaStepSucceeded, Attempts: 1},
+			{Name: domain.SagaStepCreateOrder, Status: domain.SagaStepSucceeded, Attempts: 1},
+			{Name: domain.SagaStepPayment, Status: domain.SagaStepPending, Attempts: 1},
+			{Name: domain.SagaStepCommitStock, Status: domain.SagaStepPending},
+			{Name: domain.SagaStepCreateShipments, Status: domain.SagaStepPending},
+		},
+		Version: 3,
+	}
+}
+
+// cancelRestockSaga mencocokkan saga restock yang disimpan bersama pembatalan order.
+func cancelRestockSaga(orderID string) interface{} {
+	return mock.MatchedBy(func(s *domain.Saga) bool {
+		return s != nil && s.Type == domain.SagaTypeCancelRestock && s.OrderID == orderID && s.Status == domain.SagaStatusRunning
+	})
+}
+
 func TestOrderService_CreateOrder(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
+	mockProductClient := new(whClientOrderMocks.MockProductClientForOrder)
+	allowSagaPersistence(mockOrderRepo)
 	paymentTimeout := 1 * time.Minute
 	// NewOrderService tidak menginisialisasi scheduler secara langsung yang mudah di-mock
 	// tapi ia memanggil s.initScheduler() yang menggunakan cron.New().
 	// Untuk unit test CreateOrder, scheduler tidak terlalu relevan.
-	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, paymentTimeout)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, mockProductClient, NewFakePaymentProvider("test-secret", ""), paymentTimeout)
 	// Hentikan scheduler yang mungkin dimulai oleh NewOrderService agar tidak mengganggu tes lain
 	// Anda bisa membuat `orderServiceImpl` memiliki metode `StopScheduler()` atau mengembalikan `*cron.Cron` dari `NewOrderService`
 	// Untuk contoh ini, kita asumsikan bisa mengabaikannya jika tidak ada interaksi langsung.
@@ -40,34 +93,91 @@ func TestOrderService_CreateOrder(t *testing.T) {
 	createOrderReq := domain.CreateOrderRequest{
 		UserID: "user123",
 		Items: []domain.CreateOrderItemRequest{
-			{ProductID: "prod1", Quantity: 2, Price: 10.0},
-			{ProductID: "prod2", Quantity: 1, Price: 25.0},
+			{ProductID: "prod1", Quantity: 2, Price: idrPtr(10000)},
+			{ProductID: "prod2", Quantity: 1, Price: idrPtr(25000)},
 		},
 	}
 
+	productIDs := []string{"prod1", "prod2"}
+	catalogPrices := []productDomain.ProductPrice{
+		{ProductID: "prod1", SKU: "SKU-1", Name: "Product 1", Price: idr(10000)},
+		{ProductID: "prod2", SKU: "SKU-2", Name: "Product 2", Price: idr(25000)},
+	}
+
+	reservationTTL := paymentTimeout + reservationExpiryGrace
+	batchItems := []whDomain.ReserveStockBatchItem{
+		{ProductID: "prod1", Quantity: 2},
+		{ProductID: "prod2", Quantity: 1},
+	}
+	reservedLines := []whDomain.ReservationLine{
+		{ProductID: "prod1", Quantity: 2, Reservations: []whDomain.StockReservation{
+			{ID: "res-1", ProductID: "prod1", WarehouseID: "wh1", Quantity: 2, Status: whDomain.ReservationStatusActive},
+		}},
+		{ProductID: "prod2", Quantity: 1, Reservations: []whDomain.StockReservation{
+			{ID: "res-2", ProductID: "prod2", WarehouseID: "wh1", Quantity: 1, Status: whDomain.ReservationStatusActive},
+		}},
+	}
+
 	t.Run("Successful order creation", func(t *testing.T) {
-		mockWhClient.On("ReserveStock", ctx, "prod1", 2).Return(nil).Once()
-		mockWhClient.On("ReserveStock", ctx, "prod2", 1).Return(nil).Once()
-		mockOrderRepo.On("CreateOrderWithItems", ctx, mock.AnythingOfType("*domain.Order"), mock.AnythingOfType("[]domain.OrderItem")).Return(nil).Once()
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(catalogPrices, nil).Once()
+		mockOrderRepo.On("NextOrderID", ctx).Return("order-new-1", nil).Once()
+		mockWhClient.On("ListReservations", ctx, "order-new-1").Return([]whDomain.StockReservation{}, nil).Once()
+		mockWhClient.On("ReserveStockBatch", ctx, "order-new-1", batchItems, reservationTTL).Return(reservedLines, nil).Once()
+		// Fulfillment group diturunkan dari reservasi yang tersimpan di Warehouse Service
+		mockWhClient.On("ListReservations", ctx, "order-new-1").Return([]whDomain.StockReservation{
+			reservedLines[0].Reservations[0], reservedLines[1].Reservations[0],
+		}, nil).Once()
+		mockOrderRepo.On("CreateOrderWithItems", ctx, mock.MatchedBy(func(o *domain.Order) bool {
+			return o.ID == "order-new-1" && o.UserID == "user123" && o.TotalAmount.Equal(idr(45000)) && o.Status == domain.StatusPendingPayment &&
+				len(o.FulfillmentGroups) == 1 && o.FulfillmentGroups[0].WarehouseID == "wh1" && len(o.FulfillmentGroups[0].Items) == 2 &&
+				o.FulfillmentGroups[0].Items[0].SKU == "SKU-1"
+		}), mock.MatchedBy(func(items []domain.OrderItem) bool {
+			// Harga dan snapshot nama/SKU diambil dari Product Service
+			return len(items) == 2 && items[0].ProductName == "Product 1" && items[0].SKU == "SKU-1" && items[0].PriceAtPurchase.Equal(idr(10000)) &&
+				items[1].ProductName == "Product 2" && items[1].SKU == "SKU-2" && items[1].PriceAtPurchase.Equal(idr(25000))
+		})).Return(nil).Once()
+		// Langkah payment memeriksa status order, lalu order dimuat ulang untuk response
+		savedOrder := &domain.Order{ID: "order-new-1", UserID: "user123", TotalAmount: idr(45000), Status: domain.StatusPendingPayment}
+		mockOrderRepo.On("GetOrderByID", ctx, "order-new-1").Return(savedOrder, nil).Twice()
+		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, "order-new-1").Return([]domain.OrderItem{
+			{ID: "item-1", OrderID: "order-new-1", ProductID: "prod1", Quantity: 2, PriceAtPurchase: idr(10000)},
+			{ID: "item-2", OrderID: "order-new-1", ProductID: "prod2", Quantity: 1, PriceAtPurchase: idr(25000)},
+		}, nil).Once()
+		mockOrderRepo.On("GetFulfillmentGroupsByOrderID", ctx, "order-new-1").Return([]domain.FulfillmentGroup{
+			{ID: "group-1", WarehouseID: "wh1", Sequence: 1, Items: []domain.FulfillmentGroupItem{
+				{ProductID: "prod1", Quantity: 2}, {ProductID: "prod2", Quantity: 1},
+			}},
+		}, nil).Once()
+		// Payment intent dibuat di provider untuk total order
+		mockOrderRepo.On("GetPaymentsByOrderID", ctx, "order-new-1").Return([]domain.Payment{}, nil).Once()
+		mockOrderRepo.On("CreatePayment", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+			return p.OrderID == "order-new-1" && p.Provider == FakeProviderName && p.ProviderPaymentID != "" &&
+				p.Amount.Equal(idr(45000)) && p.Status == domain.PaymentStatusPending
+		})).Return(nil).Once()
 
 		resp, err := orderServiceInstance.CreateOrder(ctx, createOrderReq)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
-		assert.Equal(t, "mock-order-id", resp.ID) // ID dari mock
+		assert.Equal(t, "order-new-1", resp.ID) // ID yang dipakai untuk reservasi
 		assert.Equal(t, domain.StatusPendingPayment, resp.Status)
-		assert.Equal(t, (2*10.0)+(1*25.0), resp.TotalAmount)
+		assert.Equal(t, idr(45000), resp.TotalAmount) // Dijumlahkan tepat dalam minor unit
+		assert.Len(t, resp.Items, 2)
+		assert.Len(t, resp.FulfillmentGroups, 1)
+		if assert.NotNil(t, resp.Payment) {
+			assert.NotEmpty(t, resp.Payment.CheckoutURL)
+		}
 		mockOrderRepo.AssertExpectations(t)
 		mockWhClient.AssertExpectations(t)
 	})
 
-	t.Run("Stock reservation failed for one item, ensure rollback", func(t *testing.T) {
-		mockWhClient.On("ReserveStock", ctx, "prod1", 2).Return(nil).Once()                             // Sukses item pertama
-		mockWhClient.On("ReserveStock", ctx, "prod2", 1).Return(errors.New("stock unavailable")).Once() // Gagal item kedua
-
-		// Expect ReleaseStock to be called for the successfully reserved item ("prod1")
-		// context.Background() digunakan di service untuk rollback
-		mockWhClient.On("ReleaseStock", context.Background(), "prod1", 2).Return(nil).Once()
+	t.Run("Stock reservation failed for one item, nothing to roll back", func(t *testing.T) {
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(catalogPrices, nil).Once()
+		mockOrderRepo.On("NextOrderID", ctx).Return("order-new-2", nil).Once()
+		mockWhClient.On("ListReservations", ctx, "order-new-2").Return([]whDomain.StockReservation{}, nil).Once()
+		// Warehouse Service menolak seluruh batch; tidak ada reservasi yang perlu dilepas
+		warehouseErr := &WarehouseStatusError{Op: "ReserveStockBatch", StatusCode: 409, Message: "Failed to reserve stock: insufficient stock: product_id prod2, quantity 1"}
+		mockWhClient.On("ReserveStockBatch", ctx, "order-new-2", batchItems, reservationTTL).Return(nil, warehouseErr).Once()
 
 		resp, err := orderServiceInstance.CreateOrder(ctx, createOrderReq)
 
@@ -76,16 +186,28 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		assert.ErrorIs(t, err, ErrStockReservationFailed)
 		assert.Contains(t, err.Error(), "prod2") // Error message should mention the failing product
 		mockWhClient.AssertExpectations(t)
+		mockWhClient.AssertNotCalled(t, "ReleaseReservation", mock.Anything, mock.Anything)
+		mockOrderRepo.AssertNumberOfCalls(t, "CreateOrderWithItems", 1) // Hanya dari subtest sebelumnya
 	})
 
 	t.Run("CreateOrderWithItems fails after stock reservation", func(t *testing.T) {
-		mockWhClient.On("ReserveStock", ctx, "prod1", 2).Return(nil).Once()
-		mockWhClient.On("ReserveStock", ctx, "prod2", 1).Return(nil).Once()
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(catalogPrices, nil).Once()
+		mockOrderRepo.On("NextOrderID", ctx).Return("order-new-3", nil).Once()
+		mockWhClient.On("ListReservations", ctx, "order-new-3").Return([]whDomain.StockReservation{}, nil).Once()
+		mockWhClient.On("ReserveStockBatch", ctx, "order-new-3", batchItems, reservationTTL).Return(reservedLines, nil).Once()
+		mockWhClient.On("ListReservations", ctx, "order-new-3").Return([]whDomain.StockReservation{
+			reservedLines[0].Reservations[0], reservedLines[1].Reservations[0],
+		}, nil).Once()
 		repoErr := errors.New("db transaction error")
 		mockOrderRepo.On("CreateOrderWithItems", ctx, mock.AnythingOfType("*domain.Order"), mock.AnythingOfType("[]domain.OrderItem")).Return(repoErr).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, "order-new-3").Return(nil, oRepo.ErrOrderNotFound).Once()
 
-		// Service tidak melakukan rollback eksplisit di sini, mengandalkan payment timeout
-		// "Stok yang sudah direservasi akan "tergantung". Mekanisme timeout harus menangani ini."
+		// Order tidak tersimpan, sehingga saga melepas kembali semua reservasi
+		mockWhClient.On("ListReservations", ctx, "order-new-3").Return([]whDomain.StockReservation{
+			reservedLines[0].Reservations[0], reservedLines[1].Reservations[0],
+		}, nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-1").Return(nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-2").Return(nil).Once()
 
 		resp, err := orderServiceInstance.CreateOrder(ctx, createOrderReq)
 
@@ -96,12 +218,74 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		mockOrderRepo.AssertExpectations(t)
 		mockWhClient.AssertExpectations(t)
 	})
+
+	t.Run("Outdated client price is rejected with a quote", func(t *testing.T) {
+		req := domain.CreateOrderRequest{
+			UserID: "user123",
+			Items: []domain.CreateOrderItemRequest{
+				{ProductID: "prod1", Quantity: 2}, // Tanpa harga: langsung memakai harga katalog
+				{ProductID: "prod2", Quantity: 1, Price: idrPtr(1000)},
+			},
+		}
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(catalogPrices, nil).Once()
+
+		resp, err := orderServiceInstance.CreateOrder(ctx, req)
+
+		assert.Nil(t, resp)
+		assert.ErrorIs(t, err, ErrPriceChanged)
+		var priceErr *PriceChangedError
+		assert.True(t, errors.As(err, &priceErr))
+		assert.Equal(t, []domain.PriceQuote{
+			{ProductID: "prod1", Name: "Product 1", SKU: "SKU-1", Quantity: 2, CurrentPrice: idr(10000)},
+			{ProductID: "prod2", Name: "Product 2", SKU: "SKU-2", Quantity: 1, RequestedPrice: idrPtr(1000), CurrentPrice: idr(25000)},
+		}, priceErr.Quote)
+		mockOrderRepo.AssertNumberOfCalls(t, "NextOrderID", 3) // Tidak ada order atau reservasi baru
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("Unknown product is rejected", func(t *testing.T) {
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(catalogPrices[:1], nil).Once()
+
+		resp, err := orderServiceInstance.CreateOrder(ctx, createOrderReq)
+
+		assert.Nil(t, resp)
+		assert.ErrorIs(t, err, ErrUnknownProduct)
+		assert.Contains(t, err.Error(), "prod2")
+		mockOrderRepo.AssertNumberOfCalls(t, "NextOrderID", 3)
+	})
+
+	t.Run("Items priced in different currencies are rejected", func(t *testing.T) {
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return([]productDomain.ProductPrice{
+			catalogPrices[0],
+			{ProductID: "prod2", SKU: "SKU-2", Name: "Product 2", Price: money.MustParse("1.50", money.USD)},
+		}, nil).Once()
+
+		resp, err := orderServiceInstance.CreateOrder(ctx, domain.CreateOrderRequest{UserID: "user123", Items: []domain.CreateOrderItemRequest{
+			{ProductID: "prod1", Quantity: 1},
+			{ProductID: "prod2", Quantity: 1},
+		}})
+
+		assert.Nil(t, resp)
+		assert.ErrorIs(t, err, ErrMixedCurrencies)
+		mockOrderRepo.AssertNumberOfCalls(t, "NextOrderID", 3)
+	})
+
+	t.Run("Product Service unavailable", func(t *testing.T) {
+		mockProductClient.On("GetProductPrices", ctx, productIDs).Return(nil, errors.New("connection refused")).Once()
+
+		resp, err := orderServiceInstance.CreateOrder(ctx, createOrderReq)
+
+		assert.Nil(t, resp)
+		assert.ErrorIs(t, err, ErrPriceLookupFailed)
+		mockOrderRepo.AssertNumberOfCalls(t, "NextOrderID", 3)
+	})
 }
 
 func TestOrderService_ConfirmPayment(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
-	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, 1*time.Minute)
+	allowSagaPersistence(mockOrderRepo)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), 1*time.Minute)
 	// if osImpl, ok := orderServiceInstance.(*orderServiceImpl); ok && osImpl.scheduler != nil { osImpl.scheduler.Stop() }
 
 	ctx := context.TODO()
@@ -110,37 +294,143 @@ func TestOrderService_ConfirmPayment(t *testing.T) {
 		ID:          orderID,
 		UserID:      "user1",
 		Status:      domain.StatusPendingPayment,
-		TotalAmount: 50.0,
+		TotalAmount: idr(50000),
 	}
 	mockOrderItems := []domain.OrderItem{
-		{ID: "item1", ProductID: "prodA", Quantity: 1, PriceAtPurchase: 20.0},
-		{ID: "item2", ProductID: "prodB", Quantity: 2, PriceAtPurchase: 15.0},
+		{ID: "item1", ProductID: "prodA", Quantity: 1, PriceAtPurchase: idr(20000)},
+		{ID: "item2", ProductID: "prodB", Quantity: 2, PriceAtPurchase: idr(15000)},
+	}
+	mockReservations := []whDomain.StockReservation{
+		{ID: "res-a", OrderID: &orderID, ProductID: "prodA", WarehouseID: "wh1", Quantity: 1, Status: whDomain.ReservationStatusActive},
+		{ID: "res-b", OrderID: &orderID, ProductID: "prodB", WarehouseID: "wh2", Quantity: 2, Status: whDomain.ReservationStatusActive},
 	}
-	mockReservations := []whDomain.ProductWarehouseReservationInfo{
-		{ProductID: "prodA", WarehouseID: "wh1", Reserved: 1},
-		{ProductID: "prodB", WarehouseID: "wh1", Reserved: 2},
+	committed := func(r whDomain.StockReservation) *whDomain.StockReservation {
+		r.Status = whDomain.ReservationStatusCommitted
+		return &r
 	}
 
+	paidOrder := &domain.Order{ID: orderID, UserID: "user1", Status: domain.StatusPaymentConfirmed, TotalAmount: idr(50000)}
+
 	t.Run("Successful payment confirmation", func(t *testing.T) {
+		saga := waitingCheckoutSaga(orderID)
 		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(mockPendingOrder, nil).Once()
-		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, orderID).Return(mockOrderItems, nil).Once()
-		mockWhClient.On("FindWarehousesWithReservations", ctx, []string{"prodA", "prodB"}).Return(mockReservations, nil).Once()
-		// Deduct stock for each item from its reserved warehouse
-		deductReqA := whDomain.DeductStockRequest{ProductID: "prodA", Quantity: 1, WarehouseID: "wh1"}
-		deductReqB := whDomain.DeductStockRequest{ProductID: "prodB", Quantity: 2, WarehouseID: "wh1"}
-		mockWhClient.On("DeductStock", ctx, deductReqA).Return(nil).Once()
-		mockWhClient.On("DeductStock", ctx, deductReqB).Return(nil).Once()
-		mockOrderRepo.On("UpdateOrderStatus", ctx, orderID, domain.StatusPaymentConfirmed).Return(nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(orderID, domain.StatusPendingPayment, domain.StatusPaymentConfirmed)).Return(nil).Once()
+		// Saga checkout dilanjutkan: langkah payment, commit_stock dan create_shipments membaca status order terbaru
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, orderID).Return(saga, nil).Once()
+		// payment, commit_stock (sebelum dan sesudah commit), dan create_shipments
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(paidOrder, nil).Times(4)
+		mockWhClient.On("ListReservations", ctx, orderID).Return(append([]whDomain.StockReservation(nil), mockReservations...), nil).Once()
+		// Setiap reservasi di-commit di gudang tempat stok direservasi
+		mockWhClient.On("CommitReservation", ctx, "res-a").Return(committed(mockReservations[0]), nil).Once()
+		mockWhClient.On("CommitReservation", ctx, "res-b").Return(committed(mockReservations[1]), nil).Once()
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, orderID).Return([]domain.StockDeduction{}, nil).Once()
+		mockOrderRepo.On("RecordStockDeduction", ctx, mock.MatchedBy(func(d *domain.StockDeduction) bool {
+			return d.OrderID == orderID && d.WarehouseID == "wh1" && d.ProductID == "prodA" && d.Quantity == 1
+		})).Return(nil).Once()
+		mockOrderRepo.On("RecordStockDeduction", ctx, mock.MatchedBy(func(d *domain.StockDeduction) bool {
+			return d.OrderID == orderID && d.WarehouseID == "wh2" && d.ProductID == "prodB" && d.Quantity == 2
+		})).Return(nil).Once()
+		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, orderID).Return(mockOrderItems, nil).Twice()
+		// Satu shipment per gudang asal stok, dibuat bersama transisi ke AWAITING_SHIPMENT
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, orderID).Return([]domain.StockDeduction{
+			{OrderID: orderID, ProductID: "prodA", WarehouseID: "wh1", Quantity: 1},
+			{OrderID: orderID, ProductID: "prodB", WarehouseID: "wh2", Quantity: 2},
+		}, nil).Once()
+		mockOrderRepo.On("CreateShipments", ctx, transition(orderID, domain.StatusPaymentConfirmed, domain.StatusAwaitingShipment),
+			mock.MatchedBy(func(shipments []domain.Shipment) bool {
+				return len(shipments) == 2 &&
+					shipments[0].WarehouseID == "wh1" && shipments[0].Items[0].ProductID == "prodA" && shipments[0].Items[0].Quantity == 1 &&
+					shipments[1].WarehouseID == "wh2" && shipments[1].Items[0].ProductID == "prodB" && shipments[1].Items[0].Quantity == 2
+			})).Return(nil).Once()
 
 		order, err := orderServiceInstance.ConfirmPayment(ctx, orderID)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
 		assert.Equal(t, domain.StatusPaymentConfirmed, order.Status)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertExpectations(t)
+	})
+
+	t.Run("Failed commit is retried by the saga instead of failing the payment", func(t *testing.T) {
+		saga := waitingCheckoutSaga(orderID)
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(&domain.Order{ID: orderID, UserID: "user1", Status: domain.StatusPendingPayment}, nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(orderID, domain.StatusPendingPayment, domain.StatusPaymentConfirmed)).Return(nil).Once()
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, orderID).Return(saga, nil).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(paidOrder, nil).Twice()
+		mockWhClient.On("ListReservations", ctx, orderID).Return(append([]whDomain.StockReservation(nil), mockReservations...), nil).Once()
+		mockWhClient.On("CommitReservation", ctx, "res-a").Return(committed(mockReservations[0]), nil).Once()
+		mockWhClient.On("CommitReservation", ctx, "res-b").Return(nil, errors.New("warehouse unavailable")).Once()
+		// Reservasi yang berhasil di-commit tetap dicatat, sehingga percobaan berikutnya tidak mencatatnya dua kali
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, orderID).Return([]domain.StockDeduction{}, nil).Once()
+		mockOrderRepo.On("RecordStockDeduction", ctx, mock.MatchedBy(func(d *domain.StockDeduction) bool {
+			return d.WarehouseID == "wh1" && d.ProductID == "prodA" && d.Quantity == 1
+		})).Return(nil).Once()
+
+		order, err := orderServiceInstance.ConfirmPayment(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusPaymentConfirmed, order.Status)
+		assert.Equal(t, domain.SagaStatusRunning, saga.Status)
+		assert.Equal(t, 3, saga.CurrentStep) // Masih di commit_stock
+		assert.True(t, saga.NextAttemptAt.After(time.Now()))
+		assert.Contains(t, *saga.LastError, "warehouse unavailable")
 		mockOrderRepo.AssertExpectations(t)
 		mockWhClient.AssertExpectations(t)
 	})
 
+	t.Run("Order cancelled while stock is being committed is restocked", func(t *testing.T) {
+		saga := waitingCheckoutSaga(orderID)
+		cancelledOrder := &domain.Order{ID: orderID, UserID: "user1", Status: domain.StatusCancelled, RefundRequired: true}
+		committedReservations := []whDomain.StockReservation{*committed(mockReservations[0]), *committed(mockReservations[1])}
+		recorded := []domain.StockDeduction{
+			{ID: "ded-a", OrderID: orderID, ProductID: "prodA", WarehouseID: "wh1", Quantity: 1},
+			{ID: "ded-b", OrderID: orderID, ProductID: "prodB", WarehouseID: "wh2", Quantity: 2},
+		}
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(&domain.Order{ID: orderID, UserID: "user1", Status: domain.StatusPendingPayment}, nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(orderID, domain.StatusPendingPayment, domain.StatusPaymentConfirmed)).Return(nil).Once()
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, orderID).Return(saga, nil).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(paidOrder, nil).Twice() // payment dan awal commit_stock
+		mockWhClient.On("ListReservations", ctx, orderID).Return(append([]whDomain.StockReservation(nil), mockReservations...), nil).Once()
+		mockWhClient.On("CommitReservation", ctx, "res-a").Return(committed(mockReservations[0]), nil).Once()
+		mockWhClient.On("CommitReservation", ctx, "res-b").Return(committed(mockReservations[1]), nil).Once()
+		// Saga cancel_restock sudah selesai sebelum pengurangan ini tercatat
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, orderID).Return([]domain.StockDeduction{}, nil).Once()
+		mockOrderRepo.On("RecordStockDeduction", ctx, mock.AnythingOfType("*domain.StockDeduction")).Return(nil).Twice()
+		// Order dibatalkan selama commit: pengurangan yang baru tercatat dikembalikan ke gudang asalnya
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(cancelledOrder, nil).Once()
+		mockWhClient.On("ListReservations", ctx, orderID).Return(committedReservations, nil).Twice()
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, orderID).Return(recorded, nil).Twice()
+		mockWhClient.On("RestockStock", ctx, "ded-a", "wh1", "prodA", 1).Return(nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-b", "wh2", "prodB", 2).Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-a").Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-b").Return(nil).Once()
+		// create_shipments melewati order yang dibatalkan
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(cancelledOrder, nil).Once()
+
+		_, err := orderServiceInstance.ConfirmPayment(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertExpectations(t)
+	})
+
+	t.Run("Lost race against payment timeout", func(t *testing.T) {
+		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(&domain.Order{ID: orderID, Status: domain.StatusPendingPayment}, nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(orderID, domain.StatusPendingPayment, domain.StatusPaymentConfirmed)).
+			Return(oRepo.ErrOrderStatusConflict).Once()
+
+		order, err := orderServiceInstance.ConfirmPayment(ctx, orderID)
+		assert.ErrorIs(t, err, ErrOrderCannotBeConfirmed)
+		assert.Nil(t, order)
+		mockOrderRepo.AssertExpectations(t)
+		// Stok tidak boleh dikurangi untuk order yang sudah di-timeout
+		mockWhClient.AssertNumberOfCalls(t, "CommitReservation", 6) // Hanya dari subtest sebelumnya
+		mockOrderRepo.AssertNumberOfCalls(t, "GetSagaByOrderID", 3) // Saga tidak dilanjutkan
+	})
+
 	t.Run("Order not found", func(t *testing.T) {
 		mockOrderRepo.On("GetOrderByID", ctx, orderID).Return(nil, oRepo.ErrOrderNotFound).Once()
 
@@ -149,9 +439,8 @@ func TestOrderService_ConfirmPayment(t *testing.T) {
 		assert.Nil(t, order)
 		assert.ErrorIs(t, err, ErrOrderCannotBeConfirmed)
 		mockOrderRepo.AssertExpectations(t)
-		mockWhClient.AssertNotCalled(t, "FindWarehousesWithReservations")
-		mockWhClient.AssertNotCalled(t, "DeductStock")
-		mockOrderRepo.AssertNotCalled(t, "UpdateOrderStatus")
+		mockWhClient.AssertNumberOfCalls(t, "ListReservations", 5) // Hanya dari subtest sebelumnya
+		mockOrderRepo.AssertNotCalled(t, "TransitionOrderStatus")
 	})
 
 	t.Run("Order not in PENDING_PAYMENT status", func(t *testing.T) {
@@ -170,27 +459,41 @@ func TestOrderService_ProcessPaymentTimeouts(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
 	timeoutDuration := 30 * time.Minute
-	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, timeoutDuration)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), timeoutDuration)
+	allowSagaPersistence(mockOrderRepo)
 	// if osImpl, ok := orderServiceInstance.(*orderServiceImpl); ok && osImpl.scheduler != nil { osImpl.scheduler.Stop() }
 
 	ctx := context.Background() // Sesuai penggunaan di service
 	pendingOrder1 := domain.Order{ID: "timeout1", UserID: "userA", Status: domain.StatusPendingPayment, CreatedAt: time.Now().Add(-timeoutDuration * 2)}
-	itemsForOrder1 := []domain.OrderItem{
-		{ProductID: "prodX", Quantity: 1},
-		{ProductID: "prodY", Quantity: 2},
+	reservationsForOrder1 := []whDomain.StockReservation{
+		{ID: "res-x", ProductID: "prodX", WarehouseID: "wh1", Quantity: 1, Status: whDomain.ReservationStatusActive},
+		{ID: "res-y", ProductID: "prodY", WarehouseID: "wh2", Quantity: 2, Status: whDomain.ReservationStatusActive},
+		// Reservasi yang sudah dilepas (misal oleh sweeper) tidak dilepas ulang
+		{ID: "res-old", ProductID: "prodY", WarehouseID: "wh1", Quantity: 1, Status: whDomain.ReservationStatusReleased},
 	}
 
+	timedOutOrder1 := &domain.Order{ID: "timeout1", UserID: "userA", Status: domain.StatusPaymentTimeout}
+
 	t.Run("Successfully process one timed-out order", func(t *testing.T) {
+		saga := waitingCheckoutSaga(pendingOrder1.ID)
 		mockOrderRepo.On("GetPendingOrdersOlderThan", ctx, timeoutDuration).Return([]domain.Order{pendingOrder1}, nil).Once()
-		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, pendingOrder1.ID).Return(itemsForOrder1, nil).Once()
-		mockWhClient.On("ReleaseStock", ctx, "prodX", 1).Return(nil).Once()
-		mockWhClient.On("ReleaseStock", ctx, "prodY", 2).Return(nil).Once()
-		mockOrderRepo.On("UpdateOrderStatus", ctx, pendingOrder1.ID, domain.StatusPaymentTimeout).Return(nil).Once()
+		mockOrderRepo.On("GetPaymentsByOrderID", ctx, pendingOrder1.ID).Return([]domain.Payment{}, nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(pendingOrder1.ID, domain.StatusPendingPayment, domain.StatusPaymentTimeout)).Return(nil).Once()
+		// Saga checkout melihat order berakhir tanpa pembayaran dan melepas reservasinya
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, pendingOrder1.ID).Return(saga, nil).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, pendingOrder1.ID).Return(timedOutOrder1, nil).Once()
+		mockWhClient.On("ListReservations", ctx, pendingOrder1.ID).Return(reservationsForOrder1, nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-x").Return(nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-y").Return(nil).Once()
 
 		orderServiceInstance.ProcessPaymentTimeouts(ctx) // Ini void method
 
+		assert.Equal(t, domain.SagaStatusCompensated, saga.Status)
+		assert.Equal(t, domain.SagaStepFailed, saga.Steps[2].Status)
+		assert.Equal(t, domain.SagaStepCompensated, saga.Steps[0].Status)
 		mockOrderRepo.AssertExpectations(t)
 		mockWhClient.AssertExpectations(t)
+		mockWhClient.AssertNotCalled(t, "ReleaseReservation", ctx, "res-old")
 	})
 
 	t.Run("No orders past payment timeout", func(t *testing.T) {
@@ -199,23 +502,317 @@ func TestOrderService_ProcessPaymentTimeouts(t *testing.T) {
 		orderServiceInstance.ProcessPaymentTimeouts(ctx)
 
 		mockOrderRepo.AssertExpectations(t)
-		mockWhClient.AssertNotCalled(t, "ReleaseStock")
-		mockOrderRepo.AssertNotCalled(t, "GetOrderItemsByOrderID")
-		mockOrderRepo.AssertNotCalled(t, "UpdateOrderStatus")
+		mockWhClient.AssertNumberOfCalls(t, "ListReservations", 1) // Hanya dari subtest sebelumnya
+		mockOrderRepo.AssertNumberOfCalls(t, "TransitionOrderStatus", 1)
 	})
 
 	t.Run("Failed to release stock for an item", func(t *testing.T) {
+		saga := waitingCheckoutSaga(pendingOrder1.ID)
 		mockOrderRepo.On("GetPendingOrdersOlderThan", ctx, timeoutDuration).Return([]domain.Order{pendingOrder1}, nil).Once()
-		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, pendingOrder1.ID).Return(itemsForOrder1, nil).Once()
-		mockWhClient.On("ReleaseStock", ctx, "prodX", 1).Return(errors.New("warehouse client error")).Once() // Gagal rilis prodX
-		mockWhClient.On("ReleaseStock", ctx, "prodY", 2).Return(nil).Once()                                  // prodY tetap dirilis
+		mockOrderRepo.On("GetPaymentsByOrderID", ctx, pendingOrder1.ID).Return([]domain.Payment{}, nil).Once()
+		// Order status tetap PAYMENT_TIMEOUT walaupun pelepasan stok gagal
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition(pendingOrder1.ID, domain.StatusPendingPayment, domain.StatusPaymentTimeout)).Return(nil).Once()
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, pendingOrder1.ID).Return(saga, nil).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, pendingOrder1.ID).Return(timedOutOrder1, nil).Once()
+		mockWhClient.On("ListReservations", ctx, pendingOrder1.ID).Return(reservationsForOrder1, nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-x").Return(errors.New("warehouse client error")).Once() // Gagal rilis prodX
+		mockWhClient.On("ReleaseReservation", ctx, "res-y").Return(nil).Once()                                  // prodY tetap dirilis
 
-		// Order status tetap diupdate menjadi PAYMENT_TIMEOUT
-		mockOrderRepo.On("UpdateOrderStatus", ctx, pendingOrder1.ID, domain.StatusPaymentTimeout).Return(nil).Once()
+		orderServiceInstance.ProcessPaymentTimeouts(ctx)
+
+		// Kompensasi dijadwalkan ulang, bukan hanya dicatat di log
+		assert.Equal(t, domain.SagaStatusCompensating, saga.Status)
+		assert.Equal(t, 0, saga.CurrentStep)
+		assert.True(t, saga.NextAttemptAt.After(time.Now()))
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertExpectations(t)
+	})
+
+	t.Run("Order confirmed concurrently is skipped", func(t *testing.T) {
+		confirmedMeanwhile := domain.Order{ID: "timeout2", Status: domain.StatusPendingPayment}
+		mockOrderRepo.On("GetPendingOrdersOlderThan", ctx, timeoutDuration).Return([]domain.Order{confirmedMeanwhile}, nil).Once()
+		mockOrderRepo.On("GetPaymentsByOrderID", ctx, "timeout2").Return([]domain.Payment{}, nil).Once()
+		mockOrderRepo.On("TransitionOrderStatus", ctx, transition("timeout2", domain.StatusPendingPayment, domain.StatusPaymentTimeout)).
+			Return(oRepo.ErrOrderStatusConflict).Once()
 
 		orderServiceInstance.ProcessPaymentTimeouts(ctx)
 
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertNotCalled(t, "ListReservations", ctx, "timeout2")
+	})
+}
+
+func TestPlanFulfillmentGroups(t *testing.T) {
+	items := []domain.OrderItem{
+		{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 3},
+		{ProductID: "prod-2", ProductName: "Mouse", SKU: "MS-1", Quantity: 1},
+	}
+	reservations := []whDomain.StockReservation{
+		{ID: "res-1", ProductID: "prod-1", WarehouseID: "wh-2", Quantity: 2, Status: whDomain.ReservationStatusActive},
+		{ID: "res-2", ProductID: "prod-2", WarehouseID: "wh-2", Quantity: 1, Status: whDomain.ReservationStatusActive},
+		{ID: "res-3", ProductID: "prod-1", WarehouseID: "wh-1", Quantity: 1, Status: whDomain.ReservationStatusActive},
+		{ID: "res-4", ProductID: "prod-1", WarehouseID: "wh-3", Quantity: 3, Status: whDomain.ReservationStatusReleased}, // Dari percobaan sebelumnya
+	}
+
+	groups := domain.PlanFulfillmentGroups(fulfillmentAllocations(reservations), items)
+
+	assert.Equal(t, []domain.FulfillmentGroup{
+		{WarehouseID: "wh-2", Sequence: 1, Items: []domain.FulfillmentGroupItem{
+			{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 2},
+			{ProductID: "prod-2", ProductName: "Mouse", SKU: "MS-1", Quantity: 1},
+		}},
+		{WarehouseID: "wh-1", Sequence: 2, Items: []domain.FulfillmentGroupItem{
+			{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 1},
+		}},
+	}, groups)
+}
+
+func TestOrderService_GetOrderDetails(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), 1*time.Minute)
+	ctx := context.TODO()
+
+	t.Run("Order with items and fulfillment groups", func(t *testing.T) {
+		mockOrderRepo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", UserID: "user1"}, nil).Once()
+		mockOrderRepo.On("GetOrderItemsByOrderID", ctx, "order-1").Return([]domain.OrderItem{{ID: "item1", ProductID: "prodA", Quantity: 1}}, nil).Once()
+		mockOrderRepo.On("GetFulfillmentGroupsByOrderID", ctx, "order-1").Return([]domain.FulfillmentGroup{
+			{ID: "group-1", WarehouseID: "wh1", Sequence: 1, Items: []domain.FulfillmentGroupItem{{ProductID: "prodA", Quantity: 1}}},
+		}, nil).Once()
+
+		order, err := orderServiceInstance.GetOrderDetails(ctx, "order-1")
+		assert.NoError(t, err)
+		assert.Len(t, order.Items, 1)
+		if assert.Len(t, order.FulfillmentGroups, 1) {
+			assert.Equal(t, "wh1", order.FulfillmentGroups[0].WarehouseID)
+		}
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("Order not found", func(t *testing.T) {
+		mockOrderRepo.On("GetOrderByID", ctx, "missing").Return(nil, oRepo.ErrOrderNotFound).Once()
+
+		order, err := orderServiceInstance.GetOrderDetails(ctx, "missing")
+		assert.ErrorIs(t, err, oRepo.ErrOrderNotFound)
+		assert.Nil(t, order)
+	})
+}
+
+func TestOrderService_ListOrders(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), 1*time.Minute)
+	ctx := context.TODO()
+
+	now := time.Now()
+	orders := []domain.Order{
+		{ID: "o3", UserID: "user1", CreatedAt: now},
+		{ID: "o2", UserID: "user1", CreatedAt: now.Add(-time.Minute)},
+		{ID: "o1", UserID: "user1", CreatedAt: now.Add(-2 * time.Minute)},
+	}
+
+	t.Run("First page with next cursor and batched items", func(t *testing.T) {
+		mockOrderRepo.On("ListOrdersByUserID", ctx, mock.MatchedBy(func(f domain.ListOrdersFilter) bool {
+			return f.UserID == "user1" && f.Limit == 3 && f.Status == domain.StatusPendingPayment
+		})).Return(orders, nil).Once()
+		mockOrderRepo.On("GetOrderItemsByOrderIDs", ctx, []string{"o3", "o2"}).Return(map[string][]domain.OrderItem{
+			"o3": {{ID: "i3", OrderID: "o3", ProductID: "prodA", Quantity: 1}},
+		}, nil).Once()
+
+		resp, err := orderServiceInstance.ListOrders(ctx, domain.ListOrdersFilter{UserID: "user1", Status: domain.StatusPendingPayment, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Orders, 2)
+		assert.Len(t, resp.Orders[0].Items, 1)
+		assert.Empty(t, resp.Orders[1].Items)
+
+		cursor, err := domain.DecodeOrderCursor(resp.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, "o2", cursor.ID)
+		assert.True(t, cursor.CreatedAt.Equal(orders[1].CreatedAt))
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("Last page has no cursor", func(t *testing.T) {
+		mockOrderRepo.On("ListOrdersByUserID", ctx, mock.AnythingOfType("domain.ListOrdersFilter")).Return(orders[2:], nil).Once()
+		mockOrderRepo.On("GetOrderItemsByOrderIDs", ctx, []string{"o1"}).Return(map[string][]domain.OrderItem{}, nil).Once()
+
+		resp, err := orderServiceInstance.ListOrders(ctx, domain.ListOrdersFilter{UserID: "user1"})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Orders, 1)
+		assert.Empty(t, resp.NextCursor)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown status rejected", func(t *testing.T) {
+		_, err := orderServiceInstance.ListOrders(ctx, domain.ListOrdersFilter{UserID: "user1", Status: "BOGUS"})
+		assert.ErrorIs(t, err, ErrInvalidListQuery)
+	})
+}
+
+func TestOrderService_CancelOrder(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), 1*time.Minute)
+	allowSagaPersistence(mockOrderRepo)
+	ctx := context.TODO()
+
+	t.Run("Pending order releases reservations", func(t *testing.T) {
+		pending := &domain.Order{ID: "order-p", UserID: "user1", Status: domain.StatusPendingPayment}
+		saga := waitingCheckoutSaga("order-p")
+		mockOrderRepo.On("GetOrderByID", ctx, "order-p").Return(pending, nil).Once()
+		mockOrderRepo.On("CancelOrder", ctx, mock.MatchedBy(func(t domain.StatusTransition) bool {
+			return t.OrderID == "order-p" && t.From == domain.StatusPendingPayment && t.Reason == "changed my mind"
+		}), false, (*domain.Saga)(nil)).Return(nil).Once()
+		// Saga checkout melepas reservasi order yang dibatalkan
+		mockOrderRepo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, "order-p").Return(saga, nil).Once()
+		mockOrderRepo.On("GetOrderByID", ctx, "order-p").Return(&domain.Order{ID: "order-p", Status: domain.StatusCancelled}, nil).Once()
+		mockWhClient.On("ListReservations", ctx, "order-p").Return([]whDomain.StockReservation{
+			{ID: "res-p", ProductID: "prodA", WarehouseID: "wh1", Quantity: 2, Status: whDomain.ReservationStatusActive},
+		}, nil).Once()
+		mockWhClient.On("ReleaseReservation", ctx, "res-p").Return(nil).Once()
+
+		order, err := orderServiceInstance.CancelOrder(ctx, "order-p", "changed my mind")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusCancelled, order.Status)
+		assert.False(t, order.RefundRequired)
+		assert.Equal(t, "changed my mind", *order.CancellationReason)
+		assert.Equal(t, domain.SagaStatusCompensated, saga.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertExpectations(t)
+	})
+
+	t.Run("Paid order restocks deducted warehouses and flags refund", func(t *testing.T) {
+		paid := &domain.Order{ID: "order-c", UserID: "user1", Status: domain.StatusPaymentConfirmed}
+		var restockSaga *domain.Saga
+		mockOrderRepo.On("GetOrderByID", ctx, "order-c").Return(paid, nil).Once()
+		// Saga restock disimpan dalam transaksi pembatalan
+		mockOrderRepo.On("CancelOrder", ctx, transition("order-c", domain.StatusPaymentConfirmed, domain.StatusCancelled), true,
+			cancelRestockSaga("order-c")).Run(func(args mock.Arguments) {
+			restockSaga = args.Get(3).(*domain.Saga)
+		}).Return(nil).Once()
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, "order-c").Return([]domain.StockDeduction{
+			{ID: "ded-c1", OrderID: "order-c", ProductID: "prodA", WarehouseID: "wh1", Quantity: 1},
+			{ID: "ded-c2", OrderID: "order-c", ProductID: "prodA", WarehouseID: "wh2", Quantity: 3},
+		}, nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-c1", "wh1", "prodA", 1).Return(nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-c2", "wh2", "prodA", 3).Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-c1").Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-c2").Return(nil).Once()
+
+		order, err := orderServiceInstance.CancelOrder(ctx, "order-c", "")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusCancelled, order.Status)
+		assert.True(t, order.RefundRequired)
+		assert.Nil(t, order.CancellationReason)
+		assert.Equal(t, domain.SagaStatusCompleted, restockSaga.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockWhClient.AssertExpectations(t)
+	})
+
+	t.Run("Failed restock is retried by the saga", func(t *testing.T) {
+		paid := &domain.Order{ID: "order-f", UserID: "user1", Status: domain.StatusAwaitingShipment}
+		var restockSaga *domain.Saga
+		mockOrderRepo.On("GetOrderByID", ctx, "order-f").Return(paid, nil).Once()
+		mockOrderRepo.On("CancelOrder", ctx, transition("order-f", domain.StatusAwaitingShipment, domain.StatusCancelled), true,
+			cancelRestockSaga("order-f")).Run(func(args mock.Arguments) {
+			restockSaga = args.Get(3).(*domain.Saga)
+		}).Return(nil).Once()
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, "order-f").Return([]domain.StockDeduction{
+			{ID: "ded-f1", OrderID: "order-f", ProductID: "prodA", WarehouseID: "wh1", Quantity: 1},
+			{ID: "ded-f2", OrderID: "order-f", ProductID: "prodB", WarehouseID: "wh2", Quantity: 2},
+		}, nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-f1", "wh1", "prodA", 1).Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-f1").Return(nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-f2", "wh2", "prodB", 2).Return(errors.New("warehouse unavailable")).Once()
+
+		order, err := orderServiceInstance.CancelOrder(ctx, "order-f", "")
+		assert.NoError(t, err, "Pembatalan tetap berhasil; restock dijadwalkan ulang")
+		assert.True(t, order.RefundRequired)
+		assert.Equal(t, domain.SagaStatusRunning, restockSaga.Status)
+		assert.Equal(t, 1, restockSaga.Steps[0].Attempts)
+		assert.NotNil(t, restockSaga.LastError)
+		assert.True(t, restockSaga.NextAttemptAt.After(time.Now()))
+
+		// Scheduler melanjutkan saga: hanya pengurangan yang belum di-restock yang dikirim ulang
+		restocked := time.Now()
+		mockOrderRepo.On("ClaimDueSagas", ctx, mock.Anything, mock.Anything).Return([]domain.Saga{*restockSaga}, nil).Once()
+		mockOrderRepo.On("GetStockDeductionsByOrderID", ctx, "order-f").Return([]domain.StockDeduction{
+			{ID: "ded-f1", OrderID: "order-f", ProductID: "prodA", WarehouseID: "wh1", Quantity: 1, RestockedAt: &restocked},
+			{ID: "ded-f2", OrderID: "order-f", ProductID: "prodB", WarehouseID: "wh2", Quantity: 2},
+		}, nil).Once()
+		mockWhClient.On("RestockStock", ctx, "ded-f2", "wh2", "prodB", 2).Return(nil).Once()
+		mockOrderRepo.On("MarkStockDeductionRestocked", ctx, "ded-f2").Return(nil).Once()
+
+		orderServiceInstance.ResumeSagas(ctx)
 		mockOrderRepo.AssertExpectations(t)
 		mockWhClient.AssertExpectations(t)
 	})
+
+	t.Run("Shipped order rejected", func(t *testing.T) {
+		shipped := &domain.Order{ID: "order-s", Status: domain.StatusShipped}
+		mockOrderRepo.On("GetOrderByID", ctx, "order-s").Return(shipped, nil).Once()
+
+		order, err := orderServiceInstance.CancelOrder(ctx, "order-s", "")
+		assert.ErrorIs(t, err, ErrOrderAlreadyShipped)
+		assert.Nil(t, order)
+		mockOrderRepo.AssertNotCalled(t, "CancelOrder", ctx, transition("order-s", domain.StatusShipped, domain.StatusCancelled), mock.Anything, mock.Anything)
+	})
+
+	t.Run("Concurrent status change loses the race", func(t *testing.T) {
+		pending := &domain.Order{ID: "order-r", Status: domain.StatusPendingPayment}
+		mockOrderRepo.On("GetOrderByID", ctx, "order-r").Return(pending, nil).Once()
+		mockOrderRepo.On("CancelOrder", ctx, transition("order-r", domain.StatusPendingPayment, domain.StatusCancelled), false, (*domain.Saga)(nil)).Return(oRepo.ErrOrderStatusConflict).Once()
+
+		order, err := orderServiceInstance.CancelOrder(ctx, "order-r", "")
+		assert.ErrorIs(t, err, ErrOrderCannotBeCancelled)
+		assert.Nil(t, order)
+		mockWhClient.AssertNotCalled(t, "ListReservations", ctx, "order-r")
+	})
+}
+
+func TestOrderService_GetOrderHistory(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockWhClient := new(whClientOrderMocks.MockWarehouseClientForOrder)
+	orderServiceInstance := NewOrderService(mockOrderRepo, mockWhClient, new(whClientOrderMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), 1*time.Minute)
+	ctx := context.TODO()
+
+	t.Run("Returns transitions in order", func(t *testing.T) {
+		pending := domain.StatusPendingPayment
+		history := []domain.OrderStatusHistory{
+			{OrderID: "order-1", ToStatus: domain.StatusPendingPayment, Actor: "customer:user1"},
+			{OrderID: "order-1", FromStatus: &pending, ToStatus: domain.StatusPaymentTimeout, Actor: domain.ActorSystemPaymentTimeout},
+		}
+		mockOrderRepo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1"}, nil).Once()
+		mockOrderRepo.On("GetOrderStatusHistory", ctx, "order-1").Return(history, nil).Once()
+
+		result, err := orderServiceInstance.GetOrderHistory(ctx, "order-1")
+		assert.NoError(t, err)
+		assert.Equal(t, history, result)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("Order not found", func(t *testing.T) {
+		mockOrderRepo.On("GetOrderByID", ctx, "missing").Return(nil, oRepo.ErrOrderNotFound).Once()
+
+		_, err := orderServiceInstance.GetOrderHistory(ctx, "missing")
+		assert.ErrorIs(t, err, oRepo.ErrOrderNotFound)
+	})
+}
+
+func TestOrderStatus_Transitions(t *testing.T) {
+	assert.True(t, domain.StatusPendingPayment.CanTransitionTo(domain.StatusPaymentConfirmed))
+	assert.True(t, domain.StatusPendingPayment.CanTransitionTo(domain.StatusPaymentTimeout))
+	assert.True(t, domain.StatusAwaitingShipment.CanTransitionTo(domain.StatusShipped))
+	assert.False(t, domain.StatusPaymentConfirmed.CanTransitionTo(domain.StatusPaymentTimeout))
+	assert.False(t, domain.StatusPaymentTimeout.CanTransitionTo(domain.StatusPaymentConfirmed))
+	assert.False(t, domain.StatusShipped.CanTransitionTo(domain.StatusCancelled))
+	// Order yang sudah diterima masih bisa di-refund lewat retur
+	assert.False(t, domain.StatusDelivered.IsTerminal())
+	assert.True(t, domain.StatusDelivered.CanTransitionTo(domain.StatusPartiallyRefunded))
+	assert.True(t, domain.StatusPartiallyRefunded.CanTransitionTo(domain.StatusRefunded))
+	assert.False(t, domain.StatusRefunded.CanTransitionTo(domain.StatusPartiallyRefunded))
+	assert.True(t, domain.StatusRefunded.IsTerminal())
+
+	err := domain.StatusTransition{OrderID: "o1", From: domain.StatusCancelled, To: domain.StatusShipped}.Validate()
+	assert.ErrorIs(t, err, domain.ErrInvalidStatusTransition)
 }
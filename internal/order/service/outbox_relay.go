@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/events"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+const (
+	defaultOutboxBatchSize   = 50
+	defaultOutboxMaxAttempts = 10
+	outboxBaseBackoff        = 5 * time.Second
+	outboxMaxBackoff         = 30 * time.Minute
+	// outboxClaimLease harus lebih lama dari waktu pengiriman satu batch,
+	// agar event yang sedang dikirim tidak diambil relay lain.
+	outboxClaimLease = 2 * time.Minute
+)
+
+// OutboxRelay mengirim event dari tabel outbox ke EventPublisher (at-least-once).
+// Event yang gagal dijadwalkan ulang dengan exponential backoff, dan ditandai DEAD setelah MaxAttempts percobaan.
+type OutboxRelay struct {
+	repo        repository.OrderRepository
+	publisher   events.EventPublisher
+	BatchSize   int
+	MaxAttempts int
+	now         func() time.Time
+}
+
+func NewOutboxRelay(repo repository.OrderRepository, publisher events.EventPublisher) *OutboxRelay {
+	return &OutboxRelay{
+		repo:        repo,
+		publisher:   publisher,
+		BatchSize:   defaultOutboxBatchSize,
+		MaxAttempts: defaultOutboxMaxAttempts,
+		now:         time.Now,
+	}
+}
+
+// Run memproses outbox setiap interval sampai ctx dibatalkan.
+// Jika satu batch penuh, batch berikutnya langsung diproses tanpa menunggu interval.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	logger.Info(fmt.Sprintf("Outbox relay started with interval %v", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if claimed := r.RunOnce(ctx); claimed >= r.BatchSize && ctx.Err() == nil {
+			continue // Masih ada backlog, proses batch berikutnya langsung
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce mengklaim satu batch event yang jatuh tempo dan mengirimnya. Mengembalikan jumlah event yang diklaim.
+func (r *OutboxRelay) RunOnce(ctx context.Context) int {
+	pending, err := r.repo.ClaimOutboxEvents(ctx, r.BatchSize, outboxClaimLease)
+	if err != nil {
+		logger.Error("OutboxRelay: failed to claim outbox events", err, nil)
+		return 0
+	}
+
+	for _, e := range pending {
+		err := r.publisher.Publish(ctx, events.Event{
+			ID:          e.ID,
+			Type:        e.EventType,
+			AggregateID: e.AggregateID,
+			OccurredAt:  e.CreatedAt,
+			Payload:     e.Payload,
+		})
+		if err == nil {
+			if err := r.repo.MarkOutboxEventPublished(ctx, e.ID); err != nil {
+				// Event akan dikirim ulang setelah lease habis; konsumen harus mendeduplikasi berdasarkan ID
+				logger.Error(fmt.Sprintf("OutboxRelay: event %s published but not marked", e.ID), err, nil)
+			}
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		dead := attempts >= r.MaxAttempts
+		retryAt := r.now().Add(outboxBackoff(attempts))
+		if dead {
+			logger.Error(fmt.Sprintf("OutboxRelay: event %s (%s) moved to dead-letter after %d attempts", e.ID, e.EventType, attempts), err, nil)
+		} else {
+			logger.Warn(fmt.Sprintf("OutboxRelay: failed to publish event %s (%s), attempt %d, retrying at %s: %v",
+				e.ID, e.EventType, attempts, retryAt.Format(time.RFC3339), err))
+		}
+		if markErr := r.repo.MarkOutboxEventFailed(ctx, e.ID, err.Error(), retryAt, dead); markErr != nil {
+			logger.Error(fmt.Sprintf("OutboxRelay: failed to record failure for event %s", e.ID), markErr, nil)
+		}
+	}
+	return len(pending)
+}
+
+// outboxBackoff menghitung jeda sebelum percobaan berikutnya: 5s, 10s, 20s, ... maksimal 30 menit.
+func outboxBackoff(attempts int) time.Duration {
+	return exponentialBackoff(attempts, outboxBaseBackoff, outboxMaxBackoff)
+}
+
+// exponentialBackoff menggandakan base untuk setiap percobaan setelah yang pertama, dibatasi max.
+func exponentialBackoff(attempts int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return backoff
+}
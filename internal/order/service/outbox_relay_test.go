@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository/mocks"
+	serviceMocks "github.com/ridloal/e-commerce-go-microservices/internal/order/service/mocks"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOutboxRelay_RunOnce(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	outboxEvent := func(id string, attempts int) domain.OutboxEvent {
+		return domain.OutboxEvent{
+			ID:          id,
+			AggregateID: "order-1",
+			EventType:   domain.EventOrderPaid,
+			Payload:     json.RawMessage(`{"order_id":"order-1"}`),
+			Status:      domain.OutboxStatusPending,
+			Attempts:    attempts,
+			CreatedAt:   now.Add(-time.Minute),
+		}
+	}
+	newRelay := func() (*OutboxRelay, *mocks.MockOrderRepository, *serviceMocks.MockEventPublisher) {
+		repo := new(mocks.MockOrderRepository)
+		publisher := new(serviceMocks.MockEventPublisher)
+		relay := NewOutboxRelay(repo, publisher)
+		relay.now = func() time.Time { return now }
+		return relay, repo, publisher
+	}
+
+	t.Run("Published events are marked", func(t *testing.T) {
+		relay, repo, publisher := newRelay()
+		repo.On("ClaimOutboxEvents", ctx, relay.BatchSize, outboxClaimLease).Return([]domain.OutboxEvent{outboxEvent("evt-1", 0)}, nil).Once()
+		publisher.On("Publish", ctx, mock.MatchedBy(func(e events.Event) bool {
+			return e.ID == "evt-1" && e.Type == domain.EventOrderPaid && e.AggregateID == "order-1"
+		})).Return(nil).Once()
+		repo.On("MarkOutboxEventPublished", ctx, "evt-1").Return(nil).Once()
+
+		assert.Equal(t, 1, relay.RunOnce(ctx))
+		repo.AssertExpectations(t)
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("Failed publish is retried with backoff", func(t *testing.T) {
+		relay, repo, publisher := newRelay()
+		repo.On("ClaimOutboxEvents", ctx, relay.BatchSize, outboxClaimLease).Return([]domain.OutboxEvent{outboxEvent("evt-2", 2)}, nil).Once()
+		publisher.On("Publish", ctx, mock.Anything).Return(errors.New("sink unavailable")).Once()
+		// Percobaan ke-3: 5s * 2^2
+		repo.On("MarkOutboxEventFailed", ctx, "evt-2", "sink unavailable", now.Add(20*time.Second), false).Return(nil).Once()
+
+		relay.RunOnce(ctx)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "MarkOutboxEventPublished", ctx, "evt-2")
+	})
+
+	t.Run("Event moves to dead-letter after max attempts", func(t *testing.T) {
+		relay, repo, publisher := newRelay()
+		repo.On("ClaimOutboxEvents", ctx, relay.BatchSize, outboxClaimLease).
+			Return([]domain.OutboxEvent{outboxEvent("evt-3", relay.MaxAttempts-1)}, nil).Once()
+		publisher.On("Publish", ctx, mock.Anything).Return(errors.New("sink unavailable")).Once()
+		repo.On("MarkOutboxEventFailed", ctx, "evt-3", "sink unavailable", mock.AnythingOfType("time.Time"), true).Return(nil).Once()
+
+		relay.RunOnce(ctx)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, outboxBackoff(1))
+	assert.Equal(t, 10*time.Second, outboxBackoff(2))
+	assert.Equal(t, outboxMaxBackoff, outboxBackoff(30))
+}
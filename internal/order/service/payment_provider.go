@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid payment webhook signature")
+	ErrInvalidWebhookPayload   = errors.New("invalid payment webhook payload")
+)
+
+// PaymentIntentRequest meminta provider menyiapkan pembayaran untuk satu order.
+// IdempotencyKey membuat permintaan yang diulang mengembalikan intent yang sama di sisi provider.
+type PaymentIntentRequest struct {
+	OrderID        string
+	Amount         money.Money
+	IdempotencyKey string
+}
+
+type PaymentIntent struct {
+	ProviderPaymentID string
+	CheckoutURL       string // Halaman tempat customer menyelesaikan pembayaran
+}
+
+// RefundRequest meminta provider mengembalikan sebagian atau seluruh dana satu payment.
+// IdempotencyKey yang sama tidak pernah mengembalikan dana dua kali.
+type RefundRequest struct {
+	ProviderPaymentID string
+	Amount            money.Money
+	IdempotencyKey    string
+}
+
+type RefundResult struct {
+	ProviderRefundID string
+}
+
+// PaymentProvider adalah abstraksi payment gateway. Status akhir pembayaran hanya diterima lewat webhook
+// yang tanda tangannya diverifikasi oleh ParseWebhook.
+type PaymentProvider interface {
+	Name() string
+	CreatePaymentIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error)
+	// ParseWebhook memverifikasi tanda tangan dan umur callback lalu mengembalikan event-nya.
+	// Mengembalikan ErrInvalidWebhookSignature atau ErrInvalidWebhookPayload jika callback ditolak.
+	ParseWebhook(header http.Header, body []byte) (*domain.PaymentEvent, error)
+	// Refund mengembalikan dana dan menunggu hasilnya dari provider; error berarti dana belum dikembalikan.
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+}
+
+// PaymentSimulator diimplementasikan provider untuk pengembangan lokal yang bisa memalsukan hasil pembayaran.
+// SimulateWebhook menghasilkan callback bertanda tangan seolah-olah dikirim provider.
+type PaymentSimulator interface {
+	SimulateWebhook(payment domain.Payment, outcome string) (http.Header, []byte, error)
+}
+
+const (
+	FakeProviderName         = "fake"
+	FakeSignatureHeader      = "X-Fake-Signature"
+	fakeWebhookTolerance     = 5 * time.Minute
+	fakePaymentIDPrefix      = "fake_pi_"
+	fakeEventIDPrefix        = "fake_evt_"
+	fakeRefundIDPrefix       = "fake_re_"
+	defaultFakeCheckoutBase  = "http://localhost:8080/fake-checkout"
+	fakeSimulatedFailureText = "simulated card decline"
+)
+
+// FakePaymentProvider adalah provider bawaan untuk pengembangan lokal: intent dibuat di memori,
+// dan webhook ditandatangani HMAC-SHA256 seperti provider sungguhan:
+//
+//	X-Fake-Signature: t=<unix timestamp>,v1=<hex(HMAC-SHA256(secret, "<t>.<body>"))>
+//
+// Callback dengan timestamp lebih dari 5 menit dari waktu sekarang ditolak agar tidak bisa di-replay.
+type FakePaymentProvider struct {
+	secret      []byte
+	checkoutURL string
+	now         func() time.Time
+
+	mu      sync.Mutex
+	intents map[string]*PaymentIntent // Per idempotency key
+	refunds map[string]*RefundResult  // Per idempotency key
+}
+
+func NewFakePaymentProvider(webhookSecret, checkoutBaseURL string) *FakePaymentProvider {
+	if checkoutBaseURL == "" {
+		checkoutBaseURL = defaultFakeCheckoutBase
+	}
+	return &FakePaymentProvider{
+		secret:      []byte(webhookSecret),
+		checkoutURL: strings.TrimSuffix(checkoutBaseURL, "/"),
+		now:         time.Now,
+		intents:     make(map[string]*PaymentIntent),
+		refunds:     make(map[string]*RefundResult),
+	}
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakePaymentProvider) CreatePaymentIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if intent, ok := p.intents[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return intent, nil
+	}
+	id, err := randomID(fakePaymentIDPrefix)
+	if err != nil {
+		return nil, err
+	}
+	intent := &PaymentIntent{ProviderPaymentID: id, CheckoutURL: p.checkoutURL + "/" + id}
+	if req.IdempotencyKey != "" {
+		p.intents[req.IdempotencyKey] = intent
+	}
+	return intent, nil
+}
+
+// Refund pada fake provider selalu berhasil seketika.
+func (p *FakePaymentProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("refund amount must be positive, got %s", req.Amount)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if result, ok := p.refunds[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return result, nil
+	}
+	id, err := randomID(fakeRefundIDPrefix)
+	if err != nil {
+		return nil, err
+	}
+	result := &RefundResult{ProviderRefundID: id}
+	if req.IdempotencyKey != "" {
+		p.refunds[req.IdempotencyKey] = result
+	}
+	return result, nil
+}
+
+func (p *FakePaymentProvider) ParseWebhook(header http.Header, body []byte) (*domain.PaymentEvent, error) {
+	timestamp, signature, err := parseFakeSignature(header.Get(FakeSignatureHeader))
+	if err != nil {
+		return nil, err
+	}
+	expected := p.sign(timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidWebhookSignature)
+	}
+	age := p.now().Sub(time.Unix(timestamp, 0))
+	if age > fakeWebhookTolerance || age < -fakeWebhookTolerance {
+		return nil, fmt.Errorf("%w: timestamp is outside the %v tolerance", ErrInvalidWebhookSignature, fakeWebhookTolerance)
+	}
+
+	var event domain.PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookPayload, err)
+	}
+	if event.EventID == "" || event.Type == "" || event.ProviderPaymentID == "" {
+		return nil, fmt.Errorf("%w: id, type and payment_id are required", ErrInvalidWebhookPayload)
+	}
+	event.Provider = FakeProviderName
+	return &event, nil
+}
+
+func (p *FakePaymentProvider) SimulateWebhook(payment domain.Payment, outcome string) (http.Header, []byte, error) {
+	event := domain.PaymentEvent{
+		ProviderPaymentID: payment.ProviderPaymentID,
+		Amount:            &payment.Amount,
+		OccurredAt:        p.now().UTC(),
+	}
+	switch outcome {
+	case "succeeded":
+		event.Type = domain.PaymentEventSucceeded
+	case "failed":
+		event.Type = domain.PaymentEventFailed
+		event.FailureReason = fakeSimulatedFailureText
+	default:
+		return nil, nil, fmt.Errorf("unknown simulated outcome %q", outcome)
+	}
+	var err error
+	if event.EventID, err = randomID(fakeEventIDPrefix); err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, p.SignatureHeader(body))
+	return header, body, nil
+}
+
+// SignatureHeader menghasilkan nilai header X-Fake-Signature untuk body dengan timestamp sekarang.
+func (p *FakePaymentProvider) SignatureHeader(body []byte) string {
+	timestamp := p.now().Unix()
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, p.sign(timestamp, body))
+}
+
+func (p *FakePaymentProvider) sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseFakeSignature(value string) (int64, string, error) {
+	if value == "" {
+		return 0, "", fmt.Errorf("%w: missing %s header", ErrInvalidWebhookSignature, FakeSignatureHeader)
+	}
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return 0, "", fmt.Errorf("%w: invalid timestamp", ErrInvalidWebhookSignature)
+			}
+			timestamp = ts
+		case "v1":
+			signature = val
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return 0, "", fmt.Errorf("%w: malformed %s header", ErrInvalidWebhookSignature, FakeSignatureHeader)
+	}
+	return timestamp, signature, nil
+}
+
+func randomID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+var (
+	ErrUnknownPaymentProvider       = errors.New("unknown payment provider")
+	ErrOrderNotPayable              = errors.New("order is not awaiting payment")
+	ErrPaymentProviderFailed        = errors.New("payment provider failed to create the payment")
+	ErrPaymentAmountMismatch        = errors.New("paid amount does not match the payment amount")
+	ErrPaymentSimulationUnsupported = errors.New("payment provider does not support simulated payments")
+	ErrPaymentSimulationDisabled    = errors.New("payment simulation is disabled")
+	ErrNoPendingPayment             = errors.New("order has no pending payment")
+)
+
+// CreatePaymentIntent mengembalikan payment yang masih berlaku untuk order, atau membuat intent baru di provider
+// (misal setelah pembayaran sebelumnya gagal).
+func (s *orderServiceImpl) CreatePaymentIntent(ctx context.Context, orderID string) (*domain.Payment, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != domain.StatusPendingPayment {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotPayable, order.Status)
+	}
+	return s.ensurePaymentIntent(ctx, order)
+}
+
+// ensurePaymentIntent memakai ulang payment PENDING/SUCCEEDED milik order jika ada.
+// Idempotency key per percobaan membuat request yang diulang tidak membuat intent ganda di provider.
+func (s *orderServiceImpl) ensurePaymentIntent(ctx context.Context, order *domain.Order) (*domain.Payment, error) {
+	payments, err := s.orderRepo.GetPaymentsByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if open := openPayment(payments); open != nil {
+		return open, nil
+	}
+
+	intent, err := s.paymentProvider.CreatePaymentIntent(ctx, PaymentIntentRequest{
+		OrderID:        order.ID,
+		Amount:         order.TotalAmount,
+		IdempotencyKey: fmt.Sprintf("order-%s-attempt-%d", order.ID, len(payments)+1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentProviderFailed, err)
+	}
+	payment := &domain.Payment{
+		OrderID:           order.ID,
+		Provider:          s.paymentProvider.Name(),
+		ProviderPaymentID: intent.ProviderPaymentID,
+		Amount:            order.TotalAmount,
+		Status:            domain.PaymentStatusPending,
+		CheckoutURL:       intent.CheckoutURL,
+	}
+	if err := s.orderRepo.CreatePayment(ctx, payment); err != nil {
+		if errors.Is(err, repository.ErrOpenPaymentExists) {
+			// Dibuat bersamaan oleh request lain
+			if payments, err = s.orderRepo.GetPaymentsByOrderID(ctx, order.ID); err == nil {
+				if open := openPayment(payments); open != nil {
+					return open, nil
+				}
+			}
+		}
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Created %s payment %s for order %s", payment.Provider, payment.ProviderPaymentID, order.ID))
+	return payment, nil
+}
+
+func openPayment(payments []domain.Payment) *domain.Payment {
+	for i := range payments {
+		if payments[i].Status == domain.PaymentStatusPending || payments[i].Status == domain.PaymentStatusSucceeded {
+			return &payments[i]
+		}
+	}
+	return nil
+}
+
+func (s *orderServiceImpl) hasSucceededPayment(ctx context.Context, orderID string) (*domain.Payment, error) {
+	payments, err := s.orderRepo.GetPaymentsByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range payments {
+		if payments[i].Status == domain.PaymentStatusSucceeded {
+			return &payments[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *orderServiceImpl) GetOrderPayments(ctx context.Context, orderID string) ([]domain.Payment, error) {
+	if _, err := s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetPaymentsByOrderID(ctx, orderID)
+}
+
+// HandlePaymentWebhook memproses callback dari provider:
+//  1. Tanda tangan dan umur callback diverifikasi provider (callback lama atau palsu ditolak).
+//  2. Nominal dicocokkan dengan payment yang tersimpan.
+//  3. Event dicatat sekali per event ID bersamaan dengan perubahan status payment; callback ganda tidak diproses ulang.
+//  4. Pembayaran berhasil mengonfirmasi order lewat ConfirmPayment.
+//
+// Error dikembalikan hanya jika provider perlu mengirim ulang callback. Callback ulang untuk payment yang sudah
+// SUCCEEDED tetap menyelesaikan konfirmasi order jika percobaan sebelumnya gagal di tengah jalan.
+func (s *orderServiceImpl) HandlePaymentWebhook(ctx context.Context, provider string, header http.Header, body []byte) error {
+	if provider != s.paymentProvider.Name() {
+		return fmt.Errorf("%w: %s", ErrUnknownPaymentProvider, provider)
+	}
+	event, err := s.paymentProvider.ParseWebhook(header, body)
+	if err != nil {
+		logger.Warn(fmt.Sprintf("HandlePaymentWebhook: rejected %s callback: %v", provider, err))
+		return err
+	}
+	status, ok := domain.PaymentStatusForEvent(event.Type)
+	if !ok {
+		logger.Info(fmt.Sprintf("HandlePaymentWebhook: ignoring %s event %s of type %s", provider, event.EventID, event.Type))
+		return nil
+	}
+
+	payment, err := s.orderRepo.GetPaymentByProviderID(ctx, provider, event.ProviderPaymentID)
+	if err != nil {
+		return err
+	}
+	if event.Amount != nil && !event.Amount.Equal(payment.Amount) {
+		logger.Error(fmt.Sprintf("HandlePaymentWebhook: event %s reports %s for payment %s of %s",
+			event.EventID, event.Amount, payment.ID, payment.Amount), ErrPaymentAmountMismatch, nil)
+		return ErrPaymentAmountMismatch
+	}
+
+	err = s.orderRepo.ApplyPaymentEvent(ctx, payment.ID, *event, status)
+	switch {
+	case errors.Is(err, repository.ErrDuplicatePaymentEvent):
+		logger.Info(fmt.Sprintf("HandlePaymentWebhook: %s event %s already processed", provider, event.EventID))
+		if payment.Status != domain.PaymentStatusSucceeded {
+			return nil
+		}
+	case errors.Is(err, repository.ErrPaymentStatusConflict):
+		if payment.Status != status {
+			logger.Warn(fmt.Sprintf("HandlePaymentWebhook: ignoring %s event %s, payment %s is already %s", event.Type, event.EventID, payment.ID, payment.Status))
+			return nil
+		}
+	case err != nil:
+		return err
+	default:
+		payment.Status = status
+	}
+
+	if status == domain.PaymentStatusFailed {
+		// Order tetap menunggu pembayaran; customer bisa membuat intent baru sampai batas waktu habis
+		logger.Info(fmt.Sprintf("Payment %s for order %s failed: %s", payment.ID, payment.OrderID, event.FailureReason))
+		return nil
+	}
+	return s.settlePaidOrder(ctx, payment)
+}
+
+// settlePaidOrder mengonfirmasi order yang pembayarannya sudah berhasil. Aman dipanggil berulang.
+func (s *orderServiceImpl) settlePaidOrder(ctx context.Context, payment *domain.Payment) error {
+	reason := fmt.Sprintf("payment %s succeeded via %s", payment.ID, payment.Provider)
+	_, err := s.confirmPayment(ctx, payment.OrderID, domain.ActorSystemPaymentWebhook, reason)
+	if err == nil || !errors.Is(err, ErrOrderCannotBeConfirmed) {
+		return err
+	}
+
+	order, getErr := s.orderRepo.GetOrderByID(ctx, payment.OrderID)
+	if getErr != nil {
+		return getErr
+	}
+	switch order.Status {
+	case domain.StatusPendingPayment:
+		return err // Kalah balapan dengan proses lain; provider mengirim ulang callback
+	case domain.StatusPaymentTimeout, domain.StatusCancelled, domain.StatusFailed:
+		logger.Error(fmt.Sprintf("CRITICAL: payment %s succeeded for order %s which is already %s, refund required",
+			payment.ID, payment.OrderID, order.Status), err, nil)
+	}
+	return nil // Sudah dikonfirmasi sebelumnya
+}
+
+// SimulatePayment (pengembangan lokal) memalsukan hasil payment PENDING milik order lewat webhook bertanda tangan,
+// sehingga jalur yang sama dengan callback provider sungguhan ikut teruji. Hanya berjalan jika simulasi diaktifkan
+// lewat konfigurasi, karena siapa pun yang bisa memanggilnya dapat menandai order lunas tanpa membayar.
+func (s *orderServiceImpl) SimulatePayment(ctx context.Context, orderID string, outcome string) (*domain.Payment, error) {
+	if !s.paymentSimulationEnabled {
+		return nil, ErrPaymentSimulationDisabled
+	}
+	simulator, ok := s.paymentProvider.(PaymentSimulator)
+	if !ok {
+		return nil, ErrPaymentSimulationUnsupported
+	}
+	payments, err := s.GetOrderPayments(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	var pending *domain.Payment
+	for i := range payments {
+		if payments[i].Status == domain.PaymentStatusPending {
+			pending = &payments[i]
+		}
+	}
+	if pending == nil {
+		return nil, ErrNoPendingPayment
+	}
+
+	header, body, err := simulator.SimulateWebhook(*pending, outcome)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.HandlePaymentWebhook(ctx, s.paymentProvider.Name(), header, body); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetPaymentByProviderID(ctx, pending.Provider, pending.ProviderPaymentID)
+}
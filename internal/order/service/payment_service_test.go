@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	oRepo "github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository/mocks"
+	serviceMocks "github.com/ridloal/e-commerce-go-microservices/internal/order/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// failingPaymentProvider mensimulasikan provider yang tidak bisa dihubungi.
+type failingPaymentProvider struct{ *FakePaymentProvider }
+
+func (p failingPaymentProvider) CreatePaymentIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (p failingPaymentProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	return nil, errors.New("connection refused")
+}
+
+func signedEvent(t *testing.T, provider *FakePaymentProvider, payment domain.Payment, outcome string) (http.Header, []byte) {
+	header, body, err := provider.SimulateWebhook(payment, outcome)
+	assert.NoError(t, err)
+	return header, body
+}
+
+func TestFakePaymentProvider_ParseWebhook(t *testing.T) {
+	provider := NewFakePaymentProvider("test-secret", "")
+	payment := domain.Payment{ProviderPaymentID: "fake_pi_1", Amount: idr(45000)}
+
+	t.Run("Valid signature", func(t *testing.T) {
+		header, body := signedEvent(t, provider, payment, "succeeded")
+
+		event, err := provider.ParseWebhook(header, body)
+
+		assert.NoError(t, err)
+		assert.Equal(t, FakeProviderName, event.Provider)
+		assert.Equal(t, domain.PaymentEventSucceeded, event.Type)
+		assert.Equal(t, "fake_pi_1", event.ProviderPaymentID)
+		assert.True(t, event.Amount.Equal(idr(45000)))
+	})
+
+	t.Run("Tampered body is rejected", func(t *testing.T) {
+		header, body := signedEvent(t, provider, payment, "failed")
+		tampered := []byte(string(body[:len(body)-1]) + " }")
+
+		_, err := provider.ParseWebhook(header, tampered)
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	})
+
+	t.Run("Signature from another secret is rejected", func(t *testing.T) {
+		header, body := signedEvent(t, NewFakePaymentProvider("other-secret", ""), payment, "succeeded")
+
+		_, err := provider.ParseWebhook(header, body)
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	})
+
+	t.Run("Replayed old callback is rejected", func(t *testing.T) {
+		header, body := signedEvent(t, provider, payment, "succeeded")
+		later := NewFakePaymentProvider("test-secret", "")
+		later.now = func() time.Time { return time.Now().Add(fakeWebhookTolerance + time.Minute) }
+
+		_, err := later.ParseWebhook(header, body)
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	})
+
+	t.Run("Missing signature header", func(t *testing.T) {
+		_, body := signedEvent(t, provider, payment, "succeeded")
+
+		_, err := provider.ParseWebhook(http.Header{}, body)
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	})
+}
+
+func TestOrderService_HandlePaymentWebhook(t *testing.T) {
+	provider := NewFakePaymentProvider("test-secret", "")
+	ctx := context.Background()
+
+	newService := func() (*mocks.MockOrderRepository, OrderService) {
+		repo := new(mocks.MockOrderRepository)
+		allowSagaPersistence(repo)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), provider, time.Minute)
+		return repo, svc
+	}
+	pendingPayment := func() *domain.Payment {
+		return &domain.Payment{ID: "pay-1", OrderID: "order-1", Provider: FakeProviderName, ProviderPaymentID: "fake_pi_1",
+			Amount: idr(45000), Status: domain.PaymentStatusPending}
+	}
+	// ConfirmPayment mengubah order yang dikembalikan repository, sehingga setiap subtest memakai salinan baru
+	pendingOrder := func() *domain.Order {
+		return &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusPendingPayment}
+	}
+	webhookConfirmation := mock.MatchedBy(func(tr domain.StatusTransition) bool {
+		return tr.OrderID == "order-1" && tr.From == domain.StatusPendingPayment && tr.To == domain.StatusPaymentConfirmed &&
+			tr.Actor == domain.ActorSystemPaymentWebhook
+	})
+
+	t.Run("Successful payment confirms the order", func(t *testing.T) {
+		repo, svc := newService()
+		header, body := signedEvent(t, provider, *pendingPayment(), "succeeded")
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(pendingPayment(), nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.AnythingOfType("domain.PaymentEvent"), domain.PaymentStatusSucceeded).Return(nil).Once()
+		repo.On("GetOrderByID", ctx, "order-1").Return(pendingOrder(), nil).Once()
+		repo.On("TransitionOrderStatus", ctx, webhookConfirmation).Return(nil).Once()
+		repo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, "order-1").Return(nil, oRepo.ErrSagaNotFound).Once()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid signature never touches the order", func(t *testing.T) {
+		repo, svc := newService()
+		_, body := signedEvent(t, provider, *pendingPayment(), "succeeded")
+		header := http.Header{}
+		header.Set(FakeSignatureHeader, "t=1700000000,v1=deadbeef")
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+		repo.AssertNotCalled(t, "GetPaymentByProviderID", mock.Anything, mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		_, svc := newService()
+
+		err := svc.HandlePaymentWebhook(ctx, "stripe", http.Header{}, []byte("{}"))
+
+		assert.ErrorIs(t, err, ErrUnknownPaymentProvider)
+	})
+
+	t.Run("Amount mismatch is rejected", func(t *testing.T) {
+		repo, svc := newService()
+		tampered := *pendingPayment()
+		tampered.Amount = idr(1000)
+		header, body := signedEvent(t, provider, tampered, "succeeded")
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(pendingPayment(), nil).Once()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.ErrorIs(t, err, ErrPaymentAmountMismatch)
+		repo.AssertNotCalled(t, "ApplyPaymentEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Duplicate callback for a confirmed order is a no-op", func(t *testing.T) {
+		repo, svc := newService()
+		header, body := signedEvent(t, provider, *pendingPayment(), "succeeded")
+		succeeded := pendingPayment()
+		succeeded.Status = domain.PaymentStatusSucceeded
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(succeeded, nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.AnythingOfType("domain.PaymentEvent"), domain.PaymentStatusSucceeded).
+			Return(oRepo.ErrDuplicatePaymentEvent).Once()
+		// Percobaan konfirmasi melihat order sudah dibayar
+		confirmed := &domain.Order{ID: "order-1", Status: domain.StatusPaymentConfirmed}
+		repo.On("GetOrderByID", ctx, "order-1").Return(confirmed, nil).Twice()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Redelivered callback finishes an interrupted confirmation", func(t *testing.T) {
+		repo, svc := newService()
+		header, body := signedEvent(t, provider, *pendingPayment(), "succeeded")
+		succeeded := pendingPayment()
+		succeeded.Status = domain.PaymentStatusSucceeded
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(succeeded, nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.AnythingOfType("domain.PaymentEvent"), domain.PaymentStatusSucceeded).
+			Return(oRepo.ErrDuplicatePaymentEvent).Once()
+		repo.On("GetOrderByID", ctx, "order-1").Return(pendingOrder(), nil).Once()
+		repo.On("TransitionOrderStatus", ctx, webhookConfirmation).Return(nil).Once()
+		repo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, "order-1").Return(nil, oRepo.ErrSagaNotFound).Once()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Failed payment leaves the order pending", func(t *testing.T) {
+		repo, svc := newService()
+		header, body := signedEvent(t, provider, *pendingPayment(), "failed")
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(pendingPayment(), nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.MatchedBy(func(e domain.PaymentEvent) bool {
+			return e.Type == domain.PaymentEventFailed && e.FailureReason != ""
+		}), domain.PaymentStatusFailed).Return(nil).Once()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Late payment for a timed-out order is acknowledged", func(t *testing.T) {
+		repo, svc := newService()
+		header, body := signedEvent(t, provider, *pendingPayment(), "succeeded")
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(pendingPayment(), nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.AnythingOfType("domain.PaymentEvent"), domain.PaymentStatusSucceeded).Return(nil).Once()
+		timedOut := &domain.Order{ID: "order-1", Status: domain.StatusPaymentTimeout}
+		repo.On("GetOrderByID", ctx, "order-1").Return(timedOut, nil).Twice()
+
+		err := svc.HandlePaymentWebhook(ctx, FakeProviderName, header, body)
+
+		assert.NoError(t, err) // Provider tidak perlu mengirim ulang; refund ditangani terpisah
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_SimulatePayment(t *testing.T) {
+	provider := NewFakePaymentProvider("test-secret", "")
+	ctx := context.Background()
+	pending := domain.Payment{ID: "pay-1", OrderID: "order-1", Provider: FakeProviderName, ProviderPaymentID: "fake_pi_1",
+		Amount: idr(45000), Status: domain.PaymentStatusPending}
+
+	t.Run("Refused unless simulation is enabled", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), provider, time.Minute)
+
+		_, err := svc.SimulatePayment(ctx, "order-1", "succeeded")
+
+		assert.ErrorIs(t, err, ErrPaymentSimulationDisabled)
+		repo.AssertNotCalled(t, "GetPaymentsByOrderID", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "ApplyPaymentEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Enabled simulation goes through the webhook path", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderServiceWithConfig(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), provider, time.Minute, true)
+		failed := pending
+		failed.Status = domain.PaymentStatusFailed
+		repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusPendingPayment}, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{pending}, nil).Once()
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(&pending, nil).Once()
+		repo.On("ApplyPaymentEvent", ctx, "pay-1", mock.AnythingOfType("domain.PaymentEvent"), domain.PaymentStatusFailed).Return(nil).Once()
+		repo.On("GetPaymentByProviderID", ctx, FakeProviderName, "fake_pi_1").Return(&failed, nil).Once()
+
+		payment, err := svc.SimulatePayment(ctx, "order-1", "failed")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PaymentStatusFailed, payment.Status)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_CreatePaymentIntent(t *testing.T) {
+	ctx := context.Background()
+	pendingOrder := &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusPendingPayment}
+
+	t.Run("Existing pending payment is reused", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), time.Minute)
+		existing := domain.Payment{ID: "pay-1", OrderID: "order-1", Status: domain.PaymentStatusPending}
+		repo.On("GetOrderByID", ctx, "order-1").Return(pendingOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{existing}, nil).Once()
+
+		payment, err := svc.CreatePaymentIntent(ctx, "order-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "pay-1", payment.ID)
+		repo.AssertNotCalled(t, "CreatePayment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("New attempt after a failed payment", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), time.Minute)
+		failed := domain.Payment{ID: "pay-1", OrderID: "order-1", Status: domain.PaymentStatusFailed}
+		repo.On("GetOrderByID", ctx, "order-1").Return(pendingOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{failed}, nil).Once()
+		repo.On("CreatePayment", ctx, mock.AnythingOfType("*domain.Payment")).Return(nil).Once()
+
+		payment, err := svc.CreatePaymentIntent(ctx, "order-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PaymentStatusPending, payment.Status)
+		assert.True(t, payment.Amount.Equal(idr(45000)))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Amount above 10^8 is kept exactly", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), time.Minute)
+		// 8 x Rp14.000.000: melebihi DECIMAL(10, 2), payments.amount harus selebar orders.total_amount
+		largeOrder := &domain.Order{ID: "order-1", TotalAmount: idr(112000000), Status: domain.StatusPendingPayment}
+		repo.On("GetOrderByID", ctx, "order-1").Return(largeOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{}, nil).Once()
+		repo.On("CreatePayment", ctx, mock.MatchedBy(func(p *domain.Payment) bool {
+			return p.Amount.Equal(idr(112000000))
+		})).Return(nil).Once()
+
+		payment, err := svc.CreatePaymentIntent(ctx, "order-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "112000000", payment.Amount.Decimal())
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Provider unavailable", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		provider := failingPaymentProvider{NewFakePaymentProvider("test-secret", "")}
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), provider, time.Minute)
+		repo.On("GetOrderByID", ctx, "order-1").Return(pendingOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{}, nil).Once()
+
+		_, err := svc.CreatePaymentIntent(ctx, "order-1")
+
+		assert.ErrorIs(t, err, ErrPaymentProviderFailed)
+	})
+
+	t.Run("Order no longer awaiting payment", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), time.Minute)
+		repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusPaymentTimeout}, nil).Once()
+
+		_, err := svc.CreatePaymentIntent(ctx, "order-1")
+
+		assert.ErrorIs(t, err, ErrOrderNotPayable)
+	})
+}
+
+func TestOrderService_ProcessPaymentTimeouts_SucceededPayment(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	allowSagaPersistence(repo)
+	timeout := 30 * time.Minute
+	svc := NewOrderService(repo, new(serviceMocks.MockWarehouseClientForOrder), new(serviceMocks.MockProductClientForOrder), NewFakePaymentProvider("test-secret", ""), timeout)
+	ctx := context.Background()
+
+	pendingOrder := domain.Order{ID: "order-1", Status: domain.StatusPendingPayment}
+	paid := domain.Payment{ID: "pay-1", OrderID: "order-1", Provider: FakeProviderName, Status: domain.PaymentStatusSucceeded}
+	repo.On("GetPendingOrdersOlderThan", ctx, timeout).Return([]domain.Order{pendingOrder}, nil).Once()
+	repo.On("GetPaymentsByOrderID", ctx, "order-1").Return([]domain.Payment{paid}, nil).Once()
+	repo.On("GetOrderByID", ctx, "order-1").Return(&pendingOrder, nil).Once()
+	repo.On("TransitionOrderStatus", ctx, transition("order-1", domain.StatusPendingPayment, domain.StatusPaymentConfirmed)).Return(nil).Once()
+	repo.On("GetSagaByOrderID", ctx, domain.SagaTypeCheckout, "order-1").Return(nil, oRepo.ErrSagaNotFound).Once()
+
+	svc.ProcessPaymentTimeouts(ctx)
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "TransitionOrderStatus", ctx, transition("order-1", domain.StatusPendingPayment, domain.StatusPaymentTimeout))
+}
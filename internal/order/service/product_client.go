@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	productDomain "github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
+)
+
+type ProductClient interface {
+	// GetProductPrices mengambil harga resmi beserta nama/SKU produk. Produk yang tidak ada tidak dikembalikan.
+	GetProductPrices(ctx context.Context, productIDs []string) ([]productDomain.ProductPrice, error)
+}
+
+type httpProductClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewHTTPProductClient(baseURL string) ProductClient {
+	return &httpProductClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (c *httpProductClient) GetProductPrices(ctx context.Context, productIDs []string) ([]productDomain.ProductPrice, error) {
+	prices := []productDomain.ProductPrice{}
+	// Product Service membatasi jumlah ID per request, jadi order besar dipecah per batch
+	for start := 0; start < len(productIDs); start += productDomain.MaxProductPriceLookup {
+		end := min(start+productDomain.MaxProductPriceLookup, len(productIDs))
+		batch, err := c.getPriceBatch(ctx, productIDs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, batch...)
+	}
+	return prices, nil
+}
+
+func (c *httpProductClient) getPriceBatch(ctx context.Context, productIDs []string) ([]productDomain.ProductPrice, error) {
+	jsonPayload, err := json.Marshal(productDomain.ProductPricesRequest{ProductIDs: productIDs})
+	if err != nil {
+		logger.Error("ProductClient.GetProductPrices: Marshal failed", err, nil)
+		return nil, fmt.Errorf("failed to marshal product price request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/v1/products/prices", bytes.NewReader(jsonPayload))
+	if err != nil {
+		logger.Error("ProductClient.GetProductPrices: NewRequest failed", err, nil)
+		return nil, fmt.Errorf("failed to create request to product service: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		logger.Error("ProductClient.GetProductPrices: HTTPClient.Do failed", err, nil)
+		return nil, fmt.Errorf("failed to call product service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		logger.Error(fmt.Sprintf("ProductClient.GetProductPrices: product service returned status %d", resp.StatusCode), nil, errResp.Error)
+		return nil, fmt.Errorf("product service returned status %d: %s", resp.StatusCode, errResp.Error)
+	}
+
+	var pricesResp productDomain.ProductPricesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pricesResp); err != nil {
+		logger.Error("ProductClient.GetProductPrices: JSON decode failed", err, nil)
+		return nil, fmt.Errorf("failed to decode response from product service: %w", err)
+	}
+	return pricesResp.Prices, nil
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
+)
+
+var (
+	ErrOrderNotReturnable      = errors.New("order cannot be returned in its current status")
+	ErrInvalidReturnRequest    = errors.New("invalid return request")
+	ErrInvalidReturnQuery      = errors.New("invalid return list query")
+	ErrReturnInvalidState      = errors.New("return request is not in the required status")
+	ErrReturnWarehouseRejected = errors.New("warehouse rejected the returned stock")
+	ErrReturnRestockFailed     = errors.New("failed to restock returned items")
+	ErrOrderNotRefundable      = errors.New("order cannot be refunded in its current status")
+	ErrNoRefundablePayment     = errors.New("order has no succeeded payment to refund")
+	ErrInvalidRefundAmount     = errors.New("refund amount must be positive and in the payment currency")
+	ErrRefundProviderFailed    = errors.New("payment provider failed to process the refund")
+)
+
+// CreateReturn mengajukan retur atas item order yang sudah diterima customer (DELIVERED atau PARTIALLY_REFUNDED).
+// Nominal refund setiap item dihitung dari harga saat pembelian.
+func (s *orderServiceImpl) CreateReturn(ctx context.Context, orderID string, req domain.CreateReturnRequest) (*domain.ReturnRequest, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != domain.StatusDelivered && order.Status != domain.StatusPartiallyRefunded {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotReturnable, order.Status)
+	}
+
+	orderItems, err := s.orderRepo.GetOrderItemsByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	itemsByID := make(map[string]domain.OrderItem, len(orderItems))
+	for _, item := range orderItems {
+		itemsByID[item.ID] = item
+	}
+
+	ret := &domain.ReturnRequest{OrderID: orderID, UserID: order.UserID}
+	if req.Note != "" {
+		ret.Note = &req.Note
+	}
+	seen := make(map[string]bool, len(req.Items))
+	for _, reqItem := range req.Items {
+		orderItem, ok := itemsByID[reqItem.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: order item %s does not belong to order %s", ErrInvalidReturnRequest, reqItem.OrderItemID, orderID)
+		}
+		if seen[reqItem.OrderItemID] {
+			return nil, fmt.Errorf("%w: order item %s is listed more than once", ErrInvalidReturnRequest, reqItem.OrderItemID)
+		}
+		seen[reqItem.OrderItemID] = true
+		if reqItem.Quantity > orderItem.Quantity {
+			return nil, fmt.Errorf("%w: order item %s was purchased %d time(s), requested %d",
+				repository.ErrReturnQuantityExceeded, reqItem.OrderItemID, orderItem.Quantity, reqItem.Quantity)
+		}
+		refundAmount, err := orderItem.PriceAtPurchase.Mul(int64(reqItem.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		ret.Items = append(ret.Items, domain.ReturnItem{
+			OrderItemID:  orderItem.ID,
+			ProductID:    orderItem.ProductID,
+			Quantity:     reqItem.Quantity,
+			Reason:       reqItem.Reason,
+			Condition:    reqItem.Condition,
+			RefundAmount: refundAmount,
+		})
+	}
+
+	if err := s.orderRepo.CreateReturnRequest(ctx, ret); err != nil {
+		if !errors.Is(err, repository.ErrReturnQuantityExceeded) {
+			logger.Error(fmt.Sprintf("CreateReturn: failed to save return for order %s", orderID), err, nil)
+		}
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Return %s requested for order %s (%d item(s))", ret.ID, orderID, len(ret.Items)))
+	return ret, nil
+}
+
+func (s *orderServiceImpl) ListOrderReturns(ctx context.Context, orderID string) ([]domain.ReturnRequest, error) {
+	if _, err := s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.ListReturnRequestsByOrderID(ctx, orderID)
+}
+
+func (s *orderServiceImpl) GetReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error) {
+	return s.orderRepo.GetReturnRequestByID(ctx, returnID)
+}
+
+func (s *orderServiceImpl) ListReturns(ctx context.Context, filter domain.ListReturnsFilter) ([]domain.ReturnRequest, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidReturnQuery, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultReturnListLimit
+	}
+	if filter.Limit > domain.MaxReturnListLimit {
+		filter.Limit = domain.MaxReturnListLimit
+	}
+	return s.orderRepo.ListReturnRequests(ctx, filter)
+}
+
+func (s *orderServiceImpl) ApproveReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error) {
+	ret, err := s.loadReturnInStatus(ctx, returnID, domain.ReturnStatusRequested)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.transitionReturn(ctx, ret, domain.ReturnTransition{To: domain.ReturnStatusApproved}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *orderServiceImpl) RejectReturn(ctx context.Context, returnID string, reason string) (*domain.ReturnRequest, error) {
+	ret, err := s.loadReturnInStatus(ctx, returnID, domain.ReturnStatusRequested)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.transitionReturn(ctx, ret, domain.ReturnTransition{To: domain.ReturnStatusRejected, RejectionReason: reason}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ReceiveReturn mencatat barang retur yang sudah sampai di gudang:
+//  1. Item yang tidak DAMAGED dimasukkan kembali ke stok gudang warehouseID. ID return item dipakai sebagai referensi,
+//     sehingga penerimaan yang diulang (misal setelah gagal di tengah jalan) tidak menambah stok dua kali.
+//  2. Retur menjadi RECEIVED, lalu nominal item retur di-refund ke payment order.
+//
+// Jika refund gagal, retur tetap RECEIVED dan bisa di-refund ulang lewat RefundReturn.
+func (s *orderServiceImpl) ReceiveReturn(ctx context.Context, returnID, warehouseID string) (*domain.ReturnRequest, error) {
+	ret, err := s.loadReturnInStatus(ctx, returnID, domain.ReturnStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range ret.Items {
+		if !item.Condition.Restockable() {
+			logger.Info(fmt.Sprintf("ReceiveReturn: item %s of return %s is %s, not restocked", item.ID, ret.ID, item.Condition))
+			continue
+		}
+		if _, err := s.warehouseClient.ReceiveReturn(ctx, item.ID, warehouseID, item.ProductID, item.Quantity); err != nil {
+			if isWarehouseRejection(err) {
+				return nil, fmt.Errorf("%w: %v", ErrReturnWarehouseRejected, err)
+			}
+			logger.Error(fmt.Sprintf("ReceiveReturn: failed to restock item %s of return %s", item.ID, ret.ID), err, nil)
+			return nil, fmt.Errorf("%w: %v", ErrReturnRestockFailed, err)
+		}
+	}
+
+	if err := s.transitionReturn(ctx, ret, domain.ReturnTransition{To: domain.ReturnStatusReceived, WarehouseID: warehouseID}); err != nil {
+		return nil, err
+	}
+	return s.refundReturn(ctx, ret)
+}
+
+// RefundReturn mengulang refund untuk retur RECEIVED yang refund-nya gagal sebelumnya.
+func (s *orderServiceImpl) RefundReturn(ctx context.Context, returnID string) (*domain.ReturnRequest, error) {
+	ret, err := s.loadReturnInStatus(ctx, returnID, domain.ReturnStatusReceived)
+	if err != nil {
+		return nil, err
+	}
+	return s.refundReturn(ctx, ret)
+}
+
+func (s *orderServiceImpl) refundReturn(ctx context.Context, ret *domain.ReturnRequest) (*domain.ReturnRequest, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := ret.RefundTotal()
+	if err != nil {
+		return nil, err
+	}
+	refund, err := s.issueRefund(ctx, order, amount, fmt.Sprintf("return %s", ret.ID), &ret.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Return %s received but refund failed; retry via RefundReturn", ret.ID), err, nil)
+		return nil, err
+	}
+	if err := s.transitionReturn(ctx, ret, domain.ReturnTransition{To: domain.ReturnStatusRefunded, RefundID: refund.ID}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *orderServiceImpl) loadReturnInStatus(ctx context.Context, returnID string, status domain.ReturnStatus) (*domain.ReturnRequest, error) {
+	ret, err := s.orderRepo.GetReturnRequestByID(ctx, returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != status {
+		return nil, fmt.Errorf("%w: return %s is %s, expected %s", ErrReturnInvalidState, returnID, ret.Status, status)
+	}
+	return ret, nil
+}
+
+// transitionReturn memindahkan ret dari status saat ini ke t.To dan memperbarui struct-nya.
+func (s *orderServiceImpl) transitionReturn(ctx context.Context, ret *domain.ReturnRequest, t domain.ReturnTransition) error {
+	t.ReturnID = ret.ID
+	t.From = ret.Status
+	if err := s.orderRepo.TransitionReturnRequest(ctx, t); err != nil {
+		if errors.Is(err, repository.ErrReturnStatusConflict) {
+			return fmt.Errorf("%w: %v", ErrReturnInvalidState, err)
+		}
+		return err
+	}
+	ret.Status = t.To
+	if t.RejectionReason != "" {
+		ret.RejectionReason = &t.RejectionReason
+	}
+	if t.WarehouseID != "" {
+		ret.WarehouseID = &t.WarehouseID
+	}
+	if t.RefundID != "" {
+		ret.RefundID = &t.RefundID
+	}
+	logger.Info(fmt.Sprintf("Return %s of order %s: %s -> %s", ret.ID, ret.OrderID, t.From, t.To))
+	return nil
+}
+
+// CreateRefund (admin) mengembalikan dana di luar alur retur, misal kompensasi atau order yang dibatalkan setelah dibayar.
+func (s *orderServiceImpl) CreateRefund(ctx context.Context, orderID string, req domain.CreateRefundRequest) (*domain.Refund, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	switch order.Status {
+	case domain.StatusShipped, domain.StatusDelivered, domain.StatusPartiallyRefunded:
+	case domain.StatusCancelled:
+		if !order.RefundRequired {
+			return nil, fmt.Errorf("%w: cancelled order %s was never paid or is already refunded", ErrOrderNotRefundable, orderID)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotRefundable, order.Status)
+	}
+	if req.Amount == nil {
+		return nil, ErrInvalidRefundAmount
+	}
+	return s.issueRefund(ctx, order, *req.Amount, req.Reason, nil)
+}
+
+func (s *orderServiceImpl) GetOrderRefunds(ctx context.Context, orderID string) ([]domain.Refund, error) {
+	if _, err := s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetRefundsByOrderID(ctx, orderID)
+}
+
+// issueRefund mencatat refund PENDING (dibatasi nominal payment), meminta provider mengembalikan dana,
+// lalu menyesuaikan status order. Refund untuk retur yang sudah tercatat dipakai ulang: SUCCEEDED tidak dikirim lagi,
+// sedangkan PENDING (proses sebelumnya terhenti) dikirim ulang dengan idempotency key yang sama.
+func (s *orderServiceImpl) issueRefund(ctx context.Context, order *domain.Order, amount money.Money, reason string, returnID *string) (*domain.Refund, error) {
+	payment, err := s.hasSucceededPayment(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, ErrNoRefundablePayment
+	}
+	if !amount.IsPositive() || amount.Currency() != payment.Amount.Currency() {
+		return nil, fmt.Errorf("%w: got %s for a %s payment", ErrInvalidRefundAmount, amount, payment.Amount.Currency())
+	}
+
+	var refund *domain.Refund
+	if returnID != nil {
+		if refund, err = s.existingReturnRefund(ctx, order.ID, *returnID); err != nil {
+			return nil, err
+		}
+	}
+	if refund == nil {
+		refund = &domain.Refund{
+			OrderID:   order.ID,
+			PaymentID: payment.ID,
+			ReturnID:  returnID,
+			Amount:    amount,
+			Status:    domain.RefundStatusPending,
+			Reason:    reason,
+			CreatedBy: actorFromContext(ctx),
+		}
+		if err := s.orderRepo.CreateRefund(ctx, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if refund.Status == domain.RefundStatusPending {
+		result, err := s.paymentProvider.Refund(ctx, RefundRequest{
+			ProviderPaymentID: payment.ProviderPaymentID,
+			Amount:            refund.Amount,
+			IdempotencyKey:    "refund-" + refund.ID,
+		})
+		if err != nil {
+			if markErr := s.orderRepo.CompleteRefund(ctx, refund.ID, domain.RefundStatusFailed, "", err.Error()); markErr != nil {
+				logger.Error(fmt.Sprintf("issueRefund: failed to mark refund %s as failed", refund.ID), markErr, nil)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrRefundProviderFailed, err)
+		}
+		if err := s.orderRepo.CompleteRefund(ctx, refund.ID, domain.RefundStatusSucceeded, result.ProviderRefundID, ""); err != nil {
+			// Dana sudah dikembalikan provider; refund tetap PENDING dan percobaan berikutnya memakai idempotency key yang sama
+			logger.Error(fmt.Sprintf("CRITICAL: refund %s succeeded at provider (%s) but could not be recorded", refund.ID, result.ProviderRefundID), err, nil)
+			return nil, err
+		}
+		refund.Status = domain.RefundStatusSucceeded
+		refund.ProviderRefundID = &result.ProviderRefundID
+		logger.Info(fmt.Sprintf("Refunded %s for order %s (refund %s)", refund.Amount, order.ID, refund.ID))
+	}
+
+	s.settleRefundedOrder(ctx, order)
+	return refund, nil
+}
+
+func (s *orderServiceImpl) existingReturnRefund(ctx context.Context, orderID, returnID string) (*domain.Refund, error) {
+	refunds, err := s.orderRepo.GetRefundsByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range refunds {
+		rf := &refunds[i]
+		if rf.ReturnID != nil && *rf.ReturnID == returnID && rf.Status != domain.RefundStatusFailed {
+			return rf, nil
+		}
+	}
+	return nil, nil
+}
+
+// settleRefundedOrder menyesuaikan order dengan total refund yang berhasil: PARTIALLY_REFUNDED atau REFUNDED,
+// atau menghapus flag refund_required pada order yang dibatalkan setelah dibayar. Kegagalan hanya dicatat
+// karena dana sudah dikembalikan.
+func (s *orderServiceImpl) settleRefundedOrder(ctx context.Context, order *domain.Order) {
+	refunds, err := s.orderRepo.GetRefundsByOrderID(ctx, order.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("settleRefundedOrder: failed to load refunds of order %s", order.ID), err, nil)
+		return
+	}
+	refunded := money.Zero(order.TotalAmount.Currency())
+	for _, rf := range refunds {
+		if rf.Status != domain.RefundStatusSucceeded {
+			continue
+		}
+		if refunded, err = refunded.Add(rf.Amount); err != nil {
+			logger.Error(fmt.Sprintf("settleRefundedOrder: cannot total refunds of order %s", order.ID), err, nil)
+			return
+		}
+	}
+	fullyRefunded := refunded.MinorUnits() >= order.TotalAmount.MinorUnits()
+
+	if order.Status == domain.StatusCancelled {
+		if fullyRefunded && order.RefundRequired {
+			if err := s.orderRepo.ClearRefundRequired(ctx, order.ID); err != nil {
+				logger.Error(fmt.Sprintf("settleRefundedOrder: failed to clear refund flag of order %s", order.ID), err, nil)
+				return
+			}
+			order.RefundRequired = false
+		}
+		return
+	}
+
+	target := domain.StatusPartiallyRefunded
+	if fullyRefunded {
+		target = domain.StatusRefunded
+	}
+	if order.Status == target || !order.Status.CanTransitionTo(target) {
+		return
+	}
+	err = s.orderRepo.TransitionOrderStatus(ctx, domain.StatusTransition{
+		OrderID: order.ID,
+		From:    order.Status,
+		To:      target,
+		Actor:   actorFromContext(ctx),
+		Reason:  fmt.Sprintf("refunded %s of %s", refunded, order.TotalAmount),
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("settleRefundedOrder: failed to move order %s to %s", order.ID, target), err, nil)
+		return
+	}
+	order.Status = target
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	oRepo "github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository/mocks"
+	serviceMocks "github.com/ridloal/e-commerce-go-microservices/internal/order/service/mocks"
+	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newReturnTestService(provider PaymentProvider) (*mocks.MockOrderRepository, *serviceMocks.MockWarehouseClientForOrder, OrderService) {
+	repo := new(mocks.MockOrderRepository)
+	wc := new(serviceMocks.MockWarehouseClientForOrder)
+	svc := NewOrderService(repo, wc, new(serviceMocks.MockProductClientForOrder), provider, time.Minute)
+	return repo, wc, svc
+}
+
+func succeededPayment() []domain.Payment {
+	return []domain.Payment{{ID: "pay-1", OrderID: "order-1", Provider: FakeProviderName, ProviderPaymentID: "fake_pi_1",
+		Amount: idr(45000), Status: domain.PaymentStatusSucceeded}}
+}
+
+func TestOrderService_CreateReturn(t *testing.T) {
+	ctx := context.Background()
+	orderItems := []domain.OrderItem{
+		{ID: "item-1", ProductID: "prod-1", Quantity: 2, PriceAtPurchase: idr(15000)},
+		{ID: "item-2", ProductID: "prod-2", Quantity: 1, PriceAtPurchase: idr(15000)},
+	}
+	deliveredOrder := func() *domain.Order {
+		return &domain.Order{ID: "order-1", UserID: "user-1", TotalAmount: idr(45000), Status: domain.StatusDelivered}
+	}
+
+	t.Run("Refund amount is taken from the purchase price", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(deliveredOrder(), nil).Once()
+		repo.On("GetOrderItemsByOrderID", ctx, "order-1").Return(orderItems, nil).Once()
+		repo.On("CreateReturnRequest", ctx, mock.MatchedBy(func(ret *domain.ReturnRequest) bool {
+			return ret.UserID == "user-1" && len(ret.Items) == 1 && ret.Items[0].ProductID == "prod-1" &&
+				ret.Items[0].RefundAmount.Equal(idr(30000))
+		})).Return(nil).Once()
+
+		ret, err := svc.CreateReturn(ctx, "order-1", domain.CreateReturnRequest{Items: []domain.CreateReturnItemRequest{
+			{OrderItemID: "item-1", Quantity: 2, Reason: "wrong size", Condition: domain.ItemConditionUnopened},
+		}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "order-1", ret.OrderID)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Order that is not delivered cannot be returned", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		shipped := deliveredOrder()
+		shipped.Status = domain.StatusShipped
+		repo.On("GetOrderByID", ctx, "order-1").Return(shipped, nil).Once()
+
+		_, err := svc.CreateReturn(ctx, "order-1", domain.CreateReturnRequest{Items: []domain.CreateReturnItemRequest{
+			{OrderItemID: "item-1", Quantity: 1, Reason: "late", Condition: domain.ItemConditionUnopened},
+		}})
+
+		assert.ErrorIs(t, err, ErrOrderNotReturnable)
+	})
+
+	t.Run("Quantity above the purchased quantity is rejected", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(deliveredOrder(), nil).Once()
+		repo.On("GetOrderItemsByOrderID", ctx, "order-1").Return(orderItems, nil).Once()
+
+		_, err := svc.CreateReturn(ctx, "order-1", domain.CreateReturnRequest{Items: []domain.CreateReturnItemRequest{
+			{OrderItemID: "item-2", Quantity: 2, Reason: "broken", Condition: domain.ItemConditionDamaged},
+		}})
+
+		assert.ErrorIs(t, err, oRepo.ErrReturnQuantityExceeded)
+		repo.AssertNotCalled(t, "CreateReturnRequest", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Item from another order is rejected", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(deliveredOrder(), nil).Once()
+		repo.On("GetOrderItemsByOrderID", ctx, "order-1").Return(orderItems, nil).Once()
+
+		_, err := svc.CreateReturn(ctx, "order-1", domain.CreateReturnRequest{Items: []domain.CreateReturnItemRequest{
+			{OrderItemID: "item-other", Quantity: 1, Reason: "broken", Condition: domain.ItemConditionOpened},
+		}})
+
+		assert.ErrorIs(t, err, ErrInvalidReturnRequest)
+	})
+}
+
+func TestOrderService_ReceiveReturn(t *testing.T) {
+	ctx := context.Background()
+	approvedReturn := func() *domain.ReturnRequest {
+		return &domain.ReturnRequest{ID: "ret-1", OrderID: "order-1", Status: domain.ReturnStatusApproved, Items: []domain.ReturnItem{
+			{ID: "ri-1", ProductID: "prod-1", Quantity: 1, Condition: domain.ItemConditionUnopened, RefundAmount: idr(15000)},
+			{ID: "ri-2", ProductID: "prod-2", Quantity: 1, Condition: domain.ItemConditionDamaged, RefundAmount: idr(15000)},
+		}}
+	}
+	deliveredOrder := &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusDelivered}
+	toReceived := mock.MatchedBy(func(tr domain.ReturnTransition) bool {
+		return tr.ReturnID == "ret-1" && tr.From == domain.ReturnStatusApproved && tr.To == domain.ReturnStatusReceived && tr.WarehouseID == "wh-1"
+	})
+
+	t.Run("Restocks undamaged items, refunds and partially refunds the order", func(t *testing.T) {
+		repo, wc, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetReturnRequestByID", ctx, "ret-1").Return(approvedReturn(), nil).Once()
+		wc.On("ReceiveReturn", ctx, "ri-1", "wh-1", "prod-1", 1).Return(&warehouseDomain.ReceiveReturnResponse{}, nil).Once()
+		repo.On("TransitionReturnRequest", ctx, toReceived).Return(nil).Once()
+		repo.On("GetOrderByID", ctx, "order-1").Return(deliveredOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return(succeededPayment(), nil).Once()
+		repo.On("GetRefundsByOrderID", ctx, "order-1").Return([]domain.Refund{}, nil).Once()
+		repo.On("CreateRefund", ctx, mock.MatchedBy(func(rf *domain.Refund) bool {
+			return rf.PaymentID == "pay-1" && rf.Amount.Equal(idr(30000)) && rf.ReturnID != nil && *rf.ReturnID == "ret-1"
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Refund).ID = "refund-1"
+		}).Return(nil).Once()
+		repo.On("CompleteRefund", ctx, "refund-1", domain.RefundStatusSucceeded, mock.AnythingOfType("string"), "").Return(nil).Once()
+		repo.On("GetRefundsByOrderID", ctx, "order-1").Return([]domain.Refund{
+			{ID: "refund-1", Amount: idr(30000), Status: domain.RefundStatusSucceeded},
+		}, nil).Once()
+		repo.On("TransitionOrderStatus", ctx, mock.MatchedBy(func(tr domain.StatusTransition) bool {
+			return tr.From == domain.StatusDelivered && tr.To == domain.StatusPartiallyRefunded
+		})).Return(nil).Once()
+		repo.On("TransitionReturnRequest", ctx, mock.MatchedBy(func(tr domain.ReturnTransition) bool {
+			return tr.From == domain.ReturnStatusReceived && tr.To == domain.ReturnStatusRefunded && tr.RefundID == "refund-1"
+		})).Return(nil).Once()
+
+		ret, err := svc.ReceiveReturn(ctx, "ret-1", "wh-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ReturnStatusRefunded, ret.Status)
+		assert.Equal(t, "refund-1", *ret.RefundID)
+		repo.AssertExpectations(t)
+		wc.AssertNotCalled(t, "ReceiveReturn", ctx, "ri-2", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failed refund leaves the return received", func(t *testing.T) {
+		repo, wc, svc := newReturnTestService(failingPaymentProvider{NewFakePaymentProvider("test-secret", "")})
+		repo.On("GetReturnRequestByID", ctx, "ret-1").Return(approvedReturn(), nil).Once()
+		wc.On("ReceiveReturn", ctx, "ri-1", "wh-1", "prod-1", 1).Return(&warehouseDomain.ReceiveReturnResponse{}, nil).Once()
+		repo.On("TransitionReturnRequest", ctx, toReceived).Return(nil).Once()
+		repo.On("GetOrderByID", ctx, "order-1").Return(deliveredOrder, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return(succeededPayment(), nil).Once()
+		repo.On("GetRefundsByOrderID", ctx, "order-1").Return([]domain.Refund{}, nil).Once()
+		repo.On("CreateRefund", ctx, mock.AnythingOfType("*domain.Refund")).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Refund).ID = "refund-1"
+		}).Return(nil).Once()
+		repo.On("CompleteRefund", ctx, "refund-1", domain.RefundStatusFailed, "", mock.AnythingOfType("string")).Return(nil).Once()
+
+		_, err := svc.ReceiveReturn(ctx, "ret-1", "wh-1")
+
+		assert.ErrorIs(t, err, ErrRefundProviderFailed)
+		repo.AssertExpectations(t)
+		repo.AssertNumberOfCalls(t, "TransitionReturnRequest", 1)
+	})
+
+	t.Run("Return that is not approved cannot be received", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		requested := approvedReturn()
+		requested.Status = domain.ReturnStatusRequested
+		repo.On("GetReturnRequestByID", ctx, "ret-1").Return(requested, nil).Once()
+
+		_, err := svc.ReceiveReturn(ctx, "ret-1", "wh-1")
+
+		assert.ErrorIs(t, err, ErrReturnInvalidState)
+	})
+}
+
+func TestOrderService_CreateRefund(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Full refund of a cancelled paid order clears the refund flag", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		cancelled := &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusCancelled, RefundRequired: true}
+		repo.On("GetOrderByID", ctx, "order-1").Return(cancelled, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return(succeededPayment(), nil).Once()
+		repo.On("CreateRefund", ctx, mock.AnythingOfType("*domain.Refund")).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Refund).ID = "refund-1"
+		}).Return(nil).Once()
+		repo.On("CompleteRefund", ctx, "refund-1", domain.RefundStatusSucceeded, mock.AnythingOfType("string"), "").Return(nil).Once()
+		repo.On("GetRefundsByOrderID", ctx, "order-1").Return([]domain.Refund{
+			{ID: "refund-1", Amount: idr(45000), Status: domain.RefundStatusSucceeded},
+		}, nil).Once()
+		repo.On("ClearRefundRequired", ctx, "order-1").Return(nil).Once()
+
+		refund, err := svc.CreateRefund(ctx, "order-1", domain.CreateRefundRequest{Amount: idrPtr(45000), Reason: "cancelled after payment"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RefundStatusSucceeded, refund.Status)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Refund above the remaining payment amount is rejected", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		delivered := &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusPartiallyRefunded}
+		repo.On("GetOrderByID", ctx, "order-1").Return(delivered, nil).Once()
+		repo.On("GetPaymentsByOrderID", ctx, "order-1").Return(succeededPayment(), nil).Once()
+		repo.On("CreateRefund", ctx, mock.AnythingOfType("*domain.Refund")).Return(oRepo.ErrRefundExceedsPayment).Once()
+
+		_, err := svc.CreateRefund(ctx, "order-1", domain.CreateRefundRequest{Amount: idrPtr(40000), Reason: "goodwill"})
+
+		assert.ErrorIs(t, err, oRepo.ErrRefundExceedsPayment)
+	})
+
+	t.Run("Unpaid cancelled order cannot be refunded", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		cancelled := &domain.Order{ID: "order-1", TotalAmount: idr(45000), Status: domain.StatusCancelled}
+		repo.On("GetOrderByID", ctx, "order-1").Return(cancelled, nil).Once()
+
+		_, err := svc.CreateRefund(ctx, "order-1", domain.CreateRefundRequest{Amount: idrPtr(45000), Reason: "oops"})
+
+		assert.ErrorIs(t, err, ErrOrderNotRefundable)
+	})
+}
+
+func TestFakePaymentProvider_RefundIsIdempotent(t *testing.T) {
+	provider := NewFakePaymentProvider("test-secret", "")
+	req := RefundRequest{ProviderPaymentID: "fake_pi_1", Amount: idr(15000), IdempotencyKey: "refund-1"}
+
+	first, err := provider.Refund(context.Background(), req)
+	assert.NoError(t, err)
+	second, err := provider.Refund(context.Background(), req)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first.ProviderRefundID, second.ProviderRefundID)
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+var (
+	ErrSagaNotRetryable = errors.New("only STUCK sagas can be retried")
+	ErrInvalidSagaQuery = errors.New("invalid saga list query")
+	errUnknownSagaType  = errors.New("unknown saga type")
+	// errSagaWaiting dikembalikan langkah yang menunggu kejadian dari luar; saga berhenti di WAITING sampai Signal dipanggil.
+	errSagaWaiting = errors.New("saga step is waiting for an external event")
+)
+
+const (
+	defaultSagaMaxAttempts = 5
+	defaultSagaBatchSize   = 20
+	sagaBaseBackoff        = 10 * time.Second
+	sagaMaxBackoff         = 10 * time.Minute
+	// sagaLease menahan saga yang sedang dijalankan agar tidak diambil ResumeDue di instance lain.
+	sagaLease = 2 * time.Minute
+	// sagaWaitPollInterval: saga WAITING tetap diperiksa ulang berkala, untuk berjaga-jaga jika Signal terlewat.
+	sagaWaitPollInterval = 5 * time.Minute
+)
+
+// permanentSagaError menandai kegagalan yang tidak akan berhasil jika diulang (misal stok tidak cukup).
+type permanentSagaError struct {
+	err error
+}
+
+func (e permanentSagaError) Error() string { return e.err.Error() }
+func (e permanentSagaError) Unwrap() error { return e.err }
+
+// permanentFailure membungkus err sebagai kegagalan permanen: saga langsung dikompensasi tanpa retry.
+func permanentFailure(err error) error {
+	return permanentSagaError{err: err}
+}
+
+func isPermanentFailure(err error) bool {
+	var p permanentSagaError
+	return errors.As(err, &p)
+}
+
+// SagaStep adalah satu langkah saga. Execute dan Compensate bisa dipanggil ulang (retry atau setelah restart),
+// sehingga keduanya harus idempoten. Compensate nil berarti langkah tidak perlu dibatalkan.
+// Langkah Pivot adalah titik tanpa kembali: setelah berhasil, kegagalan langkah berikutnya hanya di-retry, tidak dikompensasi.
+type SagaStep struct {
+	Name       string
+	Execute    func(ctx context.Context, saga *domain.Saga) error
+	Compensate func(ctx context.Context, saga *domain.Saga) error
+	Pivot      bool
+}
+
+// SagaOrchestrator menjalankan saga dan menyimpan statusnya setelah setiap langkah.
+// Kegagalan sementara di-retry dengan exponential backoff; setelah MaxAttempts percobaan saga menjadi STUCK.
+// Kegagalan permanen sebelum langkah pivot menjalankan kompensasi dari langkah terakhir yang berhasil ke belakang.
+type SagaOrchestrator struct {
+	repo        repository.OrderRepository
+	definitions map[string][]SagaStep
+	MaxAttempts int
+	BatchSize   int
+	now         func() time.Time
+}
+
+func NewSagaOrchestrator(repo repository.OrderRepository) *SagaOrchestrator {
+	return &SagaOrchestrator{
+		repo:        repo,
+		definitions: make(map[string][]SagaStep),
+		MaxAttempts: defaultSagaMaxAttempts,
+		BatchSize:   defaultSagaBatchSize,
+		now:         time.Now,
+	}
+}
+
+// Register mendaftarkan langkah-langkah untuk satu tipe saga.
+func (o *SagaOrchestrator) Register(sagaType string, steps []SagaStep) {
+	o.definitions[sagaType] = steps
+}
+
+// Start menyimpan saga baru lalu langsung menjalankannya sampai selesai, menunggu, atau dijadwalkan ulang.
+func (o *SagaOrchestrator) Start(ctx context.Context, sagaType, orderID string, data interface{}) (*domain.Saga, error) {
+	saga, err := o.Prepare(sagaType, orderID, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.repo.CreateSaga(ctx, saga); err != nil {
+		return nil, err
+	}
+	return saga, o.advance(ctx, saga)
+}
+
+// Prepare menyusun saga baru tanpa menyimpannya, untuk disimpan pemanggil dalam transaksinya sendiri lalu dijalankan dengan Run.
+// Jika proses mati sebelum Run, saga dilanjutkan ResumeDue setelah lease-nya habis.
+func (o *SagaOrchestrator) Prepare(sagaType, orderID string, data interface{}) (*domain.Saga, error) {
+	steps, ok := o.definitions[sagaType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownSagaType, sagaType)
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s saga data: %w", sagaType, err)
+	}
+
+	saga := &domain.Saga{
+		Type:          sagaType,
+		OrderID:       orderID,
+		Status:        domain.SagaStatusRunning,
+		Steps:         make([]domain.SagaStepState, len(steps)),
+		Data:          payload,
+		NextAttemptAt: o.now().Add(sagaLease),
+	}
+	for i, step := range steps {
+		saga.Steps[i] = domain.SagaStepState{Name: step.Name, Status: domain.SagaStepPending}
+	}
+	return saga, nil
+}
+
+// Run menjalankan saga dari Prepare yang sudah disimpan pemanggil.
+func (o *SagaOrchestrator) Run(ctx context.Context, saga *domain.Saga) error {
+	return o.advance(ctx, saga)
+}
+
+// Signal melanjutkan saga yang sedang WAITING untuk order tersebut, misal setelah status pembayaran berubah.
+// Saga dengan status lain dikembalikan apa adanya.
+func (o *SagaOrchestrator) Signal(ctx context.Context, sagaType, orderID string) (*domain.Saga, error) {
+	saga, err := o.repo.GetSagaByOrderID(ctx, sagaType, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if saga.Status != domain.SagaStatusWaiting {
+		return saga, nil
+	}
+	saga.Status = domain.SagaStatusRunning
+	saga.NextAttemptAt = o.now().Add(sagaLease)
+	if err := o.repo.UpdateSaga(ctx, saga); err != nil {
+		return nil, err
+	}
+	return saga, o.advance(ctx, saga)
+}
+
+// Abort menghentikan saga yang masih berjalan maju dengan menganggap langkah saat ini gagal permanen,
+// lalu menjalankan kompensasi. Tidak berpengaruh jika saga sudah melewati langkah pivot.
+func (o *SagaOrchestrator) Abort(ctx context.Context, saga *domain.Saga, cause error) error {
+	steps, ok := o.definitions[saga.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownSagaType, saga.Type)
+	}
+	if saga.Status != domain.SagaStatusRunning || pivotPassed(saga, steps) {
+		return nil
+	}
+	o.beginCompensation(saga, cause)
+	if err := o.repo.UpdateSaga(ctx, saga); err != nil {
+		return err
+	}
+	return o.advance(ctx, saga)
+}
+
+// ResumeDue melanjutkan saga yang jatuh tempo: RUNNING/COMPENSATING yang dijadwalkan ulang atau terhenti karena restart,
+// dan WAITING yang perlu diperiksa ulang. Mengembalikan jumlah saga yang diproses.
+func (o *SagaOrchestrator) ResumeDue(ctx context.Context) int {
+	sagas, err := o.repo.ClaimDueSagas(ctx, o.BatchSize, sagaLease)
+	if err != nil {
+		logger.Error("SagaOrchestrator: failed to claim due sagas", err, nil)
+		return 0
+	}
+	for i := range sagas {
+		if sagas[i].Status == domain.SagaStatusWaiting {
+			sagas[i].Status = domain.SagaStatusRunning
+		}
+		logger.Info(fmt.Sprintf("SagaOrchestrator: resuming %s saga %s (order %s) at step %d", sagas[i].Type, sagas[i].ID, sagas[i].OrderID, sagas[i].CurrentStep))
+		if err := o.advance(ctx, &sagas[i]); err != nil {
+			logger.Error(fmt.Sprintf("SagaOrchestrator: failed to resume saga %s", sagas[i].ID), err, nil)
+		}
+	}
+	return len(sagas)
+}
+
+// List mengembalikan saga dengan status tertentu (default STUCK), yang paling lama tidak berubah lebih dulu.
+func (o *SagaOrchestrator) List(ctx context.Context, filter domain.ListSagasFilter) ([]domain.Saga, error) {
+	if filter.Status == "" {
+		filter.Status = domain.SagaStatusStuck
+	}
+	if !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidSagaQuery, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultSagaListLimit
+	}
+	if filter.Limit > domain.MaxSagaListLimit {
+		filter.Limit = domain.MaxSagaListLimit
+	}
+	return o.repo.ListSagas(ctx, filter)
+}
+
+// Retry menjalankan ulang saga STUCK dari langkah tempat ia berhenti, dengan jatah percobaan baru.
+// Saga yang berhenti saat kompensasi melanjutkan kompensasinya.
+func (o *SagaOrchestrator) Retry(ctx context.Context, sagaID string) (*domain.Saga, error) {
+	saga, err := o.repo.GetSagaByID(ctx, sagaID)
+	if err != nil {
+		return nil, err
+	}
+	if saga.Status != domain.SagaStatusStuck {
+		return nil, fmt.Errorf("%w: saga %s is %s", ErrSagaNotRetryable, saga.ID, saga.Status)
+	}
+
+	if _, compensating := saga.FailedStep(); compensating {
+		saga.Status = domain.SagaStatusCompensating
+		if saga.CurrentStep >= 0 {
+			saga.Steps[saga.CurrentStep].CompensationAttempts = 0
+		}
+	} else {
+		saga.Status = domain.SagaStatusRunning
+		if saga.CurrentStep < len(saga.Steps) {
+			saga.Steps[saga.CurrentStep].Attempts = 0
+		}
+	}
+	saga.LastError = nil
+	saga.NextAttemptAt = o.now().Add(sagaLease)
+	if err := o.repo.UpdateSaga(ctx, saga); err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("SagaOrchestrator: saga %s (order %s) retried manually", saga.ID, saga.OrderID))
+	return saga, o.advance(ctx, saga)
+}
+
+// advance menjalankan langkah saga satu per satu dan menyimpan status setelah setiap langkah,
+// sampai saga selesai, menunggu kejadian dari luar, atau dijadwalkan ulang.
+// Error hanya dikembalikan jika status saga gagal disimpan.
+func (o *SagaOrchestrator) advance(ctx context.Context, saga *domain.Saga) error {
+	steps, ok := o.definitions[saga.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownSagaType, saga.Type)
+	}
+	// Saga yang disimpan sebelum definisinya mendapat langkah baru menjalankan langkah tersebut di akhir
+	for i := len(saga.Steps); i < len(steps); i++ {
+		saga.Steps = append(saga.Steps, domain.SagaStepState{Name: steps[i].Name, Status: domain.SagaStepPending})
+	}
+
+	for {
+		var progressed bool
+		var err error
+		switch saga.Status {
+		case domain.SagaStatusRunning:
+			if saga.CurrentStep >= len(steps) {
+				saga.Status = domain.SagaStatusCompleted
+				saga.LastError = nil
+				logger.Info(fmt.Sprintf("SagaOrchestrator: %s saga %s (order %s) completed", saga.Type, saga.ID, saga.OrderID))
+				return o.repo.UpdateSaga(ctx, saga)
+			}
+			progressed, err = o.runStep(ctx, saga, steps)
+		case domain.SagaStatusCompensating:
+			if saga.CurrentStep < 0 {
+				saga.Status = domain.SagaStatusCompensated
+				logger.Info(fmt.Sprintf("SagaOrchestrator: %s saga %s (order %s) compensated", saga.Type, saga.ID, saga.OrderID))
+				return o.repo.UpdateSaga(ctx, saga)
+			}
+			progressed, err = o.compensateStep(ctx, saga, steps)
+		default:
+			return nil
+		}
+		if err != nil || !progressed {
+			return err // Menunggu, dijadwalkan ulang (ResumeDue yang melanjutkan), atau STUCK
+		}
+	}
+}
+
+// runStep menjalankan langkah saat ini. progressed bernilai true jika saga bisa langsung lanjut ke langkah berikutnya.
+func (o *SagaOrchestrator) runStep(ctx context.Context, saga *domain.Saga, steps []SagaStep) (progressed bool, err error) {
+	i := saga.CurrentStep
+	state := &saga.Steps[i]
+
+	// Percobaan dicatat sebelum langkah dijalankan, agar langkah tahu ia sedang diulang setelah crash
+	state.Attempts++
+	o.touch(state)
+	saga.NextAttemptAt = o.now().Add(sagaLease)
+	if err := o.repo.UpdateSaga(ctx, saga); err != nil {
+		return false, err
+	}
+
+	stepErr := steps[i].Execute(ctx, saga)
+	switch {
+	case stepErr == nil:
+		state.Status = domain.SagaStepSucceeded
+		state.LastError = ""
+		saga.LastError = nil
+		saga.CurrentStep++
+		progressed = true
+	case errors.Is(stepErr, errSagaWaiting):
+		saga.Status = domain.SagaStatusWaiting
+		saga.NextAttemptAt = o.now().Add(sagaWaitPollInterval)
+	case isPermanentFailure(stepErr) && !pivotPassed(saga, steps):
+		logger.Warn(fmt.Sprintf("SagaOrchestrator: step %s of saga %s (order %s) failed permanently, compensating: %v", state.Name, saga.ID, saga.OrderID, stepErr))
+		o.beginCompensation(saga, stepErr)
+		progressed = true
+	default:
+		o.recordFailure(saga, state, state.Attempts, isPermanentFailure(stepErr), stepErr)
+	}
+	o.touch(state)
+	return progressed, o.repo.UpdateSaga(ctx, saga)
+}
+
+// compensateStep membatalkan langkah saat ini jika langkah tersebut sudah berhasil dan punya kompensasi.
+func (o *SagaOrchestrator) compensateStep(ctx context.Context, saga *domain.Saga, steps []SagaStep) (progressed bool, err error) {
+	i := saga.CurrentStep
+	state := &saga.Steps[i]
+	if state.Status != domain.SagaStepSucceeded || steps[i].Compensate == nil {
+		saga.CurrentStep--
+		return true, nil
+	}
+
+	state.CompensationAttempts++
+	o.touch(state)
+	saga.NextAttemptAt = o.now().Add(sagaLease)
+	if err := o.repo.UpdateSaga(ctx, saga); err != nil {
+		return false, err
+	}
+
+	if compErr := steps[i].Compensate(ctx, saga); compErr != nil {
+		o.recordFailure(saga, state, state.CompensationAttempts, isPermanentFailure(compErr), compErr)
+	} else {
+		state.Status = domain.SagaStepCompensated
+		state.LastError = ""
+		saga.CurrentStep--
+		progressed = true
+	}
+	o.touch(state)
+	return progressed, o.repo.UpdateSaga(ctx, saga)
+}
+
+// beginCompensation menandai langkah saat ini gagal permanen dan mulai membatalkan langkah-langkah sebelumnya.
+func (o *SagaOrchestrator) beginCompensation(saga *domain.Saga, cause error) {
+	state := &saga.Steps[saga.CurrentStep]
+	state.Status = domain.SagaStepFailed
+	state.LastError = cause.Error()
+	o.touch(state)
+	saga.LastError = sagaErrorMessage(state.Name, cause)
+	saga.Status = domain.SagaStatusCompensating
+	saga.CurrentStep--
+	saga.NextAttemptAt = o.now().Add(sagaLease)
+}
+
+// recordFailure menjadwalkan ulang langkah dengan backoff, atau menandai saga STUCK jika kegagalannya permanen
+// (setelah pivot) atau percobaan sudah habis.
+func (o *SagaOrchestrator) recordFailure(saga *domain.Saga, state *domain.SagaStepState, attempts int, permanent bool, err error) {
+	state.LastError = err.Error()
+	saga.LastError = sagaErrorMessage(state.Name, err)
+	if permanent || attempts >= o.MaxAttempts {
+		saga.Status = domain.SagaStatusStuck
+		logger.Error(fmt.Sprintf("SagaOrchestrator: %s saga %s (order %s) is STUCK at step %s after %d attempts; manual retry required",
+			saga.Type, saga.ID, saga.OrderID, state.Name, attempts), err, nil)
+		return
+	}
+	saga.NextAttemptAt = o.now().Add(exponentialBackoff(attempts, sagaBaseBackoff, sagaMaxBackoff))
+	logger.Warn(fmt.Sprintf("SagaOrchestrator: step %s of saga %s (order %s) failed on attempt %d, retrying at %s: %v",
+		state.Name, saga.ID, saga.OrderID, attempts, saga.NextAttemptAt.Format(time.RFC3339), err))
+}
+
+func (o *SagaOrchestrator) touch(state *domain.SagaStepState) {
+	now := o.now()
+	state.UpdatedAt = &now
+}
+
+// pivotPassed bernilai true jika salah satu langkah pivot sudah berhasil; saga tidak bisa dikompensasi lagi.
+func pivotPassed(saga *domain.Saga, steps []SagaStep) bool {
+	for i, step := range steps {
+		if step.Pivot && i < len(saga.Steps) && saga.Steps[i].Status == domain.SagaStepSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+func sagaErrorMessage(stepName string, err error) *string {
+	msg := fmt.Sprintf("%s: %v", stepName, err)
+	return &msg
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSagaOrchestrator(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const sagaType = "test"
+
+	// sagaSteps membuat langkah-langkah uji: results menentukan error setiap langkah secara berurutan,
+	// dan urutan eksekusi/kompensasi dicatat di calls.
+	type recorder struct{ calls []string }
+	sagaSteps := func(rec *recorder, results map[string][]error, pivot string) []SagaStep {
+		step := func(name string) SagaStep {
+			return SagaStep{
+				Name: name,
+				Execute: func(ctx context.Context, saga *domain.Saga) error {
+					rec.calls = append(rec.calls, "exec:"+name)
+					if errs := results[name]; len(errs) > 0 {
+						err := errs[0]
+						results[name] = errs[1:]
+						return err
+					}
+					return nil
+				},
+				Compensate: func(ctx context.Context, saga *domain.Saga) error {
+					rec.calls = append(rec.calls, "undo:"+name)
+					return nil
+				},
+				Pivot: name == pivot,
+			}
+		}
+		return []SagaStep{step("a"), step("b"), step("c")}
+	}
+	newOrchestrator := func(steps []SagaStep) (*SagaOrchestrator, *mocks.MockOrderRepository) {
+		repo := new(mocks.MockOrderRepository)
+		repo.On("CreateSaga", ctx, mock.AnythingOfType("*domain.Saga")).Return(nil).Maybe()
+		repo.On("UpdateSaga", ctx, mock.AnythingOfType("*domain.Saga")).Return(nil).Maybe()
+		o := NewSagaOrchestrator(repo)
+		o.Register(sagaType, steps)
+		o.now = func() time.Time { return now }
+		return o, repo
+	}
+
+	t.Run("All steps succeed", func(t *testing.T) {
+		rec := &recorder{}
+		o, _ := newOrchestrator(sagaSteps(rec, map[string][]error{}, ""))
+
+		saga, err := o.Start(ctx, sagaType, "order-1", map[string]string{"k": "v"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		assert.Equal(t, []string{"exec:a", "exec:b", "exec:c"}, rec.calls)
+		for _, step := range saga.Steps {
+			assert.Equal(t, domain.SagaStepSucceeded, step.Status)
+			assert.Equal(t, 1, step.Attempts)
+		}
+		assert.JSONEq(t, `{"k":"v"}`, string(saga.Data))
+	})
+
+	t.Run("Permanent failure compensates completed steps in reverse order", func(t *testing.T) {
+		rec := &recorder{}
+		o, _ := newOrchestrator(sagaSteps(rec, map[string][]error{"c": {permanentFailure(errors.New("out of stock"))}}, ""))
+
+		saga, err := o.Start(ctx, sagaType, "order-2", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompensated, saga.Status)
+		assert.Equal(t, []string{"exec:a", "exec:b", "exec:c", "undo:b", "undo:a"}, rec.calls)
+		assert.Equal(t, domain.SagaStepFailed, saga.Steps[2].Status)
+		assert.Equal(t, domain.SagaStepCompensated, saga.Steps[0].Status)
+		step, ok := saga.FailedStep()
+		assert.True(t, ok)
+		assert.Equal(t, "c", step)
+		assert.Equal(t, "c: out of stock", *saga.LastError)
+	})
+
+	t.Run("Transient failure is rescheduled, then STUCK after max attempts", func(t *testing.T) {
+		rec := &recorder{}
+		unavailable := errors.New("warehouse unavailable")
+		o, repo := newOrchestrator(sagaSteps(rec, map[string][]error{"b": {unavailable, unavailable}}, ""))
+		o.MaxAttempts = 2
+
+		saga, err := o.Start(ctx, sagaType, "order-3", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusRunning, saga.Status)
+		assert.Equal(t, 1, saga.CurrentStep)
+		assert.Equal(t, now.Add(sagaBaseBackoff), saga.NextAttemptAt)
+
+		// Dilanjutkan oleh scheduler (misal setelah restart)
+		repo.On("ClaimDueSagas", ctx, o.BatchSize, sagaLease).Return([]domain.Saga{*saga}, nil).Once()
+		assert.Equal(t, 1, o.ResumeDue(ctx))
+		claimed := repo.Calls[len(repo.Calls)-1].Arguments.Get(1).(*domain.Saga)
+		assert.Equal(t, domain.SagaStatusStuck, claimed.Status)
+		assert.Equal(t, 2, claimed.Steps[1].Attempts)
+		assert.Equal(t, []string{"exec:a", "exec:b", "exec:b"}, rec.calls) // Tidak ada kompensasi otomatis
+	})
+
+	t.Run("Failure after the pivot is not compensated", func(t *testing.T) {
+		rec := &recorder{}
+		o, _ := newOrchestrator(sagaSteps(rec, map[string][]error{"c": {permanentFailure(errors.New("reservation expired"))}}, "b"))
+
+		saga, err := o.Start(ctx, sagaType, "order-4", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusStuck, saga.Status)
+		assert.Equal(t, []string{"exec:a", "exec:b", "exec:c"}, rec.calls)
+		_, compensating := saga.FailedStep()
+		assert.False(t, compensating)
+	})
+
+	t.Run("Waiting saga continues on Signal", func(t *testing.T) {
+		rec := &recorder{}
+		o, repo := newOrchestrator(sagaSteps(rec, map[string][]error{"b": {errSagaWaiting}}, ""))
+
+		saga, err := o.Start(ctx, sagaType, "order-5", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusWaiting, saga.Status)
+		assert.Equal(t, now.Add(sagaWaitPollInterval), saga.NextAttemptAt)
+
+		repo.On("GetSagaByOrderID", ctx, sagaType, "order-5").Return(saga, nil).Once()
+		saga, err = o.Signal(ctx, sagaType, "order-5")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		assert.Equal(t, []string{"exec:a", "exec:b", "exec:b", "exec:c"}, rec.calls)
+	})
+
+	t.Run("Abort compensates a running saga", func(t *testing.T) {
+		rec := &recorder{}
+		o, _ := newOrchestrator(sagaSteps(rec, map[string][]error{"b": {errors.New("db timeout")}}, ""))
+
+		saga, err := o.Start(ctx, sagaType, "order-6", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusRunning, saga.Status)
+
+		assert.NoError(t, o.Abort(ctx, saga, errors.New("db timeout")))
+		assert.Equal(t, domain.SagaStatusCompensated, saga.Status)
+		assert.Equal(t, domain.SagaStepFailed, saga.Steps[1].Status)
+		assert.Equal(t, []string{"exec:a", "exec:b", "undo:a"}, rec.calls)
+	})
+
+	t.Run("Retry resumes a STUCK saga with a fresh attempt budget", func(t *testing.T) {
+		rec := &recorder{}
+		o, repo := newOrchestrator(sagaSteps(rec, map[string][]error{}, ""))
+		lastErr := "b: warehouse unavailable"
+		stuck := &domain.Saga{
+			ID: "saga-7", Type: sagaType, OrderID: "order-7", Status: domain.SagaStatusStuck, CurrentStep: 1, LastError: &lastErr,
+			Steps: []domain.SagaStepState{
+				{Name: "a", Status: domain.SagaStepSucceeded, Attempts: 1},
+				{Name: "b", Status: domain.SagaStepPending, Attempts: 5},
+				{Name: "c", Status: domain.SagaStepPending},
+			},
+		}
+		repo.On("GetSagaByID", ctx, "saga-7").Return(stuck, nil).Once()
+
+		saga, err := o.Retry(ctx, "saga-7")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		assert.Equal(t, 1, saga.Steps[1].Attempts)
+		assert.Nil(t, saga.LastError)
+		assert.Equal(t, []string{"exec:b", "exec:c"}, rec.calls)
+	})
+
+	t.Run("Saga stored before a step was added runs the new step", func(t *testing.T) {
+		rec := &recorder{}
+		o, repo := newOrchestrator(sagaSteps(rec, map[string][]error{}, ""))
+		waiting := &domain.Saga{
+			ID: "saga-10", Type: sagaType, OrderID: "order-10", Status: domain.SagaStatusWaiting, CurrentStep: 1,
+			Steps: []domain.SagaStepState{
+				{Name: "a", Status: domain.SagaStepSucceeded, Attempts: 1},
+				{Name: "b", Status: domain.SagaStepPending, Attempts: 1},
+			},
+		}
+		repo.On("GetSagaByOrderID", ctx, sagaType, "order-10").Return(waiting, nil).Once()
+
+		saga, err := o.Signal(ctx, sagaType, "order-10")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SagaStatusCompleted, saga.Status)
+		assert.Len(t, saga.Steps, 3)
+		assert.Equal(t, domain.SagaStepSucceeded, saga.Steps[2].Status)
+		assert.Equal(t, []string{"exec:b", "exec:c"}, rec.calls)
+	})
+
+	t.Run("Only STUCK sagas can be retried", func(t *testing.T) {
+		o, repo := newOrchestrator(sagaSteps(&recorder{}, map[string][]error{}, ""))
+		repo.On("GetSagaByID", ctx, "saga-8").Return(&domain.Saga{ID: "saga-8", Type: sagaType, Status: domain.SagaStatusWaiting}, nil).Once()
+
+		saga, err := o.Retry(ctx, "saga-8")
+		assert.ErrorIs(t, err, ErrSagaNotRetryable)
+		assert.Nil(t, saga)
+	})
+
+	t.Run("List defaults to STUCK sagas", func(t *testing.T) {
+		o, repo := newOrchestrator(sagaSteps(&recorder{}, map[string][]error{}, ""))
+		filter := domain.ListSagasFilter{Status: domain.SagaStatusStuck, Limit: domain.DefaultSagaListLimit}
+		repo.On("ListSagas", ctx, filter).Return([]domain.Saga{{ID: "saga-9"}}, nil).Once()
+
+		sagas, err := o.List(ctx, domain.ListSagasFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, sagas, 1)
+
+		_, err = o.List(ctx, domain.ListSagasFilter{Status: "BOGUS"})
+		assert.ErrorIs(t, err, ErrInvalidSagaQuery)
+	})
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+var (
+	ErrInvalidShipmentQuery   = errors.New("invalid shipment list query")
+	ErrInvalidShipmentRequest = errors.New("carrier and tracking number are required")
+	ErrShipmentInvalidState   = errors.New("shipment is not in the required status")
+	ErrOrderNotShippable      = errors.New("order can no longer be shipped in its current status")
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// GetOrderShipments mengembalikan shipment order beserta isi paketnya. Shipment dibuat oleh saga checkout
+// setelah stok di-commit, sehingga order yang belum dibayar belum punya shipment.
+func (s *orderServiceImpl) GetOrderShipments(ctx context.Context, orderID string) ([]domain.Shipment, error) {
+	if _, err := s.orderRepo.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetShipmentsByOrderID(ctx, orderID)
+}
+
+func (s *orderServiceImpl) GetShipment(ctx context.Context, shipmentID string) (*domain.Shipment, error) {
+	return s.orderRepo.GetShipmentByID(ctx, shipmentID)
+}
+
+func (s *orderServiceImpl) ListShipments(ctx context.Context, filter domain.ListShipmentsFilter) ([]domain.Shipment, error) {
+	if filter.WarehouseID != "" && !uuidPattern.MatchString(filter.WarehouseID) {
+		return nil, fmt.Errorf("%w: warehouse_id must be a UUID", ErrInvalidShipmentQuery)
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidShipmentQuery, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultShipmentListLimit
+	}
+	if filter.Limit > domain.MaxShipmentListLimit {
+		filter.Limit = domain.MaxShipmentListLimit
+	}
+	return s.orderRepo.ListShipments(ctx, filter)
+}
+
+// ShipShipment mencatat paket yang sudah diserahkan ke kurir. Status order diturunkan dari semua shipment-nya:
+// PARTIALLY_SHIPPED selama masih ada shipment lain yang belum dikirim, SHIPPED jika semuanya sudah dikirim.
+func (s *orderServiceImpl) ShipShipment(ctx context.Context, shipmentID string, req domain.ShipShipmentRequest) (*domain.Shipment, error) {
+	if req.Carrier == "" || req.TrackingNumber == "" {
+		return nil, ErrInvalidShipmentRequest
+	}
+	return s.transitionShipment(ctx, domain.ShipmentTransition{
+		ShipmentID:     shipmentID,
+		From:           domain.ShipmentStatusPending,
+		To:             domain.ShipmentStatusShipped,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	})
+}
+
+// DeliverShipment mencatat paket yang sudah diterima customer; order menjadi DELIVERED setelah semua shipment diterima.
+func (s *orderServiceImpl) DeliverShipment(ctx context.Context, shipmentID string) (*domain.Shipment, error) {
+	return s.transitionShipment(ctx, domain.ShipmentTransition{
+		ShipmentID: shipmentID,
+		From:       domain.ShipmentStatusShipped,
+		To:         domain.ShipmentStatusDelivered,
+	})
+}
+
+// transitionShipment memindahkan shipment dari t.From ke t.To lalu mengembalikan shipment terbaru.
+func (s *orderServiceImpl) transitionShipment(ctx context.Context, t domain.ShipmentTransition) (*domain.Shipment, error) {
+	shipment, err := s.orderRepo.GetShipmentByID(ctx, t.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+	if shipment.Status != t.From {
+		return nil, fmt.Errorf("%w: shipment %s is %s, expected %s", ErrShipmentInvalidState, shipment.ID, shipment.Status, t.From)
+	}
+
+	t.Actor = actorFromContext(ctx)
+	if err := s.orderRepo.TransitionShipment(ctx, t); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrShipmentStatusConflict):
+			return nil, fmt.Errorf("%w: %v", ErrShipmentInvalidState, err)
+		case errors.Is(err, repository.ErrOrderStatusConflict):
+			return nil, fmt.Errorf("%w: %v", ErrOrderNotShippable, err)
+		}
+		logger.Error(fmt.Sprintf("Failed to move shipment %s of order %s to %s", shipment.ID, shipment.OrderID, t.To), err, nil)
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Shipment %s of order %s: %s -> %s", shipment.ID, shipment.OrderID, t.From, t.To))
+	return s.orderRepo.GetShipmentByID(ctx, t.ShipmentID)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/order/domain"
+	oRepo "github.com/ridloal/e-commerce-go-microservices/internal/order/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFulfillmentStatus(t *testing.T) {
+	const (
+		pending   = domain.ShipmentStatusPending
+		shipped   = domain.ShipmentStatusShipped
+		delivered = domain.ShipmentStatusDelivered
+		cancelled = domain.ShipmentStatusCancelled
+	)
+	cases := []struct {
+		statuses []domain.ShipmentStatus
+		want     domain.OrderStatus
+	}{
+		{[]domain.ShipmentStatus{pending, pending}, domain.StatusAwaitingShipment},
+		{[]domain.ShipmentStatus{shipped, pending}, domain.StatusPartiallyShipped},
+		{[]domain.ShipmentStatus{delivered, pending}, domain.StatusPartiallyShipped},
+		{[]domain.ShipmentStatus{shipped, delivered}, domain.StatusShipped},
+		{[]domain.ShipmentStatus{delivered, delivered}, domain.StatusDelivered},
+		{[]domain.ShipmentStatus{delivered, cancelled}, domain.StatusDelivered},
+	}
+	for _, tc := range cases {
+		got, ok := domain.FulfillmentStatus(tc.statuses)
+		assert.True(t, ok, "%v", tc.statuses)
+		assert.Equal(t, tc.want, got, "%v", tc.statuses)
+	}
+
+	_, ok := domain.FulfillmentStatus([]domain.ShipmentStatus{cancelled})
+	assert.False(t, ok)
+
+	// Pengiriman sebagian berlanjut ke SHIPPED, dan pembatalan tidak lagi diizinkan
+	assert.True(t, domain.StatusAwaitingShipment.CanTransitionTo(domain.StatusPartiallyShipped))
+	assert.True(t, domain.StatusPartiallyShipped.CanTransitionTo(domain.StatusShipped))
+	assert.False(t, domain.StatusPartiallyShipped.CanTransitionTo(domain.StatusCancelled))
+}
+
+func TestPlanShipments(t *testing.T) {
+	items := []domain.OrderItem{
+		{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 3},
+		{ProductID: "prod-2", ProductName: "Mouse", SKU: "MS-1", Quantity: 1},
+	}
+	deductions := []domain.StockDeduction{
+		{ProductID: "prod-1", WarehouseID: "wh-1", Quantity: 2},
+		{ProductID: "prod-2", WarehouseID: "wh-2", Quantity: 1},
+		{ProductID: "prod-1", WarehouseID: "wh-2", Quantity: 1},
+		{ProductID: "prod-1", WarehouseID: "wh-1", Quantity: 1}, // Dicatat ulang setelah retry commit
+	}
+
+	shipments := domain.PlanShipments("order-1", deductions, items)
+
+	assert.Len(t, shipments, 2)
+	assert.Equal(t, "wh-1", shipments[0].WarehouseID)
+	assert.Equal(t, []domain.ShipmentItem{{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 3}}, shipments[0].Items)
+	assert.Equal(t, "wh-2", shipments[1].WarehouseID)
+	assert.Equal(t, []domain.ShipmentItem{
+		{ProductID: "prod-2", ProductName: "Mouse", SKU: "MS-1", Quantity: 1},
+		{ProductID: "prod-1", ProductName: "Laptop", SKU: "LP-1", Quantity: 1},
+	}, shipments[1].Items)
+	for _, sh := range shipments {
+		assert.Equal(t, "order-1", sh.OrderID)
+		assert.Equal(t, domain.ShipmentStatusPending, sh.Status)
+	}
+}
+
+func TestOrderService_CreateShipmentsStep(t *testing.T) {
+	ctx := context.Background()
+	saga := &domain.Saga{Type: domain.SagaTypeCheckout, OrderID: "order-1"}
+
+	t.Run("Cancelled order is skipped", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusCancelled}, nil).Once()
+
+		err := svc.(*orderServiceImpl).createShipmentsStep(ctx, saga)
+
+		assert.NoError(t, err)
+		repo.AssertNotCalled(t, "CreateShipments", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Order changed concurrently is not an error", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusPaymentConfirmed}, nil).Once()
+		repo.On("GetStockDeductionsByOrderID", ctx, "order-1").Return([]domain.StockDeduction{
+			{OrderID: "order-1", ProductID: "prod-1", WarehouseID: "wh-1", Quantity: 1},
+		}, nil).Once()
+		repo.On("GetOrderItemsByOrderID", ctx, "order-1").Return([]domain.OrderItem{{ProductID: "prod-1", Quantity: 1}}, nil).Once()
+		repo.On("CreateShipments", ctx, mock.AnythingOfType("domain.StatusTransition"), mock.Anything).
+			Return(fmt.Errorf("%w: expected PAYMENT_CONFIRMED", oRepo.ErrOrderStatusConflict)).Once()
+
+		err := svc.(*orderServiceImpl).createShipmentsStep(ctx, saga)
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Paid order without stock deductions needs manual handling", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusPaymentConfirmed}, nil).Once()
+		repo.On("GetStockDeductionsByOrderID", ctx, "order-1").Return([]domain.StockDeduction{}, nil).Once()
+
+		err := svc.(*orderServiceImpl).createShipmentsStep(ctx, saga)
+
+		assert.True(t, isPermanentFailure(err))
+	})
+}
+
+func TestOrderService_ShipShipment(t *testing.T) {
+	admin := auth.Identity{UserID: "staff-1", Role: auth.RoleAdmin}
+	ctx := auth.WithIdentity(context.Background(), admin)
+	pendingShipment := func() *domain.Shipment {
+		return &domain.Shipment{ID: "ship-1", OrderID: "order-1", WarehouseID: "wh-1", Status: domain.ShipmentStatusPending}
+	}
+	req := domain.ShipShipmentRequest{Carrier: "JNE", TrackingNumber: "JNE123"}
+
+	t.Run("Carrier and tracking number are recorded by the acting staff", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		carrier, tracking := "JNE", "JNE123"
+		shipped := pendingShipment()
+		shipped.Status = domain.ShipmentStatusShipped
+		shipped.Carrier, shipped.TrackingNumber = &carrier, &tracking
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(pendingShipment(), nil).Once()
+		repo.On("TransitionShipment", ctx, domain.ShipmentTransition{
+			ShipmentID: "ship-1", From: domain.ShipmentStatusPending, To: domain.ShipmentStatusShipped,
+			Carrier: "JNE", TrackingNumber: "JNE123", Actor: "admin:staff-1",
+		}).Return(nil).Once()
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(shipped, nil).Once()
+
+		shipment, err := svc.ShipShipment(ctx, "ship-1", req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ShipmentStatusShipped, shipment.Status)
+		assert.Equal(t, "JNE123", *shipment.TrackingNumber)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Shipment that was already shipped is rejected", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		shipped := pendingShipment()
+		shipped.Status = domain.ShipmentStatusShipped
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(shipped, nil).Once()
+
+		_, err := svc.ShipShipment(ctx, "ship-1", req)
+
+		assert.ErrorIs(t, err, ErrShipmentInvalidState)
+		repo.AssertNotCalled(t, "TransitionShipment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Order cancelled in the meantime cannot be shipped", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(pendingShipment(), nil).Once()
+		repo.On("TransitionShipment", ctx, mock.AnythingOfType("domain.ShipmentTransition")).
+			Return(fmt.Errorf("%w: order order-1 is CANCELLED", oRepo.ErrOrderStatusConflict)).Once()
+
+		_, err := svc.ShipShipment(ctx, "ship-1", req)
+
+		assert.ErrorIs(t, err, ErrOrderNotShippable)
+	})
+
+	t.Run("Blank carrier is rejected", func(t *testing.T) {
+		_, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+
+		_, err := svc.ShipShipment(ctx, "ship-1", domain.ShipShipmentRequest{TrackingNumber: "JNE123"})
+
+		assert.ErrorIs(t, err, ErrInvalidShipmentRequest)
+	})
+}
+
+func TestOrderService_DeliverShipment(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Shipped shipment is delivered", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(&domain.Shipment{ID: "ship-1", OrderID: "order-1", Status: domain.ShipmentStatusShipped}, nil).Once()
+		repo.On("TransitionShipment", ctx, mock.MatchedBy(func(tr domain.ShipmentTransition) bool {
+			return tr.From == domain.ShipmentStatusShipped && tr.To == domain.ShipmentStatusDelivered && tr.Actor == domain.ActorSystem
+		})).Return(nil).Once()
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(&domain.Shipment{ID: "ship-1", OrderID: "order-1", Status: domain.ShipmentStatusDelivered}, nil).Once()
+
+		shipment, err := svc.DeliverShipment(ctx, "ship-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ShipmentStatusDelivered, shipment.Status)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Pending shipment must be shipped first", func(t *testing.T) {
+		repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+		repo.On("GetShipmentByID", ctx, "ship-1").Return(&domain.Shipment{ID: "ship-1", Status: domain.ShipmentStatusPending}, nil).Once()
+
+		_, err := svc.DeliverShipment(ctx, "ship-1")
+
+		assert.ErrorIs(t, err, ErrShipmentInvalidState)
+	})
+}
+
+func TestOrderService_ListShipments(t *testing.T) {
+	ctx := context.Background()
+	repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+	warehouseID := "0b9f5a3e-6c1d-4f7a-9e2b-1a2b3c4d5e6f"
+	repo.On("ListShipments", ctx, domain.ListShipmentsFilter{
+		WarehouseID: warehouseID, Status: domain.ShipmentStatusPending, Limit: domain.DefaultShipmentListLimit,
+	}).Return([]domain.Shipment{{ID: "ship-1"}}, nil).Once()
+
+	shipments, err := svc.ListShipments(ctx, domain.ListShipmentsFilter{WarehouseID: warehouseID, Status: domain.ShipmentStatusPending})
+	assert.NoError(t, err)
+	assert.Len(t, shipments, 1)
+
+	_, err = svc.ListShipments(ctx, domain.ListShipmentsFilter{WarehouseID: "wh-1"})
+	assert.ErrorIs(t, err, ErrInvalidShipmentQuery)
+	_, err = svc.ListShipments(ctx, domain.ListShipmentsFilter{Status: "LOST"})
+	assert.ErrorIs(t, err, ErrInvalidShipmentQuery)
+}
+
+func TestOrderService_CancelPartiallyShippedOrder(t *testing.T) {
+	ctx := context.Background()
+	repo, _, svc := newReturnTestService(NewFakePaymentProvider("test-secret", ""))
+	repo.On("GetOrderByID", ctx, "order-1").Return(&domain.Order{ID: "order-1", Status: domain.StatusPartiallyShipped}, nil).Once()
+
+	_, err := svc.CancelOrder(ctx, "order-1", "changed my mind")
+
+	assert.ErrorIs(t, err, ErrOrderAlreadyShipped)
+	repo.AssertNotCalled(t, "CancelOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
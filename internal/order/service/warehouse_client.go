@@ -4,158 +4,220 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	// Ganti dengan path yang benar
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/idempotency"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 	warehouseDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 )
 
 type WarehouseClient interface {
-	ReserveStock(ctx context.Context, productID string, quantity int) error
-	ReleaseStock(ctx context.Context, productID string, quantity int) error
-	DeductStock(ctx context.Context, req warehouseDomain.DeductStockRequest) error
-	FindWarehousesWithReservations(ctx context.Context, productIDs []string) ([]warehouseDomain.ProductWarehouseReservationInfo, error)
+	// ReserveStockBatch mereservasi semua item order dalam satu transaksi di Warehouse Service (all-or-nothing)
+	// dan mengembalikan alokasi per gudang untuk setiap baris.
+	ReserveStockBatch(ctx context.Context, orderID string, items []warehouseDomain.ReserveStockBatchItem, ttl time.Duration) ([]warehouseDomain.ReservationLine, error)
+	ListReservations(ctx context.Context, orderID string) ([]warehouseDomain.StockReservation, error)
+	// CommitReservation mengurangi stok tepat di gudang yang direservasi untuk order tersebut
+	CommitReservation(ctx context.Context, reservationID string) (*warehouseDomain.StockReservation, error)
+	ReleaseReservation(ctx context.Context, reservationID string) error
+	// RestockStock mengembalikan stok yang sudah dikurangi ke gudang tertentu (misal saat order dibatalkan setelah bayar).
+	// reference dikirim sebagai Idempotency-Key, sehingga restock yang diulang dengan reference sama tidak menambah stok dua kali.
+	RestockStock(ctx context.Context, reference, warehouseID, productID string, quantity int) error
+	// ReceiveReturn memasukkan barang retur ke stok gudang. Reference yang sama tidak menambah stok dua kali.
+	ReceiveReturn(ctx context.Context, reference, warehouseID, productID string, quantity int) (*warehouseDomain.ReceiveReturnResponse, error)
+}
+
+// WarehouseStatusError dikembalikan jika Warehouse Service merespons dengan status yang tidak diharapkan.
+type WarehouseStatusError struct {
+	Op         string
+	StatusCode int
+	Message    string // Pesan dari field "error" pada response, jika ada
+}
+
+func (e *WarehouseStatusError) Error() string {
+	msg := fmt.Sprintf("warehouse service %s returned status %d", e.Op, e.StatusCode)
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s - %s", msg, e.Message)
+	}
+	return msg
+}
+
+// isWarehouseRejection bernilai true jika Warehouse Service menolak request (4xx selain 408/429),
+// sehingga mengulang request yang sama tidak akan berhasil.
+func isWarehouseRejection(err error) bool {
+	var statusErr *WarehouseStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests
 }
 
 type httpWarehouseClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// serviceSecret menandatangani token service untuk rute Warehouse Service yang tertutup bagi customer (misal reservasi)
+	serviceSecret []byte
 }
 
-func NewHTTPWarehouseClient(baseURL string) WarehouseClient {
+// warehouseClientServiceName tercatat sebagai actor di buku besar stok Warehouse Service ("service:order-service").
+const warehouseClientServiceName = "order-service"
+
+func NewHTTPWarehouseClient(baseURL string, serviceSecret []byte) WarehouseClient {
 	return &httpWarehouseClient{
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
 			Timeout: 10 * time.Second, // Timeout mungkin perlu lebih lama untuk operasi stok
 		},
+		serviceSecret: serviceSecret,
 	}
 }
 
-func (c *httpWarehouseClient) doStockOperation(ctx context.Context, operation string, productID string, quantity int) error {
-	reqURL := fmt.Sprintf("%s/api/v1/stocks/%s", c.BaseURL, operation)
+// Percobaan ulang hanya untuk kegagalan jaringan; Idempotency-Key yang sama memastikan
+// Warehouse Service tidak menerapkan operasi dua kali jika request pertama sebenarnya sudah sampai.
+const (
+	maxWarehouseRequestAttempts = 3
+	warehouseRetryBackoff       = 200 * time.Millisecond
+)
 
-	payload := warehouseDomain.StockOperationRequest{
-		ProductID: productID,
-		Quantity:  quantity,
-	}
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		logger.Error(fmt.Sprintf("WarehouseClient.%sStock: Marshal failed", operation), err, nil)
-		return fmt.Errorf("failed to marshal %s stock request: %w", operation, err)
+// doRequest mengirim request JSON ke Warehouse Service dan men-decode response ke out (jika tidak nil).
+// Status selain expectedStatus dikembalikan sebagai error beserta pesan error dari response.
+// Setiap request POST otomatis membawa Idempotency-Key baru yang dipakai ulang pada percobaan ulang.
+func (c *httpWarehouseClient) doRequest(ctx context.Context, op, method, reqURL string, payload interface{}, expectedStatus int, out interface{}) error {
+	var idempotencyKey string
+	if method == http.MethodPost {
+		idempotencyKey = idempotency.NewKey()
 	}
+	return c.doRequestWithKey(ctx, op, method, reqURL, idempotencyKey, payload, expectedStatus, out)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		logger.Error(fmt.Sprintf("WarehouseClient.%sStock: NewRequest failed", operation), err, nil)
-		return fmt.Errorf("failed to create %s stock request: %w", operation, err)
+// doRequestWithKey sama dengan doRequest, dengan Idempotency-Key dari pemanggil (kosong berarti tanpa header),
+// untuk operasi yang harus tetap idempoten walaupun diulang setelah proses restart.
+func (c *httpWarehouseClient) doRequestWithKey(ctx context.Context, op, method, reqURL, idempotencyKey string, payload interface{}, expectedStatus int, out interface{}) error {
+	var jsonPayload []byte
+	if payload != nil {
+		var err error
+		jsonPayload, err = json.Marshal(payload)
+		if err != nil {
+			logger.Error(fmt.Sprintf("WarehouseClient.%s: Marshal failed", op), err, nil)
+			return fmt.Errorf("failed to marshal %s request: %w", op, err)
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(jsonPayload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+		if err != nil {
+			logger.Error(fmt.Sprintf("WarehouseClient.%s: NewRequest failed", op), err, nil)
+			return fmt.Errorf("failed to create %s request: %w", op, err)
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set(idempotency.HeaderKey, idempotencyKey)
+		}
+		token, err := auth.NewServiceToken(c.serviceSecret, warehouseClientServiceName, time.Minute)
+		if err != nil {
+			return fmt.Errorf("failed to sign service token for %s: %w", op, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		logger.Error(fmt.Sprintf("WarehouseClient.%sStock: HTTPClient.Do failed", operation), err, nil)
-		return fmt.Errorf("failed to call warehouse service for %s stock: %w", operation, err)
+		resp, err = c.HTTPClient.Do(req)
+		if err == nil {
+			break
+		}
+		if attempt >= maxWarehouseRequestAttempts || ctx.Err() != nil {
+			logger.Error(fmt.Sprintf("WarehouseClient.%s: HTTPClient.Do failed", op), err, nil)
+			return fmt.Errorf("failed to call warehouse service for %s: %w", op, err)
+		}
+		logger.Warn(fmt.Sprintf("WarehouseClient.%s: attempt %d failed, retrying: %v", op, attempt, err))
+		time.Sleep(warehouseRetryBackoff * time.Duration(attempt))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var errResp warehouseDomain.StockOperationResponse // Atau map[string]string generik
+	if resp.StatusCode != expectedStatus {
+		var errResp struct {
+			Error string `json:"error"`
+		}
 		// Mencoba decode error response, tapi jangan sampai error decode menghalangi error utama
 		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		errMsg := fmt.Sprintf("warehouse service %s stock returned status %d", operation, resp.StatusCode)
-		if errResp.Message != "" {
-			errMsg = fmt.Sprintf("%s - %s", errMsg, errResp.Message)
-		}
-		logger.Error(errMsg, nil, fmt.Sprintf("ProductID: %s, Qty: %d", productID, quantity))
-		return fmt.Errorf(errMsg) // Mengembalikan error yang lebih deskriptif
+		statusErr := &WarehouseStatusError{Op: op, StatusCode: resp.StatusCode, Message: errResp.Error}
+		logger.Error(statusErr.Error(), nil, reqURL)
+		return statusErr
 	}
 
-	// Sukses jika status OK
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			logger.Error(fmt.Sprintf("WarehouseClient.%s: JSON decode failed", op), err, nil)
+			return fmt.Errorf("failed to decode %s response: %w", op, err)
+		}
+	}
 	return nil
 }
 
-func (c *httpWarehouseClient) ReserveStock(ctx context.Context, productID string, quantity int) error {
-	return c.doStockOperation(ctx, "reserve", productID, quantity)
-}
-
-func (c *httpWarehouseClient) ReleaseStock(ctx context.Context, productID string, quantity int) error {
-	return c.doStockOperation(ctx, "release", productID, quantity)
-}
-
-func (c *httpWarehouseClient) DeductStock(ctx context.Context, reqBody warehouseDomain.DeductStockRequest) error {
-	reqURL := fmt.Sprintf("%s/api/v1/stocks/deduct", c.BaseURL)
-
-	jsonPayload, err := json.Marshal(reqBody)
-	if err != nil {
-		logger.Error("WarehouseClient.DeductStock: Marshal failed", err, nil)
-		return fmt.Errorf("failed to marshal deduct stock request: %w", err)
+func (c *httpWarehouseClient) ReserveStockBatch(ctx context.Context, orderID string, items []warehouseDomain.ReserveStockBatchItem, ttl time.Duration) ([]warehouseDomain.ReservationLine, error) {
+	payload := warehouseDomain.ReserveStockBatchRequest{
+		OrderID:    orderID,
+		TTLSeconds: int(ttl.Seconds()),
+		Items:      items,
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewBuffer(jsonPayload))
+	var resp warehouseDomain.ReserveStockBatchResponse
+	err := c.doRequest(ctx, "ReserveStockBatch", http.MethodPost, c.BaseURL+"/api/v1/stocks/reserve-batch", payload, http.StatusOK, &resp)
 	if err != nil {
-		logger.Error("WarehouseClient.DeductStock: NewRequest failed", err, nil)
-		return fmt.Errorf("failed to create deduct stock request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		logger.Error("WarehouseClient.DeductStock: HTTPClient.Do failed", err, nil)
-		return fmt.Errorf("failed to call warehouse service for deduct stock: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// ... (Error handling mirip dengan doStockOperation)
-		errMsg := fmt.Sprintf("warehouse service deduct stock returned status %d", resp.StatusCode)
-		logger.Error(errMsg, nil, fmt.Sprintf("Req: %+v", reqBody))
-		return fmt.Errorf(errMsg)
+		return nil, err
 	}
-	return nil
+	return resp.Lines, nil
 }
 
-func (c *httpWarehouseClient) FindWarehousesWithReservations(ctx context.Context, productIDs []string) ([]warehouseDomain.ProductWarehouseReservationInfo, error) {
-	if len(productIDs) == 0 {
-		return []warehouseDomain.ProductWarehouseReservationInfo{}, nil
+func (c *httpWarehouseClient) ListReservations(ctx context.Context, orderID string) ([]warehouseDomain.StockReservation, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/stocks/reservations?order_id=%s", c.BaseURL, url.QueryEscape(orderID))
+	var reservations []warehouseDomain.StockReservation
+	if err := c.doRequest(ctx, "ListReservations", http.MethodGet, reqURL, nil, http.StatusOK, &reservations); err != nil {
+		return nil, err
 	}
-	reqURL := fmt.Sprintf("%s/api/v1/stock-info/reserved-locations", c.BaseURL)
+	return reservations, nil
+}
 
-	payload := warehouseDomain.FindWarehousesWithReservationsRequest{
-		ProductIDs: productIDs,
-	}
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		logger.Error("WarehouseClient.FindWarehousesWithReservations: Marshal failed", err, nil)
-		return nil, fmt.Errorf("failed to marshal find reservations request: %w", err)
+func (c *httpWarehouseClient) CommitReservation(ctx context.Context, reservationID string) (*warehouseDomain.StockReservation, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/stocks/reservations/%s/commit", c.BaseURL, url.PathEscape(reservationID))
+	var reservation warehouseDomain.StockReservation
+	if err := c.doRequest(ctx, "CommitReservation", http.MethodPost, reqURL, nil, http.StatusOK, &reservation); err != nil {
+		return nil, err
 	}
+	return &reservation, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		logger.Error("WarehouseClient.FindWarehousesWithReservations: NewRequest failed", err, nil)
-		return nil, fmt.Errorf("failed to create find reservations request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+func (c *httpWarehouseClient) ReleaseReservation(ctx context.Context, reservationID string) error {
+	reqURL := fmt.Sprintf("%s/api/v1/stocks/reservations/%s/release", c.BaseURL, url.PathEscape(reservationID))
+	return c.doRequest(ctx, "ReleaseReservation", http.MethodPost, reqURL, nil, http.StatusOK, nil)
+}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		logger.Error("WarehouseClient.FindWarehousesWithReservations: HTTPClient.Do failed", err, nil)
-		return nil, fmt.Errorf("failed to call warehouse service for find reservations: %w", err)
-	}
-	defer resp.Body.Close()
+func (c *httpWarehouseClient) RestockStock(ctx context.Context, reference, warehouseID, productID string, quantity int) error {
+	reqURL := fmt.Sprintf("%s/api/v1/warehouses/%s/stocks", c.BaseURL, url.PathEscape(warehouseID))
+	payload := warehouseDomain.AddStockRequest{ProductID: productID, Quantity: quantity}
+	return c.doRequestWithKey(ctx, "RestockStock", http.MethodPost, reqURL, "restock-"+reference, payload, http.StatusCreated, nil)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("warehouse service find reservations returned status %d", resp.StatusCode)
-		logger.Error(errMsg, nil, fmt.Sprintf("ProductIDs count: %d", len(productIDs)))
-		return nil, fmt.Errorf(errMsg)
+func (c *httpWarehouseClient) ReceiveReturn(ctx context.Context, reference, warehouseID, productID string, quantity int) (*warehouseDomain.ReceiveReturnResponse, error) {
+	payload := warehouseDomain.ReceiveReturnRequest{
+		ReturnReference: reference,
+		WarehouseID:     warehouseID,
+		ProductID:       productID,
+		Quantity:        quantity,
 	}
-
-	var results []warehouseDomain.ProductWarehouseReservationInfo
-	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
-		logger.Error("WarehouseClient.FindWarehousesWithReservations: JSON decode failed", err, nil)
-		return nil, fmt.Errorf("failed to decode find reservations response: %w", err)
+	var resp warehouseDomain.ReceiveReturnResponse
+	if err := c.doRequest(ctx, "ReceiveReturn", http.MethodPost, c.BaseURL+"/api/v1/stocks/receive-return", payload, http.StatusOK, &resp); err != nil {
+		return nil, err
 	}
-	return results, nil
+	return &resp, nil
 }
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const identityContextKey = "auth.identity"
+
+type ctxKey struct{}
+
+// Identity adalah identitas pemanggil yang sudah diverifikasi (dari JWT atau header gateway).
+type Identity struct {
+	UserID string
+	Email  string
+	Role   string
+}
+
+func (i Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+func (i Identity) IsService() bool {
+	return i.Role == RoleService
+}
+
+// CanActFor bernilai true jika pemanggil adalah pemilik resource atau admin.
+func (i Identity) CanActFor(userID string) bool {
+	return i.IsAdmin() || (i.UserID != "" && i.UserID == userID)
+}
+
+// GinMiddleware mewajibkan identitas terverifikasi untuk rute Gin.
+// Bearer token selalu diutamakan; header X-User-* dari gateway hanya dipakai jika trustIdentityHeaders aktif,
+// yaitu ketika service hanya bisa diakses lewat API Gateway (gateway selalu membuang header tersebut dari client).
+func GinMiddleware(secret []byte, trustIdentityHeaders bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := resolveIdentity(c.Request, secret, trustIdentityHeaders)
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Set(identityContextKey, identity)
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// OptionalGinMiddleware seperti GinMiddleware, tetapi request tanpa kredensial tetap dilayani sebagai anonim
+// (misal keranjang guest). Bearer token yang tidak valid tetap ditolak agar client tahu harus login ulang.
+func OptionalGinMiddleware(secret []byte, trustIdentityHeaders bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := resolveIdentity(c.Request, secret, trustIdentityHeaders)
+		if !ok {
+			if c.GetHeader("Authorization") != "" {
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidToken.Error()})
+				return
+			}
+			c.Next()
+			return
+		}
+		c.Set(identityContextKey, identity)
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func resolveIdentity(r *http.Request, secret []byte, trustIdentityHeaders bool) (Identity, bool) {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		tokenString, err := BearerToken(authHeader)
+		if err != nil {
+			return Identity{}, false
+		}
+		claims, err := ParseToken(tokenString, secret)
+		if err != nil {
+			return Identity{}, false
+		}
+		role := claims.Role
+		if role == "" {
+			role = RoleCustomer
+		}
+		return Identity{UserID: claims.UserID, Email: claims.Email, Role: role}, true
+	}
+
+	if trustIdentityHeaders {
+		if userID := r.Header.Get(HeaderUserID); userID != "" {
+			role := r.Header.Get(HeaderUserRole)
+			if role == "" {
+				role = RoleCustomer
+			}
+			return Identity{UserID: userID, Email: r.Header.Get(HeaderUserEmail), Role: role}, true
+		}
+	}
+	return Identity{}, false
+}
+
+// RequireAdmin dipasang setelah GinMiddleware untuk rute khusus admin.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := IdentityFromGin(c)
+		if !ok || !identity.IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdminOrService dipasang setelah GinMiddleware untuk rute internal yang dipanggil service lain
+// (lihat NewServiceToken) dan boleh dipakai admin, tetapi tidak oleh customer.
+func RequireAdminOrService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := IdentityFromGin(c)
+		if !ok || !(identity.IsAdmin() || identity.IsService()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin or service privileges required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IdentityFromGin mengambil identitas yang disimpan oleh GinMiddleware.
+func IdentityFromGin(c *gin.Context) (Identity, bool) {
+	v, exists := c.Get(identityContextKey)
+	if !exists {
+		return Identity{}, false
+	}
+	identity, ok := v.(Identity)
+	return identity, ok
+}
+
+// WithIdentity dan IdentityFromContext memungkinkan identitas dibawa sampai ke service layer lewat context.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(ctxKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(trustIdentityHeaders bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/me", GinMiddleware(testSecret, trustIdentityHeaders), func(c *gin.Context) {
+		identity, _ := IdentityFromGin(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": identity.UserID, "role": identity.Role})
+	})
+	router.GET("/admin", GinMiddleware(testSecret, trustIdentityHeaders), RequireAdmin(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/internal", GinMiddleware(testSecret, trustIdentityHeaders), RequireAdminOrService(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func TestGinMiddleware(t *testing.T) {
+	validToken := signTestToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+		"user_id": "user-1",
+		"email":   "user1@example.com",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+
+	t.Run("Valid bearer token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+validToken)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(false).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"user_id":"user-1","role":"customer"}`, rec.Body.String())
+	})
+
+	t.Run("Missing credentials", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(true).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Invalid token is not rescued by identity headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer not-a-jwt")
+		req.Header.Set(HeaderUserID, "user-1")
+		rec := httptest.NewRecorder()
+
+		newTestRouter(true).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Identity headers ignored when not trusted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set(HeaderUserID, "user-1")
+		rec := httptest.NewRecorder()
+
+		newTestRouter(false).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Trusted gateway identity headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set(HeaderUserID, "admin-1")
+		req.Header.Set(HeaderUserRole, RoleAdmin)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(true).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("Non-admin rejected from admin route", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+validToken)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(false).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Service token allowed on internal route", func(t *testing.T) {
+		serviceToken, err := NewServiceToken(testSecret, "order-service", time.Minute)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+		req.Header.Set("Authorization", "Bearer "+serviceToken)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(false).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("Customer rejected from internal route", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+		req.Header.Set("Authorization", "Bearer "+validToken)
+		rec := httptest.NewRecorder()
+
+		newTestRouter(false).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestOptionalGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cart", OptionalGinMiddleware(testSecret, false), func(c *gin.Context) {
+		identity, ok := IdentityFromGin(c)
+		c.JSON(http.StatusOK, gin.H{"authenticated": ok, "user_id": identity.UserID})
+	})
+	validToken := signTestToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+		"user_id": "user-1",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+
+	t.Run("Anonymous request is served", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"authenticated":false,"user_id":""}`, rec.Body.String())
+	})
+
+	t.Run("Valid bearer token sets identity", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("Authorization", "Bearer "+validToken)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"authenticated":true,"user_id":"user-1"}`, rec.Body.String())
+	})
+
+	t.Run("Invalid bearer token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("Authorization", "Bearer not-a-jwt")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestIdentity_CanActFor(t *testing.T) {
+	customer := Identity{UserID: "user-1", Role: RoleCustomer}
+	admin := Identity{UserID: "admin-1", Role: RoleAdmin}
+
+	assert.True(t, customer.CanActFor("user-1"))
+	assert.False(t, customer.CanActFor("user-2"))
+	assert.False(t, Identity{}.CanActFor(""))
+	assert.True(t, admin.CanActFor("user-2"))
+}
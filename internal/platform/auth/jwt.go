@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Header identitas yang disisipkan oleh API Gateway setelah token diverifikasi.
+// Service di belakang gateway hanya boleh mempercayai header ini jika request datang dari gateway.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserEmail = "X-User-Email"
+	HeaderUserRole  = "X-User-Role"
+)
+
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+	// RoleService untuk panggilan antar service (misal Order Service ke Warehouse Service), lihat NewServiceToken
+	RoleService = "service"
+)
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Claims adalah payload JWT yang diterbitkan oleh User Service saat login.
+type Claims struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// ParseToken memverifikasi signature HS256 dan masa berlaku token, lalu mengembalikan claims-nya.
+func ParseToken(tokenString string, secret []byte) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !token.Valid || claims.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// NewServiceToken menerbitkan token berumur pendek untuk panggilan antar service, ditandatangani dengan secret JWT
+// yang sama. UserID berisi nama service sehingga tercatat sebagai actor (misal "service:order-service").
+func NewServiceToken(secret []byte, serviceName string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: serviceName,
+		Role:   RoleService,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// BearerToken mengambil token dari header Authorization dengan format "Bearer <token>".
+func BearerToken(authHeader string) (string, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(authHeader), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
+
+// StripIdentityHeaders menghapus header identitas yang mungkin dikirim sendiri oleh client,
+// agar tidak bisa menyamar sebagai user lain.
+func StripIdentityHeaders(h http.Header) {
+	h.Del(HeaderUserID)
+	h.Del(HeaderUserEmail)
+	h.Del(HeaderUserRole)
+}
+
+// SetIdentityHeaders menyisipkan identitas terpercaya dari claims ke request yang akan diteruskan.
+func SetIdentityHeaders(h http.Header, claims *Claims) {
+	h.Set(HeaderUserID, claims.UserID)
+	h.Set(HeaderUserEmail, claims.Email)
+	role := claims.Role
+	if role == "" {
+		role = RoleCustomer // Token lama sebelum ada role
+	}
+	h.Set(HeaderUserRole, role)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("test-secret")
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	tokenString, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func TestParseToken(t *testing.T) {
+	t.Run("Valid token", func(t *testing.T) {
+		tokenString := signTestToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+			"user_id": "user-1",
+			"email":   "user1@example.com",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+
+		claims, err := ParseToken(tokenString, testSecret)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.UserID)
+		assert.Equal(t, "user1@example.com", claims.Email)
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		tokenString := signTestToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{
+			"user_id": "user-1",
+			"exp":     time.Now().Add(-time.Minute).Unix(),
+		})
+
+		claims, err := ParseToken(tokenString, testSecret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+		assert.Nil(t, claims)
+	})
+
+	t.Run("Token without expiry", func(t *testing.T) {
+		tokenString := signTestToken(t, jwt.SigningMethodHS256, testSecret, jwt.MapClaims{"user_id": "user-1"})
+
+		_, err := ParseToken(tokenString, testSecret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		tokenString := signTestToken(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{
+			"user_id": "user-1",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+
+		_, err := ParseToken(tokenString, testSecret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Unexpected signing method", func(t *testing.T) {
+		tokenString := signTestToken(t, jwt.SigningMethodHS512, testSecret, jwt.MapClaims{
+			"user_id": "user-1",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+
+		_, err := ParseToken(tokenString, testSecret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestBearerToken(t *testing.T) {
+	token, err := BearerToken("Bearer abc.def.ghi")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc.def.ghi", token)
+
+	_, err = BearerToken("")
+	assert.ErrorIs(t, err, ErrMissingToken)
+
+	_, err = BearerToken("Basic dXNlcjpwYXNz")
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestIdentityHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set(HeaderUserID, "spoofed-user")
+	h.Set(HeaderUserEmail, "spoofed@example.com")
+	h.Set(HeaderUserRole, RoleAdmin)
+
+	StripIdentityHeaders(h)
+	assert.Empty(t, h.Get(HeaderUserID))
+	assert.Empty(t, h.Get(HeaderUserEmail))
+	assert.Empty(t, h.Get(HeaderUserRole))
+
+	SetIdentityHeaders(h, &Claims{UserID: "user-1", Email: "user1@example.com"})
+	assert.Equal(t, "user-1", h.Get(HeaderUserID))
+	assert.Equal(t, "user1@example.com", h.Get(HeaderUserEmail))
+	assert.Equal(t, RoleCustomer, h.Get(HeaderUserRole))
+}
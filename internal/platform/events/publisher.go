@@ -0,0 +1,162 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event adalah envelope yang dikirim ke subscriber. ID stabil untuk setiap event sehingga konsumen
+// bisa mendeduplikasi: pengiriman bersifat at-least-once, event yang sama bisa diterima lebih dari sekali.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// EventPublisher mengirim satu event ke sink tujuan. Error berarti event belum terkirim dan akan dicoba lagi.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+const (
+	PublisherFile     = "file"
+	PublisherWebhook  = "webhook"
+	PublisherPostgres = "postgres"
+)
+
+// Config memilih implementasi EventPublisher. Kind kosong berarti file.
+type Config struct {
+	Kind          string
+	FilePath      string
+	WebhookURL    string
+	NotifyChannel string
+}
+
+// NewPublisher membuat EventPublisher sesuai cfg.Kind. db hanya dipakai untuk Kind "postgres".
+func NewPublisher(cfg Config, db *sql.DB) (EventPublisher, error) {
+	switch cfg.Kind {
+	case "", PublisherFile:
+		return NewFilePublisher(cfg.FilePath), nil
+	case PublisherWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("webhook event publisher requires a URL")
+		}
+		return NewWebhookPublisher(cfg.WebhookURL), nil
+	case PublisherPostgres:
+		if db == nil {
+			return nil, fmt.Errorf("postgres event publisher requires a database connection")
+		}
+		return NewPostgresNotifyPublisher(db, cfg.NotifyChannel), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q", cfg.Kind)
+	}
+}
+
+// FilePublisher menulis setiap event sebagai satu baris JSON (JSON Lines) ke file.
+type FilePublisher struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFilePublisher(path string) *FilePublisher {
+	if path == "" {
+		path = "events.jsonl"
+	}
+	return &FilePublisher{path: path}
+}
+
+func (p *FilePublisher) Publish(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", event.ID, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if dir := filepath.Dir(p.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create event directory: %w", err)
+		}
+	}
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open event file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// WebhookPublisher mengirim event sebagai POST JSON. Response selain 2xx dianggap gagal.
+type WebhookPublisher struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	return &WebhookPublisher{
+		URL:        url,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", event.ID, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver event %s: %w", event.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d for event %s", resp.StatusCode, event.ID)
+	}
+	return nil
+}
+
+// PostgresNotifyPublisher mengirim event lewat NOTIFY, untuk konsumen yang melakukan LISTEN pada channel yang sama.
+// Payload NOTIFY dibatasi Postgres (sekitar 8000 byte).
+type PostgresNotifyPublisher struct {
+	db      *sql.DB
+	channel string
+}
+
+func NewPostgresNotifyPublisher(db *sql.DB, channel string) *PostgresNotifyPublisher {
+	if channel == "" {
+		channel = "events"
+	}
+	return &PostgresNotifyPublisher{db: db, channel: channel}
+}
+
+func (p *PostgresNotifyPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %s: %w", event.ID, err)
+	}
+	if _, err := p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, p.channel, string(body)); err != nil {
+		return fmt.Errorf("failed to notify event %s: %w", event.ID, err)
+	}
+	return nil
+}
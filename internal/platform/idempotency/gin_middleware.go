@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+)
+
+// GinMiddleware menerapkan Idempotency-Key pada rute yang memasangnya.
+//   - Tanpa header: request diproses seperti biasa.
+//   - Key baru: request diproses dan response-nya disimpan selama ttl.
+//   - Key yang sama dengan payload yang sama: response yang tersimpan dikirim ulang tanpa memproses request lagi.
+//   - Key yang sama dengan payload berbeda: 422.
+//   - Key yang request pertamanya belum selesai: 409.
+//
+// Response 5xx dan handler yang panic tidak disimpan sehingga client boleh mengulang dengan key yang sama.
+// Klaim request yang sedang diproses hanya berlaku selama lease; jika proses mati di tengah request,
+// key bisa diklaim ulang setelah lease habis tanpa menunggu ttl.
+// Key dibedakan per method + rute, dan per user jika middleware auth dipasang sebelumnya.
+func GinMiddleware(store Store, ttl, lease time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = DefaultKeyTTL
+	}
+	if lease <= 0 {
+		lease = DefaultLockLease
+	}
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(HeaderKey))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > MaxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := scopeFor(c)
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.RequestURI(), body)
+		// Simpan hasil walaupun client sudah memutus koneksi, agar retry berikutnya mendapat response yang sama
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		now := time.Now()
+		claimToken := NewKey()
+		existing, created, err := store.Begin(ctx, Record{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   now.Add(ttl),
+			LockedUntil: now.Add(lease),
+			ClaimToken:  claimToken,
+		})
+		if err != nil {
+			logger.Error("Idempotency: failed to claim key", err, scope)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process Idempotency-Key"})
+			return
+		}
+		if !created {
+			replayOrReject(c, existing, requestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Klaim dilepas jika handler gagal dengan 5xx atau panic. Defer tetap berjalan saat panic,
+		// karena 500 dari gin.Recovery baru ditulis di atas middleware ini setelah panic keluar dari sini.
+		handled := false
+		defer func() {
+			if handled && recorder.Status() < http.StatusInternalServerError {
+				return
+			}
+			released, err := store.Delete(ctx, scope, key, claimToken)
+			switch {
+			case err != nil:
+				logger.Error("Idempotency: failed to release key after server error", err, scope)
+			case released == 0:
+				logger.Warn("Idempotency: claim was taken over after its lease expired, not releasing key for " + scope)
+			}
+		}()
+
+		c.Next()
+		handled = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		// Jika response gagal disimpan, klaim dibiarkan sampai lease habis agar retry tidak langsung mengulang operasi
+		stored, err := store.Complete(ctx, scope, key, claimToken, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		switch {
+		case err != nil:
+			logger.Error("Idempotency: failed to store response", err, scope)
+		case stored == 0:
+			// Request ini melewati lease dan key sudah diklaim request lain; response klaim baru tidak ditimpa
+			logger.Warn("Idempotency: claim was taken over after its lease expired, response not stored for " + scope)
+		}
+	}
+}
+
+func replayOrReject(c *gin.Context, existing *Record, requestHash string) {
+	switch {
+	case existing.RequestHash != requestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request payload"})
+	case !existing.Completed():
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		contentType := existing.ContentType
+		if contentType == "" {
+			contentType = "application/json; charset=utf-8"
+		}
+		c.Data(existing.StatusCode, contentType, existing.ResponseBody)
+		c.Abort()
+	}
+}
+
+// scopeFor membedakan key per method + rute (misal "POST /api/v1/orders"), ditambah user jika identitas tersedia,
+// sehingga key yang sama dari user lain atau endpoint lain tidak pernah saling me-replay.
+func scopeFor(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	scope := c.Request.Method + " " + route
+	if identity, ok := auth.IdentityFromGin(c); ok && identity.UserID != "" {
+		scope += " user:" + identity.UserID
+	}
+	return scope
+}
+
+// responseRecorder meneruskan response ke client sambil menyalin body-nya untuk disimpan.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,222 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore adalah Store in-memory untuk pengujian middleware.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}}
+}
+
+func (s *memoryStore) Begin(_ context.Context, rec Record) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := rec.Scope + "|" + rec.Key
+	abandoned := func(r *Record) bool { return !r.Completed() && !r.LockedUntil.After(time.Now()) }
+	if existing, ok := s.records[id]; ok && existing.ExpiresAt.After(time.Now()) && !abandoned(existing) {
+		copied := *existing
+		return &copied, false, nil
+	}
+	rec.CreatedAt = time.Now()
+	s.records[id] = &rec
+	return nil, true, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, scope, key, claimToken string, statusCode int, contentType string, body []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[scope+"|"+key]
+	if !ok || rec.ClaimToken != claimToken || rec.Completed() {
+		return 0, nil
+	}
+	rec.StatusCode, rec.ContentType, rec.ResponseBody = statusCode, contentType, body
+	return 1, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, scope, key, claimToken string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[scope+"|"+key]
+	if !ok || rec.ClaimToken != claimToken || rec.Completed() {
+		return 0, nil
+	}
+	delete(s.records, scope+"|"+key)
+	return 1, nil
+}
+
+func (s *memoryStore) DeleteExpired(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func newTestRouter(store Store, status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", GinMiddleware(store, time.Hour, time.Minute), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"call": *calls})
+	})
+	return router
+}
+
+func postOrder(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGinMiddleware(t *testing.T) {
+	t.Run("Requests without a key are not deduplicated", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		router := newTestRouter(newMemoryStore(), &status, &calls)
+
+		postOrder(router, "", `{"qty":1}`)
+		postOrder(router, "", `{"qty":1}`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Repeat with the same payload replays the stored response", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		router := newTestRouter(newMemoryStore(), &status, &calls)
+
+		first := postOrder(router, "key-1", `{"qty":1}`)
+		second := postOrder(router, "key-1", `{"qty":1}`)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Key reused with a different payload is rejected", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		router := newTestRouter(newMemoryStore(), &status, &calls)
+
+		postOrder(router, "key-1", `{"qty":1}`)
+		rec := postOrder(router, "key-1", `{"qty":2}`)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("Request still in progress", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		store := newMemoryStore()
+		router := newTestRouter(store, &status, &calls)
+		// Klaim key tanpa menyelesaikannya, seolah request pertama masih berjalan
+		_, _, _ = store.Begin(context.Background(), Record{
+			Scope:       "POST /orders",
+			Key:         "key-1",
+			RequestHash: hashRequest(http.MethodPost, "/orders", []byte(`{"qty":1}`)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+			LockedUntil: time.Now().Add(time.Minute),
+		})
+
+		rec := postOrder(router, "key-1", `{"qty":1}`)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("Abandoned claim is taken over after the lease", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		store := newMemoryStore()
+		router := newTestRouter(store, &status, &calls)
+		// Klaim dari proses yang mati di tengah request: belum selesai dan lease-nya sudah lewat
+		_, _, _ = store.Begin(context.Background(), Record{
+			Scope:       "POST /orders",
+			Key:         "key-1",
+			RequestHash: hashRequest(http.MethodPost, "/orders", []byte(`{"qty":1}`)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+			LockedUntil: time.Now().Add(-time.Second),
+		})
+
+		rec := postOrder(router, "key-1", `{"qty":1}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("Stale request cannot overwrite or release a taken-over claim", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		store := newMemoryStore()
+		router := gin.New()
+		router.POST("/orders", GinMiddleware(store, time.Hour, time.Minute), func(c *gin.Context) {
+			// Lease request ini habis di tengah jalan dan key diklaim ulang oleh retry
+			store.records["POST /orders|key-1"].LockedUntil = time.Now().Add(-time.Second)
+			_, created, _ := store.Begin(context.Background(), Record{
+				Scope: "POST /orders", Key: "key-1", ClaimToken: "retry-claim",
+				ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(time.Minute),
+			})
+			assert.True(t, created)
+			c.JSON(http.StatusCreated, gin.H{"call": 1})
+		})
+
+		postOrder(router, "key-1", `{"qty":1}`)
+
+		rec := store.records["POST /orders|key-1"]
+		assert.Equal(t, "retry-claim", rec.ClaimToken)
+		assert.False(t, rec.Completed())
+	})
+
+	t.Run("Handler panic releases the key", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		store, calls := newMemoryStore(), 0
+		router := gin.New()
+		router.Use(gin.CustomRecovery(func(c *gin.Context, _ interface{}) { c.AbortWithStatus(http.StatusInternalServerError) }))
+		router.POST("/orders", GinMiddleware(store, time.Hour, time.Minute), func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			c.JSON(http.StatusCreated, gin.H{"call": calls})
+		})
+
+		first := postOrder(router, "key-1", `{"qty":1}`)
+		second := postOrder(router, "key-1", `{"qty":1}`)
+
+		assert.Equal(t, http.StatusInternalServerError, first.Code)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusCreated, second.Code)
+	})
+
+	t.Run("Server errors are not stored so the request can be retried", func(t *testing.T) {
+		status, calls := http.StatusInternalServerError, 0
+		router := newTestRouter(newMemoryStore(), &status, &calls)
+
+		postOrder(router, "key-1", `{"qty":1}`)
+		status = http.StatusCreated
+		rec := postOrder(router, "key-1", `{"qty":1}`)
+
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("Expired key is treated as new", func(t *testing.T) {
+		status, calls := http.StatusCreated, 0
+		store := newMemoryStore()
+		router := newTestRouter(store, &status, &calls)
+
+		postOrder(router, "key-1", `{"qty":1}`)
+		store.records["POST /orders|key-1"].ExpiresAt = time.Now().Add(-time.Minute)
+		postOrder(router, "key-1", `{"qty":2}`)
+
+		assert.Equal(t, 2, calls)
+	})
+}
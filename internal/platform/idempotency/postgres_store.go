@@ -0,0 +1,83 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore memakai tabel idempotency_keys di database milik service (lihat migrasi masing-masing service).
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Begin(ctx context.Context, rec Record) (*Record, bool, error) {
+	// Insert key baru, atau ambil alih key yang sudah kedaluwarsa maupun klaim yang ditinggalkan (request belum selesai
+	// dan locked_until sudah lewat, misal karena proses mati). Jika key masih berlaku, tidak ada baris yang dikembalikan.
+	query := `INSERT INTO idempotency_keys (scope, key, request_hash, expires_at, locked_until, claim_token)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              ON CONFLICT (scope, key) DO UPDATE
+              SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL,
+                  response_body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at,
+                  locked_until = EXCLUDED.locked_until, claim_token = EXCLUDED.claim_token
+              WHERE idempotency_keys.expires_at <= NOW()
+                 OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW())
+              RETURNING created_at`
+	err := s.db.QueryRowContext(ctx, query, rec.Scope, rec.Key, rec.RequestHash, rec.ExpiresAt, rec.LockedUntil, rec.ClaimToken).Scan(&rec.CreatedAt)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	existing := &Record{}
+	var statusCode sql.NullInt32
+	var contentType sql.NullString
+	var lockedUntil sql.NullTime
+	query = `SELECT scope, key, request_hash, status_code, content_type, response_body, created_at, expires_at, locked_until
+             FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	err = s.db.QueryRowContext(ctx, query, rec.Scope, rec.Key).Scan(
+		&existing.Scope, &existing.Key, &existing.RequestHash, &statusCode, &contentType,
+		&existing.ResponseBody, &existing.CreatedAt, &existing.ExpiresAt, &lockedUntil,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+	existing.StatusCode = int(statusCode.Int32)
+	existing.ContentType = contentType.String
+	existing.LockedUntil = lockedUntil.Time
+	return existing, false, nil
+}
+
+func (s *postgresStore) Complete(ctx context.Context, scope, key, claimToken string, statusCode int, contentType string, body []byte) (int64, error) {
+	query := `UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3
+              WHERE scope = $4 AND key = $5 AND claim_token = $6 AND status_code IS NULL`
+	result, err := s.db.ExecContext(ctx, query, statusCode, contentType, body, scope, key, claimToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *postgresStore) Delete(ctx context.Context, scope, key, claimToken string) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND claim_token = $3 AND status_code IS NULL`
+	result, err := s.db.ExecContext(ctx, query, scope, key, claimToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *postgresStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
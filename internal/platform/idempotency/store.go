@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// HeaderKey adalah header yang dikirim client untuk menandai request yang aman diulang.
+const HeaderKey = "Idempotency-Key"
+
+// MaxKeyLength membatasi panjang key yang diterima dari client.
+const MaxKeyLength = 255
+
+// DefaultKeyTTL dipakai jika service tidak mengatur masa berlaku key sendiri.
+const DefaultKeyTTL = 24 * time.Hour
+
+// DefaultLockLease dipakai jika service tidak mengatur lama klaim request yang sedang diproses.
+const DefaultLockLease = 2 * time.Minute
+
+// Record adalah satu key yang pernah dipakai beserta hash request dan response yang disimpan.
+// StatusCode 0 berarti request pertama dengan key ini masih diproses.
+type Record struct {
+	Scope        string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	// LockedUntil adalah batas klaim selama request pertama diproses. Jika proses mati sebelum menyelesaikannya,
+	// klaim boleh diambil alih setelah waktu ini tanpa menunggu ExpiresAt.
+	LockedUntil time.Time
+	// ClaimToken unik per klaim. Complete dan Delete hanya berlaku untuk klaim dengan token yang sama,
+	// sehingga request lama yang klaimnya sudah diambil alih tidak bisa menimpa atau menghapus klaim baru.
+	ClaimToken string
+}
+
+func (r *Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Store menyimpan idempotency key per service.
+type Store interface {
+	// Begin mengklaim key untuk request baru. Jika key (dalam scope yang sama) masih berlaku,
+	// record yang sudah ada dikembalikan dengan created=false dan tidak ada yang diubah.
+	// Key yang sudah kedaluwarsa, atau yang belum selesai dan LockedUntil-nya sudah lewat, diklaim ulang seperti key baru.
+	Begin(ctx context.Context, rec Record) (existing *Record, created bool, err error)
+	// Complete menyimpan response dari request yang mengklaim key. Mengembalikan jumlah baris yang berubah;
+	// 0 berarti klaim dengan claimToken tersebut sudah diambil alih request lain.
+	Complete(ctx context.Context, scope, key, claimToken string, statusCode int, contentType string, body []byte) (int64, error)
+	// Delete melepas klaim key, misal ketika request gagal dengan error server sehingga boleh diulang.
+	// Seperti Complete, hanya berlaku untuk klaim dengan claimToken yang sama.
+	Delete(ctx context.Context, scope, key, claimToken string) (int64, error)
+	// DeleteExpired membersihkan key yang sudah melewati masa berlakunya.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// NewKey membuat key acak (format UUID v4) untuk client yang mengirim request antar service.
+func NewKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand tidak seharusnya gagal; fallback tetap unik per waktu
+		return fmt.Sprintf("key-%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// hashRequest menghasilkan sidik jari request, dipakai untuk mendeteksi key yang dipakai ulang dengan payload berbeda.
+func hashRequest(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(uri))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package money
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Decimal membaca kolom Postgres DECIMAL/NUMERIC apa adanya sebagai teks, tanpa melewati float.
+// Mata uang biasanya ada di kolom lain, jadi Money dibentuk setelah scan:
+//
+//	var price money.Decimal
+//	var currency string
+//	row.Scan(&price, &currency)
+//	p.Price, err = price.Money(money.Currency(currency))
+type Decimal struct {
+	text string
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		d.text = string(v)
+	case string:
+		d.text = v
+	case int64:
+		d.text = strconv.FormatInt(v, 10)
+	case float64:
+		// Sebagian driver mengembalikan float; format terpendek yang tepat mempertahankan nilai DECIMAL aslinya
+		d.text = strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return fmt.Errorf("%w: NULL", ErrInvalidAmount)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+	return nil
+}
+
+// Money mengubah nilai hasil scan menjadi Money dalam mata uang tertentu. Nilai yang lebih presisi dari mata uangnya
+// (misal sen pada rupiah) ditolak alih-alih dibulatkan, agar nominal yang dilaporkan selalu sama dengan yang tersimpan.
+func (d Decimal) Money(currency Currency) (Money, error) {
+	return ParseExact(d.text, currency)
+}
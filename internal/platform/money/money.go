@@ -0,0 +1,277 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid money amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Currency adalah kode mata uang ISO 4217.
+type Currency string
+
+const (
+	IDR Currency = "IDR"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	SGD Currency = "SGD"
+	MYR Currency = "MYR"
+	JPY Currency = "JPY"
+)
+
+// DefaultCurrency dipakai untuk data yang dibuat sebelum kolom currency ada.
+const DefaultCurrency = IDR
+
+// currencyExponents adalah jumlah digit di belakang koma (minor unit) setiap mata uang,
+// sekaligus aturan pembulatannya. IDR memakai 0: ISO 4217 mencantumkan 2 digit, tapi sen tidak dipakai
+// dan payment gateway menolak nominal rupiah pecahan.
+var currencyExponents = map[Currency]int{
+	IDR: 0,
+	USD: 2,
+	EUR: 2,
+	SGD: 2,
+	MYR: 2,
+	JPY: 0,
+}
+
+// ParseCurrency memvalidasi kode mata uang (tidak peka huruf besar/kecil).
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if _, ok := currencyExponents[c]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Exponent mengembalikan jumlah digit minor unit mata uang.
+func (c Currency) Exponent() (int, bool) {
+	exp, ok := currencyExponents[c]
+	return exp, ok
+}
+
+// Money adalah nominal uang yang tepat dalam minor unit (misal sen untuk USD, rupiah untuk IDR) beserta mata uangnya.
+// Zero value (tanpa mata uang) berarti "tidak ada nilai" dan di-encode sebagai JSON null.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// New membuat Money dari minor unit. Mata uang harus dikenal (lihat ParseCurrency).
+func New(minorUnits int64, currency Currency) Money {
+	return Money{amount: minorUnits, currency: currency}
+}
+
+func Zero(currency Currency) Money {
+	return Money{currency: currency}
+}
+
+// Parse membaca nominal desimal seperti "14000000" atau "10.505".
+// Digit yang melebihi presisi mata uang dibulatkan setengah menjauhi nol (10.505 USD -> 10.51, 1500.5 IDR -> 1501).
+func Parse(amount string, currency Currency) (Money, error) {
+	return parse(amount, currency, false)
+}
+
+// ParseExact seperti Parse, tetapi menolak nominal yang punya digit bukan nol di luar presisi mata uang
+// (1200.50 IDR ditolak, 1200.00 IDR diterima). Dipakai untuk nilai tersimpan yang tidak boleh berubah diam-diam saat dibaca.
+func ParseExact(amount string, currency Currency) (Money, error) {
+	return parse(amount, currency, true)
+}
+
+func parse(amount string, currency Currency, exact bool) (Money, error) {
+	exp, ok := currency.Exponent()
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" || !isDigits(intPart) || !isDigits(fracPart) || (strings.Contains(s, ".") && fracPart == "") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	roundUp := false
+	if len(fracPart) > exp {
+		if exact && strings.TrimRight(fracPart[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more precision than %s allows", ErrInvalidAmount, amount, currency)
+		}
+		roundUp = fracPart[exp] >= '5'
+		fracPart = fracPart[:exp]
+	} else {
+		fracPart += strings.Repeat("0", exp-len(fracPart))
+	}
+	minor, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil || (roundUp && minor == maxInt64) {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, amount)
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{amount: minor, currency: currency}, nil
+}
+
+// MustParse seperti Parse tetapi panic jika gagal; untuk konstanta dan test.
+func MustParse(amount string, currency Currency) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+const maxInt64 = 1<<63 - 1
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MinorUnits mengembalikan nominal dalam minor unit.
+func (m Money) MinorUnits() int64 {
+	return m.amount
+}
+
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.amount > 0
+}
+
+// Equal bernilai true jika nominal dan mata uangnya sama.
+func (m Money) Equal(other Money) bool {
+	return m.amount == other.amount && m.currency == other.currency
+}
+
+// Add menjumlahkan dua nominal dengan mata uang yang sama.
+func (m Money) Add(other Money) (Money, error) {
+	if m.currency != other.currency {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.currency, other.currency)
+	}
+	sum := m.amount + other.amount
+	if (other.amount > 0 && sum < m.amount) || (other.amount < 0 && sum > m.amount) {
+		return Money{}, fmt.Errorf("%w: sum is out of range", ErrInvalidAmount)
+	}
+	return Money{amount: sum, currency: m.currency}, nil
+}
+
+// Sub mengurangkan other dari m; keduanya harus bermata uang sama.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.amount == -maxInt64-1 {
+		return Money{}, fmt.Errorf("%w: difference is out of range", ErrInvalidAmount)
+	}
+	return m.Add(Money{amount: -other.amount, currency: other.currency})
+}
+
+// Mul mengalikan nominal dengan jumlah (misal harga satuan x kuantitas).
+func (m Money) Mul(quantity int64) (Money, error) {
+	if quantity != 0 && m.amount != 0 {
+		product := m.amount * quantity
+		if product/quantity != m.amount {
+			return Money{}, fmt.Errorf("%w: product is out of range", ErrInvalidAmount)
+		}
+		return Money{amount: product, currency: m.currency}, nil
+	}
+	return Money{currency: m.currency}, nil
+}
+
+// Decimal mengembalikan nominal sebagai teks desimal sesuai presisi mata uangnya, misal "10.50" atau "14000000".
+func (m Money) Decimal() string {
+	exp, _ := m.currency.Exponent()
+	abs := strconv.FormatUint(absUint(m.amount), 10)
+	if exp > 0 {
+		if len(abs) <= exp {
+			abs = strings.Repeat("0", exp-len(abs)+1) + abs
+		}
+		abs = abs[:len(abs)-exp] + "." + abs[len(abs)-exp:]
+	}
+	if m.amount < 0 {
+		return "-" + abs
+	}
+	return abs
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.currency)
+}
+
+// jsonMoney adalah bentuk JSON Money. Nominal dikirim sebagai string agar tidak kehilangan presisi di client.
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON menghasilkan {"amount": "10.50", "currency": "USD"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.currency == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(struct {
+		Amount   string   `json:"amount"`
+		Currency Currency `json:"currency"`
+	}{m.Decimal(), m.currency})
+}
+
+// UnmarshalJSON menerima {"amount": "10.50", "currency": "USD"}. Amount berupa angka JSON juga diterima
+// dan dibaca dari teks aslinya, bukan lewat float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	var raw jsonMoney
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: expected an object with amount and currency", ErrInvalidAmount)
+	}
+	currency, err := ParseCurrency(raw.Currency)
+	if err != nil {
+		return err
+	}
+	amount := string(bytes.TrimSpace(raw.Amount))
+	if unquoted, err := strconv.Unquote(amount); err == nil {
+		amount = unquoted
+	}
+	parsed, err := Parse(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value menyimpan nominal ke kolom DECIMAL sebagai teks desimal. Mata uang disimpan di kolom terpisah.
+func (m Money) Value() (driver.Value, error) {
+	if _, ok := m.currency.Exponent(); !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurrency, m.currency)
+	}
+	return m.Decimal(), nil
+}
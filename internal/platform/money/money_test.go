@@ -0,0 +1,138 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency Currency
+		minor    int64
+		decimal  string
+	}{
+		{"14000000", IDR, 14000000, "14000000"},
+		{"14000000.00", IDR, 14000000, "14000000"},
+		{"1500.5", IDR, 1501, "1501"},  // Dibulatkan setengah menjauhi nol
+		{"1500.49", IDR, 1500, "1500"}, // Hanya digit pertama yang dibuang yang menentukan
+		{"10.5", USD, 1050, "10.50"},
+		{"10.505", USD, 1051, "10.51"},
+		{"10.504", USD, 1050, "10.50"},
+		{"-10.505", USD, -1051, "-10.51"},
+		{"0.07", EUR, 7, "0.07"},
+		{"1200", JPY, 1200, "1200"},
+	}
+	for _, tc := range cases {
+		m, err := Parse(tc.amount, tc.currency)
+		assert.NoError(t, err, tc.amount)
+		assert.Equal(t, tc.minor, m.MinorUnits(), tc.amount)
+		assert.Equal(t, tc.decimal, m.Decimal(), tc.amount)
+	}
+
+	for _, invalid := range []string{"", "abc", "1.2.3", ".5", "10.", "1e5", "+5", "99999999999999999999"} {
+		_, err := Parse(invalid, USD)
+		assert.ErrorIs(t, err, ErrInvalidAmount, invalid)
+	}
+	_, err := Parse("10", Currency("XXX"))
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestParseCurrency(t *testing.T) {
+	c, err := ParseCurrency(" idr ")
+	assert.NoError(t, err)
+	assert.Equal(t, IDR, c)
+
+	_, err = ParseCurrency("RUPIAH")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestArithmetic(t *testing.T) {
+	price := MustParse("0.10", USD)
+
+	total, err := price.Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.30", total.Decimal()) // Tidak ada 0.30000000000000004 seperti float
+
+	sum, err := total.Add(MustParse("0.20", USD))
+	assert.NoError(t, err)
+	assert.True(t, sum.Equal(MustParse("0.50", USD)))
+
+	diff, err := sum.Sub(MustParse("0.75", USD))
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.25", diff.Decimal())
+
+	_, err = sum.Add(MustParse("1", IDR))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = New(maxInt64, IDR).Add(New(1, IDR))
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = New(maxInt64/2+1, IDR).Mul(2)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestJSON(t *testing.T) {
+	encoded, err := json.Marshal(MustParse("10.5", USD))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"10.50","currency":"USD"}`, string(encoded))
+
+	encoded, err = json.Marshal(struct {
+		Price *Money `json:"price,omitempty"`
+		Total Money  `json:"total"`
+	}{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":null}`, string(encoded))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"14000000","currency":"idr"}`), &m))
+	assert.True(t, m.Equal(New(14000000, IDR)))
+
+	// Angka JSON dibaca dari teks aslinya
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":19.99,"currency":"USD"}`), &m))
+	assert.Equal(t, int64(1999), m.MinorUnits())
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"10","currency":"XXX"}`), &m), ErrUnknownCurrency)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"ten","currency":"USD"}`), &m), ErrInvalidAmount)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`10.5`), &m), ErrInvalidAmount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`null`), &m))
+	assert.Equal(t, Money{}, m)
+}
+
+func TestDecimalScan(t *testing.T) {
+	for _, src := range []interface{}{[]byte("1252.48"), "1252.48", 1252.48} {
+		var d Decimal
+		assert.NoError(t, d.Scan(src))
+		m, err := d.Money(USD)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(125248), m.MinorUnits())
+	}
+
+	var d Decimal
+	assert.NoError(t, d.Scan(int64(600000)))
+	m, err := d.Money(IDR)
+	assert.NoError(t, err)
+	assert.Equal(t, "600000", m.Decimal())
+
+	// DECIMAL(12, 2) selalu punya dua digit; nol di belakang koma boleh, sen pada rupiah tidak dibulatkan diam-diam
+	assert.NoError(t, d.Scan("1252.00"))
+	m, err = d.Money(IDR)
+	assert.NoError(t, err)
+	assert.Equal(t, "1252", m.Decimal())
+	assert.NoError(t, d.Scan("1252.48"))
+	_, err = d.Money(IDR)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	assert.ErrorIs(t, d.Scan(nil), ErrInvalidAmount)
+}
+
+func TestValue(t *testing.T) {
+	v, err := MustParse("10.5", USD).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.50", v)
+
+	_, err = Money{}.Value()
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
 	"github.com/ridloal/e-commerce-go-microservices/internal/product/repository"
 	"github.com/ridloal/e-commerce-go-microservices/internal/product/service"
 )
@@ -24,6 +25,7 @@ func (h *ProductHandler) RegisterRoutes(router *gin.RouterGroup) {
 		productRoutes.GET("", h.ListProducts)
 		productRoutes.GET("/", h.ListProducts)
 		productRoutes.GET("/:id", h.GetProduct)
+		productRoutes.POST("/prices", h.GetProductPrices) // Lookup harga batch untuk checkout
 	}
 }
 
@@ -51,3 +53,19 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, product)
 }
+
+func (h *ProductHandler) GetProductPrices(c *gin.Context) {
+	var req domain.ProductPricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	prices, err := h.productService.GetProductPrices(c.Request.Context(), req.ProductIDs)
+	if err != nil {
+		logger.Error("GetProductPrices: service error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product prices"})
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
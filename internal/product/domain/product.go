@@ -2,14 +2,38 @@ package domain
 
 import (
 	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
 )
 
 type Product struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Price         float64   `json:"price"` // Menggunakan float untuk kemudahan, decimal lebih baik untuk uang
-	StockQuantity int       `json:"stock_quantity"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string      `json:"id"`
+	SKU           string      `json:"sku"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Price         money.Money `json:"price"`
+	StockQuantity int         `json:"stock_quantity"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// MaxProductPriceLookup membatasi jumlah produk dalam satu request lookup harga.
+const MaxProductPriceLookup = 100
+
+// Request lookup harga beberapa produk sekaligus (dipakai Order Service saat checkout)
+type ProductPricesRequest struct {
+	ProductIDs []string `json:"product_ids" binding:"required,min=1,max=100,dive,uuid"`
+}
+
+// ProductPrice adalah harga resmi produk beserta snapshot nama/SKU untuk dicatat di order.
+type ProductPrice struct {
+	ProductID string      `json:"product_id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+}
+
+type ProductPricesResponse struct {
+	Prices   []ProductPrice `json:"prices"`
+	NotFound []string       `json:"not_found"` // ID produk yang tidak ditemukan
 }
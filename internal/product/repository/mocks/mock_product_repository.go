@@ -27,3 +27,11 @@ func (m *MockProductRepository) GetProductByID(ctx context.Context, id string) (
 	}
 	return nil, args.Error(1)
 }
+
+func (m *MockProductRepository) GetProductsByIDs(ctx context.Context, ids []string) ([]pDomain.Product, error) {
+	args := m.Called(ctx, ids)
+	if res := args.Get(0); res != nil {
+		return res.([]pDomain.Product), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -5,15 +5,37 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/lib/pq"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
 	"github.com/ridloal/e-commerce-go-microservices/internal/product/domain"
 )
 
 var ErrProductNotFound = errors.New("product not found")
 
+// productColumns dan scanProduct dipakai bersama oleh semua query yang membaca tabel products.
+const productColumns = `id, sku, name, description, price, currency, stock_quantity, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner, p *domain.Product) error {
+	var price money.Decimal
+	var currency string
+	if err := row.Scan(&p.ID, &p.SKU, &p.Name, &p.Description, &price, &currency, &p.StockQuantity, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	var err error
+	p.Price, err = price.Money(money.Currency(currency))
+	return err
+}
+
 type ProductRepository interface {
 	ListProducts(ctx context.Context) ([]domain.Product, error)
 	GetProductByID(ctx context.Context, id string) (*domain.Product, error)
+	// GetProductsByIDs mengembalikan produk yang ditemukan; ID yang tidak ada dilewati
+	GetProductsByIDs(ctx context.Context, ids []string) ([]domain.Product, error)
 	// Add CreateProduct, UpdateProduct, DeleteProduct later if needed
 }
 
@@ -26,7 +48,7 @@ func NewPostgresProductRepository(db *sql.DB) ProductRepository {
 }
 
 func (r *postgresProductRepository) ListProducts(ctx context.Context) ([]domain.Product, error) {
-	query := `SELECT id, name, description, price, stock_quantity, created_at, updated_at FROM products ORDER BY created_at DESC`
+	query := `SELECT ` + productColumns + ` FROM products ORDER BY created_at DESC`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		logger.Error("ListProducts: query failed", err)
@@ -37,7 +59,7 @@ func (r *postgresProductRepository) ListProducts(ctx context.Context) ([]domain.
 	products := []domain.Product{}
 	for rows.Next() {
 		var p domain.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.StockQuantity, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			logger.Error("ListProducts: scan failed", err)
 			return nil, err
 		}
@@ -51,11 +73,9 @@ func (r *postgresProductRepository) ListProducts(ctx context.Context) ([]domain.
 }
 
 func (r *postgresProductRepository) GetProductByID(ctx context.Context, id string) (*domain.Product, error) {
-	query := `SELECT id, name, description, price, stock_quantity, created_at, updated_at FROM products WHERE id = $1`
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
 	var p domain.Product
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&p.ID, &p.Name, &p.Description, &p.Price, &p.StockQuantity, &p.CreatedAt, &p.UpdatedAt,
-	)
+	err := scanProduct(r.db.QueryRowContext(ctx, query, id), &p)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrProductNotFound
@@ -65,3 +85,32 @@ func (r *postgresProductRepository) GetProductByID(ctx context.Context, id strin
 	}
 	return &p, nil
 }
+
+func (r *postgresProductRepository) GetProductsByIDs(ctx context.Context, ids []string) ([]domain.Product, error) {
+	products := []domain.Product{}
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = ANY($1)`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		logger.Error("GetProductsByIDs: query failed", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p domain.Product
+		if err := scanProduct(rows, &p); err != nil {
+			logger.Error("GetProductsByIDs: scan failed", err)
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("GetProductsByIDs: rows iteration error", err)
+		return nil, err
+	}
+	return products, nil
+}
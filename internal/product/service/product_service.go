@@ -12,6 +12,8 @@ import (
 type ProductService interface {
 	ListProducts(ctx context.Context) ([]domain.Product, error)
 	GetProductDetails(ctx context.Context, productID string) (*domain.Product, error)
+	// GetProductPrices mengembalikan harga resmi beberapa produk sekaligus tanpa memanggil Warehouse Service
+	GetProductPrices(ctx context.Context, productIDs []string) (*domain.ProductPricesResponse, error)
 	// CreateProduct, UpdateProduct (tanpa stock), DeleteProduct - sementara manual dari db
 }
 
@@ -94,3 +96,35 @@ func (s *productServiceImpl) GetProductDetails(ctx context.Context, productID st
 
 	return product, nil
 }
+
+func (s *productServiceImpl) GetProductPrices(ctx context.Context, productIDs []string) (*domain.ProductPricesResponse, error) {
+	// Hilangkan duplikat, urutan request dipertahankan
+	seen := make(map[string]bool, len(productIDs))
+	uniqueIDs := make([]string, 0, len(productIDs))
+	for _, id := range productIDs {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+
+	products, err := s.repo.GetProductsByIDs(ctx, uniqueIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]domain.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	resp := &domain.ProductPricesResponse{Prices: []domain.ProductPrice{}, NotFound: []string{}}
+	for _, id := range uniqueIDs {
+		p, ok := byID[id]
+		if !ok {
+			resp.NotFound = append(resp.NotFound, id)
+			continue
+		}
+		resp.Prices = append(resp.Prices, domain.ProductPrice{ProductID: p.ID, SKU: p.SKU, Name: p.Name, Price: p.Price})
+	}
+	return resp, nil
+}
@@ -11,8 +11,10 @@ import (
 	// Mock untuk product repo
 	"github.com/ridloal/e-commerce-go-microservices/internal/product/repository/mocks"
 	// Mock untuk warehouse client
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/money"
 	whClientMocks "github.com/ridloal/e-commerce-go-microservices/internal/product/service/mocks"
 	whDomain "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestProductService_ListProducts(t *testing.T) {
@@ -21,8 +23,8 @@ func TestProductService_ListProducts(t *testing.T) {
 
 	ctx := context.TODO()
 	mockProducts := []pDomain.Product{
-		{ID: "prod1", Name: "Product 1", Price: 100},
-		{ID: "prod2", Name: "Product 2", Price: 200},
+		{ID: "prod1", Name: "Product 1", Price: money.New(100, money.IDR)},
+		{ID: "prod2", Name: "Product 2", Price: money.New(200, money.IDR)},
 	}
 
 	t.Run("Successful list with stock info", func(t *testing.T) {
@@ -45,7 +47,7 @@ func TestProductService_GetProductDetails(t *testing.T) {
 	mockWhClient := new(whClientMocks.MockWarehouseServiceClientForProduct)
 
 	ctx := context.TODO()
-	mockProduct := &pDomain.Product{ID: "prod1", Name: "Product 1", Price: 100}
+	mockProduct := &pDomain.Product{ID: "prod1", Name: "Product 1", Price: money.New(100, money.IDR)}
 
 	t.Run("Successful get with stock info", func(t *testing.T) {
 		mockRepo.On("GetProductByID", ctx, "prod1").Return(mockProduct, nil).Once()
@@ -67,3 +69,37 @@ func TestProductService_GetProductDetails(t *testing.T) {
 		t.Log("Skipping GetProductDetails failed stock info due to concrete dependency.")
 	})
 }
+
+func TestProductService_GetProductPrices(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Returns prices in request order and reports missing products", func(t *testing.T) {
+		mockRepo := new(mocks.MockProductRepository)
+		svc := NewProductService(mockRepo, nil) // Lookup harga tidak memanggil Warehouse Service
+		mockRepo.On("GetProductsByIDs", ctx, []string{"prod2", "prod1", "prod3"}).Return([]pDomain.Product{
+			{ID: "prod1", SKU: "SKU-1", Name: "Product 1", Price: money.New(100, money.IDR)},
+			{ID: "prod2", SKU: "SKU-2", Name: "Product 2", Price: money.New(200, money.IDR)},
+		}, nil).Once()
+
+		resp, err := svc.GetProductPrices(ctx, []string{"prod2", "prod1", "prod2", "prod3"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []pDomain.ProductPrice{
+			{ProductID: "prod2", SKU: "SKU-2", Name: "Product 2", Price: money.New(200, money.IDR)},
+			{ProductID: "prod1", SKU: "SKU-1", Name: "Product 1", Price: money.New(100, money.IDR)},
+		}, resp.Prices)
+		assert.Equal(t, []string{"prod3"}, resp.NotFound)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Repository error", func(t *testing.T) {
+		mockRepo := new(mocks.MockProductRepository)
+		svc := NewProductService(mockRepo, nil)
+		mockRepo.On("GetProductsByIDs", ctx, []string{"prod1"}).Return(nil, errors.New("db error")).Once()
+
+		resp, err := svc.GetProductPrices(ctx, []string{"prod1"})
+
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	})
+}
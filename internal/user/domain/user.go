@@ -9,6 +9,7 @@ type User struct {
 	Email        string    `json:"email"`
 	PhoneNumber  *string   `json:"phone_number,omitempty"` // Pointer agar bisa null
 	PasswordHash string    `json:"-"`                      // Jangan kirim password hash ke client
+	Role         string    `json:"role"`                   // "customer" atau "admin"
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -31,7 +31,7 @@ func NewPostgresUserRepository(db *sql.DB) UserRepository {
 
 func (r *postgresUserRepository) CreateUser(ctx context.Context, user *domain.User) error {
 	query := `INSERT INTO users (email, phone_number, password_hash, created_at, updated_at)
-              VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+              VALUES ($1, $2, $3, $4, $5) RETURNING id, role, created_at, updated_at`
 
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
@@ -42,7 +42,7 @@ func (r *postgresUserRepository) CreateUser(ctx context.Context, user *domain.Us
 	}
 
 	err := r.db.QueryRowContext(ctx, query, user.Email, phoneNumber, user.PasswordHash, user.CreatedAt, user.UpdatedAt).
-		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+		Scan(&user.ID, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		// Cek error spesifik PostgreSQL untuk duplikasi (unique violation)
@@ -58,12 +58,12 @@ func (r *postgresUserRepository) CreateUser(ctx context.Context, user *domain.Us
 }
 
 func (r *postgresUserRepository) getUserBy(ctx context.Context, field, value string) (*domain.User, error) {
-	query := `SELECT id, email, phone_number, password_hash, created_at, updated_at FROM users WHERE ` + field + ` = $1`
+	query := `SELECT id, email, phone_number, password_hash, role, created_at, updated_at FROM users WHERE ` + field + ` = $1`
 	user := &domain.User{}
 	var phoneNumber sql.NullString
 
 	err := r.db.QueryRowContext(ctx, query, value).Scan(
-		&user.ID, &user.Email, &phoneNumber, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &phoneNumber, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
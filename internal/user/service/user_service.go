@@ -4,12 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/config"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 	"github.com/ridloal/e-commerce-go-microservices/internal/user/domain"
 	"github.com/ridloal/e-commerce-go-microservices/internal/user/repository"
@@ -24,12 +23,7 @@ var (
 var jwtSecretKey []byte
 
 func init() {
-	secret := os.Getenv("JWT_SECRET_KEY")
-	if secret == "" {
-		log.Println("Warning: JWT_SECRET_KEY not set, using default insecure key")
-		secret = "your-very-secret-key-for-jwt" // fallback
-	}
-	jwtSecretKey = []byte(secret)
+	jwtSecretKey = config.LoadJWTSecretKey()
 }
 
 type UserService interface {
@@ -98,6 +92,7 @@ func (s *userService) Login(ctx context.Context, req domain.LoginRequest) (*doma
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
+		"role":    user.Role,
 		"exp":     time.Now().Add(time.Hour * 72).Unix(), // Token berlaku 72 jam
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -2,10 +2,13 @@ package api
 
 import (
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
@@ -20,30 +23,100 @@ func NewWarehouseHandler(ws service.WarehouseService) *WarehouseHandler {
 	return &WarehouseHandler{warehouseService: ws}
 }
 
-func (h *WarehouseHandler) RegisterRoutes(router *gin.RouterGroup) {
-	whRoutes := router.Group("/warehouses")
+// RegisterRoutes memasang rute warehouse. idempotencyMiddleware dipasang pada operasi stok yang mengubah data,
+// sehingga retry dengan Idempotency-Key yang sama tidak diterapkan dua kali.
+// authMiddleware boleh meloloskan request anonim (lihat auth.OptionalGinMiddleware) karena Order Service
+// memanggil rute stok tanpa identitas user; identitas yang ada dicatat sebagai actor di buku besar stok.
+// Rute reservasi per order mewajibkan token service Order Service atau admin.
+func (h *WarehouseHandler) RegisterRoutes(router *gin.RouterGroup, authMiddleware, idempotencyMiddleware gin.HandlerFunc) {
+	whRoutes := router.Group("/warehouses", authMiddleware)
 	{
 		whRoutes.POST("", h.CreateWarehouse)
 		whRoutes.GET("", h.ListWarehouses)
+		whRoutes.GET("/nearest", h.FindNearestWarehouses) // ?lat=&lng=&product_id=&quantity=
 		whRoutes.GET("/:id", h.GetWarehouse)
+		// Mengubah data atau menonaktifkan gudang hanya untuk admin
+		whRoutes.PATCH("/:id", auth.RequireAdmin(), h.UpdateWarehouse)
 		whRoutes.PUT("/:id/activate", h.ActivateWarehouse)
 		whRoutes.PUT("/:id/deactivate", h.DeactivateWarehouse)
+		whRoutes.POST("/:id/decommission", auth.RequireAdmin(), h.DecommissionWarehouse) // Soft delete, opsional memindahkan stok dulu
 
-		whRoutes.POST("/:id/stocks", h.AddStock)                       // Add stock to a specific warehouse
-		whRoutes.GET("/:id/stocks/:product_id", h.GetStockInWarehouse) // Get stock for a product in a specific warehouse
+		whRoutes.POST("/:id/stocks", idempotencyMiddleware, h.AddStock)                              // Add stock to a specific warehouse
+		whRoutes.GET("/:id/stocks/:product_id", h.GetStockInWarehouse)                               // Get stock for a product in a specific warehouse
+		whRoutes.GET("/:id/stocks/:product_id/movements", auth.RequireAdmin(), h.ListStockMovements) // ?type=&limit=&cursor=
+
+		// Penyesuaian stok manual hanya untuk admin; identitas admin dicatat sebagai pengaju/penyetuju
+		adjustmentRoutes := whRoutes.Group("/:id/adjustments", auth.RequireAdmin())
+		{
+			adjustmentRoutes.POST("", h.CreateStockAdjustment)
+			adjustmentRoutes.GET("", h.ListStockAdjustments) // ?status=
+			adjustmentRoutes.GET("/:adjustment_id", h.GetStockAdjustment)
+			adjustmentRoutes.POST("/:adjustment_id/approve", h.ApproveStockAdjustment)
+			adjustmentRoutes.POST("/:adjustment_id/reject", h.RejectStockAdjustment)
+		}
+
+		stockCountRoutes := whRoutes.Group("/:id/stock-counts", auth.RequireAdmin())
+		{
+			stockCountRoutes.POST("", h.OpenStockCount)
+			stockCountRoutes.GET("", h.ListStockCounts) // ?status=
+			stockCountRoutes.GET("/:count_id", h.GetStockCount)
+			stockCountRoutes.PUT("/:count_id/lines", h.RecordStockCounts) // Hasil hitung dari scanner, boleh diulang
+			stockCountRoutes.POST("/:count_id/close", h.CloseStockCount)
+			stockCountRoutes.POST("/:count_id/cancel", h.CancelStockCount)
+		}
+
+		thresholdRoutes := whRoutes.Group("/:id/thresholds", auth.RequireAdmin())
+		{
+			thresholdRoutes.GET("", h.ListStockThresholds)
+			thresholdRoutes.GET("/:product_id", h.GetStockThreshold)
+			thresholdRoutes.PUT("/:product_id", h.SetStockThreshold)
+			thresholdRoutes.DELETE("/:product_id", h.DeleteStockThreshold)
+		}
 	}
 
-	stockOpsRoutes := router.Group("/stocks") // Grup baru untuk operasi stok umum
+	stockOpsRoutes := router.Group("/stocks", authMiddleware) // Grup baru untuk operasi stok umum
 	{
-		stockOpsRoutes.POST("/reserve", h.ReserveStock)
-		stockOpsRoutes.POST("/release", h.ReleaseStock)
+		stockOpsRoutes.POST("/reserve", idempotencyMiddleware, h.ReserveStock)
+		stockOpsRoutes.POST("/reserve-batch", idempotencyMiddleware, h.ReserveStockBatch) // Reservasi seluruh keranjang, all-or-nothing
+		stockOpsRoutes.POST("/release", idempotencyMiddleware, h.ReleaseStock)
 		stockOpsRoutes.POST("/transfer", h.TransferStock)
-		stockOpsRoutes.POST("/deduct", h.DeductStock)
+		stockOpsRoutes.POST("/deduct", idempotencyMiddleware, h.DeductStock)
+		stockOpsRoutes.POST("/receive-return", idempotencyMiddleware, h.ReceiveReturn) // Barang retur pelanggan masuk kembali ke stok
+
+		// Reservasi per order hanya dikelola Order Service (token service) atau admin, bukan customer
+		reservationRoutes := stockOpsRoutes.Group("/reservations", auth.RequireAdminOrService())
+		{
+			reservationRoutes.GET("", h.ListReservations) // ?order_id=
+			reservationRoutes.GET("/:reservation_id", h.GetReservation)
+			reservationRoutes.POST("/:reservation_id/commit", h.CommitReservation)
+			reservationRoutes.POST("/:reservation_id/release", h.ReleaseReservation)
+		}
+	}
+
+	// Transfer dua fase antar gudang; pengiriman dan penerimaan dicatat oleh admin gudang masing-masing
+	transferOrderRoutes := router.Group("/transfer-orders", authMiddleware, auth.RequireAdmin())
+	{
+		transferOrderRoutes.POST("", idempotencyMiddleware, h.CreateTransferOrder)
+		transferOrderRoutes.GET("", h.ListTransferOrders) // ?warehouse_id=&product_id=&status=
+		transferOrderRoutes.GET("/:transfer_id", h.GetTransferOrder)
+		transferOrderRoutes.POST("/:transfer_id/pick", h.PickTransferOrder)
+		transferOrderRoutes.POST("/:transfer_id/dispatch", h.DispatchTransferOrder)
+		transferOrderRoutes.POST("/:transfer_id/receive", h.ReceiveTransferOrder)
+		transferOrderRoutes.POST("/:transfer_id/cancel", h.CancelTransferOrder)
 	}
 
-	stockInfoRoutes := router.Group("/stock-info")
+	// Alert stok rendah dibuat oleh evaluator di background; admin hanya menindaklanjuti
+	stockAlertRoutes := router.Group("/stock-alerts", authMiddleware, auth.RequireAdmin())
 	{
-		stockInfoRoutes.GET("/products/:product_id", h.GetAggregatedProductStock)
+		stockAlertRoutes.GET("", h.ListStockAlerts) // ?warehouse_id=&product_id=&status=
+		stockAlertRoutes.GET("/:alert_id", h.GetStockAlert)
+		stockAlertRoutes.POST("/:alert_id/acknowledge", h.AcknowledgeStockAlert)
+		stockAlertRoutes.POST("/:alert_id/resolve", h.ResolveStockAlert)
+	}
+
+	stockInfoRoutes := router.Group("/stock-info", authMiddleware)
+	{
+		stockInfoRoutes.GET("/products/:product_id", h.GetAggregatedProductStock) // ?exclude_safety_stock=true
 		stockInfoRoutes.POST("/reserved-locations", h.FindWarehousesWithReservations)
 	}
 
@@ -57,6 +130,10 @@ func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
 	}
 	wh, err := h.warehouseService.CreateWarehouse(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidWarehouse) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error("Hdl.CreateWarehouse: service error", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create warehouse"})
 		return
@@ -74,6 +151,41 @@ func (h *WarehouseHandler) ListWarehouses(c *gin.Context) {
 	c.JSON(http.StatusOK, warehouses)
 }
 
+func (h *WarehouseHandler) FindNearestWarehouses(c *gin.Context) {
+	query := domain.NearestWarehousesQuery{ProductID: c.Query("product_id"), Quantity: 1}
+	var err error
+	if query.Latitude, err = strconv.ParseFloat(c.Query("lat"), 64); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or missing 'lat' parameter"})
+		return
+	}
+	if query.Longitude, err = strconv.ParseFloat(c.Query("lng"), 64); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or missing 'lng' parameter"})
+		return
+	}
+	if quantityStr := c.Query("quantity"); quantityStr != "" {
+		if query.Quantity, err = strconv.Atoi(quantityStr); err != nil || query.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'quantity' parameter"})
+			return
+		}
+	}
+
+	warehouses, err := h.warehouseService.FindNearestWarehouses(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidNearestQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.FindNearestWarehouses: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find nearest warehouses"})
+		return
+	}
+	c.JSON(http.StatusOK, domain.NearestWarehousesResponse{
+		ProductID:  query.ProductID,
+		Quantity:   query.Quantity,
+		Warehouses: warehouses,
+	})
+}
+
 func (h *WarehouseHandler) GetWarehouse(c *gin.Context) {
 	id := c.Param("id")
 	wh, err := h.warehouseService.GetWarehouse(c.Request.Context(), id)
@@ -89,6 +201,30 @@ func (h *WarehouseHandler) GetWarehouse(c *gin.Context) {
 	c.JSON(http.StatusOK, wh)
 }
 
+func (h *WarehouseHandler) UpdateWarehouse(c *gin.Context) {
+	var req domain.UpdateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	wh, err := h.warehouseService.UpdateWarehouse(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWarehouseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidWarehouse):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrWarehouseDecommissioned):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.Error("Hdl.UpdateWarehouse: service error", err, nil)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update warehouse"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, wh)
+}
+
 func (h *WarehouseHandler) ActivateWarehouse(c *gin.Context) {
 	id := c.Param("id")
 	err := h.warehouseService.ActivateWarehouse(c.Request.Context(), id)
@@ -97,6 +233,10 @@ func (h *WarehouseHandler) ActivateWarehouse(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, service.ErrWarehouseDecommissioned) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error("Hdl.ActivateWarehouse: service error", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate warehouse"})
 		return
@@ -106,17 +246,46 @@ func (h *WarehouseHandler) ActivateWarehouse(c *gin.Context) {
 
 func (h *WarehouseHandler) DeactivateWarehouse(c *gin.Context) {
 	id := c.Param("id")
-	err := h.warehouseService.DeactivateWarehouse(c.Request.Context(), id)
+	resp, err := h.warehouseService.DeactivateWarehouse(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, repository.ErrWarehouseNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, service.ErrWarehouseDecommissioned) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error("Hdl.DeactivateWarehouse: service error", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate warehouse"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Warehouse deactivated"})
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WarehouseHandler) DecommissionWarehouse(c *gin.Context) {
+	var req domain.DecommissionWarehouseRequest
+	// Body opsional: tanpa body gudang di-decommission tanpa memindahkan stok.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.DecommissionWarehouse(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidDecommission):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrWarehouseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrWarehouseDecommissioned), errors.Is(err, service.ErrWarehouseHasReservations):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.Error("Hdl.DecommissionWarehouse: service error", err, nil)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decommission warehouse"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *WarehouseHandler) AddStock(c *gin.Context) {
@@ -153,9 +322,340 @@ func (h *WarehouseHandler) GetStockInWarehouse(c *gin.Context) {
 	c.JSON(http.StatusOK, stock)
 }
 
+func (h *WarehouseHandler) ListStockMovements(c *gin.Context) {
+	filter := domain.ListMovementsFilter{
+		WarehouseID: c.Param("id"),
+		ProductID:   c.Param("product_id"),
+		Type:        domain.MovementType(strings.ToUpper(c.Query("type"))),
+	}
+	var err error
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if filter.Limit, err = strconv.Atoi(limitStr); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'limit' parameter"})
+			return
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.BeforeID, err = domain.DecodeMovementCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	resp, err := h.warehouseService.ListStockMovements(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMovementQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrWarehouseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.ListStockMovements: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list stock movements"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WarehouseHandler) CreateStockAdjustment(c *gin.Context) {
+	var req domain.CreateStockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.CreateStockAdjustment(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		h.writeAdjustmentError(c, "Hdl.CreateStockAdjustment", err)
+		return
+	}
+	// 202 menandakan penyesuaian disimpan tetapi stok belum berubah sampai disetujui
+	if resp.Adjustment.Status == domain.AdjustmentStatusPendingApproval {
+		c.JSON(http.StatusAccepted, resp)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *WarehouseHandler) ListStockAdjustments(c *gin.Context) {
+	status := domain.AdjustmentStatus(strings.ToUpper(c.Query("status")))
+	adjustments, err := h.warehouseService.ListStockAdjustments(c.Request.Context(), c.Param("id"), status)
+	if err != nil {
+		h.writeAdjustmentError(c, "Hdl.ListStockAdjustments", err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustments)
+}
+
+func (h *WarehouseHandler) GetStockAdjustment(c *gin.Context) {
+	adjustment, err := h.warehouseService.GetStockAdjustment(c.Request.Context(), c.Param("id"), c.Param("adjustment_id"))
+	if err != nil {
+		h.writeAdjustmentError(c, "Hdl.GetStockAdjustment", err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustment)
+}
+
+func (h *WarehouseHandler) ApproveStockAdjustment(c *gin.Context) {
+	var req domain.ReviewStockAdjustmentRequest
+	// Body opsional: catatan review boleh kosong.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.ApproveStockAdjustment(c.Request.Context(), c.Param("id"), c.Param("adjustment_id"), req)
+	if err != nil {
+		h.writeAdjustmentError(c, "Hdl.ApproveStockAdjustment", err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WarehouseHandler) RejectStockAdjustment(c *gin.Context) {
+	var req domain.ReviewStockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.RejectStockAdjustment(c.Request.Context(), c.Param("id"), c.Param("adjustment_id"), req)
+	if err != nil {
+		h.writeAdjustmentError(c, "Hdl.RejectStockAdjustment", err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WarehouseHandler) writeAdjustmentError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAdjustment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAdjustmentSelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrStockAdjustmentNotFound),
+		errors.Is(err, repository.ErrProductStockNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrWarehouseDecommissioned),
+		errors.Is(err, service.ErrAdjustmentBelowReserved),
+		errors.Is(err, service.ErrAdjustmentNotPending),
+		errors.Is(err, repository.ErrStockAdjustmentReviewed),
+		errors.Is(err, repository.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(op+": service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during stock adjustment"})
+	}
+}
+
+func (h *WarehouseHandler) OpenStockCount(c *gin.Context) {
+	var req domain.OpenStockCountRequest
+	// Body opsional: tanpa body seluruh produk di gudang dihitung.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	count, err := h.warehouseService.OpenStockCount(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.OpenStockCount", err)
+		return
+	}
+	c.JSON(http.StatusCreated, count)
+}
+
+func (h *WarehouseHandler) ListStockCounts(c *gin.Context) {
+	status := domain.StockCountStatus(strings.ToUpper(c.Query("status")))
+	counts, err := h.warehouseService.ListStockCounts(c.Request.Context(), c.Param("id"), status)
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.ListStockCounts", err)
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+func (h *WarehouseHandler) GetStockCount(c *gin.Context) {
+	count, err := h.warehouseService.GetStockCount(c.Request.Context(), c.Param("id"), c.Param("count_id"))
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.GetStockCount", err)
+		return
+	}
+	c.JSON(http.StatusOK, count)
+}
+
+func (h *WarehouseHandler) RecordStockCounts(c *gin.Context) {
+	var req domain.RecordStockCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	count, err := h.warehouseService.RecordStockCounts(c.Request.Context(), c.Param("id"), c.Param("count_id"), req)
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.RecordStockCounts", err)
+		return
+	}
+	c.JSON(http.StatusOK, count)
+}
+
+func (h *WarehouseHandler) CloseStockCount(c *gin.Context) {
+	var req domain.CloseStockCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.CloseStockCount(c.Request.Context(), c.Param("id"), c.Param("count_id"), req)
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.CloseStockCount", err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WarehouseHandler) CancelStockCount(c *gin.Context) {
+	count, err := h.warehouseService.CancelStockCount(c.Request.Context(), c.Param("id"), c.Param("count_id"))
+	if err != nil {
+		h.writeStockCountError(c, "Hdl.CancelStockCount", err)
+		return
+	}
+	c.JSON(http.StatusOK, count)
+}
+
+func (h *WarehouseHandler) writeStockCountError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidStockCount),
+		errors.Is(err, repository.ErrStockCountLineNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrStockCountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrWarehouseDecommissioned),
+		errors.Is(err, repository.ErrStockCountConflict),
+		errors.Is(err, repository.ErrStockCountNotOpen),
+		errors.Is(err, service.ErrStockCountIncomplete),
+		errors.Is(err, service.ErrAdjustmentBelowReserved),
+		errors.Is(err, repository.ErrProductStockNotFound),
+		errors.Is(err, repository.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(op+": service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during stock count"})
+	}
+}
+
+func (h *WarehouseHandler) SetStockThreshold(c *gin.Context) {
+	var req domain.SetStockThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	threshold, err := h.warehouseService.SetStockThreshold(c.Request.Context(), c.Param("id"), c.Param("product_id"), req)
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.SetStockThreshold", err)
+		return
+	}
+	c.JSON(http.StatusOK, threshold)
+}
+
+func (h *WarehouseHandler) GetStockThreshold(c *gin.Context) {
+	threshold, err := h.warehouseService.GetStockThreshold(c.Request.Context(), c.Param("id"), c.Param("product_id"))
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.GetStockThreshold", err)
+		return
+	}
+	c.JSON(http.StatusOK, threshold)
+}
+
+func (h *WarehouseHandler) ListStockThresholds(c *gin.Context) {
+	thresholds, err := h.warehouseService.ListStockThresholds(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.ListStockThresholds", err)
+		return
+	}
+	c.JSON(http.StatusOK, thresholds)
+}
+
+func (h *WarehouseHandler) DeleteStockThreshold(c *gin.Context) {
+	if err := h.warehouseService.DeleteStockThreshold(c.Request.Context(), c.Param("id"), c.Param("product_id")); err != nil {
+		h.writeStockAlertError(c, "Hdl.DeleteStockThreshold", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WarehouseHandler) ListStockAlerts(c *gin.Context) {
+	filter := domain.ListStockAlertsFilter{
+		WarehouseID: c.Query("warehouse_id"),
+		ProductID:   c.Query("product_id"),
+		Status:      domain.StockAlertStatus(strings.ToUpper(c.Query("status"))),
+	}
+	alerts, err := h.warehouseService.ListStockAlerts(c.Request.Context(), filter)
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.ListStockAlerts", err)
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (h *WarehouseHandler) GetStockAlert(c *gin.Context) {
+	alert, err := h.warehouseService.GetStockAlert(c.Request.Context(), c.Param("alert_id"))
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.GetStockAlert", err)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+func (h *WarehouseHandler) AcknowledgeStockAlert(c *gin.Context) {
+	alert, err := h.warehouseService.AcknowledgeStockAlert(c.Request.Context(), c.Param("alert_id"))
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.AcknowledgeStockAlert", err)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+func (h *WarehouseHandler) ResolveStockAlert(c *gin.Context) {
+	var req domain.ResolveStockAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	alert, err := h.warehouseService.ResolveStockAlert(c.Request.Context(), c.Param("alert_id"), req)
+	if err != nil {
+		h.writeStockAlertError(c, "Hdl.ResolveStockAlert", err)
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+func (h *WarehouseHandler) writeStockAlertError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidStockThreshold),
+		errors.Is(err, service.ErrInvalidStockAlert):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrStockThresholdNotFound),
+		errors.Is(err, repository.ErrStockAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrWarehouseDecommissioned),
+		errors.Is(err, service.ErrStockAlertResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(op+": service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during stock alert operation"})
+	}
+}
+
 func (h *WarehouseHandler) GetAggregatedProductStock(c *gin.Context) {
 	productID := c.Param("product_id")
-	stockInfo, err := h.warehouseService.GetAggregatedProductStock(c.Request.Context(), productID)
+	excludeSafetyStock := false
+	if v := c.Query("exclude_safety_stock"); v != "" {
+		var err error
+		if excludeSafetyStock, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'exclude_safety_stock' parameter"})
+			return
+		}
+	}
+	stockInfo, err := h.warehouseService.GetAggregatedProductStock(c.Request.Context(), productID, excludeSafetyStock)
 	if err != nil {
 		logger.Error("Hdl.GetAggregatedProductStock: service error", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get aggregated stock"})
@@ -165,26 +665,60 @@ func (h *WarehouseHandler) GetAggregatedProductStock(c *gin.Context) {
 }
 
 func (h *WarehouseHandler) ReserveStock(c *gin.Context) {
-	var req domain.StockOperationRequest
+	var req domain.ReserveStockRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
 
-	err := h.warehouseService.ReserveStock(c.Request.Context(), req.ProductID, req.Quantity)
+	reservations, err := h.warehouseService.ReserveStock(c.Request.Context(), req)
 	if err != nil {
 		if errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrProductStockNotFound) {
 			c.JSON(http.StatusConflict, gin.H{"error": "Failed to reserve stock: " + err.Error()})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidAllocationRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error("Hdl.ReserveStock: service error", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during stock reservation"})
 		return
 	}
 
-	c.JSON(http.StatusOK, domain.StockOperationResponse{
-		Message:   "Stock reserved successfully",
-		ProductID: req.ProductID,
+	c.JSON(http.StatusOK, domain.ReserveStockResponse{
+		Message:      "Stock reserved successfully",
+		ProductID:    req.ProductID,
+		Reservations: reservations,
+	})
+}
+
+func (h *WarehouseHandler) ReserveStockBatch(c *gin.Context) {
+	var req domain.ReserveStockBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	lines, err := h.warehouseService.ReserveStockBatch(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrProductStockNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Failed to reserve stock: " + err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidAllocationRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.ReserveStockBatch: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during stock reservation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.ReserveStockBatchResponse{
+		Message: "Stock reserved successfully",
+		OrderID: req.OrderID,
+		Lines:   lines,
 	})
 }
 
@@ -236,6 +770,103 @@ func (h *WarehouseHandler) TransferStock(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Stock transferred successfully"})
 }
 
+func (h *WarehouseHandler) CreateTransferOrder(c *gin.Context) {
+	var req domain.CreateTransferOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	order, err := h.warehouseService.CreateTransferOrder(c.Request.Context(), req)
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.CreateTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusCreated, order)
+}
+
+func (h *WarehouseHandler) ListTransferOrders(c *gin.Context) {
+	filter := domain.ListTransferOrdersFilter{
+		WarehouseID: c.Query("warehouse_id"),
+		ProductID:   c.Query("product_id"),
+		Status:      domain.TransferOrderStatus(strings.ToUpper(c.Query("status"))),
+	}
+	orders, err := h.warehouseService.ListTransferOrders(c.Request.Context(), filter)
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.ListTransferOrders", err)
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *WarehouseHandler) GetTransferOrder(c *gin.Context) {
+	order, err := h.warehouseService.GetTransferOrder(c.Request.Context(), c.Param("transfer_id"))
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.GetTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *WarehouseHandler) PickTransferOrder(c *gin.Context) {
+	order, err := h.warehouseService.PickTransferOrder(c.Request.Context(), c.Param("transfer_id"))
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.PickTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *WarehouseHandler) DispatchTransferOrder(c *gin.Context) {
+	order, err := h.warehouseService.DispatchTransferOrder(c.Request.Context(), c.Param("transfer_id"))
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.DispatchTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *WarehouseHandler) ReceiveTransferOrder(c *gin.Context) {
+	var req domain.ReceiveTransferOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	order, err := h.warehouseService.ReceiveTransferOrder(c.Request.Context(), c.Param("transfer_id"), req)
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.ReceiveTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *WarehouseHandler) CancelTransferOrder(c *gin.Context) {
+	order, err := h.warehouseService.CancelTransferOrder(c.Request.Context(), c.Param("transfer_id"))
+	if err != nil {
+		h.writeTransferOrderError(c, "Hdl.CancelTransferOrder", err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *WarehouseHandler) writeTransferOrderError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTransferOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrTransferOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrWarehouseDecommissioned),
+		errors.Is(err, service.ErrTransferOrderState),
+		errors.Is(err, repository.ErrProductStockNotFound),
+		errors.Is(err, repository.ErrInsufficientStock),
+		errors.Is(err, repository.ErrStockConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(op+": service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during transfer order"})
+	}
+}
+
 func (h *WarehouseHandler) DeductStock(c *gin.Context) {
 	var req domain.DeductStockRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -276,3 +907,88 @@ func (h *WarehouseHandler) FindWarehousesWithReservations(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, infos)
 }
+
+func (h *WarehouseHandler) ListReservations(c *gin.Context) {
+	orderID := c.Query("order_id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order_id query parameter is required"})
+		return
+	}
+	reservations, err := h.warehouseService.ListReservationsByOrder(c.Request.Context(), orderID)
+	if err != nil {
+		logger.Error("Hdl.ListReservations: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reservations"})
+		return
+	}
+	c.JSON(http.StatusOK, reservations)
+}
+
+func (h *WarehouseHandler) GetReservation(c *gin.Context) {
+	reservation, err := h.warehouseService.GetReservation(c.Request.Context(), c.Param("reservation_id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrReservationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("Hdl.GetReservation: service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reservation"})
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+func (h *WarehouseHandler) CommitReservation(c *gin.Context) {
+	reservation, err := h.warehouseService.CommitReservation(c.Request.Context(), c.Param("reservation_id"))
+	if err != nil {
+		h.writeReservationError(c, "Hdl.CommitReservation", err)
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+func (h *WarehouseHandler) ReleaseReservation(c *gin.Context) {
+	reservation, err := h.warehouseService.ReleaseReservation(c.Request.Context(), c.Param("reservation_id"))
+	if err != nil {
+		h.writeReservationError(c, "Hdl.ReleaseReservation", err)
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+func (h *WarehouseHandler) writeReservationError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrReservationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrReservationNotActive),
+		errors.Is(err, repository.ErrInsufficientStock),
+		errors.Is(err, repository.ErrStockConflict),
+		errors.Is(err, repository.ErrProductStockNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(op+": service error", err, nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error during reservation update"})
+	}
+}
+
+func (h *WarehouseHandler) ReceiveReturn(c *gin.Context) {
+	var req domain.ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	resp, err := h.warehouseService.ReceiveReturn(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWarehouseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrWarehouseInactive), errors.Is(err, service.ErrReturnReferenceReused):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.Error("Hdl.ReceiveReturn: service error", err, nil)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error while receiving return"})
+		}
+		return
+	}
+	// Replay juga dijawab 200 (dengan replayed=true) agar pemanggil yang mengulang request tidak perlu membedakan
+	c.JSON(http.StatusOK, resp)
+}
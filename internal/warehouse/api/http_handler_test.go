@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter memasang rute warehouse dengan identitas dari header gateway, tanpa service di belakangnya:
+// rute yang ditolak middleware tidak pernah sampai ke handler.
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	noop := func(c *gin.Context) { c.Next() }
+	NewWarehouseHandler(nil).RegisterRoutes(router.Group("/api/v1"), auth.OptionalGinMiddleware([]byte("test-secret"), true), noop)
+	return router
+}
+
+func requestAs(router *gin.Engine, role, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if role != "" {
+		req.Header.Set(auth.HeaderUserID, "user-1")
+		req.Header.Set(auth.HeaderUserRole, role)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWarehouseRoutes_ReservationsRequireAdminOrService(t *testing.T) {
+	router := newTestRouter()
+	routes := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/api/v1/stocks/reservations?order_id=a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{http.MethodGet, "/api/v1/stocks/reservations/res-1"},
+		{http.MethodPost, "/api/v1/stocks/reservations/res-1/commit"},
+		{http.MethodPost, "/api/v1/stocks/reservations/res-1/release"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, requestAs(router, auth.RoleCustomer, route.method, route.path, "").Code)
+			assert.Equal(t, http.StatusForbidden, requestAs(router, "", route.method, route.path, "").Code)
+		})
+	}
+}
+
+func TestWarehouseRoutes_AdminOnly(t *testing.T) {
+	router := newTestRouter()
+	routes := []struct {
+		method, path, body string
+	}{
+		{http.MethodPatch, "/api/v1/warehouses/wh1", `{"name":"Renamed"}`},
+		{http.MethodPost, "/api/v1/warehouses/wh1/decommission", `{}`},
+		{http.MethodGet, "/api/v1/warehouses/wh1/stocks/prodA/movements", ""},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, requestAs(router, auth.RoleCustomer, route.method, route.path, route.body).Code)
+			assert.Equal(t, http.StatusForbidden, requestAs(router, "", route.method, route.path, route.body).Code)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+// AdjustmentReasonCode wajib diisi untuk setiap penyesuaian stok manual.
+type AdjustmentReasonCode string
+
+const (
+	AdjustmentReasonDamage          AdjustmentReasonCode = "DAMAGE"           // Barang rusak dihapus dari stok
+	AdjustmentReasonShrinkage       AdjustmentReasonCode = "SHRINKAGE"        // Barang hilang (pencurian, salah kirim, dll.)
+	AdjustmentReasonCountCorrection AdjustmentReasonCode = "COUNT_CORRECTION" // Koreksi hasil hitung fisik, boleh naik atau turun
+	AdjustmentReasonFound           AdjustmentReasonCode = "FOUND"            // Barang yang sebelumnya hilang ditemukan kembali
+)
+
+// AllowsDelta memeriksa arah penyesuaian terhadap reason code: DAMAGE dan SHRINKAGE hanya mengurangi,
+// FOUND hanya menambah, COUNT_CORRECTION boleh keduanya.
+func (c AdjustmentReasonCode) AllowsDelta(delta int) bool {
+	switch c {
+	case AdjustmentReasonDamage, AdjustmentReasonShrinkage:
+		return delta < 0
+	case AdjustmentReasonFound:
+		return delta > 0
+	case AdjustmentReasonCountCorrection:
+		return delta != 0
+	}
+	return false
+}
+
+type AdjustmentStatus string
+
+const (
+	AdjustmentStatusPendingApproval AdjustmentStatus = "PENDING_APPROVAL" // Menunggu persetujuan orang kedua, stok belum berubah
+	AdjustmentStatusApplied         AdjustmentStatus = "APPLIED"
+	AdjustmentStatusRejected        AdjustmentStatus = "REJECTED"
+)
+
+// StockAdjustment mencatat satu penyesuaian stok manual beserta alur persetujuannya.
+type StockAdjustment struct {
+	ID            string               `json:"id"`
+	WarehouseID   string               `json:"warehouse_id"`
+	ProductID     string               `json:"product_id"`
+	QuantityDelta int                  `json:"quantity_delta"`
+	ReasonCode    AdjustmentReasonCode `json:"reason_code"`
+	Note          string               `json:"note,omitempty"`
+	Status        AdjustmentStatus     `json:"status"`
+	RequestedBy   string               `json:"requested_by"`
+	ReviewedBy    string               `json:"reviewed_by,omitempty"`
+	ReviewNote    string               `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time           `json:"reviewed_at,omitempty"`
+	AppliedAt     *time.Time           `json:"applied_at,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// MovementReason adalah alasan yang dicatat di buku besar stok untuk penyesuaian ini.
+func (a *StockAdjustment) MovementReason() string {
+	if a.Note == "" {
+		return string(a.ReasonCode)
+	}
+	return string(a.ReasonCode) + ": " + a.Note
+}
+
+type CreateStockAdjustmentRequest struct {
+	ProductID     string               `json:"product_id" binding:"required"`
+	QuantityDelta int                  `json:"quantity_delta" binding:"required"` // Positif menambah, negatif mengurangi stok
+	ReasonCode    AdjustmentReasonCode `json:"reason_code" binding:"required,oneof=DAMAGE SHRINKAGE COUNT_CORRECTION FOUND"`
+	Note          string               `json:"note,omitempty" binding:"omitempty,max=500"`
+}
+
+type ReviewStockAdjustmentRequest struct {
+	Note string `json:"note,omitempty" binding:"omitempty,max=500"`
+}
+
+type StockAdjustmentResponse struct {
+	Adjustment *StockAdjustment `json:"adjustment"`
+	// Stock berisi stok setelah penyesuaian diterapkan; kosong jika penyesuaian masih menunggu persetujuan atau ditolak
+	Stock *ProductStock `json:"stock,omitempty"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "math"
+
+// earthRadiusKm adalah radius rata-rata bumi yang dipakai rumus haversine.
+const earthRadiusKm = 6371.0
+
+// DistanceKm menghitung jarak great-circle antara dua koordinat dengan rumus haversine.
+func DistanceKm(a, b GeoPoint) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLng := toRad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
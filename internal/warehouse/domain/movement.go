@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MovementType menjelaskan jenis perubahan product_stocks yang dicatat di stock_movements.
+type MovementType string
+
+const (
+	MovementReceipt     MovementType = "RECEIPT"      // Stok masuk lewat AddStock
+	MovementTransferOut MovementType = "TRANSFER_OUT" // Stok keluar ke gudang lain
+	MovementTransferIn  MovementType = "TRANSFER_IN"  // Stok masuk dari gudang lain
+	MovementReserve     MovementType = "RESERVE"      // reserved_quantity naik untuk reservasi
+	MovementRelease     MovementType = "RELEASE"      // reserved_quantity turun karena reservasi dilepas
+	MovementCommit      MovementType = "COMMIT"       // Stok terjual: quantity dan reserved_quantity turun bersama
+	MovementReturn      MovementType = "RETURN"       // Barang retur pelanggan masuk kembali
+	MovementAdjustment  MovementType = "ADJUSTMENT"   // Koreksi quantity di luar alur di atas
+)
+
+// Jenis referensi yang dicatat bersama pergerakan stok.
+const (
+	MovementRefReservation   = "reservation"    // ReferenceID = ID stock_reservations
+	MovementRefTransfer      = "transfer"       // Pasangan TRANSFER_OUT/TRANSFER_IN berbagi ReferenceID yang sama
+	MovementRefStockReturn   = "stock_return"   // ReferenceID = return_reference dari Order Service
+	MovementRefReceipt       = "receipt"        // ReferenceID = nomor dokumen penerimaan (misal purchase order)
+	MovementRefDecommission  = "decommission"   // ReferenceID = ID gudang yang di-decommission
+	MovementRefAdjustment    = "adjustment"     // ReferenceID = ID stock_adjustments
+	MovementRefTransferOrder = "transfer_order" // ReferenceID = ID transfer_orders (transfer dua fase)
+)
+
+// Actor untuk perubahan stok yang dipicu sistem (bukan request user).
+const (
+	ActorSystem                  = "system"
+	ActorSystemReservationExpiry = "system:reservation-expiry"
+)
+
+// MovementRef adalah konteks audit yang dibawa service ke setiap perubahan stok.
+type MovementRef struct {
+	ReferenceType string
+	ReferenceID   string
+	Actor         string // Kosong dicatat sebagai ActorSystem
+	Reason        string
+}
+
+// StockMovement adalah satu baris buku besar stok. Before/After adalah nilai baris product_stocks
+// sebelum dan sesudah perubahan, sehingga selisih antar baris bisa diaudit.
+type StockMovement struct {
+	ID             int64        `json:"id"`
+	WarehouseID    string       `json:"warehouse_id"`
+	ProductID      string       `json:"product_id"`
+	Type           MovementType `json:"type"`
+	QuantityDelta  int          `json:"quantity_delta"`
+	ReservedDelta  int          `json:"reserved_delta"`
+	QuantityBefore int          `json:"quantity_before"`
+	QuantityAfter  int          `json:"quantity_after"`
+	ReservedBefore int          `json:"reserved_before"`
+	ReservedAfter  int          `json:"reserved_after"`
+	ReferenceType  string       `json:"reference_type,omitempty"`
+	ReferenceID    string       `json:"reference_id,omitempty"`
+	Actor          string       `json:"actor"`
+	Reason         string       `json:"reason,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// NewStockMovement membangun movement dari nilai setelah perubahan (hasil RETURNING) dan selisihnya.
+func NewStockMovement(movementType MovementType, warehouseID, productID string, quantityDelta, reservedDelta, quantityAfter, reservedAfter int, ref MovementRef) StockMovement {
+	return StockMovement{
+		WarehouseID:    warehouseID,
+		ProductID:      productID,
+		Type:           movementType,
+		QuantityDelta:  quantityDelta,
+		ReservedDelta:  reservedDelta,
+		QuantityBefore: quantityAfter - quantityDelta,
+		QuantityAfter:  quantityAfter,
+		ReservedBefore: reservedAfter - reservedDelta,
+		ReservedAfter:  reservedAfter,
+		ReferenceType:  ref.ReferenceType,
+		ReferenceID:    ref.ReferenceID,
+		Actor:          ref.Actor,
+		Reason:         ref.Reason,
+	}
+}
+
+const (
+	DefaultMovementListLimit = 50
+	MaxMovementListLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// EncodeMovementCursor menghasilkan cursor opaque dari ID movement terakhir di halaman.
+func EncodeMovementCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func DecodeMovementCursor(encoded string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidCursor, raw)
+	}
+	return id, nil
+}
+
+// ListMovementsFilter memilih pergerakan satu produk di satu gudang, terbaru lebih dulu.
+type ListMovementsFilter struct {
+	WarehouseID string
+	ProductID   string
+	Type        MovementType // Kosong berarti semua jenis
+	BeforeID    int64        // Keyset: hanya movement dengan id < BeforeID; 0 berarti dari yang terbaru
+	Limit       int
+}
+
+type ListMovementsResponse struct {
+	Movements  []StockMovement `json:"movements"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// IsValid bernilai true untuk jenis movement yang dikenal.
+func (t MovementType) IsValid() bool {
+	switch t {
+	case MovementReceipt, MovementTransferOut, MovementTransferIn, MovementReserve, MovementRelease,
+		MovementCommit, MovementReturn, MovementAdjustment:
+		return true
+	}
+	return false
+}
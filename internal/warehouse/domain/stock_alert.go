@@ -0,0 +1,103 @@
+package domain
+
+import "time"
+
+// StockThreshold mengatur kapan stok satu produk di satu gudang perlu diisi ulang. Semua nilai dibandingkan
+// dengan stok tersedia (quantity - reserved_quantity).
+type StockThreshold struct {
+	WarehouseID  string `json:"warehouse_id"`
+	ProductID    string `json:"product_id"`
+	ReorderPoint int    `json:"reorder_point"` // Alert LOW saat stok tersedia <= nilai ini
+	// SafetyStock adalah buffer yang tidak dijual online; alert CRITICAL saat stok tersedia <= nilai ini
+	SafetyStock     int             `json:"safety_stock"`
+	ReorderQuantity int             `json:"reorder_quantity"` // Jumlah yang disarankan untuk dipesan ulang
+	LastAlertLevel  StockAlertLevel `json:"last_alert_level,omitempty"`
+	UpdatedBy       string          `json:"updated_by"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// LevelFor mengembalikan level alert untuk stok tersedia tersebut, atau "" jika masih di atas reorder point.
+func (t *StockThreshold) LevelFor(available int) StockAlertLevel {
+	switch {
+	case available <= t.SafetyStock:
+		return StockAlertLevelCritical
+	case available <= t.ReorderPoint:
+		return StockAlertLevelLow
+	}
+	return ""
+}
+
+type SetStockThresholdRequest struct {
+	ReorderPoint    *int `json:"reorder_point" binding:"required,gte=0"`
+	SafetyStock     int  `json:"safety_stock" binding:"gte=0"`
+	ReorderQuantity int  `json:"reorder_quantity" binding:"gte=0"`
+}
+
+// StockAlertLevel: CRITICAL berarti stok yang bisa dijual online (di atas safety stock) sudah habis.
+type StockAlertLevel string
+
+const (
+	StockAlertLevelLow      StockAlertLevel = "LOW"
+	StockAlertLevelCritical StockAlertLevel = "CRITICAL"
+)
+
+// Severity untuk membandingkan level; semakin besar semakin parah.
+func (l StockAlertLevel) Severity() int {
+	switch l {
+	case StockAlertLevelLow:
+		return 1
+	case StockAlertLevelCritical:
+		return 2
+	}
+	return 0
+}
+
+type StockAlertStatus string
+
+const (
+	StockAlertStatusOpen         StockAlertStatus = "OPEN"
+	StockAlertStatusAcknowledged StockAlertStatus = "ACKNOWLEDGED" // Sudah ditangani (misal purchase order dibuat), menunggu stok masuk
+	StockAlertStatusResolved     StockAlertStatus = "RESOLVED"
+)
+
+// StockAlertResolverSystem dicatat sebagai resolved_by untuk alert yang selesai otomatis.
+const StockAlertResolverSystem = "system:stock-alerts"
+
+// StockAlert dibuat oleh evaluator saat stok melewati ambang batas. Alert selesai otomatis begitu stok
+// tersedia kembali di atas reorder point, atau diselesaikan manual oleh admin.
+type StockAlert struct {
+	ID                string           `json:"id"`
+	WarehouseID       string           `json:"warehouse_id"`
+	ProductID         string           `json:"product_id"`
+	Level             StockAlertLevel  `json:"level"`
+	Status            StockAlertStatus `json:"status"`
+	AvailableQuantity int              `json:"available_quantity"` // Stok tersedia pada evaluasi terakhir
+	ReorderPoint      int              `json:"reorder_point"`
+	SafetyStock       int              `json:"safety_stock"`
+	ReorderQuantity   int              `json:"reorder_quantity"`
+	AcknowledgedBy    string           `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt    *time.Time       `json:"acknowledged_at,omitempty"`
+	ResolvedBy        string           `json:"resolved_by,omitempty"`
+	ResolvedAt        *time.Time       `json:"resolved_at,omitempty"`
+	ResolutionNote    string           `json:"resolution_note,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+type ResolveStockAlertRequest struct {
+	Note string `json:"note,omitempty" binding:"omitempty,max=500"`
+}
+
+// ListStockAlertsFilter: field kosong berarti tanpa filter.
+type ListStockAlertsFilter struct {
+	WarehouseID string
+	ProductID   string
+	Status      StockAlertStatus
+}
+
+// StockKey mengidentifikasi satu baris stok (produk di satu gudang).
+type StockKey struct {
+	WarehouseID string
+	ProductID   string
+}
@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+type StockCountStatus string
+
+const (
+	StockCountStatusOpen      StockCountStatus = "OPEN"
+	StockCountStatusClosed    StockCountStatus = "CLOSED"    // Selisih sudah diterapkan sebagai penyesuaian stok
+	StockCountStatusCancelled StockCountStatus = "CANCELLED" // Ditutup tanpa mengubah stok
+)
+
+// StockCount adalah satu sesi stocktake/cycle count di satu gudang. Expected quantity di setiap baris
+// adalah snapshot product_stocks.quantity saat sesi dibuka.
+type StockCount struct {
+	ID          string           `json:"id"`
+	WarehouseID string           `json:"warehouse_id"`
+	Status      StockCountStatus `json:"status"`
+	FullScope   bool             `json:"full_scope"` // true jika sesi menghitung semua produk di gudang
+	Note        string           `json:"note,omitempty"`
+	OpenedBy    string           `json:"opened_by"`
+	ClosedBy    string           `json:"closed_by,omitempty"`
+	ClosedAt    *time.Time       `json:"closed_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Lines       []StockCountLine `json:"lines,omitempty"`
+}
+
+type StockCountLine struct {
+	ProductID        string `json:"product_id"`
+	ExpectedQuantity int    `json:"expected_quantity"`
+	CountedQuantity  *int   `json:"counted_quantity,omitempty"` // Kosong jika produk belum dihitung
+	// Variance = counted - expected; kosong jika produk belum dihitung
+	Variance     *int       `json:"variance,omitempty"`
+	CountedBy    string     `json:"counted_by,omitempty"`
+	CountedAt    *time.Time `json:"counted_at,omitempty"`
+	AdjustmentID string     `json:"adjustment_id,omitempty"` // Penyesuaian yang dibuat saat sesi ditutup
+}
+
+// Record mencatat hasil hitung terbaru; hitungan ulang menggantikan hasil sebelumnya.
+func (l *StockCountLine) Record(counted int, countedBy string, countedAt time.Time) {
+	variance := counted - l.ExpectedQuantity
+	l.CountedQuantity = &counted
+	l.Variance = &variance
+	l.CountedBy = countedBy
+	l.CountedAt = &countedAt
+}
+
+type OpenStockCountRequest struct {
+	// Kosong berarti semua produk yang punya baris stok di gudang
+	ProductIDs []string `json:"product_ids,omitempty" binding:"omitempty,max=500,dive,required"`
+	Note       string   `json:"note,omitempty" binding:"omitempty,max=500"`
+}
+
+type StockCountEntry struct {
+	ProductID       string `json:"product_id" binding:"required"`
+	CountedQuantity *int   `json:"counted_quantity" binding:"required,gte=0"`
+}
+
+type RecordStockCountRequest struct {
+	Counts []StockCountEntry `json:"counts" binding:"required,min=1,max=500,dive"`
+}
+
+type CloseStockCountRequest struct {
+	// Jika true, produk yang belum dihitung dibiarkan tanpa penyesuaian; jika false, sesi hanya bisa ditutup
+	// setelah semua produk dihitung
+	AllowUncounted bool `json:"allow_uncounted"`
+}
+
+type CloseStockCountResponse struct {
+	Count             *StockCount       `json:"count"`
+	Adjustments       []StockAdjustment `json:"adjustments"`
+	UncountedProducts []string          `json:"uncounted_products,omitempty"`
+}
@@ -0,0 +1,67 @@
+package domain
+
+import "time"
+
+// TransferOrderStatus mengikuti alur REQUESTED -> PICKED -> IN_TRANSIT -> RECEIVED.
+// CANCELLED hanya bisa dicapai sebelum barang dikirim.
+type TransferOrderStatus string
+
+const (
+	TransferOrderStatusRequested TransferOrderStatus = "REQUESTED"  // Belum ada perubahan stok
+	TransferOrderStatusPicked    TransferOrderStatus = "PICKED"     // Unit direservasi di gudang asal agar tidak terjual
+	TransferOrderStatusInTransit TransferOrderStatus = "IN_TRANSIT" // Unit sudah keluar dari gudang asal
+	TransferOrderStatusReceived  TransferOrderStatus = "RECEIVED"   // Unit yang diterima masuk ke gudang tujuan
+	TransferOrderStatusCancelled TransferOrderStatus = "CANCELLED"
+)
+
+// IsValid bernilai true untuk status transfer order yang dikenal.
+func (s TransferOrderStatus) IsValid() bool {
+	switch s {
+	case TransferOrderStatusRequested, TransferOrderStatusPicked, TransferOrderStatusInTransit,
+		TransferOrderStatusReceived, TransferOrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+type TransferOrder struct {
+	ID                string              `json:"id"`
+	ProductID         string              `json:"product_id"`
+	SourceWarehouseID string              `json:"source_warehouse_id"`
+	TargetWarehouseID string              `json:"target_warehouse_id"`
+	Quantity          int                 `json:"quantity"`
+	Status            TransferOrderStatus `json:"status"`
+	ReceivedQuantity  *int                `json:"received_quantity,omitempty"`
+	// DiscrepancyQuantity adalah unit yang dikirim tetapi tidak diterima (hilang/rusak di jalan)
+	DiscrepancyQuantity int        `json:"discrepancy_quantity"`
+	DiscrepancyNote     string     `json:"discrepancy_note,omitempty"`
+	Reason              string     `json:"reason,omitempty"`
+	RequestedBy         string     `json:"requested_by"`
+	PickedAt            *time.Time `json:"picked_at,omitempty"`
+	DispatchedAt        *time.Time `json:"dispatched_at,omitempty"`
+	ReceivedAt          *time.Time `json:"received_at,omitempty"`
+	CancelledAt         *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type CreateTransferOrderRequest struct {
+	ProductID         string `json:"product_id" binding:"required"`
+	SourceWarehouseID string `json:"source_warehouse_id" binding:"required"`
+	TargetWarehouseID string `json:"target_warehouse_id" binding:"required"`
+	Quantity          int    `json:"quantity" binding:"required,gt=0"`
+	Reason            string `json:"reason,omitempty" binding:"omitempty,max=500"`
+}
+
+type ReceiveTransferOrderRequest struct {
+	// Boleh lebih kecil dari quantity yang dikirim (penerimaan sebagian); 0 berarti seluruh kiriman hilang
+	ReceivedQuantity *int   `json:"received_quantity" binding:"required,gte=0"`
+	DiscrepancyNote  string `json:"discrepancy_note,omitempty" binding:"omitempty,max=500"`
+}
+
+// ListTransferOrdersFilter: WarehouseID mencocokkan gudang asal maupun tujuan. Field kosong berarti tanpa filter.
+type ListTransferOrdersFilter struct {
+	WarehouseID string
+	ProductID   string
+	Status      TransferOrderStatus
+}
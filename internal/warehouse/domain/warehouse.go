@@ -5,17 +5,120 @@ import (
 )
 
 type Warehouse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name" binding:"required"`
-	Location  *string   `json:"location,omitempty"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string            `json:"id"`
+	Name      string            `json:"name" binding:"required"`
+	Location  *string           `json:"location,omitempty"` // Teks bebas dari versi lama; gunakan Address
+	Address   *Address          `json:"address,omitempty"`
+	IsActive  bool              `json:"is_active"`
+	Priority  int               `json:"priority"`            // Angka lebih kecil didahulukan oleh strategi alokasi priority
+	Latitude  *float64          `json:"latitude,omitempty"`  // Koordinat dipakai strategi alokasi nearest
+	Longitude *float64          `json:"longitude,omitempty"` // Diisi berpasangan dengan Latitude
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	DeletedAt *time.Time        `json:"deleted_at,omitempty"` // Diisi saat gudang di-decommission (soft delete)
+}
+
+// IsDecommissioned bernilai true untuk gudang yang sudah di-soft-delete.
+func (w Warehouse) IsDecommissioned() bool {
+	return w.DeletedAt != nil
+}
+
+// DefaultWarehousePriority dipakai jika gudang dibuat tanpa prioritas.
+const DefaultWarehousePriority = 100
+
+// Address adalah alamat terstruktur gudang. Country memakai kode ISO 3166-1 alpha-2 (misal "ID").
+type Address struct {
+	Street     string `json:"street" binding:"required,max=255"`
+	City       string `json:"city" binding:"required,max=100"`
+	Province   string `json:"province,omitempty" binding:"max=100"`
+	PostalCode string `json:"postal_code,omitempty" binding:"omitempty,alphanum,max=20"`
+	Country    string `json:"country" binding:"required,iso3166_1_alpha2"`
 }
 
 type CreateWarehouseRequest struct {
-	Name     string  `json:"name" binding:"required"`
-	Location *string `json:"location,omitempty"`
+	Name      string            `json:"name" binding:"required,max=255"`
+	Location  *string           `json:"location,omitempty" binding:"omitempty,max=255"`
+	Address   *Address          `json:"address,omitempty"`
+	Priority  *int              `json:"priority,omitempty" binding:"omitempty,gte=0"`
+	Latitude  *float64          `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64          `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	Metadata  map[string]string `json:"metadata,omitempty" binding:"omitempty,max=50,dive,keys,required,max=64,endkeys,max=255"`
+}
+
+// GeoPoint adalah koordinat dalam derajat desimal, misal alamat pengiriman customer.
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude" binding:"gte=-90,lte=90"`
+	Longitude float64 `json:"longitude" binding:"gte=-180,lte=180"`
+}
+
+// UpdateWarehouseRequest adalah body PATCH gudang. Field yang nil tidak diubah;
+// Metadata yang dikirim menggantikan seluruh metadata lama.
+type UpdateWarehouseRequest struct {
+	Name      *string           `json:"name,omitempty" binding:"omitempty,max=255"`
+	Location  *string           `json:"location,omitempty" binding:"omitempty,max=255"`
+	Address   *Address          `json:"address,omitempty"`
+	Priority  *int              `json:"priority,omitempty" binding:"omitempty,gte=0"`
+	Latitude  *float64          `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64          `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	Metadata  map[string]string `json:"metadata,omitempty" binding:"omitempty,max=50,dive,keys,required,max=64,endkeys,max=255"`
+}
+
+// DeactivateWarehouseResponse melaporkan dampak penonaktifan: stok yang masih direservasi di gudang
+// tetap harus di-commit atau dilepas lewat reservasinya masing-masing.
+type DeactivateWarehouseResponse struct {
+	Message          string `json:"message"`
+	WarehouseID      string `json:"warehouse_id"`
+	ReservedUnits    int    `json:"reserved_units"`
+	ReservedProducts int    `json:"reserved_products"`
+}
+
+// DecommissionWarehouseRequest: jika TargetWarehouseID diisi, seluruh stok gudang dipindahkan ke sana
+// sebelum gudang di-soft-delete.
+type DecommissionWarehouseRequest struct {
+	TargetWarehouseID string `json:"target_warehouse_id,omitempty" binding:"omitempty,uuid"`
+}
+
+// DrainedStock adalah satu produk yang dipindahkan saat decommission.
+type DrainedStock struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+type DecommissionWarehouseResponse struct {
+	Warehouse         Warehouse      `json:"warehouse"`
+	TargetWarehouseID string         `json:"target_warehouse_id,omitempty"`
+	Drained           []DrainedStock `json:"drained"`
+	RemainingUnits    int            `json:"remaining_units"` // Stok yang tertinggal karena tidak ada gudang tujuan
+}
+
+// NearestWarehousesQuery mencari gudang aktif terdekat dari suatu titik yang bisa memenuhi Quantity produk.
+type NearestWarehousesQuery struct {
+	Latitude  float64
+	Longitude float64
+	ProductID string
+	Quantity  int
+}
+
+// NearestWarehouse adalah gudang hasil pencarian beserta jaraknya dan stok produk yang tersedia.
+type NearestWarehouse struct {
+	Warehouse
+	DistanceKm float64 `json:"distance_km"`
+	Available  int     `json:"available"`
+}
+
+type NearestWarehousesResponse struct {
+	ProductID  string             `json:"product_id"`
+	Quantity   int                `json:"quantity"`
+	Warehouses []NearestWarehouse `json:"warehouses"`
+}
+
+// Coordinates mengembalikan koordinat gudang; ok bernilai false jika gudang belum punya koordinat.
+func (w Warehouse) Coordinates() (point GeoPoint, ok bool) {
+	if w.Latitude == nil || w.Longitude == nil {
+		return GeoPoint{}, false
+	}
+	return GeoPoint{Latitude: *w.Latitude, Longitude: *w.Longitude}, true
 }
 
 type ProductStock struct {
@@ -30,13 +133,20 @@ type ProductStock struct {
 
 type AddStockRequest struct {
 	ProductID string `json:"product_id" binding:"required"`
-	Quantity  int    `json:"quantity" binding:"required,gt=0"` // Must be greater than 0
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`                // Must be greater than 0
+	Reference string `json:"reference,omitempty" binding:"omitempty,max=255"` // Nomor dokumen penerimaan, misal purchase order
+	Reason    string `json:"reason,omitempty" binding:"omitempty,max=500"`
 }
 
 // Digunakan untuk Product Service mengambil info stok
 type ProductStockInfo struct {
 	ProductID      string `json:"product_id"`
 	TotalAvailable int    `json:"total_available"`
+	// InTransit adalah unit yang sudah dikirim antar gudang tetapi belum diterima; tidak termasuk TotalAvailable
+	InTransit            int            `json:"in_transit"`
+	InTransitByWarehouse map[string]int `json:"in_transit_by_warehouse,omitempty"` // Per gudang tujuan
+	// SafetyStockExcluded adalah unit safety stock yang tidak dihitung di TotalAvailable (hanya jika diminta)
+	SafetyStockExcluded int `json:"safety_stock_excluded,omitempty"`
 }
 
 // Untuk update stok internal (reservasi, dll.)
@@ -64,6 +174,7 @@ type TransferStockRequest struct {
 	SourceWarehouseID string `json:"source_warehouse_id" binding:"required"`
 	TargetWarehouseID string `json:"target_warehouse_id" binding:"required"`
 	Quantity          int    `json:"quantity" binding:"required,gt=0"`
+	Reason            string `json:"reason,omitempty" binding:"omitempty,max=500"`
 }
 
 type DeductStockRequest struct {
@@ -82,3 +193,111 @@ type ProductWarehouseReservationInfo struct {
 type FindWarehousesWithReservationsRequest struct {
 	ProductIDs []string `json:"product_ids" binding:"required,dive,uuid"`
 }
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "ACTIVE"    // Stok masih ditahan (reserved_quantity)
+	ReservationStatusCommitted ReservationStatus = "COMMITTED" // Stok sudah dikurangi setelah pembayaran
+	ReservationStatusReleased  ReservationStatus = "RELEASED"  // Reservasi dilepas (timeout, batal, atau kedaluwarsa)
+)
+
+// DefaultReservationTTL dipakai jika pemanggil tidak menentukan masa berlaku reservasi.
+const DefaultReservationTTL = 1 * time.Hour
+
+// StockReservation adalah alokasi stok untuk satu order pada satu gudang.
+// Satu baris order bisa menghasilkan beberapa reservasi jika stok diambil dari beberapa gudang.
+type StockReservation struct {
+	ID          string            `json:"id"`
+	OrderID     *string           `json:"order_id,omitempty"`
+	WarehouseID string            `json:"warehouse_id"`
+	ProductID   string            `json:"product_id"`
+	Quantity    int               `json:"quantity"`
+	Status      ReservationStatus `json:"status"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// AllocationStrategyName memilih cara stok dibagi ke gudang saat reservasi.
+type AllocationStrategyName string
+
+const (
+	AllocationPriority          AllocationStrategyName = "priority"           // Gudang berurutan sesuai priority
+	AllocationNearest           AllocationStrategyName = "nearest"            // Gudang terdekat ke ship_to lebih dulu
+	AllocationSingleWarehouse   AllocationStrategyName = "single_warehouse"   // Gudang sesedikit mungkin per reservasi
+	AllocationBalancedDepletion AllocationStrategyName = "balanced_depletion" // Gudang dengan stok terbanyak lebih dulu
+)
+
+// AllocationOptions memilih strategi alokasi per request. Kosong berarti strategi default service.
+type AllocationOptions struct {
+	AllocationStrategy AllocationStrategyName `json:"allocation_strategy,omitempty" binding:"omitempty,oneof=priority nearest single_warehouse balanced_depletion"`
+	ShipTo             *GeoPoint              `json:"ship_to,omitempty"` // Wajib untuk strategi nearest
+}
+
+type ReserveStockRequest struct {
+	ProductID  string `json:"product_id" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,gt=0"`
+	OrderID    string `json:"order_id,omitempty" binding:"omitempty,uuid"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" binding:"omitempty,gt=0"` // Default DefaultReservationTTL
+	AllocationOptions
+}
+
+type ReserveStockResponse struct {
+	Message      string             `json:"message"`
+	ProductID    string             `json:"product_id"`
+	Reservations []StockReservation `json:"reservations"`
+}
+
+type ReserveStockBatchItem struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
+
+// ReserveStockBatchRequest mereservasi seluruh isi keranjang sekaligus: semua baris berhasil atau tidak ada sama sekali.
+type ReserveStockBatchRequest struct {
+	OrderID    string                  `json:"order_id,omitempty" binding:"omitempty,uuid"`
+	TTLSeconds int                     `json:"ttl_seconds,omitempty" binding:"omitempty,gt=0"` // Default DefaultReservationTTL
+	Items      []ReserveStockBatchItem `json:"items" binding:"required,min=1,dive"`
+	AllocationOptions
+}
+
+// ReservationLine adalah hasil reservasi satu baris request beserta alokasinya per gudang.
+type ReservationLine struct {
+	ProductID    string             `json:"product_id"`
+	Quantity     int                `json:"quantity"`
+	Reservations []StockReservation `json:"reservations"`
+}
+
+type ReserveStockBatchResponse struct {
+	Message string            `json:"message"`
+	OrderID string            `json:"order_id,omitempty"`
+	Lines   []ReservationLine `json:"lines"`
+}
+
+// ReceiveReturnRequest menerima kembali barang retur pelanggan ke gudang tertentu.
+// ReturnReference harus unik per penerimaan (Order Service memakai ID return item),
+// sehingga retry dengan referensi yang sama tidak menambah stok dua kali.
+type ReceiveReturnRequest struct {
+	ReturnReference string `json:"return_reference" binding:"required"`
+	WarehouseID     string `json:"warehouse_id" binding:"required,uuid"`
+	ProductID       string `json:"product_id" binding:"required,uuid"`
+	Quantity        int    `json:"quantity" binding:"required,gt=0"`
+}
+
+// StockReturn mencatat barang retur yang sudah masuk kembali ke product_stocks.quantity.
+type StockReturn struct {
+	ID              string    `json:"id"`
+	ReturnReference string    `json:"return_reference"`
+	WarehouseID     string    `json:"warehouse_id"`
+	ProductID       string    `json:"product_id"`
+	Quantity        int       `json:"quantity"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ReceiveReturnResponse struct {
+	Return *StockReturn  `json:"return"`
+	Stock  *ProductStock `json:"stock,omitempty"`
+	// Replayed bernilai true jika referensi retur sudah pernah diterima sebelumnya (stok tidak diubah lagi)
+	Replayed bool `json:"replayed"`
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 	// Diperlukan jika DBTX adalah sql.DB atau sql.Tx
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain" // Untuk DBTX
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
@@ -38,8 +39,16 @@ func (m *MockWarehouseRepository) UpdateWarehouseStatus(ctx context.Context, id
 	args := m.Called(ctx, id, isActive)
 	return args.Error(0)
 }
-func (m *MockWarehouseRepository) CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock) error {
-	args := m.Called(ctx, stock)
+func (m *MockWarehouseRepository) UpdateWarehouse(ctx context.Context, warehouse *domain.Warehouse) error {
+	args := m.Called(ctx, warehouse)
+	return args.Error(0)
+}
+func (m *MockWarehouseRepository) SoftDeleteWarehouse(ctx context.Context, id string) (time.Time, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+func (m *MockWarehouseRepository) CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock, ref domain.MovementRef) error {
+	args := m.Called(ctx, stock, ref)
 	if stock != nil && args.Error(0) == nil {
 		stock.ID = "mock-stock-id"
 	}
@@ -56,8 +65,8 @@ func (m *MockWarehouseRepository) GetTotalAvailableStockByProductID(ctx context.
 	args := m.Called(ctx, productID)
 	return args.Int(0), args.Error(1)
 }
-func (m *MockWarehouseRepository) TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int) error {
-	args := m.Called(ctx, productID, sourceWarehouseID, targetWarehouseID, quantity)
+func (m *MockWarehouseRepository) TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int, ref domain.MovementRef) error {
+	args := m.Called(ctx, productID, sourceWarehouseID, targetWarehouseID, quantity, ref)
 	return args.Error(0)
 }
 func (m *MockWarehouseRepository) BeginTx(ctx context.Context) (whRepo.DBTX, error) {
@@ -67,6 +76,21 @@ func (m *MockWarehouseRepository) BeginTx(ctx context.Context) (whRepo.DBTX, err
 	}
 	return nil, args.Error(1)
 }
+func (m *MockWarehouseRepository) ListProductStocks(ctx context.Context, productID string) ([]domain.ProductStock, error) {
+	args := m.Called(ctx, productID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.ProductStock), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *MockWarehouseRepository) ListWarehouseStocks(ctx context.Context, warehouseID string) ([]domain.ProductStock, error) {
+	args := m.Called(ctx, warehouseID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.ProductStock), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockWarehouseRepository) GetProductStockForUpdate(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string) (*domain.ProductStock, error) {
 	args := m.Called(ctx, dbops, warehouseID, productID)
 	if ps := args.Get(0); ps != nil {
@@ -74,16 +98,16 @@ func (m *MockWarehouseRepository) GetProductStockForUpdate(ctx context.Context,
 	}
 	return nil, args.Error(1)
 }
-func (m *MockWarehouseRepository) IncreaseReservedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, amount int) error {
-	args := m.Called(ctx, dbops, warehouseID, productID, amount)
+func (m *MockWarehouseRepository) IncreaseReservedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, amount, ref)
 	return args.Error(0)
 }
-func (m *MockWarehouseRepository) DecreaseReservedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, amount int) error {
-	args := m.Called(ctx, dbops, warehouseID, productID, amount)
+func (m *MockWarehouseRepository) DecreaseReservedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, amount, ref)
 	return args.Error(0)
 }
-func (m *MockWarehouseRepository) DeductCommittedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, quantityToDeduct int) error {
-	args := m.Called(ctx, dbops, warehouseID, productID, quantityToDeduct)
+func (m *MockWarehouseRepository) DeductCommittedStock(ctx context.Context, dbops whRepo.DBTX, warehouseID, productID string, quantityToDeduct int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, quantityToDeduct, ref)
 	return args.Error(0)
 }
 func (m *MockWarehouseRepository) FindWarehousesWithActiveReservations(ctx context.Context, productIDs []string) ([]domain.ProductWarehouseReservationInfo, error) {
@@ -95,12 +119,314 @@ func (m *MockWarehouseRepository) FindWarehousesWithActiveReservations(ctx conte
 }
 
 // Method yang hilang
-func (m *MockWarehouseRepository) DecreaseProductStockQuantity(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, amount int) error {
-	args := m.Called(ctx, dbops, warehouseID, productID, amount)
+func (m *MockWarehouseRepository) DecreaseProductStockQuantity(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, amount, ref)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) IncreaseProductStockQuantity(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, amount, ref)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) CreateReservation(ctx context.Context, dbops repository.DBTX, reservation *domain.StockReservation) error {
+	args := m.Called(ctx, dbops, reservation)
+	if reservation != nil && args.Error(0) == nil && reservation.ID == "" {
+		reservation.ID = "mock-res-" + reservation.WarehouseID
+	}
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetReservationForUpdate(ctx context.Context, dbops repository.DBTX, reservationID string) (*domain.StockReservation, error) {
+	args := m.Called(ctx, dbops, reservationID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateReservationStatus(ctx context.Context, dbops repository.DBTX, reservationID string, status domain.ReservationStatus) error {
+	args := m.Called(ctx, dbops, reservationID, status)
 	return args.Error(0)
 }
 
-func (m *MockWarehouseRepository) IncreaseProductStockQuantity(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, amount int) error {
-	args := m.Called(ctx, dbops, warehouseID, productID, amount)
+func (m *MockWarehouseRepository) GetReservationByID(ctx context.Context, reservationID string) (*domain.StockReservation, error) {
+	args := m.Called(ctx, reservationID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) ListReservationsByOrderID(ctx context.Context, orderID string) ([]domain.StockReservation, error) {
+	args := m.Called(ctx, orderID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]domain.StockReservation, error) {
+	args := m.Called(ctx, now, limit)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockReservation), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) CreateStockReturn(ctx context.Context, dbops repository.DBTX, stockReturn *domain.StockReturn) error {
+	args := m.Called(ctx, dbops, stockReturn)
 	return args.Error(0)
 }
+
+func (m *MockWarehouseRepository) GetStockReturnByReference(ctx context.Context, reference string) (*domain.StockReturn, error) {
+	args := m.Called(ctx, reference)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockReturn), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) AddReturnedStock(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error) {
+	args := m.Called(ctx, dbops, warehouseID, productID, quantity, ref)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.ProductStock), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) ListStockMovements(ctx context.Context, filter domain.ListMovementsFilter) ([]domain.StockMovement, error) {
+	args := m.Called(ctx, filter)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockMovement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) CreateStockAdjustment(ctx context.Context, dbops repository.DBTX, adjustment *domain.StockAdjustment) error {
+	args := m.Called(ctx, dbops, adjustment)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetStockAdjustmentByID(ctx context.Context, id string) (*domain.StockAdjustment, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockAdjustment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetStockAdjustmentForUpdate(ctx context.Context, dbops repository.DBTX, id string) (*domain.StockAdjustment, error) {
+	args := m.Called(ctx, dbops, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockAdjustment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateStockAdjustmentReview(ctx context.Context, dbops repository.DBTX, adjustment *domain.StockAdjustment) error {
+	args := m.Called(ctx, dbops, adjustment)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ListStockAdjustments(ctx context.Context, warehouseID string, status domain.AdjustmentStatus) ([]domain.StockAdjustment, error) {
+	args := m.Called(ctx, warehouseID, status)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockAdjustment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) CreateStockCount(ctx context.Context, dbops repository.DBTX, count *domain.StockCount, productIDs []string) error {
+	args := m.Called(ctx, dbops, count, productIDs)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetStockCountByID(ctx context.Context, id string) (*domain.StockCount, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockCount), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetStockCountForUpdate(ctx context.Context, dbops repository.DBTX, id string) (*domain.StockCount, error) {
+	args := m.Called(ctx, dbops, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockCount), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateStockCountLine(ctx context.Context, dbops repository.DBTX, countID string, line *domain.StockCountLine) error {
+	args := m.Called(ctx, dbops, countID, line)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) UpdateStockCountStatus(ctx context.Context, dbops repository.DBTX, count *domain.StockCount) error {
+	args := m.Called(ctx, dbops, count)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ListStockCounts(ctx context.Context, warehouseID string, status domain.StockCountStatus) ([]domain.StockCount, error) {
+	args := m.Called(ctx, warehouseID, status)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockCount), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) CreateTransferOrder(ctx context.Context, order *domain.TransferOrder) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetTransferOrderByID(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.TransferOrder), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetTransferOrderForUpdate(ctx context.Context, dbops repository.DBTX, id string) (*domain.TransferOrder, error) {
+	args := m.Called(ctx, dbops, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.TransferOrder), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateTransferOrder(ctx context.Context, dbops repository.DBTX, order *domain.TransferOrder) error {
+	args := m.Called(ctx, dbops, order)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ListTransferOrders(ctx context.Context, filter domain.ListTransferOrdersFilter) ([]domain.TransferOrder, error) {
+	args := m.Called(ctx, filter)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.TransferOrder), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) DispatchTransferStock(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, quantity, ref)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ReceiveTransferStock(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error) {
+	args := m.Called(ctx, dbops, warehouseID, productID, quantity, ref)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.ProductStock), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetInTransitQuantities(ctx context.Context, productID string) (map[string]int, error) {
+	args := m.Called(ctx, productID)
+	if res := args.Get(0); res != nil {
+		return res.(map[string]int), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpsertStockThreshold(ctx context.Context, threshold *domain.StockThreshold) error {
+	args := m.Called(ctx, threshold)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetStockThreshold(ctx context.Context, warehouseID, productID string) (*domain.StockThreshold, error) {
+	args := m.Called(ctx, warehouseID, productID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockThreshold), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetStockThresholdForUpdate(ctx context.Context, dbops repository.DBTX, warehouseID, productID string) (*domain.StockThreshold, error) {
+	args := m.Called(ctx, dbops, warehouseID, productID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockThreshold), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateStockThresholdAlertLevel(ctx context.Context, dbops repository.DBTX, warehouseID, productID string, level domain.StockAlertLevel) error {
+	args := m.Called(ctx, dbops, warehouseID, productID, level)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) DeleteStockThreshold(ctx context.Context, dbops repository.DBTX, warehouseID, productID string) error {
+	args := m.Called(ctx, dbops, warehouseID, productID)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ListStockThresholds(ctx context.Context, warehouseID string) ([]domain.StockThreshold, error) {
+	args := m.Called(ctx, warehouseID)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockThreshold), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetTotalSellableStockByProductID(ctx context.Context, productID string) (int, error) {
+	args := m.Called(ctx, productID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetLatestStockMovementID(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWarehouseRepository) ListStockKeysChangedSince(ctx context.Context, afterMovementID int64, limit int) ([]domain.StockKey, int64, error) {
+	args := m.Called(ctx, afterMovementID, limit)
+	var keys []domain.StockKey
+	if res := args.Get(0); res != nil {
+		keys = res.([]domain.StockKey)
+	}
+	return keys, args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockWarehouseRepository) CreateStockAlert(ctx context.Context, dbops repository.DBTX, alert *domain.StockAlert) error {
+	args := m.Called(ctx, dbops, alert)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetStockAlertByID(ctx context.Context, id string) (*domain.StockAlert, error) {
+	args := m.Called(ctx, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetStockAlertForUpdate(ctx context.Context, dbops repository.DBTX, id string) (*domain.StockAlert, error) {
+	args := m.Called(ctx, dbops, id)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) GetUnresolvedStockAlert(ctx context.Context, dbops repository.DBTX, warehouseID, productID string) (*domain.StockAlert, error) {
+	args := m.Called(ctx, dbops, warehouseID, productID)
+	if res := args.Get(0); res != nil {
+		return res.(*domain.StockAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWarehouseRepository) UpdateStockAlert(ctx context.Context, dbops repository.DBTX, alert *domain.StockAlert) error {
+	args := m.Called(ctx, dbops, alert)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) ListStockAlerts(ctx context.Context, filter domain.ListStockAlertsFilter) ([]domain.StockAlert, error) {
+	args := m.Called(ctx, filter)
+	if res := args.Get(0); res != nil {
+		return res.([]domain.StockAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
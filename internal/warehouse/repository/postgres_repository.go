@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq" // Untuk pq.Error
@@ -13,11 +15,24 @@ import (
 )
 
 var (
-	ErrWarehouseNotFound      = errors.New("warehouse not found")
-	ErrProductStockNotFound   = errors.New("product stock entry not found for this warehouse and product")
-	ErrInsufficientStock      = errors.New("insufficient stock")
-	ErrStockConflict          = errors.New("stock entry conflict, possibly unique constraint violation")
-	ErrUpdateStockOutOfBounds = errors.New("update results in negative quantity or reserved quantity")
+	ErrWarehouseNotFound       = errors.New("warehouse not found")
+	ErrProductStockNotFound    = errors.New("product stock entry not found for this warehouse and product")
+	ErrInsufficientStock       = errors.New("insufficient stock")
+	ErrStockConflict           = errors.New("stock entry conflict, possibly unique constraint violation")
+	ErrUpdateStockOutOfBounds  = errors.New("update results in negative quantity or reserved quantity")
+	ErrReservationNotFound     = errors.New("stock reservation not found")
+	ErrStockReturnExists       = errors.New("stock return with this reference already received")
+	ErrStockReturnNotFound     = errors.New("stock return not found")
+	ErrWarehouseHasReserved    = errors.New("warehouse still holds reserved stock")
+	ErrStockAdjustmentNotFound = errors.New("stock adjustment not found")
+	ErrStockAdjustmentReviewed = errors.New("stock adjustment has already been reviewed")
+	ErrStockCountNotFound      = errors.New("stock count not found")
+	ErrStockCountConflict      = errors.New("another open stock count already covers this warehouse or product")
+	ErrStockCountNotOpen       = errors.New("stock count is not open")
+	ErrStockCountLineNotFound  = errors.New("product is not part of this stock count")
+	ErrTransferOrderNotFound   = errors.New("transfer order not found")
+	ErrStockThresholdNotFound  = errors.New("stock threshold not found")
+	ErrStockAlertNotFound      = errors.New("stock alert not found")
 )
 
 type WarehouseRepository interface {
@@ -25,25 +40,114 @@ type WarehouseRepository interface {
 	GetWarehouseByID(ctx context.Context, id string) (*domain.Warehouse, error)
 	ListWarehouses(ctx context.Context) ([]domain.Warehouse, error)
 	UpdateWarehouseStatus(ctx context.Context, id string, isActive bool) error
+	// UpdateWarehouse menyimpan atribut gudang yang bisa diedit (tidak termasuk status aktif).
+	UpdateWarehouse(ctx context.Context, warehouse *domain.Warehouse) error
+	// SoftDeleteWarehouse menonaktifkan dan menandai gudang sebagai decommissioned.
+	// Gagal dengan ErrWarehouseHasReserved jika masih ada stok yang direservasi di gudang itu.
+	SoftDeleteWarehouse(ctx context.Context, id string) (time.Time, error)
 
 	// Stock Management
-	CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock) error
+	CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock, ref domain.MovementRef) error
 	GetProductStock(ctx context.Context, warehouseID, productID string) (*domain.ProductStock, error)
+	// ListProductStocks mengembalikan baris stok satu produk di semua gudang (tanpa mengunci).
+	ListProductStocks(ctx context.Context, productID string) ([]domain.ProductStock, error)
+	// ListWarehouseStocks mengembalikan seluruh baris stok di satu gudang (tanpa mengunci).
+	ListWarehouseStocks(ctx context.Context, warehouseID string) ([]domain.ProductStock, error)
 	GetTotalAvailableStockByProductID(ctx context.Context, productID string) (int, error)
-	TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int) error
+	// TransferStock memindahkan stok antar gudang. Jika ref tidak membawa ReferenceID, transfer diberi ID baru
+	// yang dipakai bersama oleh movement TRANSFER_OUT dan TRANSFER_IN.
+	TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int, ref domain.MovementRef) error
 
 	// Internal methods for more complex stock operations (typically within a transaction)
-	// These may need to be called by the service layer with db tx object
-	IncreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error
-	DecreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error // For actual sale deduction
-	IncreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error
-	DecreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error // For releasing reservation
+	// These may need to be called by the service layer with db tx object.
+	// Setiap perubahan stok mencatat stock_movements di dbops yang sama, dengan konteks audit dari ref.
+	IncreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error
+	DecreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error // For actual sale deduction
+	IncreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error
+	DecreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error // For releasing reservation
 	GetProductStockForUpdate(ctx context.Context, dbops DBTX, warehouseID, productID string) (*domain.ProductStock, error)
-	DeductCommittedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantityToDeduct int) error
+	DeductCommittedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantityToDeduct int, ref domain.MovementRef) error
 
 	BeginTx(ctx context.Context) (DBTX, error)
 
 	FindWarehousesWithActiveReservations(ctx context.Context, productIDs []string) ([]domain.ProductWarehouseReservationInfo, error)
+
+	// Reservation records
+	CreateReservation(ctx context.Context, dbops DBTX, reservation *domain.StockReservation) error
+	GetReservationForUpdate(ctx context.Context, dbops DBTX, reservationID string) (*domain.StockReservation, error)
+	UpdateReservationStatus(ctx context.Context, dbops DBTX, reservationID string, status domain.ReservationStatus) error
+	GetReservationByID(ctx context.Context, reservationID string) (*domain.StockReservation, error)
+	ListReservationsByOrderID(ctx context.Context, orderID string) ([]domain.StockReservation, error)
+	ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]domain.StockReservation, error)
+
+	// Stock returns (barang retur pelanggan)
+	CreateStockReturn(ctx context.Context, dbops DBTX, stockReturn *domain.StockReturn) error
+	GetStockReturnByReference(ctx context.Context, reference string) (*domain.StockReturn, error)
+	AddReturnedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error)
+
+	// Buku besar pergerakan stok (append-only)
+	ListStockMovements(ctx context.Context, filter domain.ListMovementsFilter) ([]domain.StockMovement, error)
+
+	// Penyesuaian stok manual. Perubahan stoknya sendiri dilakukan lewat Increase/DecreaseProductStockQuantity.
+	CreateStockAdjustment(ctx context.Context, dbops DBTX, adjustment *domain.StockAdjustment) error
+	GetStockAdjustmentByID(ctx context.Context, id string) (*domain.StockAdjustment, error)
+	GetStockAdjustmentForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockAdjustment, error)
+	// UpdateStockAdjustmentReview menyimpan hasil review penyesuaian yang masih PENDING_APPROVAL.
+	// Gagal dengan ErrStockAdjustmentReviewed jika penyesuaian sudah pernah di-review.
+	UpdateStockAdjustmentReview(ctx context.Context, dbops DBTX, adjustment *domain.StockAdjustment) error
+	// ListStockAdjustments mengembalikan penyesuaian di satu gudang, terbaru lebih dulu. Status kosong berarti semua status.
+	ListStockAdjustments(ctx context.Context, warehouseID string, status domain.AdjustmentStatus) ([]domain.StockAdjustment, error)
+
+	// Stocktake / cycle count
+	// CreateStockCount membuat sesi dan baris-barisnya dengan snapshot quantity saat ini. productIDs kosong berarti
+	// semua produk di gudang. Gagal dengan ErrStockCountConflict jika produk sudah dihitung sesi lain yang masih terbuka.
+	CreateStockCount(ctx context.Context, dbops DBTX, count *domain.StockCount, productIDs []string) error
+	GetStockCountByID(ctx context.Context, id string) (*domain.StockCount, error)
+	// GetStockCountForUpdate mengunci sesi (beserta barisnya) sampai transaksi selesai.
+	GetStockCountForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockCount, error)
+	UpdateStockCountLine(ctx context.Context, dbops DBTX, countID string, line *domain.StockCountLine) error
+	// UpdateStockCountStatus menutup atau membatalkan sesi yang masih OPEN dan melepas cakupan produknya.
+	UpdateStockCountStatus(ctx context.Context, dbops DBTX, count *domain.StockCount) error
+	// ListStockCounts mengembalikan sesi di satu gudang tanpa barisnya, terbaru lebih dulu. Status kosong berarti semua status.
+	ListStockCounts(ctx context.Context, warehouseID string, status domain.StockCountStatus) ([]domain.StockCount, error)
+
+	// Transfer order dua fase. Perubahan stoknya memakai IncreaseReservedStock/DecreaseReservedStock saat pick/cancel,
+	// DispatchTransferStock saat dikirim dan ReceiveTransferStock saat diterima.
+	CreateTransferOrder(ctx context.Context, order *domain.TransferOrder) error
+	GetTransferOrderByID(ctx context.Context, id string) (*domain.TransferOrder, error)
+	GetTransferOrderForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.TransferOrder, error)
+	// UpdateTransferOrder menyimpan status, hasil penerimaan dan timestamp tahapan transfer order.
+	UpdateTransferOrder(ctx context.Context, dbops DBTX, order *domain.TransferOrder) error
+	ListTransferOrders(ctx context.Context, filter domain.ListTransferOrdersFilter) ([]domain.TransferOrder, error)
+	// DispatchTransferStock mengurangi quantity dan reserved_quantity gudang asal (unit yang sudah di-pick).
+	DispatchTransferStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) error
+	// ReceiveTransferStock menambah quantity gudang tujuan, membuat baris stok baru jika perlu.
+	ReceiveTransferStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error)
+	// GetInTransitQuantities mengembalikan unit IN_TRANSIT satu produk per gudang tujuan.
+	GetInTransitQuantities(ctx context.Context, productID string) (map[string]int, error)
+
+	// Ambang batas stok dan alert stok rendah
+	UpsertStockThreshold(ctx context.Context, threshold *domain.StockThreshold) error
+	GetStockThreshold(ctx context.Context, warehouseID, productID string) (*domain.StockThreshold, error)
+	GetStockThresholdForUpdate(ctx context.Context, dbops DBTX, warehouseID, productID string) (*domain.StockThreshold, error)
+	// UpdateStockThresholdAlertLevel menyimpan level hasil evaluasi terakhir ("" berarti di atas reorder point).
+	UpdateStockThresholdAlertLevel(ctx context.Context, dbops DBTX, warehouseID, productID string, level domain.StockAlertLevel) error
+	DeleteStockThreshold(ctx context.Context, dbops DBTX, warehouseID, productID string) error
+	// ListStockThresholds mengembalikan ambang batas satu gudang, atau semua gudang yang belum di-decommission jika warehouseID kosong.
+	ListStockThresholds(ctx context.Context, warehouseID string) ([]domain.StockThreshold, error)
+	// GetTotalSellableStockByProductID seperti GetTotalAvailableStockByProductID, tetapi tanpa safety stock setiap gudang.
+	GetTotalSellableStockByProductID(ctx context.Context, productID string) (int, error)
+	// GetLatestStockMovementID dan ListStockKeysChangedSince dipakai evaluator alert untuk membaca buku besar stok secara bertahap.
+	GetLatestStockMovementID(ctx context.Context) (int64, error)
+	ListStockKeysChangedSince(ctx context.Context, afterMovementID int64, limit int) ([]domain.StockKey, int64, error)
+
+	CreateStockAlert(ctx context.Context, dbops DBTX, alert *domain.StockAlert) error
+	GetStockAlertByID(ctx context.Context, id string) (*domain.StockAlert, error)
+	GetStockAlertForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockAlert, error)
+	// GetUnresolvedStockAlert mengembalikan alert OPEN/ACKNOWLEDGED satu produk di satu gudang (paling banyak satu).
+	GetUnresolvedStockAlert(ctx context.Context, dbops DBTX, warehouseID, productID string) (*domain.StockAlert, error)
+	UpdateStockAlert(ctx context.Context, dbops DBTX, alert *domain.StockAlert) error
+	ListStockAlerts(ctx context.Context, filter domain.ListStockAlertsFilter) ([]domain.StockAlert, error)
 }
 
 // DBTX adalah interface yang bisa berupa *sql.DB atau *sql.Tx
@@ -65,19 +169,60 @@ func NewPostgresWarehouseRepository(db *sql.DB) WarehouseRepository {
 }
 
 // --- Warehouse Methods ---
+const warehouseColumns = `id, name, location, address_street, address_city, address_province, address_postal_code, address_country,
+       is_active, priority, latitude, longitude, metadata, created_at, updated_at, deleted_at`
+
+func scanWarehouse(row rowScanner, w *domain.Warehouse) error {
+	var location, street, city, province, postalCode, country sql.NullString
+	var latitude, longitude sql.NullFloat64
+	var metadata []byte
+	var deletedAt sql.NullTime
+	if err := row.Scan(&w.ID, &w.Name, &location, &street, &city, &province, &postalCode, &country,
+		&w.IsActive, &w.Priority, &latitude, &longitude, &metadata, &w.CreatedAt, &w.UpdatedAt, &deletedAt); err != nil {
+		return err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &w.Metadata); err != nil {
+			return fmt.Errorf("invalid metadata for warehouse %s: %w", w.ID, err)
+		}
+	}
+	if deletedAt.Valid {
+		w.DeletedAt = &deletedAt.Time
+	}
+	if location.Valid {
+		w.Location = &location.String
+	}
+	if street.Valid || city.Valid || country.Valid {
+		w.Address = &domain.Address{
+			Street:     street.String,
+			City:       city.String,
+			Province:   province.String,
+			PostalCode: postalCode.String,
+			Country:    country.String,
+		}
+	}
+	if latitude.Valid && longitude.Valid {
+		w.Latitude, w.Longitude = &latitude.Float64, &longitude.Float64
+	}
+	return nil
+}
+
 func (r *postgresWarehouseRepository) CreateWarehouse(ctx context.Context, warehouse *domain.Warehouse) error {
-	query := `INSERT INTO warehouses (name, location, is_active, created_at, updated_at)
-              VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+	query := `INSERT INTO warehouses (name, location, address_street, address_city, address_province, address_postal_code, address_country,
+                                      is_active, priority, latitude, longitude, metadata, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, created_at, updated_at`
 	warehouse.IsActive = true // Default
 	warehouse.CreatedAt = time.Now()
 	warehouse.UpdatedAt = time.Now()
 
-	var location sql.NullString
-	if warehouse.Location != nil {
-		location = sql.NullString{String: *warehouse.Location, Valid: true}
+	location, street, city, province, postalCode, country := warehouseTextColumns(warehouse)
+	metadata, err := marshalMetadata(warehouse.Metadata)
+	if err != nil {
+		return err
 	}
 
-	err := r.db.QueryRowContext(ctx, query, warehouse.Name, location, warehouse.IsActive, warehouse.CreatedAt, warehouse.UpdatedAt).
+	err = r.db.QueryRowContext(ctx, query, warehouse.Name, location, street, city, province, postalCode, country,
+		warehouse.IsActive, warehouse.Priority, warehouse.Latitude, warehouse.Longitude, metadata, warehouse.CreatedAt, warehouse.UpdatedAt).
 		Scan(&warehouse.ID, &warehouse.CreatedAt, &warehouse.UpdatedAt)
 	if err != nil {
 		logger.Error("CreateWarehouse: failed to insert warehouse", err, nil)
@@ -86,26 +231,48 @@ func (r *postgresWarehouseRepository) CreateWarehouse(ctx context.Context, wareh
 	return nil
 }
 
+// warehouseTextColumns memetakan location dan alamat gudang ke kolomnya; string kosong disimpan sebagai NULL.
+func warehouseTextColumns(w *domain.Warehouse) (location, street, city, province, postalCode, country sql.NullString) {
+	if w.Location != nil {
+		location = sql.NullString{String: *w.Location, Valid: true}
+	}
+	if a := w.Address; a != nil {
+		street, city, country = nullString(a.Street), nullString(a.City), nullString(a.Country)
+		province, postalCode = nullString(a.Province), nullString(a.PostalCode)
+	}
+	return
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func marshalMetadata(metadata map[string]string) ([]byte, error) {
+	if metadata == nil {
+		return []byte("{}"), nil
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode warehouse metadata: %w", err)
+	}
+	return b, nil
+}
+
 func (r *postgresWarehouseRepository) GetWarehouseByID(ctx context.Context, id string) (*domain.Warehouse, error) {
-	query := `SELECT id, name, location, is_active, created_at, updated_at FROM warehouses WHERE id = $1`
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE id = $1`
 	var w domain.Warehouse
-	var location sql.NullString
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&w.ID, &w.Name, &location, &w.IsActive, &w.CreatedAt, &w.UpdatedAt)
-	if err != nil {
+	if err := scanWarehouse(r.db.QueryRowContext(ctx, query, id), &w); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrWarehouseNotFound
 		}
 		logger.Error("GetWarehouseByID: query failed", err, nil)
 		return nil, err
 	}
-	if location.Valid {
-		w.Location = &location.String
-	}
 	return &w, nil
 }
 
 func (r *postgresWarehouseRepository) ListWarehouses(ctx context.Context) ([]domain.Warehouse, error) {
-	query := `SELECT id, name, location, is_active, created_at, updated_at FROM warehouses ORDER BY name ASC`
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE deleted_at IS NULL ORDER BY name ASC`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		logger.Error("ListWarehouses: query failed", err, nil)
@@ -116,14 +283,10 @@ func (r *postgresWarehouseRepository) ListWarehouses(ctx context.Context) ([]dom
 	warehouses := []domain.Warehouse{}
 	for rows.Next() {
 		var w domain.Warehouse
-		var location sql.NullString
-		if err := rows.Scan(&w.ID, &w.Name, &location, &w.IsActive, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		if err := scanWarehouse(rows, &w); err != nil {
 			logger.Error("ListWarehouses: scan failed", err, nil)
 			return nil, err
 		}
-		if location.Valid {
-			w.Location = &location.String
-		}
 		warehouses = append(warehouses, w)
 	}
 	return warehouses, rows.Err()
@@ -139,11 +302,78 @@ func (r *postgresWarehouseRepository) UpdateWarehouseStatus(ctx context.Context,
 	return nil
 }
 
+func (r *postgresWarehouseRepository) UpdateWarehouse(ctx context.Context, warehouse *domain.Warehouse) error {
+	query := `UPDATE warehouses SET name = $1, location = $2, address_street = $3, address_city = $4, address_province = $5,
+                  address_postal_code = $6, address_country = $7, priority = $8, latitude = $9, longitude = $10,
+                  metadata = $11, updated_at = NOW()
+              WHERE id = $12 AND deleted_at IS NULL
+              RETURNING updated_at`
+	location, street, city, province, postalCode, country := warehouseTextColumns(warehouse)
+	metadata, err := marshalMetadata(warehouse.Metadata)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, query, warehouse.Name, location, street, city, province, postalCode, country,
+		warehouse.Priority, warehouse.Latitude, warehouse.Longitude, metadata, warehouse.ID).Scan(&warehouse.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWarehouseNotFound
+		}
+		logger.Error("UpdateWarehouse: exec failed", err, nil)
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) SoftDeleteWarehouse(ctx context.Context, id string) (time.Time, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("SoftDeleteWarehouse: failed to begin transaction", err, nil)
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// Kunci baris stok gudang agar reservasi yang sedang berjalan selesai dulu (atau menunggu) sebelum dicek.
+	rows, err := tx.QueryContext(ctx, `SELECT reserved_quantity FROM product_stocks WHERE warehouse_id = $1 FOR UPDATE`, id)
+	if err != nil {
+		logger.Error("SoftDeleteWarehouse: failed to lock stock rows", err, nil)
+		return time.Time{}, err
+	}
+	reserved := 0
+	for rows.Next() {
+		var qty int
+		if err := rows.Scan(&qty); err != nil {
+			rows.Close()
+			return time.Time{}, err
+		}
+		reserved += qty
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return time.Time{}, err
+	}
+	if reserved > 0 {
+		return time.Time{}, fmt.Errorf("%d units reserved: %w", reserved, ErrWarehouseHasReserved)
+	}
+
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, `UPDATE warehouses SET is_active = FALSE, deleted_at = NOW(), updated_at = NOW()
+                                   WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`, id).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrWarehouseNotFound
+		}
+		logger.Error("SoftDeleteWarehouse: update failed", err, nil)
+		return time.Time{}, err
+	}
+	return deletedAt, tx.Commit()
+}
+
 // --- Stock Methods ---
 
 // CreateOrUpdateProductStock is used for initially adding stock or adjusting it.
 // For transactional reservations/deductions, use specific methods with DBTX.
-func (r *postgresWarehouseRepository) CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock) error {
+func (r *postgresWarehouseRepository) CreateOrUpdateProductStock(ctx context.Context, stock *domain.ProductStock, ref domain.MovementRef) error {
 	query := `
         INSERT INTO product_stocks (warehouse_id, product_id, quantity, reserved_quantity, created_at, updated_at)
         VALUES ($1, $2, $3, $4, $5, $6)
@@ -156,8 +386,16 @@ func (r *postgresWarehouseRepository) CreateOrUpdateProductStock(ctx context.Con
 	stock.UpdatedAt = time.Now()
 	// initial reserved_quantity is 0 when adding stock this way
 	stock.ReservedQuantity = 0
+	added := stock.Quantity
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("CreateOrUpdateProductStock: failed to begin transaction", err, nil)
+		return err
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRowContext(ctx, query,
+	err = tx.QueryRowContext(ctx, query,
 		stock.WarehouseID, stock.ProductID, stock.Quantity, stock.ReservedQuantity,
 		stock.CreatedAt, stock.UpdatedAt,
 	).Scan(&stock.ID, &stock.Quantity, &stock.ReservedQuantity, &stock.CreatedAt, &stock.UpdatedAt)
@@ -170,7 +408,11 @@ func (r *postgresWarehouseRepository) CreateOrUpdateProductStock(ctx context.Con
 		logger.Error("CreateOrUpdateProductStock: failed to upsert stock", err, nil)
 		return err
 	}
-	return nil
+	movement := domain.NewStockMovement(domain.MovementReceipt, stock.WarehouseID, stock.ProductID, added, 0, stock.Quantity, stock.ReservedQuantity, ref)
+	if err := insertStockMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *postgresWarehouseRepository) GetProductStock(ctx context.Context, warehouseID, productID string) (*domain.ProductStock, error) {
@@ -190,6 +432,38 @@ func (r *postgresWarehouseRepository) GetProductStock(ctx context.Context, wareh
 	return &ps, nil
 }
 
+func (r *postgresWarehouseRepository) ListProductStocks(ctx context.Context, productID string) ([]domain.ProductStock, error) {
+	query := `SELECT id, warehouse_id, product_id, quantity, reserved_quantity, created_at, updated_at
+              FROM product_stocks WHERE product_id = $1`
+	return r.queryProductStocks(ctx, "ListProductStocks", query, productID)
+}
+
+func (r *postgresWarehouseRepository) ListWarehouseStocks(ctx context.Context, warehouseID string) ([]domain.ProductStock, error) {
+	query := `SELECT id, warehouse_id, product_id, quantity, reserved_quantity, created_at, updated_at
+              FROM product_stocks WHERE warehouse_id = $1 ORDER BY product_id`
+	return r.queryProductStocks(ctx, "ListWarehouseStocks", query, warehouseID)
+}
+
+func (r *postgresWarehouseRepository) queryProductStocks(ctx context.Context, op, query string, args ...interface{}) ([]domain.ProductStock, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error(op+": query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocks := []domain.ProductStock{}
+	for rows.Next() {
+		var ps domain.ProductStock
+		if err := rows.Scan(&ps.ID, &ps.WarehouseID, &ps.ProductID, &ps.Quantity, &ps.ReservedQuantity, &ps.CreatedAt, &ps.UpdatedAt); err != nil {
+			logger.Error(op+": scan failed", err, nil)
+			return nil, err
+		}
+		stocks = append(stocks, ps)
+	}
+	return stocks, rows.Err()
+}
+
 func (r *postgresWarehouseRepository) GetTotalAvailableStockByProductID(ctx context.Context, productID string) (int, error) {
 	query := `
         SELECT COALESCE(SUM(ps.quantity - ps.reserved_quantity), 0)
@@ -205,7 +479,7 @@ func (r *postgresWarehouseRepository) GetTotalAvailableStockByProductID(ctx cont
 	return totalAvailable, nil
 }
 
-func (r *postgresWarehouseRepository) TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int) error {
+func (r *postgresWarehouseRepository) TransferStock(ctx context.Context, productID, sourceWarehouseID, targetWarehouseID string, quantity int, ref domain.MovementRef) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		logger.Error("TransferStock: failed to begin transaction", err, nil)
@@ -233,15 +507,29 @@ func (r *postgresWarehouseRepository) TransferStock(ctx context.Context, product
 			productID, sourceWarehouseID, (sourceStock.Quantity - quantity), sourceStock.ReservedQuantity, ErrInsufficientStock)
 	}
 
+	if ref.ReferenceID == "" {
+		ref.ReferenceType = domain.MovementRefTransfer
+		if err := tx.QueryRowContext(ctx, `SELECT gen_random_uuid()::text`).Scan(&ref.ReferenceID); err != nil {
+			logger.Error("TransferStock: failed to generate transfer id", err, nil)
+			return err
+		}
+	}
+
 	// 3. Kurangi stok dari gudang sumber
 	// Langsung mengurangi 'quantity', bukan 'reserved_quantity'
 	querySource := `UPDATE product_stocks SET quantity = quantity - $1, updated_at = NOW()
-                    WHERE warehouse_id = $2 AND product_id = $3`
-	_, err = tx.ExecContext(ctx, querySource, quantity, sourceWarehouseID, productID)
+                    WHERE warehouse_id = $2 AND product_id = $3
+                    RETURNING quantity, reserved_quantity`
+	var sourceQty, sourceReserved int
+	err = tx.QueryRowContext(ctx, querySource, quantity, sourceWarehouseID, productID).Scan(&sourceQty, &sourceReserved)
 	if err != nil {
 		logger.Error("TransferStock: failed to decrease stock from source", err, nil)
 		return fmt.Errorf("failed to decrease stock from source warehouse %s: %w", sourceWarehouseID, err)
 	}
+	out := domain.NewStockMovement(domain.MovementTransferOut, sourceWarehouseID, productID, -quantity, 0, sourceQty, sourceReserved, ref)
+	if err := insertStockMovement(ctx, tx, out); err != nil {
+		return err
+	}
 
 	// 4. Tambah atau update stok di gudang tujuan (buat entri jika belum ada)
 	// Kunci baris entri stok di gudang tujuan jika sudah ada, atau siapkan untuk insert
@@ -252,9 +540,11 @@ func (r *postgresWarehouseRepository) TransferStock(ctx context.Context, product
         ON CONFLICT (warehouse_id, product_id) DO UPDATE SET
         quantity = product_stocks.quantity + EXCLUDED.quantity,
         updated_at = NOW()
-        WHERE product_stocks.warehouse_id = $1 AND product_stocks.product_id = $2`
+        WHERE product_stocks.warehouse_id = $1 AND product_stocks.product_id = $2
+        RETURNING quantity, reserved_quantity`
 
-	_, err = tx.ExecContext(ctx, queryTarget, targetWarehouseID, productID, quantity)
+	var targetQty, targetReserved int
+	err = tx.QueryRowContext(ctx, queryTarget, targetWarehouseID, productID, quantity).Scan(&targetQty, &targetReserved)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation for targetWarehouseID
 			logger.Error("TransferStock: target warehouse does not exist", err, map[string]interface{}{"target_warehouse_id": targetWarehouseID})
@@ -263,6 +553,10 @@ func (r *postgresWarehouseRepository) TransferStock(ctx context.Context, product
 		logger.Error("TransferStock: failed to increase/update stock in target", err, nil)
 		return fmt.Errorf("failed to update stock in target warehouse %s: %w", targetWarehouseID, err)
 	}
+	in := domain.NewStockMovement(domain.MovementTransferIn, targetWarehouseID, productID, quantity, 0, targetQty, targetReserved, ref)
+	if err := insertStockMovement(ctx, tx, in); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
@@ -303,13 +597,18 @@ func (r *postgresWarehouseRepository) GetProductStockForUpdate(ctx context.Conte
 	return &ps, nil
 }
 
-func (r *postgresWarehouseRepository) IncreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error {
+func (r *postgresWarehouseRepository) IncreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
 	// Ensure stock item exists, if not, create with 0, then update
 	// This is a simplified version, assumes stock item exists or GetProductStockForUpdate handled it
 	query := `UPDATE product_stocks SET quantity = quantity + $1, updated_at = NOW()
-              WHERE warehouse_id = $2 AND product_id = $3`
-	res, err := dbops.ExecContext(ctx, query, amount, warehouseID, productID)
+              WHERE warehouse_id = $2 AND product_id = $3
+              RETURNING quantity, reserved_quantity`
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, amount, warehouseID, productID).Scan(&qty, &reserved)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProductStockNotFound // Or handle more gracefully, e.g. try to insert it
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation (e.g. quantity < 0)
 			logger.Error("IncreaseProductStockQuantity: check violation", err, nil)
 			return ErrUpdateStockOutOfBounds
@@ -317,19 +616,20 @@ func (r *postgresWarehouseRepository) IncreaseProductStockQuantity(ctx context.C
 		logger.Error("IncreaseProductStockQuantity: exec failed", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrProductStockNotFound // Or handle more gracefully, e.g. try to insert it
-	}
-	return nil
+	return insertStockMovement(ctx, dbops, domain.NewStockMovement(domain.MovementAdjustment, warehouseID, productID, amount, 0, qty, reserved, ref))
 }
 
 // DecreaseProductStockQuantity (for actual sale)
-func (r *postgresWarehouseRepository) DecreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error {
+func (r *postgresWarehouseRepository) DecreaseProductStockQuantity(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
 	query := `UPDATE product_stocks SET quantity = quantity - $1, updated_at = NOW()
-              WHERE warehouse_id = $2 AND product_id = $3 AND (quantity - $1) >= reserved_quantity AND (quantity - $1) >= 0`
-	res, err := dbops.ExecContext(ctx, query, amount, warehouseID, productID)
+              WHERE warehouse_id = $2 AND product_id = $3 AND (quantity - $1) >= reserved_quantity AND (quantity - $1) >= 0
+              RETURNING quantity, reserved_quantity`
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, amount, warehouseID, productID).Scan(&qty, &reserved)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInsufficientStock // or product not found, or (quantity - amount) < 0 condition failed
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
 			logger.Error("DecreaseProductStockQuantity: check violation", err, nil)
 			// This can also mean quantity became less than reserved_quantity, which is bad if not handled
@@ -338,19 +638,20 @@ func (r *postgresWarehouseRepository) DecreaseProductStockQuantity(ctx context.C
 		logger.Error("DecreaseProductStockQuantity: exec failed", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrInsufficientStock // or product not found, or (quantity - amount) < 0 condition failed
-	}
-	return nil
+	return insertStockMovement(ctx, dbops, domain.NewStockMovement(domain.MovementAdjustment, warehouseID, productID, -amount, 0, qty, reserved, ref))
 }
 
 // IncreaseReservedStock (for order reservation)
-func (r *postgresWarehouseRepository) IncreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error {
+func (r *postgresWarehouseRepository) IncreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
 	query := `UPDATE product_stocks SET reserved_quantity = reserved_quantity + $1, updated_at = NOW()
-              WHERE warehouse_id = $2 AND product_id = $3 AND (quantity - (reserved_quantity + $1)) >= 0`
-	res, err := dbops.ExecContext(ctx, query, amount, warehouseID, productID)
+              WHERE warehouse_id = $2 AND product_id = $3 AND (quantity - (reserved_quantity + $1)) >= 0
+              RETURNING quantity, reserved_quantity`
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, amount, warehouseID, productID).Scan(&qty, &reserved)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInsufficientStock // or product not found, or no available stock to reserve
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
 			logger.Error("IncreaseReservedStock: check violation", err, nil)
 			return ErrInsufficientStock
@@ -358,19 +659,20 @@ func (r *postgresWarehouseRepository) IncreaseReservedStock(ctx context.Context,
 		logger.Error("IncreaseReservedStock: exec failed", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrInsufficientStock // or product not found, or no available stock to reserve
-	}
-	return nil
+	return insertStockMovement(ctx, dbops, domain.NewStockMovement(domain.MovementReserve, warehouseID, productID, 0, amount, qty, reserved, ref))
 }
 
 // DecreaseReservedStock (for releasing reservation or completing sale)
-func (r *postgresWarehouseRepository) DecreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int) error {
+func (r *postgresWarehouseRepository) DecreaseReservedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, amount int, ref domain.MovementRef) error {
 	query := `UPDATE product_stocks SET reserved_quantity = reserved_quantity - $1, updated_at = NOW()
-              WHERE warehouse_id = $2 AND product_id = $3 AND (reserved_quantity - $1) >= 0`
-	res, err := dbops.ExecContext(ctx, query, amount, warehouseID, productID)
+              WHERE warehouse_id = $2 AND product_id = $3 AND (reserved_quantity - $1) >= 0
+              RETURNING quantity, reserved_quantity`
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, amount, warehouseID, productID).Scan(&qty, &reserved)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockConflict // or product not found, or reserved_quantity couldn't be decreased that much
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
 			logger.Error("DecreaseReservedStock: check violation", err, nil)
 			return ErrUpdateStockOutOfBounds // e.g. reserved_quantity went negative
@@ -378,14 +680,10 @@ func (r *postgresWarehouseRepository) DecreaseReservedStock(ctx context.Context,
 		logger.Error("DecreaseReservedStock: exec failed", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrStockConflict // or product not found, or reserved_quantity couldn't be decreased that much
-	}
-	return nil
+	return insertStockMovement(ctx, dbops, domain.NewStockMovement(domain.MovementRelease, warehouseID, productID, 0, -amount, qty, reserved, ref))
 }
 
-func (r *postgresWarehouseRepository) DeductCommittedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantityToDeduct int) error {
+func (r *postgresWarehouseRepository) DeductCommittedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantityToDeduct int, ref domain.MovementRef) error {
 	// Metode ini mengurangi reserved_quantity DAN quantity aktual.
 	query := `UPDATE product_stocks
               SET quantity = quantity - $1,
@@ -393,11 +691,20 @@ func (r *postgresWarehouseRepository) DeductCommittedStock(ctx context.Context,
                   updated_at = NOW()
               WHERE warehouse_id = $2 AND product_id = $3
                 AND (reserved_quantity - $1) >= 0
-                AND (quantity - $1) >= 0`
+                AND (quantity - $1) >= 0
+              RETURNING quantity, reserved_quantity`
 	// Mungkin perlu juga AND quantity >= reserved_quantity
 
-	res, err := dbops.ExecContext(ctx, query, quantityToDeduct, warehouseID, productID)
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, quantityToDeduct, warehouseID, productID).Scan(&qty, &reserved)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Ini bisa berarti stok tidak cukup lagi, atau reservasi kurang, atau produk tidak ada
+			logger.Error("DeductCommittedStock: no rows affected, potential inconsistency or insufficient reserved stock", nil, map[string]interface{}{
+				"warehouse_id": warehouseID, "product_id": productID, "quantity": quantityToDeduct,
+			})
+			return ErrInsufficientStock // Atau error yang lebih spesifik
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
 			logger.Error("DeductCommittedStock: check violation (negative stock/reserved)", err, nil)
 			return ErrInsufficientStock // Atau error yang lebih spesifik
@@ -405,15 +712,8 @@ func (r *postgresWarehouseRepository) DeductCommittedStock(ctx context.Context,
 		logger.Error("DeductCommittedStock: exec failed", err, nil)
 		return err
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		// Ini bisa berarti stok tidak cukup lagi, atau reservasi kurang, atau produk tidak ada
-		logger.Error("DeductCommittedStock: no rows affected, potential inconsistency or insufficient reserved stock", nil, map[string]interface{}{
-			"warehouse_id": warehouseID, "product_id": productID, "quantity": quantityToDeduct,
-		})
-		return ErrInsufficientStock // Atau error yang lebih spesifik
-	}
-	return nil
+	movement := domain.NewStockMovement(domain.MovementCommit, warehouseID, productID, -quantityToDeduct, -quantityToDeduct, qty, reserved, ref)
+	return insertStockMovement(ctx, dbops, movement)
 }
 
 func (r *postgresWarehouseRepository) FindWarehousesWithActiveReservations(ctx context.Context, productIDs []string) ([]domain.ProductWarehouseReservationInfo, error) {
@@ -450,3 +750,1019 @@ func (r *postgresWarehouseRepository) FindWarehousesWithActiveReservations(ctx c
 	}
 	return results, rows.Err()
 }
+
+// --- Reservation Methods ---
+
+const reservationColumns = `id, order_id, warehouse_id, product_id, quantity, status, expires_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer dipenuhi oleh *sql.DB maupun DBTX, untuk query yang dipakai di dalam dan di luar transaksi.
+type queryer interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}
+
+func scanReservation(row rowScanner, res *domain.StockReservation) error {
+	var orderID sql.NullString
+	err := row.Scan(&res.ID, &orderID, &res.WarehouseID, &res.ProductID, &res.Quantity, &res.Status, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if orderID.Valid {
+		res.OrderID = &orderID.String
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) CreateReservation(ctx context.Context, dbops DBTX, reservation *domain.StockReservation) error {
+	query := `INSERT INTO stock_reservations (order_id, warehouse_id, product_id, quantity, status, expires_at)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              RETURNING id, created_at, updated_at`
+	if reservation.Status == "" {
+		reservation.Status = domain.ReservationStatusActive
+	}
+	err := dbops.QueryRowContext(ctx, query, reservation.OrderID, reservation.WarehouseID, reservation.ProductID,
+		reservation.Quantity, reservation.Status, reservation.ExpiresAt).
+		Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateReservation: insert failed", err, map[string]interface{}{
+			"warehouse_id": reservation.WarehouseID, "product_id": reservation.ProductID,
+		})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetReservationForUpdate(ctx context.Context, dbops DBTX, reservationID string) (*domain.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE id = $1 FOR UPDATE`
+	var res domain.StockReservation
+	if err := scanReservation(dbops.QueryRowContext(ctx, query, reservationID), &res); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReservationNotFound
+		}
+		logger.Error("GetReservationForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *postgresWarehouseRepository) UpdateReservationStatus(ctx context.Context, dbops DBTX, reservationID string, status domain.ReservationStatus) error {
+	query := `UPDATE stock_reservations SET status = $1, updated_at = NOW() WHERE id = $2`
+	res, err := dbops.ExecContext(ctx, query, status, reservationID)
+	if err != nil {
+		logger.Error("UpdateReservationStatus: exec failed", err, map[string]interface{}{"reservation_id": reservationID})
+		return err
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetReservationByID(ctx context.Context, reservationID string) (*domain.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE id = $1`
+	var res domain.StockReservation
+	if err := scanReservation(r.db.QueryRowContext(ctx, query, reservationID), &res); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReservationNotFound
+		}
+		logger.Error("GetReservationByID: query failed", err, nil)
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *postgresWarehouseRepository) ListReservationsByOrderID(ctx context.Context, orderID string) ([]domain.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations WHERE order_id = $1 ORDER BY created_at, id`
+	return r.queryReservations(ctx, "ListReservationsByOrderID", query, orderID)
+}
+
+// ListExpiredReservations mengembalikan reservasi ACTIVE yang sudah melewati expires_at, paling lama lebih dulu.
+func (r *postgresWarehouseRepository) ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]domain.StockReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM stock_reservations
+              WHERE status = $1 AND expires_at < $2
+              ORDER BY expires_at ASC
+              LIMIT $3`
+	return r.queryReservations(ctx, "ListExpiredReservations", query, domain.ReservationStatusActive, now, limit)
+}
+
+func (r *postgresWarehouseRepository) queryReservations(ctx context.Context, op, query string, args ...interface{}) ([]domain.StockReservation, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error(op+": query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []domain.StockReservation{}
+	for rows.Next() {
+		var res domain.StockReservation
+		if err := scanReservation(rows, &res); err != nil {
+			logger.Error(op+": scan failed", err, nil)
+			return nil, err
+		}
+		reservations = append(reservations, res)
+	}
+	return reservations, rows.Err()
+}
+
+// --- Stock Return Methods ---
+
+const stockReturnColumns = `id, return_reference, warehouse_id, product_id, quantity, created_at`
+
+// CreateStockReturn mencatat penerimaan retur. Referensi yang sudah pernah dipakai menghasilkan ErrStockReturnExists.
+func (r *postgresWarehouseRepository) CreateStockReturn(ctx context.Context, dbops DBTX, stockReturn *domain.StockReturn) error {
+	query := `INSERT INTO stock_returns (return_reference, warehouse_id, product_id, quantity)
+              VALUES ($1, $2, $3, $4)
+              ON CONFLICT (return_reference) DO NOTHING
+              RETURNING id, created_at`
+	err := dbops.QueryRowContext(ctx, query, stockReturn.ReturnReference, stockReturn.WarehouseID,
+		stockReturn.ProductID, stockReturn.Quantity).Scan(&stockReturn.ID, &stockReturn.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockReturnExists
+		}
+		logger.Error("CreateStockReturn: insert failed", err, map[string]interface{}{"return_reference": stockReturn.ReturnReference})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetStockReturnByReference(ctx context.Context, reference string) (*domain.StockReturn, error) {
+	query := `SELECT ` + stockReturnColumns + ` FROM stock_returns WHERE return_reference = $1`
+	var ret domain.StockReturn
+	err := r.db.QueryRowContext(ctx, query, reference).
+		Scan(&ret.ID, &ret.ReturnReference, &ret.WarehouseID, &ret.ProductID, &ret.Quantity, &ret.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockReturnNotFound
+		}
+		logger.Error("GetStockReturnByReference: query failed", err, nil)
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// AddReturnedStock menambah product_stocks.quantity, membuat baris stok baru jika gudang belum pernah menyimpan produk ini.
+func (r *postgresWarehouseRepository) AddReturnedStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error) {
+	return addStockQuantity(ctx, dbops, "AddReturnedStock", domain.MovementReturn, warehouseID, productID, quantity, ref)
+}
+
+// addStockQuantity menambah quantity dengan upsert dan mencatat movement dengan jenis yang diberikan.
+func addStockQuantity(ctx context.Context, dbops DBTX, op string, movementType domain.MovementType, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error) {
+	query := `
+        INSERT INTO product_stocks (warehouse_id, product_id, quantity, reserved_quantity)
+        VALUES ($1, $2, $3, 0)
+        ON CONFLICT (warehouse_id, product_id) DO UPDATE SET
+        quantity = product_stocks.quantity + EXCLUDED.quantity,
+        updated_at = NOW()
+        RETURNING id, warehouse_id, product_id, quantity, reserved_quantity, created_at, updated_at`
+	var stock domain.ProductStock
+	err := dbops.QueryRowContext(ctx, query, warehouseID, productID, quantity).
+		Scan(&stock.ID, &stock.WarehouseID, &stock.ProductID, &stock.Quantity, &stock.ReservedQuantity, &stock.CreatedAt, &stock.UpdatedAt)
+	if err != nil {
+		logger.Error(op+": upsert failed", err, map[string]interface{}{"warehouse_id": warehouseID, "product_id": productID})
+		return nil, err
+	}
+	movement := domain.NewStockMovement(movementType, warehouseID, productID, quantity, 0, stock.Quantity, stock.ReservedQuantity, ref)
+	if err := insertStockMovement(ctx, dbops, movement); err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+// --- Stock Movements ---
+
+const stockMovementColumns = `id, warehouse_id, product_id, movement_type, quantity_delta, reserved_delta,
+       quantity_before, quantity_after, reserved_before, reserved_after,
+       COALESCE(reference_type, ''), COALESCE(reference_id, ''), actor, COALESCE(reason, ''), created_at`
+
+// insertStockMovement menulis satu baris buku besar memakai dbops yang sama dengan perubahan stoknya,
+// sehingga movement ikut di-rollback jika perubahannya gagal.
+func insertStockMovement(ctx context.Context, dbops DBTX, m domain.StockMovement) error {
+	if m.Actor == "" {
+		m.Actor = domain.ActorSystem
+	}
+	query := `INSERT INTO stock_movements (warehouse_id, product_id, movement_type, quantity_delta, reserved_delta,
+                  quantity_before, quantity_after, reserved_before, reserved_after, reference_type, reference_id, actor, reason)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := dbops.ExecContext(ctx, query, m.WarehouseID, m.ProductID, m.Type, m.QuantityDelta, m.ReservedDelta,
+		m.QuantityBefore, m.QuantityAfter, m.ReservedBefore, m.ReservedAfter,
+		nullString(m.ReferenceType), nullString(m.ReferenceID), m.Actor, nullString(m.Reason))
+	if err != nil {
+		logger.Error("insertStockMovement: insert failed", err, map[string]interface{}{
+			"warehouse_id": m.WarehouseID, "product_id": m.ProductID, "type": m.Type,
+		})
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListStockMovements(ctx context.Context, filter domain.ListMovementsFilter) ([]domain.StockMovement, error) {
+	conditions := []string{"warehouse_id = $1", "product_id = $2"}
+	args := []interface{}{filter.WarehouseID, filter.ProductID}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("movement_type = $%d", len(args)))
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	query := `SELECT ` + stockMovementColumns + `
+              FROM stock_movements
+              WHERE ` + strings.Join(conditions, " AND ") + `
+              ORDER BY id DESC
+              LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("ListStockMovements: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []domain.StockMovement{}
+	for rows.Next() {
+		var m domain.StockMovement
+		if err := rows.Scan(&m.ID, &m.WarehouseID, &m.ProductID, &m.Type, &m.QuantityDelta, &m.ReservedDelta,
+			&m.QuantityBefore, &m.QuantityAfter, &m.ReservedBefore, &m.ReservedAfter,
+			&m.ReferenceType, &m.ReferenceID, &m.Actor, &m.Reason, &m.CreatedAt); err != nil {
+			logger.Error("ListStockMovements: scan failed", err, nil)
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// --- Stock Adjustment Methods ---
+const stockAdjustmentColumns = `id, warehouse_id, product_id, quantity_delta, reason_code, COALESCE(note, ''), status,
+       requested_by, COALESCE(reviewed_by, ''), COALESCE(review_note, ''), reviewed_at, applied_at, created_at, updated_at`
+
+func scanStockAdjustment(row rowScanner, a *domain.StockAdjustment) error {
+	var reviewedAt, appliedAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.WarehouseID, &a.ProductID, &a.QuantityDelta, &a.ReasonCode, &a.Note, &a.Status,
+		&a.RequestedBy, &a.ReviewedBy, &a.ReviewNote, &reviewedAt, &appliedAt, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return err
+	}
+	if reviewedAt.Valid {
+		a.ReviewedAt = &reviewedAt.Time
+	}
+	if appliedAt.Valid {
+		a.AppliedAt = &appliedAt.Time
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) CreateStockAdjustment(ctx context.Context, dbops DBTX, adjustment *domain.StockAdjustment) error {
+	query := `INSERT INTO stock_adjustments (warehouse_id, product_id, quantity_delta, reason_code, note, status, requested_by, applied_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+              RETURNING id, created_at, updated_at`
+	err := dbops.QueryRowContext(ctx, query, adjustment.WarehouseID, adjustment.ProductID, adjustment.QuantityDelta,
+		adjustment.ReasonCode, nullString(adjustment.Note), adjustment.Status, adjustment.RequestedBy, adjustment.AppliedAt).
+		Scan(&adjustment.ID, &adjustment.CreatedAt, &adjustment.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateStockAdjustment: insert failed", err, map[string]interface{}{
+			"warehouse_id": adjustment.WarehouseID, "product_id": adjustment.ProductID,
+		})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetStockAdjustmentByID(ctx context.Context, id string) (*domain.StockAdjustment, error) {
+	query := `SELECT ` + stockAdjustmentColumns + ` FROM stock_adjustments WHERE id = $1`
+	var a domain.StockAdjustment
+	if err := scanStockAdjustment(r.db.QueryRowContext(ctx, query, id), &a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockAdjustmentNotFound
+		}
+		logger.Error("GetStockAdjustmentByID: query failed", err, nil)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *postgresWarehouseRepository) GetStockAdjustmentForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockAdjustment, error) {
+	query := `SELECT ` + stockAdjustmentColumns + ` FROM stock_adjustments WHERE id = $1 FOR UPDATE`
+	var a domain.StockAdjustment
+	if err := scanStockAdjustment(dbops.QueryRowContext(ctx, query, id), &a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockAdjustmentNotFound
+		}
+		logger.Error("GetStockAdjustmentForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *postgresWarehouseRepository) UpdateStockAdjustmentReview(ctx context.Context, dbops DBTX, adjustment *domain.StockAdjustment) error {
+	query := `UPDATE stock_adjustments
+              SET status = $1, reviewed_by = $2, review_note = $3, reviewed_at = $4, applied_at = $5, updated_at = NOW()
+              WHERE id = $6 AND status = $7
+              RETURNING updated_at`
+	err := dbops.QueryRowContext(ctx, query, adjustment.Status, nullString(adjustment.ReviewedBy), nullString(adjustment.ReviewNote),
+		adjustment.ReviewedAt, adjustment.AppliedAt, adjustment.ID, domain.AdjustmentStatusPendingApproval).
+		Scan(&adjustment.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockAdjustmentReviewed
+		}
+		logger.Error("UpdateStockAdjustmentReview: update failed", err, map[string]interface{}{"adjustment_id": adjustment.ID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListStockAdjustments(ctx context.Context, warehouseID string, status domain.AdjustmentStatus) ([]domain.StockAdjustment, error) {
+	query := `SELECT ` + stockAdjustmentColumns + ` FROM stock_adjustments
+              WHERE warehouse_id = $1 AND ($2 = '' OR status = $2)
+              ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, warehouseID, string(status))
+	if err != nil {
+		logger.Error("ListStockAdjustments: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []domain.StockAdjustment{}
+	for rows.Next() {
+		var a domain.StockAdjustment
+		if err := scanStockAdjustment(rows, &a); err != nil {
+			logger.Error("ListStockAdjustments: scan failed", err, nil)
+			return nil, err
+		}
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
+
+// --- Stock Count Methods ---
+const stockCountColumns = `id, warehouse_id, status, full_scope, COALESCE(note, ''), opened_by, COALESCE(closed_by, ''),
+       closed_at, created_at, updated_at`
+
+func scanStockCount(row rowScanner, c *domain.StockCount) error {
+	var closedAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.WarehouseID, &c.Status, &c.FullScope, &c.Note, &c.OpenedBy, &c.ClosedBy,
+		&closedAt, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return err
+	}
+	if closedAt.Valid {
+		c.ClosedAt = &closedAt.Time
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) CreateStockCount(ctx context.Context, dbops DBTX, count *domain.StockCount, productIDs []string) error {
+	query := `INSERT INTO stock_counts (warehouse_id, status, full_scope, note, opened_by)
+              VALUES ($1, $2, $3, $4, $5)
+              RETURNING id, created_at, updated_at`
+	err := dbops.QueryRowContext(ctx, query, count.WarehouseID, count.Status, count.FullScope, nullString(count.Note), count.OpenedBy).
+		Scan(&count.ID, &count.CreatedAt, &count.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation: sesi menyeluruh lain masih terbuka
+			return ErrStockCountConflict
+		}
+		logger.Error("CreateStockCount: insert failed", err, map[string]interface{}{"warehouse_id": count.WarehouseID})
+		return err
+	}
+
+	// Snapshot expected quantity diambil dalam satu statement bersama pembuatan baris
+	linesQuery := `INSERT INTO stock_count_lines (count_id, warehouse_id, product_id, expected_quantity)
+                   SELECT $1, warehouse_id, product_id, quantity FROM product_stocks
+                   WHERE warehouse_id = $2 AND ($3 OR product_id::text = ANY($4))
+                   RETURNING product_id, expected_quantity`
+	rows, err := dbops.QueryContext(ctx, linesQuery, count.ID, count.WarehouseID, count.FullScope, pq.Array(productIDs))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation: produk sudah dihitung sesi lain
+			return ErrStockCountConflict
+		}
+		logger.Error("CreateStockCount: snapshot failed", err, map[string]interface{}{"count_id": count.ID})
+		return err
+	}
+	defer rows.Close()
+
+	count.Lines = []domain.StockCountLine{}
+	for rows.Next() {
+		var line domain.StockCountLine
+		if err := rows.Scan(&line.ProductID, &line.ExpectedQuantity); err != nil {
+			logger.Error("CreateStockCount: scan failed", err, nil)
+			return err
+		}
+		count.Lines = append(count.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrStockCountConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetStockCountByID(ctx context.Context, id string) (*domain.StockCount, error) {
+	query := `SELECT ` + stockCountColumns + ` FROM stock_counts WHERE id = $1`
+	var c domain.StockCount
+	if err := scanStockCount(r.db.QueryRowContext(ctx, query, id), &c); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockCountNotFound
+		}
+		logger.Error("GetStockCountByID: query failed", err, nil)
+		return nil, err
+	}
+	lines, err := queryStockCountLines(ctx, r.db, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.Lines = lines
+	return &c, nil
+}
+
+func (r *postgresWarehouseRepository) GetStockCountForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockCount, error) {
+	query := `SELECT ` + stockCountColumns + ` FROM stock_counts WHERE id = $1 FOR UPDATE`
+	var c domain.StockCount
+	if err := scanStockCount(dbops.QueryRowContext(ctx, query, id), &c); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockCountNotFound
+		}
+		logger.Error("GetStockCountForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	lines, err := queryStockCountLines(ctx, dbops, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.Lines = lines
+	return &c, nil
+}
+
+func queryStockCountLines(ctx context.Context, q queryer, countID string) ([]domain.StockCountLine, error) {
+	query := `SELECT product_id, expected_quantity, counted_quantity, COALESCE(counted_by, ''), counted_at,
+                     COALESCE(adjustment_id::text, '')
+              FROM stock_count_lines WHERE count_id = $1 ORDER BY product_id`
+	rows, err := q.QueryContext(ctx, query, countID)
+	if err != nil {
+		logger.Error("queryStockCountLines: query failed", err, map[string]interface{}{"count_id": countID})
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []domain.StockCountLine{}
+	for rows.Next() {
+		var line domain.StockCountLine
+		var counted sql.NullInt64
+		var countedAt sql.NullTime
+		if err := rows.Scan(&line.ProductID, &line.ExpectedQuantity, &counted, &line.CountedBy, &countedAt, &line.AdjustmentID); err != nil {
+			logger.Error("queryStockCountLines: scan failed", err, nil)
+			return nil, err
+		}
+		if counted.Valid {
+			line.Record(int(counted.Int64), line.CountedBy, countedAt.Time)
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+func (r *postgresWarehouseRepository) UpdateStockCountLine(ctx context.Context, dbops DBTX, countID string, line *domain.StockCountLine) error {
+	query := `UPDATE stock_count_lines
+              SET counted_quantity = $1, counted_by = $2, counted_at = $3, adjustment_id = $4
+              WHERE count_id = $5 AND product_id = $6`
+	result, err := dbops.ExecContext(ctx, query, line.CountedQuantity, nullString(line.CountedBy), line.CountedAt,
+		nullString(line.AdjustmentID), countID, line.ProductID)
+	if err != nil {
+		logger.Error("UpdateStockCountLine: update failed", err, map[string]interface{}{"count_id": countID, "product_id": line.ProductID})
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrStockCountLineNotFound
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) UpdateStockCountStatus(ctx context.Context, dbops DBTX, count *domain.StockCount) error {
+	query := `UPDATE stock_counts SET status = $1, closed_by = $2, closed_at = $3, updated_at = NOW()
+              WHERE id = $4 AND status = $5
+              RETURNING updated_at`
+	err := dbops.QueryRowContext(ctx, query, count.Status, nullString(count.ClosedBy), count.ClosedAt,
+		count.ID, domain.StockCountStatusOpen).Scan(&count.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockCountNotOpen
+		}
+		logger.Error("UpdateStockCountStatus: update failed", err, map[string]interface{}{"count_id": count.ID})
+		return err
+	}
+	if _, err := dbops.ExecContext(ctx, `UPDATE stock_count_lines SET is_open = FALSE WHERE count_id = $1`, count.ID); err != nil {
+		logger.Error("UpdateStockCountStatus: releasing scope failed", err, map[string]interface{}{"count_id": count.ID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListStockCounts(ctx context.Context, warehouseID string, status domain.StockCountStatus) ([]domain.StockCount, error) {
+	query := `SELECT ` + stockCountColumns + ` FROM stock_counts
+              WHERE warehouse_id = $1 AND ($2 = '' OR status = $2)
+              ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, warehouseID, string(status))
+	if err != nil {
+		logger.Error("ListStockCounts: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []domain.StockCount{}
+	for rows.Next() {
+		var c domain.StockCount
+		if err := scanStockCount(rows, &c); err != nil {
+			logger.Error("ListStockCounts: scan failed", err, nil)
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// --- Transfer Order Methods ---
+const transferOrderColumns = `id, product_id, source_warehouse_id, target_warehouse_id, quantity, status, received_quantity,
+       discrepancy_quantity, COALESCE(discrepancy_note, ''), COALESCE(reason, ''), requested_by,
+       picked_at, dispatched_at, received_at, cancelled_at, created_at, updated_at`
+
+func scanTransferOrder(row rowScanner, o *domain.TransferOrder) error {
+	var received sql.NullInt64
+	var pickedAt, dispatchedAt, receivedAt, cancelledAt sql.NullTime
+	if err := row.Scan(&o.ID, &o.ProductID, &o.SourceWarehouseID, &o.TargetWarehouseID, &o.Quantity, &o.Status, &received,
+		&o.DiscrepancyQuantity, &o.DiscrepancyNote, &o.Reason, &o.RequestedBy,
+		&pickedAt, &dispatchedAt, &receivedAt, &cancelledAt, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		return err
+	}
+	if received.Valid {
+		qty := int(received.Int64)
+		o.ReceivedQuantity = &qty
+	}
+	o.PickedAt = nullTimePtr(pickedAt)
+	o.DispatchedAt = nullTimePtr(dispatchedAt)
+	o.ReceivedAt = nullTimePtr(receivedAt)
+	o.CancelledAt = nullTimePtr(cancelledAt)
+	return nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (r *postgresWarehouseRepository) CreateTransferOrder(ctx context.Context, order *domain.TransferOrder) error {
+	query := `INSERT INTO transfer_orders (product_id, source_warehouse_id, target_warehouse_id, quantity, status, reason, requested_by)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)
+              RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, order.ProductID, order.SourceWarehouseID, order.TargetWarehouseID, order.Quantity,
+		order.Status, nullString(order.Reason), order.RequestedBy).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateTransferOrder: insert failed", err, map[string]interface{}{
+			"product_id": order.ProductID, "source_wh": order.SourceWarehouseID, "target_wh": order.TargetWarehouseID,
+		})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetTransferOrderByID(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	query := `SELECT ` + transferOrderColumns + ` FROM transfer_orders WHERE id = $1`
+	var o domain.TransferOrder
+	if err := scanTransferOrder(r.db.QueryRowContext(ctx, query, id), &o); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferOrderNotFound
+		}
+		logger.Error("GetTransferOrderByID: query failed", err, nil)
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *postgresWarehouseRepository) GetTransferOrderForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.TransferOrder, error) {
+	query := `SELECT ` + transferOrderColumns + ` FROM transfer_orders WHERE id = $1 FOR UPDATE`
+	var o domain.TransferOrder
+	if err := scanTransferOrder(dbops.QueryRowContext(ctx, query, id), &o); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferOrderNotFound
+		}
+		logger.Error("GetTransferOrderForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *postgresWarehouseRepository) UpdateTransferOrder(ctx context.Context, dbops DBTX, order *domain.TransferOrder) error {
+	query := `UPDATE transfer_orders
+              SET status = $1, received_quantity = $2, discrepancy_quantity = $3, discrepancy_note = $4,
+                  picked_at = $5, dispatched_at = $6, received_at = $7, cancelled_at = $8, updated_at = NOW()
+              WHERE id = $9
+              RETURNING updated_at`
+	err := dbops.QueryRowContext(ctx, query, order.Status, order.ReceivedQuantity, order.DiscrepancyQuantity,
+		nullString(order.DiscrepancyNote), order.PickedAt, order.DispatchedAt, order.ReceivedAt, order.CancelledAt, order.ID).
+		Scan(&order.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransferOrderNotFound
+		}
+		logger.Error("UpdateTransferOrder: update failed", err, map[string]interface{}{"transfer_order_id": order.ID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListTransferOrders(ctx context.Context, filter domain.ListTransferOrdersFilter) ([]domain.TransferOrder, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.WarehouseID != "" {
+		args = append(args, filter.WarehouseID)
+		conditions = append(conditions, fmt.Sprintf("(source_warehouse_id = $%d OR target_warehouse_id = $%d)", len(args), len(args)))
+	}
+	if filter.ProductID != "" {
+		args = append(args, filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("product_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + transferOrderColumns + ` FROM transfer_orders
+              WHERE ` + strings.Join(conditions, " AND ") + `
+              ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("ListTransferOrders: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []domain.TransferOrder{}
+	for rows.Next() {
+		var o domain.TransferOrder
+		if err := scanTransferOrder(rows, &o); err != nil {
+			logger.Error("ListTransferOrders: scan failed", err, nil)
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *postgresWarehouseRepository) DispatchTransferStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) error {
+	query := `UPDATE product_stocks
+              SET quantity = quantity - $1, reserved_quantity = reserved_quantity - $1, updated_at = NOW()
+              WHERE warehouse_id = $2 AND product_id = $3
+                AND (reserved_quantity - $1) >= 0
+                AND (quantity - $1) >= 0
+              RETURNING quantity, reserved_quantity`
+	var qty, reserved int
+	err := dbops.QueryRowContext(ctx, query, quantity, warehouseID, productID).Scan(&qty, &reserved)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInsufficientStock // Unit yang di-pick tidak lagi tercatat sebagai reserved
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
+			logger.Error("DispatchTransferStock: check violation", err, nil)
+			return ErrUpdateStockOutOfBounds
+		}
+		logger.Error("DispatchTransferStock: exec failed", err, nil)
+		return err
+	}
+	movement := domain.NewStockMovement(domain.MovementTransferOut, warehouseID, productID, -quantity, -quantity, qty, reserved, ref)
+	return insertStockMovement(ctx, dbops, movement)
+}
+
+func (r *postgresWarehouseRepository) ReceiveTransferStock(ctx context.Context, dbops DBTX, warehouseID, productID string, quantity int, ref domain.MovementRef) (*domain.ProductStock, error) {
+	return addStockQuantity(ctx, dbops, "ReceiveTransferStock", domain.MovementTransferIn, warehouseID, productID, quantity, ref)
+}
+
+func (r *postgresWarehouseRepository) GetInTransitQuantities(ctx context.Context, productID string) (map[string]int, error) {
+	query := `SELECT target_warehouse_id, SUM(quantity) FROM transfer_orders
+              WHERE product_id = $1 AND status = $2
+              GROUP BY target_warehouse_id`
+	rows, err := r.db.QueryContext(ctx, query, productID, domain.TransferOrderStatusInTransit)
+	if err != nil {
+		logger.Error("GetInTransitQuantities: query failed", err, map[string]interface{}{"product_id": productID})
+		return nil, err
+	}
+	defer rows.Close()
+
+	inTransit := map[string]int{}
+	for rows.Next() {
+		var warehouseID string
+		var quantity int
+		if err := rows.Scan(&warehouseID, &quantity); err != nil {
+			logger.Error("GetInTransitQuantities: scan failed", err, nil)
+			return nil, err
+		}
+		inTransit[warehouseID] = quantity
+	}
+	return inTransit, rows.Err()
+}
+
+// --- Stock Threshold & Alert Methods ---
+const stockThresholdColumns = `warehouse_id, product_id, reorder_point, safety_stock, reorder_quantity,
+       COALESCE(last_alert_level, ''), updated_by, created_at, updated_at`
+
+func scanStockThreshold(row rowScanner, t *domain.StockThreshold) error {
+	return row.Scan(&t.WarehouseID, &t.ProductID, &t.ReorderPoint, &t.SafetyStock, &t.ReorderQuantity,
+		&t.LastAlertLevel, &t.UpdatedBy, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *postgresWarehouseRepository) UpsertStockThreshold(ctx context.Context, threshold *domain.StockThreshold) error {
+	query := `INSERT INTO stock_thresholds (warehouse_id, product_id, reorder_point, safety_stock, reorder_quantity, updated_by)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              ON CONFLICT (warehouse_id, product_id)
+              DO UPDATE SET reorder_point = EXCLUDED.reorder_point, safety_stock = EXCLUDED.safety_stock,
+                            reorder_quantity = EXCLUDED.reorder_quantity, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+              RETURNING COALESCE(last_alert_level, ''), created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, threshold.WarehouseID, threshold.ProductID, threshold.ReorderPoint,
+		threshold.SafetyStock, threshold.ReorderQuantity, threshold.UpdatedBy).
+		Scan(&threshold.LastAlertLevel, &threshold.CreatedAt, &threshold.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return ErrWarehouseNotFound
+		}
+		logger.Error("UpsertStockThreshold: upsert failed", err, map[string]interface{}{
+			"warehouse_id": threshold.WarehouseID, "product_id": threshold.ProductID,
+		})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetStockThreshold(ctx context.Context, warehouseID, productID string) (*domain.StockThreshold, error) {
+	query := `SELECT ` + stockThresholdColumns + ` FROM stock_thresholds WHERE warehouse_id = $1 AND product_id = $2`
+	var t domain.StockThreshold
+	if err := scanStockThreshold(r.db.QueryRowContext(ctx, query, warehouseID, productID), &t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockThresholdNotFound
+		}
+		logger.Error("GetStockThreshold: query failed", err, nil)
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *postgresWarehouseRepository) GetStockThresholdForUpdate(ctx context.Context, dbops DBTX, warehouseID, productID string) (*domain.StockThreshold, error) {
+	query := `SELECT ` + stockThresholdColumns + ` FROM stock_thresholds WHERE warehouse_id = $1 AND product_id = $2 FOR UPDATE`
+	var t domain.StockThreshold
+	if err := scanStockThreshold(dbops.QueryRowContext(ctx, query, warehouseID, productID), &t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockThresholdNotFound
+		}
+		logger.Error("GetStockThresholdForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *postgresWarehouseRepository) UpdateStockThresholdAlertLevel(ctx context.Context, dbops DBTX, warehouseID, productID string, level domain.StockAlertLevel) error {
+	query := `UPDATE stock_thresholds SET last_alert_level = $1 WHERE warehouse_id = $2 AND product_id = $3`
+	result, err := dbops.ExecContext(ctx, query, nullString(string(level)), warehouseID, productID)
+	if err != nil {
+		logger.Error("UpdateStockThresholdAlertLevel: update failed", err, nil)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrStockThresholdNotFound
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) DeleteStockThreshold(ctx context.Context, dbops DBTX, warehouseID, productID string) error {
+	result, err := dbops.ExecContext(ctx, `DELETE FROM stock_thresholds WHERE warehouse_id = $1 AND product_id = $2`, warehouseID, productID)
+	if err != nil {
+		logger.Error("DeleteStockThreshold: delete failed", err, nil)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrStockThresholdNotFound
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListStockThresholds(ctx context.Context, warehouseID string) ([]domain.StockThreshold, error) {
+	query := `SELECT ` + stockThresholdColumns + ` FROM stock_thresholds st
+              WHERE ($1 = '' OR st.warehouse_id::text = $1)
+                AND EXISTS (SELECT 1 FROM warehouses w WHERE w.id = st.warehouse_id AND ($1 <> '' OR w.deleted_at IS NULL))
+              ORDER BY st.warehouse_id, st.product_id`
+	rows, err := r.db.QueryContext(ctx, query, warehouseID)
+	if err != nil {
+		logger.Error("ListStockThresholds: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	thresholds := []domain.StockThreshold{}
+	for rows.Next() {
+		var t domain.StockThreshold
+		if err := scanStockThreshold(rows, &t); err != nil {
+			logger.Error("ListStockThresholds: scan failed", err, nil)
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, rows.Err()
+}
+
+func (r *postgresWarehouseRepository) GetTotalSellableStockByProductID(ctx context.Context, productID string) (int, error) {
+	// Safety stock satu gudang tidak bisa ditutup oleh kelebihan stok gudang lain, sehingga dikurangi per gudang
+	query := `
+        SELECT COALESCE(SUM(GREATEST(ps.quantity - ps.reserved_quantity - COALESCE(st.safety_stock, 0), 0)), 0)
+        FROM product_stocks ps
+        JOIN warehouses w ON ps.warehouse_id = w.id
+        LEFT JOIN stock_thresholds st ON st.warehouse_id = ps.warehouse_id AND st.product_id = ps.product_id
+        WHERE ps.product_id = $1 AND w.is_active = TRUE`
+	var totalSellable int
+	if err := r.db.QueryRowContext(ctx, query, productID).Scan(&totalSellable); err != nil {
+		logger.Error("GetTotalSellableStockByProductID: query failed for product_id "+productID, err, nil)
+		return 0, err
+	}
+	return totalSellable, nil
+}
+
+func (r *postgresWarehouseRepository) GetLatestStockMovementID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM stock_movements`).Scan(&id); err != nil {
+		logger.Error("GetLatestStockMovementID: query failed", err, nil)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *postgresWarehouseRepository) ListStockKeysChangedSince(ctx context.Context, afterMovementID int64, limit int) ([]domain.StockKey, int64, error) {
+	query := `SELECT id, warehouse_id, product_id FROM stock_movements WHERE id > $1 ORDER BY id LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterMovementID, limit)
+	if err != nil {
+		logger.Error("ListStockKeysChangedSince: query failed", err, nil)
+		return nil, afterMovementID, err
+	}
+	defer rows.Close()
+
+	lastID := afterMovementID
+	seen := map[domain.StockKey]bool{}
+	keys := []domain.StockKey{}
+	for rows.Next() {
+		var key domain.StockKey
+		if err := rows.Scan(&lastID, &key.WarehouseID, &key.ProductID); err != nil {
+			logger.Error("ListStockKeysChangedSince: scan failed", err, nil)
+			return nil, afterMovementID, err
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterMovementID, err
+	}
+	return keys, lastID, nil
+}
+
+const stockAlertColumns = `id, warehouse_id, product_id, level, status, available_quantity, reorder_point, safety_stock,
+       reorder_quantity, COALESCE(acknowledged_by, ''), acknowledged_at, COALESCE(resolved_by, ''), resolved_at,
+       COALESCE(resolution_note, ''), created_at, updated_at`
+
+func scanStockAlert(row rowScanner, a *domain.StockAlert) error {
+	var acknowledgedAt, resolvedAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.WarehouseID, &a.ProductID, &a.Level, &a.Status, &a.AvailableQuantity, &a.ReorderPoint,
+		&a.SafetyStock, &a.ReorderQuantity, &a.AcknowledgedBy, &acknowledgedAt, &a.ResolvedBy, &resolvedAt,
+		&a.ResolutionNote, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return err
+	}
+	a.AcknowledgedAt = nullTimePtr(acknowledgedAt)
+	a.ResolvedAt = nullTimePtr(resolvedAt)
+	return nil
+}
+
+func (r *postgresWarehouseRepository) CreateStockAlert(ctx context.Context, dbops DBTX, alert *domain.StockAlert) error {
+	query := `INSERT INTO stock_alerts (warehouse_id, product_id, level, status, available_quantity, reorder_point,
+                                        safety_stock, reorder_quantity)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+              RETURNING id, created_at, updated_at`
+	err := dbops.QueryRowContext(ctx, query, alert.WarehouseID, alert.ProductID, alert.Level, alert.Status,
+		alert.AvailableQuantity, alert.ReorderPoint, alert.SafetyStock, alert.ReorderQuantity).
+		Scan(&alert.ID, &alert.CreatedAt, &alert.UpdatedAt)
+	if err != nil {
+		logger.Error("CreateStockAlert: insert failed", err, map[string]interface{}{
+			"warehouse_id": alert.WarehouseID, "product_id": alert.ProductID,
+		})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) GetStockAlertByID(ctx context.Context, id string) (*domain.StockAlert, error) {
+	query := `SELECT ` + stockAlertColumns + ` FROM stock_alerts WHERE id = $1`
+	var a domain.StockAlert
+	if err := scanStockAlert(r.db.QueryRowContext(ctx, query, id), &a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockAlertNotFound
+		}
+		logger.Error("GetStockAlertByID: query failed", err, nil)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *postgresWarehouseRepository) GetStockAlertForUpdate(ctx context.Context, dbops DBTX, id string) (*domain.StockAlert, error) {
+	query := `SELECT ` + stockAlertColumns + ` FROM stock_alerts WHERE id = $1 FOR UPDATE`
+	var a domain.StockAlert
+	if err := scanStockAlert(dbops.QueryRowContext(ctx, query, id), &a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockAlertNotFound
+		}
+		logger.Error("GetStockAlertForUpdate: query failed", err, nil)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *postgresWarehouseRepository) GetUnresolvedStockAlert(ctx context.Context, dbops DBTX, warehouseID, productID string) (*domain.StockAlert, error) {
+	query := `SELECT ` + stockAlertColumns + ` FROM stock_alerts
+              WHERE warehouse_id = $1 AND product_id = $2 AND status <> $3
+              FOR UPDATE`
+	var a domain.StockAlert
+	err := scanStockAlert(dbops.QueryRowContext(ctx, query, warehouseID, productID, domain.StockAlertStatusResolved), &a)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockAlertNotFound
+		}
+		logger.Error("GetUnresolvedStockAlert: query failed", err, nil)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *postgresWarehouseRepository) UpdateStockAlert(ctx context.Context, dbops DBTX, alert *domain.StockAlert) error {
+	query := `UPDATE stock_alerts
+              SET level = $1, status = $2, available_quantity = $3, reorder_point = $4, safety_stock = $5,
+                  reorder_quantity = $6, acknowledged_by = $7, acknowledged_at = $8, resolved_by = $9,
+                  resolved_at = $10, resolution_note = $11, updated_at = NOW()
+              WHERE id = $12
+              RETURNING updated_at`
+	err := dbops.QueryRowContext(ctx, query, alert.Level, alert.Status, alert.AvailableQuantity, alert.ReorderPoint,
+		alert.SafetyStock, alert.ReorderQuantity, nullString(alert.AcknowledgedBy), alert.AcknowledgedAt,
+		nullString(alert.ResolvedBy), alert.ResolvedAt, nullString(alert.ResolutionNote), alert.ID).Scan(&alert.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockAlertNotFound
+		}
+		logger.Error("UpdateStockAlert: update failed", err, map[string]interface{}{"stock_alert_id": alert.ID})
+		return err
+	}
+	return nil
+}
+
+func (r *postgresWarehouseRepository) ListStockAlerts(ctx context.Context, filter domain.ListStockAlertsFilter) ([]domain.StockAlert, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.WarehouseID != "" {
+		args = append(args, filter.WarehouseID)
+		conditions = append(conditions, fmt.Sprintf("warehouse_id = $%d", len(args)))
+	}
+	if filter.ProductID != "" {
+		args = append(args, filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("product_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + stockAlertColumns + ` FROM stock_alerts
+              WHERE ` + strings.Join(conditions, " AND ") + `
+              ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("ListStockAlerts: query failed", err, nil)
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []domain.StockAlert{}
+	for rows.Next() {
+		var a domain.StockAlert
+		if err := scanStockAlert(rows, &a); err != nil {
+			logger.Error("ListStockAlerts: scan failed", err, nil)
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
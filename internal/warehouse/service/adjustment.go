@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+var (
+	ErrInvalidAdjustment       = errors.New("invalid stock adjustment")
+	ErrAdjustmentBelowReserved = errors.New("adjustment would drop quantity below reserved quantity")
+	ErrAdjustmentNotPending    = errors.New("stock adjustment is not pending approval")
+	ErrAdjustmentSelfApproval  = errors.New("stock adjustment must be approved by someone other than the requester")
+)
+
+// requiresApproval bernilai true jika penyesuaian melewati ambang batas persetujuan (0 berarti tanpa persetujuan).
+func (s *warehouseServiceImpl) requiresApproval(delta int) bool {
+	if delta < 0 {
+		delta = -delta
+	}
+	return s.adjustmentApprovalThreshold > 0 && delta > s.adjustmentApprovalThreshold
+}
+
+// checkAdjustmentBounds memastikan penyesuaian tidak membuat quantity lebih kecil dari reserved_quantity,
+// sehingga reservasi yang sudah ada tetap bisa di-commit.
+func checkAdjustmentBounds(stock *domain.ProductStock, delta int) error {
+	if stock.Quantity+delta < stock.ReservedQuantity {
+		return fmt.Errorf("%w: quantity %d, reserved %d, delta %d",
+			ErrAdjustmentBelowReserved, stock.Quantity, stock.ReservedQuantity, delta)
+	}
+	return nil
+}
+
+// CreateStockAdjustment langsung menerapkan penyesuaian di bawah ambang batas. Penyesuaian di atasnya
+// disimpan sebagai PENDING_APPROVAL dan baru mengubah stok setelah disetujui orang lain.
+func (s *warehouseServiceImpl) CreateStockAdjustment(ctx context.Context, warehouseID string, req domain.CreateStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error) {
+	if !req.ReasonCode.AllowsDelta(req.QuantityDelta) {
+		return nil, fmt.Errorf("%w: quantity_delta %d is not allowed for reason %s", ErrInvalidAdjustment, req.QuantityDelta, req.ReasonCode)
+	}
+	if _, err := s.getLiveWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.CreateStockAdjustment: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	stock, err := s.repo.GetProductStockForUpdate(ctx, tx, warehouseID, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	// Penyesuaian yang menunggu persetujuan juga dicek sekarang agar pengaju langsung tahu jika tidak mungkin diterapkan
+	if err := checkAdjustmentBounds(stock, req.QuantityDelta); err != nil {
+		return nil, err
+	}
+
+	adjustment := &domain.StockAdjustment{
+		WarehouseID:   warehouseID,
+		ProductID:     req.ProductID,
+		QuantityDelta: req.QuantityDelta,
+		ReasonCode:    req.ReasonCode,
+		Note:          req.Note,
+		Status:        domain.AdjustmentStatusPendingApproval,
+		RequestedBy:   actorFromContext(ctx),
+	}
+	approvalRequired := s.requiresApproval(req.QuantityDelta)
+	if !approvalRequired {
+		now := time.Now()
+		adjustment.Status = domain.AdjustmentStatusApplied
+		adjustment.AppliedAt = &now
+	}
+	if err := s.repo.CreateStockAdjustment(ctx, tx, adjustment); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	if !approvalRequired {
+		if err := s.applyAdjustment(ctx, tx, adjustment, stock); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.CreateStockAdjustment: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	if approvalRequired {
+		logger.Info(fmt.Sprintf("Svc.CreateStockAdjustment: adjustment %s (%+d of product %s in warehouse %s) awaits approval",
+			adjustment.ID, adjustment.QuantityDelta, adjustment.ProductID, warehouseID))
+		return &domain.StockAdjustmentResponse{Adjustment: adjustment}, nil
+	}
+	return &domain.StockAdjustmentResponse{Adjustment: adjustment, Stock: stock}, nil
+}
+
+// applyAdjustment mengubah quantity pada baris stok yang sudah dikunci dan memperbarui stock sesuai hasilnya.
+func (s *warehouseServiceImpl) applyAdjustment(ctx context.Context, tx repository.DBTX, adjustment *domain.StockAdjustment, stock *domain.ProductStock) error {
+	ref := movementRef(ctx, domain.MovementRefAdjustment, adjustment.ID, adjustment.MovementReason())
+	var err error
+	if adjustment.QuantityDelta > 0 {
+		err = s.repo.IncreaseProductStockQuantity(ctx, tx, adjustment.WarehouseID, adjustment.ProductID, adjustment.QuantityDelta, ref)
+	} else {
+		err = s.repo.DecreaseProductStockQuantity(ctx, tx, adjustment.WarehouseID, adjustment.ProductID, -adjustment.QuantityDelta, ref)
+	}
+	if err != nil {
+		logger.Error("Svc.applyAdjustment: stock update failed", err, map[string]interface{}{"adjustment_id": adjustment.ID})
+		return err
+	}
+	stock.Quantity += adjustment.QuantityDelta
+	return nil
+}
+
+// ApproveStockAdjustment menerapkan penyesuaian yang menunggu persetujuan. Penyetuju harus berbeda dari pengaju,
+// dan batas reserved_quantity dicek ulang karena stok bisa berubah sejak penyesuaian diajukan.
+func (s *warehouseServiceImpl) ApproveStockAdjustment(ctx context.Context, warehouseID, adjustmentID string, req domain.ReviewStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error) {
+	if _, err := s.getLiveWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.ApproveStockAdjustment: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	adjustment, err := s.pendingAdjustmentForUpdate(ctx, tx, warehouseID, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	approver := actorFromContext(ctx)
+	if approver == adjustment.RequestedBy {
+		return nil, fmt.Errorf("%w: %s", ErrAdjustmentSelfApproval, approver)
+	}
+	stock, err := s.repo.GetProductStockForUpdate(ctx, tx, warehouseID, adjustment.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAdjustmentBounds(stock, adjustment.QuantityDelta); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	adjustment.Status = domain.AdjustmentStatusApplied
+	adjustment.ReviewedBy = approver
+	adjustment.ReviewNote = req.Note
+	adjustment.ReviewedAt = &now
+	adjustment.AppliedAt = &now
+	if err := s.repo.UpdateStockAdjustmentReview(ctx, tx, adjustment); err != nil {
+		return nil, err
+	}
+	if err := s.applyAdjustment(ctx, tx, adjustment, stock); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.ApproveStockAdjustment: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return &domain.StockAdjustmentResponse{Adjustment: adjustment, Stock: stock}, nil
+}
+
+// RejectStockAdjustment menutup penyesuaian yang menunggu persetujuan tanpa mengubah stok.
+// Pengaju boleh menolak penyesuaiannya sendiri (membatalkan).
+func (s *warehouseServiceImpl) RejectStockAdjustment(ctx context.Context, warehouseID, adjustmentID string, req domain.ReviewStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.RejectStockAdjustment: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	adjustment, err := s.pendingAdjustmentForUpdate(ctx, tx, warehouseID, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	adjustment.Status = domain.AdjustmentStatusRejected
+	adjustment.ReviewedBy = actorFromContext(ctx)
+	adjustment.ReviewNote = req.Note
+	adjustment.ReviewedAt = &now
+	if err := s.repo.UpdateStockAdjustmentReview(ctx, tx, adjustment); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.RejectStockAdjustment: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return &domain.StockAdjustmentResponse{Adjustment: adjustment}, nil
+}
+
+func (s *warehouseServiceImpl) pendingAdjustmentForUpdate(ctx context.Context, tx repository.DBTX, warehouseID, adjustmentID string) (*domain.StockAdjustment, error) {
+	adjustment, err := s.repo.GetStockAdjustmentForUpdate(ctx, tx, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	if adjustment.WarehouseID != warehouseID {
+		return nil, repository.ErrStockAdjustmentNotFound
+	}
+	if adjustment.Status != domain.AdjustmentStatusPendingApproval {
+		return nil, fmt.Errorf("%w: %s is %s", ErrAdjustmentNotPending, adjustmentID, adjustment.Status)
+	}
+	return adjustment, nil
+}
+
+func (s *warehouseServiceImpl) GetStockAdjustment(ctx context.Context, warehouseID, adjustmentID string) (*domain.StockAdjustment, error) {
+	adjustment, err := s.repo.GetStockAdjustmentByID(ctx, adjustmentID)
+	if err != nil {
+		return nil, err
+	}
+	if adjustment.WarehouseID != warehouseID {
+		return nil, repository.ErrStockAdjustmentNotFound
+	}
+	return adjustment, nil
+}
+
+func (s *warehouseServiceImpl) ListStockAdjustments(ctx context.Context, warehouseID string, status domain.AdjustmentStatus) ([]domain.StockAdjustment, error) {
+	switch status {
+	case "", domain.AdjustmentStatusPendingApproval, domain.AdjustmentStatusApplied, domain.AdjustmentStatusRejected:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidAdjustment, status)
+	}
+	if _, err := s.repo.GetWarehouseByID(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListStockAdjustments(ctx, warehouseID, status)
+}
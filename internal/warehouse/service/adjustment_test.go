@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWarehouseService_CreateStockAdjustment(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	liveWarehouse := &domain.Warehouse{ID: "wh1", IsActive: true}
+
+	t.Run("Small adjustment is applied immediately", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseServiceWithConfig(mockRepo, singleWarehouseAllocation{}, 10)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prodA", Quantity: 10, ReservedQuantity: 2}, nil).Once()
+		mockRepo.On("CreateStockAdjustment", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAdjustment) bool {
+			return a.Status == domain.AdjustmentStatusApplied && a.RequestedBy == "admin:user-1" && a.AppliedAt != nil
+		})).Run(func(args mock.Arguments) {
+			args.Get(2).(*domain.StockAdjustment).ID = "adj-1"
+		}).Return(nil).Once()
+		mockRepo.On("DecreaseProductStockQuantity", ctx, mockTx, "wh1", "prodA", 3, domain.MovementRef{
+			ReferenceType: domain.MovementRefAdjustment, ReferenceID: "adj-1", Actor: "admin:user-1", Reason: "DAMAGE: dropped pallet",
+		}).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.CreateStockAdjustment(ctx, "wh1", domain.CreateStockAdjustmentRequest{
+			ProductID: "prodA", QuantityDelta: -3, ReasonCode: domain.AdjustmentReasonDamage, Note: "dropped pallet",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AdjustmentStatusApplied, resp.Adjustment.Status)
+		assert.Equal(t, 7, resp.Stock.Quantity)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Adjustment above the threshold waits for approval", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseServiceWithConfig(mockRepo, singleWarehouseAllocation{}, 10)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{Quantity: 5, ReservedQuantity: 0}, nil).Once()
+		mockRepo.On("CreateStockAdjustment", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAdjustment) bool {
+			return a.Status == domain.AdjustmentStatusPendingApproval && a.AppliedAt == nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.CreateStockAdjustment(ctx, "wh1", domain.CreateStockAdjustmentRequest{
+			ProductID: "prodA", QuantityDelta: 25, ReasonCode: domain.AdjustmentReasonFound,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AdjustmentStatusPendingApproval, resp.Adjustment.Status)
+		assert.Nil(t, resp.Stock)
+		mockRepo.AssertNotCalled(t, "IncreaseProductStockQuantity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Reason code must match the direction of the delta", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		_, err := service.CreateStockAdjustment(ctx, "wh1", domain.CreateStockAdjustmentRequest{
+			ProductID: "prodA", QuantityDelta: -2, ReasonCode: domain.AdjustmentReasonFound,
+		})
+		assert.ErrorIs(t, err, ErrInvalidAdjustment)
+		mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+	})
+
+	t.Run("Adjustment cannot drop quantity below reserved", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{Quantity: 6, ReservedQuantity: 3}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.CreateStockAdjustment(ctx, "wh1", domain.CreateStockAdjustmentRequest{
+			ProductID: "prodA", QuantityDelta: -4, ReasonCode: domain.AdjustmentReasonCountCorrection,
+		})
+		assert.ErrorIs(t, err, ErrAdjustmentBelowReserved)
+		mockRepo.AssertNotCalled(t, "CreateStockAdjustment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Decommissioned warehouse", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		deletedAt := time.Now()
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1", DeletedAt: &deletedAt}, nil).Once()
+
+		_, err := service.CreateStockAdjustment(ctx, "wh1", domain.CreateStockAdjustmentRequest{
+			ProductID: "prodA", QuantityDelta: 1, ReasonCode: domain.AdjustmentReasonFound,
+		})
+		assert.ErrorIs(t, err, ErrWarehouseDecommissioned)
+	})
+}
+
+func TestWarehouseService_ReviewStockAdjustment(t *testing.T) {
+	approverCtx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-2", Role: auth.RoleAdmin})
+	liveWarehouse := &domain.Warehouse{ID: "wh1", IsActive: true}
+	pending := func() *domain.StockAdjustment {
+		return &domain.StockAdjustment{
+			ID: "adj-1", WarehouseID: "wh1", ProductID: "prodA", QuantityDelta: -20,
+			ReasonCode: domain.AdjustmentReasonShrinkage, Status: domain.AdjustmentStatusPendingApproval, RequestedBy: "admin:user-1",
+		}
+	}
+
+	t.Run("Second admin approves and the stock changes", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseServiceWithConfig(mockRepo, singleWarehouseAllocation{}, 10)
+
+		mockRepo.On("GetWarehouseByID", approverCtx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", approverCtx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAdjustmentForUpdate", approverCtx, mockTx, "adj-1").Return(pending(), nil).Once()
+		mockRepo.On("GetProductStockForUpdate", approverCtx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{Quantity: 50, ReservedQuantity: 10}, nil).Once()
+		mockRepo.On("UpdateStockAdjustmentReview", approverCtx, mockTx, mock.MatchedBy(func(a *domain.StockAdjustment) bool {
+			return a.Status == domain.AdjustmentStatusApplied && a.ReviewedBy == "admin:user-2" && a.ReviewNote == "confirmed by audit"
+		})).Return(nil).Once()
+		mockRepo.On("DecreaseProductStockQuantity", approverCtx, mockTx, "wh1", "prodA", 20, domain.MovementRef{
+			ReferenceType: domain.MovementRefAdjustment, ReferenceID: "adj-1", Actor: "admin:user-2", Reason: "SHRINKAGE",
+		}).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.ApproveStockAdjustment(approverCtx, "wh1", "adj-1", domain.ReviewStockAdjustmentRequest{Note: "confirmed by audit"})
+		assert.NoError(t, err)
+		assert.Equal(t, 30, resp.Stock.Quantity)
+		assert.NotNil(t, resp.Adjustment.AppliedAt)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Requester cannot approve their own adjustment", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+		requesterCtx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+
+		mockRepo.On("GetWarehouseByID", requesterCtx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", requesterCtx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAdjustmentForUpdate", requesterCtx, mockTx, "adj-1").Return(pending(), nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ApproveStockAdjustment(requesterCtx, "wh1", "adj-1", domain.ReviewStockAdjustmentRequest{})
+		assert.ErrorIs(t, err, ErrAdjustmentSelfApproval)
+		mockRepo.AssertNotCalled(t, "UpdateStockAdjustmentReview", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Guardrail is checked again at approval time", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", approverCtx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", approverCtx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAdjustmentForUpdate", approverCtx, mockTx, "adj-1").Return(pending(), nil).Once()
+		// Sejak diajukan, reservasi baru membuat pengurangan 20 unit tidak lagi mungkin
+		mockRepo.On("GetProductStockForUpdate", approverCtx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{Quantity: 50, ReservedQuantity: 35}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ApproveStockAdjustment(approverCtx, "wh1", "adj-1", domain.ReviewStockAdjustmentRequest{})
+		assert.ErrorIs(t, err, ErrAdjustmentBelowReserved)
+		mockRepo.AssertNotCalled(t, "UpdateStockAdjustmentReview", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Already reviewed adjustment", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+		rejected := pending()
+		rejected.Status = domain.AdjustmentStatusRejected
+
+		mockRepo.On("BeginTx", approverCtx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAdjustmentForUpdate", approverCtx, mockTx, "adj-1").Return(rejected, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.RejectStockAdjustment(approverCtx, "wh1", "adj-1", domain.ReviewStockAdjustmentRequest{})
+		assert.ErrorIs(t, err, ErrAdjustmentNotPending)
+	})
+
+	t.Run("Reject leaves the stock untouched", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", approverCtx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAdjustmentForUpdate", approverCtx, mockTx, "adj-1").Return(pending(), nil).Once()
+		mockRepo.On("UpdateStockAdjustmentReview", approverCtx, mockTx, mock.MatchedBy(func(a *domain.StockAdjustment) bool {
+			return a.Status == domain.AdjustmentStatusRejected && a.AppliedAt == nil && a.ReviewedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.RejectStockAdjustment(approverCtx, "wh1", "adj-1", domain.ReviewStockAdjustmentRequest{Note: "recount found the units"})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.AdjustmentStatusRejected, resp.Adjustment.Status)
+		assert.Nil(t, resp.Stock)
+		mockRepo.AssertNotCalled(t, "DecreaseProductStockQuantity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAdjustmentReasonCode_AllowsDelta(t *testing.T) {
+	assert.True(t, domain.AdjustmentReasonDamage.AllowsDelta(-1))
+	assert.False(t, domain.AdjustmentReasonShrinkage.AllowsDelta(1))
+	assert.True(t, domain.AdjustmentReasonFound.AllowsDelta(1))
+	assert.True(t, domain.AdjustmentReasonCountCorrection.AllowsDelta(-1))
+	assert.True(t, domain.AdjustmentReasonCountCorrection.AllowsDelta(1))
+	assert.False(t, domain.AdjustmentReasonCountCorrection.AllowsDelta(0))
+	assert.False(t, domain.AdjustmentReasonCode("THEFT").AllowsDelta(-1))
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+var ErrInvalidAllocationRequest = errors.New("invalid allocation request")
+
+// StockAvailability adalah stok tersedia (quantity - reserved_quantity) per gudang lalu per produk.
+type StockAvailability map[string]map[string]int
+
+func (a StockAvailability) clone() StockAvailability {
+	out := make(StockAvailability, len(a))
+	for warehouseID, products := range a {
+		out[warehouseID] = copyCounts(products)
+	}
+	return out
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	out := make(map[string]int, len(counts))
+	for key, qty := range counts {
+		out[key] = qty
+	}
+	return out
+}
+
+// Allocation adalah bagian satu baris reservasi yang diambil dari satu gudang.
+type Allocation struct {
+	WarehouseID string
+	Quantity    int
+}
+
+// AllocationRequest adalah input strategi alokasi. Warehouses hanya berisi gudang aktif (urutan nama),
+// Available dibaca dari baris stok yang sudah dikunci, dan total stok setiap produk sudah dipastikan cukup.
+type AllocationRequest struct {
+	Warehouses []domain.Warehouse
+	Available  StockAvailability
+	Items      []domain.ReserveStockBatchItem
+	ShipTo     *domain.GeoPoint // Alamat pengiriman; hanya dipakai strategi nearest
+}
+
+// AllocationStrategy menentukan dari gudang mana setiap baris reservasi diambil.
+// Allocate mengembalikan alokasi per baris sesuai urutan Items dan tidak boleh mengubah req.Available.
+type AllocationStrategy interface {
+	Name() domain.AllocationStrategyName
+	Allocate(req AllocationRequest) ([][]Allocation, error)
+}
+
+// NewAllocationStrategy mengembalikan strategi alokasi berdasarkan nama (misal dari konfigurasi).
+func NewAllocationStrategy(name domain.AllocationStrategyName) (AllocationStrategy, error) {
+	switch name {
+	case domain.AllocationPriority:
+		return priorityAllocation{}, nil
+	case domain.AllocationNearest:
+		return nearestAllocation{}, nil
+	case domain.AllocationSingleWarehouse:
+		return singleWarehouseAllocation{}, nil
+	case domain.AllocationBalancedDepletion:
+		return balancedDepletionAllocation{}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown allocation strategy %q", ErrInvalidAllocationRequest, name)
+}
+
+// strategyFor memilih strategi request, atau strategi default service jika request tidak menentukannya.
+func (s *warehouseServiceImpl) strategyFor(opts domain.AllocationOptions) (AllocationStrategy, error) {
+	if opts.AllocationStrategy == "" {
+		return s.allocation, nil
+	}
+	if opts.AllocationStrategy == domain.AllocationNearest && opts.ShipTo == nil {
+		return nil, fmt.Errorf("%w: ship_to is required for the nearest strategy", ErrInvalidAllocationRequest)
+	}
+	return NewAllocationStrategy(opts.AllocationStrategy)
+}
+
+// priorityAllocation mengambil stok dari gudang berurutan sesuai Priority (seri: urutan nama),
+// menghabiskan stok gudang pertama sebelum pindah ke gudang berikutnya.
+type priorityAllocation struct{}
+
+func (priorityAllocation) Name() domain.AllocationStrategyName { return domain.AllocationPriority }
+
+func (priorityAllocation) Allocate(req AllocationRequest) ([][]Allocation, error) {
+	return fillInOrder(byPriority(req.Warehouses), req.Available, req.Items), nil
+}
+
+// nearestAllocation seperti priorityAllocation, tetapi gudang diurutkan dari yang terdekat ke ShipTo.
+// Gudang tanpa koordinat dipakai paling akhir. Tanpa ShipTo, urutan jatuh kembali ke prioritas.
+type nearestAllocation struct{}
+
+func (nearestAllocation) Name() domain.AllocationStrategyName { return domain.AllocationNearest }
+
+func (nearestAllocation) Allocate(req AllocationRequest) ([][]Allocation, error) {
+	warehouses := byPriority(req.Warehouses)
+	if req.ShipTo != nil {
+		distance := func(wh domain.Warehouse) (float64, bool) {
+			point, ok := wh.Coordinates()
+			if !ok {
+				return 0, false
+			}
+			return domain.DistanceKm(*req.ShipTo, point), true
+		}
+		sort.SliceStable(warehouses, func(i, j int) bool {
+			di, okI := distance(warehouses[i])
+			dj, okJ := distance(warehouses[j])
+			if okI != okJ {
+				return okI
+			}
+			return di < dj
+		})
+	}
+	return fillInOrder(warehouses, req.Available, req.Items), nil
+}
+
+// singleWarehouseAllocation meminimalkan jumlah gudang (= jumlah shipment), lihat planFulfillment.
+// Gudang dengan prioritas lebih tinggi memenangkan seri.
+type singleWarehouseAllocation struct{}
+
+func (singleWarehouseAllocation) Name() domain.AllocationStrategyName {
+	return domain.AllocationSingleWarehouse
+}
+
+func (singleWarehouseAllocation) Allocate(req AllocationRequest) ([][]Allocation, error) {
+	return planFulfillment(byPriority(req.Warehouses), req.Available, req.Items), nil
+}
+
+// balancedDepletionAllocation mengambil setiap baris dari gudang yang stok produknya paling banyak tersisa,
+// sehingga stok antar gudang habis secara merata. Jika tidak ada gudang yang cukup, baris dipecah mulai dari
+// gudang dengan stok terbanyak. Seri dipecah dengan prioritas gudang.
+type balancedDepletionAllocation struct{}
+
+func (balancedDepletionAllocation) Name() domain.AllocationStrategyName {
+	return domain.AllocationBalancedDepletion
+}
+
+func (balancedDepletionAllocation) Allocate(req AllocationRequest) ([][]Allocation, error) {
+	available := req.Available.clone()
+	plan := make([][]Allocation, len(req.Items))
+	for i, item := range req.Items {
+		warehouses := byPriority(req.Warehouses)
+		sort.SliceStable(warehouses, func(a, b int) bool {
+			return available[warehouses[a].ID][item.ProductID] > available[warehouses[b].ID][item.ProductID]
+		})
+		plan[i] = fillInOrder(warehouses, available, req.Items[i:i+1])[0]
+		for _, alloc := range plan[i] {
+			available[alloc.WarehouseID][item.ProductID] -= alloc.Quantity
+		}
+	}
+	return plan, nil
+}
+
+// byPriority mengembalikan salinan warehouses yang diurutkan sesuai Priority; urutan awal dipertahankan untuk seri.
+func byPriority(warehouses []domain.Warehouse) []domain.Warehouse {
+	sorted := append([]domain.Warehouse(nil), warehouses...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+	return sorted
+}
+
+// fillInOrder mengambil setiap baris dari gudang-gudang sesuai urutan warehouses, sebanyak yang tersedia,
+// sampai baris terpenuhi. Baris dengan produk yang sama berbagi stok yang sama.
+func fillInOrder(warehouses []domain.Warehouse, available StockAvailability, items []domain.ReserveStockBatchItem) [][]Allocation {
+	available = available.clone()
+	plan := make([][]Allocation, len(items))
+	for i, item := range items {
+		remaining := item.Quantity
+		for _, wh := range warehouses {
+			if remaining == 0 {
+				break
+			}
+			qty := available[wh.ID][item.ProductID]
+			if qty > remaining {
+				qty = remaining
+			}
+			if qty <= 0 {
+				continue
+			}
+			available[wh.ID][item.ProductID] -= qty
+			remaining -= qty
+			plan[i] = append(plan[i], Allocation{WarehouseID: wh.ID, Quantity: qty})
+		}
+	}
+	return plan
+}
+
+// lockAvailability mengunci baris stok semua produk di gudang aktif dan membaca stok tersedianya.
+// Urutan penguncian selalu product_id lalu urutan gudang, agar dua reservasi dengan produk yang sama tidak saling deadlock.
+func (s *warehouseServiceImpl) lockAvailability(ctx context.Context, tx repository.DBTX, warehouses []domain.Warehouse, items []domain.ReserveStockBatchItem) (StockAvailability, error) {
+	productIDs := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	sort.Strings(productIDs)
+
+	available := StockAvailability{}
+	for _, productID := range productIDs {
+		for _, wh := range warehouses {
+			stockItem, err := s.repo.GetProductStockForUpdate(ctx, tx, wh.ID, productID)
+			if err != nil {
+				if errors.Is(err, repository.ErrProductStockNotFound) {
+					continue // Produk tidak ada di gudang ini
+				}
+				logger.Error("Svc.lockAvailability: GetProductStockForUpdate failed", err, fmt.Sprintf("WID: %s, PID: %s", wh.ID, productID))
+				return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+			}
+			if free := stockItem.Quantity - stockItem.ReservedQuantity; free > 0 {
+				if available[wh.ID] == nil {
+					available[wh.ID] = map[string]int{}
+				}
+				available[wh.ID][productID] = free
+			}
+		}
+	}
+	return available, nil
+}
+
+// firstShortItem mengembalikan indeks baris pertama yang tidak bisa dipenuhi dari total stok semua gudang,
+// atau -1 jika semua baris bisa dipenuhi. Baris dengan produk yang sama berbagi stok yang sama.
+func firstShortItem(available StockAvailability, items []domain.ReserveStockBatchItem) int {
+	totals := map[string]int{}
+	for _, products := range available {
+		for productID, qty := range products {
+			totals[productID] += qty
+		}
+	}
+	for i, item := range items {
+		if totals[item.ProductID] < item.Quantity {
+			return i
+		}
+		totals[item.ProductID] -= item.Quantity
+	}
+	return -1
+}
+
+// planFulfillment membagi baris-baris reservasi ke gudang dengan jumlah gudang (= jumlah shipment) sesedikit mungkin.
+// Secara greedy, gudang berikutnya adalah yang bisa memenuhi penuh baris tersisa terbanyak; seri dipecah dengan
+// jumlah unit yang bisa dipenuhi lalu urutan warehouses. Gudang terpilih hanya mengambil baris yang bisa dipenuhinya
+// penuh, sehingga satu baris tidak dipecah ke beberapa gudang kecuali memang tidak ada gudang yang stoknya cukup.
+// Untuk sisa baris seperti itu, gudang yang sudah terpilih didahulukan karena tidak menambah shipment.
+// Pemanggil harus sudah memastikan total stok cukup (lihat firstShortItem). Hasil berurutan sesuai items.
+func planFulfillment(warehouses []domain.Warehouse, available StockAvailability, items []domain.ReserveStockBatchItem) [][]Allocation {
+	remaining := make([]int, len(items))
+	open := 0
+	for i, item := range items {
+		remaining[i] = item.Quantity
+		if item.Quantity > 0 {
+			open++
+		}
+	}
+	available = available.clone()
+	used := map[string]bool{}
+	plan := make([][]Allocation, len(items))
+
+	// take mengambil stok dari satu gudang; partial=false hanya mengambil baris yang bisa dipenuhi penuh.
+	// Dengan apply=false hanya menghitung skor tanpa mengubah state.
+	take := func(warehouseID string, partial, apply bool) (fullLines, units int) {
+		stock := available[warehouseID]
+		if !apply {
+			stock = copyCounts(stock)
+		}
+		for i, item := range items {
+			if remaining[i] == 0 {
+				continue
+			}
+			qty := remaining[i]
+			if stock[item.ProductID] < qty {
+				if !partial {
+					continue
+				}
+				qty = stock[item.ProductID]
+			}
+			if qty == 0 {
+				continue
+			}
+			stock[item.ProductID] -= qty
+			if qty == remaining[i] {
+				fullLines++
+			}
+			units += qty
+			if apply {
+				remaining[i] -= qty
+				plan[i] = append(plan[i], Allocation{WarehouseID: warehouseID, Quantity: qty})
+				if remaining[i] == 0 {
+					open--
+				}
+			}
+		}
+		return fullLines, units
+	}
+
+	for open > 0 {
+		best, bestFull, bestUnits := "", 0, 0
+		for _, wh := range warehouses {
+			full, units := take(wh.ID, false, false)
+			if full > bestFull || (full == bestFull && units > bestUnits) {
+				best, bestFull, bestUnits = wh.ID, full, units
+			}
+		}
+		if bestFull > 0 {
+			used[best] = true
+			take(best, false, true)
+			continue
+		}
+
+		// Tidak ada gudang yang bisa memenuhi penuh satu baris pun: pecah baris, dahulukan gudang yang sudah terpilih.
+		best, bestUsed, bestUnits := "", false, 0
+		for _, wh := range warehouses {
+			_, units := take(wh.ID, true, false)
+			if units == 0 {
+				continue
+			}
+			if best == "" || (used[wh.ID] && !bestUsed) || (used[wh.ID] == bestUsed && units > bestUnits) {
+				best, bestUsed, bestUnits = wh.ID, used[wh.ID], units
+			}
+		}
+		if best == "" {
+			break // Tidak terjadi jika total stok sudah dicek
+		}
+		used[best] = true
+		take(best, true, true)
+	}
+	return plan
+}
+
+// checkPlan memastikan hasil strategi memenuhi setiap baris tepat sesuai quantity tanpa melebihi stok tersedia,
+// agar strategi yang salah tidak pernah mereservasi lebih dari stok yang ada.
+func checkPlan(available StockAvailability, items []domain.ReserveStockBatchItem, plan [][]Allocation) error {
+	if len(plan) != len(items) {
+		return fmt.Errorf("%w: allocation plan has %d lines, expected %d", ErrStockOperationFailed, len(plan), len(items))
+	}
+	used := map[string]map[string]int{}
+	for i, item := range items {
+		total := 0
+		for _, alloc := range plan[i] {
+			if alloc.Quantity <= 0 {
+				return fmt.Errorf("%w: non-positive allocation for product_id %s", ErrStockOperationFailed, item.ProductID)
+			}
+			if used[alloc.WarehouseID] == nil {
+				used[alloc.WarehouseID] = map[string]int{}
+			}
+			used[alloc.WarehouseID][item.ProductID] += alloc.Quantity
+			if used[alloc.WarehouseID][item.ProductID] > available[alloc.WarehouseID][item.ProductID] {
+				return fmt.Errorf("%w: allocation exceeds available stock of product_id %s in warehouse %s", ErrStockOperationFailed, item.ProductID, alloc.WarehouseID)
+			}
+			total += alloc.Quantity
+		}
+		if total != item.Quantity {
+			return fmt.Errorf("%w: allocated %d of %d for product_id %s", ErrStockOperationFailed, total, item.Quantity, item.ProductID)
+		}
+	}
+	return nil
+}
+
+// applyAllocations menaikkan reserved_quantity dan mencatat satu stock_reservations per alokasi di dalam tx.
+// Commit/rollback menjadi tanggung jawab pemanggil.
+func (s *warehouseServiceImpl) applyAllocations(ctx context.Context, tx repository.DBTX, items []domain.ReserveStockBatchItem, plan [][]Allocation,
+	orderID *string, expiresAt time.Time) ([]domain.ReservationLine, error) {
+	lines := make([]domain.ReservationLine, len(items))
+	for i, item := range items {
+		reservations := []domain.StockReservation{}
+		for _, alloc := range plan[i] {
+			// Reservasi dibuat lebih dulu agar ID-nya bisa menjadi referensi movement RESERVE
+			reservation := domain.StockReservation{
+				OrderID:     orderID,
+				WarehouseID: alloc.WarehouseID,
+				ProductID:   item.ProductID,
+				Quantity:    alloc.Quantity,
+				Status:      domain.ReservationStatusActive,
+				ExpiresAt:   expiresAt,
+			}
+			if err := s.repo.CreateReservation(ctx, tx, &reservation); err != nil {
+				logger.Error("Svc.applyAllocations: CreateReservation failed", err, fmt.Sprintf("WID: %s, PID: %s", alloc.WarehouseID, item.ProductID))
+				return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+			}
+			ref := movementRef(ctx, domain.MovementRefReservation, reservation.ID, "")
+			if err := s.repo.IncreaseReservedStock(ctx, tx, alloc.WarehouseID, item.ProductID, alloc.Quantity, ref); err != nil {
+				logger.Error("Svc.applyAllocations: IncreaseReservedStock failed", err, fmt.Sprintf("WID: %s, PID: %s", alloc.WarehouseID, item.ProductID))
+				return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+			}
+			reservations = append(reservations, reservation)
+		}
+		lines[i] = domain.ReservationLine{ProductID: item.ProductID, Quantity: item.Quantity, Reservations: reservations}
+	}
+	return lines, nil
+}
+
+// activeWarehouses menyaring gudang nonaktif; urutan gudang dipertahankan.
+func activeWarehouses(warehouses []domain.Warehouse) []domain.Warehouse {
+	active := make([]domain.Warehouse, 0, len(warehouses))
+	for _, wh := range warehouses {
+		if wh.IsActive {
+			active = append(active, wh)
+		}
+	}
+	return active
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	whRepo "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func coord(v float64) *float64 { return &v }
+
+// strategyTestWarehouses berurutan sesuai nama, seperti hasil ListWarehouses.
+var strategyTestWarehouses = []domain.Warehouse{
+	{ID: "wh-bdg", Name: "Bandung", IsActive: true, Priority: 30, Latitude: coord(-6.9175), Longitude: coord(107.6191)},
+	{ID: "wh-jkt", Name: "Jakarta", IsActive: true, Priority: 10, Latitude: coord(-6.2088), Longitude: coord(106.8456)},
+	{ID: "wh-sby", Name: "Surabaya", IsActive: true, Priority: 20, Latitude: coord(-7.2575), Longitude: coord(112.7521)},
+	{ID: "wh-off", Name: "Zz Closed", IsActive: false, Priority: 0},
+}
+
+// strategyTestStock: quantity per gudang per produk; prod-2 tidak ada di Bandung.
+var strategyTestStock = map[string]map[string]int{
+	"wh-bdg": {"prod-1": 10},
+	"wh-jkt": {"prod-1": 2, "prod-2": 5},
+	"wh-sby": {"prod-1": 4, "prod-2": 6},
+}
+
+// expectStrategyReservation menyiapkan mock repository untuk satu ReserveStockBatch dan hasil alokasi yang diharapkan.
+func expectStrategyReservation(mockRepo *mocks.MockWarehouseRepository, mockTx *mocks.MockDBTX, ctx context.Context, expected map[string]map[string]int) {
+	mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+	mockRepo.On("ListWarehouses", ctx).Return(strategyTestWarehouses, nil).Once()
+	for _, productID := range []string{"prod-1", "prod-2"} {
+		for _, wh := range strategyTestWarehouses[:3] {
+			qty, ok := strategyTestStock[wh.ID][productID]
+			if !ok {
+				mockRepo.On("GetProductStockForUpdate", ctx, mockTx, wh.ID, productID).Return(nil, whRepo.ErrProductStockNotFound).Once()
+				continue
+			}
+			mockRepo.On("GetProductStockForUpdate", ctx, mockTx, wh.ID, productID).
+				Return(&domain.ProductStock{WarehouseID: wh.ID, ProductID: productID, Quantity: qty}, nil).Once()
+		}
+	}
+	reservations := 0
+	for warehouseID, products := range expected {
+		for productID, qty := range products {
+			mockRepo.On("IncreaseReservedStock", ctx, mockTx, warehouseID, productID, qty, reservationRef(warehouseID)).Return(nil).Once()
+			reservations++
+		}
+	}
+	mockRepo.On("CreateReservation", ctx, mockTx, mock.AnythingOfType("*domain.StockReservation")).Return(nil).Times(reservations)
+	mockTx.On("Commit").Return(nil).Once()
+	mockTx.On("Rollback").Return(nil).Maybe()
+}
+
+func TestWarehouseService_ReserveStockBatch_AllocationStrategies(t *testing.T) {
+	ctx := context.TODO()
+	items := []domain.ReserveStockBatchItem{{ProductID: "prod-1", Quantity: 3}, {ProductID: "prod-2", Quantity: 2}}
+
+	tests := []struct {
+		name     string
+		opts     domain.AllocationOptions
+		expected map[string]map[string]int // warehouse -> product -> quantity yang direservasi
+	}{
+		{
+			// Jakarta (prioritas 10) dihabiskan dulu, sisanya dari Surabaya (20)
+			name:     "priority",
+			opts:     domain.AllocationOptions{AllocationStrategy: domain.AllocationPriority},
+			expected: map[string]map[string]int{"wh-jkt": {"prod-1": 2, "prod-2": 2}, "wh-sby": {"prod-1": 1}},
+		},
+		{
+			// Dari Bandung: Bandung sendiri lalu Jakarta (~120 km) sebelum Surabaya
+			name: "nearest",
+			opts: domain.AllocationOptions{AllocationStrategy: domain.AllocationNearest,
+				ShipTo: &domain.GeoPoint{Latitude: -6.9147, Longitude: 107.6098}},
+			expected: map[string]map[string]int{"wh-bdg": {"prod-1": 3}, "wh-jkt": {"prod-2": 2}},
+		},
+		{
+			// Hanya Surabaya yang bisa mengirim kedua baris sekaligus
+			name:     "single_warehouse",
+			opts:     domain.AllocationOptions{AllocationStrategy: domain.AllocationSingleWarehouse},
+			expected: map[string]map[string]int{"wh-sby": {"prod-1": 3, "prod-2": 2}},
+		},
+		{
+			// Stok prod-1 terbanyak di Bandung, prod-2 terbanyak di Surabaya
+			name:     "balanced_depletion",
+			opts:     domain.AllocationOptions{AllocationStrategy: domain.AllocationBalancedDepletion},
+			expected: map[string]map[string]int{"wh-bdg": {"prod-1": 3}, "wh-sby": {"prod-2": 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockWarehouseRepository)
+			mockTx := new(mocks.MockDBTX)
+			service := NewWarehouseServiceWithAllocation(mockRepo, priorityAllocation{})
+			expectStrategyReservation(mockRepo, mockTx, ctx, tt.expected)
+
+			lines, err := service.ReserveStockBatch(ctx, domain.ReserveStockBatchRequest{Items: items, AllocationOptions: tt.opts})
+
+			assert.NoError(t, err)
+			assert.Len(t, lines, 2)
+			mockRepo.AssertExpectations(t)
+			mockTx.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Configured default strategy is used when the request names none", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseServiceWithAllocation(mockRepo, balancedDepletionAllocation{})
+		expectStrategyReservation(mockRepo, mockTx, ctx, map[string]map[string]int{"wh-bdg": {"prod-1": 3}, "wh-sby": {"prod-2": 2}})
+
+		_, err := service.ReserveStockBatch(ctx, domain.ReserveStockBatchRequest{Items: items})
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Nearest without ship_to is rejected before touching stock", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		lines, err := service.ReserveStockBatch(ctx, domain.ReserveStockBatchRequest{
+			Items:             items,
+			AllocationOptions: domain.AllocationOptions{AllocationStrategy: domain.AllocationNearest},
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidAllocationRequest)
+		assert.Nil(t, lines)
+		mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+	})
+}
+
+// overAllocation adalah strategi yang salah: mereservasi lebih dari stok yang tersedia.
+type overAllocation struct{}
+
+func (overAllocation) Name() domain.AllocationStrategyName { return "over" }
+
+func (overAllocation) Allocate(req AllocationRequest) ([][]Allocation, error) {
+	plan := make([][]Allocation, len(req.Items))
+	for i, item := range req.Items {
+		plan[i] = []Allocation{{WarehouseID: req.Warehouses[0].ID, Quantity: item.Quantity}}
+	}
+	return plan, nil
+}
+
+func TestWarehouseService_ReserveStock_RejectsInvalidPlan(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	mockTx := new(mocks.MockDBTX)
+	service := NewWarehouseServiceWithAllocation(mockRepo, overAllocation{})
+
+	mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+	mockRepo.On("ListWarehouses", ctx).Return(strategyTestWarehouses, nil).Once()
+	mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh-bdg", "prod-2").Return(nil, whRepo.ErrProductStockNotFound).Once()
+	mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh-jkt", "prod-2").
+		Return(&domain.ProductStock{WarehouseID: "wh-jkt", ProductID: "prod-2", Quantity: 5}, nil).Once()
+	mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh-sby", "prod-2").
+		Return(&domain.ProductStock{WarehouseID: "wh-sby", ProductID: "prod-2", Quantity: 6}, nil).Once()
+	mockTx.On("Rollback").Return(nil).Once()
+
+	reservations, err := service.ReserveStock(ctx, domain.ReserveStockRequest{ProductID: "prod-2", Quantity: 2})
+
+	assert.ErrorIs(t, err, ErrStockOperationFailed)
+	assert.Nil(t, reservations)
+	mockRepo.AssertNotCalled(t, "IncreaseReservedStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockTx.AssertNotCalled(t, "Commit")
+}
+
+func TestNewAllocationStrategy(t *testing.T) {
+	for _, name := range []domain.AllocationStrategyName{
+		domain.AllocationPriority, domain.AllocationNearest, domain.AllocationSingleWarehouse, domain.AllocationBalancedDepletion,
+	} {
+		strategy, err := NewAllocationStrategy(name)
+		if assert.NoError(t, err) {
+			assert.Equal(t, name, strategy.Name())
+		}
+	}
+	_, err := NewAllocationStrategy("random")
+	assert.ErrorIs(t, err, ErrInvalidAllocationRequest)
+}
+
+func TestPlanFulfillment(t *testing.T) {
+	warehouses := []domain.Warehouse{{ID: "wh1"}, {ID: "wh2"}, {ID: "wh3"}}
+
+	t.Run("Single warehouse holding every line wins over name order", func(t *testing.T) {
+		available := StockAvailability{
+			"wh1": {"prod-a": 5},
+			"wh2": {"prod-a": 5, "prod-b": 5},
+		}
+		items := []domain.ReserveStockBatchItem{{ProductID: "prod-a", Quantity: 2}, {ProductID: "prod-b", Quantity: 2}}
+
+		plan := planFulfillment(warehouses, available, items)
+		assert.Equal(t, [][]Allocation{{{WarehouseID: "wh2", Quantity: 2}}, {{WarehouseID: "wh2", Quantity: 2}}}, plan)
+		assert.Equal(t, 5, available["wh2"]["prod-a"], "input availability must not be modified")
+	})
+
+	t.Run("Fewest warehouses across lines", func(t *testing.T) {
+		available := StockAvailability{
+			"wh1": {"prod-a": 1},
+			"wh2": {"prod-b": 1},
+			"wh3": {"prod-b": 1, "prod-c": 1},
+		}
+		items := []domain.ReserveStockBatchItem{
+			{ProductID: "prod-a", Quantity: 1},
+			{ProductID: "prod-b", Quantity: 1},
+			{ProductID: "prod-c", Quantity: 1},
+		}
+
+		plan := planFulfillment(warehouses, available, items)
+		assert.Equal(t, [][]Allocation{
+			{{WarehouseID: "wh1", Quantity: 1}},
+			{{WarehouseID: "wh3", Quantity: 1}},
+			{{WarehouseID: "wh3", Quantity: 1}},
+		}, plan)
+	})
+
+	t.Run("Line is split only when no warehouse has enough", func(t *testing.T) {
+		available := StockAvailability{
+			"wh1": {"prod-a": 8},
+			"wh2": {"prod-a": 3},
+		}
+		items := []domain.ReserveStockBatchItem{{ProductID: "prod-a", Quantity: 10}}
+
+		plan := planFulfillment(warehouses, available, items)
+		assert.Equal(t, [][]Allocation{{{WarehouseID: "wh1", Quantity: 8}, {WarehouseID: "wh2", Quantity: 2}}}, plan)
+	})
+
+	t.Run("Duplicate product lines share the same stock", func(t *testing.T) {
+		available := StockAvailability{
+			"wh1": {"prod-a": 4},
+			"wh2": {"prod-a": 6},
+		}
+		items := []domain.ReserveStockBatchItem{{ProductID: "prod-a", Quantity: 3}, {ProductID: "prod-a", Quantity: 3}}
+
+		plan := planFulfillment(warehouses, available, items)
+		assert.Equal(t, [][]Allocation{{{WarehouseID: "wh2", Quantity: 3}}, {{WarehouseID: "wh2", Quantity: 3}}}, plan)
+	})
+}
+
+func TestFirstShortItem(t *testing.T) {
+	available := StockAvailability{
+		"wh1": {"prod-a": 2},
+		"wh2": {"prod-a": 1, "prod-b": 1},
+	}
+
+	assert.Equal(t, -1, firstShortItem(available, []domain.ReserveStockBatchItem{{ProductID: "prod-a", Quantity: 3}}))
+	assert.Equal(t, 1, firstShortItem(available, []domain.ReserveStockBatchItem{
+		{ProductID: "prod-a", Quantity: 2},
+		{ProductID: "prod-a", Quantity: 2},
+	}))
+	assert.Equal(t, 0, firstShortItem(available, []domain.ReserveStockBatchItem{{ProductID: "prod-c", Quantity: 1}}))
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+)
+
+var ErrInvalidMovementQuery = errors.New("invalid stock movement query")
+
+// actorFromContext menentukan actor untuk buku besar stok: "<role>:<user_id>" jika request membawa identitas,
+// atau "system" untuk pemanggilan antar service dan job internal.
+func actorFromContext(ctx context.Context) string {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || identity.UserID == "" {
+		return domain.ActorSystem
+	}
+	return identity.Role + ":" + identity.UserID
+}
+
+// movementRef membangun konteks audit untuk perubahan stok dari request saat ini.
+func movementRef(ctx context.Context, referenceType, referenceID, reason string) domain.MovementRef {
+	ref := domain.MovementRef{Actor: actorFromContext(ctx), Reason: reason}
+	if referenceID != "" {
+		ref.ReferenceType, ref.ReferenceID = referenceType, referenceID
+	}
+	return ref
+}
+
+// ListStockMovements mengembalikan riwayat perubahan stok satu produk di satu gudang, terbaru lebih dulu.
+func (s *warehouseServiceImpl) ListStockMovements(ctx context.Context, filter domain.ListMovementsFilter) (*domain.ListMovementsResponse, error) {
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return nil, fmt.Errorf("%w: unknown movement type %s", ErrInvalidMovementQuery, filter.Type)
+	}
+	if _, err := s.repo.GetWarehouseByID(ctx, filter.WarehouseID); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultMovementListLimit
+	}
+	if filter.Limit > domain.MaxMovementListLimit {
+		filter.Limit = domain.MaxMovementListLimit
+	}
+	pageSize := filter.Limit
+
+	// Ambil satu baris ekstra untuk mengetahui apakah masih ada halaman berikutnya
+	filter.Limit = pageSize + 1
+	movements, err := s.repo.ListStockMovements(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	resp := &domain.ListMovementsResponse{Movements: movements}
+	if len(movements) > pageSize {
+		resp.Movements = movements[:pageSize]
+		resp.NextCursor = domain.EncodeMovementCursor(resp.Movements[pageSize-1].ID)
+	}
+	return resp, nil
+}
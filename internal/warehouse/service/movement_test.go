@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWarehouseService_ListStockMovements(t *testing.T) {
+	ctx := context.TODO()
+	movements := func(ids ...int64) []domain.StockMovement {
+		list := make([]domain.StockMovement, len(ids))
+		for i, id := range ids {
+			list[i] = domain.StockMovement{ID: id, WarehouseID: "wh1", ProductID: "prodA", Type: domain.MovementReserve}
+		}
+		return list
+	}
+
+	t.Run("Returns a cursor when more movements exist", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1"}, nil).Once()
+		mockRepo.On("ListStockMovements", ctx, domain.ListMovementsFilter{WarehouseID: "wh1", ProductID: "prodA", Limit: 3}).
+			Return(movements(9, 8, 7), nil).Once()
+
+		resp, err := service.ListStockMovements(ctx, domain.ListMovementsFilter{WarehouseID: "wh1", ProductID: "prodA", Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Movements, 2)
+		beforeID, err := domain.DecodeMovementCursor(resp.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(8), beforeID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Last page has no cursor and the limit is capped", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1"}, nil).Once()
+		mockRepo.On("ListStockMovements", ctx, mock.MatchedBy(func(f domain.ListMovementsFilter) bool {
+			return f.Limit == domain.MaxMovementListLimit+1 && f.BeforeID == 8
+		})).Return(movements(7), nil).Once()
+
+		resp, err := service.ListStockMovements(ctx, domain.ListMovementsFilter{WarehouseID: "wh1", ProductID: "prodA", BeforeID: 8, Limit: 1000})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Movements, 1)
+		assert.Empty(t, resp.NextCursor)
+	})
+
+	t.Run("Unknown movement type", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		_, err := service.ListStockMovements(ctx, domain.ListMovementsFilter{WarehouseID: "wh1", ProductID: "prodA", Type: "SHRINKAGE"})
+		assert.ErrorIs(t, err, ErrInvalidMovementQuery)
+		mockRepo.AssertNotCalled(t, "ListStockMovements", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_MovementActor(t *testing.T) {
+	t.Run("Caller identity is recorded on manual stock changes", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-7", Role: auth.RoleAdmin})
+		mockRepo.On("CreateOrUpdateProductStock", ctx, mock.Anything, domain.MovementRef{
+			ReferenceType: domain.MovementRefReceipt, ReferenceID: "PO-2024-001", Actor: "admin:user-7", Reason: "supplier delivery",
+		}).Return(nil).Once()
+
+		_, err := service.AddProductStock(ctx, "wh1", domain.AddStockRequest{
+			ProductID: "prodA", Quantity: 5, Reference: "PO-2024-001", Reason: "supplier delivery",
+		})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Manual transfer lets the repository assign a transfer id", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		ctx := context.TODO()
+		mockRepo.On("TransferStock", ctx, "prodA", "wh1", "wh2", 4, domain.MovementRef{Actor: domain.ActorSystem, Reason: "rebalance"}).Return(nil).Once()
+
+		err := service.TransferProductStock(ctx, domain.TransferStockRequest{
+			ProductID: "prodA", SourceWarehouseID: "wh1", TargetWarehouseID: "wh2", Quantity: 4, Reason: "rebalance",
+		})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Expired reservations are released by the expiry job actor", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+		ctx := context.TODO()
+		expired := domain.StockReservation{ID: "res-9", WarehouseID: "wh1", ProductID: "prodA", Quantity: 2, Status: domain.ReservationStatusActive}
+
+		mockRepo.On("ListExpiredReservations", ctx, mock.AnythingOfType("time.Time"), expiredReservationBatchSize).
+			Return([]domain.StockReservation{expired}, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetReservationForUpdate", ctx, mockTx, "res-9").Return(&expired, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").Return(&domain.ProductStock{Quantity: 5, ReservedQuantity: 2}, nil).Once()
+		mockRepo.On("DecreaseReservedStock", ctx, mockTx, "wh1", "prodA", 2, domain.MovementRef{
+			ReferenceType: domain.MovementRefReservation, ReferenceID: "res-9",
+			Actor: domain.ActorSystemReservationExpiry, Reason: "reservation expired",
+		}).Return(nil).Once()
+		mockRepo.On("UpdateReservationStatus", ctx, mockTx, "res-9", domain.ReservationStatusReleased).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		released, err := service.ReleaseExpiredReservations(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, released)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestNewStockMovement(t *testing.T) {
+	ref := domain.MovementRef{ReferenceType: domain.MovementRefReservation, ReferenceID: "res-1", Actor: "system"}
+	m := domain.NewStockMovement(domain.MovementCommit, "wh1", "prodA", -3, -3, 7, 2, ref)
+
+	assert.Equal(t, 10, m.QuantityBefore)
+	assert.Equal(t, 7, m.QuantityAfter)
+	assert.Equal(t, 5, m.ReservedBefore)
+	assert.Equal(t, 2, m.ReservedAfter)
+	assert.Equal(t, "res-1", m.ReferenceID)
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+var (
+	ErrInvalidStockThreshold = errors.New("invalid stock threshold")
+	ErrInvalidStockAlert     = errors.New("invalid stock alert request")
+	ErrStockAlertResolved    = errors.New("stock alert is already resolved")
+)
+
+// SetStockThreshold membuat atau mengganti ambang batas satu produk di satu gudang, lalu langsung mengevaluasinya
+// sehingga stok yang sudah di bawah reorder point segera mendapat alert.
+func (s *warehouseServiceImpl) SetStockThreshold(ctx context.Context, warehouseID, productID string, req domain.SetStockThresholdRequest) (*domain.StockThreshold, error) {
+	if req.SafetyStock > *req.ReorderPoint {
+		return nil, fmt.Errorf("%w: safety_stock %d exceeds reorder_point %d", ErrInvalidStockThreshold, req.SafetyStock, *req.ReorderPoint)
+	}
+	if _, err := s.getLiveWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+
+	threshold := &domain.StockThreshold{
+		WarehouseID:     warehouseID,
+		ProductID:       productID,
+		ReorderPoint:    *req.ReorderPoint,
+		SafetyStock:     req.SafetyStock,
+		ReorderQuantity: req.ReorderQuantity,
+		UpdatedBy:       actorFromContext(ctx),
+	}
+	if err := s.repo.UpsertStockThreshold(ctx, threshold); err != nil {
+		return nil, err
+	}
+	// Ambang batas sudah tersimpan; jika evaluasi gagal, evaluator terjadwal akan mengulanginya
+	level, err := evaluateStockAlert(ctx, s.repo, domain.StockKey{WarehouseID: warehouseID, ProductID: productID})
+	if err != nil {
+		logger.Error("Svc.SetStockThreshold: evaluation failed", err, map[string]interface{}{"warehouse_id": warehouseID, "product_id": productID})
+	} else {
+		threshold.LastAlertLevel = level
+	}
+	return threshold, nil
+}
+
+func (s *warehouseServiceImpl) GetStockThreshold(ctx context.Context, warehouseID, productID string) (*domain.StockThreshold, error) {
+	return s.repo.GetStockThreshold(ctx, warehouseID, productID)
+}
+
+func (s *warehouseServiceImpl) ListStockThresholds(ctx context.Context, warehouseID string) ([]domain.StockThreshold, error) {
+	if _, err := s.repo.GetWarehouseByID(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListStockThresholds(ctx, warehouseID)
+}
+
+// DeleteStockThreshold menghapus ambang batas dan menyelesaikan alert yang masih terbuka untuk produk tersebut.
+func (s *warehouseServiceImpl) DeleteStockThreshold(ctx context.Context, warehouseID, productID string) error {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.DeleteStockThreshold: begin tx failed", err, nil)
+		return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.DeleteStockThreshold(ctx, tx, warehouseID, productID); err != nil {
+		return err
+	}
+	alert, err := s.repo.GetUnresolvedStockAlert(ctx, tx, warehouseID, productID)
+	switch {
+	case err == nil:
+		resolveStockAlert(alert, actorFromContext(ctx), "threshold removed", time.Now())
+		if err := s.repo.UpdateStockAlert(ctx, tx, alert); err != nil {
+			return err
+		}
+	case !errors.Is(err, repository.ErrStockAlertNotFound):
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.DeleteStockThreshold: commit tx failed", err, nil)
+		return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return nil
+}
+
+func (s *warehouseServiceImpl) GetStockAlert(ctx context.Context, id string) (*domain.StockAlert, error) {
+	return s.repo.GetStockAlertByID(ctx, id)
+}
+
+func (s *warehouseServiceImpl) ListStockAlerts(ctx context.Context, filter domain.ListStockAlertsFilter) ([]domain.StockAlert, error) {
+	switch filter.Status {
+	case "", domain.StockAlertStatusOpen, domain.StockAlertStatusAcknowledged, domain.StockAlertStatusResolved:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidStockAlert, filter.Status)
+	}
+	return s.repo.ListStockAlerts(ctx, filter)
+}
+
+// AcknowledgeStockAlert menandai alert sedang ditangani. Alert tetap terbuka sampai stok kembali di atas
+// reorder point; jika stok turun lagi ke level yang lebih parah, alert dibuka kembali.
+func (s *warehouseServiceImpl) AcknowledgeStockAlert(ctx context.Context, id string) (*domain.StockAlert, error) {
+	return s.updateStockAlert(ctx, "AcknowledgeStockAlert", id, func(alert *domain.StockAlert, now time.Time) {
+		if alert.Status == domain.StockAlertStatusOpen {
+			alert.Status = domain.StockAlertStatusAcknowledged
+			alert.AcknowledgedBy = actorFromContext(ctx)
+			alert.AcknowledgedAt = &now
+		}
+	})
+}
+
+// ResolveStockAlert menutup alert secara manual, misalnya untuk produk yang tidak akan diisi ulang.
+// Alert baru hanya dibuat lagi jika stok kembali di atas reorder point lalu turun lagi, atau turun ke level yang lebih parah.
+func (s *warehouseServiceImpl) ResolveStockAlert(ctx context.Context, id string, req domain.ResolveStockAlertRequest) (*domain.StockAlert, error) {
+	return s.updateStockAlert(ctx, "ResolveStockAlert", id, func(alert *domain.StockAlert, now time.Time) {
+		resolveStockAlert(alert, actorFromContext(ctx), req.Note, now)
+	})
+}
+
+func (s *warehouseServiceImpl) updateStockAlert(ctx context.Context, op, id string, apply func(alert *domain.StockAlert, now time.Time)) (*domain.StockAlert, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc."+op+": begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	alert, err := s.repo.GetStockAlertForUpdate(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == domain.StockAlertStatusResolved {
+		return nil, fmt.Errorf("%w: %s", ErrStockAlertResolved, id)
+	}
+	apply(alert, time.Now())
+	if err := s.repo.UpdateStockAlert(ctx, tx, alert); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc."+op+": commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return alert, nil
+}
+
+func resolveStockAlert(alert *domain.StockAlert, resolvedBy, note string, now time.Time) {
+	alert.Status = domain.StockAlertStatusResolved
+	alert.ResolvedBy = resolvedBy
+	alert.ResolvedAt = &now
+	alert.ResolutionNote = note
+}
+
+// evaluateStockAlert membandingkan stok tersedia satu produk dengan ambang batasnya dan membuat, memperbarui
+// atau menyelesaikan alert-nya. Alert baru hanya dibuat saat level memburuk dibanding evaluasi sebelumnya
+// (misal dari di atas reorder point ke LOW, atau LOW ke CRITICAL), sehingga evaluasi berulang tidak membuat duplikat.
+// Mengembalikan level saat ini ("" jika stok di atas reorder point atau produk tidak punya ambang batas).
+func evaluateStockAlert(ctx context.Context, repo repository.WarehouseRepository, key domain.StockKey) (domain.StockAlertLevel, error) {
+	tx, err := repo.BeginTx(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Baris ambang batas dikunci agar evaluasi paralel (setelah perubahan stok dan sweep terjadwal) tidak saling menimpa
+	threshold, err := repo.GetStockThresholdForUpdate(ctx, tx, key.WarehouseID, key.ProductID)
+	if err != nil {
+		if errors.Is(err, repository.ErrStockThresholdNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	warehouse, err := repo.GetWarehouseByID(ctx, key.WarehouseID)
+	if err != nil {
+		return "", err
+	}
+
+	var level domain.StockAlertLevel
+	available, resolution := 0, "stock back above reorder point"
+	if warehouse.IsDecommissioned() {
+		resolution = "warehouse decommissioned"
+	} else {
+		stock, err := repo.GetProductStock(ctx, key.WarehouseID, key.ProductID)
+		switch {
+		case err == nil:
+			available = stock.Quantity - stock.ReservedQuantity
+		case !errors.Is(err, repository.ErrProductStockNotFound):
+			return "", err
+		}
+		level = threshold.LevelFor(available)
+	}
+
+	alert, err := repo.GetUnresolvedStockAlert(ctx, tx, key.WarehouseID, key.ProductID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrStockAlertNotFound) {
+			return "", err
+		}
+		alert = nil
+	}
+
+	now := time.Now()
+	switch {
+	case alert != nil:
+		before := *alert
+		if level == "" {
+			resolveStockAlert(alert, domain.StockAlertResolverSystem, resolution, now)
+		} else {
+			if level.Severity() > alert.Level.Severity() && alert.Status == domain.StockAlertStatusAcknowledged {
+				// Stok turun lebih jauh setelah alert ditangani; buka kembali agar terlihat lagi
+				alert.Status = domain.StockAlertStatusOpen
+				alert.AcknowledgedBy, alert.AcknowledgedAt = "", nil
+			}
+			alert.Level = level
+		}
+		alert.AvailableQuantity = available
+		alert.ReorderPoint, alert.SafetyStock, alert.ReorderQuantity = threshold.ReorderPoint, threshold.SafetyStock, threshold.ReorderQuantity
+		if *alert != before {
+			if err := repo.UpdateStockAlert(ctx, tx, alert); err != nil {
+				return "", err
+			}
+		}
+	case level.Severity() > threshold.LastAlertLevel.Severity():
+		alert = &domain.StockAlert{
+			WarehouseID:       key.WarehouseID,
+			ProductID:         key.ProductID,
+			Level:             level,
+			Status:            domain.StockAlertStatusOpen,
+			AvailableQuantity: available,
+			ReorderPoint:      threshold.ReorderPoint,
+			SafetyStock:       threshold.SafetyStock,
+			ReorderQuantity:   threshold.ReorderQuantity,
+		}
+		if err := repo.CreateStockAlert(ctx, tx, alert); err != nil {
+			return "", err
+		}
+		logger.Warn(fmt.Sprintf("Stock alert %s: product %s in warehouse %s is %s (%d available, reorder point %d, safety stock %d)",
+			alert.ID, key.ProductID, key.WarehouseID, level, available, threshold.ReorderPoint, threshold.SafetyStock))
+	}
+
+	if level != threshold.LastAlertLevel {
+		if err := repo.UpdateStockThresholdAlertLevel(ctx, tx, key.WarehouseID, key.ProductID, level); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return level, nil
+}
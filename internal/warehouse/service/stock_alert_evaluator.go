@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+const defaultStockAlertBatchSize = 500
+
+// StockAlertEvaluator mengevaluasi alert stok rendah di background. Setiap operasi yang mengubah stok menulis
+// ke buku besar stok, sehingga evaluator cukup membaca movement baru (RunOnce) untuk menemukan stok yang berubah,
+// tanpa memperlambat operasi stok itu sendiri. EvaluateAll mengevaluasi semua ambang batas dan dijadwalkan
+// terpisah untuk menangkap perubahan yang terlewat (misal movement dari transaksi yang commit terlambat).
+type StockAlertEvaluator struct {
+	repo      repository.WarehouseRepository
+	BatchSize int
+
+	mu             sync.Mutex // Mencegah RunOnce dan EvaluateAll berjalan bersamaan
+	lastMovementID int64
+	started        bool
+}
+
+func NewStockAlertEvaluator(repo repository.WarehouseRepository) *StockAlertEvaluator {
+	return &StockAlertEvaluator{repo: repo, BatchSize: defaultStockAlertBatchSize}
+}
+
+// Run mengevaluasi semua ambang batas sekali saat mulai, lalu memproses movement baru setiap interval
+// sampai ctx dibatalkan. Selama masih ada backlog, batch berikutnya langsung diproses.
+func (e *StockAlertEvaluator) Run(ctx context.Context, interval time.Duration) {
+	logger.Info(fmt.Sprintf("Stock alert evaluator started with interval %v", interval))
+	e.RunOnce(ctx) // Menetapkan posisi awal di buku besar sebelum evaluasi penuh, agar tidak ada perubahan yang terlewat
+	if _, err := e.EvaluateAll(ctx); err != nil {
+		logger.Error("StockAlertEvaluator: initial evaluation failed", err, nil)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if progressed := e.RunOnce(ctx); progressed && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Stock alert evaluator stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce mengevaluasi stok yang berubah sejak movement terakhir yang diproses.
+// Mengembalikan true jika ada movement baru yang dibaca.
+func (e *StockAlertEvaluator) RunOnce(ctx context.Context) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.started {
+		// Perubahan sebelum evaluator berjalan sudah dicakup oleh EvaluateAll
+		latest, err := e.repo.GetLatestStockMovementID(ctx)
+		if err != nil {
+			logger.Error("StockAlertEvaluator: failed to read latest stock movement", err, nil)
+			return false
+		}
+		e.lastMovementID, e.started = latest, true
+	}
+
+	keys, lastID, err := e.repo.ListStockKeysChangedSince(ctx, e.lastMovementID, e.BatchSize)
+	if err != nil {
+		logger.Error("StockAlertEvaluator: failed to read stock movements", err, nil)
+		return false
+	}
+	e.evaluate(ctx, keys)
+	progressed := lastID > e.lastMovementID
+	e.lastMovementID = lastID
+	return progressed
+}
+
+// EvaluateAll mengevaluasi setiap ambang batas di gudang yang belum di-decommission.
+// Mengembalikan jumlah ambang batas yang dievaluasi.
+func (e *StockAlertEvaluator) EvaluateAll(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	thresholds, err := e.repo.ListStockThresholds(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	keys := make([]domain.StockKey, 0, len(thresholds))
+	for _, t := range thresholds {
+		keys = append(keys, domain.StockKey{WarehouseID: t.WarehouseID, ProductID: t.ProductID})
+	}
+	e.evaluate(ctx, keys)
+	return len(keys), nil
+}
+
+func (e *StockAlertEvaluator) evaluate(ctx context.Context, keys []domain.StockKey) {
+	for _, key := range keys {
+		if _, err := evaluateStockAlert(ctx, e.repo, key); err != nil {
+			// Tidak menghentikan batch; sweep terjadwal berikutnya akan mengulang produk ini
+			logger.Error(fmt.Sprintf("StockAlertEvaluator: failed to evaluate product %s in warehouse %s", key.ProductID, key.WarehouseID), err, nil)
+		}
+	}
+}
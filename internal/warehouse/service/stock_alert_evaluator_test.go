@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStockAlertEvaluator_RunOnce(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Evaluates changed stock and advances past the processed movements", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		evaluator := NewStockAlertEvaluator(mockRepo)
+
+		mockRepo.On("GetLatestStockMovementID", ctx).Return(int64(100), nil).Once()
+		mockRepo.On("ListStockKeysChangedSince", ctx, int64(100), defaultStockAlertBatchSize).
+			Return([]domain.StockKey{{WarehouseID: "wh1", ProductID: "prodA"}}, int64(104), nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockThresholdForUpdate", ctx, mockTx, "wh1", "prodA").Return(nil, repository.ErrStockThresholdNotFound).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		assert.True(t, evaluator.RunOnce(ctx))
+
+		mockRepo.On("ListStockKeysChangedSince", ctx, int64(104), defaultStockAlertBatchSize).Return([]domain.StockKey{}, int64(104), nil).Once()
+		assert.False(t, evaluator.RunOnce(ctx))
+		mockRepo.AssertNumberOfCalls(t, "GetLatestStockMovementID", 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("A failed read keeps the position", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		evaluator := NewStockAlertEvaluator(mockRepo)
+
+		mockRepo.On("GetLatestStockMovementID", ctx).Return(int64(7), nil).Once()
+		mockRepo.On("ListStockKeysChangedSince", ctx, int64(7), defaultStockAlertBatchSize).Return(nil, int64(7), errors.New("db down")).Twice()
+
+		assert.False(t, evaluator.RunOnce(ctx))
+		assert.False(t, evaluator.RunOnce(ctx))
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestStockAlertEvaluator_EvaluateAll(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	mockTx := new(mocks.MockDBTX)
+	evaluator := NewStockAlertEvaluator(mockRepo)
+
+	mockRepo.On("ListStockThresholds", ctx, "").Return([]domain.StockThreshold{
+		{WarehouseID: "wh1", ProductID: "prodA"}, {WarehouseID: "wh2", ProductID: "prodA"},
+	}, nil).Once()
+	mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Twice()
+	mockRepo.On("GetStockThresholdForUpdate", ctx, mockTx, mock.Anything, "prodA").Return(nil, repository.ErrStockThresholdNotFound).Twice()
+	mockTx.On("Rollback").Return(nil)
+
+	evaluated, err := evaluator.EvaluateAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, evaluated)
+	mockRepo.AssertExpectations(t)
+}
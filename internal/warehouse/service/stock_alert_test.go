@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStockThreshold_LevelFor(t *testing.T) {
+	threshold := domain.StockThreshold{ReorderPoint: 10, SafetyStock: 3}
+	assert.Equal(t, domain.StockAlertLevel(""), threshold.LevelFor(11))
+	assert.Equal(t, domain.StockAlertLevelLow, threshold.LevelFor(10))
+	assert.Equal(t, domain.StockAlertLevelLow, threshold.LevelFor(4))
+	assert.Equal(t, domain.StockAlertLevelCritical, threshold.LevelFor(3))
+	assert.Equal(t, domain.StockAlertLevelCritical, threshold.LevelFor(0))
+}
+
+func TestEvaluateStockAlert(t *testing.T) {
+	ctx := context.TODO()
+	key := domain.StockKey{WarehouseID: "wh1", ProductID: "prodA"}
+	liveWarehouse := &domain.Warehouse{ID: "wh1", IsActive: true}
+	newThreshold := func(last domain.StockAlertLevel) *domain.StockThreshold {
+		return &domain.StockThreshold{WarehouseID: "wh1", ProductID: "prodA", ReorderPoint: 10, SafetyStock: 3, ReorderQuantity: 50, LastAlertLevel: last}
+	}
+	setup := func(threshold *domain.StockThreshold, stock *domain.ProductStock, alert *domain.StockAlert) (*mocks.MockWarehouseRepository, *mocks.MockDBTX) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockThresholdForUpdate", ctx, mockTx, "wh1", "prodA").Return(threshold, nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("GetProductStock", ctx, "wh1", "prodA").Return(stock, nil).Once()
+		if alert != nil {
+			mockRepo.On("GetUnresolvedStockAlert", ctx, mockTx, "wh1", "prodA").Return(alert, nil).Once()
+		} else {
+			mockRepo.On("GetUnresolvedStockAlert", ctx, mockTx, "wh1", "prodA").Return(nil, repository.ErrStockAlertNotFound).Once()
+		}
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+		return mockRepo, mockTx
+	}
+
+	t.Run("Crossing the reorder point opens a LOW alert", func(t *testing.T) {
+		mockRepo, mockTx := setup(newThreshold(""), &domain.ProductStock{Quantity: 12, ReservedQuantity: 4}, nil)
+		mockRepo.On("CreateStockAlert", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAlert) bool {
+			return a.Level == domain.StockAlertLevelLow && a.Status == domain.StockAlertStatusOpen &&
+				a.AvailableQuantity == 8 && a.ReorderQuantity == 50
+		})).Return(nil).Once()
+		mockRepo.On("UpdateStockThresholdAlertLevel", ctx, mockTx, "wh1", "prodA", domain.StockAlertLevelLow).Return(nil).Once()
+
+		level, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StockAlertLevelLow, level)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Staying below the reorder point does not duplicate the alert", func(t *testing.T) {
+		alert := &domain.StockAlert{ID: "alert-1", Level: domain.StockAlertLevelLow, Status: domain.StockAlertStatusOpen,
+			AvailableQuantity: 8, ReorderPoint: 10, SafetyStock: 3, ReorderQuantity: 50}
+		mockRepo, _ := setup(newThreshold(domain.StockAlertLevelLow), &domain.ProductStock{Quantity: 8}, alert)
+
+		_, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "CreateStockAlert", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateStockAlert", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateStockThresholdAlertLevel", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Dropping to safety stock escalates and reopens an acknowledged alert", func(t *testing.T) {
+		alert := &domain.StockAlert{ID: "alert-1", Level: domain.StockAlertLevelLow, Status: domain.StockAlertStatusAcknowledged,
+			AcknowledgedBy: "admin:user-1", AvailableQuantity: 8, ReorderPoint: 10, SafetyStock: 3, ReorderQuantity: 50}
+		mockRepo, mockTx := setup(newThreshold(domain.StockAlertLevelLow), &domain.ProductStock{Quantity: 2}, alert)
+		mockRepo.On("UpdateStockAlert", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAlert) bool {
+			return a.Level == domain.StockAlertLevelCritical && a.Status == domain.StockAlertStatusOpen &&
+				a.AcknowledgedBy == "" && a.AvailableQuantity == 2
+		})).Return(nil).Once()
+		mockRepo.On("UpdateStockThresholdAlertLevel", ctx, mockTx, "wh1", "prodA", domain.StockAlertLevelCritical).Return(nil).Once()
+
+		level, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StockAlertLevelCritical, level)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Replenishment resolves the alert automatically", func(t *testing.T) {
+		alert := &domain.StockAlert{ID: "alert-1", Level: domain.StockAlertLevelLow, Status: domain.StockAlertStatusAcknowledged,
+			AvailableQuantity: 8, ReorderPoint: 10, SafetyStock: 3, ReorderQuantity: 50}
+		mockRepo, mockTx := setup(newThreshold(domain.StockAlertLevelLow), &domain.ProductStock{Quantity: 58}, alert)
+		mockRepo.On("UpdateStockAlert", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAlert) bool {
+			return a.Status == domain.StockAlertStatusResolved && a.ResolvedBy == domain.StockAlertResolverSystem && a.ResolvedAt != nil
+		})).Return(nil).Once()
+		mockRepo.On("UpdateStockThresholdAlertLevel", ctx, mockTx, "wh1", "prodA", domain.StockAlertLevel("")).Return(nil).Once()
+
+		level, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StockAlertLevel(""), level)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Manually resolved alert is not recreated at the same level", func(t *testing.T) {
+		mockRepo, _ := setup(newThreshold(domain.StockAlertLevelLow), &domain.ProductStock{Quantity: 7}, nil)
+
+		_, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "CreateStockAlert", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Products without a threshold are skipped", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockThresholdForUpdate", ctx, mockTx, "wh1", "prodA").Return(nil, repository.ErrStockThresholdNotFound).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		level, err := evaluateStockAlert(ctx, mockRepo, key)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StockAlertLevel(""), level)
+		mockRepo.AssertNotCalled(t, "GetProductStock", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_StockThresholds(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	reorderPoint := func(n int) *int { return &n }
+
+	t.Run("Safety stock above the reorder point is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		_, err := service.SetStockThreshold(ctx, "wh1", "prodA", domain.SetStockThresholdRequest{ReorderPoint: reorderPoint(5), SafetyStock: 6})
+		assert.ErrorIs(t, err, ErrInvalidStockThreshold)
+		mockRepo.AssertNotCalled(t, "UpsertStockThreshold", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Deleting a threshold resolves its open alert", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("DeleteStockThreshold", ctx, mockTx, "wh1", "prodA").Return(nil).Once()
+		mockRepo.On("GetUnresolvedStockAlert", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.StockAlert{ID: "alert-1", Status: domain.StockAlertStatusOpen}, nil).Once()
+		mockRepo.On("UpdateStockAlert", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAlert) bool {
+			return a.Status == domain.StockAlertStatusResolved && a.ResolvedBy == "admin:user-1" && a.ResolutionNote == "threshold removed"
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		assert.NoError(t, service.DeleteStockThreshold(ctx, "wh1", "prodA"))
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWarehouseService_StockAlertLifecycle(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+
+	t.Run("Acknowledge records who handles the alert", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAlertForUpdate", ctx, mockTx, "alert-1").
+			Return(&domain.StockAlert{ID: "alert-1", Status: domain.StockAlertStatusOpen}, nil).Once()
+		mockRepo.On("UpdateStockAlert", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAlert) bool {
+			return a.Status == domain.StockAlertStatusAcknowledged && a.AcknowledgedBy == "admin:user-1" && a.AcknowledgedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		alert, err := service.AcknowledgeStockAlert(ctx, "alert-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StockAlertStatusAcknowledged, alert.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Resolved alerts cannot change", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockAlertForUpdate", ctx, mockTx, "alert-1").
+			Return(&domain.StockAlert{ID: "alert-1", Status: domain.StockAlertStatusResolved}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ResolveStockAlert(ctx, "alert-1", domain.ResolveStockAlertRequest{Note: "discontinued"})
+		assert.ErrorIs(t, err, ErrStockAlertResolved)
+		mockRepo.AssertNotCalled(t, "UpdateStockAlert", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_GetAggregatedProductStock_ExcludeSafetyStock(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	service := NewWarehouseService(mockRepo)
+
+	mockRepo.On("GetTotalAvailableStockByProductID", ctx, "prodA").Return(12, nil).Once()
+	mockRepo.On("GetTotalSellableStockByProductID", ctx, "prodA").Return(9, nil).Once()
+	mockRepo.On("GetInTransitQuantities", ctx, "prodA").Return(map[string]int{}, nil).Once()
+
+	info, err := service.GetAggregatedProductStock(ctx, "prodA", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, info.TotalAvailable)
+	assert.Equal(t, 3, info.SafetyStockExcluded)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+var (
+	ErrInvalidStockCount    = errors.New("invalid stock count request")
+	ErrStockCountIncomplete = errors.New("stock count still has uncounted products")
+)
+
+// OpenStockCount membuka sesi hitung fisik dan menyimpan snapshot quantity saat ini sebagai expected quantity.
+// Produk yang sedang dihitung sesi lain yang masih terbuka ditolak dengan repository.ErrStockCountConflict.
+func (s *warehouseServiceImpl) OpenStockCount(ctx context.Context, warehouseID string, req domain.OpenStockCountRequest) (*domain.StockCount, error) {
+	if _, err := s.getLiveWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	productIDs := uniqueStrings(req.ProductIDs)
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.OpenStockCount: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	count := &domain.StockCount{
+		WarehouseID: warehouseID,
+		Status:      domain.StockCountStatusOpen,
+		FullScope:   len(productIDs) == 0,
+		Note:        req.Note,
+		OpenedBy:    actorFromContext(ctx),
+	}
+	if err := s.repo.CreateStockCount(ctx, tx, count, productIDs); err != nil {
+		return nil, err
+	}
+	if len(count.Lines) == 0 {
+		return nil, fmt.Errorf("%w: warehouse %s has no stock to count", ErrInvalidStockCount, warehouseID)
+	}
+	if missing := missingCountProducts(count, productIDs); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: no stock entry in warehouse %s for product(s) %s",
+			ErrInvalidStockCount, warehouseID, strings.Join(missing, ", "))
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.OpenStockCount: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	logger.Info(fmt.Sprintf("Svc.OpenStockCount: stock count %s opened for %d product(s) in warehouse %s",
+		count.ID, len(count.Lines), warehouseID))
+	return count, nil
+}
+
+func missingCountProducts(count *domain.StockCount, productIDs []string) []string {
+	inCount := make(map[string]bool, len(count.Lines))
+	for _, line := range count.Lines {
+		inCount[line.ProductID] = true
+	}
+	var missing []string
+	for _, id := range productIDs {
+		if !inCount[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func (s *warehouseServiceImpl) GetStockCount(ctx context.Context, warehouseID, countID string) (*domain.StockCount, error) {
+	count, err := s.repo.GetStockCountByID(ctx, countID)
+	if err != nil {
+		return nil, err
+	}
+	if count.WarehouseID != warehouseID {
+		return nil, repository.ErrStockCountNotFound
+	}
+	return count, nil
+}
+
+func (s *warehouseServiceImpl) ListStockCounts(ctx context.Context, warehouseID string, status domain.StockCountStatus) ([]domain.StockCount, error) {
+	switch status {
+	case "", domain.StockCountStatusOpen, domain.StockCountStatusClosed, domain.StockCountStatusCancelled:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidStockCount, status)
+	}
+	if _, err := s.repo.GetWarehouseByID(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListStockCounts(ctx, warehouseID, status)
+}
+
+// RecordStockCounts menyimpan hasil hitung dari scanner. Produk boleh dihitung ulang berkali-kali selama sesi
+// masih terbuka; hasil terakhir yang dipakai saat sesi ditutup.
+func (s *warehouseServiceImpl) RecordStockCounts(ctx context.Context, warehouseID, countID string, req domain.RecordStockCountRequest) (*domain.StockCount, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.RecordStockCounts: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	count, err := s.openStockCountForUpdate(ctx, tx, warehouseID, countID)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string]*domain.StockCountLine, len(count.Lines))
+	for i := range count.Lines {
+		lines[count.Lines[i].ProductID] = &count.Lines[i]
+	}
+
+	countedBy, now := actorFromContext(ctx), time.Now()
+	for _, entry := range req.Counts {
+		line, ok := lines[entry.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %s", repository.ErrStockCountLineNotFound, entry.ProductID)
+		}
+		line.Record(*entry.CountedQuantity, countedBy, now)
+		if err := s.repo.UpdateStockCountLine(ctx, tx, count.ID, line); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.RecordStockCounts: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return count, nil
+}
+
+// CloseStockCount menerapkan selisih setiap produk yang sudah dihitung sebagai penyesuaian COUNT_CORRECTION
+// dalam satu transaksi: jika satu penyesuaian gagal (misal quantity akan di bawah reserved), tidak ada yang diterapkan.
+// Selisih dihitung terhadap snapshot saat sesi dibuka dan diterapkan pada quantity saat ini, sehingga penjualan
+// atau penerimaan selama penghitungan tidak ikut terhapus.
+func (s *warehouseServiceImpl) CloseStockCount(ctx context.Context, warehouseID, countID string, req domain.CloseStockCountRequest) (*domain.CloseStockCountResponse, error) {
+	if _, err := s.getLiveWarehouse(ctx, warehouseID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.CloseStockCount: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	count, err := s.openStockCountForUpdate(ctx, tx, warehouseID, countID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.CloseStockCountResponse{Count: count, Adjustments: []domain.StockAdjustment{}}
+	for _, line := range count.Lines {
+		if line.CountedQuantity == nil {
+			resp.UncountedProducts = append(resp.UncountedProducts, line.ProductID)
+		}
+	}
+	if len(resp.UncountedProducts) > 0 && !req.AllowUncounted {
+		return nil, fmt.Errorf("%w: %s", ErrStockCountIncomplete, strings.Join(resp.UncountedProducts, ", "))
+	}
+
+	closedBy, now := actorFromContext(ctx), time.Now()
+	// Baris sudah terurut per product_id, sehingga urutan penguncian stok konsisten antar transaksi
+	for i := range count.Lines {
+		line := &count.Lines[i]
+		if line.Variance == nil || *line.Variance == 0 {
+			continue
+		}
+		stock, err := s.repo.GetProductStockForUpdate(ctx, tx, warehouseID, line.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAdjustmentBounds(stock, *line.Variance); err != nil {
+			return nil, fmt.Errorf("product %s: %w", line.ProductID, err)
+		}
+		adjustment := &domain.StockAdjustment{
+			WarehouseID:   warehouseID,
+			ProductID:     line.ProductID,
+			QuantityDelta: *line.Variance,
+			ReasonCode:    domain.AdjustmentReasonCountCorrection,
+			Note:          "stock count " + count.ID,
+			Status:        domain.AdjustmentStatusApplied,
+			RequestedBy:   closedBy,
+			AppliedAt:     &now,
+		}
+		if err := s.repo.CreateStockAdjustment(ctx, tx, adjustment); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+		}
+		if err := s.applyAdjustment(ctx, tx, adjustment, stock); err != nil {
+			return nil, err
+		}
+		line.AdjustmentID = adjustment.ID
+		if err := s.repo.UpdateStockCountLine(ctx, tx, count.ID, line); err != nil {
+			return nil, err
+		}
+		resp.Adjustments = append(resp.Adjustments, *adjustment)
+	}
+
+	count.Status = domain.StockCountStatusClosed
+	count.ClosedBy = closedBy
+	count.ClosedAt = &now
+	if err := s.repo.UpdateStockCountStatus(ctx, tx, count); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.CloseStockCount: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	logger.Info(fmt.Sprintf("Svc.CloseStockCount: stock count %s closed with %d adjustment(s)", count.ID, len(resp.Adjustments)))
+	return resp, nil
+}
+
+// CancelStockCount menutup sesi tanpa mengubah stok dan melepas cakupan produknya untuk sesi lain.
+func (s *warehouseServiceImpl) CancelStockCount(ctx context.Context, warehouseID, countID string) (*domain.StockCount, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.CancelStockCount: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	count, err := s.openStockCountForUpdate(ctx, tx, warehouseID, countID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	count.Status = domain.StockCountStatusCancelled
+	count.ClosedBy = actorFromContext(ctx)
+	count.ClosedAt = &now
+	if err := s.repo.UpdateStockCountStatus(ctx, tx, count); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.CancelStockCount: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	return count, nil
+}
+
+func (s *warehouseServiceImpl) openStockCountForUpdate(ctx context.Context, tx repository.DBTX, warehouseID, countID string) (*domain.StockCount, error) {
+	count, err := s.repo.GetStockCountForUpdate(ctx, tx, countID)
+	if err != nil {
+		return nil, err
+	}
+	if count.WarehouseID != warehouseID {
+		return nil, repository.ErrStockCountNotFound
+	}
+	if count.Status != domain.StockCountStatusOpen {
+		return nil, fmt.Errorf("%w: %s is %s", repository.ErrStockCountNotOpen, countID, count.Status)
+	}
+	return count, nil
+}
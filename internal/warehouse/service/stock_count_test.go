@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWarehouseService_OpenStockCount(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	liveWarehouse := &domain.Warehouse{ID: "wh1", IsActive: true}
+
+	t.Run("Scoped count snapshots the requested products", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockCount", ctx, mockTx, mock.MatchedBy(func(c *domain.StockCount) bool {
+			return !c.FullScope && c.OpenedBy == "admin:user-1" && c.Status == domain.StockCountStatusOpen
+		}), []string{"prodA", "prodB"}).Run(func(args mock.Arguments) {
+			c := args.Get(2).(*domain.StockCount)
+			c.ID = "count-1"
+			c.Lines = []domain.StockCountLine{{ProductID: "prodA", ExpectedQuantity: 10}, {ProductID: "prodB", ExpectedQuantity: 4}}
+		}).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		count, err := service.OpenStockCount(ctx, "wh1", domain.OpenStockCountRequest{ProductIDs: []string{"prodA", "prodB", "prodA"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "count-1", count.ID)
+		assert.Len(t, count.Lines, 2)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Product without a stock entry is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockCount", ctx, mockTx, mock.Anything, []string{"prodA", "prodX"}).Run(func(args mock.Arguments) {
+			args.Get(2).(*domain.StockCount).Lines = []domain.StockCountLine{{ProductID: "prodA", ExpectedQuantity: 10}}
+		}).Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.OpenStockCount(ctx, "wh1", domain.OpenStockCountRequest{ProductIDs: []string{"prodA", "prodX"}})
+		assert.ErrorIs(t, err, ErrInvalidStockCount)
+		assert.Contains(t, err.Error(), "prodX")
+		mockTx.AssertNotCalled(t, "Commit")
+	})
+
+	t.Run("Overlapping open count is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockCount", ctx, mockTx, mock.MatchedBy(func(c *domain.StockCount) bool { return c.FullScope }), []string{}).
+			Return(repository.ErrStockCountConflict).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.OpenStockCount(ctx, "wh1", domain.OpenStockCountRequest{})
+		assert.ErrorIs(t, err, repository.ErrStockCountConflict)
+	})
+}
+
+func TestWarehouseService_RecordStockCounts(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "scanner-1", Role: auth.RoleAdmin})
+	counted := func(n int) *int { return &n }
+
+	t.Run("Counts are recorded with their variance", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").Return(&domain.StockCount{
+			ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen,
+			Lines: []domain.StockCountLine{{ProductID: "prodA", ExpectedQuantity: 10}, {ProductID: "prodB", ExpectedQuantity: 4}},
+		}, nil).Once()
+		mockRepo.On("UpdateStockCountLine", ctx, mockTx, "count-1", mock.MatchedBy(func(l *domain.StockCountLine) bool {
+			return l.ProductID == "prodA" && *l.CountedQuantity == 8 && *l.Variance == -2 && l.CountedBy == "admin:scanner-1"
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		count, err := service.RecordStockCounts(ctx, "wh1", "count-1", domain.RecordStockCountRequest{
+			Counts: []domain.StockCountEntry{{ProductID: "prodA", CountedQuantity: counted(8)}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, -2, *count.Lines[0].Variance)
+		assert.Nil(t, count.Lines[1].CountedQuantity)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Product outside the count scope", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").Return(&domain.StockCount{
+			ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen,
+			Lines: []domain.StockCountLine{{ProductID: "prodA", ExpectedQuantity: 10}},
+		}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.RecordStockCounts(ctx, "wh1", "count-1", domain.RecordStockCountRequest{
+			Counts: []domain.StockCountEntry{{ProductID: "prodZ", CountedQuantity: counted(1)}},
+		})
+		assert.ErrorIs(t, err, repository.ErrStockCountLineNotFound)
+	})
+
+	t.Run("Closed count cannot be recorded", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").
+			Return(&domain.StockCount{ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusClosed}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.RecordStockCounts(ctx, "wh1", "count-1", domain.RecordStockCountRequest{
+			Counts: []domain.StockCountEntry{{ProductID: "prodA", CountedQuantity: counted(1)}},
+		})
+		assert.ErrorIs(t, err, repository.ErrStockCountNotOpen)
+	})
+}
+
+func TestWarehouseService_CloseStockCount(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	liveWarehouse := &domain.Warehouse{ID: "wh1", IsActive: true}
+	countedLine := func(productID string, expected, counted int) domain.StockCountLine {
+		line := domain.StockCountLine{ProductID: productID, ExpectedQuantity: expected}
+		line.Record(counted, "admin:scanner-1", time.Now())
+		return line
+	}
+
+	t.Run("Variances are applied as count corrections", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").Return(&domain.StockCount{
+			ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen,
+			Lines: []domain.StockCountLine{countedLine("prodA", 10, 8), countedLine("prodB", 4, 4)},
+		}, nil).Once()
+		// Sejak snapshot, 3 unit prodA terjual; selisih -2 diterapkan pada quantity saat ini
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prodA", Quantity: 7, ReservedQuantity: 1}, nil).Once()
+		mockRepo.On("CreateStockAdjustment", ctx, mockTx, mock.MatchedBy(func(a *domain.StockAdjustment) bool {
+			return a.ProductID == "prodA" && a.QuantityDelta == -2 && a.ReasonCode == domain.AdjustmentReasonCountCorrection &&
+				a.Status == domain.AdjustmentStatusApplied && a.Note == "stock count count-1"
+		})).Run(func(args mock.Arguments) {
+			args.Get(2).(*domain.StockAdjustment).ID = "adj-1"
+		}).Return(nil).Once()
+		mockRepo.On("DecreaseProductStockQuantity", ctx, mockTx, "wh1", "prodA", 2, domain.MovementRef{
+			ReferenceType: domain.MovementRefAdjustment, ReferenceID: "adj-1", Actor: "admin:user-1",
+			Reason: "COUNT_CORRECTION: stock count count-1",
+		}).Return(nil).Once()
+		mockRepo.On("UpdateStockCountLine", ctx, mockTx, "count-1", mock.MatchedBy(func(l *domain.StockCountLine) bool {
+			return l.ProductID == "prodA" && l.AdjustmentID == "adj-1"
+		})).Return(nil).Once()
+		mockRepo.On("UpdateStockCountStatus", ctx, mockTx, mock.MatchedBy(func(c *domain.StockCount) bool {
+			return c.Status == domain.StockCountStatusClosed && c.ClosedBy == "admin:user-1" && c.ClosedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.CloseStockCount(ctx, "wh1", "count-1", domain.CloseStockCountRequest{})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Adjustments, 1)
+		assert.Empty(t, resp.UncountedProducts)
+		mockRepo.AssertNotCalled(t, "GetProductStockForUpdate", mock.Anything, mock.Anything, "wh1", "prodB")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Uncounted products block closing unless allowed", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").Return(&domain.StockCount{
+			ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen,
+			Lines: []domain.StockCountLine{countedLine("prodA", 10, 10), {ProductID: "prodB", ExpectedQuantity: 4}},
+		}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.CloseStockCount(ctx, "wh1", "count-1", domain.CloseStockCountRequest{})
+		assert.ErrorIs(t, err, ErrStockCountIncomplete)
+		assert.Contains(t, err.Error(), "prodB")
+		mockRepo.AssertNotCalled(t, "UpdateStockCountStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("One invalid variance rolls back the whole close", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(liveWarehouse, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").Return(&domain.StockCount{
+			ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen,
+			Lines: []domain.StockCountLine{countedLine("prodA", 10, 12), countedLine("prodB", 6, 1)},
+		}, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodA").
+			Return(&domain.ProductStock{Quantity: 10}, nil).Once()
+		mockRepo.On("CreateStockAdjustment", ctx, mockTx, mock.Anything).Return(nil).Once()
+		mockRepo.On("IncreaseProductStockQuantity", ctx, mockTx, "wh1", "prodA", 2, mock.Anything).Return(nil).Once()
+		mockRepo.On("UpdateStockCountLine", ctx, mockTx, "count-1", mock.Anything).Return(nil).Once()
+		// prodB: mengurangi 5 unit akan membuat quantity di bawah reserved
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prodB").
+			Return(&domain.ProductStock{Quantity: 6, ReservedQuantity: 3}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.CloseStockCount(ctx, "wh1", "count-1", domain.CloseStockCountRequest{})
+		assert.ErrorIs(t, err, ErrAdjustmentBelowReserved)
+		assert.Contains(t, err.Error(), "prodB")
+		mockTx.AssertNotCalled(t, "Commit")
+		mockRepo.AssertNotCalled(t, "UpdateStockCountStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_CancelStockCount(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	mockTx := new(mocks.MockDBTX)
+	service := NewWarehouseService(mockRepo)
+
+	mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+	mockRepo.On("GetStockCountForUpdate", ctx, mockTx, "count-1").
+		Return(&domain.StockCount{ID: "count-1", WarehouseID: "wh1", Status: domain.StockCountStatusOpen}, nil).Once()
+	mockRepo.On("UpdateStockCountStatus", ctx, mockTx, mock.MatchedBy(func(c *domain.StockCount) bool {
+		return c.Status == domain.StockCountStatusCancelled
+	})).Return(nil).Once()
+	mockTx.On("Commit").Return(nil).Once()
+	mockTx.On("Rollback").Return(nil).Maybe()
+
+	count, err := service.CancelStockCount(ctx, "wh1", "count-1")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StockCountStatusCancelled, count.Status)
+	mockRepo.AssertNotCalled(t, "CreateStockAdjustment", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+)
+
+var (
+	ErrInvalidTransferOrder = errors.New("invalid transfer order")
+	ErrTransferOrderState   = errors.New("transfer order is not in a state that allows this operation")
+)
+
+// CreateTransferOrder mencatat permintaan transfer antar gudang tanpa mengubah stok. Ketersediaan di gudang asal
+// hanya dicek sebagai validasi awal; stok baru benar-benar diamankan saat order di-pick.
+func (s *warehouseServiceImpl) CreateTransferOrder(ctx context.Context, req domain.CreateTransferOrderRequest) (*domain.TransferOrder, error) {
+	if req.SourceWarehouseID == req.TargetWarehouseID {
+		return nil, fmt.Errorf("%w: source and target warehouse must differ", ErrInvalidTransferOrder)
+	}
+	if _, err := s.getLiveWarehouse(ctx, req.SourceWarehouseID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getLiveWarehouse(ctx, req.TargetWarehouseID); err != nil {
+		return nil, err
+	}
+	stock, err := s.repo.GetProductStock(ctx, req.SourceWarehouseID, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if available := stock.Quantity - stock.ReservedQuantity; available < req.Quantity {
+		return nil, fmt.Errorf("%w: %d available in source warehouse, %d requested", repository.ErrInsufficientStock, available, req.Quantity)
+	}
+
+	order := &domain.TransferOrder{
+		ProductID:         req.ProductID,
+		SourceWarehouseID: req.SourceWarehouseID,
+		TargetWarehouseID: req.TargetWarehouseID,
+		Quantity:          req.Quantity,
+		Status:            domain.TransferOrderStatusRequested,
+		Reason:            req.Reason,
+		RequestedBy:       actorFromContext(ctx),
+	}
+	if err := s.repo.CreateTransferOrder(ctx, order); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	logger.Info(fmt.Sprintf("Svc.CreateTransferOrder: transfer order %s requested for %d unit(s) of %s from %s to %s",
+		order.ID, order.Quantity, order.ProductID, order.SourceWarehouseID, order.TargetWarehouseID))
+	return order, nil
+}
+
+func (s *warehouseServiceImpl) GetTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	return s.repo.GetTransferOrderByID(ctx, id)
+}
+
+func (s *warehouseServiceImpl) ListTransferOrders(ctx context.Context, filter domain.ListTransferOrdersFilter) ([]domain.TransferOrder, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidTransferOrder, filter.Status)
+	}
+	return s.repo.ListTransferOrders(ctx, filter)
+}
+
+// PickTransferOrder mereservasi unit di gudang asal sehingga tidak bisa dijual selama barang disiapkan.
+func (s *warehouseServiceImpl) PickTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	return s.advanceTransferOrder(ctx, "PickTransferOrder", id, domain.TransferOrderStatusRequested,
+		func(tx repository.DBTX, order *domain.TransferOrder, now time.Time) error {
+			if _, err := s.getLiveWarehouse(ctx, order.SourceWarehouseID); err != nil {
+				return err
+			}
+			ref := movementRef(ctx, domain.MovementRefTransferOrder, order.ID, "transfer order picked")
+			if err := s.repo.IncreaseReservedStock(ctx, tx, order.SourceWarehouseID, order.ProductID, order.Quantity, ref); err != nil {
+				return err
+			}
+			order.Status = domain.TransferOrderStatusPicked
+			order.PickedAt = &now
+			return nil
+		})
+}
+
+// DispatchTransferOrder mengeluarkan unit yang sudah di-pick dari gudang asal. Sejak saat ini unit tercatat
+// in transit dan belum menjadi stok gudang mana pun.
+func (s *warehouseServiceImpl) DispatchTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	return s.advanceTransferOrder(ctx, "DispatchTransferOrder", id, domain.TransferOrderStatusPicked,
+		func(tx repository.DBTX, order *domain.TransferOrder, now time.Time) error {
+			ref := movementRef(ctx, domain.MovementRefTransferOrder, order.ID, "transfer order dispatched")
+			if err := s.repo.DispatchTransferStock(ctx, tx, order.SourceWarehouseID, order.ProductID, order.Quantity, ref); err != nil {
+				return err
+			}
+			order.Status = domain.TransferOrderStatusInTransit
+			order.DispatchedAt = &now
+			return nil
+		})
+}
+
+// ReceiveTransferOrder memasukkan unit yang benar-benar diterima ke gudang tujuan. Penerimaan sebagian wajib
+// disertai catatan; selisihnya disimpan sebagai discrepancy dan tidak kembali ke gudang asal.
+func (s *warehouseServiceImpl) ReceiveTransferOrder(ctx context.Context, id string, req domain.ReceiveTransferOrderRequest) (*domain.TransferOrder, error) {
+	received := *req.ReceivedQuantity
+	return s.advanceTransferOrder(ctx, "ReceiveTransferOrder", id, domain.TransferOrderStatusInTransit,
+		func(tx repository.DBTX, order *domain.TransferOrder, now time.Time) error {
+			if received > order.Quantity {
+				return fmt.Errorf("%w: received quantity %d exceeds dispatched quantity %d", ErrInvalidTransferOrder, received, order.Quantity)
+			}
+			if received < order.Quantity && req.DiscrepancyNote == "" {
+				return fmt.Errorf("%w: discrepancy_note is required when receiving less than %d", ErrInvalidTransferOrder, order.Quantity)
+			}
+			// Penerimaan 0 unit tetap diizinkan untuk gudang tujuan yang sudah di-decommission, agar order bisa ditutup
+			if received > 0 {
+				if _, err := s.getLiveWarehouse(ctx, order.TargetWarehouseID); err != nil {
+					return err
+				}
+				ref := movementRef(ctx, domain.MovementRefTransferOrder, order.ID, "transfer order received")
+				if _, err := s.repo.ReceiveTransferStock(ctx, tx, order.TargetWarehouseID, order.ProductID, received, ref); err != nil {
+					return err
+				}
+			}
+			order.Status = domain.TransferOrderStatusReceived
+			order.ReceivedQuantity = &received
+			order.DiscrepancyQuantity = order.Quantity - received
+			order.DiscrepancyNote = req.DiscrepancyNote
+			order.ReceivedAt = &now
+			return nil
+		})
+}
+
+// CancelTransferOrder hanya bisa dilakukan sebelum barang dikirim. Order yang sudah di-pick melepas reservasinya
+// di gudang asal; barang yang sudah in transit harus diterima (boleh dengan quantity 0 dan catatan discrepancy).
+func (s *warehouseServiceImpl) CancelTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error) {
+	return s.advanceTransferOrder(ctx, "CancelTransferOrder", id, "",
+		func(tx repository.DBTX, order *domain.TransferOrder, now time.Time) error {
+			switch order.Status {
+			case domain.TransferOrderStatusRequested:
+			case domain.TransferOrderStatusPicked:
+				ref := movementRef(ctx, domain.MovementRefTransferOrder, order.ID, "transfer order cancelled")
+				if err := s.repo.DecreaseReservedStock(ctx, tx, order.SourceWarehouseID, order.ProductID, order.Quantity, ref); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%w: cannot cancel %s order %s", ErrTransferOrderState, order.Status, order.ID)
+			}
+			order.Status = domain.TransferOrderStatusCancelled
+			order.CancelledAt = &now
+			return nil
+		})
+}
+
+// advanceTransferOrder mengunci order, memastikan statusnya sesuai (from kosong berarti dicek oleh apply),
+// lalu menjalankan perubahan stok dan menyimpan status baru dalam satu transaksi.
+func (s *warehouseServiceImpl) advanceTransferOrder(ctx context.Context, op, id string, from domain.TransferOrderStatus,
+	apply func(tx repository.DBTX, order *domain.TransferOrder, now time.Time) error) (*domain.TransferOrder, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc."+op+": begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	order, err := s.repo.GetTransferOrderForUpdate(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if from != "" && order.Status != from {
+		return nil, fmt.Errorf("%w: order %s is %s, expected %s", ErrTransferOrderState, order.ID, order.Status, from)
+	}
+	if err := apply(tx, order, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateTransferOrder(ctx, tx, order); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc."+op+": commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	logger.Info(fmt.Sprintf("Svc.%s: transfer order %s is now %s", op, order.ID, order.Status))
+	return order, nil
+}
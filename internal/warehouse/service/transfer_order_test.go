@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ridloal/e-commerce-go-microservices/internal/platform/auth"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
+	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func transferOrderRef(refID string) interface{} {
+	return mock.MatchedBy(func(ref domain.MovementRef) bool {
+		return ref.ReferenceType == domain.MovementRefTransferOrder && ref.ReferenceID == refID && ref.Actor == "admin:user-1"
+	})
+}
+
+func TestWarehouseService_CreateTransferOrder(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	req := domain.CreateTransferOrderRequest{ProductID: "prodA", SourceWarehouseID: "wh1", TargetWarehouseID: "wh2", Quantity: 5}
+
+	t.Run("Order is requested without touching stock", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1", IsActive: true}, nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh2").Return(&domain.Warehouse{ID: "wh2", IsActive: true}, nil).Once()
+		mockRepo.On("GetProductStock", ctx, "wh1", "prodA").Return(&domain.ProductStock{Quantity: 10, ReservedQuantity: 5}, nil).Once()
+		mockRepo.On("CreateTransferOrder", ctx, mock.MatchedBy(func(o *domain.TransferOrder) bool {
+			return o.Status == domain.TransferOrderStatusRequested && o.Quantity == 5 && o.RequestedBy == "admin:user-1"
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.TransferOrder).ID = "to-1"
+		}).Return(nil).Once()
+
+		order, err := service.CreateTransferOrder(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, "to-1", order.ID)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "IncreaseReservedStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Same source and target is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		sameWarehouse := req
+		sameWarehouse.TargetWarehouseID = "wh1"
+		_, err := service.CreateTransferOrder(ctx, sameWarehouse)
+		assert.ErrorIs(t, err, ErrInvalidTransferOrder)
+	})
+
+	t.Run("Insufficient available stock at source is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1", IsActive: true}, nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh2").Return(&domain.Warehouse{ID: "wh2", IsActive: true}, nil).Once()
+		mockRepo.On("GetProductStock", ctx, "wh1", "prodA").Return(&domain.ProductStock{Quantity: 10, ReservedQuantity: 8}, nil).Once()
+
+		_, err := service.CreateTransferOrder(ctx, req)
+		assert.ErrorIs(t, err, repository.ErrInsufficientStock)
+		mockRepo.AssertNotCalled(t, "CreateTransferOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_TransferOrderLifecycle(t *testing.T) {
+	ctx := auth.WithIdentity(context.TODO(), auth.Identity{UserID: "user-1", Role: auth.RoleAdmin})
+	newOrder := func(status domain.TransferOrderStatus) *domain.TransferOrder {
+		return &domain.TransferOrder{ID: "to-1", ProductID: "prodA", SourceWarehouseID: "wh1", TargetWarehouseID: "wh2", Quantity: 5, Status: status}
+	}
+	received := func(n int) *int { return &n }
+
+	t.Run("Pick reserves the units at the source", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusRequested), nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1", IsActive: true}, nil).Once()
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", "prodA", 5, transferOrderRef("to-1")).Return(nil).Once()
+		mockRepo.On("UpdateTransferOrder", ctx, mockTx, mock.MatchedBy(func(o *domain.TransferOrder) bool {
+			return o.Status == domain.TransferOrderStatusPicked && o.PickedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		order, err := service.PickTransferOrder(ctx, "to-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransferOrderStatusPicked, order.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Dispatch takes picked units out of the source", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusPicked), nil).Once()
+		mockRepo.On("DispatchTransferStock", ctx, mockTx, "wh1", "prodA", 5, transferOrderRef("to-1")).Return(nil).Once()
+		mockRepo.On("UpdateTransferOrder", ctx, mockTx, mock.MatchedBy(func(o *domain.TransferOrder) bool {
+			return o.Status == domain.TransferOrderStatusInTransit && o.DispatchedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		order, err := service.DispatchTransferOrder(ctx, "to-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransferOrderStatusInTransit, order.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Dispatch before pick is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusRequested), nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.DispatchTransferOrder(ctx, "to-1")
+		assert.ErrorIs(t, err, ErrTransferOrderState)
+		mockTx.AssertNotCalled(t, "Commit")
+	})
+
+	t.Run("Partial receipt records the discrepancy", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusInTransit), nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh2").Return(&domain.Warehouse{ID: "wh2", IsActive: true}, nil).Once()
+		mockRepo.On("ReceiveTransferStock", ctx, mockTx, "wh2", "prodA", 3, transferOrderRef("to-1")).
+			Return(&domain.ProductStock{WarehouseID: "wh2", ProductID: "prodA", Quantity: 3}, nil).Once()
+		mockRepo.On("UpdateTransferOrder", ctx, mockTx, mock.MatchedBy(func(o *domain.TransferOrder) bool {
+			return o.Status == domain.TransferOrderStatusReceived && o.DiscrepancyQuantity == 2 && o.ReceivedAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		order, err := service.ReceiveTransferOrder(ctx, "to-1", domain.ReceiveTransferOrderRequest{
+			ReceivedQuantity: received(3), DiscrepancyNote: "2 units damaged in transit",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, *order.ReceivedQuantity)
+		assert.Equal(t, 2, order.DiscrepancyQuantity)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Partial receipt without a note is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusInTransit), nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ReceiveTransferOrder(ctx, "to-1", domain.ReceiveTransferOrderRequest{ReceivedQuantity: received(3)})
+		assert.ErrorIs(t, err, ErrInvalidTransferOrder)
+		mockRepo.AssertNotCalled(t, "ReceiveTransferStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Receiving more than dispatched is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusInTransit), nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ReceiveTransferOrder(ctx, "to-1", domain.ReceiveTransferOrderRequest{ReceivedQuantity: received(6)})
+		assert.ErrorIs(t, err, ErrInvalidTransferOrder)
+	})
+
+	t.Run("Cancel after pick releases the reservation", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusPicked), nil).Once()
+		mockRepo.On("DecreaseReservedStock", ctx, mockTx, "wh1", "prodA", 5, transferOrderRef("to-1")).Return(nil).Once()
+		mockRepo.On("UpdateTransferOrder", ctx, mockTx, mock.MatchedBy(func(o *domain.TransferOrder) bool {
+			return o.Status == domain.TransferOrderStatusCancelled && o.CancelledAt != nil
+		})).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		order, err := service.CancelTransferOrder(ctx, "to-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TransferOrderStatusCancelled, order.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Cancel while in transit is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetTransferOrderForUpdate", ctx, mockTx, "to-1").Return(newOrder(domain.TransferOrderStatusInTransit), nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.CancelTransferOrder(ctx, "to-1")
+		assert.ErrorIs(t, err, ErrTransferOrderState)
+		mockRepo.AssertNotCalled(t, "UpdateTransferOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_GetAggregatedProductStock_InTransit(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	service := NewWarehouseService(mockRepo)
+
+	mockRepo.On("GetTotalAvailableStockByProductID", ctx, "prodA").Return(12, nil).Once()
+	mockRepo.On("GetInTransitQuantities", ctx, "prodA").Return(map[string]int{"wh2": 5, "wh3": 2}, nil).Once()
+
+	info, err := service.GetAggregatedProductStock(ctx, "prodA", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, info.TotalAvailable)
+	assert.Equal(t, 7, info.InTransit)
+	assert.Equal(t, map[string]int{"wh2": 5, "wh3": 2}, info.InTransitByWarehouse)
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/ridloal/e-commerce-go-microservices/internal/platform/logger"
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
@@ -11,43 +14,129 @@ import (
 )
 
 var (
-	ErrStockOperationFailed   = errors.New("stock operation failed")
-	ErrNoActiveWarehouseFound = errors.New("no active warehouse found to fulfill stock operation")
+	ErrStockOperationFailed     = errors.New("stock operation failed")
+	ErrNoActiveWarehouseFound   = errors.New("no active warehouse found to fulfill stock operation")
+	ErrReservationNotActive     = errors.New("stock reservation is no longer active")
+	ErrWarehouseInactive        = errors.New("warehouse is not active")
+	ErrReturnReferenceReused    = errors.New("return reference already used for a different warehouse, product or quantity")
+	ErrInvalidWarehouse         = errors.New("invalid warehouse data")
+	ErrInvalidNearestQuery      = errors.New("invalid nearest warehouse query")
+	ErrWarehouseDecommissioned  = errors.New("warehouse is decommissioned")
+	ErrWarehouseHasReservations = errors.New("warehouse still has reserved stock")
+	ErrInvalidDecommission      = errors.New("invalid decommission request")
 )
 
+// expiredReservationBatchSize membatasi jumlah reservasi kedaluwarsa yang dilepas per eksekusi job.
+const expiredReservationBatchSize = 100
+
 type WarehouseService interface {
 	CreateWarehouse(ctx context.Context, req domain.CreateWarehouseRequest) (*domain.Warehouse, error)
 	GetWarehouse(ctx context.Context, id string) (*domain.Warehouse, error)
 	ListWarehouses(ctx context.Context) ([]domain.Warehouse, error)
+	FindNearestWarehouses(ctx context.Context, query domain.NearestWarehousesQuery) ([]domain.NearestWarehouse, error)
+	UpdateWarehouse(ctx context.Context, id string, req domain.UpdateWarehouseRequest) (*domain.Warehouse, error)
 	ActivateWarehouse(ctx context.Context, id string) error
-	DeactivateWarehouse(ctx context.Context, id string) error
+	DeactivateWarehouse(ctx context.Context, id string) (*domain.DeactivateWarehouseResponse, error)
+	DecommissionWarehouse(ctx context.Context, id string, req domain.DecommissionWarehouseRequest) (*domain.DecommissionWarehouseResponse, error)
 
 	AddProductStock(ctx context.Context, warehouseID string, req domain.AddStockRequest) (*domain.ProductStock, error)
 	GetProductStockByWarehouse(ctx context.Context, warehouseID, productID string) (*domain.ProductStock, error)
-	GetAggregatedProductStock(ctx context.Context, productID string) (*domain.ProductStockInfo, error)
+	// GetAggregatedProductStock: excludeSafetyStock=true mengurangi safety stock setiap gudang dari total_available
+	GetAggregatedProductStock(ctx context.Context, productID string, excludeSafetyStock bool) (*domain.ProductStockInfo, error)
 	TransferProductStock(ctx context.Context, req domain.TransferStockRequest) error
 
 	// Internal methods for Order Service (will require transactions)
-	ReserveStock(ctx context.Context, productID string, quantityToReserve int) error
+	ReserveStock(ctx context.Context, req domain.ReserveStockRequest) ([]domain.StockReservation, error)
+	ReserveStockBatch(ctx context.Context, req domain.ReserveStockBatchRequest) ([]domain.ReservationLine, error)
 	ReleaseStock(ctx context.Context, productID string, quantityToRelease int) error
 	DeductStockAfterSale(ctx context.Context, req domain.DeductStockRequest) error
 
 	FindWarehousesForReservedProducts(ctx context.Context, productIDs []string) ([]domain.ProductWarehouseReservationInfo, error)
+
+	// Operasi berbasis reservation ID: hanya menyentuh alokasi milik reservasi tersebut
+	GetReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error)
+	ListReservationsByOrder(ctx context.Context, orderID string) ([]domain.StockReservation, error)
+	CommitReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error)
+	ReleaseReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error)
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
+
+	// ReceiveReturn menambah stok dari barang retur pelanggan (idempotent per return_reference)
+	ReceiveReturn(ctx context.Context, req domain.ReceiveReturnRequest) (*domain.ReceiveReturnResponse, error)
+
+	// ListStockMovements membaca buku besar stok satu produk di satu gudang
+	ListStockMovements(ctx context.Context, filter domain.ListMovementsFilter) (*domain.ListMovementsResponse, error)
+
+	// Penyesuaian stok manual dengan reason code, opsional dengan persetujuan orang kedua
+	CreateStockAdjustment(ctx context.Context, warehouseID string, req domain.CreateStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error)
+	GetStockAdjustment(ctx context.Context, warehouseID, adjustmentID string) (*domain.StockAdjustment, error)
+	ListStockAdjustments(ctx context.Context, warehouseID string, status domain.AdjustmentStatus) ([]domain.StockAdjustment, error)
+	ApproveStockAdjustment(ctx context.Context, warehouseID, adjustmentID string, req domain.ReviewStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error)
+	RejectStockAdjustment(ctx context.Context, warehouseID, adjustmentID string, req domain.ReviewStockAdjustmentRequest) (*domain.StockAdjustmentResponse, error)
+
+	// Stocktake / cycle count: sesi bisa dilanjutkan kapan saja sampai ditutup atau dibatalkan
+	OpenStockCount(ctx context.Context, warehouseID string, req domain.OpenStockCountRequest) (*domain.StockCount, error)
+	GetStockCount(ctx context.Context, warehouseID, countID string) (*domain.StockCount, error)
+	ListStockCounts(ctx context.Context, warehouseID string, status domain.StockCountStatus) ([]domain.StockCount, error)
+	RecordStockCounts(ctx context.Context, warehouseID, countID string, req domain.RecordStockCountRequest) (*domain.StockCount, error)
+	CloseStockCount(ctx context.Context, warehouseID, countID string, req domain.CloseStockCountRequest) (*domain.CloseStockCountResponse, error)
+	CancelStockCount(ctx context.Context, warehouseID, countID string) (*domain.StockCount, error)
+
+	// Transfer order dua fase: REQUESTED -> PICKED -> IN_TRANSIT -> RECEIVED, atau CANCELLED sebelum dikirim
+	CreateTransferOrder(ctx context.Context, req domain.CreateTransferOrderRequest) (*domain.TransferOrder, error)
+	GetTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error)
+	ListTransferOrders(ctx context.Context, filter domain.ListTransferOrdersFilter) ([]domain.TransferOrder, error)
+	PickTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error)
+	DispatchTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error)
+	ReceiveTransferOrder(ctx context.Context, id string, req domain.ReceiveTransferOrderRequest) (*domain.TransferOrder, error)
+	CancelTransferOrder(ctx context.Context, id string) (*domain.TransferOrder, error)
+
+	// Ambang batas stok (reorder point, safety stock) dan alert stok rendah yang dibuat oleh StockAlertEvaluator
+	SetStockThreshold(ctx context.Context, warehouseID, productID string, req domain.SetStockThresholdRequest) (*domain.StockThreshold, error)
+	GetStockThreshold(ctx context.Context, warehouseID, productID string) (*domain.StockThreshold, error)
+	ListStockThresholds(ctx context.Context, warehouseID string) ([]domain.StockThreshold, error)
+	DeleteStockThreshold(ctx context.Context, warehouseID, productID string) error
+	GetStockAlert(ctx context.Context, id string) (*domain.StockAlert, error)
+	ListStockAlerts(ctx context.Context, filter domain.ListStockAlertsFilter) ([]domain.StockAlert, error)
+	AcknowledgeStockAlert(ctx context.Context, id string) (*domain.StockAlert, error)
+	ResolveStockAlert(ctx context.Context, id string, req domain.ResolveStockAlertRequest) (*domain.StockAlert, error)
 }
 
 type warehouseServiceImpl struct {
-	repo repository.WarehouseRepository
+	repo       repository.WarehouseRepository
+	allocation AllocationStrategy // Strategi default jika request reservasi tidak memilih strategi
+	// Penyesuaian stok dengan |quantity_delta| di atas nilai ini butuh persetujuan; 0 berarti selalu langsung diterapkan
+	adjustmentApprovalThreshold int
 }
 
+// NewWarehouseService memakai strategi alokasi single_warehouse sebagai default.
 func NewWarehouseService(repo repository.WarehouseRepository) WarehouseService {
-	return &warehouseServiceImpl{repo: repo}
+	return NewWarehouseServiceWithAllocation(repo, singleWarehouseAllocation{})
+}
+
+func NewWarehouseServiceWithAllocation(repo repository.WarehouseRepository, allocation AllocationStrategy) WarehouseService {
+	return NewWarehouseServiceWithConfig(repo, allocation, 0)
+}
+
+func NewWarehouseServiceWithConfig(repo repository.WarehouseRepository, allocation AllocationStrategy, adjustmentApprovalThreshold int) WarehouseService {
+	return &warehouseServiceImpl{repo: repo, allocation: allocation, adjustmentApprovalThreshold: adjustmentApprovalThreshold}
 }
 
 // --- Warehouse Management ---
 func (s *warehouseServiceImpl) CreateWarehouse(ctx context.Context, req domain.CreateWarehouseRequest) (*domain.Warehouse, error) {
 	w := &domain.Warehouse{
-		Name:     req.Name,
-		Location: req.Location,
+		Name:      strings.TrimSpace(req.Name),
+		Location:  req.Location,
+		Address:   req.Address,
+		Priority:  domain.DefaultWarehousePriority,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Metadata:  req.Metadata,
+	}
+	if req.Priority != nil {
+		w.Priority = *req.Priority
+	}
+	if err := validateWarehouse(w); err != nil {
+		return nil, err
 	}
 	err := s.repo.CreateWarehouse(ctx, w)
 	if err != nil {
@@ -57,6 +146,31 @@ func (s *warehouseServiceImpl) CreateWarehouse(ctx context.Context, req domain.C
 	return w, nil
 }
 
+// validateWarehouse melengkapi validasi binding untuk pemanggil di luar HTTP handler,
+// dan memeriksa hasil akhir PATCH yang menggabungkan data lama dengan perubahan.
+func validateWarehouse(w *domain.Warehouse) error {
+	if w.Name == "" {
+		return fmt.Errorf("%w: name must not be blank", ErrInvalidWarehouse)
+	}
+	if w.Priority < 0 {
+		return fmt.Errorf("%w: priority must not be negative", ErrInvalidWarehouse)
+	}
+	if (w.Latitude == nil) != (w.Longitude == nil) {
+		return fmt.Errorf("%w: latitude and longitude must be given together", ErrInvalidWarehouse)
+	}
+	if w.Latitude != nil && !validCoordinates(*w.Latitude, *w.Longitude) {
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidWarehouse)
+	}
+	if a := w.Address; a != nil && (strings.TrimSpace(a.Street) == "" || strings.TrimSpace(a.City) == "" || len(a.Country) != 2) {
+		return fmt.Errorf("%w: address requires street, city and a two-letter country code", ErrInvalidWarehouse)
+	}
+	return nil
+}
+
+func validCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
 func (s *warehouseServiceImpl) GetWarehouse(ctx context.Context, id string) (*domain.Warehouse, error) {
 	return s.repo.GetWarehouseByID(ctx, id)
 }
@@ -65,20 +179,204 @@ func (s *warehouseServiceImpl) ListWarehouses(ctx context.Context) ([]domain.War
 	return s.repo.ListWarehouses(ctx)
 }
 
-func (s *warehouseServiceImpl) ActivateWarehouse(ctx context.Context, id string) error {
-	_, err := s.repo.GetWarehouseByID(ctx, id) // Check if exists
+// FindNearestWarehouses mengembalikan gudang aktif yang stok tersedianya cukup untuk query.Quantity,
+// diurutkan dari yang terdekat (jarak great-circle, dihitung di service tanpa layanan geocoding).
+// Gudang tanpa koordinat tidak bisa diukur jaraknya sehingga tidak ikut dalam hasil.
+func (s *warehouseServiceImpl) FindNearestWarehouses(ctx context.Context, query domain.NearestWarehousesQuery) ([]domain.NearestWarehouse, error) {
+	if !validCoordinates(query.Latitude, query.Longitude) {
+		return nil, fmt.Errorf("%w: lat must be within [-90, 90] and lng within [-180, 180]", ErrInvalidNearestQuery)
+	}
+	if query.ProductID == "" {
+		return nil, fmt.Errorf("%w: product_id is required", ErrInvalidNearestQuery)
+	}
+	if query.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidNearestQuery)
+	}
+
+	warehouses, err := s.repo.ListWarehouses(ctx)
 	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	stocks, err := s.repo.ListProductStocks(ctx, query.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	available := make(map[string]int, len(stocks))
+	for _, stock := range stocks {
+		available[stock.WarehouseID] = stock.Quantity - stock.ReservedQuantity
+	}
+
+	origin := domain.GeoPoint{Latitude: query.Latitude, Longitude: query.Longitude}
+	nearest := []domain.NearestWarehouse{}
+	for _, wh := range warehouses {
+		point, ok := wh.Coordinates()
+		if !wh.IsActive || !ok || available[wh.ID] < query.Quantity {
+			continue
+		}
+		nearest = append(nearest, domain.NearestWarehouse{
+			Warehouse:  wh,
+			DistanceKm: domain.DistanceKm(origin, point),
+			Available:  available[wh.ID],
+		})
+	}
+	sort.SliceStable(nearest, func(i, j int) bool { return nearest[i].DistanceKm < nearest[j].DistanceKm })
+	return nearest, nil
+}
+
+// UpdateWarehouse menerapkan PATCH: hanya field yang dikirim yang berubah. Status aktif diubah
+// lewat activate/deactivate, dan gudang yang sudah di-decommission tidak bisa diedit.
+func (s *warehouseServiceImpl) UpdateWarehouse(ctx context.Context, id string, req domain.UpdateWarehouseRequest) (*domain.Warehouse, error) {
+	w, err := s.getLiveWarehouse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		w.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Location != nil {
+		w.Location = req.Location
+	}
+	if req.Address != nil {
+		w.Address = req.Address
+	}
+	if req.Priority != nil {
+		w.Priority = *req.Priority
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		w.Latitude, w.Longitude = req.Latitude, req.Longitude
+	}
+	if req.Metadata != nil {
+		w.Metadata = req.Metadata
+	}
+	if err := validateWarehouse(w); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateWarehouse(ctx, w); err != nil {
+		logger.Error("Svc.UpdateWarehouse: repo error", err, nil)
+		return nil, err
+	}
+	return w, nil
+}
+
+// getLiveWarehouse mengambil gudang yang belum di-decommission.
+func (s *warehouseServiceImpl) getLiveWarehouse(ctx context.Context, id string) (*domain.Warehouse, error) {
+	w, err := s.repo.GetWarehouseByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if w.IsDecommissioned() {
+		return nil, fmt.Errorf("%w: %s", ErrWarehouseDecommissioned, id)
+	}
+	return w, nil
+}
+
+func (s *warehouseServiceImpl) ActivateWarehouse(ctx context.Context, id string) error {
+	if _, err := s.getLiveWarehouse(ctx, id); err != nil {
 		return err
 	}
 	return s.repo.UpdateWarehouseStatus(ctx, id, true)
 }
 
-func (s *warehouseServiceImpl) DeactivateWarehouse(ctx context.Context, id string) error {
-	_, err := s.repo.GetWarehouseByID(ctx, id) // Check if exists
+// DeactivateWarehouse menghentikan alokasi baru ke gudang. Reservasi yang sudah ada tidak disentuh;
+// jumlahnya dilaporkan agar operator tahu berapa unit yang masih menunggu commit atau release di sana.
+func (s *warehouseServiceImpl) DeactivateWarehouse(ctx context.Context, id string) (*domain.DeactivateWarehouseResponse, error) {
+	if _, err := s.getLiveWarehouse(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateWarehouseStatus(ctx, id, false); err != nil {
+		return nil, err
+	}
+	stocks, err := s.repo.ListWarehouseStocks(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	units, products := reservedTotals(stocks)
+	return &domain.DeactivateWarehouseResponse{
+		Message:          "Warehouse deactivated",
+		WarehouseID:      id,
+		ReservedUnits:    units,
+		ReservedProducts: products,
+	}, nil
+}
+
+func reservedTotals(stocks []domain.ProductStock) (units, products int) {
+	for _, stock := range stocks {
+		if stock.ReservedQuantity > 0 {
+			units += stock.ReservedQuantity
+			products++
+		}
+	}
+	return units, products
+}
+
+// DecommissionWarehouse menutup gudang secara permanen:
+//  1. Gudang dinonaktifkan dulu agar tidak menerima reservasi baru.
+//  2. Ditolak selama masih ada stok yang direservasi; gudang tetap nonaktif sehingga operator
+//     cukup menunggu reservasi di-commit/release lalu mengulang.
+//  3. Jika TargetWarehouseID diisi, seluruh stok dipindahkan lewat TransferStock (per produk).
+//  4. Gudang di-soft-delete; baris gudang tetap ada untuk reservasi, retur dan order lama.
+//
+// Pengulangan setelah drain gagal di tengah jalan aman: produk yang sudah dipindah tidak lagi punya stok.
+func (s *warehouseServiceImpl) DecommissionWarehouse(ctx context.Context, id string, req domain.DecommissionWarehouseRequest) (*domain.DecommissionWarehouseResponse, error) {
+	w, err := s.getLiveWarehouse(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if req.TargetWarehouseID != "" {
+		if req.TargetWarehouseID == id {
+			return nil, fmt.Errorf("%w: target warehouse must differ from the decommissioned warehouse", ErrInvalidDecommission)
+		}
+		if _, err := s.getLiveWarehouse(ctx, req.TargetWarehouseID); err != nil {
+			return nil, fmt.Errorf("%w: target warehouse %s: %v", ErrInvalidDecommission, req.TargetWarehouseID, err)
+		}
 	}
-	return s.repo.UpdateWarehouseStatus(ctx, id, false)
+
+	if w.IsActive {
+		if err := s.repo.UpdateWarehouseStatus(ctx, id, false); err != nil {
+			return nil, err
+		}
+		w.IsActive = false
+	}
+
+	stocks, err := s.repo.ListWarehouseStocks(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	if units, products := reservedTotals(stocks); units > 0 {
+		return nil, fmt.Errorf("%w: %d units reserved across %d products", ErrWarehouseHasReservations, units, products)
+	}
+
+	resp := &domain.DecommissionWarehouseResponse{TargetWarehouseID: req.TargetWarehouseID, Drained: []domain.DrainedStock{}}
+	for _, stock := range stocks {
+		if stock.Quantity <= 0 {
+			continue
+		}
+		if req.TargetWarehouseID == "" {
+			resp.RemainingUnits += stock.Quantity
+			continue
+		}
+		ref := movementRef(ctx, domain.MovementRefDecommission, id, "warehouse decommissioned")
+		if err := s.repo.TransferStock(ctx, stock.ProductID, id, req.TargetWarehouseID, stock.Quantity, ref); err != nil {
+			logger.Error("Svc.DecommissionWarehouse: drain failed", err, map[string]interface{}{
+				"warehouse_id": id,
+				"product_id":   stock.ProductID,
+				"target_wh":    req.TargetWarehouseID,
+			})
+			return nil, fmt.Errorf("%w: draining product %s: %v", ErrStockOperationFailed, stock.ProductID, err)
+		}
+		resp.Drained = append(resp.Drained, domain.DrainedStock{ProductID: stock.ProductID, Quantity: stock.Quantity})
+	}
+
+	deletedAt, err := s.repo.SoftDeleteWarehouse(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrWarehouseHasReserved) {
+			return nil, fmt.Errorf("%w: %v", ErrWarehouseHasReservations, err)
+		}
+		return nil, err
+	}
+	w.DeletedAt = &deletedAt
+	resp.Warehouse = *w
+	return resp, nil
 }
 
 // --- Stock Management ---
@@ -94,7 +392,7 @@ func (s *warehouseServiceImpl) AddProductStock(ctx context.Context, warehouseID
 		ProductID:   req.ProductID,
 		Quantity:    req.Quantity, // This is the amount to ADD
 	}
-	err := s.repo.CreateOrUpdateProductStock(ctx, stock)
+	err := s.repo.CreateOrUpdateProductStock(ctx, stock, movementRef(ctx, domain.MovementRefReceipt, req.Reference, req.Reason))
 	if err != nil {
 		logger.Error("Svc.AddProductStock: repo error", err, nil)
 		return nil, err
@@ -106,16 +404,39 @@ func (s *warehouseServiceImpl) GetProductStockByWarehouse(ctx context.Context, w
 	return s.repo.GetProductStock(ctx, warehouseID, productID)
 }
 
-func (s *warehouseServiceImpl) GetAggregatedProductStock(ctx context.Context, productID string) (*domain.ProductStockInfo, error) {
+func (s *warehouseServiceImpl) GetAggregatedProductStock(ctx context.Context, productID string, excludeSafetyStock bool) (*domain.ProductStockInfo, error) {
 	totalAvailable, err := s.repo.GetTotalAvailableStockByProductID(ctx, productID)
 	if err != nil {
 		logger.Error("Svc.GetAggregatedProductStock: repo error", err, nil)
 		return nil, err
 	}
-	return &domain.ProductStockInfo{
-		ProductID:      productID,
-		TotalAvailable: totalAvailable,
-	}, nil
+	safetyStockExcluded := 0
+	if excludeSafetyStock {
+		sellable, err := s.repo.GetTotalSellableStockByProductID(ctx, productID)
+		if err != nil {
+			logger.Error("Svc.GetAggregatedProductStock: sellable stock query failed", err, nil)
+			return nil, err
+		}
+		safetyStockExcluded, totalAvailable = totalAvailable-sellable, sellable
+	}
+	// Unit in transit belum bisa dijual di gudang mana pun, sehingga dilaporkan terpisah dari total_available
+	inTransit, err := s.repo.GetInTransitQuantities(ctx, productID)
+	if err != nil {
+		logger.Error("Svc.GetAggregatedProductStock: in-transit query failed", err, nil)
+		return nil, err
+	}
+	info := &domain.ProductStockInfo{
+		ProductID:           productID,
+		TotalAvailable:      totalAvailable,
+		SafetyStockExcluded: safetyStockExcluded,
+	}
+	for _, qty := range inTransit {
+		info.InTransit += qty
+	}
+	if len(inTransit) > 0 {
+		info.InTransitByWarehouse = inTransit
+	}
+	return info, nil
 }
 
 func (s *warehouseServiceImpl) TransferProductStock(ctx context.Context, req domain.TransferStockRequest) error {
@@ -128,7 +449,9 @@ func (s *warehouseServiceImpl) TransferProductStock(ctx context.Context, req dom
 	// _, err = s.repo.GetWarehouseByID(ctx, req.TargetWarehouseID)
 	// if err != nil { return fmt.Errorf("target warehouse not found: %w", err) }
 
-	err := s.repo.TransferStock(ctx, req.ProductID, req.SourceWarehouseID, req.TargetWarehouseID, req.Quantity)
+	// ReferenceID kosong: repository memberi ID transfer baru untuk pasangan TRANSFER_OUT/TRANSFER_IN
+	ref := movementRef(ctx, domain.MovementRefTransfer, "", req.Reason)
+	err := s.repo.TransferStock(ctx, req.ProductID, req.SourceWarehouseID, req.TargetWarehouseID, req.Quantity, ref)
 	if err != nil {
 		logger.Error("Svc.TransferProductStock: repo error", err, map[string]interface{}{
 			"product_id": req.ProductID,
@@ -141,111 +464,139 @@ func (s *warehouseServiceImpl) TransferProductStock(ctx context.Context, req dom
 	return nil
 }
 
-// ReserveStock attempts to reserve stock for a product across active warehouses.
-// This is a simplified version. A more robust one would pick specific warehouses.
-// For Tahap 2, we'll assume reservation happens from an aggregated pool,
-// and the actual deduction will need to pinpoint warehouses.
-// This method should be transactional.
-func (s *warehouseServiceImpl) ReserveStock(ctx context.Context, productID string, quantityToReserve int) error {
-	if quantityToReserve <= 0 {
-		return errors.New("quantity to reserve must be positive")
+// ReserveStock mereservasi stok satu produk dari gudang-gudang aktif dalam satu transaksi.
+// Pembagian ke gudang ditentukan oleh strategi alokasi request, atau strategi default service.
+//
+// Setiap alokasi per gudang disimpan sebagai baris stock_reservations (dengan order_id dan expires_at),
+// sehingga commit/release nantinya hanya menyentuh gudang dan jumlah yang memang direservasi untuk order itu.
+func (s *warehouseServiceImpl) ReserveStock(ctx context.Context, req domain.ReserveStockRequest) ([]domain.StockReservation, error) {
+	if req.Quantity <= 0 {
+		return nil, errors.New("quantity to reserve must be positive")
 	}
-
-	// 1. Find active warehouses that MIGHT have the product (or just try them all for simplicity now)
-	// This is complex for choosing WHICH warehouse. For now, let's assume a strategy:
-	// Try to reserve from the first warehouse that has enough stock.
-	// A better approach for later: distribute reservation or use a "virtual" aggregated stock for reservation,
-	// then a an allocation job. For now, simpler: pick one.
-
-	// This simplified ReserveStock will try to reserve from any available stock in any active warehouse
-	// It's not ideal as it doesn't specify which warehouse.
-	// The repository methods for increase/decrease reserved stock need a warehouse_id.
-
-	// Let's make a conceptual simplification for Tahap 2:
-	// The `ReserveStock` in the service must iterate through warehouses,
-	// lock rows, and update. THIS IS A COMPLEX TRANSACTION.
-
-	// To simplify for now, let's simulate reserving from the "first suitable warehouse"
-	// This will be refactored for Order Service later with proper Saga/transaction management if across warehouses,
-	// or within a single transaction if only one warehouse is involved for the whole reservation.
+	strategy, err := s.strategyFor(req.AllocationOptions)
+	if err != nil {
+		return nil, err
+	}
+	orderID, expiresAt := reservationTerms(req.OrderID, req.TTLSeconds)
+	items := []domain.ReserveStockBatchItem{{ProductID: req.ProductID, Quantity: req.Quantity}}
 
 	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
 		logger.Error("Svc.ReserveStock: begin tx failed", err, nil)
-		return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
 	}
 	defer tx.Rollback() // Rollback if not committed
 
-	// Find warehouses that have the product. For simplicity, let's get all active warehouses.
-	// A more optimized query would be to find warehouses that stock this product and are active.
-	activeWarehouses, err := s.repo.ListWarehouses(ctx) // Ideally filter for active here
+	warehouses, err := s.repo.ListWarehouses(ctx)
 	if err != nil {
 		logger.Error("Svc.ReserveStock: list warehouses failed", err, nil)
-		return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
 	}
+	warehouses = activeWarehouses(warehouses)
 
-	remainingToReserve := quantityToReserve
-	reservedInThisTx := false
-
-	for _, wh := range activeWarehouses {
-		if !wh.IsActive {
-			continue
-		}
-		if remainingToReserve <= 0 {
-			break
-		}
+	available, err := s.lockAvailability(ctx, tx, warehouses, items)
+	if err != nil {
+		return nil, err
+	}
+	if firstShortItem(available, items) >= 0 {
+		return nil, repository.ErrInsufficientStock
+	}
+	lines, err := s.allocate(ctx, tx, strategy, AllocationRequest{Warehouses: warehouses, Available: available, Items: items, ShipTo: req.ShipTo}, orderID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
 
-		// Lock the product stock row for this warehouse
-		stockItem, err := s.repo.GetProductStockForUpdate(ctx, tx, wh.ID, productID)
-		if err != nil {
-			if errors.Is(err, repository.ErrProductStockNotFound) {
-				continue // Product not in this warehouse
-			}
-			logger.Error("Svc.ReserveStock: GetProductStockForUpdate failed", err, fmt.Sprintf("WID: %s, PID: %s", wh.ID, productID))
-			return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
-		}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.ReserveStock: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
 
-		availableInWarehouse := stockItem.Quantity - stockItem.ReservedQuantity
-		if availableInWarehouse > 0 {
-			canReserveFromThisWH := 0
-			if availableInWarehouse >= remainingToReserve {
-				canReserveFromThisWH = remainingToReserve
-			} else {
-				canReserveFromThisWH = availableInWarehouse
-			}
+	return lines[0].Reservations, nil
+}
 
-			if canReserveFromThisWH > 0 {
-				err = s.repo.IncreaseReservedStock(ctx, tx, wh.ID, productID, canReserveFromThisWH)
-				if err != nil {
-					logger.Error("Svc.ReserveStock: IncreaseReservedStock failed", err, fmt.Sprintf("WID: %s, PID: %s", wh.ID, productID))
-					// if one part fails, the whole transaction should roll back
-					return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
-				}
-				remainingToReserve -= canReserveFromThisWH
-				reservedInThisTx = true
-			}
+// ReserveStockBatch mereservasi seluruh baris dalam satu transaksi (all-or-nothing).
+// Jika satu baris saja tidak bisa dipenuhi, tidak ada stok yang direservasi dan error menyebutkan produk yang gagal.
+// Baris-baris dibagi ke gudang oleh strategi alokasi; pembagian reservasi per gudang sekaligus menjadi
+// fulfillment group order. Hasil dikembalikan per baris sesuai urutan request.
+func (s *warehouseServiceImpl) ReserveStockBatch(ctx context.Context, req domain.ReserveStockBatchRequest) ([]domain.ReservationLine, error) {
+	if len(req.Items) == 0 {
+		return nil, errors.New("batch reservation must contain at least one item")
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity to reserve must be positive (product_id %s)", item.ProductID)
 		}
 	}
+	strategy, err := s.strategyFor(req.AllocationOptions)
+	if err != nil {
+		return nil, err
+	}
+	orderID, expiresAt := reservationTerms(req.OrderID, req.TTLSeconds)
 
-	if remainingToReserve > 0 {
-		// Not enough stock could be reserved across all warehouses
-		// Rollback has already been deferred, so it will happen.
-		return repository.ErrInsufficientStock
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.ReserveStockBatch: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
 	}
+	defer tx.Rollback() // Rollback seluruh batch jika tidak di-commit
 
-	if !reservedInThisTx && quantityToReserve > 0 { // Edge case: 0 to reserve, or product genuinely not found in any active WH
-		return repository.ErrProductStockNotFound // Or a more specific "Product has no stock in any active warehouse"
+	warehouses, err := s.repo.ListWarehouses(ctx)
+	if err != nil {
+		logger.Error("Svc.ReserveStockBatch: list warehouses failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	warehouses = activeWarehouses(warehouses)
+
+	// Semua baris stok dikunci dan dibaca dulu, agar pembagian ke gudang dihitung dari stok seluruh batch
+	available, err := s.lockAvailability(ctx, tx, warehouses, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if idx := firstShortItem(available, req.Items); idx >= 0 {
+		item := req.Items[idx]
+		return nil, fmt.Errorf("%w: product_id %s, quantity %d", repository.ErrInsufficientStock, item.ProductID, item.Quantity)
+	}
+	lines, err := s.allocate(ctx, tx, strategy, AllocationRequest{Warehouses: warehouses, Available: available, Items: req.Items, ShipTo: req.ShipTo}, orderID, expiresAt)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		logger.Error("Svc.ReserveStock: commit tx failed", err, nil)
-		return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+		logger.Error("Svc.ReserveStockBatch: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
 	}
 
-	return nil
+	return lines, nil
+}
+
+// allocate menjalankan strategi alokasi, memeriksa hasilnya, lalu mereservasi stok sesuai hasil tersebut di dalam tx.
+func (s *warehouseServiceImpl) allocate(ctx context.Context, tx repository.DBTX, strategy AllocationStrategy, req AllocationRequest,
+	orderID *string, expiresAt time.Time) ([]domain.ReservationLine, error) {
+	plan, err := strategy.Allocate(req)
+	if err == nil {
+		err = checkPlan(req.Available, req.Items, plan)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Svc.allocate: strategy %s failed", strategy.Name()), err, nil)
+		return nil, err
+	}
+	return s.applyAllocations(ctx, tx, req.Items, plan, orderID, expiresAt)
+}
+
+// reservationTerms menentukan order pemilik reservasi (nil jika tidak ada) dan waktu kedaluwarsanya.
+func reservationTerms(orderID string, ttlSeconds int) (*string, time.Time) {
+	ttl := domain.DefaultReservationTTL
+	if ttlSeconds > 0 {
+		ttl = time.Duration(ttlSeconds) * time.Second
+	}
+	if orderID == "" {
+		return nil, time.Now().Add(ttl)
+	}
+	return &orderID, time.Now().Add(ttl)
 }
 
-// ReleaseStock - similar logic to ReserveStock but for decreasing reserved_quantity
+// ReleaseStock - similar logic to ReserveStock but for decreasing reserved_quantity.
+// Tidak terikat ke reservasi tertentu; gunakan ReleaseReservation untuk alokasi milik order.
 func (s *warehouseServiceImpl) ReleaseStock(ctx context.Context, productID string, quantityToRelease int) error {
 	if quantityToRelease <= 0 {
 		return errors.New("quantity to release must be positive")
@@ -293,7 +644,7 @@ func (s *warehouseServiceImpl) ReleaseStock(ctx context.Context, productID strin
 			}
 
 			if canReleaseFromThisWH > 0 {
-				err = s.repo.DecreaseReservedStock(ctx, tx, wh.ID, productID, canReleaseFromThisWH)
+				err = s.repo.DecreaseReservedStock(ctx, tx, wh.ID, productID, canReleaseFromThisWH, movementRef(ctx, "", "", "legacy release by product"))
 				if err != nil {
 					logger.Error("Svc.ReleaseStock: DecreaseReservedStock failed", err, fmt.Sprintf("WID: %s, PID: %s", wh.ID, productID))
 					return fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
@@ -339,7 +690,7 @@ func (s *warehouseServiceImpl) DeductStockAfterSale(ctx context.Context, req dom
 		return fmt.Errorf("failed to lock stock for deduction (WH: %s, Prod: %s): %w", req.WarehouseID, req.ProductID, err)
 	}
 
-	err = s.repo.DeductCommittedStock(ctx, tx, req.WarehouseID, req.ProductID, req.Quantity)
+	err = s.repo.DeductCommittedStock(ctx, tx, req.WarehouseID, req.ProductID, req.Quantity, movementRef(ctx, "", "", "deduct after sale"))
 	if err != nil {
 		return fmt.Errorf("failed to deduct committed stock (WH: %s, Prod: %s, Qty: %d): %w", req.WarehouseID, req.ProductID, req.Quantity, err)
 	}
@@ -353,3 +704,164 @@ func (s *warehouseServiceImpl) FindWarehousesForReservedProducts(ctx context.Con
 	}
 	return s.repo.FindWarehousesWithActiveReservations(ctx, productIDs)
 }
+
+func (s *warehouseServiceImpl) GetReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error) {
+	return s.repo.GetReservationByID(ctx, reservationID)
+}
+
+func (s *warehouseServiceImpl) ListReservationsByOrder(ctx context.Context, orderID string) ([]domain.StockReservation, error) {
+	return s.repo.ListReservationsByOrderID(ctx, orderID)
+}
+
+// CommitReservation mengurangi stok (quantity dan reserved_quantity) tepat di gudang reservasi.
+// Commit ulang atas reservasi yang sudah COMMITTED dianggap sukses (idempotent).
+func (s *warehouseServiceImpl) CommitReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error) {
+	ref := movementRef(ctx, domain.MovementRefReservation, reservationID, "")
+	return s.settleReservation(ctx, reservationID, domain.ReservationStatusCommitted, func(tx repository.DBTX, res *domain.StockReservation) error {
+		return s.repo.DeductCommittedStock(ctx, tx, res.WarehouseID, res.ProductID, res.Quantity, ref)
+	})
+}
+
+// ReleaseReservation mengembalikan reserved_quantity milik reservasi ke stok tersedia.
+// Release ulang atas reservasi yang sudah RELEASED dianggap sukses (idempotent).
+func (s *warehouseServiceImpl) ReleaseReservation(ctx context.Context, reservationID string) (*domain.StockReservation, error) {
+	return s.releaseReservation(ctx, reservationID, movementRef(ctx, domain.MovementRefReservation, reservationID, ""))
+}
+
+func (s *warehouseServiceImpl) releaseReservation(ctx context.Context, reservationID string, ref domain.MovementRef) (*domain.StockReservation, error) {
+	return s.settleReservation(ctx, reservationID, domain.ReservationStatusReleased, func(tx repository.DBTX, res *domain.StockReservation) error {
+		return s.repo.DecreaseReservedStock(ctx, tx, res.WarehouseID, res.ProductID, res.Quantity, ref)
+	})
+}
+
+// settleReservation memindahkan reservasi ACTIVE ke status akhir target dalam satu transaksi,
+// setelah menjalankan perubahan stok yang sesuai.
+func (s *warehouseServiceImpl) settleReservation(ctx context.Context, reservationID string, target domain.ReservationStatus,
+	applyStockChange func(tx repository.DBTX, res *domain.StockReservation) error) (*domain.StockReservation, error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.settleReservation: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	res, err := s.repo.GetReservationForUpdate(ctx, tx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if res.Status == target {
+		return res, nil
+	}
+	if res.Status != domain.ReservationStatusActive {
+		return nil, fmt.Errorf("%w: reservation %s is %s", ErrReservationNotActive, reservationID, res.Status)
+	}
+
+	// Kunci baris stok sebelum diubah
+	if _, err := s.repo.GetProductStockForUpdate(ctx, tx, res.WarehouseID, res.ProductID); err != nil {
+		return nil, fmt.Errorf("failed to lock stock for reservation %s: %w", reservationID, err)
+	}
+	if err := applyStockChange(tx, res); err != nil {
+		logger.Error(fmt.Sprintf("Svc.settleReservation: stock update failed for reservation %s -> %s", reservationID, target), err, nil)
+		return nil, err
+	}
+	if err := s.repo.UpdateReservationStatus(ctx, tx, reservationID, target); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.settleReservation: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	res.Status = target
+	res.UpdatedAt = time.Now()
+	return res, nil
+}
+
+// ReleaseExpiredReservations melepas reservasi ACTIVE yang sudah melewati expires_at,
+// misalnya milik order yang gagal dibuat setelah stok direservasi.
+func (s *warehouseServiceImpl) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	expired, err := s.repo.ListExpiredReservations(ctx, time.Now(), expiredReservationBatchSize)
+	if err != nil {
+		logger.Error("Svc.ReleaseExpiredReservations: list failed", err, nil)
+		return 0, err
+	}
+
+	released := 0
+	for _, res := range expired {
+		ref := domain.MovementRef{
+			ReferenceType: domain.MovementRefReservation,
+			ReferenceID:   res.ID,
+			Actor:         domain.ActorSystemReservationExpiry,
+			Reason:        "reservation expired",
+		}
+		if _, err := s.releaseReservation(ctx, res.ID, ref); err != nil {
+			// Bisa saja sudah di-commit/release oleh request lain sejak di-list
+			logger.Warn(fmt.Sprintf("Svc.ReleaseExpiredReservations: failed to release reservation %s: %v", res.ID, err))
+			continue
+		}
+		released++
+	}
+	if released > 0 {
+		logger.Info(fmt.Sprintf("Svc.ReleaseExpiredReservations: released %d expired reservations", released))
+	}
+	return released, nil
+}
+
+// ReceiveReturn mencatat barang retur dan menambah product_stocks.quantity di gudang tujuan dalam satu transaksi.
+// Referensi yang sudah pernah diterima dengan data yang sama dianggap replay: stok tidak diubah lagi.
+func (s *warehouseServiceImpl) ReceiveReturn(ctx context.Context, req domain.ReceiveReturnRequest) (*domain.ReceiveReturnResponse, error) {
+	wh, err := s.repo.GetWarehouseByID(ctx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if !wh.IsActive {
+		return nil, fmt.Errorf("%w: %s", ErrWarehouseInactive, req.WarehouseID)
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("Svc.ReceiveReturn: begin tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+	defer tx.Rollback()
+
+	stockReturn := &domain.StockReturn{
+		ReturnReference: req.ReturnReference,
+		WarehouseID:     req.WarehouseID,
+		ProductID:       req.ProductID,
+		Quantity:        req.Quantity,
+	}
+	err = s.repo.CreateStockReturn(ctx, tx, stockReturn)
+	if errors.Is(err, repository.ErrStockReturnExists) {
+		tx.Rollback()
+		return s.replayStockReturn(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ref := movementRef(ctx, domain.MovementRefStockReturn, req.ReturnReference, "customer return received")
+	stock, err := s.repo.AddReturnedStock(ctx, tx, req.WarehouseID, req.ProductID, req.Quantity, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Svc.ReceiveReturn: commit tx failed", err, nil)
+		return nil, fmt.Errorf("%w: %v", ErrStockOperationFailed, err)
+	}
+
+	logger.Info(fmt.Sprintf("Svc.ReceiveReturn: %d unit(s) of product %s returned to warehouse %s (ref %s)",
+		req.Quantity, req.ProductID, req.WarehouseID, req.ReturnReference))
+	return &domain.ReceiveReturnResponse{Return: stockReturn, Stock: stock}, nil
+}
+
+func (s *warehouseServiceImpl) replayStockReturn(ctx context.Context, req domain.ReceiveReturnRequest) (*domain.ReceiveReturnResponse, error) {
+	existing, err := s.repo.GetStockReturnByReference(ctx, req.ReturnReference)
+	if err != nil {
+		return nil, err
+	}
+	if existing.WarehouseID != req.WarehouseID || existing.ProductID != req.ProductID || existing.Quantity != req.Quantity {
+		return nil, fmt.Errorf("%w: %s", ErrReturnReferenceReused, req.ReturnReference)
+	}
+	return &domain.ReceiveReturnResponse{Return: existing, Replayed: true}, nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ridloal/e-commerce-go-microservices/internal/warehouse/domain"
 	whRepo "github.com/ridloal/e-commerce-go-microservices/internal/warehouse/repository"
@@ -11,6 +12,11 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// reservationRef adalah ref movement RESERVE untuk reservasi yang ID-nya diisi mock CreateReservation.
+func reservationRef(warehouseID string) domain.MovementRef {
+	return domain.MovementRef{ReferenceType: domain.MovementRefReservation, ReferenceID: "mock-res-" + warehouseID, Actor: domain.ActorSystem}
+}
+
 func TestWarehouseService_CreateWarehouse(t *testing.T) {
 	mockRepo := new(mocks.MockWarehouseRepository)
 	service := NewWarehouseService(mockRepo)
@@ -29,6 +35,272 @@ func TestWarehouseService_CreateWarehouse(t *testing.T) {
 		assert.Equal(t, "mock-wh-id", wh.ID) // ID diset oleh mock
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("Address is stored with the warehouse", func(t *testing.T) {
+		address := &domain.Address{Street: "Jl. Raya Bogor No. 1", City: "Jakarta", Province: "DKI Jakarta", PostalCode: "13750", Country: "ID"}
+		mockRepo.On("CreateWarehouse", ctx, mock.MatchedBy(func(wh *domain.Warehouse) bool {
+			return wh.Name == "East WH" && wh.Address == address
+		})).Return(nil).Once()
+
+		wh, err := service.CreateWarehouse(ctx, domain.CreateWarehouseRequest{Name: "  East WH ", Address: address})
+		assert.NoError(t, err)
+		assert.Equal(t, "Jakarta", wh.Address.City)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid requests are rejected before hitting the repository", func(t *testing.T) {
+		lat, badLng := -6.2, 200.0
+		invalid := []domain.CreateWarehouseRequest{
+			{Name: "   "},
+			{Name: "Half coordinates", Latitude: &lat},
+			{Name: "Out of range", Latitude: &lat, Longitude: &badLng},
+			{Name: "No city", Address: &domain.Address{Street: "Jl. Merdeka 1", Country: "ID"}},
+		}
+		for _, req := range invalid {
+			_, err := service.CreateWarehouse(ctx, req)
+			assert.ErrorIs(t, err, ErrInvalidWarehouse, req.Name)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWarehouseService_UpdateWarehouse(t *testing.T) {
+	ctx := context.TODO()
+	location := "Jakarta, Indonesia"
+	existing := func() *domain.Warehouse {
+		return &domain.Warehouse{ID: "wh-1", Name: "Main WH", Location: &location, IsActive: true, Priority: 10,
+			Metadata: map[string]string{"branch_code": "JKT-01"}}
+	}
+
+	t.Run("Only provided fields change", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		name, priority := " Main WH Cakung ", 5
+		metadata := map[string]string{"branch_code": "JKT-02", "dock_count": "4"}
+		mockRepo.On("GetWarehouseByID", ctx, "wh-1").Return(existing(), nil).Once()
+		mockRepo.On("UpdateWarehouse", ctx, mock.MatchedBy(func(wh *domain.Warehouse) bool {
+			return wh.Name == "Main WH Cakung" && wh.Priority == 5 && wh.Location == &location &&
+				wh.IsActive && wh.Metadata["dock_count"] == "4"
+		})).Return(nil).Once()
+
+		wh, err := service.UpdateWarehouse(ctx, "wh-1", domain.UpdateWarehouseRequest{Name: &name, Priority: &priority, Metadata: metadata})
+		assert.NoError(t, err)
+		assert.Equal(t, "Main WH Cakung", wh.Name)
+		assert.Equal(t, "JKT-02", wh.Metadata["branch_code"])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Merged result is validated", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		lat := -6.2
+		mockRepo.On("GetWarehouseByID", ctx, "wh-1").Return(existing(), nil).Once()
+
+		_, err := service.UpdateWarehouse(ctx, "wh-1", domain.UpdateWarehouseRequest{Latitude: &lat})
+		assert.ErrorIs(t, err, ErrInvalidWarehouse)
+		mockRepo.AssertNotCalled(t, "UpdateWarehouse", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Decommissioned warehouse cannot be edited", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		deleted := existing()
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+		name := "Revived"
+		mockRepo.On("GetWarehouseByID", ctx, "wh-1").Return(deleted, nil).Once()
+
+		_, err := service.UpdateWarehouse(ctx, "wh-1", domain.UpdateWarehouseRequest{Name: &name})
+		assert.ErrorIs(t, err, ErrWarehouseDecommissioned)
+		mockRepo.AssertNotCalled(t, "UpdateWarehouse", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_DeactivateWarehouse(t *testing.T) {
+	ctx := context.TODO()
+	mockRepo := new(mocks.MockWarehouseRepository)
+	service := NewWarehouseService(mockRepo)
+	mockRepo.On("GetWarehouseByID", ctx, "wh-1").Return(&domain.Warehouse{ID: "wh-1", IsActive: true}, nil).Once()
+	mockRepo.On("UpdateWarehouseStatus", ctx, "wh-1", false).Return(nil).Once()
+	mockRepo.On("ListWarehouseStocks", ctx, "wh-1").Return([]domain.ProductStock{
+		{ProductID: "prod-a", Quantity: 10, ReservedQuantity: 3},
+		{ProductID: "prod-b", Quantity: 5},
+		{ProductID: "prod-c", Quantity: 8, ReservedQuantity: 8},
+	}, nil).Once()
+
+	resp, err := service.DeactivateWarehouse(ctx, "wh-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, resp.ReservedUnits)
+	assert.Equal(t, 2, resp.ReservedProducts)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWarehouseService_DecommissionWarehouse(t *testing.T) {
+	ctx := context.TODO()
+	active := func(id string) *domain.Warehouse { return &domain.Warehouse{ID: id, Name: id, IsActive: true} }
+
+	t.Run("Blocked while reservations exist", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		mockRepo.On("GetWarehouseByID", ctx, "wh-old").Return(active("wh-old"), nil).Once()
+		mockRepo.On("UpdateWarehouseStatus", ctx, "wh-old", false).Return(nil).Once()
+		mockRepo.On("ListWarehouseStocks", ctx, "wh-old").Return([]domain.ProductStock{
+			{ProductID: "prod-a", Quantity: 10, ReservedQuantity: 2},
+		}, nil).Once()
+
+		_, err := service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{})
+		assert.ErrorIs(t, err, ErrWarehouseHasReservations)
+		assert.Contains(t, err.Error(), "2 units reserved")
+		mockRepo.AssertNotCalled(t, "TransferStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "SoftDeleteWarehouse", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Drains stock to the target then soft-deletes", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		deletedAt := time.Now()
+		drainRef := domain.MovementRef{
+			ReferenceType: domain.MovementRefDecommission, ReferenceID: "wh-old", Actor: domain.ActorSystem, Reason: "warehouse decommissioned",
+		}
+		mockRepo.On("GetWarehouseByID", ctx, "wh-old").Return(active("wh-old"), nil).Once()
+		mockRepo.On("GetWarehouseByID", ctx, "wh-new").Return(active("wh-new"), nil).Once()
+		mockRepo.On("UpdateWarehouseStatus", ctx, "wh-old", false).Return(nil).Once()
+		mockRepo.On("ListWarehouseStocks", ctx, "wh-old").Return([]domain.ProductStock{
+			{ProductID: "prod-a", Quantity: 10},
+			{ProductID: "prod-b", Quantity: 0},
+			{ProductID: "prod-c", Quantity: 4},
+		}, nil).Once()
+		mockRepo.On("TransferStock", ctx, "prod-a", "wh-old", "wh-new", 10, drainRef).Return(nil).Once()
+		mockRepo.On("TransferStock", ctx, "prod-c", "wh-old", "wh-new", 4, drainRef).Return(nil).Once()
+		mockRepo.On("SoftDeleteWarehouse", ctx, "wh-old").Return(deletedAt, nil).Once()
+
+		resp, err := service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{TargetWarehouseID: "wh-new"})
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.DrainedStock{{ProductID: "prod-a", Quantity: 10}, {ProductID: "prod-c", Quantity: 4}}, resp.Drained)
+		assert.Zero(t, resp.RemainingUnits)
+		assert.False(t, resp.Warehouse.IsActive)
+		assert.True(t, resp.Warehouse.IsDecommissioned())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Without a target the remaining stock is reported", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		inactive := active("wh-old")
+		inactive.IsActive = false
+		mockRepo.On("GetWarehouseByID", ctx, "wh-old").Return(inactive, nil).Once()
+		mockRepo.On("ListWarehouseStocks", ctx, "wh-old").Return([]domain.ProductStock{{ProductID: "prod-a", Quantity: 7}}, nil).Once()
+		mockRepo.On("SoftDeleteWarehouse", ctx, "wh-old").Return(time.Now(), nil).Once()
+
+		resp, err := service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, resp.RemainingUnits)
+		assert.Empty(t, resp.Drained)
+		mockRepo.AssertNotCalled(t, "UpdateWarehouseStatus", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid targets are rejected before anything changes", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		deletedAt := time.Now()
+		gone := active("wh-gone")
+		gone.DeletedAt = &deletedAt
+		mockRepo.On("GetWarehouseByID", ctx, "wh-old").Return(active("wh-old"), nil)
+		mockRepo.On("GetWarehouseByID", ctx, "wh-gone").Return(gone, nil)
+
+		_, err := service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{TargetWarehouseID: "wh-old"})
+		assert.ErrorIs(t, err, ErrInvalidDecommission)
+		_, err = service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{TargetWarehouseID: "wh-gone"})
+		assert.ErrorIs(t, err, ErrInvalidDecommission)
+		mockRepo.AssertNotCalled(t, "UpdateWarehouseStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Already decommissioned", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		deletedAt := time.Now()
+		gone := active("wh-old")
+		gone.DeletedAt = &deletedAt
+		mockRepo.On("GetWarehouseByID", ctx, "wh-old").Return(gone, nil).Once()
+
+		_, err := service.DecommissionWarehouse(ctx, "wh-old", domain.DecommissionWarehouseRequest{})
+		assert.ErrorIs(t, err, ErrWarehouseDecommissioned)
+	})
+}
+
+func TestWarehouseService_FindNearestWarehouses(t *testing.T) {
+	ctx := context.TODO()
+	productID := "11111111-2222-3333-4444-555555555555"
+	coords := func(lat, lng float64) (*float64, *float64) { return &lat, &lng }
+	jktLat, jktLng := coords(-6.2088, 106.8456)
+	bdgLat, bdgLng := coords(-6.9175, 107.6191)
+	sbyLat, sbyLng := coords(-7.2575, 112.7521)
+	warehouses := []domain.Warehouse{
+		{ID: "wh-bdg", Name: "Bandung", IsActive: true, Latitude: bdgLat, Longitude: bdgLng},
+		{ID: "wh-jkt", Name: "Jakarta", IsActive: true, Latitude: jktLat, Longitude: jktLng},
+		{ID: "wh-nocoord", Name: "No Coordinates", IsActive: true},
+		{ID: "wh-off", Name: "Offline", IsActive: false, Latitude: jktLat, Longitude: jktLng},
+		{ID: "wh-sby", Name: "Surabaya", IsActive: true, Latitude: sbyLat, Longitude: sbyLng},
+	}
+	stocks := []domain.ProductStock{
+		{WarehouseID: "wh-bdg", ProductID: productID, Quantity: 10, ReservedQuantity: 2},
+		{WarehouseID: "wh-jkt", ProductID: productID, Quantity: 6, ReservedQuantity: 3},
+		{WarehouseID: "wh-nocoord", ProductID: productID, Quantity: 50},
+		{WarehouseID: "wh-off", ProductID: productID, Quantity: 50},
+		{WarehouseID: "wh-sby", ProductID: productID, Quantity: 20},
+	}
+
+	t.Run("Sorted by distance and filtered by availability", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		mockRepo.On("ListWarehouses", ctx).Return(warehouses, nil).Once()
+		mockRepo.On("ListProductStocks", ctx, productID).Return(stocks, nil).Once()
+
+		// Dari Bogor: Jakarta paling dekat tapi hanya tersedia 3 unit.
+		result, err := service.FindNearestWarehouses(ctx, domain.NearestWarehousesQuery{
+			Latitude: -6.5971, Longitude: 106.8060, ProductID: productID, Quantity: 5,
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, result, 2) {
+			assert.Equal(t, "wh-bdg", result[0].ID)
+			assert.Equal(t, 8, result[0].Available)
+			assert.Equal(t, "wh-sby", result[1].ID)
+			assert.Less(t, result[0].DistanceKm, result[1].DistanceKm)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("No warehouse can fulfil returns an empty list", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		mockRepo.On("ListWarehouses", ctx).Return(warehouses, nil).Once()
+		mockRepo.On("ListProductStocks", ctx, productID).Return(stocks, nil).Once()
+
+		result, err := service.FindNearestWarehouses(ctx, domain.NearestWarehousesQuery{
+			Latitude: -6.2, Longitude: 106.8, ProductID: productID, Quantity: 21,
+		})
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Empty(t, result)
+	})
+
+	t.Run("Invalid queries", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+		invalid := []domain.NearestWarehousesQuery{
+			{Latitude: 91, Longitude: 106.8, ProductID: productID, Quantity: 1},
+			{Latitude: -6.2, Longitude: -181, ProductID: productID, Quantity: 1},
+			{Latitude: -6.2, Longitude: 106.8, Quantity: 1},
+			{Latitude: -6.2, Longitude: 106.8, ProductID: productID},
+		}
+		for _, q := range invalid {
+			_, err := service.FindNearestWarehouses(ctx, q)
+			assert.ErrorIs(t, err, ErrInvalidNearestQuery)
+		}
+		mockRepo.AssertNotCalled(t, "ListWarehouses", mock.Anything)
+	})
 }
 
 func TestWarehouseService_ReserveStock(t *testing.T) {
@@ -36,6 +308,7 @@ func TestWarehouseService_ReserveStock(t *testing.T) {
 	service := NewWarehouseService(mockRepo)
 	ctx := context.TODO()
 	productID := "prod-reserve"
+	orderID := "order-reserve"
 	quantityToReserve := 5
 
 	mockTx := new(mocks.MockDBTX) // Mock untuk transaksi DB
@@ -51,12 +324,18 @@ func TestWarehouseService_ReserveStock(t *testing.T) {
 		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
 		mockRepo.On("ListWarehouses", ctx).Return(activeWarehouses[:1], nil).Once() // Hanya WH1
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", productID).Return(stockInWh1, nil).Once()
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, quantityToReserve).Return(nil).Once()
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, quantityToReserve, reservationRef("wh1")).Return(nil).Once()
+		mockRepo.On("CreateReservation", ctx, mockTx, mock.MatchedBy(func(res *domain.StockReservation) bool {
+			return res.WarehouseID == "wh1" && res.Quantity == quantityToReserve && *res.OrderID == orderID &&
+				res.Status == domain.ReservationStatusActive && res.ExpiresAt.After(time.Now())
+		})).Return(nil).Once()
 		mockTx.On("Commit").Return(nil).Once()
 		mockTx.On("Rollback").Return(nil).Maybe() // Mungkin tidak dipanggil jika commit berhasil
 
-		err := service.ReserveStock(ctx, productID, quantityToReserve)
+		reservations, err := service.ReserveStock(ctx, domain.ReserveStockRequest{ProductID: productID, Quantity: quantityToReserve, OrderID: orderID})
 		assert.NoError(t, err)
+		assert.Len(t, reservations, 1)
+		assert.Equal(t, "mock-res-wh1", reservations[0].ID)
 		mockRepo.AssertExpectations(t)
 		mockTx.AssertExpectations(t)
 	})
@@ -67,14 +346,15 @@ func TestWarehouseService_ReserveStock(t *testing.T) {
 		mockRepo.On("ListWarehouses", ctx).Return(activeWarehouses, nil).Once()
 		// WH1
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", productID).Return(stockInWh1, nil).Once()
-		// Misal, logic akan mencoba mengambil semua dari WH1 jika cukup.
-		// Jika quantityToReserve = 7, dan WH1 punya 8 available (10-2). Maka 7 akan diambil dari WH1.
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, 7).Return(nil).Once()
-		// WH2 tidak akan dipanggil karena sudah cukup dari WH1
+		// Semua gudang dikunci dulu. WH1 punya 8 available (10-2), cukup untuk 7, jadi seluruhnya diambil dari WH1.
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh2", productID).Return(stockInWh2, nil).Once()
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, 7, reservationRef("wh1")).Return(nil).Once()
+		mockRepo.On("CreateReservation", ctx, mockTx, mock.AnythingOfType("*domain.StockReservation")).Return(nil).Once()
+		// Tidak ada reservasi dari WH2 karena WH1 sudah cukup
 		mockTx.On("Commit").Return(nil).Once()
 		mockTx.On("Rollback").Return(nil).Maybe()
 
-		err := service.ReserveStock(ctx, productID, qtyToReserveMore)
+		_, err := service.ReserveStock(ctx, domain.ReserveStockRequest{ProductID: productID, Quantity: qtyToReserveMore})
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 		mockTx.AssertExpectations(t)
@@ -92,15 +372,22 @@ func TestWarehouseService_ReserveStock(t *testing.T) {
 		mockRepo.On("ListWarehouses", ctx).Return(activeWarehouses, nil).Once()
 		// WH1
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", productID).Return(stockInWh1, nil).Once()
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, 8).Return(nil).Once() // Ambil semua yang available (8)
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, 8, reservationRef("wh1")).Return(nil).Once() // Ambil semua yang available (8)
+		mockRepo.On("CreateReservation", ctx, mockTx, mock.MatchedBy(func(res *domain.StockReservation) bool {
+			return res.WarehouseID == "wh1" && res.Quantity == 8
+		})).Return(nil).Once()
 		// WH2
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh2", productID).Return(stockInWh2, nil).Once()
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh2", productID, 2).Return(nil).Once() // Ambil sisa (2)
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh2", productID, 2, reservationRef("wh2")).Return(nil).Once() // Ambil sisa (2)
+		mockRepo.On("CreateReservation", ctx, mockTx, mock.MatchedBy(func(res *domain.StockReservation) bool {
+			return res.WarehouseID == "wh2" && res.Quantity == 2
+		})).Return(nil).Once()
 		mockTx.On("Commit").Return(nil).Once()
 		mockTx.On("Rollback").Return(nil).Maybe()
 
-		err = service.ReserveStock(ctx, productID, qtyToReserveAcross)
+		reservations, err := service.ReserveStock(ctx, domain.ReserveStockRequest{ProductID: productID, Quantity: qtyToReserveAcross, OrderID: orderID})
 		assert.NoError(t, err)
+		assert.Len(t, reservations, 2) // Satu reservasi per gudang
 		mockRepo.AssertExpectations(t)
 		mockTx.AssertExpectations(t)
 	})
@@ -109,19 +396,223 @@ func TestWarehouseService_ReserveStock(t *testing.T) {
 		qtyToReserveTooMuch := 20
 		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
 		mockRepo.On("ListWarehouses", ctx).Return(activeWarehouses, nil).Once()
-		// WH1
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", productID).Return(stockInWh1, nil).Once()
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", productID, 8).Return(nil).Once() // Ambil 8
-		// WH2
 		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh2", productID).Return(stockInWh2, nil).Once()
-		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh2", productID, 3).Return(nil).Once() // Ambil 3
-		// Total direservasi 11, tapi butuh 20. remainingToReserve akan > 0.
+		// Total available 11, tapi butuh 20: ditolak sebelum ada stok yang direservasi.
 		mockTx.On("Rollback").Return(nil).Once() // Commit tidak akan dipanggil
 		mockTx.On("Commit").Return(nil).Maybe()
 
-		err := service.ReserveStock(ctx, productID, qtyToReserveTooMuch)
+		reservations, err := service.ReserveStock(ctx, domain.ReserveStockRequest{ProductID: productID, Quantity: qtyToReserveTooMuch})
 		assert.Error(t, err)
+		assert.Nil(t, reservations)
 		assert.EqualError(t, err, whRepo.ErrInsufficientStock.Error())
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestWarehouseService_ReserveStockBatch(t *testing.T) {
+	ctx := context.TODO()
+	orderID := "order-batch"
+	warehouses := []domain.Warehouse{{ID: "wh1", Name: "WH1", IsActive: true}}
+	req := domain.ReserveStockBatchRequest{
+		OrderID: orderID,
+		Items: []domain.ReserveStockBatchItem{
+			{ProductID: "prod-b", Quantity: 2},
+			{ProductID: "prod-a", Quantity: 1},
+		},
+	}
+
+	t.Run("All lines reserved in one transaction", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("ListWarehouses", ctx).Return(warehouses, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prod-a").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prod-a", Quantity: 5}, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prod-b").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prod-b", Quantity: 5}, nil).Once()
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", "prod-a", 1, reservationRef("wh1")).Return(nil).Once()
+		mockRepo.On("IncreaseReservedStock", ctx, mockTx, "wh1", "prod-b", 2, reservationRef("wh1")).Return(nil).Once()
+		mockRepo.On("CreateReservation", ctx, mockTx, mock.MatchedBy(func(res *domain.StockReservation) bool {
+			return *res.OrderID == orderID && res.Status == domain.ReservationStatusActive
+		})).Return(nil).Twice()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		lines, err := service.ReserveStockBatch(ctx, req)
+		assert.NoError(t, err)
+		// Hasil mengikuti urutan request, bukan urutan penguncian
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "prod-b", lines[0].ProductID)
+		assert.Equal(t, 2, lines[0].Reservations[0].Quantity)
+		assert.Equal(t, "prod-a", lines[1].ProductID)
+		assert.Equal(t, 1, lines[1].Reservations[0].Quantity)
+		mockRepo.AssertExpectations(t)
+		mockTx.AssertExpectations(t)
+	})
+
+	t.Run("One short line rolls back the whole batch", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("ListWarehouses", ctx).Return(warehouses, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prod-a").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prod-a", Quantity: 5}, nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh1", "prod-b").
+			Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prod-b", Quantity: 1}, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		lines, err := service.ReserveStockBatch(ctx, req)
+		assert.ErrorIs(t, err, whRepo.ErrInsufficientStock)
+		assert.Contains(t, err.Error(), "prod-b")
+		assert.Nil(t, lines)
+		mockRepo.AssertExpectations(t)
+		mockTx.AssertExpectations(t)
+		mockTx.AssertNotCalled(t, "Commit")
+		mockRepo.AssertNotCalled(t, "IncreaseReservedStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWarehouseService_SettleReservation(t *testing.T) {
+	ctx := context.TODO()
+	orderID := "order-1"
+	activeReservation := func() *domain.StockReservation {
+		return &domain.StockReservation{ID: "res-1", OrderID: &orderID, WarehouseID: "wh2", ProductID: "prodA", Quantity: 3, Status: domain.ReservationStatusActive}
+	}
+
+	t.Run("Commit deducts only from the reserved warehouse", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetReservationForUpdate", ctx, mockTx, "res-1").Return(activeReservation(), nil).Once()
+		mockRepo.On("GetProductStockForUpdate", ctx, mockTx, "wh2", "prodA").Return(&domain.ProductStock{Quantity: 10, ReservedQuantity: 3}, nil).Once()
+		mockRepo.On("DeductCommittedStock", ctx, mockTx, "wh2", "prodA", 3, domain.MovementRef{
+			ReferenceType: domain.MovementRefReservation, ReferenceID: "res-1", Actor: domain.ActorSystem,
+		}).Return(nil).Once()
+		mockRepo.On("UpdateReservationStatus", ctx, mockTx, "res-1", domain.ReservationStatusCommitted).Return(nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		res, err := service.CommitReservation(ctx, "res-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ReservationStatusCommitted, res.Status)
+		mockRepo.AssertExpectations(t)
+		mockTx.AssertExpectations(t)
+	})
+
+	t.Run("Release of an already released reservation is a no-op", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		released := activeReservation()
+		released.Status = domain.ReservationStatusReleased
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetReservationForUpdate", ctx, mockTx, "res-1").Return(released, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		res, err := service.ReleaseReservation(ctx, "res-1")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ReservationStatusReleased, res.Status)
+		mockRepo.AssertNotCalled(t, "DecreaseReservedStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Committed reservation cannot be released", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		committed := activeReservation()
+		committed.Status = domain.ReservationStatusCommitted
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("GetReservationForUpdate", ctx, mockTx, "res-1").Return(committed, nil).Once()
+		mockTx.On("Rollback").Return(nil).Once()
+
+		_, err := service.ReleaseReservation(ctx, "res-1")
+		assert.ErrorIs(t, err, ErrReservationNotActive)
+	})
+}
+
+func TestWarehouseService_ReceiveReturn(t *testing.T) {
+	ctx := context.TODO()
+	req := domain.ReceiveReturnRequest{ReturnReference: "ret-item-1", WarehouseID: "wh1", ProductID: "prodA", Quantity: 2}
+	activeWh := &domain.Warehouse{ID: "wh1", IsActive: true}
+
+	t.Run("Records the return and increases stock quantity", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(activeWh, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockReturn", ctx, mockTx, mock.MatchedBy(func(r *domain.StockReturn) bool {
+			return r.ReturnReference == "ret-item-1" && r.Quantity == 2
+		})).Return(nil).Once()
+		mockRepo.On("AddReturnedStock", ctx, mockTx, "wh1", "prodA", 2, mock.MatchedBy(func(ref domain.MovementRef) bool {
+			return ref.ReferenceType == domain.MovementRefStockReturn && ref.ReferenceID == "ret-item-1"
+		})).Return(&domain.ProductStock{WarehouseID: "wh1", ProductID: "prodA", Quantity: 7}, nil).Once()
+		mockTx.On("Commit").Return(nil).Once()
+		mockTx.On("Rollback").Return(nil).Maybe()
+
+		resp, err := service.ReceiveReturn(ctx, req)
+		assert.NoError(t, err)
+		assert.False(t, resp.Replayed)
+		assert.Equal(t, 7, resp.Stock.Quantity)
+		mockRepo.AssertExpectations(t)
+		mockTx.AssertExpectations(t)
+	})
+
+	t.Run("Replayed reference does not add stock again", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(activeWh, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockReturn", ctx, mockTx, mock.Anything).Return(whRepo.ErrStockReturnExists).Once()
+		mockRepo.On("GetStockReturnByReference", ctx, "ret-item-1").Return(&domain.StockReturn{
+			ID: "sr-1", ReturnReference: "ret-item-1", WarehouseID: "wh1", ProductID: "prodA", Quantity: 2,
+		}, nil).Once()
+		mockTx.On("Rollback").Return(nil)
+
+		resp, err := service.ReceiveReturn(ctx, req)
+		assert.NoError(t, err)
+		assert.True(t, resp.Replayed)
+		assert.Equal(t, "sr-1", resp.Return.ID)
+		mockRepo.AssertNotCalled(t, "AddReturnedStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reference reused with different data is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		mockTx := new(mocks.MockDBTX)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(activeWh, nil).Once()
+		mockRepo.On("BeginTx", ctx).Return(mockTx, nil).Once()
+		mockRepo.On("CreateStockReturn", ctx, mockTx, mock.Anything).Return(whRepo.ErrStockReturnExists).Once()
+		mockRepo.On("GetStockReturnByReference", ctx, "ret-item-1").Return(&domain.StockReturn{
+			ReturnReference: "ret-item-1", WarehouseID: "wh1", ProductID: "prodA", Quantity: 5,
+		}, nil).Once()
+		mockTx.On("Rollback").Return(nil)
+
+		_, err := service.ReceiveReturn(ctx, req)
+		assert.ErrorIs(t, err, ErrReturnReferenceReused)
+	})
+
+	t.Run("Inactive warehouse is rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockWarehouseRepository)
+		service := NewWarehouseService(mockRepo)
+
+		mockRepo.On("GetWarehouseByID", ctx, "wh1").Return(&domain.Warehouse{ID: "wh1", IsActive: false}, nil).Once()
+
+		_, err := service.ReceiveReturn(ctx, req)
+		assert.ErrorIs(t, err, ErrWarehouseInactive)
+		mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+	})
+}
@@ -0,0 +1,28 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- Satu keranjang per user. Keranjang guest tidak punya user_id dan diakses lewat token acak;
+-- yang disimpan hanya hash SHA-256 token tersebut.
+CREATE TABLE IF NOT EXISTS carts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID UNIQUE,
+    guest_token_hash CHAR(64) UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT carts_owner_check CHECK ((user_id IS NULL) <> (guest_token_hash IS NULL))
+);
+
+-- Index untuk pembersihan keranjang guest yang sudah lama tidak disentuh
+CREATE INDEX IF NOT EXISTS idx_carts_guest_updated_at ON carts(updated_at) WHERE user_id IS NULL;
+
+-- price_snapshot adalah harga satuan saat item terakhir ditambahkan, dikirim ke Order Service saat checkout
+-- agar perubahan harga setelahnya terdeteksi.
+CREATE TABLE IF NOT EXISTS cart_items (
+    cart_id UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    price_snapshot DECIMAL(10, 2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'IDR',
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cart_id, product_id)
+);
@@ -0,0 +1,15 @@
+-- Idempotency-Key yang pernah dipakai beserta response-nya, agar retry request tidak diproses dua kali.
+-- scope berisi method + rute (dan user jika ada), sehingga key yang sama di endpoint lain tidak saling bentrok.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT, -- NULL selama request pertama masih diproses
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
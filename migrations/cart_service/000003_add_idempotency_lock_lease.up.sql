@@ -0,0 +1,4 @@
+-- Batas klaim request yang masih diproses (status_code NULL). Klaim yang ditinggalkan karena proses mati
+-- bisa diambil alih setelah locked_until, terpisah dari expires_at yang menyimpan response selesai.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+UPDATE idempotency_keys SET locked_until = created_at + INTERVAL '2 minutes' WHERE status_code IS NULL;
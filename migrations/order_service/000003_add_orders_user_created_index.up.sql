@@ -0,0 +1,2 @@
+-- Mendukung keyset pagination daftar order per user (created_at DESC, id DESC)
+CREATE INDEX IF NOT EXISTS idx_orders_user_created_id ON orders(user_id, created_at DESC, id DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_order_stock_deductions_order_id;
+DROP TABLE IF EXISTS order_stock_deductions;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS refund_required,
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancellation_reason;
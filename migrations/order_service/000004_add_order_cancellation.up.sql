@@ -0,0 +1,17 @@
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS cancellation_reason TEXT,
+    ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS refund_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Mencatat gudang asal setiap pengurangan stok saat pembayaran dikonfirmasi,
+-- agar pembatalan setelah pembayaran bisa mengembalikan stok ke gudang yang sama.
+CREATE TABLE IF NOT EXISTS order_stock_deductions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL,
+    warehouse_id UUID NOT NULL, -- Merujuk ke ID gudang dari Warehouse Service
+    quantity INT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_stock_deductions_order_id ON order_stock_deductions(order_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status order_status, -- NULL untuk status awal saat order dibuat
+    to_status order_status NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id, created_at);
+
+-- Riwayat awal untuk order yang sudah ada sebelum tabel ini dibuat
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, created_at)
+SELECT id, NULL, status, 'system:migration', 'status before history tracking', updated_at
+FROM orders;
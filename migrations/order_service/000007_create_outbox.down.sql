@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_aggregate_id;
+DROP INDEX IF EXISTS idx_outbox_pending;
+DROP TABLE IF EXISTS outbox;
+DROP TYPE IF EXISTS outbox_status;
@@ -0,0 +1,23 @@
+CREATE TYPE outbox_status AS ENUM (
+    'PENDING',
+    'PUBLISHED',
+    'DEAD'
+);
+
+-- Transactional outbox: event ditulis dalam transaksi yang sama dengan perubahan order,
+-- lalu dikirim oleh relay (at-least-once) ke EventPublisher.
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    aggregate_id UUID NOT NULL, -- ID order
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status outbox_status NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_outbox_aggregate_id ON outbox(aggregate_id, created_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_sagas_status;
+DROP INDEX IF EXISTS idx_sagas_due;
+DROP TABLE IF EXISTS sagas;
+DROP TYPE IF EXISTS saga_status;
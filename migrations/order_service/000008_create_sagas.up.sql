@@ -0,0 +1,41 @@
+CREATE TYPE saga_status AS ENUM (
+    'RUNNING',
+    'WAITING',
+    'COMPENSATING',
+    'COMPLETED',
+    'COMPENSATED',
+    'STUCK'
+);
+
+-- Saga checkout: status setiap langkah (reserve_stock, create_order, payment, commit_stock) disimpan di kolom steps,
+-- sehingga saga yang sedang berjalan bisa dilanjutkan setelah restart.
+-- order_id tidak memakai foreign key karena saga dimulai sebelum order tersimpan.
+CREATE TABLE IF NOT EXISTS sagas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    saga_type VARCHAR(50) NOT NULL,
+    order_id UUID NOT NULL,
+    status saga_status NOT NULL DEFAULT 'RUNNING',
+    current_step INT NOT NULL DEFAULT 0,
+    steps JSONB NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (saga_type, order_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sagas_due ON sagas(next_attempt_at) WHERE status IN ('RUNNING', 'COMPENSATING', 'WAITING');
+CREATE INDEX IF NOT EXISTS idx_sagas_status ON sagas(status, updated_at);
+
+-- Order yang masih menunggu pembayaran sebelum tabel ini dibuat: stok sudah direservasi dan order sudah tersimpan,
+-- sehingga saga-nya dimulai dari langkah payment.
+INSERT INTO sagas (saga_type, order_id, status, current_step, steps)
+SELECT 'checkout', id, 'WAITING', 2,
+       '[{"name": "reserve_stock", "status": "SUCCEEDED", "attempts": 1},
+         {"name": "create_order", "status": "SUCCEEDED", "attempts": 1},
+         {"name": "payment", "status": "PENDING", "attempts": 1},
+         {"name": "commit_stock", "status": "PENDING", "attempts": 0}]'::jsonb
+FROM orders
+WHERE status = 'PENDING_PAYMENT';
@@ -0,0 +1,3 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS sku,
+    DROP COLUMN IF EXISTS product_name;
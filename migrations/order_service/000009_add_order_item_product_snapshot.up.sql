@@ -0,0 +1,5 @@
+-- Snapshot nama dan SKU produk saat dibeli, bersama price_at_purchase.
+-- Item lama tidak punya snapshot sehingga diisi string kosong.
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS product_name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(64) NOT NULL DEFAULT '';
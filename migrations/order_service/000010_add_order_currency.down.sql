@@ -0,0 +1,2 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
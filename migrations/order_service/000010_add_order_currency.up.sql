@@ -0,0 +1,3 @@
+-- Mata uang total_amount dan price_at_purchase (ISO 4217). Order lama dibuat dalam rupiah.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
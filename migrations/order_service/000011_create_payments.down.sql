@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS payment_webhook_events;
+DROP INDEX IF EXISTS idx_payments_order_open;
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,28 @@
+-- Pembayaran order lewat payment provider. Satu order bisa punya beberapa percobaan pembayaran,
+-- tetapi paling banyak satu yang masih PENDING atau sudah SUCCEEDED.
+CREATE TABLE IF NOT EXISTS payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    provider_payment_id VARCHAR(255) NOT NULL, -- ID payment intent di sisi provider
+    amount DECIMAL(10, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL, -- PENDING, SUCCEEDED, FAILED
+    checkout_url TEXT, -- Halaman pembayaran dari provider untuk customer
+    failure_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_payment_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_order_open ON payments(order_id) WHERE status IN ('PENDING', 'SUCCEEDED');
+
+-- Event webhook yang sudah diproses. Callback yang dikirim ulang (retry provider atau replay) dengan event ID yang sama diabaikan.
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    provider VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
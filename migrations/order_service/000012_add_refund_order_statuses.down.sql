@@ -0,0 +1,26 @@
+-- Postgres tidak bisa menghapus nilai enum, sehingga tipe dibuat ulang tanpa status refund.
+-- Order yang sudah di-refund dikembalikan ke DELIVERED.
+UPDATE orders SET status = 'DELIVERED' WHERE status IN ('PARTIALLY_REFUNDED', 'REFUNDED');
+DELETE FROM order_status_history WHERE to_status IN ('PARTIALLY_REFUNDED', 'REFUNDED');
+UPDATE order_status_history SET from_status = 'DELIVERED' WHERE from_status IN ('PARTIALLY_REFUNDED', 'REFUNDED');
+
+ALTER TYPE order_status RENAME TO order_status_old;
+CREATE TYPE order_status AS ENUM (
+    'PENDING_PAYMENT',
+    'AWAITING_SHIPMENT',
+    'SHIPPED',
+    'DELIVERED',
+    'CANCELLED',
+    'FAILED',
+    'PAYMENT_TIMEOUT',
+    'PAYMENT_CONFIRMED'
+);
+
+ALTER TABLE orders ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN status TYPE order_status USING status::text::order_status;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'PENDING_PAYMENT';
+ALTER TABLE order_status_history
+    ALTER COLUMN from_status TYPE order_status USING from_status::text::order_status,
+    ALTER COLUMN to_status TYPE order_status USING to_status::text::order_status;
+
+DROP TYPE order_status_old;
@@ -0,0 +1,4 @@
+-- Status order setelah retur/refund. ADD VALUE diletakkan di migrasi tersendiri karena nilai enum baru
+-- belum bisa dipakai di transaksi yang sama dengan penambahannya.
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'PARTIALLY_REFUNDED';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'REFUNDED';
@@ -0,0 +1,4 @@
+ALTER TABLE IF EXISTS return_requests DROP CONSTRAINT IF EXISTS fk_return_requests_refund;
+DROP TABLE IF EXISTS refunds;
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS return_requests;
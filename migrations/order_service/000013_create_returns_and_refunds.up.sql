@@ -0,0 +1,55 @@
+-- Pengajuan retur (RMA) per order. Satu order bisa punya beberapa retur selama total quantity
+-- per item (di luar retur REJECTED) tidak melebihi quantity yang dibeli.
+CREATE TABLE IF NOT EXISTS return_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'REQUESTED', -- REQUESTED, APPROVED, REJECTED, RECEIVED, REFUNDED
+    note TEXT,
+    rejection_reason TEXT,
+    warehouse_id UUID, -- Gudang penerima barang retur (Warehouse Service)
+    refund_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_requests_order_id ON return_requests(order_id);
+CREATE INDEX IF NOT EXISTS idx_return_requests_status ON return_requests(status, created_at);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    return_id UUID NOT NULL REFERENCES return_requests(id) ON DELETE CASCADE,
+    order_item_id UUID NOT NULL REFERENCES order_items(id),
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    reason TEXT NOT NULL,
+    condition VARCHAR(20) NOT NULL, -- UNOPENED, OPENED, DAMAGED
+    refund_amount DECIMAL(12, 2) NOT NULL,
+    currency CHAR(3) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_return_items_return_id ON return_items(return_id);
+CREATE INDEX IF NOT EXISTS idx_return_items_order_item_id ON return_items(order_item_id);
+
+-- Pengembalian dana atas payment yang SUCCEEDED, penuh atau sebagian.
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id),
+    return_id UUID REFERENCES return_requests(id),
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, SUCCEEDED, FAILED
+    reason TEXT NOT NULL,
+    provider_refund_id VARCHAR(255),
+    failure_reason TEXT,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+
+ALTER TABLE return_requests
+    ADD CONSTRAINT fk_return_requests_refund FOREIGN KEY (refund_id) REFERENCES refunds(id);
@@ -0,0 +1,28 @@
+-- Postgres tidak bisa menghapus nilai enum, sehingga tipe dibuat ulang tanpa PARTIALLY_SHIPPED.
+-- Order yang baru dikirim sebagian dikembalikan ke AWAITING_SHIPMENT.
+UPDATE orders SET status = 'AWAITING_SHIPMENT' WHERE status = 'PARTIALLY_SHIPPED';
+DELETE FROM order_status_history WHERE to_status = 'PARTIALLY_SHIPPED';
+UPDATE order_status_history SET from_status = 'AWAITING_SHIPMENT' WHERE from_status = 'PARTIALLY_SHIPPED';
+
+ALTER TYPE order_status RENAME TO order_status_old;
+CREATE TYPE order_status AS ENUM (
+    'PENDING_PAYMENT',
+    'AWAITING_SHIPMENT',
+    'SHIPPED',
+    'DELIVERED',
+    'CANCELLED',
+    'FAILED',
+    'PAYMENT_TIMEOUT',
+    'PAYMENT_CONFIRMED',
+    'PARTIALLY_REFUNDED',
+    'REFUNDED'
+);
+
+ALTER TABLE orders ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN status TYPE order_status USING status::text::order_status;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'PENDING_PAYMENT';
+ALTER TABLE order_status_history
+    ALTER COLUMN from_status TYPE order_status USING from_status::text::order_status,
+    ALTER COLUMN to_status TYPE order_status USING to_status::text::order_status;
+
+DROP TYPE order_status_old;
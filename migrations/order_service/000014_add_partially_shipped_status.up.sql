@@ -0,0 +1,2 @@
+-- Order yang sebagian shipment-nya sudah dikirim (order dengan stok dari beberapa gudang).
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'PARTIALLY_SHIPPED';
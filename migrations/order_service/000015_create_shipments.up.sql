@@ -0,0 +1,29 @@
+-- Satu shipment per gudang asal stok order (lihat order_stock_deductions).
+CREATE TABLE IF NOT EXISTS shipments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, SHIPPED, DELIVERED, CANCELLED
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    shipped_by VARCHAR(255),
+    shipped_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_shipments_order_warehouse UNIQUE (order_id, warehouse_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipments_warehouse_status ON shipments(warehouse_id, status, created_at);
+
+-- Isi paket: produk dan quantity yang dikirim dari gudang tersebut.
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    shipment_id UUID NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL,
+    product_name VARCHAR(255) NOT NULL DEFAULT '',
+    sku VARCHAR(64) NOT NULL DEFAULT '',
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_items_shipment_id ON shipment_items(shipment_id);
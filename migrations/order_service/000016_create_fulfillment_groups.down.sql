@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_fulfillment_group_items;
+DROP TABLE IF EXISTS order_fulfillment_groups;
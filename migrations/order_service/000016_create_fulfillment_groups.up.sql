@@ -0,0 +1,21 @@
+-- Fulfillment group: pembagian item order per gudang yang dihitung saat checkout dari reservasi stok.
+-- Satu group menjadi satu shipment setelah order dibayar.
+CREATE TABLE IF NOT EXISTS order_fulfillment_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL,
+    sequence INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_fulfillment_groups_order_warehouse UNIQUE (order_id, warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_fulfillment_group_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES order_fulfillment_groups(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL,
+    product_name VARCHAR(255) NOT NULL DEFAULT '',
+    sku VARCHAR(64) NOT NULL DEFAULT '',
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fulfillment_group_items_group_id ON order_fulfillment_group_items(group_id);
@@ -0,0 +1 @@
+ALTER TABLE payments ALTER COLUMN amount TYPE DECIMAL(10, 2);
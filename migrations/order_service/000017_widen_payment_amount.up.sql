@@ -0,0 +1,3 @@
+-- payments.amount mengikuti presisi orders.total_amount; DECIMAL(10, 2) overflow untuk total di atas 99.999.999,99
+-- sehingga payment intent order besar tidak pernah bisa dibuat.
+ALTER TABLE payments ALTER COLUMN amount TYPE DECIMAL(12, 2);
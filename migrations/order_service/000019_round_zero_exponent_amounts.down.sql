@@ -0,0 +1,3 @@
+-- Pembulatan data tidak dikembalikan; hanya constraint yang dilepas.
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS chk_order_items_price_scale;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS chk_orders_total_amount_scale;
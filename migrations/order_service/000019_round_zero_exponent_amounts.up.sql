@@ -0,0 +1,15 @@
+-- Order lama ditandai IDR (000010) padahal kolomnya DECIMAL dengan 2 digit dan bisa berisi sen (misal seed 1252.48).
+-- Nilai tersebut dibulatkan setengah menjauhi nol, sama seperti yang selama ini dilaporkan API dan dipakai
+-- untuk nominal payment dan batas refund. Total dan harga item dibulatkan sendiri-sendiri agar total order
+-- tetap sama dengan payment yang mungkin sudah dibuat.
+UPDATE orders SET total_amount = ROUND(total_amount)
+WHERE currency IN ('IDR', 'JPY') AND total_amount <> ROUND(total_amount);
+
+UPDATE order_items SET price_at_purchase = ROUND(price_at_purchase)
+WHERE currency IN ('IDR', 'JPY') AND price_at_purchase <> ROUND(price_at_purchase);
+
+-- Mata uang tanpa minor unit tidak boleh menyimpan pecahan lagi
+ALTER TABLE orders ADD CONSTRAINT chk_orders_total_amount_scale
+    CHECK (currency NOT IN ('IDR', 'JPY') OR total_amount = ROUND(total_amount));
+ALTER TABLE order_items ADD CONSTRAINT chk_order_items_price_scale
+    CHECK (currency NOT IN ('IDR', 'JPY') OR price_at_purchase = ROUND(price_at_purchase));
@@ -0,0 +1,3 @@
+-- Token unik per klaim key. Menyimpan response dan melepas klaim hanya berlaku untuk pemilik klaim saat ini,
+-- sehingga request lama yang lease-nya habis tidak bisa menimpa atau menghapus klaim dari retry berikutnya.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS claim_token VARCHAR(64);
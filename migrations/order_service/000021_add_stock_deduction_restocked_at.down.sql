@@ -0,0 +1 @@
+ALTER TABLE order_stock_deductions DROP COLUMN IF EXISTS restocked_at;
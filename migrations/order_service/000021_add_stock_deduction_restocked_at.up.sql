@@ -0,0 +1,2 @@
+-- Pengurangan stok yang sudah dikembalikan ke gudang (saga cancel_restock), agar restock yang diulang melewatinya.
+ALTER TABLE order_stock_deductions ADD COLUMN IF NOT EXISTS restocked_at TIMESTAMPTZ;
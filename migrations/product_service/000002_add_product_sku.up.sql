@@ -0,0 +1,10 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+
+-- SKU untuk seed data; produk lain mendapat SKU sementara dari ID-nya
+UPDATE products SET sku = 'LAP-16GB-001' WHERE id = 'c0eebc99-9c0b-4ef8-bb6d-6bb9bd380a31';
+UPDATE products SET sku = 'MOU-ERGO-001' WHERE id = 'c0eebc99-9c0b-4ef8-bb6d-6bb9bd380a32';
+UPDATE products SET sku = 'KEY-RGB-001' WHERE id = 'c0eebc99-9c0b-4ef8-bb6d-6bb9bd380a33';
+UPDATE products SET sku = 'SKU-' || UPPER(REPLACE(id::text, '-', '')) WHERE sku IS NULL;
+
+ALTER TABLE products ALTER COLUMN sku SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products(sku);
@@ -0,0 +1,2 @@
+-- Harga produk disimpan sebagai DECIMAL dalam mata uang ISO 4217 di kolom currency
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
@@ -0,0 +1,2 @@
+-- Pembulatan data tidak dikembalikan; hanya constraint yang dilepas.
+ALTER TABLE products DROP CONSTRAINT IF EXISTS chk_products_price_scale;
@@ -0,0 +1,8 @@
+-- Harga produk lama ditandai IDR (000003) padahal kolomnya DECIMAL dengan 2 digit dan bisa berisi sen.
+-- Dibulatkan setengah menjauhi nol, sama seperti yang selama ini dilaporkan API.
+UPDATE products SET price = ROUND(price)
+WHERE currency IN ('IDR', 'JPY') AND price <> ROUND(price);
+
+-- Mata uang tanpa minor unit tidak boleh menyimpan pecahan lagi
+ALTER TABLE products ADD CONSTRAINT chk_products_price_scale
+    CHECK (currency NOT IN ('IDR', 'JPY') OR price = ROUND(price));
@@ -0,0 +1,6 @@
+-- Role dipakai oleh service lain (via JWT / header gateway) untuk otorisasi, misal admin boleh bertindak atas nama user lain.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer';
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('customer', 'admin'));
+
+-- Untuk menjadikan user sebagai admin:
+-- UPDATE users SET role = 'admin' WHERE email = '...';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_stock_reservations_active_expiry;
+DROP INDEX IF EXISTS idx_stock_reservations_order_id;
+DROP TABLE IF EXISTS stock_reservations;
+DROP TYPE IF EXISTS reservation_status;
@@ -0,0 +1,22 @@
+CREATE TYPE reservation_status AS ENUM (
+    'ACTIVE',
+    'COMMITTED',
+    'RELEASED'
+);
+
+-- Satu baris per alokasi stok (order x gudang x produk), agar commit/release
+-- hanya menyentuh stok yang memang direservasi untuk order tersebut.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID, -- Merujuk ke ID order dari Order Service (NULL untuk reservasi tanpa order)
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status reservation_status NOT NULL DEFAULT 'ACTIVE',
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_order_id ON stock_reservations(order_id);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_active_expiry ON stock_reservations(expires_at) WHERE status = 'ACTIVE';
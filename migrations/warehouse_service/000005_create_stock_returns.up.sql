@@ -0,0 +1,12 @@
+-- Barang retur yang diterima kembali ke gudang. return_reference berasal dari
+-- Order Service (ID return item), sehingga penerimaan ulang tidak menambah stok dua kali.
+CREATE TABLE IF NOT EXISTS stock_returns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    return_reference VARCHAR(255) NOT NULL UNIQUE,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_returns_product ON stock_returns(product_id);
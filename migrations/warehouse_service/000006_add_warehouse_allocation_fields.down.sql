@@ -0,0 +1,5 @@
+ALTER TABLE warehouses DROP CONSTRAINT IF EXISTS chk_warehouses_coordinates;
+ALTER TABLE warehouses
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude,
+    DROP COLUMN IF EXISTS priority;
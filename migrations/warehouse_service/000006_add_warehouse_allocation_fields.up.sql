@@ -0,0 +1,14 @@
+-- Prioritas dan koordinat gudang untuk strategi alokasi stok. Prioritas dengan angka lebih kecil didahulukan.
+ALTER TABLE warehouses
+    ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- Koordinat diisi berpasangan atau tidak sama sekali
+ALTER TABLE warehouses ADD CONSTRAINT chk_warehouses_coordinates CHECK (
+    (latitude IS NULL AND longitude IS NULL) OR
+    (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+UPDATE warehouses SET priority = 10, latitude = -6.2088, longitude = 106.8456 WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a21';
+UPDATE warehouses SET priority = 20, latitude = -7.2575, longitude = 112.7521 WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a22';
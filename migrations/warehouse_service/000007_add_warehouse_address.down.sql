@@ -0,0 +1,6 @@
+ALTER TABLE warehouses
+    DROP COLUMN IF EXISTS address_country,
+    DROP COLUMN IF EXISTS address_postal_code,
+    DROP COLUMN IF EXISTS address_province,
+    DROP COLUMN IF EXISTS address_city,
+    DROP COLUMN IF EXISTS address_street;
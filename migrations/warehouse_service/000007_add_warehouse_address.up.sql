@@ -0,0 +1,12 @@
+-- Alamat terstruktur gudang. Kolom location (teks bebas) tetap ada untuk data lama.
+ALTER TABLE warehouses
+    ADD COLUMN IF NOT EXISTS address_street VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS address_city VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS address_province VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS address_postal_code VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS address_country CHAR(2);
+
+UPDATE warehouses SET address_city = 'Jakarta', address_province = 'DKI Jakarta', address_country = 'ID'
+WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a21';
+UPDATE warehouses SET address_city = 'Surabaya', address_province = 'Jawa Timur', address_country = 'ID'
+WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a22';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_warehouses_not_deleted;
+
+ALTER TABLE warehouses
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS metadata;
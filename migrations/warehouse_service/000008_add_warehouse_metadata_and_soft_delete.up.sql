@@ -0,0 +1,7 @@
+-- metadata: atribut bebas gudang (misal kode cabang, jam operasional) yang bisa diubah lewat PATCH.
+-- deleted_at: gudang yang di-decommission tidak dihapus agar reservasi, retur dan order lama tetap bisa merujuknya.
+ALTER TABLE warehouses
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_warehouses_not_deleted ON warehouses(name) WHERE deleted_at IS NULL;
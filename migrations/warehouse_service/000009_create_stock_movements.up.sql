@@ -0,0 +1,34 @@
+-- Buku besar pergerakan stok: satu baris untuk setiap perubahan product_stocks, ditulis di transaksi
+-- yang sama dengan perubahannya. Baris tidak pernah diubah atau dihapus (lihat trigger di bawah).
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    movement_type VARCHAR(30) NOT NULL,
+    quantity_delta INT NOT NULL,
+    reserved_delta INT NOT NULL,
+    quantity_before INT NOT NULL,
+    quantity_after INT NOT NULL,
+    reserved_before INT NOT NULL,
+    reserved_after INT NOT NULL,
+    reference_type VARCHAR(30),
+    reference_id VARCHAR(255),
+    actor VARCHAR(255) NOT NULL DEFAULT 'system',
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_stock_movements_quantity CHECK (quantity_after = quantity_before + quantity_delta),
+    CONSTRAINT chk_stock_movements_reserved CHECK (reserved_after = reserved_before + reserved_delta)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_stock ON stock_movements(warehouse_id, product_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_reference ON stock_movements(reference_type, reference_id);
+
+CREATE OR REPLACE FUNCTION stock_movements_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_stock_movements_immutable
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION stock_movements_immutable();
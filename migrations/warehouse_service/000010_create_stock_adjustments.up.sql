@@ -0,0 +1,25 @@
+-- Penyesuaian stok manual (barang rusak, hilang, ditemukan, koreksi hitung). Penyesuaian di atas
+-- ambang batas menunggu persetujuan orang kedua sebelum mengubah product_stocks.
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    quantity_delta INT NOT NULL CHECK (quantity_delta <> 0),
+    reason_code VARCHAR(30) NOT NULL CHECK (reason_code IN ('DAMAGE', 'SHRINKAGE', 'COUNT_CORRECTION', 'FOUND')),
+    note TEXT,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('PENDING_APPROVAL', 'APPLIED', 'REJECTED')),
+    requested_by VARCHAR(255) NOT NULL,
+    reviewed_by VARCHAR(255),
+    review_note TEXT,
+    reviewed_at TIMESTAMPTZ,
+    applied_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Penyesuaian yang disetujui tidak boleh disetujui oleh pengaju sendiri
+    CONSTRAINT chk_stock_adjustments_four_eyes CHECK (
+        status <> 'APPLIED' OR reviewed_by IS NULL OR reviewed_by <> requested_by
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_warehouse ON stock_adjustments(warehouse_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_pending ON stock_adjustments(warehouse_id) WHERE status = 'PENDING_APPROVAL';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stock_count_lines;
+DROP TABLE IF EXISTS stock_counts;
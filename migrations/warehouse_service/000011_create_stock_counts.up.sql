@@ -0,0 +1,33 @@
+-- Sesi stocktake/cycle count. Setiap baris menyimpan snapshot quantity saat sesi dibuka dan hasil hitung fisik.
+CREATE TABLE IF NOT EXISTS stock_counts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'CLOSED', 'CANCELLED')),
+    full_scope BOOLEAN NOT NULL,
+    note TEXT,
+    opened_by VARCHAR(255) NOT NULL,
+    closed_by VARCHAR(255),
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_counts_warehouse ON stock_counts(warehouse_id, created_at DESC);
+-- Hanya satu sesi menyeluruh yang boleh terbuka per gudang
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_counts_open_full_scope ON stock_counts(warehouse_id) WHERE status = 'OPEN' AND full_scope;
+
+CREATE TABLE IF NOT EXISTS stock_count_lines (
+    count_id UUID NOT NULL REFERENCES stock_counts(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    expected_quantity INT NOT NULL,
+    counted_quantity INT CHECK (counted_quantity >= 0),
+    counted_by VARCHAR(255),
+    counted_at TIMESTAMPTZ,
+    adjustment_id UUID REFERENCES stock_adjustments(id),
+    is_open BOOLEAN NOT NULL DEFAULT TRUE, -- false setelah sesi ditutup atau dibatalkan
+    PRIMARY KEY (count_id, product_id)
+);
+
+-- Satu produk di satu gudang hanya boleh dihitung oleh satu sesi terbuka
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_count_lines_open ON stock_count_lines(warehouse_id, product_id) WHERE is_open;
@@ -0,0 +1,28 @@
+-- Transfer antar gudang dua fase: stok keluar dari gudang asal saat dikirim dan baru masuk ke gudang tujuan
+-- saat diterima. Selama IN_TRANSIT, quantity tidak tercatat di product_stocks gudang mana pun.
+CREATE TABLE IF NOT EXISTS transfer_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL,
+    source_warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    target_warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'REQUESTED'
+        CHECK (status IN ('REQUESTED', 'PICKED', 'IN_TRANSIT', 'RECEIVED', 'CANCELLED')),
+    received_quantity INT CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    discrepancy_quantity INT NOT NULL DEFAULT 0, -- quantity - received_quantity, diisi saat diterima
+    discrepancy_note TEXT,
+    reason TEXT,
+    requested_by VARCHAR(255) NOT NULL,
+    picked_at TIMESTAMPTZ,
+    dispatched_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_transfer_orders_distinct_warehouses CHECK (source_warehouse_id <> target_warehouse_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_orders_source ON transfer_orders(source_warehouse_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_transfer_orders_target ON transfer_orders(target_warehouse_id, created_at DESC);
+-- Untuk laporan stok dalam perjalanan per produk
+CREATE INDEX IF NOT EXISTS idx_transfer_orders_in_transit ON transfer_orders(product_id) WHERE status = 'IN_TRANSIT';
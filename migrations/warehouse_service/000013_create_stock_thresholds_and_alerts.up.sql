@@ -0,0 +1,41 @@
+-- Ambang batas stok per produk per gudang. Alert dibuat saat stok tersedia (quantity - reserved_quantity)
+-- turun ke reorder_point atau ke safety_stock.
+CREATE TABLE IF NOT EXISTS stock_thresholds (
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    reorder_point INT NOT NULL CHECK (reorder_point >= 0),
+    safety_stock INT NOT NULL DEFAULT 0 CHECK (safety_stock >= 0),
+    reorder_quantity INT NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0),
+    -- Level hasil evaluasi terakhir (NULL = di atas reorder_point); alert baru hanya dibuat saat level memburuk
+    last_alert_level VARCHAR(20) CHECK (last_alert_level IN ('LOW', 'CRITICAL')),
+    updated_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (warehouse_id, product_id),
+    CONSTRAINT chk_stock_thresholds_safety_stock CHECK (safety_stock <= reorder_point)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_thresholds_product ON stock_thresholds(product_id);
+
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    product_id UUID NOT NULL,
+    level VARCHAR(20) NOT NULL CHECK (level IN ('LOW', 'CRITICAL')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('OPEN', 'ACKNOWLEDGED', 'RESOLVED')),
+    available_quantity INT NOT NULL, -- Stok tersedia pada evaluasi terakhir
+    reorder_point INT NOT NULL,
+    safety_stock INT NOT NULL,
+    reorder_quantity INT NOT NULL,
+    acknowledged_by VARCHAR(255),
+    acknowledged_at TIMESTAMPTZ,
+    resolved_by VARCHAR(255),
+    resolved_at TIMESTAMPTZ,
+    resolution_note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Paling banyak satu alert yang belum selesai per produk per gudang
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_alerts_unresolved ON stock_alerts(warehouse_id, product_id) WHERE status <> 'RESOLVED';
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_warehouse ON stock_alerts(warehouse_id, created_at DESC);